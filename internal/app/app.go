@@ -46,6 +46,7 @@ type repositories struct {
 	learningLog        *repository.LearningLogRepository
 	quiz               *repository.QuizRepository
 	achievement        *repository.AchievementRepository
+	season             *repository.SeasonRepository
 	post               *repository.PostRepository
 	comment            *repository.CommentRepository
 	question           *repository.QuestionRepository
@@ -72,15 +73,22 @@ type repositories struct {
 	chat               *repository.ChatRepository
 	friendship         *repository.FriendshipRepository
 	communityResource  *repository.CommunityResourceRepository
+	transcodeJob       *repository.TranscodeJobRepository
+	announcement       *repository.AnnouncementRepository
+	notification       *repository.NotificationRepository
 }
 
 type services struct {
 	auth                 *service.AuthService
 	storage              *service.StorageService
 	content              *service.ContentService
+	uploadLimiter        *service.UploadLimiter
+	userStatus           *service.UserStatusCache
+	transcode            *service.TranscodeService
 	motivation           *service.MotivationService
 	dashboard            *service.DashboardService
 	learning             *service.LearningService
+	events               *service.EventBus
 	achievement          *service.AchievementService
 	community            *service.CommunityService
 	analytics            *service.AnalyticsService
@@ -104,6 +112,12 @@ type services struct {
 	ai                   *service.AIService
 	qa                   *service.QAService
 	autoTagging          *service.AutoTaggingService
+	announcement         *service.AnnouncementService
+	ownership            *service.OwnershipService
+	gradingQueue         *service.GradingQueueService
+	notification         *service.NotificationService
+	search               *service.SearchService
+	unread               *service.UnreadService
 }
 
 type controllers struct {
@@ -132,6 +146,11 @@ type controllers struct {
 	chat           *controller.ChatController
 	health         *controller.HealthController
 	qa             *controller.QAController
+	announcement   *controller.AnnouncementController
+	notification   *controller.NotificationController
+	ownership      *controller.OwnershipController
+	search         *controller.SearchController
+	unread         *controller.UnreadController
 }
 
 func (a *App) RegisterConfigCallback(callback func(*config.Config)) {
@@ -142,6 +161,7 @@ func (a *App) initRepositories(db *gorm.DB, rdb *redis.Client) *repositories {
 	return &repositories{
 		user:               repository.NewUserRepository(db),
 		resource:           repository.NewResourceRepository(db),
+		transcodeJob:       repository.NewTranscodeJobRepository(db),
 		task:               repository.NewTaskRepository(db),
 		goal:               repository.NewGoalRepository(db),
 		module:             repository.NewModuleRepository(db),
@@ -149,6 +169,7 @@ func (a *App) initRepositories(db *gorm.DB, rdb *redis.Client) *repositories {
 		learningLog:        repository.NewLearningLogRepository(db),
 		quiz:               repository.NewQuizRepository(db),
 		achievement:        repository.NewAchievementRepository(db),
+		season:             repository.NewSeasonRepository(db),
 		post:               repository.NewPostRepository(db),
 		comment:            repository.NewCommentRepository(db),
 		question:           repository.NewQuestionRepository(db),
@@ -175,6 +196,8 @@ func (a *App) initRepositories(db *gorm.DB, rdb *redis.Client) *repositories {
 		chat:               repository.NewChatRepository(db, rdb),
 		friendship:         repository.NewFriendshipRepository(db, rdb),
 		communityResource:  repository.NewCommunityResourceRepository(db),
+		announcement:       repository.NewAnnouncementRepository(db),
+		notification:       repository.NewNotificationRepository(db),
 	}
 }
 
@@ -182,15 +205,21 @@ func (a *App) initServices(repos *repositories, cfg *config.Config, db *gorm.DB,
 	s := &services{}
 
 	s.storage = service.NewStorageService(cfg)
-	s.auth = service.NewAuthService(repos.user, cfg)
-	s.content = service.NewContentService(repos.resource, s.storage, cfg, rdb)
-	s.motivation = service.NewMotivationService(repos.motivation)
+	passwords := service.NewPasswordPolicy(cfg.Password)
+	s.auth = service.NewAuthService(repos.user, cfg, passwords)
+	ffmpegLimiter := service.NewFFmpegLimiter(cfg.Video.FFmpegConcurrency)
+	s.transcode = service.NewTranscodeService(repos.transcodeJob, repos.resource, s.storage, cfg, ffmpegLimiter)
+	s.userStatus = service.NewUserStatusCache(repos.user, rdb)
+	s.uploadLimiter = service.NewUploadLimiter(rdb, cfg.Upload.MaxConcurrentPerUser, time.Duration(cfg.Storage.UploadTTLMinutes)*time.Minute)
+	s.content = service.NewContentService(repos.resource, s.storage, cfg, rdb, s.transcode, ffmpegLimiter, s.uploadLimiter)
+	s.motivation = service.NewMotivationService(repos.motivation, cfg.Motivation.RotateHour)
 	s.dashboard = service.NewDashboardService(repos.user, repos.task, repos.resource, repos.goal, s.motivation)
 	s.learning = service.NewLearningService(repos.module, repos.task, repos.resource, repos.progress, repos.learningLog, repos.quiz, cfg, db)
-	s.achievement = service.NewAchievementService(repos.achievement, repos.user, repos.goal)
+	s.events = service.NewEventBus()
+	s.achievement = service.NewAchievementService(repos.achievement, repos.user, repos.goal, repos.season, s.events)
 	s.community = service.NewCommunityService(repos.post, repos.comment, repos.question, repos.answer, repos.user, repos.communityResource, rdb, cfg, s.storage)
 	s.analytics = service.NewAnalyticsService(repos.progress, repos.session, repos.skill, repos.learningLog, repos.recommendation, repos.levelAttempt, db)
-	s.user = service.NewUserServiceWithDB(repos.user, repos.checkin, db)
+	s.user = service.NewUserServiceWithDB(repos.user, repos.checkin, db, passwords, s.events)
 	s.captcha = service.NewCaptchaService(rdb, cfg)
 
 	s.task = service.NewTaskService(
@@ -199,6 +228,8 @@ func (a *App) initServices(repos *repositories, cfg *config.Config, db *gorm.DB,
 		repos.exerciseQuestion,
 		repos.cProgrammingRes,
 		repos.goal,
+		db,
+		cfg.Achievement.WeeklyTasksCompletedPoints,
 	)
 
 	s.cProgrammingResource = service.NewCProgrammingResourceService(
@@ -214,27 +245,53 @@ func (a *App) initServices(repos *repositories, cfg *config.Config, db *gorm.DB,
 		db,
 	)
 
-	s.level = service.NewLevelService(repos.level, repos.levelAttempt, s.learning, db)
+	s.level = service.NewLevelService(repos.level, repos.levelAttempt, s.learning, db, s.events)
 	s.knowledgeTag = service.NewKnowledgeTagService(repos.knowledgeTag)
 	s.suggestion = service.NewSuggestionService(repos.suggestion, repos.level, repos.levelAttempt)
 	s.assessment = service.NewAssessmentService(repos.assessment)
 	s.learningPath = service.NewLearningPathService(repos.learningPath, repos.assessment, repos.learningLog, repos.user)
-	s.knowledgePoint = service.NewKnowledgePointService(db)
+	s.knowledgePoint = service.NewKnowledgePointService(db, s.events)
 	s.learningGoal = service.NewLearningGoalService(
 		repos.goal,
 		repos.cProgrammingRes,
 		s.cProgrammingResource,
 		db,
+		s.events,
 	)
 	s.postClassTest = service.NewPostClassTestService(repos.postClassTest, s.user)
 	s.migrationTask = service.NewMigrationTaskService(repos.migrationTask, s.user)
-	s.reflection = service.NewReflectionService(repos.reflection)
+	s.reflection = service.NewReflectionService(repos.reflection, repos.user, s.events)
 
-	s.chatHub = service.NewChatHub(rdb, repos.chat, repos.user, repos.friendship)
+	s.chatHub = service.NewChatHub(rdb, repos.chat, repos.user, repos.friendship, cfg.Chat.ShardCount, cfg.Chat.NodeID, cfg.JWT.Secret)
+	service.SetOriginChecker(security.WebSocketOriginChecker(cfg.CORS))
 	go s.chatHub.Run()
 
-	s.chat = service.NewChatService(repos.chat, rdb)
-	s.friendship = service.NewFriendshipService(repos.friendship, repos.user)
+	s.events.Subscribe(service.EventGoalCompleted, s.achievement.GoalCompletedHandler(cfg.Achievement))
+	s.events.Subscribe(service.EventGoalCompleted, s.user.GoalCompletedHandler(cfg.Achievement.GoalCompletedPoints))
+	if cfg.Achievement.GoalCompletedNotify {
+		s.events.Subscribe(service.EventGoalCompleted, s.chatHub.GoalCompletedHandler())
+	}
+	s.events.Subscribe(service.EventAttemptGraded, s.chatHub.AttemptGradedHandler())
+	s.events.Subscribe(service.EventUserDisabled, s.userStatus.UserDisabledHandler())
+	s.events.Subscribe(service.EventUserDisabled, s.chatHub.UserDisabledHandler())
+
+	s.unread = service.NewUnreadService(repos.chat, repos.notification, s.chatHub)
+	s.notification = service.NewNotificationService(repos.notification, s.chatHub, s.unread)
+	s.events.Subscribe(service.EventFriendRequestSent, s.notification.FriendRequestHandler())
+	s.events.Subscribe(service.EventUserMentioned, s.notification.MentionHandler())
+	s.events.Subscribe(service.EventSubmissionAudited, s.notification.SubmissionAuditedHandler())
+	s.events.Subscribe(service.EventAttemptGraded, s.notification.AttemptGradedHandler())
+	s.events.Subscribe(service.EventAnnouncementPublished, s.notification.AnnouncementHandler())
+	s.events.Subscribe(service.EventGoalCompleted, s.notification.GoalCompletedHandler())
+	s.events.Subscribe(service.EventGoalDeadline, s.notification.GoalDeadlineHandler())
+	s.events.Subscribe(service.EventReflectionCommented, s.notification.ReflectionCommentedHandler())
+
+	s.chat = service.NewChatService(repos.chat, repos.friendship, rdb, s.events, cfg.Chat)
+	s.friendship = service.NewFriendshipService(repos.friendship, repos.user, s.events, cfg.Chat)
+	s.announcement = service.NewAnnouncementService(repos.announcement, repos.user, s.chatHub, s.events)
+	s.ownership = service.NewOwnershipService(db)
+	s.gradingQueue = service.NewGradingQueueService(db)
+	s.search = service.NewSearchService(db)
 
 	s.ai = service.NewAIService(cfg.AI)
 	s.qa = service.NewQAService(db, rdb, s.ai)
@@ -258,7 +315,7 @@ func (a *App) initControllers(s *services, db *gorm.DB) *controllers {
 		learningGoal:   controller.NewLearningGoalController(s.learningGoal),
 		task:           controller.NewTaskController(s.task),
 		level:          controller.NewLevelController(s.level, s.content),
-		grade:          controller.NewGradeController(s.level),
+		grade:          controller.NewGradeController(s.level, s.gradingQueue),
 		suggestion:     controller.NewSuggestionController(s.suggestion),
 		assessment:     controller.NewAssessmentController(s.assessment),
 		learningPath:   controller.NewLearningPathController(s.learningPath),
@@ -267,14 +324,19 @@ func (a *App) initControllers(s *services, db *gorm.DB) *controllers {
 		postClassTest:  controller.NewPostClassTestController(s.postClassTest),
 		migrationTask:  controller.NewMigrationTaskController(s.migrationTask),
 		reflection:     controller.NewReflectionController(s.reflection),
-		chat:           controller.NewChatController(s.chat, s.friendship, s.chatHub, s.storage, a.Config),
+		chat:           controller.NewChatController(s.chat, s.friendship, s.chatHub, s.storage, s.uploadLimiter, s.unread, a.Config),
 		health:         controller.NewHealthController(db),
 		qa:             controller.NewQAController(s.qa),
+		announcement:   controller.NewAnnouncementController(s.announcement),
+		notification:   controller.NewNotificationController(s.notification),
+		ownership:      controller.NewOwnershipController(s.ownership),
+		search:         controller.NewSearchController(s.search),
+		unread:         controller.NewUnreadController(s.unread),
 	}
 }
 
 func (a *App) setupMiddlewares(router *gin.Engine, cfg *config.Config) {
-	router.Use(security.CORS(cfg.CORS.AllowedOrigins))
+	router.Use(security.CORS(cfg.CORS))
 	router.Use(security.Secure())
 
 	// 默认200次/分钟
@@ -297,7 +359,35 @@ func (a *App) setupMiddlewares(router *gin.Engine, cfg *config.Config) {
 }
 
 func (a *App) startBackgroundTasks(s *services) {
-	// 每分钟执行：关卡定时发布
+	// 采集 IM 分片连接数和消息队列积压指标
+	go func() {
+		ticker := time.NewTicker(15 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.chatHub.ReportMetrics()
+			case <-a.stopCh:
+				return
+			}
+		}
+	}()
+
+	// 合并推送未读数变化
+	go func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.unread.FlushChanged()
+			case <-a.stopCh:
+				return
+			}
+		}
+	}()
+
+	// 每分钟执行：关卡定时发布、到期赛季结算、激励短句每日轮换
 	go func() {
 		ticker := time.NewTicker(time.Minute)
 		defer ticker.Stop()
@@ -307,6 +397,14 @@ func (a *App) startBackgroundTasks(s *services) {
 				if err := s.level.ProcessScheduledPublishes(); err != nil {
 					logger.Log.Error("scheduled publish error", zap.Error(err))
 				}
+				if err := s.achievement.CloseExpiredSeasons(); err != nil {
+					logger.Log.Error("close expired seasons error", zap.Error(err))
+				}
+				if rotated, err := s.motivation.RotateIfDue(time.Now()); err != nil {
+					logger.Log.Error("rotate motivation error", zap.Error(err))
+				} else if rotated {
+					logger.Log.Info("daily motivation rotated")
+				}
 			case <-a.stopCh:
 				logger.Log.Info("Background tasks stopped")
 				return
@@ -314,6 +412,169 @@ func (a *App) startBackgroundTasks(s *services) {
 		}
 	}()
 
+	// 定期清理废弃的分片上传
+	go func() {
+		interval := time.Duration(a.Config.Storage.UploadCleanupMinutes) * time.Minute
+		if interval <= 0 {
+			interval = 10 * time.Minute
+		}
+		ttl := time.Duration(a.Config.Storage.UploadTTLMinutes) * time.Minute
+		if ttl <= 0 {
+			ttl = time.Hour
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.content.CleanupStaleUploads(ttl)
+			case <-a.stopCh:
+				return
+			}
+		}
+	}()
+
+	// 重新探测上传时未获取到元数据的视频，FFmpeg 探测较慢，单独运行以免拖慢分片清理
+	go func() {
+		ticker := time.NewTicker(5 * time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.content.ReprobePendingVideos()
+			case <-a.stopCh:
+				return
+			}
+		}
+	}()
+
+	// 按保留策略分批清理过期消息
+	go func() {
+		interval := time.Duration(a.Config.Chat.MessagePurgeIntervalMinutes) * time.Minute
+		if interval <= 0 {
+			interval = time.Hour
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if n, err := s.chat.PurgeExpiredMessages(a.Config.Chat, a.stopCh); err != nil {
+					logger.Log.Error("purge expired messages error", zap.Error(err))
+				} else if n > 0 {
+					logger.Log.Info("Purged expired messages", zap.Int64("count", n))
+				}
+			case <-a.stopCh:
+				return
+			}
+		}
+	}()
+
+	// 按提交正确率重新计算练习题建议难度
+	go func() {
+		interval := time.Duration(a.Config.Exercise.DifficultyCalibrationHours) * time.Hour
+		if interval <= 0 {
+			interval = 6 * time.Hour
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if n, err := s.cProgrammingResource.CalibrateDifficulty(a.Config.Exercise.DifficultyMinSamples); err != nil {
+					logger.Log.Error("calibrate exercise difficulty error", zap.Error(err))
+				} else if n > 0 {
+					logger.Log.Info("Calibrated exercise difficulty", zap.Int("suggested", n))
+				}
+			case <-a.stopCh:
+				return
+			}
+		}
+	}()
+
+	// 每小时清理超过保留期的系统消息
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if n, err := s.chat.PruneSystemMessages(a.Config.Chat.SystemMessageRetentionDays); err != nil {
+					logger.Log.Error("prune system messages error", zap.Error(err))
+				} else if n > 0 {
+					logger.Log.Info("Pruned expired system messages", zap.Int64("count", n))
+				}
+			case <-a.stopCh:
+				return
+			}
+		}
+	}()
+
+	// 自动结束心跳超时的学习会话
+	go func() {
+		timeout := time.Duration(a.Config.Analytics.SessionTimeoutMinutes) * time.Minute
+		if timeout <= 0 {
+			timeout = 5 * time.Minute
+		}
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if n, err := s.analytics.CloseStaleSessions(timeout); err != nil {
+					logger.Log.Error("close stale sessions error", zap.Error(err))
+				} else if n > 0 {
+					logger.Log.Info("Closed stale learning sessions", zap.Int("count", n))
+				}
+			case <-a.stopCh:
+				return
+			}
+		}
+	}()
+
+	// 学习目标截止提醒
+	go func() {
+		interval := time.Duration(a.Config.Achievement.GoalReminderIntervalMinutes) * time.Minute
+		if interval <= 0 {
+			interval = time.Hour
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if n, err := s.learningGoal.SendDeadlineReminders(a.Config.Achievement.GoalReminderDays); err != nil {
+					logger.Log.Error("send goal reminders error", zap.Error(err))
+				} else if n > 0 {
+					logger.Log.Info("Sent goal deadline reminders", zap.Int("count", n))
+				}
+			case <-a.stopCh:
+				return
+			}
+		}
+	}()
+
+	// 每小时按周任务模板生成下一周的任务草稿
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if n, err := s.task.InstantiateWeeklyTemplates(time.Now()); err != nil {
+					logger.Log.Error("instantiate weekly task templates error", zap.Error(err))
+				} else if n > 0 {
+					logger.Log.Info("Instantiated weekly task templates", zap.Int("count", n))
+				}
+			case <-a.stopCh:
+				return
+			}
+		}
+	}()
+
+	// 视频多分辨率转码 worker
+	go s.transcode.Run(a.stopCh)
+
 	// 每24小时执行
 	go func() {
 		select {
@@ -374,7 +635,8 @@ func NewApp(cfg *config.Config) *App {
 	// 监控初始化
 	monitoring.Init()
 
-	router := gin.Default()
+	router := gin.New()
+	router.Use(logger.GinMiddleware(), logger.GinRecovery())
 	router.MaxMultipartMemory = 1536 << 20 // 1.5 GB
 	app.Router = router
 
@@ -391,15 +653,22 @@ func NewApp(cfg *config.Config) *App {
 	app.registerRoutes(router, controllers, repos, cfg)
 
 	if cfg.Storage.Type == "local" {
-		router.Static("/uploads", cfg.Storage.LocalPath)
-		router.Static("/api/uploads", cfg.Storage.LocalPath)
+		router.Group("/uploads", security.StaticFileHeaders()).Static("/", cfg.Storage.LocalPath)
+		router.Group("/api/uploads", security.StaticFileHeaders()).Static("/", cfg.Storage.LocalPath)
 	}
 
 	// 社区资源文件存放路径
 	if _, err := os.Stat("resource_file"); os.IsNotExist(err) {
 		os.MkdirAll("resource_file", os.ModePerm)
 	}
-	router.Static("/api/community/resources/files", "resource_file")
+	router.Group("/api/community/resources/files", security.StaticFileHeaders()).Static("/", "resource_file")
+
+	// 视频封面截取失败时会回退到默认封面，启动时确保其存在
+	ensureCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := services.content.EnsureDefaultThumbnail(ensureCtx); err != nil {
+		logger.Log.Warn("默认视频封面不可用", zap.Error(err))
+	}
+	cancel()
 
 	app.startBackgroundTasks(services)
 
@@ -446,14 +715,23 @@ func (a *App) Run() {
 		logger.Log.Error("Server forced to shutdown", zap.Error(err))
 	}
 
-	// 4. 关闭分布式追踪
+	// 4. 等待消息消费者将 Redis Stream 中剩余的消息写入数据库
+	if a.services != nil && a.services.chat != nil {
+		if err := a.services.chat.ChatRepo.Shutdown(15 * time.Second); err != nil {
+			logger.Log.Error("Failed to drain chat message stream", zap.Error(err))
+		} else {
+			logger.Log.Info("Chat message stream drained")
+		}
+	}
+
+	// 5. 关闭分布式追踪
 	if a.tracerProvider != nil {
 		if err := a.tracerProvider.Shutdown(ctx); err != nil {
 			logger.Log.Error("Failed to shutdown tracer provider", zap.Error(err))
 		}
 	}
 
-	// 5. 关闭数据库连接
+	// 6. 关闭数据库连接
 	if a.DB != nil {
 		sqlDB, err := a.DB.DB()
 		if err == nil {
@@ -465,7 +743,7 @@ func (a *App) Run() {
 		}
 	}
 
-	// 6. 关闭 Redis 连接
+	// 7. 关闭 Redis 连接
 	if a.Redis != nil {
 		if err := a.Redis.Close(); err != nil {
 			logger.Log.Error("Failed to close Redis connection", zap.Error(err))
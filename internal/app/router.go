@@ -28,7 +28,7 @@ func (a *App) registerRoutes(router *gin.Engine, c *controllers, repos *reposito
 
 	// 3. 需要授权的路由
 	authGroup := router.Group("/api")
-	authGroup.Use(middleware.AuthMiddleware(cfg), middleware.ActivityMiddleware(repos.user))
+	authGroup.Use(middleware.AuthMiddleware(cfg, a.services.userStatus), middleware.ActivityMiddleware(repos.user))
 	{
 		// 学生/通用 授权接口
 		a.registerStudentRoutes(authGroup, c)
@@ -56,7 +56,7 @@ func (a *App) registerCommunityRoutes(router *gin.Engine, c *controllers, repos
 
 		// 交互类：强制认证
 		authorized := community.Group("/")
-		authorized.Use(middleware.AuthMiddleware(a.Config))
+		authorized.Use(middleware.AuthMiddleware(a.Config, a.services.userStatus))
 		{
 			authorized.POST("/posts", c.community.CreatePost)
 			authorized.PUT("/posts/:id", c.community.UpdatePost)
@@ -102,6 +102,7 @@ func (a *App) registerStudentRoutes(rg *gin.RouterGroup, c *controllers) {
 	rg.PUT("/user/profile", c.user.UpdateProfile)
 	rg.POST("/user/avatar/upload", c.user.UploadAvatar)
 	rg.GET("/resources", c.content.GetResources)
+	rg.GET("/resources/:id/url", c.content.GetResourceAccessURL) // 限时访问地址
 	rg.GET("/knowledge-tags", c.knowledgeTag.ListTags)
 	rg.GET("/dashboard", c.dashboard.GetDashboard)
 	rg.GET("/dashboard/today-tasks", c.dashboard.GetTodayTasks)
@@ -111,6 +112,7 @@ func (a *App) registerStudentRoutes(rg *gin.RouterGroup, c *controllers) {
 	rg.GET("/knowledge-points/student", c.knowledgePoint.ListForStudent)
 	rg.GET("/knowledge-points/ranking", c.knowledgePoint.GetRanking)
 	rg.GET("/knowledge-points/student/:id", c.knowledgePoint.GetDetailForStudent)
+	rg.GET("/knowledge-points/student/submissions/:id", c.knowledgePoint.GetMySubmissionResult)
 	rg.POST("/knowledge-points/student/:id/start", c.knowledgePoint.StartExercises)
 	rg.POST("/knowledge-points/student/submit", c.knowledgePoint.SubmitExercises)
 	rg.POST("/knowledge-points/student/:id/learning-time", c.knowledgePoint.RecordLearningTime)
@@ -122,14 +124,33 @@ func (a *App) registerStudentRoutes(rg *gin.RouterGroup, c *controllers) {
 	rg.POST("/learning/learning-log", c.learning.SubmitLearningLog)
 	rg.POST("/learning/quiz/:quizId", c.learning.SubmitQuiz)
 	rg.POST("/learning/run-code", c.learning.ExecuteCode)
+	rg.GET("/learning/logs/summary", c.learning.GetLearningLogSummary)
 
 	// 成就/目标
 	rg.GET("/achievements", c.achievement.GetUserAchievements)
 	rg.GET("/achievements/leaderboard", c.achievement.GetLeaderboard)
+	rg.GET("/achievements/leaderboard/season", c.achievement.GetSeasonLeaderboard)
+	rg.GET("/achievements/seasons", c.achievement.ListSeasons)
+	rg.GET("/achievements/seasons/:id/results", c.achievement.GetSeasonResults)
 	rg.GET("/achievements/goals", c.achievement.GetUserGoals)
 	rg.POST("/achievements/goals", c.achievement.CreateGoal)
 	rg.PATCH("/achievements/goals/:goalId", c.achievement.UpdateGoalProgress)
 
+	// 公告
+	rg.GET("/announcements", c.announcement.ListAnnouncements)
+	rg.PUT("/announcements/read", c.announcement.MarkRead)
+
+	// 通知中心
+	rg.GET("/unread-summary", c.unread.GetUnreadSummary)
+	rg.GET("/notifications", c.notification.ListNotifications)
+	rg.PUT("/notifications/read-all", c.notification.MarkAllRead)
+	rg.PUT("/notifications/:id/read", c.notification.MarkRead)
+	rg.GET("/notifications/preferences", c.notification.GetPreferences)
+	rg.PUT("/notifications/preferences", c.notification.UpdatePreferences)
+
+	// 全站搜索
+	rg.GET("/search", c.search.Search)
+
 	// 分析
 	rg.GET("/analytics/overview", c.analytics.GetOverview)
 	rg.GET("/analytics/progress", c.analytics.GetProgress)
@@ -139,11 +160,13 @@ func (a *App) registerStudentRoutes(rg *gin.RouterGroup, c *controllers) {
 	rg.GET("/analytics/levels/:levelId/curve", c.analytics.GetLevelCurve)
 	rg.GET("/analytics/recommendations", c.analytics.GetRecommendations)
 	rg.POST("/analytics/session/start", c.analytics.StartSession)
+	rg.POST("/analytics/session/:sessionId/heartbeat", c.analytics.HeartbeatSession)
 	rg.POST("/analytics/session/:sessionId/end", c.analytics.EndSession)
 
 	// 视频上传相关（通用）
 	rg.POST("/upload/video", c.content.UploadVideo)
 	rg.POST("/upload/video/chunk", c.content.UploadVideoChunk)
+	rg.DELETE("/upload/video/chunk/:identifier", c.content.AbortChunkUpload)
 	rg.GET("/upload/video/progress/:uploadId", c.content.GetUploadProgress)
 
 	// 关卡挑战
@@ -172,14 +195,18 @@ func (a *App) registerStudentRoutes(rg *gin.RouterGroup, c *controllers) {
 	// 用户相关
 	rg.POST("/users/checkin", c.user.Checkin)
 	rg.GET("/users/checkin/stats", c.user.GetCheckinStats)
+	rg.POST("/users/checkin/freeze", c.user.FreezeCheckinStreak)
 	rg.GET("/users/stats", c.user.GetUserStats)
 	rg.GET("/users/level-status", c.user.GetLevelStatus)
-	rg.POST("/users/:id/points", middleware.RoleMiddleware(model.Student, model.Teacher, model.Admin), c.user.UpdateUserPoints)
+	rg.POST("/users/:id/points", middleware.RoleMiddleware(model.Teacher, model.Admin), c.user.UpdateUserPoints)
+	rg.GET("/users/:id/points/ledger", c.user.GetPointsLedger)
 
 	// AI 问答
 	rg.POST("/qa/ask", c.qa.Ask)
+	rg.POST("/qa/stop", c.qa.Stop) // 停止生成
 	rg.GET("/qa/history", c.qa.GetHistory)
 	rg.GET("/qa/history/detail", c.qa.GetHistoryDetail)
+	rg.GET("/qa/sessions/:sessionId", c.qa.GetSession)      // 获取会话完整对话
 	rg.DELETE("/qa/history/:sessionId", c.qa.DeleteSession) // 删除会话
 	rg.GET("/qa/report/weekly", c.qa.GetWeeklyReport)       // 学习周报接口
 	rg.POST("/qa/diagnose", c.qa.DiagnoseCode)              // 代码诊断接口
@@ -198,10 +225,12 @@ func (a *App) registerStudentRoutes(rg *gin.RouterGroup, c *controllers) {
 	rg.GET("/learning-goals/:id", c.learningGoal.GetGoalByID)
 	rg.PUT("/learning-goals/:id", c.learningGoal.UpdateGoal)
 	rg.DELETE("/learning-goals/:id", c.learningGoal.DeleteGoal)
+	rg.PUT("/learning-goals/:id/reminder", c.learningGoal.UpdateReminder)
 	rg.GET("/learning-goals/:id/details", c.learningGoal.GetGoalDetails)
 
 	// 任务相关
 	rg.GET("/tasks/today", c.task.GetTodayTasks)
+	rg.GET("/tasks/weekly/summary", c.task.GetWeeklySummary)
 	rg.POST("/tasks/:taskItemId/completion", c.task.UpdateTaskCompletion)
 
 	// 教师建议
@@ -236,6 +265,8 @@ func (a *App) registerStudentRoutes(rg *gin.RouterGroup, c *controllers) {
 	// 有效反思
 	rg.GET("/reflections/my", c.reflection.GetMyReflection)
 	rg.POST("/reflections/my", c.reflection.SaveMyReflection)
+	rg.GET("/reflections/my/comments", c.reflection.GetMyReflectionComments)
+	rg.POST("/reflections/my/comments", c.reflection.AddMyReflectionComment)
 
 	// 协作中心 - 聊天室
 	chat := rg.Group("/chat")
@@ -245,6 +276,7 @@ func (a *App) registerStudentRoutes(rg *gin.RouterGroup, c *controllers) {
 		chat.GET("/conversations", c.chat.GetConversations)
 		chat.POST("/groups", c.chat.CreateGroup)
 		chat.POST("/privates", c.chat.CreatePrivateChat)
+		chat.GET("/saved", c.chat.GetSavedConversation)          // 我的收藏（给自己发消息）
 		chat.PUT("/conversations/:id", c.chat.UpdateGroupInfo)   // 修改群信息
 		chat.DELETE("/conversations/:id", c.chat.DisbandGroup)   // 解散群聊
 		chat.POST("/conversations/:id/leave", c.chat.LeaveGroup) // 退出群聊
@@ -252,11 +284,16 @@ func (a *App) registerStudentRoutes(rg *gin.RouterGroup, c *controllers) {
 		chat.GET("/messages/:id/context", c.chat.GetMessageContext) // 获取消息上下文
 		chat.PUT("/messages/:id/revoke", c.chat.RevokeMessage)      // 撤回消息
 		chat.GET("/conversations/:id/members", c.chat.GetMembers)
-		chat.POST("/conversations/:id/members", c.chat.InviteMember)         // 邀请成员
-		chat.DELETE("/conversations/:id/members/:userId", c.chat.KickMember) // 踢出成员
-		chat.POST("/conversations/:id/transfer", c.chat.TransferAdmin)       // 转让群主
+		chat.GET("/conversations/:id/stats", c.chat.GetConversationStats)         // 会话消息统计
+		chat.POST("/conversations/:id/members", c.chat.InviteMember)              // 邀请成员
+		chat.DELETE("/conversations/:id/members/:userId", c.chat.KickMember)      // 踢出成员
+		chat.PUT("/conversations/:id/members/:userId/role", c.chat.SetMemberRole) // 设置协管员
+		chat.POST("/conversations/:id/transfer", c.chat.TransferAdmin)            // 转让群主
 		chat.POST("/conversations/:id/messages", c.chat.SendMessage)
 		chat.PUT("/conversations/:id/read", c.chat.MarkAsRead)
+		chat.GET("/mentions", c.chat.GetMentions)                    // 未读的 @ 提及
+		chat.PUT("/read-all", c.chat.MarkAllAsRead)                  // 全部标记为已读
+		chat.POST("/presence", c.chat.GetPresence)                   // 批量查询在线状态
 		chat.PUT("/conversations/:id/hide", c.chat.HideConversation) // 隐藏会话
 		chat.GET("/search", c.chat.GlobalSearch)                     // 全局搜索
 		chat.POST("/upload", c.chat.UploadFile)
@@ -280,14 +317,26 @@ func (a *App) registerTeacherRoutes(rg *gin.RouterGroup, c *controllers) {
 		teacher.GET("/tasks/weekly", c.task.GetWeeklyTasks)
 		teacher.GET("/tasks/weekly/current", c.task.GetCurrentWeekTask)
 		teacher.DELETE("/tasks/weekly/:taskId", c.task.DeleteWeeklyTask)
+		teacher.PUT("/tasks/weekly/:taskId", c.task.UpdateWeeklyTaskItems)
+		teacher.PUT("/tasks/weekly/:taskId/publish", c.task.PublishWeeklyTask)
+		teacher.POST("/tasks/weekly/template", c.task.SaveWeeklyTaskTemplate)
+		teacher.GET("/tasks/weekly/templates", c.task.ListWeeklyTaskTemplates)
+		teacher.DELETE("/tasks/weekly/template/:id", c.task.DeleteWeeklyTaskTemplate)
+
+		// 公告
+		teacher.POST("/announcements", middleware.RoleMiddleware(model.Teacher, model.Admin), c.announcement.CreateAnnouncement)
 
 		// 关卡管理
 		teacher.POST("/levels", c.level.CreateLevel)
 		teacher.GET("/levels", c.level.ListLevels)
 		teacher.GET("/levels/:id", c.level.GetLevel)
+		teacher.GET("/levels/:id/edit", c.level.GetLevelForEdit)
+		teacher.GET("/analytics/export", middleware.RoleMiddleware(model.Teacher, model.Admin), c.analytics.ExportAnalytics)
+		teacher.GET("/analytics/abilities/class", middleware.RoleMiddleware(model.Teacher, model.Admin), c.analytics.GetClassAbilities)
 		teacher.PUT("/levels/:id", c.level.UpdateLevel)
 		teacher.DELETE("/levels/:id", c.level.DeleteLevel)
 		teacher.POST("/levels/:id/publish", c.level.PublishLevel)
+		teacher.GET("/levels/:id/validate", middleware.RoleMiddleware(model.Teacher, model.Admin), c.level.ValidateLevel)
 		teacher.POST("/levels/bulk/publish", c.level.BulkPublish)
 		teacher.POST("/levels/bulk", c.level.BulkUpdate)
 		teacher.GET("/levels/:id/versions", c.level.GetVersions)
@@ -300,11 +349,15 @@ func (a *App) registerTeacherRoutes(rg *gin.RouterGroup, c *controllers) {
 
 		// 评分相关
 		teacher.GET("/levels/:id/attempts/pending-grading", c.grade.ListPendingGrading)
+		teacher.GET("/grading/pending-summary", middleware.RoleMiddleware(model.Teacher, model.Admin), c.grade.GetPendingSummary)
 		teacher.POST("/levels/:id/attempts/:attemptId/grade", c.grade.GradeAttempt)
+		teacher.POST("/levels/:id/attempts/bulk-grade", c.grade.BulkGradeAttempts)
 
 		// 学生进度
 		teacher.GET("/students/progress", c.suggestion.ListStudentsProgress)
 		teacher.GET("/students/:id/progress", c.suggestion.GetStudentProgress)
+		teacher.GET("/students/:id/attempt-reviews", middleware.RoleMiddleware(model.Teacher, model.Admin), c.grade.GetStudentAttemptReviews)
+		teacher.GET("/students/:id/learning-logs/summary", middleware.RoleMiddleware(model.Teacher, model.Admin), c.learning.GetStudentLearningLogSummary)
 
 		// 尝试统计
 		teacher.GET("/levels/:id/attempts/stats", c.level.GetAttemptStats)
@@ -325,6 +378,7 @@ func (a *App) registerTeacherRoutes(rg *gin.RouterGroup, c *controllers) {
 		teacher.POST("/assessments", c.assessment.CreateAssessment)
 		teacher.GET("/assessments", c.assessment.ListAssessments)
 		teacher.GET("/assessments/:id", c.assessment.GetAssessment)
+		teacher.PUT("/assessments/:id", middleware.RoleMiddleware(model.Teacher, model.Admin), c.assessment.UpdateAssessment)
 		teacher.POST("/assessments/questions", c.assessment.CreateQuestion)
 		teacher.GET("/assessments/questions", c.assessment.ListQuestions)
 		teacher.GET("/assessments/questions/:id", c.assessment.GetQuestion)
@@ -340,7 +394,7 @@ func (a *App) registerTeacherRoutes(rg *gin.RouterGroup, c *controllers) {
 
 		// 知识点管理
 		teacher.POST("/knowledge-points", c.knowledgePoint.Create)
-		teacher.GET("/knowledge-points", c.knowledgePoint.List)
+		teacher.GET("/knowledge-points", middleware.RoleMiddleware(model.Teacher, model.Admin), c.knowledgePoint.List)
 		teacher.PUT("/knowledge-points/:id", c.knowledgePoint.Update)
 		teacher.DELETE("/knowledge-points/:id", c.knowledgePoint.Delete)
 		teacher.GET("/knowledge-points/points-list", c.knowledgePoint.GetStudentsPointsList)
@@ -348,7 +402,8 @@ func (a *App) registerTeacherRoutes(rg *gin.RouterGroup, c *controllers) {
 
 		// 知识点审核
 		teacher.GET("/knowledge-points/submissions", c.knowledgePoint.ListSubmissions)
-		teacher.GET("/knowledge-points/submissions/:id", c.knowledgePoint.GetSubmissionDetail)
+		teacher.GET("/knowledge-points/submissions/:id", middleware.RoleMiddleware(model.Teacher, model.Admin), c.knowledgePoint.GetSubmissionDetail)
+		teacher.GET("/knowledge-points/submissions/:id/result", middleware.RoleMiddleware(model.Teacher, model.Admin), c.knowledgePoint.GetSubmissionResult)
 		teacher.POST("/knowledge-points/submissions/:id/audit", c.knowledgePoint.AuditSubmission)
 
 		// 课后测试试卷管理
@@ -361,6 +416,7 @@ func (a *App) registerTeacherRoutes(rg *gin.RouterGroup, c *controllers) {
 		// 课后测试答题管理
 		teacher.GET("/post-class-tests/:id/submissions", c.postClassTest.ListSubmissions)
 		teacher.GET("/post-class-tests/submissions/:id", c.postClassTest.GetSubmissionDetail)
+		teacher.POST("/post-class-tests/submissions/:id/grade", c.postClassTest.GradeSubmission)
 		teacher.POST("/post-class-tests/submissions/reset", c.postClassTest.ResetStudentTests)
 
 		// 迁移任务管理
@@ -374,7 +430,8 @@ func (a *App) registerTeacherRoutes(rg *gin.RouterGroup, c *controllers) {
 
 		// 有效反思管理
 		teacher.GET("/reflections", middleware.RoleMiddleware(model.Teacher, model.Admin), c.reflection.ListAllReflections)
-		teacher.PUT("/reflections/user/:userId", middleware.RoleMiddleware(model.Teacher, model.Admin), c.reflection.UpdateReflection)
+		teacher.GET("/reflections/user/:userId/comments", middleware.RoleMiddleware(model.Teacher, model.Admin), c.reflection.GetReflectionComments)
+		teacher.POST("/reflections/user/:userId/comments", middleware.RoleMiddleware(model.Teacher, model.Admin), c.reflection.AddReflectionComment)
 	}
 
 	// 学习路径管理
@@ -391,24 +448,40 @@ func (a *App) registerTeacherRoutes(rg *gin.RouterGroup, c *controllers) {
 
 func (a *App) registerAdminRoutes(router *gin.Engine, c *controllers, repos *repositories, cfg *config.Config) {
 	admin := router.Group("/api/admin")
-	admin.Use(middleware.AuthMiddleware(a.Config), middleware.ActivityMiddleware(repos.user))
+	admin.Use(middleware.AuthMiddleware(a.Config, a.services.userStatus), middleware.ActivityMiddleware(repos.user))
 	{
 		// 1. 用户列表和详情：允许管理员和老师访问
 		admin.GET("/users", middleware.RoleMiddleware(model.Admin, model.Teacher), c.user.GetUsers)
 		admin.GET("/users/:id", middleware.RoleMiddleware(model.Admin, model.Teacher), c.user.GetUser)
+		admin.POST("/users/import", middleware.RoleMiddleware(model.Admin, model.Teacher), c.user.ImportUsers)
 
 		// 2. 其他所有接口：仅限管理员访问
 		adminOnly := admin.Group("/")
 		adminOnly.Use(middleware.RoleMiddleware(model.Admin))
 		{
 			adminOnly.POST("/upload/icon", c.content.UploadIcon)
+			adminOnly.DELETE("/upload/video/:identifier", c.content.AdminAbortChunkUpload)
 			adminOnly.POST("/resources", c.content.UploadResource)
+			adminOnly.GET("/users/role-changes", c.user.GetRoleChanges)
+			adminOnly.POST("/users/bulk", c.user.BulkUpdateUsers)
 			adminOnly.PUT("/users/:id", c.user.UpdateUser)
 			adminOnly.DELETE("/users/:id", c.user.DeleteUser)
 			adminOnly.POST("/users/:id/reset-password", c.user.ResetPassword)
 			adminOnly.POST("/users/:id/disable", c.user.DisableUser)
 
+			adminOnly.GET("/chat/shards", c.chat.GetShardStats)
+			adminOnly.PUT("/chat/conversations/:id/retention", c.chat.UpdateConversationRetention)
+			adminOnly.GET("/points/consistency", c.user.CheckPointsConsistency)
+			adminOnly.POST("/levels/:id/versions/regenerate", c.level.RegenerateCurrentVersion)
+			adminOnly.PUT("/levels/:id/owner", c.ownership.TransferLevel)                          // 转移关卡创建者
+			adminOnly.PUT("/resources/:id/owner", c.ownership.TransferResource)                    // 转移资源上传者
+			adminOnly.PUT("/community/resources/:id/owner", c.ownership.TransferCommunityResource) // 转移社区资源作者
+			adminOnly.POST("/seasons", c.achievement.CreateSeason)
+			adminOnly.POST("/seasons/:id/close", c.achievement.CloseSeason)
+
 			adminOnly.GET("/motivations", c.motivation.GetAllMotivations)
+			adminOnly.GET("/motivations/schedule", c.motivation.GetSchedule)
+			adminOnly.PUT("/motivations/schedule", c.motivation.UpdateSchedule)
 			adminOnly.POST("/motivations", c.motivation.CreateMotivation)
 			adminOnly.PUT("/motivations/:id", c.motivation.UpdateMotivation)
 			adminOnly.DELETE("/motivations/:id", c.motivation.DeleteMotivation)
@@ -432,6 +505,7 @@ func (a *App) registerAdminRoutes(router *gin.Engine, c *controllers, repos *rep
 			adminOnly.PUT("/articles/:id", c.cProgramming.UpdateArticle)
 			adminOnly.PUT("/exercise-categories/:id", c.cProgramming.UpdateExerciseCategory)
 			adminOnly.PUT("/questions/:id", c.cProgramming.UpdateQuestion)
+			adminOnly.POST("/questions/:id/difficulty/accept", c.cProgramming.AcceptSuggestedDifficulty)
 			adminOnly.DELETE("/:itemType/:itemId", c.cProgramming.DeleteContentItem)
 		}
 	}
@@ -9,24 +9,40 @@ import (
 )
 
 type Config struct {
-	Server    ServerConfig
-	Database  DatabaseConfig
-	JWT       JWTConfig
-	Storage   StorageConfig
-	Tracing   TracingConfig `mapstructure:"tracing"`
-	Judge0    Judge0Config
-	Redis     RedisConfig
-	AI        AIConfig
-	CORS      CORSConfig      `mapstructure:"cors"`
-	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+	Server      ServerConfig
+	Database    DatabaseConfig
+	JWT         JWTConfig
+	Storage     StorageConfig
+	Tracing     TracingConfig `mapstructure:"tracing"`
+	Judge0      Judge0Config
+	Redis       RedisConfig
+	AI          AIConfig
+	CORS        CORSConfig        `mapstructure:"cors"`
+	RateLimit   RateLimitConfig   `mapstructure:"rate_limit"`
+	Chat        ChatConfig        `mapstructure:"chat"`
+	Upload      UploadConfig      `mapstructure:"upload"`
+	Achievement AchievementConfig `mapstructure:"achievement"`
+	Analytics   AnalyticsConfig   `mapstructure:"analytics"`
+	Video       VideoConfig       `mapstructure:"video"`
+	Exercise    ExerciseConfig    `mapstructure:"exercise"`
+	Motivation  MotivationConfig  `mapstructure:"motivation"`
+	Password    PasswordConfig    `mapstructure:"password"`
 
 	// 运行时标志（非配置文件，通过命令行参数设置）
 	ForceMigrate bool `mapstructure:"-"` // 强制执行数据库迁移
 	MigrateOnly  bool `mapstructure:"-"` // 仅迁移模式（迁移后退出）
 }
 
+// CORSConfig 跨域配置，WebSocket 握手的 Origin 校验也使用同一份白名单
 type CORSConfig struct {
-	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	// AllowedOrigins 允许的来源，需写完整的 scheme://host[:port]；配置 "*" 表示允许任意来源（此时不会下发 Credentials）
+	AllowedOrigins   []string `mapstructure:"allowed_origins"`
+	AllowedMethods   []string `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string `mapstructure:"allowed_headers"`
+	ExposedHeaders   []string `mapstructure:"exposed_headers"`
+	AllowCredentials bool     `mapstructure:"allow_credentials"`
+	// MaxAgeSeconds 预检结果缓存时长
+	MaxAgeSeconds int `mapstructure:"max_age_seconds"`
 }
 
 type RateLimitConfig struct {
@@ -34,10 +50,114 @@ type RateLimitConfig struct {
 	WindowMinutes int `mapstructure:"window_minutes"`
 }
 
+type ChatConfig struct {
+	// ShardCount ChatHub 连接分片数，<=0 时使用默认值 32。
+	// 调大可降低单分片锁竞争，但会增加群推送/心跳等全分片遍历的开销
+	ShardCount int `mapstructure:"shard_count"`
+	// NodeID 当前 ChatHub 节点 ID，用于跨节点消息路由和监控指标的 node 标签，重启后应保持不变。
+	// 为空时使用主机名（容器中即 Pod 名），同一主机运行多个实例时必须分别配置
+	NodeID string `mapstructure:"node_id"`
+	// SystemMessageRetentionDays 系统消息（入群/退群/群信息变更等）保留天数，超期由后台任务清理，<=0 表示不清理（默认）。
+	// 清理不影响 conversation_events 中的成员变动审计记录，但该表上线前的成员变动只有系统消息这一份记录，开启前需确认可以丢弃
+	SystemMessageRetentionDays int `mapstructure:"system_message_retention_days"`
+	// CollapseSystemMessages 获取历史消息时是否默认折叠连续的系统消息，客户端可通过 collapseSystem 参数覆盖
+	CollapseSystemMessages bool `mapstructure:"collapse_system_messages"`
+	// MessageRetentionDays 消息全局保留天数，超期消息由后台任务分批物理删除，<=0 表示永久保留。
+	// 会话可通过 retentionDays/retentionExempt 单独覆盖
+	MessageRetentionDays int `mapstructure:"message_retention_days"`
+	// MessagePurgeIntervalMinutes 清理任务执行间隔（分钟）
+	MessagePurgeIntervalMinutes int `mapstructure:"message_purge_interval_minutes"`
+	// MessagePurgeBatchSize 每批删除的消息条数，批次之间暂停 MessagePurgeBatchPauseMs 毫秒，避免长时间锁表
+	MessagePurgeBatchSize    int `mapstructure:"message_purge_batch_size"`
+	MessagePurgeBatchPauseMs int `mapstructure:"message_purge_batch_pause_ms"`
+	// MessagePurgeWindow 允许执行清理的时间段（本地时间，如 "02:00-06:00"，可跨零点），为空表示不限制
+	MessagePurgeWindow string `mapstructure:"message_purge_window"`
+	// MaxFriends 每个用户的好友数量上限，<=0 表示不限制
+	MaxFriends int `mapstructure:"max_friends"`
+	// MaxPendingFriendRequests 每个用户同时待处理的已发出好友申请上限，<=0 表示不限制
+	MaxPendingFriendRequests int `mapstructure:"max_pending_friend_requests"`
+	// FriendRequestRateLimit 每 FriendRequestRateWindowMinutes 分钟内允许发送的好友申请数，<=0 表示不限制
+	FriendRequestRateLimit         int `mapstructure:"friend_request_rate_limit"`
+	FriendRequestRateWindowMinutes int `mapstructure:"friend_request_rate_window_minutes"`
+	// RevokeWindowSeconds 发送者撤回自己消息的时限（秒），<=0 时使用默认值 120。
+	// 群主和协管员撤回其他成员的消息不受此限制
+	RevokeWindowSeconds int `mapstructure:"revoke_window_seconds"`
+	// GroupInviteFriendsOnly 建群和邀请入群时只能拉入自己的好友，非好友会被跳过（建群）或拒绝（邀请）
+	GroupInviteFriendsOnly bool `mapstructure:"group_invite_friends_only"`
+	// GroupInviteExemptRoles 不受好友限制的用户角色，默认教师和管理员可以直接拉学生组建学习小组
+	GroupInviteExemptRoles []string `mapstructure:"group_invite_exempt_roles"`
+}
+
+// VideoConfig 视频处理配置
+type VideoConfig struct {
+	// FFmpegConcurrency 同时运行的 FFmpeg 任务（探测、截图、转码）上限，超出的任务排队等待
+	FFmpegConcurrency int `mapstructure:"ffmpeg_concurrency"`
+}
+
+// ExerciseConfig 练习题难度校准配置
+type ExerciseConfig struct {
+	// DifficultyMinSamples 题目至少有多少人提交后才给出建议难度，样本太少时正确率波动大
+	DifficultyMinSamples int `mapstructure:"difficulty_min_samples"`
+	// DifficultyCalibrationHours 重新计算建议难度的间隔（小时）
+	DifficultyCalibrationHours int `mapstructure:"difficulty_calibration_hours"`
+}
+
+// MotivationConfig 激励短句自动轮换配置
+type MotivationConfig struct {
+	// RotateHour 每天自动切换到下一条短句的时刻（本地时间 0-23 点），管理员可在后台关闭自动轮换或置顶短句
+	RotateHour int `mapstructure:"rotate_hour"`
+}
+
+// PasswordConfig 注册及管理员设置密码时的强度要求
+type PasswordConfig struct {
+	// MinLength 密码最少字符数
+	MinLength int `mapstructure:"min_length"`
+	// MinCharClasses 至少包含小写字母、大写字母、数字、符号中的几种
+	MinCharClasses int `mapstructure:"min_char_classes"`
+	// BreachCheck 是否通过 HaveIBeenPwned 的 k-anonymity 接口拒绝已泄露的密码，离线部署需关闭；
+	// 查询失败或超时时放行，不影响注册
+	BreachCheck               bool `mapstructure:"breach_check"`
+	BreachCheckTimeoutSeconds int  `mapstructure:"breach_check_timeout_seconds"`
+}
+
+// AnalyticsConfig 学习会话心跳配置
+type AnalyticsConfig struct {
+	// SessionHeartbeatSeconds 客户端发送会话心跳的建议间隔
+	SessionHeartbeatSeconds int `mapstructure:"session_heartbeat_seconds"`
+	// SessionTimeoutMinutes 超过该时长未收到心跳的会话会被自动结束
+	SessionTimeoutMinutes int `mapstructure:"session_timeout_minutes"`
+}
+
+// AchievementConfig 目标达成时的奖励配置
+type AchievementConfig struct {
+	GoalCompletedName   string `mapstructure:"goal_completed_name"`
+	GoalCompletedIcon   string `mapstructure:"goal_completed_icon"`
+	GoalCompletedXP     int    `mapstructure:"goal_completed_xp"`
+	GoalCompletedPoints int    `mapstructure:"goal_completed_points"`
+	// GoalCompletedNotify 是否通过聊天推送系统通知给用户
+	GoalCompletedNotify bool `mapstructure:"goal_completed_notify"`
+	// GoalReminderDays 学习目标截止前多少天提醒（可配置多个阈值，每个阈值只提醒一次），为空时不提醒
+	GoalReminderDays []int `mapstructure:"goal_reminder_days"`
+	// GoalReminderIntervalMinutes 截止提醒的检查间隔
+	GoalReminderIntervalMinutes int `mapstructure:"goal_reminder_interval_minutes"`
+	// WeeklyTasksCompletedPoints 学生完成某周全部周任务时发放的积分，每周只发放一次，0 表示不发放
+	WeeklyTasksCompletedPoints int `mapstructure:"weekly_tasks_completed_points"`
+}
+
 type AIConfig struct {
 	BaseURL string `mapstructure:"base_url"`
 	APIKey  string `mapstructure:"api_key"`
 	Model   string `mapstructure:"model"`
+	// Providers 备用模型服务，按顺序在主服务连接失败或返回 5xx 时依次尝试
+	Providers []AIProviderConfig `mapstructure:"providers"`
+}
+
+// AIProviderConfig 兼容 OpenAI Chat Completions 接口的模型服务
+type AIProviderConfig struct {
+	Name    string `mapstructure:"name"`
+	BaseURL string `mapstructure:"base_url"`
+	APIKey  string `mapstructure:"api_key"`
+	Model   string `mapstructure:"model"` // 为空时使用 ai.model
 }
 
 type ServerConfig struct {
@@ -71,6 +191,14 @@ type StorageConfig struct {
 	OSSAccessKey  string `mapstructure:"oss_access_key"`
 	OSSSecretKey  string `mapstructure:"oss_secret_key"`
 	OSSBucket     string `mapstructure:"oss_bucket"`
+	// 分片上传清理：超过 UploadTTLMinutes 未活动的上传视为废弃，每 UploadCleanupMinutes 扫描一次
+	UploadTTLMinutes     int `mapstructure:"upload_ttl_minutes"`
+	UploadCleanupMinutes int `mapstructure:"upload_cleanup_minutes"`
+	// DefaultThumbnail 视频封面截取失败时使用的占位图：完整 URL（http(s):// 或 / 开头）直接使用，
+	// 否则视为存储中的对象路径，启动时检查是否存在，缺失则自动生成
+	DefaultThumbnail string `mapstructure:"default_thumbnail"`
+	// ThumbnailOffsets 截取视频封面的时间点（秒），按顺序尝试，前一个失败（如视频过短、解码异常）再试下一个
+	ThumbnailOffsets []string `mapstructure:"thumbnail_offsets"`
 }
 type TracingConfig struct {
 	Enabled           bool   `mapstructure:"enabled"`
@@ -81,6 +209,36 @@ type Judge0Config struct {
 	APIKey string `mapstructure:"api_key"`
 	URL    string
 	Host   string
+	// TimeLimitSeconds 单次运行的 CPU 及墙钟时间上限
+	TimeLimitSeconds float64 `mapstructure:"time_limit_seconds"`
+	// MemoryLimitKB 单次运行的内存上限
+	MemoryLimitKB int `mapstructure:"memory_limit_kb"`
+	// MaxOutputBytes 标准输出/错误输出的字节上限，超出后终止运行并返回截断结果
+	MaxOutputBytes int `mapstructure:"max_output_bytes"`
+	// Languages 支持的编程语言（键为 c、cpp、python 等），未配置的语言拒绝运行
+	Languages map[string]Judge0LanguageConfig `mapstructure:"languages"`
+}
+
+// Judge0LanguageConfig 单个编程语言在 Judge0 中的编译器/解释器及运行时限
+type Judge0LanguageConfig struct {
+	// LanguageID Judge0 的语言 ID，决定使用的编译器或解释器版本
+	LanguageID int `mapstructure:"language_id"`
+	// TimeLimitSeconds 覆盖全局运行时限，解释型语言通常需要更宽松的时限；为 0 时使用全局时限
+	TimeLimitSeconds float64 `mapstructure:"time_limit_seconds"`
+}
+
+// UploadConfig 各上传接口的单文件大小上限（MB）及单用户并发上传限制
+type UploadConfig struct {
+	AvatarMaxMB   int `mapstructure:"avatar_max_mb"`
+	ImageMaxMB    int `mapstructure:"image_max_mb"`    // 图标、关卡封面
+	VideoMaxMB    int `mapstructure:"video_max_mb"`    // 整文件视频上传
+	ChunkMaxMB    int `mapstructure:"chunk_max_mb"`    // 分片上传的单个分片
+	ResourceMaxMB int `mapstructure:"resource_max_mb"` // 课程资源、关卡附件、社区资源
+	ChatMaxMB     int `mapstructure:"chat_max_mb"`
+	// MaxConcurrentPerUser 单个用户同时进行的上传数（分片上传按 identifier 计一次），为 0 时不限制
+	MaxConcurrentPerUser int `mapstructure:"max_concurrent_per_user"`
+	// RetryAfterSeconds 超出并发上限时通过 Retry-After 建议客户端等待的秒数
+	RetryAfterSeconds int `mapstructure:"retry_after_seconds"`
 }
 
 type RedisConfig struct {
@@ -131,6 +289,8 @@ func LoadConfig(path string) (*Config, error) {
 	viper.BindEnv("storage.minio_access_key", "MINIO_ACCESS_KEY")
 	viper.BindEnv("storage.minio_secret_key", "MINIO_SECRET_KEY")
 	viper.BindEnv("storage.minio_bucket", "MINIO_BUCKET")
+	viper.SetDefault("storage.default_thumbnail", "thumbnails/default-video-thumbnail.jpg")
+	viper.SetDefault("storage.thumbnail_offsets", []string{"3", "0"})
 
 	// Tracing
 	viper.BindEnv("tracing.enabled", "TRACING_ENABLED")
@@ -140,6 +300,70 @@ func LoadConfig(path string) (*Config, error) {
 	viper.BindEnv("judge0.api_key", "JUDGE0_API_KEY")
 	viper.BindEnv("judge0.url", "JUDGE0_URL")
 	viper.BindEnv("judge0.host", "JUDGE0_HOST")
+	viper.SetDefault("judge0.time_limit_seconds", 5)
+	viper.SetDefault("judge0.memory_limit_kb", 128000)
+	viper.SetDefault("judge0.max_output_bytes", 64*1024)
+	viper.SetDefault("judge0.languages", map[string]interface{}{
+		"c":      map[string]interface{}{"language_id": 75},
+		"cpp":    map[string]interface{}{"language_id": 76},
+		"python": map[string]interface{}{"language_id": 71, "time_limit_seconds": 10},
+	})
+
+	// CORS
+	viper.SetDefault("cors.allowed_methods", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"})
+	viper.SetDefault("cors.allowed_headers", []string{"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "Accept", "Origin", "Cache-Control", "X-Requested-With", "X-Request-ID"})
+	viper.SetDefault("cors.exposed_headers", []string{"Content-Disposition", "X-Request-ID"})
+	viper.SetDefault("cors.allow_credentials", true)
+	viper.SetDefault("cors.max_age_seconds", 600)
+
+	// Upload
+	viper.SetDefault("upload.avatar_max_mb", 5)
+	viper.SetDefault("upload.image_max_mb", 5)
+	viper.SetDefault("upload.video_max_mb", 500)
+	viper.SetDefault("upload.chunk_max_mb", 50)
+	viper.SetDefault("upload.resource_max_mb", 100)
+	viper.SetDefault("upload.chat_max_mb", 20)
+	viper.SetDefault("upload.max_concurrent_per_user", 3)
+	viper.SetDefault("upload.retry_after_seconds", 30)
+
+	// Chat
+	viper.SetDefault("chat.system_message_retention_days", 0)
+	viper.SetDefault("chat.collapse_system_messages", true)
+	viper.SetDefault("chat.message_retention_days", 0)
+	viper.SetDefault("chat.message_purge_interval_minutes", 60)
+	viper.SetDefault("chat.message_purge_batch_size", 1000)
+	viper.SetDefault("chat.message_purge_batch_pause_ms", 200)
+	viper.SetDefault("chat.message_purge_window", "02:00-06:00")
+	viper.SetDefault("chat.max_friends", 500)
+	viper.SetDefault("chat.max_pending_friend_requests", 50)
+	viper.SetDefault("chat.friend_request_rate_limit", 20)
+	viper.SetDefault("chat.friend_request_rate_window_minutes", 60)
+	viper.SetDefault("chat.revoke_window_seconds", 120)
+	viper.SetDefault("chat.group_invite_friends_only", true)
+	viper.SetDefault("chat.group_invite_exempt_roles", []string{"teacher", "admin"})
+
+	// Analytics
+	viper.SetDefault("analytics.session_heartbeat_seconds", 60)
+	viper.SetDefault("analytics.session_timeout_minutes", 5)
+
+	// Video
+	viper.SetDefault("video.ffmpeg_concurrency", 2)
+	viper.SetDefault("exercise.difficulty_min_samples", 20)
+	viper.SetDefault("exercise.difficulty_calibration_hours", 6)
+	viper.SetDefault("motivation.rotate_hour", 0)
+	viper.SetDefault("password.min_length", 8)
+	viper.SetDefault("password.min_char_classes", 3)
+	viper.SetDefault("password.breach_check", true)
+	viper.SetDefault("password.breach_check_timeout_seconds", 3)
+
+	// Achievement
+	viper.SetDefault("achievement.goal_completed_name", "目标达成")
+	viper.SetDefault("achievement.goal_completed_xp", 50)
+	viper.SetDefault("achievement.goal_completed_points", 0)
+	viper.SetDefault("achievement.goal_completed_notify", true)
+	viper.SetDefault("achievement.goal_reminder_days", []int{3, 1})
+	viper.SetDefault("achievement.goal_reminder_interval_minutes", 60)
+	viper.SetDefault("achievement.weekly_tasks_completed_points", 20)
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
@@ -3,9 +3,11 @@ package controller
 import (
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type AchievementController struct {
@@ -66,6 +68,124 @@ func (c *AchievementController) GetLeaderboard(ctx *gin.Context) {
 	util.Success(ctx, leaderboard)
 }
 
+// @Summary 获取赛季排行榜
+// @Description 按当前赛季内获得的积分排名，没有进行中的赛季时 season 为空
+// @Tags 成就系统
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "返回数量" default(10)
+// @Success 200 {object} util.Response{data=service.SeasonLeaderboard}
+// @Router /api/achievements/leaderboard/season [get]
+func (c *AchievementController) GetSeasonLeaderboard(ctx *gin.Context) {
+	limit := 10
+	if limitStr := ctx.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	leaderboard, err := c.AchievementService.GetSeasonLeaderboard(limit)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+
+	util.Success(ctx, leaderboard)
+}
+
+// @Summary 获取赛季列表
+// @Tags 成就系统
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} util.Response{data=[]model.Season}
+// @Router /api/achievements/seasons [get]
+func (c *AchievementController) ListSeasons(ctx *gin.Context) {
+	seasons, err := c.AchievementService.ListSeasons()
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, seasons)
+}
+
+// @Summary 获取赛季最终排名
+// @Tags 成就系统
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "赛季ID"
+// @Success 200 {object} util.Response{data=[]model.SeasonResult}
+// @Router /api/achievements/seasons/{id}/results [get]
+func (c *AchievementController) GetSeasonResults(ctx *gin.Context) {
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	results, err := c.AchievementService.GetSeasonResults(id)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, results)
+}
+
+// @Summary 创建赛季
+// @Description 创建新的排行榜赛季，同一时间只能有一个进行中的赛季（仅管理员）
+// @Tags 成就系统
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body service.SeasonRequest true "赛季信息"
+// @Success 201 {object} util.Response{data=model.Season}
+// @Router /api/admin/seasons [post]
+func (c *AchievementController) CreateSeason(ctx *gin.Context) {
+	var req service.SeasonRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+
+	season, err := c.AchievementService.CreateSeason(req)
+	if err != nil {
+		if errors.Is(err, util.ErrSeasonActive) || errors.Is(err, util.ErrInvalidSeasonRange) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Created(ctx, season)
+}
+
+// @Summary 结束赛季
+// @Description 结算赛季最终排名并关闭赛季，用户累计积分不受影响（仅管理员）
+// @Tags 成就系统
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "赛季ID"
+// @Success 200 {object} util.Response{data=model.Season}
+// @Router /api/admin/seasons/{id}/close [post]
+func (c *AchievementController) CloseSeason(ctx *gin.Context) {
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	season, err := c.AchievementService.CloseSeason(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			util.NotFound(ctx)
+			return
+		}
+		if errors.Is(err, util.ErrSeasonClosed) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, season)
+}
+
 // @Summary 获取用户目标
 // @Description 获取用户的学习目标
 // @Tags 成就系统
@@ -138,10 +258,8 @@ func (c *AchievementController) UpdateGoalProgress(ctx *gin.Context) {
 		return
 	}
 
-	goalIDStr := ctx.Param("goalId")
-	goalID, err := strconv.Atoi(goalIDStr)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid goal ID")
+	goalID, ok := util.ParseUintParam(ctx, "goalId")
+	if !ok {
 		return
 	}
 
@@ -154,7 +272,7 @@ func (c *AchievementController) UpdateGoalProgress(ctx *gin.Context) {
 		return
 	}
 
-	err = c.AchievementService.UpdateGoalProgress(user.UserID, uint(goalID), req.Progress)
+	err := c.AchievementService.UpdateGoalProgress(user.UserID, goalID, req.Progress)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
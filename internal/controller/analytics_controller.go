@@ -1,11 +1,18 @@
 package controller
 
 import (
+	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
+	"coder_edu_backend/pkg/logger"
+	"errors"
+	"fmt"
+	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 type AnalyticsController struct {
@@ -115,6 +122,29 @@ func (c *AnalyticsController) GetAbilities(ctx *gin.Context) {
 	util.Success(ctx, abilities)
 }
 
+// @Summary 获取班级能力雷达图
+// @Description 按能力维度统计全部学生已结束尝试的平均得分率（0-100）；传入 studentIds 时额外返回该学生子集的平均值用于对比（仅教师/管理员）
+// @Tags 分析
+// @Produce json
+// @Security BearerAuth
+// @Param studentIds query string false "学生ID列表，逗号分隔"
+// @Success 200 {object} util.Response{data=model.ClassAbilityRadar}
+// @Router /api/teacher/analytics/abilities/class [get]
+func (c *AnalyticsController) GetClassAbilities(ctx *gin.Context) {
+	studentIDs, ok := util.ParseUintListQuery(ctx, "studentIds")
+	if !ok {
+		return
+	}
+
+	radar, err := c.AnalyticsService.GetClassAbilityRadar(studentIDs)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+
+	util.Success(ctx, radar)
+}
+
 // @Summary 获取关卡挑战曲线
 // @Description 获取用户在特定关卡中的多次尝试得分变化趋势
 // @Tags 分析
@@ -122,7 +152,7 @@ func (c *AnalyticsController) GetAbilities(ctx *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param levelId path int false "关卡ID (不传则取最近一次尝试的关卡)"
-// @Param limit query int false "返回最近几次尝试 (默认10)" default(10)
+// @Param limit query int false "返回最近几天的得分 (默认10，最多90)" default(10)
 // @Success 200 {object} util.Response
 // @Router /api/analytics/levels/{levelId}/curve [get]
 func (c *AnalyticsController) GetLevelCurve(ctx *gin.Context) {
@@ -138,7 +168,12 @@ func (c *AnalyticsController) GetLevelCurve(ctx *gin.Context) {
 		levelID, _ = strconv.Atoi(levelIDStr)
 	}
 
+	// limit 为曲线覆盖的天数，服务层按天预分配并逐天填充，需限制范围
 	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	if limit < 1 {
+		limit = 10
+	}
+	limit = min(limit, 90)
 
 	curve, err := c.AnalyticsService.GetLevelLearningCurve(user.UserID, uint(levelID), limit)
 	if err != nil {
@@ -150,12 +185,12 @@ func (c *AnalyticsController) GetLevelCurve(ctx *gin.Context) {
 }
 
 // @Summary 获取个性化建议
-// @Description 获取基于用户学习数据的个性化建议
+// @Description 获取基于用户学习数据的个性化建议，items 中每条推荐附带推荐理由 reason 和优先级 score
 // @Tags 分析
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} util.Response
+// @Success 200 {object} util.Response{data=model.PersonalizedRecommendation}
 // @Router /api/analytics/recommendations [get]
 func (c *AnalyticsController) GetRecommendations(ctx *gin.Context) {
 	user := util.GetUserFromContext(ctx)
@@ -229,6 +264,40 @@ func (c *AnalyticsController) StartSession(ctx *gin.Context) {
 	util.Success(ctx, gin.H{"sessionId": sessionID})
 }
 
+// @Summary 学习会话心跳
+// @Description 刷新学习会话的活跃时间。客户端应在会话期间按 analytics.session_heartbeat_seconds（默认 60 秒）定期调用，
+// @Description 超过 analytics.session_timeout_minutes（默认 5 分钟）未收到心跳的会话会被自动结束，时长截止到最后一次心跳
+// @Tags 分析
+// @Produce json
+// @Security BearerAuth
+// @Param sessionId path int true "会话ID"
+// @Success 200 {object} util.Response
+// @Failure 404 {object} util.Response "会话不存在或已结束"
+// @Router /api/analytics/session/{sessionId}/heartbeat [post]
+func (c *AnalyticsController) HeartbeatSession(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	sessionID, ok := util.ParseUintParam(ctx, "sessionId")
+	if !ok {
+		return
+	}
+
+	if err := c.AnalyticsService.HeartbeatLearningSession(user.UserID, sessionID); err != nil {
+		if errors.Is(err, util.ErrSessionNotFound) {
+			util.Error(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		util.InternalServerError(ctx)
+		return
+	}
+
+	util.Success(ctx, gin.H{"message": "ok"})
+}
+
 // @Summary 结束学习会话
 // @Description 记录用户的学习会话结束
 // @Tags 分析
@@ -246,10 +315,8 @@ func (c *AnalyticsController) EndSession(ctx *gin.Context) {
 		return
 	}
 
-	sessionIDStr := ctx.Param("sessionId")
-	sessionID, err := strconv.Atoi(sessionIDStr)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid session ID")
+	sessionID, ok := util.ParseUintParam(ctx, "sessionId")
+	if !ok {
 		return
 	}
 
@@ -262,7 +329,7 @@ func (c *AnalyticsController) EndSession(ctx *gin.Context) {
 		return
 	}
 
-	err = c.AnalyticsService.EndLearningSession(user.UserID, uint(sessionID), req.Activity)
+	err := c.AnalyticsService.EndLearningSession(user.UserID, uint(sessionID), req.Activity)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -270,3 +337,57 @@ func (c *AnalyticsController) EndSession(ctx *gin.Context) {
 
 	util.Success(ctx, gin.H{"message": "Session ended"})
 }
+
+// @Summary 导出学习分析数据
+// @Description 以 CSV 流式导出学生的学习概览、周进度或技能评估数据，文件带 UTF-8 BOM 以便 Excel 正确显示中文（仅教师/管理员）。
+// @Description 教师只能导出在自己创建的关卡中有过挑战记录的学生，管理员可导出全部学生
+// @Tags 分析
+// @Produce text/csv
+// @Security BearerAuth
+// @Param type query string true "导出类型" Enums(overview,progress,skills)
+// @Param format query string false "导出格式" Enums(csv) default(csv)
+// @Param userIds query string false "学生ID列表，逗号分隔；为空时导出全部学生"
+// @Param weeks query int false "进度周数（type=progress 时有效，最多 52）" default(6)
+// @Success 200 {file} file
+// @Failure 400 {object} util.Response "参数错误"
+// @Router /api/teacher/analytics/export [get]
+func (c *AnalyticsController) ExportAnalytics(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	exportType := ctx.Query("type")
+	if !service.IsValidAnalyticsExportType(exportType) {
+		util.BadRequest(ctx, "type 仅支持 overview、progress、skills")
+		return
+	}
+	if format := ctx.DefaultQuery("format", "csv"); format != "csv" {
+		util.BadRequest(ctx, "format 仅支持 csv")
+		return
+	}
+	weeks, _ := strconv.Atoi(ctx.DefaultQuery("weeks", "6"))
+	if weeks <= 0 {
+		weeks = 6
+	}
+	weeks = min(weeks, service.MaxAnalyticsExportWeeks)
+
+	userIDs, ok := util.ParseUintListQuery(ctx, "userIds")
+	if !ok {
+		return
+	}
+	var teacherID uint
+	if user.Role != model.Admin {
+		teacherID = user.UserID
+	}
+
+	filename := fmt.Sprintf("analytics_%s_%s.csv", exportType, time.Now().Format("20060102"))
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Status(http.StatusOK)
+
+	// 响应头已发送，出错时只能中断输出并记录日志
+	if err := c.AnalyticsService.ExportAnalyticsCSV(ctx.Writer, exportType, weeks, userIDs, teacherID); err != nil {
+		logger.FromContext(ctx.Request.Context()).Error("Failed to export analytics", zap.String("type", exportType), zap.Error(err))
+	}
+}
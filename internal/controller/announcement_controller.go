@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"coder_edu_backend/internal/service"
+	"coder_edu_backend/internal/util"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnnouncementController struct {
+	AnnouncementService *service.AnnouncementService
+}
+
+func NewAnnouncementController(announcementService *service.AnnouncementService) *AnnouncementController {
+	return &AnnouncementController{AnnouncementService: announcementService}
+}
+
+// CreateAnnouncementRequest 发布公告请求
+type CreateAnnouncementRequest struct {
+	Title       string `json:"title" binding:"required,max=255" example:"期中考试安排"`
+	Content     string `json:"content" binding:"required" example:"下周三上午进行期中考试，请提前复习。"`
+	TargetScope string `json:"targetScope" binding:"omitempty,oneof=all specific" example:"all"` // all: 全部学生, specific: 指定学生
+	StudentIDs  []uint `json:"studentIds" swaggertype:"array,number" example:"1,2,3"`
+}
+
+// MarkAnnouncementsReadRequest 标记公告已读请求
+type MarkAnnouncementsReadRequest struct {
+	IDs []uint `json:"ids" swaggertype:"array,number" example:"1,2"` // 为空时标记全部
+}
+
+// @Summary 教师发布公告
+// @Description 向全部学生或指定学生发布单向公告，在线学生会通过 WebSocket 收到 ANNOUNCEMENT 消息，学生不能回复
+// @Tags 公告
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateAnnouncementRequest true "公告内容"
+// @Success 201 {object} util.Response{data=model.Announcement}
+// @Failure 400 {object} util.Response
+// @Router /api/teacher/announcements [post]
+func (c *AnnouncementController) CreateAnnouncement(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	var req CreateAnnouncementRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+
+	announcement, err := c.AnnouncementService.CreateAnnouncement(user.UserID, service.CreateAnnouncementInput{
+		Title:       req.Title,
+		Content:     req.Content,
+		TargetScope: req.TargetScope,
+		StudentIDs:  req.StudentIDs,
+	})
+	if err != nil {
+		if errors.Is(err, util.ErrTitleRequired) || errors.Is(err, util.ErrContentRequired) || errors.Is(err, util.ErrInvalidRequest) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
+		util.InternalServerError(ctx)
+		return
+	}
+
+	util.Created(ctx, announcement)
+}
+
+// @Summary 获取我的公告
+// @Description 按发布时间倒序返回当前用户收到的公告，附带已读时间和未读总数
+// @Tags 公告
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(20)
+// @Param unread query bool false "仅返回未读"
+// @Success 200 {object} util.Response{data=service.AnnouncementList}
+// @Router /api/announcements [get]
+func (c *AnnouncementController) ListAnnouncements(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	unreadOnly := ctx.Query("unread") == "true"
+
+	list, err := c.AnnouncementService.ListForUser(user.UserID, unreadOnly, limit, (page-1)*limit)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, list)
+}
+
+// @Summary 标记公告已读
+// @Tags 公告
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body MarkAnnouncementsReadRequest false "公告ID列表，为空时全部标记已读"
+// @Success 200 {object} util.Response
+// @Router /api/announcements/read [put]
+func (c *AnnouncementController) MarkRead(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	var req MarkAnnouncementsReadRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
+	}
+
+	updated, err := c.AnnouncementService.MarkRead(user.UserID, req.IDs)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, gin.H{"updated": updated})
+}
@@ -3,9 +3,11 @@ package controller
 import (
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type AssessmentController struct {
@@ -72,10 +74,8 @@ func (c *AssessmentController) ListQuestions(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/teacher/assessments/questions/{id} [get]
 func (c *AssessmentController) GetQuestion(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -112,8 +112,12 @@ func (c *AssessmentController) GetStudentQuestions(ctx *gin.Context) {
 		return
 	}
 
-	qs, err := c.Service.ListStudentQuestions()
+	qs, err := c.Service.ListStudentQuestions(user.UserID)
 	if err != nil {
+		if isAssessmentAttemptError(err) {
+			util.Error(ctx, 403, err.Error())
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -155,6 +159,10 @@ func (c *AssessmentController) SubmitAssessment(ctx *gin.Context) {
 
 	_, err = c.Service.SubmitAssessment(user.UserID, req)
 	if err != nil {
+		if isAssessmentAttemptError(err) {
+			util.Error(ctx, 403, err.Error())
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -162,7 +170,13 @@ func (c *AssessmentController) SubmitAssessment(ctx *gin.Context) {
 	util.Success(ctx, "提交成功")
 }
 
+// isAssessmentAttemptError 作答次数用尽或重测已截止
+func isAssessmentAttemptError(err error) bool {
+	return errors.Is(err, util.ErrAssessmentAttemptsUsed) || errors.Is(err, util.ErrRetestDeadlinePassed)
+}
+
 // @Summary 学生端：获取自己的评估状态和结果
+// @Description 返回提交记录、是否可作答，以及已用/剩余作答次数和重测截止时间
 // @Tags 学前测试评估
 // @Produce json
 // @Security BearerAuth
@@ -194,10 +208,8 @@ func (c *AssessmentController) GetMyResult(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/teacher/assessments/questions/{id} [put]
 func (c *AssessmentController) UpdateQuestion(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -224,10 +236,8 @@ func (c *AssessmentController) UpdateQuestion(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/teacher/assessments/questions/{id} [delete]
 func (c *AssessmentController) DeleteQuestion(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -261,6 +271,39 @@ func (c *AssessmentController) CreateAssessment(ctx *gin.Context) {
 	util.Created(ctx, a)
 }
 
+// @Summary 更新评估
+// @Description 更新评估信息，shuffleQuestions/shuffleOptions 开启后每个学生看到固定的乱序题目和选项
+// @Tags 学前测试评估
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "评估ID"
+// @Param body body service.AssessmentRequest true "评估信息"
+// @Success 200 {object} util.Response{data=model.Assessment}
+// @Router /api/teacher/assessments/{id} [put]
+func (c *AssessmentController) UpdateAssessment(ctx *gin.Context) {
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var req service.AssessmentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+	a, err := c.Service.UpdateAssessment(uint(id), req)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			util.NotFound(ctx)
+			return
+		}
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, a)
+}
+
 // @Summary 获取评估列表
 // @Tags 学前测试评估
 // @Produce json
@@ -298,10 +341,8 @@ func (c *AssessmentController) ListAssessments(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/teacher/assessments/{id} [get]
 func (c *AssessmentController) GetAssessment(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -347,10 +388,8 @@ func (c *AssessmentController) ListSubmissions(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/teacher/assessments/submissions/{id} [get]
 func (c *AssessmentController) GetSubmissionDetail(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -373,10 +412,8 @@ func (c *AssessmentController) GetSubmissionDetail(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/teacher/assessments/submissions/{id}/grade [post]
 func (c *AssessmentController) GradeSubmission(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -402,10 +439,8 @@ func (c *AssessmentController) GradeSubmission(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/teacher/assessments/submissions/{id} [delete]
 func (c *AssessmentController) DeleteSubmission(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -5,6 +5,7 @@ import (
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
 	"errors"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -30,7 +31,7 @@ func NewAuthController(authService *service.AuthService, userService *service.Us
 type RegisterRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8"`
+	Password string `json:"password" binding:"required"` // 强度要求见配置 password，不满足时 data.rule 给出未通过的规则
 	Role     string `json:"role" binding:"required,oneof=student teacher"`
 }
 
@@ -42,7 +43,7 @@ type RegisterRequest struct {
 // @Produce  json
 // @Param   body body RegisterRequest true "用户注册信息"
 // @Success 201 {object} util.Response{data=object} "创建成功"
-// @Failure 400 {object} util.Response "请求参数错误"
+// @Failure 400 {object} util.Response "请求参数错误或密码强度不足（data.rule 为 min_length、max_length、char_classes 或 breached）"
 // @Failure 409 {object} util.Response "邮箱已被注册"
 // @Failure 500 {object} util.Response "服务器内部错误"
 // @Router /api/register [post]
@@ -62,8 +63,8 @@ func (c *AuthController) Register(ctx *gin.Context) {
 
 	if err := c.AuthService.Register(user); err != nil {
 		if errors.Is(err, util.ErrEmailRegistered) {
-			util.Error(ctx, 409, "该邮箱已被注册")
-		} else {
+			util.Error(ctx, 409, util.T(ctx, "该邮箱已被注册"))
+		} else if !respondWeakPassword(ctx, err) {
 			util.LogInternalError(ctx, err)
 		}
 		return
@@ -72,6 +73,16 @@ func (c *AuthController) Register(ctx *gin.Context) {
 	util.Created(ctx, gin.H{"id": user.ID})
 }
 
+// respondWeakPassword 密码不符合强度要求时返回 400 并在 data.rule 中给出未通过的规则，其余错误返回 false
+func respondWeakPassword(ctx *gin.Context, err error) bool {
+	var ruleErr *service.PasswordRuleError
+	if !errors.As(err, &ruleErr) {
+		return false
+	}
+	util.ErrorWithData(ctx, http.StatusBadRequest, ruleErr.Message, gin.H{"rule": ruleErr.Rule})
+	return true
+}
+
 // CaptchaVerifyRequest 验证码校验请求
 type CaptchaVerifyRequest struct {
 	Trajectory []service.TrajectoryPoint `json:"trajectory"`
@@ -97,7 +108,7 @@ func (c *AuthController) VerifyCaptcha(ctx *gin.Context) {
 
 	token, err := c.CaptchaService.VerifyTrajectory(req.Trajectory, req.Duration)
 	if err != nil {
-		util.Error(ctx, 400, "人机验证失败: "+err.Error())
+		util.Error(ctx, 400, util.T(ctx, "人机验证失败")+": "+err.Error())
 		return
 	}
 
@@ -141,7 +152,7 @@ type LoginRequest struct {
 // @Success 200 {object} util.Response{data=object} "成功"
 // @Failure 400 {object} util.Response "请求参数错误"
 // @Failure 401 {object} util.Response "未授权"
-// @Failure 403 {object} util.Response "验证码错误"
+// @Failure 403 {object} util.Response "验证码错误或账号已被禁用"
 // @Router /api/login [post]
 func (c *AuthController) Login(ctx *gin.Context) {
 	var req LoginRequest
@@ -157,13 +168,17 @@ func (c *AuthController) Login(ctx *gin.Context) {
 	// 2. 如果不满足免验证条件，则必须校验 captcha_token
 	if !isTrusted {
 		if req.CaptchaToken == "" || !c.CaptchaService.ValidateToken(req.CaptchaToken) {
-			util.Error(ctx, 403, "请先完成人机验证")
+			util.Error(ctx, 403, util.T(ctx, "请先完成人机验证"))
 			return
 		}
 	}
 
 	token, err := c.AuthService.Login(req.Email, req.Password)
 	if err != nil {
+		if errors.Is(err, util.ErrAccountDisabled) {
+			util.Error(ctx, http.StatusForbidden, util.T(ctx, err.Error()))
+			return
+		}
 		util.Unauthorized(ctx)
 		return
 	}
@@ -207,15 +222,16 @@ func (c *AuthController) GetProfile(ctx *gin.Context) {
 	}
 
 	profile := gin.H{
-		"id":               user.ID,
-		"name":             user.Name,
-		"email":            user.Email,
-		"avatar":           user.Avatar,
-		"role":             user.Role,
-		"xp":               user.XP,
-		"language":         user.Language,
-		"createdAt":        user.CreatedAt,
-		"isCheckedInToday": isCheckedInToday,
+		"id":                user.ID,
+		"name":              user.Name,
+		"email":             user.Email,
+		"avatar":            user.Avatar,
+		"role":              user.Role,
+		"xp":                user.XP,
+		"language":          user.Language,
+		"createdAt":         user.CreatedAt,
+		"isCheckedInToday":  isCheckedInToday,
+		"qaPersonalization": !user.QAPersonalizationDisabled, // 智能问答是否结合个人学习记录
 	}
 
 	util.Success(ctx, profile)
@@ -5,12 +5,15 @@ import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // CProgrammingResourceController 处理C语言编程资源分类模块的API请求
@@ -83,7 +86,10 @@ func (c *CProgrammingResourceController) CreateResource(ctx *gin.Context) {
 // @Param limit query int false "每页记录数" default(10)
 // @Param search query string false "搜索关键词，匹配名称或描述"
 // @Param enabled query boolean false "过滤启用/禁用的资源"
-// @Param sortBy query string false "排序字段：name, order, createdAt, updatedAt" default(order)
+// @Param hasVideos query boolean false "过滤是否包含视频"
+// @Param hasQuestions query boolean false "过滤是否包含练习题"
+// @Param minItems query int false "内容总数（视频+文章+练习题）下限"
+// @Param sortBy query string false "排序字段：name, order, createdAt, updatedAt, itemCount" default(order)
 // @Param sortOrder query string false "排序方向：asc(升序), desc(降序)" default(asc)
 // @Success 200 {object} util.Response{data=map[string]interface{}}
 // @Failure 400 {object} util.Response "请求参数错误"
@@ -116,6 +122,7 @@ func (c *CProgrammingResourceController) GetAdminResources(ctx *gin.Context) {
 		"order":     true,
 		"createdAt": true,
 		"updatedAt": true,
+		"itemCount": true,
 	}
 	if !validSortFields[sortBy] {
 		util.BadRequest(ctx, "无效的排序字段")
@@ -138,7 +145,32 @@ func (c *CProgrammingResourceController) GetAdminResources(ctx *gin.Context) {
 		enabled = &enabledVal
 	}
 
-	result, err := c.Service.GetResourcesWithStats(page, limit, search, enabled, sortBy, sortOrder)
+	filter := service.ResourceStatsFilter{
+		Search:    search,
+		Enabled:   enabled,
+		SortBy:    sortBy,
+		SortOrder: sortOrder,
+	}
+	for name, target := range map[string]**bool{"hasVideos": &filter.HasVideos, "hasQuestions": &filter.HasQuestions} {
+		if str := ctx.Query(name); str != "" {
+			val, err := strconv.ParseBool(str)
+			if err != nil {
+				util.BadRequest(ctx, name+"必须是布尔值")
+				return
+			}
+			*target = &val
+		}
+	}
+	if str := ctx.Query("minItems"); str != "" {
+		minItems, err := strconv.Atoi(str)
+		if err != nil || minItems < 0 {
+			util.BadRequest(ctx, "minItems必须是非负整数")
+			return
+		}
+		filter.MinItems = minItems
+	}
+
+	result, err := c.Service.GetResourcesWithStats(page, limit, filter)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -158,10 +190,8 @@ func (c *CProgrammingResourceController) GetAdminResources(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/admin/c-programming/resources/{id} [put]
 func (c *CProgrammingResourceController) UpdateResource(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid resource ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -171,8 +201,8 @@ func (c *CProgrammingResourceController) UpdateResource(ctx *gin.Context) {
 		return
 	}
 
-	resource.ID = uint(id)
-	err = c.Service.UpdateResource(&resource)
+	resource.ID = id
+	err := c.Service.UpdateResource(&resource)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -191,14 +221,12 @@ func (c *CProgrammingResourceController) UpdateResource(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/admin/c-programming/resources/{id} [delete]
 func (c *CProgrammingResourceController) DeleteResource(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid resource ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	err = c.Service.DeleteResource(uint(id))
+	err := c.Service.DeleteResource(id)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -253,14 +281,12 @@ func (c *CProgrammingResourceController) GetResources(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/c-programming/resources/{id} [get]
 func (c *CProgrammingResourceController) GetResourceByID(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid resource ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	resource, err := c.Service.GetResourceByID(uint(id))
+	resource, err := c.Service.GetResourceByID(id)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -280,10 +306,8 @@ func (c *CProgrammingResourceController) GetResourceByID(ctx *gin.Context) {
 // @Success 201 {object} util.Response
 // @Router /api/admin/c-programming/resources/{id}/categories [post]
 func (c *CProgrammingResourceController) CreateCategory(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid resource ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -292,9 +316,13 @@ func (c *CProgrammingResourceController) CreateCategory(ctx *gin.Context) {
 		util.BadRequest(ctx, "Invalid request body")
 		return
 	}
+	if !validAnswerReveal(category.AnswerReveal, category.RevealDelayMinutes) {
+		util.BadRequest(ctx, answerRevealErrMsg)
+		return
+	}
 
-	category.CProgrammingResID = uint(id)
-	err = c.Service.CreateCategory(&category)
+	category.CProgrammingResID = id
+	err := c.Service.CreateCategory(&category)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -313,14 +341,12 @@ func (c *CProgrammingResourceController) CreateCategory(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/c-programming/resources/{id}/categories [get]
 func (c *CProgrammingResourceController) GetCategoriesByResourceID(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid resource ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	categories, err := c.Service.GetCategoriesByResourceID(uint(id))
+	categories, err := c.Service.GetCategoriesByResourceID(id)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -340,10 +366,8 @@ func (c *CProgrammingResourceController) GetCategoriesByResourceID(ctx *gin.Cont
 // @Success 201 {object} util.Response
 // @Router /api/admin/c-programming/categories/{categoryId}/questions [post]
 func (c *CProgrammingResourceController) CreateQuestion(ctx *gin.Context) {
-	categoryIDStr := ctx.Param("categoryId")
-	categoryID, err := strconv.ParseUint(categoryIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid category ID")
+	categoryID, ok := util.ParseUintParam(ctx, "categoryId")
+	if !ok {
 		return
 	}
 
@@ -356,6 +380,10 @@ func (c *CProgrammingResourceController) CreateQuestion(ctx *gin.Context) {
 		util.BadRequest(ctx, "积分不能为负数")
 		return
 	}
+	if !validAnswerReveal(question.AnswerReveal, question.RevealDelayMinutes) {
+		util.BadRequest(ctx, answerRevealErrMsg)
+		return
+	}
 
 	// 验证题目类型和必填字段
 	switch question.QuestionType {
@@ -380,8 +408,8 @@ func (c *CProgrammingResourceController) CreateQuestion(ctx *gin.Context) {
 		question.QuestionType = "programming"
 	}
 
-	question.CategoryID = uint(categoryID)
-	err = c.Service.CreateQuestion(&question)
+	question.CategoryID = categoryID
+	err := c.Service.CreateQuestion(&question)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -391,7 +419,7 @@ func (c *CProgrammingResourceController) CreateQuestion(ctx *gin.Context) {
 }
 
 // @Summary 获取练习题题目列表
-// @Description 获取指定练习题分类下的所有练习题题目，支持分页
+// @Description 获取指定练习题分类下的所有练习题题目，支持分页；学生端按答案公开策略（AnswerReveal）决定是否返回 CorrectAnswer/SolutionCode
 // @Tags C语言编程资源
 // @Accept json
 // @Produce json
@@ -402,17 +430,21 @@ func (c *CProgrammingResourceController) CreateQuestion(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/c-programming/categories/{categoryId}/questions [get]
 func (c *CProgrammingResourceController) GetQuestionsByCategoryID(ctx *gin.Context) {
-	categoryIDStr := ctx.Param("categoryId")
-	categoryID, err := strconv.ParseUint(categoryIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid category ID")
+	categoryID, ok := util.ParseUintParam(ctx, "categoryId")
+	if !ok {
+		return
+	}
+
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
 		return
 	}
 
 	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "5"))
 
-	questions, total, err := c.Service.GetQuestionsByCategoryID(uint(categoryID), page, limit)
+	questions, total, err := c.Service.GetQuestionsByCategoryID(categoryID, page, limit, user.UserID, user.Role)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -438,17 +470,15 @@ func (c *CProgrammingResourceController) GetQuestionsByCategoryID(ctx *gin.Conte
 // @Success 200 {object} util.Response
 // @Router /api/c-programming/resources/{id}/videos [get]
 func (c *CProgrammingResourceController) GetVideosByResourceID(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid resource ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
 	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "5"))
 
-	videos, total, err := c.Service.GetVideosByResourceID(uint(id), page, limit)
+	videos, total, err := c.Service.GetVideosByResourceID(id, page, limit)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -474,17 +504,15 @@ func (c *CProgrammingResourceController) GetVideosByResourceID(ctx *gin.Context)
 // @Success 200 {object} util.Response
 // @Router /api/c-programming/resources/{id}/articles [get]
 func (c *CProgrammingResourceController) GetArticlesByResourceID(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid resource ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
 	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "5"))
 
-	articles, total, err := c.Service.GetArticlesByResourceID(uint(id), page, limit)
+	articles, total, err := c.Service.GetArticlesByResourceID(id, page, limit)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -516,24 +544,42 @@ func (c *CProgrammingResourceController) GetArticlesByResourceID(ctx *gin.Contex
 // @Failure 500 {object} util.Response
 // @Router /api/c-programming/resources/upload [post]
 func (c *CProgrammingResourceController) UploadResource(ctx *gin.Context) {
-	resourceID := ctx.PostForm("resource_id")
+	if !util.LimitUploadBody(ctx, util.MB(c.Config.Upload.ResourceMaxMB)) {
+		return
+	}
+	resourceIDStr := ctx.PostForm("resource_id")
 	resourceType := ctx.PostForm("type")
 	title := ctx.PostForm("title")
 	description := ctx.PostForm("description")
 
-	if resourceID == "" || resourceType == "" || title == "" {
+	if resourceIDStr == "" || resourceType == "" || title == "" {
 		util.BadRequest(ctx, "resource_id, type, and title are required")
 		return
 	}
 
+	resourceID, err := strconv.ParseUint(resourceIDStr, 10, 32)
+	if err != nil || resourceID == 0 {
+		util.BadRequest(ctx, "invalid resource_id")
+		return
+	}
+
+	// 确认目标资源模块存在，避免资源挂到不存在的模块下
+	if _, err := c.Service.GetResourceByID(uint(resourceID)); err != nil {
+		util.BadRequest(ctx, "resource module not found")
+		return
+	}
+
 	file, err := ctx.FormFile("file")
 	if err != nil {
 		util.BadRequest(ctx, "file is required")
 		return
 	}
+	if !util.CheckUpload(ctx, file, resourceExtensions(), util.MB(c.Config.Upload.ResourceMaxMB)) {
+		return
+	}
 
 	resource := &model.Resource{
-		ModuleID:    util.MustParseUint(resourceID),
+		ModuleID:    uint(resourceID),
 		Type:        model.ResourceType(resourceType),
 		Title:       title,
 		Description: description,
@@ -558,36 +604,34 @@ func (c *CProgrammingResourceController) UploadResource(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/admin/resources/{id}/content [get]
 func (c *CProgrammingResourceController) GetResourceCompleteContent(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid resource ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
 	// 获取资源基本信息
-	resource, err := c.Service.GetResourceByID(uint(id))
+	resource, err := c.Service.GetResourceByID(id)
 	if err != nil {
 		util.NotFound(ctx)
 		return
 	}
 
 	// 获取视频列表
-	videos, _, err := c.Service.GetVideosByResourceID(uint(id), 1, 1000) // 获取所有视频
+	videos, _, err := c.Service.GetVideosByResourceID(id, 1, 1000) // 获取所有视频
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
 	}
 
 	// 获取文章列表
-	articles, _, err := c.Service.GetArticlesByResourceID(uint(id), 1, 1000) // 获取所有文章
+	articles, _, err := c.Service.GetArticlesByResourceID(id, 1, 1000) // 获取所有文章
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
 	}
 
 	// 获取练习题类目及题目
-	categories, err := c.Service.GetCategoriesByResourceID(uint(id))
+	categories, err := c.Service.GetCategoriesByResourceID(id)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -596,7 +640,7 @@ func (c *CProgrammingResourceController) GetResourceCompleteContent(ctx *gin.Con
 	// 为每个类目获取题目
 	categoriesWithQuestions := make([]map[string]interface{}, 0, len(categories))
 	for _, category := range categories {
-		questions, _, _ := c.Service.GetQuestionsByCategoryID(category.ID, 1, 1000)
+		questions, _, _ := c.Service.GetQuestionsByCategoryID(category.ID, 1, 1000, 0, model.Admin)
 		categoryMap := map[string]interface{}{
 			"id":          category.ID,
 			"name":        category.Name,
@@ -630,10 +674,8 @@ func (c *CProgrammingResourceController) GetResourceCompleteContent(ctx *gin.Con
 // @Success 201 {object} util.Response
 // @Router /api/admin/resources/{id}/videos [post]
 func (c *CProgrammingResourceController) AddVideoToResource(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid resource ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -653,7 +695,7 @@ func (c *CProgrammingResourceController) AddVideoToResource(ctx *gin.Context) {
 	}
 
 	resource := &model.Resource{
-		ModuleID:    uint(id),
+		ModuleID:    id,
 		ModuleType:  "c_programming",
 		Type:        model.Video,
 		Title:       video.Title,
@@ -665,7 +707,7 @@ func (c *CProgrammingResourceController) AddVideoToResource(ctx *gin.Context) {
 		// Duration和Order可以存储在额外字段中，这里使用description扩展
 	}
 
-	if err := c.ContentService.ResourceRepo.Create(resource); err != nil {
+	if err := c.ContentService.CreateVideoResource(resource); err != nil {
 		util.InternalServerError(ctx)
 		return
 	}
@@ -685,10 +727,8 @@ func (c *CProgrammingResourceController) AddVideoToResource(ctx *gin.Context) {
 // @Success 201 {object} util.Response
 // @Router /api/admin/resources/{id}/articles [post]
 func (c *CProgrammingResourceController) AddArticleToResource(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid resource ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -712,7 +752,7 @@ func (c *CProgrammingResourceController) AddArticleToResource(ctx *gin.Context)
 	}
 
 	resource := &model.Resource{
-		ModuleID:    uint(id),
+		ModuleID:    id,
 		ModuleType:  "c_programming",
 		Type:        model.Article,
 		Title:       article.Title,
@@ -744,6 +784,10 @@ func convertMapKeysToSnakeCase(input map[string]interface{}) map[string]interfac
 			result["module_type"] = value
 		case "uploaderId":
 			result["uploader_id"] = value
+		case "answerReveal":
+			result["answer_reveal"] = value
+		case "revealDelayMinutes":
+			result["reveal_delay_minutes"] = value
 		default:
 			result[key] = value
 		}
@@ -752,7 +796,26 @@ func convertMapKeysToSnakeCase(input map[string]interface{}) map[string]interfac
 	return result
 }
 
-// 更新资源分类内容项的通用方法
+// bindUpdateMap 绑定按字段部分更新的请求体：请求体必须是 JSON 对象且至少包含一个字段
+func bindUpdateMap(ctx *gin.Context) (map[string]interface{}, bool) {
+	var updateData map[string]interface{}
+	if err := ctx.ShouldBindJSON(&updateData); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field == "" {
+			util.BadRequest(ctx, util.ErrBodyNotJSONObject.Error())
+			return nil, false
+		}
+		util.BadRequest(ctx, util.ErrInvalidJSONBody.Error())
+		return nil, false
+	}
+	if len(updateData) == 0 {
+		util.BadRequest(ctx, util.ErrNoFieldsToUpdate.Error())
+		return nil, false
+	}
+	return updateData, true
+}
+
+// 更新资源分类内容项的通用方法。过滤掉不可更新的字段后为空时返回 ErrNoFieldsToUpdate
 func (c *CProgrammingResourceController) UpdateContentItem(ctx *gin.Context, contentType string, itemID uint, updateData interface{}) error {
 	switch contentType {
 	case "video":
@@ -784,6 +847,9 @@ func (c *CProgrammingResourceController) UpdateContentItem(ctx *gin.Context, con
 			}
 			videoData = filteredData
 		}
+		if len(videoData) == 0 {
+			return util.ErrNoFieldsToUpdate
+		}
 
 		return c.Service.UpdateVideo(itemID, videoData)
 
@@ -816,6 +882,9 @@ func (c *CProgrammingResourceController) UpdateContentItem(ctx *gin.Context, con
 			}
 			articleData = filteredData
 		}
+		if len(articleData) == 0 {
+			return util.ErrNoFieldsToUpdate
+		}
 
 		return c.Service.UpdateArticle(itemID, articleData)
 
@@ -836,6 +905,8 @@ func (c *CProgrammingResourceController) UpdateContentItem(ctx *gin.Context, con
 				"description":          true,
 				"order":                true,
 				"c_programming_res_id": true,
+				"answer_reveal":        true,
+				"reveal_delay_minutes": true,
 			}
 
 			filteredData := make(map[string]interface{})
@@ -845,8 +916,9 @@ func (c *CProgrammingResourceController) UpdateContentItem(ctx *gin.Context, con
 				}
 			}
 			categoryData = filteredData
-		} else {
-			categoryData = make(map[string]interface{})
+		}
+		if len(categoryData) == 0 {
+			return util.ErrNoFieldsToUpdate
 		}
 
 		return c.Service.UpdateExerciseCategory(itemID, categoryData)
@@ -858,16 +930,18 @@ func (c *CProgrammingResourceController) UpdateContentItem(ctx *gin.Context, con
 
 			// 过滤不需要更新的字段和无效字段
 			validFields := map[string]bool{
-				"category_id":    true,
-				"title":          true,
-				"description":    true,
-				"difficulty":     true,
-				"hint":           true,
-				"solution_code":  true,
-				"question_type":  true,
-				"options":        true,
-				"correct_answer": true,
-				"points":         true,
+				"category_id":          true,
+				"title":                true,
+				"description":          true,
+				"difficulty":           true,
+				"hint":                 true,
+				"solution_code":        true,
+				"question_type":        true,
+				"options":              true,
+				"correct_answer":       true,
+				"points":               true,
+				"answer_reveal":        true,
+				"reveal_delay_minutes": true,
 			}
 
 			filteredData := make(map[string]interface{})
@@ -901,10 +975,8 @@ func (c *CProgrammingResourceController) UpdateContentItem(ctx *gin.Context, con
 // @Router /api/admin/{itemType}/{itemId} [delete]
 func (c *CProgrammingResourceController) DeleteContentItem(ctx *gin.Context) {
 	itemType := ctx.Param("itemType")
-	itemIDStr := ctx.Param("itemId")
-	itemID, err := strconv.ParseUint(itemIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid item ID")
+	itemID, ok := util.ParseUintParam(ctx, "itemId")
+	if !ok {
 		return
 	}
 
@@ -932,16 +1004,13 @@ func (c *CProgrammingResourceController) DeleteContentItem(ctx *gin.Context) {
 // @Failure 500 {object} util.Response "服务器内部错误"
 // @Router /api/admin/videos/{id} [put]
 func (c *CProgrammingResourceController) UpdateVideo(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid video ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	var updateData map[string]interface{}
-	if err := ctx.ShouldBindJSON(&updateData); err != nil {
-		util.BadRequest(ctx, err.Error())
+	updateData, ok := bindUpdateMap(ctx)
+	if !ok {
 		return
 	}
 
@@ -952,7 +1021,11 @@ func (c *CProgrammingResourceController) UpdateVideo(ctx *gin.Context) {
 		}
 	}
 
-	if err := c.UpdateContentItem(ctx, "video", uint(id), updateData); err != nil {
+	if err := c.UpdateContentItem(ctx, "video", id, updateData); err != nil {
+		if errors.Is(err, util.ErrNoFieldsToUpdate) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -976,16 +1049,13 @@ func (c *CProgrammingResourceController) UpdateVideo(ctx *gin.Context) {
 // @Failure 500 {object} util.Response "服务器内部错误"
 // @Router /api/admin/articles/{id} [put]
 func (c *CProgrammingResourceController) UpdateArticle(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid article ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	var updateData map[string]interface{}
-	if err := ctx.ShouldBindJSON(&updateData); err != nil {
-		util.BadRequest(ctx, err.Error())
+	updateData, ok := bindUpdateMap(ctx)
+	if !ok {
 		return
 	}
 
@@ -996,7 +1066,11 @@ func (c *CProgrammingResourceController) UpdateArticle(ctx *gin.Context) {
 		}
 	}
 
-	if err := c.UpdateContentItem(ctx, "article", uint(id), updateData); err != nil {
+	if err := c.UpdateContentItem(ctx, "article", id, updateData); err != nil {
+		if errors.Is(err, util.ErrNoFieldsToUpdate) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -1020,20 +1094,30 @@ func (c *CProgrammingResourceController) UpdateArticle(ctx *gin.Context) {
 // @Failure 500 {object} util.Response "服务器内部错误"
 // @Router /api/admin/exercise-categories/{id} [put]
 func (c *CProgrammingResourceController) UpdateExerciseCategory(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid exercise category ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	var updateData map[string]interface{}
-	if err := ctx.ShouldBindJSON(&updateData); err != nil {
-		util.BadRequest(ctx, err.Error())
+	updateData, ok := bindUpdateMap(ctx)
+	if !ok {
 		return
 	}
+	if data := convertMapKeysToSnakeCase(updateData); data["answer_reveal"] != nil || data["reveal_delay_minutes"] != nil {
+		policy, okPolicy := data["answer_reveal"].(string)
+		delay, okDelay := data["reveal_delay_minutes"].(float64)
+		if (data["answer_reveal"] != nil && !okPolicy) || (data["reveal_delay_minutes"] != nil && !okDelay) ||
+			!validAnswerReveal(policy, int(delay)) {
+			util.BadRequest(ctx, answerRevealErrMsg)
+			return
+		}
+	}
 
-	if err := c.UpdateContentItem(ctx, "exercise-category", uint(id), updateData); err != nil {
+	if err := c.UpdateContentItem(ctx, "exercise-category", id, updateData); err != nil {
+		if errors.Is(err, util.ErrNoFieldsToUpdate) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -1052,10 +1136,8 @@ func (c *CProgrammingResourceController) UpdateExerciseCategory(ctx *gin.Context
 // @Success 200 {object} util.Response
 // @Router /api/admin/questions/{id} [put]
 func (c *CProgrammingResourceController) UpdateQuestion(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid question ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -1069,6 +1151,10 @@ func (c *CProgrammingResourceController) UpdateQuestion(ctx *gin.Context) {
 		util.BadRequest(ctx, "积分不能为负数")
 		return
 	}
+	if !validAnswerReveal(question.AnswerReveal, question.RevealDelayMinutes) {
+		util.BadRequest(ctx, answerRevealErrMsg)
+		return
+	}
 
 	// 验证题目类型和必填字段
 	switch question.QuestionType {
@@ -1090,10 +1176,10 @@ func (c *CProgrammingResourceController) UpdateQuestion(ctx *gin.Context) {
 		}
 	}
 
-	question.ID = uint(id)
+	question.ID = id
 
 	// 直接调用Service层的UpdateQuestion方法
-	err = c.Service.UpdateQuestion(&question)
+	err := c.Service.UpdateQuestion(&question)
 	if err != nil {
 		// 如果Service层的UpdateQuestion方法不存在或有问题，可以使用UpdateContentItem方法
 		// 转换question为map格式
@@ -1106,8 +1192,10 @@ func (c *CProgrammingResourceController) UpdateQuestion(ctx *gin.Context) {
 		updateData["question_type"] = question.QuestionType
 		updateData["options"] = question.Options
 		updateData["correct_answer"] = question.CorrectAnswer
+		updateData["answer_reveal"] = question.AnswerReveal
+		updateData["reveal_delay_minutes"] = question.RevealDelayMinutes
 
-		if err := c.UpdateContentItem(ctx, "question", uint(id), updateData); err != nil {
+		if err := c.UpdateContentItem(ctx, "question", id, updateData); err != nil {
 			util.InternalServerError(ctx)
 			return
 		}
@@ -1116,8 +1204,39 @@ func (c *CProgrammingResourceController) UpdateQuestion(ctx *gin.Context) {
 	util.Success(ctx, question)
 }
 
+// @Summary 采纳练习题建议难度
+// @Description 将题目难度设为后台根据提交正确率计算的建议难度（SuggestedDifficulty）
+// @Tags C语言编程资源
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "题目ID"
+// @Success 200 {object} util.Response{data=model.ExerciseQuestion}
+// @Failure 400 {object} util.Response "提交人数不足，暂无建议难度"
+// @Failure 404 {object} util.Response
+// @Router /api/admin/questions/{id}/difficulty/accept [post]
+func (c *CProgrammingResourceController) AcceptSuggestedDifficulty(ctx *gin.Context) {
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	question, err := c.Service.AcceptSuggestedDifficulty(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			util.NotFound(ctx)
+		case errors.Is(err, util.ErrNoSuggestedDifficulty):
+			util.BadRequest(ctx, err.Error())
+		default:
+			util.InternalServerError(ctx)
+		}
+		return
+	}
+	util.Success(ctx, question)
+}
+
 // @Summary 管理员获取指定分类下的所有练习题题目
-// @Description 管理员获取指定练习题分类下的所有题目（不需要分页）
+// @Description 管理员获取指定练习题分类下的所有题目（不需要分页），包含老师设置的难度 Difficulty 和按提交正确率计算的建议难度 SuggestedDifficulty
 // @Tags C语言编程资源
 // @Accept json
 // @Produce json
@@ -1126,14 +1245,12 @@ func (c *CProgrammingResourceController) UpdateQuestion(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/admin/c-programming/categories/{categoryId}/questions/all [get]
 func (c *CProgrammingResourceController) AdminGetAllQuestionsByCategoryID(ctx *gin.Context) {
-	categoryIDStr := ctx.Param("categoryId")
-	categoryID, err := strconv.ParseUint(categoryIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid category ID")
+	categoryID, ok := util.ParseUintParam(ctx, "categoryId")
+	if !ok {
 		return
 	}
 
-	questions, total, err := c.Service.GetAllQuestionsByCategoryID(uint(categoryID))
+	questions, total, err := c.Service.GetAllQuestionsByCategoryID(categoryID)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -1171,11 +1288,13 @@ func (c *CProgrammingResourceController) GetResourcesWithAllContent(ctx *gin.Con
 	// 获取当前用户ID
 	user := util.GetUserFromContext(ctx)
 	var userID uint = 0
+	role := model.Student
 	if user != nil {
 		userID = user.UserID
+		role = user.Role
 	}
 
-	resourcesWithContent, total, err := c.Service.GetResourcesWithAllContent(enabled, page, limit, userID)
+	resourcesWithContent, total, err := c.Service.GetResourcesWithAllContent(enabled, page, limit, userID, role)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -1195,30 +1314,43 @@ func (c *CProgrammingResourceController) GetResourcesWithAllContent(ctx *gin.Con
 // @Accept json
 // @Produce json
 // @Param categoryId path int true "分类ID"
-// @Param user_id query int true "用户ID"
+// @Security BearerAuth
+// @Param user_id query int false "用户ID，默认当前用户；查询他人需教师或管理员权限"
 // @Param page query int false "页码" default(1)
 // @Param limit query int false "每页数量" default(5)
 // @Success 200 {object} util.Response
+// @Failure 403 {object} util.Response
 // @Router /api/c-programming/categories/{categoryId}/questions-with-status [get]
 func (c *CProgrammingResourceController) GetQuestionsByCategoryIDWithUserStatus(ctx *gin.Context) {
-	categoryIDStr := ctx.Param("categoryId")
-	categoryID, err := strconv.ParseUint(categoryIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid category ID")
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
 		return
 	}
 
-	userIDStr := ctx.Query("user_id")
-	userID, err := strconv.ParseUint(userIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid user ID")
+	categoryID, ok := util.ParseUintParam(ctx, "categoryId")
+	if !ok {
+		return
+	}
+
+	userID := uint64(user.UserID)
+	if userIDStr := ctx.Query("user_id"); userIDStr != "" {
+		var err error
+		userID, err = strconv.ParseUint(userIDStr, 10, 32)
+		if err != nil {
+			util.BadRequest(ctx, "Invalid user ID")
+			return
+		}
+	}
+	if !canViewUserStatus(user, uint(userID)) {
+		util.Forbidden(ctx)
 		return
 	}
 
 	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "5"))
 
-	questions, total, err := c.Service.GetQuestionsByCategoryIDWithUserStatus(uint(categoryID), uint(userID), page, limit)
+	questions, total, err := c.Service.GetQuestionsByCategoryIDWithUserStatus(categoryID, uint(userID), user.Role, page, limit)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -1242,10 +1374,8 @@ func (c *CProgrammingResourceController) GetQuestionsByCategoryIDWithUserStatus(
 // @Success 200 {object} util.Response
 // @Router /api/public/c-programming/questions/{questionId}/submit [post]
 func (c *CProgrammingResourceController) SubmitExerciseAnswerPublic(ctx *gin.Context) {
-	questionIDStr := ctx.Param("questionId")
-	questionID, err := strconv.ParseUint(questionIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid question ID")
+	questionID, ok := util.ParseUintParam(ctx, "questionId")
+	if !ok {
 		return
 	}
 
@@ -1287,8 +1417,15 @@ func (c *CProgrammingResourceController) SubmitExerciseAnswerPublic(ctx *gin.Con
 // @Param userID path uint true "用户ID"
 // @Param questionID path uint true "题目ID"
 // @Success 200 {object} util.Response
+// @Failure 403 {object} util.Response
 // @Router /api/c-programming/exercises/users/{userID}/questions/{questionID}/submission [get]
 func (c *CProgrammingResourceController) CheckUserSubmittedQuestion(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
 	// 从路径参数中获取用户ID和题目ID
 	userIDStr := ctx.Param("userID")
 	questionIDStr := ctx.Param("questionID")
@@ -1306,6 +1443,11 @@ func (c *CProgrammingResourceController) CheckUserSubmittedQuestion(ctx *gin.Con
 		return
 	}
 
+	if !canViewUserStatus(user, uint(userID)) {
+		util.Forbidden(ctx)
+		return
+	}
+
 	// 调用服务层方法检查用户是否提交过题目
 	isSubmitted, err := c.Service.CheckUserSubmittedQuestion(uint(userID), uint(questionID))
 	if err != nil {
@@ -1321,6 +1463,18 @@ func (c *CProgrammingResourceController) CheckUserSubmittedQuestion(ctx *gin.Con
 	})
 }
 
+const answerRevealErrMsg = "answerReveal 必须为 always/after_submission/after_delay/never，revealDelayMinutes 不能为负数"
+
+// validAnswerReveal 校验答案公开策略配置
+func validAnswerReveal(policy string, delayMinutes int) bool {
+	return model.IsValidAnswerReveal(policy) && delayMinutes >= 0
+}
+
+// canViewUserStatus 学生只能查询自己的答题状态，教师和管理员可查询任意学生
+func canViewUserStatus(claims *util.Claims, userID uint) bool {
+	return claims.UserID == userID || claims.Role == model.Teacher || claims.Role == model.Admin
+}
+
 // @Summary 获取带进度的资源模块
 // @Description 获取指定资源模块的详细信息，包括视频、文章、练习题的完成状态和进度
 // @Tags C语言编程资源
@@ -1339,15 +1493,13 @@ func (c *CProgrammingResourceController) GetResourceModuleWithProgress(ctx *gin.
 	}
 
 	// 获取资源ID
-	resourceIDStr := ctx.Param("resourceId")
-	resourceID, err := strconv.ParseUint(resourceIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid resource ID")
+	resourceID, ok := util.ParseUintParam(ctx, "resourceId")
+	if !ok {
 		return
 	}
 
 	// 获取带进度的资源模块
-	resourceModule, err := c.Service.GetResourceModuleWithProgress(uint(resourceID), user.UserID)
+	resourceModule, err := c.Service.GetResourceModuleWithProgress(uint(resourceID), user.UserID, user.Role)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -1383,7 +1535,7 @@ func (c *CProgrammingResourceController) GetUnfinishedResourceModules(ctx *gin.C
 	}
 
 	// 调用服务层获取未完成的资源模块
-	modules, err := c.Service.GetUnfinishedResourceModules(user.UserID, limit)
+	modules, err := c.Service.GetUnfinishedResourceModules(user.UserID, user.Role, limit)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -1411,27 +1563,36 @@ func (c *CProgrammingResourceController) UpdateResourceCompletionStatus(ctx *gin
 	}
 
 	// 获取资源ID
-	resourceIDStr := ctx.Param("resourceId")
-	resourceID, err := strconv.ParseUint(resourceIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid resource ID")
+	resourceID, ok := util.ParseUintParam(ctx, "resourceId")
+	if !ok {
 		return
 	}
 
-	// 解析请求体
+	// 解析请求体（使用指针区分未传与 false，binding:"required" 会拒绝 bool 的零值）
 	var req struct {
-		Completed bool `json:"completed" binding:"required"`
+		Completed *bool `json:"completed"`
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		util.BadRequest(ctx, err.Error())
 		return
 	}
+	if req.Completed == nil {
+		util.BadRequest(ctx, "completed is required")
+		return
+	}
 
-	// 更新资源完成状态
-	err = c.Service.UpdateResourceCompletionStatus(user.UserID, uint(resourceID), req.Completed)
+	// 更新资源完成状态（重复提交相同状态结果一致）
+	err := c.Service.UpdateResourceCompletionStatus(user.UserID, resourceID, *req.Completed)
 	if err != nil {
-		util.InternalServerError(ctx)
+		switch {
+		case errors.Is(err, util.ErrResourceNotFound):
+			util.NotFound(ctx)
+		case errors.Is(err, util.ErrPermissionDenied):
+			util.Forbidden(ctx)
+		default:
+			util.InternalServerError(ctx)
+		}
 		return
 	}
 
@@ -1445,7 +1606,9 @@ func (c *CProgrammingResourceController) UpdateResourceCompletionStatus(ctx *gin
 // @Produce json
 // @Security BearerAuth
 // @Param enabled query boolean false "是否只获取启用的资源分类"
-// @Success 200 {object} util.Response
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(10)
+// @Success 200 {object} util.Response{data=util.PageResponse{list=[]service.ResourceModuleWithProgress}}
 // @Router /api/c-programming/resource-progress/all [get]
 func (c *CProgrammingResourceController) GetAllResourceModulesWithProgress(ctx *gin.Context) {
 	// 获取当前用户
@@ -1463,12 +1626,26 @@ func (c *CProgrammingResourceController) GetAllResourceModulesWithProgress(ctx *
 		enabled = &val
 	}
 
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
 	// 调用服务层方法
-	resourceModules, err := c.Service.GetAllResourceModulesWithProgress(user.UserID, enabled)
+	resourceModules, total, err := c.Service.GetAllResourceModulesWithProgress(user.UserID, user.Role, enabled, page, limit)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
 	}
 
-	util.Success(ctx, resourceModules)
+	util.Success(ctx, util.PageResponse{
+		List:  resourceModules,
+		Total: int64(total),
+		Page:  page,
+		Limit: limit,
+	})
 }
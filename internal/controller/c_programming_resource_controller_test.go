@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"bytes"
+	"coder_edu_backend/internal/config"
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/service"
+	"coder_edu_backend/internal/testutil"
+	"coder_edu_backend/internal/util"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestContentUpdateRejectsInvalidBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := &CProgrammingResourceController{}
+	r := gin.New()
+	r.PUT("/videos/:id", c.UpdateVideo)
+	r.PUT("/articles/:id", c.UpdateArticle)
+	r.PUT("/exercise-categories/:id", c.UpdateExerciseCategory)
+
+	bodies := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"array", `[{"title": "数组"}]`, util.ErrBodyNotJSONObject.Error()},
+		{"string scalar", `"title"`, util.ErrBodyNotJSONObject.Error()},
+		{"number scalar", `42`, util.ErrBodyNotJSONObject.Error()},
+		{"empty object", `{}`, util.ErrNoFieldsToUpdate.Error()},
+		{"malformed json", `{"title": `, util.ErrInvalidJSONBody.Error()},
+	}
+	for _, path := range []string{"/videos/1", "/articles/1", "/exercise-categories/1"} {
+		for _, tt := range bodies {
+			t.Run(path+" "+tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				if w.Code != http.StatusBadRequest {
+					t.Fatalf("status = %d, want 400", w.Code)
+				}
+				var resp util.Response
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if resp.Message != tt.want {
+					t.Fatalf("message = %q, want %q", resp.Message, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func newUploadRequest(t *testing.T, resourceID string) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("resource_id", resourceID)
+	mw.WriteField("type", "document")
+	mw.WriteField("title", "上传测试")
+	fw, err := mw.CreateFormFile("file", "notes.pdf")
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	fw.Write([]byte("%PDF-1.4"))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadResourceRejectsBogusResourceID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := &CProgrammingResourceController{Config: &config.Config{}}
+	r := gin.New()
+	r.POST("/upload", c.UploadResource)
+
+	for _, id := range []string{"abc", "0", "-1", "1.5", "99999999999"} {
+		t.Run(id, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, newUploadRequest(t, id))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", w.Code)
+			}
+			var resp util.Response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Message != "invalid resource_id" {
+				t.Fatalf("message = %q, want %q", resp.Message, "invalid resource_id")
+			}
+		})
+	}
+}
+
+// TestUploadResourceRejectsUnknownResourceID 需要 testutil.MySQL 的测试库，未设置 TEST_MYSQL_DSN 时跳过
+func TestUploadResourceRejectsUnknownResourceID(t *testing.T) {
+	db := testutil.MySQL(t)
+
+	gin.SetMode(gin.TestMode)
+	c := &CProgrammingResourceController{
+		Service: &service.CProgrammingResourceService{Repo: repository.NewCProgrammingResourceRepository(db)},
+		Config:  &config.Config{},
+	}
+	r := gin.New()
+	r.POST("/upload", c.UploadResource)
+
+	// 取一个比现有最大 ID 更大的值，确保模块不存在
+	var maxID uint
+	db.Model(&model.CProgrammingResource{}).Unscoped().Select("COALESCE(MAX(id), 0)").Scan(&maxID)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newUploadRequest(t, strconv.FormatUint(uint64(maxID)+1, 10)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	var resp util.Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Message != "resource module not found" {
+		t.Fatalf("message = %q, want %q", resp.Message, "resource module not found")
+	}
+}
+
+func TestCanViewUserStatus(t *testing.T) {
+	cases := []struct {
+		role   model.UserRole
+		userID uint
+		want   bool
+	}{
+		{model.Student, 1, true},
+		{model.Student, 2, false},
+		{model.Teacher, 2, true},
+		{model.Admin, 2, true},
+	}
+	for _, tc := range cases {
+		claims := &util.Claims{UserID: 1, Role: tc.role}
+		if got := canViewUserStatus(claims, tc.userID); got != tc.want {
+			t.Errorf("canViewUserStatus(%s, %d) = %v, want %v", tc.role, tc.userID, got, tc.want)
+		}
+	}
+}
+
+func TestExerciseStatusRejectsCrossUserQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := &CProgrammingResourceController{}
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("user", &util.Claims{UserID: 1, Role: model.Student})
+	})
+	r.GET("/categories/:categoryId/questions-with-status", c.GetQuestionsByCategoryIDWithUserStatus)
+	r.GET("/exercises/users/:userID/questions/:questionID/submission", c.CheckUserSubmittedQuestion)
+
+	for _, path := range []string{
+		"/categories/1/questions-with-status?user_id=2",
+		"/exercises/users/2/questions/1/submission",
+	} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want 403", w.Code)
+			}
+		})
+	}
+}
+
+func TestUpdateResourceCompletionStatusRequiresCompleted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := &CProgrammingResourceController{}
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("user", &util.Claims{UserID: 1, Role: model.Student})
+	})
+	r.POST("/resource-progress/:resourceId/completion", c.UpdateResourceCompletionStatus)
+
+	for _, body := range []string{`{}`, `{"completed": null}`} {
+		t.Run(body, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/resource-progress/1/completion", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", w.Code)
+			}
+			var resp util.Response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Message != "completed is required" {
+				t.Fatalf("message = %q, want %q", resp.Message, "completed is required")
+			}
+		})
+	}
+}
+
+// TestUpdateResourceCompletionStatusMarksIncomplete 需要 testutil.MySQL 的测试库，未设置 TEST_MYSQL_DSN 时跳过
+func TestUpdateResourceCompletionStatusMarksIncomplete(t *testing.T) {
+	db := testutil.MySQL(t)
+
+	module := model.CProgrammingResource{Name: "completion-test", IconURL: "/icon.png", Enabled: true}
+	if err := db.Create(&module).Error; err != nil {
+		t.Fatalf("create module: %v", err)
+	}
+	resource := model.Resource{Title: "completion-test", Type: model.Video, URL: "/uploads/completion-test.mp4", ModuleType: "c_programming", ModuleID: module.ID}
+	if err := db.Create(&resource).Error; err != nil {
+		t.Fatalf("create resource: %v", err)
+	}
+	const userID = 987654321
+	defer func() {
+		db.Unscoped().Where("user_id = ? AND resource_id = ?", userID, resource.ID).Delete(&model.ResourceCompletion{})
+		db.Unscoped().Delete(&resource)
+		db.Unscoped().Delete(&module)
+	}()
+
+	completionRepo := repository.NewResourceCompletionRepository(db)
+	gin.SetMode(gin.TestMode)
+	c := &CProgrammingResourceController{
+		Service: &service.CProgrammingResourceService{
+			Repo:                   repository.NewCProgrammingResourceRepository(db),
+			ResourceRepo:           repository.NewResourceRepository(db),
+			ResourceCompletionRepo: completionRepo,
+		},
+	}
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		ctx.Set("user", &util.Claims{UserID: userID, Role: model.Student})
+	})
+	r.POST("/resource-progress/:resourceId/completion", c.UpdateResourceCompletionStatus)
+
+	path := fmt.Sprintf("/resource-progress/%d/completion", resource.ID)
+	// 先标记完成，再取消完成，重复提交 false 结果一致
+	for _, completed := range []bool{true, false, false} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(fmt.Sprintf(`{"completed": %t}`, completed)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("completed=%t: status = %d, want 200 (body %s)", completed, w.Code, w.Body.String())
+		}
+
+		got, err := completionRepo.GetCompletionStatus(userID, resource.ID)
+		if err != nil {
+			t.Fatalf("get completion status: %v", err)
+		}
+		if got != completed {
+			t.Fatalf("stored completed = %t, want %t", got, completed)
+		}
+	}
+}
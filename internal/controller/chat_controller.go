@@ -5,6 +5,8 @@ import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -21,6 +23,8 @@ type ChatController struct {
 	FriendshipService *service.FriendshipService
 	Hub               *service.ChatHub
 	StorageService    *service.StorageService
+	UploadLimiter     *service.UploadLimiter
+	Unread            *service.UnreadService
 	Config            *config.Config
 }
 
@@ -28,18 +32,26 @@ type ChatController struct {
 type CreateGroupRequest struct {
 	Name      string `json:"name" binding:"required" example:"学习小组"`
 	MemberIDs []uint `json:"memberIds" swaggertype:"array,number" example:"1,2,3"`
+	InitialMessageFields
 }
 
 // CreatePrivateChatRequest 创建私聊请求
 type CreatePrivateChatRequest struct {
 	TargetUserID uint `json:"targetUserId" binding:"required" example:"2"`
+	InitialMessageFields
+}
+
+// InitialMessageFields 创建会话时可选的首条文本消息，与会话在同一请求内发送
+type InitialMessageFields struct {
+	InitialMessage string `json:"initialMessage" example:"你好，想请教一个问题"`
+	ClientMsgID    string `json:"clientMsgId" binding:"max=50" example:"uuid-123"` // 首条消息的客户端ID。私聊重试创建时复用同一会话，保持不变即可去重；建群每次请求都会新建群聊，不按该字段去重
 }
 
 // SendMessageRequest 发送消息请求
 type SendMessageRequest struct {
 	Type        string `json:"type" binding:"required" example:"text"`
 	Content     string `json:"content" binding:"required" example:"你好"`
-	ClientMsgID string `json:"clientMsgId" example:"uuid-123"`
+	ClientMsgID string `json:"clientMsgId" binding:"max=50" example:"uuid-123"` // 客户端生成的消息ID，重试时保持不变即可去重
 }
 
 // SendFriendRequestRequest 发送好友申请请求
@@ -48,12 +60,14 @@ type SendFriendRequestRequest struct {
 	Message    string `json:"message" example:"我是王小明"`
 }
 
-func NewChatController(chatService *service.ChatService, friendshipService *service.FriendshipService, hub *service.ChatHub, storageService *service.StorageService, cfg *config.Config) *ChatController {
+func NewChatController(chatService *service.ChatService, friendshipService *service.FriendshipService, hub *service.ChatHub, storageService *service.StorageService, uploadLimiter *service.UploadLimiter, unread *service.UnreadService, cfg *config.Config) *ChatController {
 	return &ChatController{
 		ChatService:       chatService,
 		FriendshipService: friendshipService,
 		Hub:               hub,
 		StorageService:    storageService,
+		UploadLimiter:     uploadLimiter,
+		Unread:            unread,
 		Config:            cfg,
 	}
 }
@@ -89,9 +103,65 @@ func (ctrl *ChatController) GetOverview(c *gin.Context) {
 	util.Success(c, overview)
 }
 
+// GetShardStats godoc
+// @Summary 获取IM分片负载
+// @Description 获取本节点 ChatHub 各分片的连接数与总连接数，用于发现热点分片、评估是否需要调整分片数
+// @Tags IM系统
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} util.Response{data=service.ShardStats} "成功"
+// @Failure 401 {object} util.Response "未授权"
+// @Failure 403 {object} util.Response "权限不足"
+// @Router /api/admin/chat/shards [get]
+func (ctrl *ChatController) GetShardStats(c *gin.Context) {
+	util.Success(c, ctrl.Hub.GetShardStats())
+}
+
+// UpdateRetentionRequest 会话消息保留策略
+type UpdateRetentionRequest struct {
+	RetentionDays int  `json:"retentionDays" example:"180"` // 0 表示使用全局配置
+	Exempt        bool `json:"exempt" example:"false"`      // true 表示不自动清理（重要群聊）
+}
+
+// UpdateConversationRetention godoc
+// @Summary 设置会话消息保留策略
+// @Description 管理员为单个会话设置消息保留天数或豁免自动清理
+// @Tags IM系统
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param   id path string true "会话ID"
+// @Param   request body UpdateRetentionRequest true "保留策略"
+// @Success 200 {object} util.Response{data=model.Conversation} "成功"
+// @Failure 400 {object} util.Response "参数错误"
+// @Failure 404 {object} util.Response "会话不存在"
+// @Router /api/admin/chat/conversations/{id}/retention [put]
+func (ctrl *ChatController) UpdateConversationRetention(c *gin.Context) {
+	var req UpdateRetentionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	conv, err := ctrl.ChatService.UpdateConversationRetention(c.Param("id"), req.RetentionDays, req.Exempt)
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrInvalidRequest):
+			util.BadRequest(c, err.Error())
+		case errors.Is(err, util.ErrConversationNotFound):
+			util.NotFound(c)
+		default:
+			util.Error(c, 500, err.Error())
+		}
+		return
+	}
+	util.Success(c, conv)
+}
+
 // HandleWS godoc
 // @Summary WebSocket 连接
-// @Description 建立 WebSocket 连接以接收实时消息
+// @Description 建立 WebSocket 连接以接收实时消息。token 过期前可发送 AUTH_REFRESH 消息携带新 token 续期，过期未续期时服务端以关闭码 4001 断开连接
 // @Tags IM系统
 // @Accept  json
 // @Produce  json
@@ -105,13 +175,14 @@ func (ctrl *ChatController) HandleWS(c *gin.Context) {
 		util.Unauthorized(c)
 		return
 	}
-	userID := claims.UserID
-	service.ServeWs(ctrl.Hub, c.Writer, c.Request, userID)
+	service.ServeWs(ctrl.Hub, c.Writer, c.Request, claims)
 }
 
 // CreateGroup godoc
 // @Summary 创建群聊
-// @Description 创建一个新的群聊会话
+// @Description 创建一个新的群聊会话。可选 initialMessage 作为首条文本消息一并发送并推送 NEW_MESSAGE，发送失败时群聊不会被创建，成功时在返回的 initialMessage 字段中带回该消息。
+// @Description 默认只能拉入自己的好友（教师、管理员不受限制），不满足条件的用户不会加入，在 skippedMembers 中返回用户ID和原因（not_friend、user_not_found）
+// @Description 每次请求都会新建群聊，clientMsgId 不能用于建群请求的重试去重，客户端不应自动重试该接口
 // @Tags IM系统
 // @Accept  json
 // @Produce  json
@@ -140,6 +211,17 @@ func (ctrl *ChatController) CreateGroup(c *gin.Context) {
 		return
 	}
 
+	var initialMsg *model.Message
+	var duplicated bool
+	if req.InitialMessage != "" {
+		// 首条消息发送失败时群聊会被删除，此时不再推送建群的系统消息
+		initialMsg, duplicated, err = ctrl.ChatService.SendInitialMessage(c.Request.Context(), conv.ID, userID, true, req.InitialMessage, req.ClientMsgID)
+		if err != nil {
+			util.Error(c, 500, err.Error())
+			return
+		}
+	}
+
 	// 推送系统消息
 	if sysMsg != nil {
 		var memberIDs []uint
@@ -151,13 +233,19 @@ func (ctrl *ChatController) CreateGroup(c *gin.Context) {
 			Data: sysMsg,
 		})
 	}
+	if initialMsg != nil {
+		conv.InitialMessage = initialMsg
+		if !duplicated {
+			ctrl.pushUserMessage(conv, initialMsg)
+		}
+	}
 
 	util.Success(c, conv)
 }
 
 // CreatePrivateChat godoc
 // @Summary 创建或获取私聊
-// @Description 创建一个新的私聊会话，如果已存在则返回现有会话
+// @Description 创建一个新的私聊会话，如果已存在则返回现有会话。可选 initialMessage 作为首条文本消息一并发送并推送 NEW_MESSAGE，新建会话时若消息发送失败会话会被撤销
 // @Tags IM系统
 // @Accept  json
 // @Produce  json
@@ -181,12 +269,24 @@ func (ctrl *ChatController) CreatePrivateChat(c *gin.Context) {
 		return
 	}
 
-	conv, err := ctrl.ChatService.GetOrCreatePrivateChat(userID, req.TargetUserID)
+	conv, created, err := ctrl.ChatService.GetOrCreatePrivateChat(userID, req.TargetUserID)
 	if err != nil {
 		util.Error(c, 500, err.Error())
 		return
 	}
 
+	if req.InitialMessage != "" {
+		msg, duplicated, err := ctrl.ChatService.SendInitialMessage(c.Request.Context(), conv.ID, userID, created, req.InitialMessage, req.ClientMsgID)
+		if err != nil {
+			util.Error(c, 500, err.Error())
+			return
+		}
+		if !duplicated {
+			ctrl.pushUserMessage(conv, msg)
+		}
+		conv.InitialMessage = msg
+	}
+
 	// 如果是私聊，填充对方的昵称和头像作为会话名
 	if conv.Type == "private" {
 		for _, m := range conv.Members {
@@ -207,17 +307,27 @@ func (ctrl *ChatController) CreatePrivateChat(c *gin.Context) {
 	util.Success(c, conv)
 }
 
+// ConversationPage 会话列表分页，HasMore 表示是否还有下一页
+type ConversationPage struct {
+	util.PageResponse
+	HasMore bool `json:"hasMore"`
+}
+
 // GetConversations godoc
 // @Summary 获取会话列表
-// @Description 获取当前用户的所有会话列表，支持分页和模糊搜索
+// @Description 获取当前用户的所有会话列表，支持分页、按类型筛选和搜索，默认按最后一条消息时间倒序
 // @Tags IM系统
 // @Accept  json
 // @Produce  json
 // @Security ApiKeyAuth
 // @Param   page query int false "页码 (从1开始)" default(1)
 // @Param   limit query int false "每页条数" default(20)
-// @Param   query query string false "搜索关键字 (群名或好友名)"
-// @Success 200 {object} util.Response{data=util.PageResponse{list=[]model.Conversation}} "成功"
+// @Param   query query string false "搜索关键字：群聊匹配群名，私聊匹配对方的用户名或邮箱"
+// @Param   match query string false "匹配方式：contains 包含匹配，prefix 前缀匹配（可走索引）" Enums(contains, prefix) default(contains)
+// @Param   type query string false "会话类型，saved 为我的收藏" Enums(private, group, saved)
+// @Param   sort query string false "排序方式：last_message 按最后消息时间，updated 按会话更新时间" Enums(last_message, updated) default(last_message)
+// @Success 200 {object} util.Response{data=ConversationPage{list=[]model.Conversation}} "成功"
+// @Failure 400 {object} util.Response "参数错误"
 // @Failure 500 {object} util.Response "服务器内部错误"
 // @Router /api/chat/conversations [get]
 func (ctrl *ChatController) GetConversations(c *gin.Context) {
@@ -229,15 +339,31 @@ func (ctrl *ChatController) GetConversations(c *gin.Context) {
 	userID := claims.UserID
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	query := c.Query("query")
+	filter := service.ConversationListFilter{
+		Query:       c.Query("query"),
+		PrefixMatch: c.Query("match") == "prefix",
+		Type:        c.Query("type"),
+		SortBy:      c.DefaultQuery("sort", service.ConversationSortLastMessage),
+	}
+	if filter.Type != "" && filter.Type != "private" && filter.Type != "group" && filter.Type != "saved" {
+		util.BadRequest(c, util.T(c, "type 只能是 private、group 或 saved"))
+		return
+	}
+	if filter.SortBy != service.ConversationSortLastMessage && filter.SortBy != service.ConversationSortUpdated {
+		util.BadRequest(c, util.T(c, "sort 只能是 last_message 或 updated"))
+		return
+	}
 
 	if page < 1 {
 		page = 1
 	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
 
 	offset := (page - 1) * limit
 
-	convs, total, err := ctrl.ChatService.ChatRepo.GetUserConversations(userID, query, limit, offset)
+	convs, total, err := ctrl.ChatService.ChatRepo.GetUserConversations(userID, filter, limit, offset)
 	if err != nil {
 		util.Error(c, 500, err.Error())
 		return
@@ -248,7 +374,21 @@ func (ctrl *ChatController) GetConversations(c *gin.Context) {
 		model.Conversation
 		IsOnline bool `json:"isOnline,omitempty"`
 	}
-	var list []convWithStatus
+	peerIDs := make([]uint, 0, len(convs))
+	for _, conv := range convs {
+		if conv.Type != "private" {
+			continue
+		}
+		for _, m := range conv.Members {
+			if m.UserID != userID {
+				peerIDs = append(peerIDs, m.UserID)
+				break
+			}
+		}
+	}
+	online := ctrl.Hub.VisibleOnlineStatus(userID, peerIDs)
+
+	list := make([]convWithStatus, 0, len(convs))
 	for _, conv := range convs {
 		// 填充扁平化的 MemberIDs
 		conv.MemberIDs = make([]uint, 0)
@@ -260,7 +400,7 @@ func (ctrl *ChatController) GetConversations(c *gin.Context) {
 		if conv.Type == "private" {
 			for _, m := range conv.Members {
 				if m.UserID != userID {
-					isOnline = ctrl.Hub.IsUserOnline(m.UserID)
+					isOnline = online[m.UserID]
 					// 私聊默认使用对方的昵称和头像
 					conv.Name = m.User.Name
 					conv.Avatar = m.User.Avatar
@@ -274,14 +414,43 @@ func (ctrl *ChatController) GetConversations(c *gin.Context) {
 		})
 	}
 
-	util.Success(c, util.PageResponse{
-		List:  list,
-		Total: total,
-		Page:  page,
-		Limit: limit,
+	util.Success(c, ConversationPage{
+		PageResponse: util.PageResponse{
+			List:  list,
+			Total: total,
+			Page:  page,
+			Limit: limit,
+		},
+		HasMore: int64(offset+len(convs)) < total,
 	})
 }
 
+// GetSavedConversation godoc
+// @Summary 获取我的收藏会话
+// @Description 返回当前用户的“我的收藏”会话，只有自己一个成员，可用于记笔记、保存链接；首次访问时自动创建。
+// @Description 发送消息和获取历史消息使用普通会话接口
+// @Tags IM系统
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} util.Response{data=model.Conversation} "成功"
+// @Failure 500 {object} util.Response "服务器内部错误"
+// @Router /api/chat/saved [get]
+func (ctrl *ChatController) GetSavedConversation(c *gin.Context) {
+	claims := util.GetUserFromContext(c)
+	if claims == nil {
+		util.Unauthorized(c)
+		return
+	}
+
+	conv, err := ctrl.ChatService.GetSavedConversation(claims.UserID)
+	if err != nil {
+		util.Error(c, 500, err.Error())
+		return
+	}
+	conv.MemberIDs = []uint{claims.UserID}
+	util.Success(c, conv)
+}
+
 // SendMessage godoc
 // @Summary 发送消息
 // @Description 向指定会话发送消息
@@ -309,30 +478,47 @@ func (ctrl *ChatController) SendMessage(c *gin.Context) {
 		return
 	}
 
-	msg, err := ctrl.ChatService.SendMessage(userID, convID, req.Type, req.Content, req.ClientMsgID)
+	msg, duplicated, err := ctrl.ChatService.SendMessage(c.Request.Context(), userID, convID, req.Type, req.Content, req.ClientMsgID)
 	if err != nil {
 		util.Error(c, 500, err.Error())
 		return
 	}
 
-	// 刚发送的消息默认可以撤回
-	msg.CanRevoke = true
-
-	// 补充在线状态发送给 WS
-	type msgWithStatus struct {
-		*model.Message
-		IsOnline  bool `json:"isOnline"`
-		IsRead    bool `json:"isRead"`
-		ReadCount int  `json:"readCount"`
+	// 重复提交（相同 ClientMsgID）直接返回首次创建的消息，不再重复推送
+	if duplicated {
+		msg.CanRevoke = true
+		util.Success(c, newMessageWithStatus(msg))
+		return
 	}
-	wsData := msgWithStatus{
+
+	conv, _ := ctrl.ChatService.ChatRepo.GetConversation(convID)
+	util.Success(c, ctrl.pushUserMessage(conv, msg))
+}
+
+// messageWithStatus 推送和返回给发送者的新消息，附带在线与已读状态
+type messageWithStatus struct {
+	*model.Message
+	IsOnline  bool `json:"isOnline"`
+	IsRead    bool `json:"isRead"`
+	ReadCount int  `json:"readCount"`
+}
+
+func newMessageWithStatus(msg *model.Message) messageWithStatus {
+	return messageWithStatus{
 		Message:   msg,
 		IsOnline:  true,
 		IsRead:    false,
 		ReadCount: 0,
 	}
+}
 
-	conv, _ := ctrl.ChatService.ChatRepo.GetConversation(convID)
+// pushUserMessage 向会话成员推送 NEW_MESSAGE，并向被 @ 的成员推送 MENTION
+func (ctrl *ChatController) pushUserMessage(conv *model.Conversation, msg *model.Message) messageWithStatus {
+	// 刚发送的消息默认可以撤回
+	msg.CanRevoke = true
+	wsData := newMessageWithStatus(msg)
+
+	// 接收者的未读数在消息落库后由 UnreadService 更新
 	var memberIDs []uint
 	for _, m := range conv.Members {
 		memberIDs = append(memberIDs, m.UserID)
@@ -342,7 +528,22 @@ func (ctrl *ChatController) SendMessage(c *gin.Context) {
 		Data: wsData,
 	})
 
-	util.Success(c, wsData)
+	// 被 @ 的成员单独推送 MENTION 事件，客户端即使对会话设置了免打扰也应提醒
+	if len(msg.Mentions) > 0 {
+		ctrl.Hub.PushToUsers(msg.Mentions, service.WSMessage{
+			Type: "MENTION",
+			Data: map[string]interface{}{
+				"conversationId":   conv.ID,
+				"conversationName": conv.Name,
+				"messageId":        msg.ID,
+				"senderId":         msg.SenderID,
+				"senderName":       msg.Sender.Name,
+				"content":          msg.Content,
+				"createdAt":        msg.CreatedAt,
+			},
+		})
+	}
+	return wsData
 }
 
 // GetHistory godoc
@@ -359,6 +560,7 @@ func (ctrl *ChatController) SendMessage(c *gin.Context) {
 // @Param   before_id query string false "在此消息 ID 之前的消息"
 // @Param   after_id query string false "在此消息 ID 之后的消息"
 // @Param   after_seq query int false "获取此 SeqID 之后的消息 (用于增量同步)"
+// @Param   collapseSystem query bool false "是否折叠连续的系统消息，默认取服务端配置；搜索和增量同步时不折叠"
 // @Success 200 {object} util.Response{data=object} "成功"
 // @Failure 500 {object} util.Response "服务器内部错误"
 // @Router /api/chat/conversations/{id}/messages [get]
@@ -383,7 +585,16 @@ func (ctrl *ChatController) GetHistory(c *gin.Context) {
 		return
 	}
 
-	// 获取会话成员以计算已读状态
+	// 折叠连续的系统消息；搜索结果和基于 SeqID 的增量同步需要完整消息，不折叠
+	collapse := ctrl.Config.Chat.CollapseSystemMessages
+	if v := c.Query("collapseSystem"); v != "" {
+		collapse = v == "true"
+	}
+	if collapse && query == "" && afterSeq == 0 {
+		msgs = service.CollapseSystemMessages(msgs)
+	}
+
+	// 获取会话成员以计算已读状态和撤回权限
 	conv, _ := ctrl.ChatService.ChatRepo.GetConversation(convID)
 
 	// 补充发送者的在线状态、消息的已读状态和已读人数
@@ -393,13 +604,21 @@ func (ctrl *ChatController) GetHistory(c *gin.Context) {
 		IsRead    bool `json:"isRead"`
 		ReadCount int  `json:"readCount"`
 	}
-	var list []msgWithStatus
+	list := make([]msgWithStatus, 0, len(msgs))
+
+	senderIDs := make([]uint, 0, len(msgs))
+	for _, m := range msgs {
+		if m.SenderID != nil {
+			senderIDs = append(senderIDs, *m.SenderID)
+		}
+	}
+	online := ctrl.Hub.VisibleOnlineStatus(userID, senderIDs)
 
 	// 提前准备好所有成员的已读时间，用于批量计算 ReadCount
 	memberReadTimes := make(map[uint]time.Time)
 	for _, m := range conv.Members {
 		if m.LastReadMsgTime != nil {
-			memberReadTimes[m.UserID] = *m.LastReadMsgTime
+			memberReadTimes[m.UserID] = m.LastReadMsgTime.Time
 		}
 	}
 
@@ -412,12 +631,15 @@ func (ctrl *ChatController) GetHistory(c *gin.Context) {
 			if m.SenderID != nil && uid == *m.SenderID {
 				continue
 			}
-			if !lastReadTime.Before(m.CreatedAt) { // lastReadTime >= m.CreatedAt
+			if !lastReadTime.Before(m.CreatedAt.Time) { // lastReadTime >= m.CreatedAt
 				readCount++
 			}
 		}
 
-		if conv.Type == "private" {
+		if conv.Type == "saved" {
+			// 我的收藏只有自己，消息始终视为已读
+			isRead = true
+		} else if conv.Type == "private" {
 			// 私聊逻辑：ReadCount > 0 即为对方已读
 			if m.SenderID != nil && *m.SenderID == userID {
 				isRead = readCount > 0
@@ -431,17 +653,16 @@ func (ctrl *ChatController) GetHistory(c *gin.Context) {
 			}
 		}
 
-		// 计算是否可撤回：必须是自己发的，且在 2 分钟内，且未被撤回
-		m.CanRevoke = m.SenderID != nil && *m.SenderID == userID && !m.IsRevoked && time.Since(m.CreatedAt) < 2*time.Minute
+		m.CanRevoke = ctrl.ChatService.CanRevoke(conv, &m, userID)
 
-		senderID := uint(0)
+		senderOnline := false
 		if m.SenderID != nil {
-			senderID = *m.SenderID
+			senderOnline = online[*m.SenderID]
 		}
 
 		list = append(list, msgWithStatus{
 			Message:   m,
-			IsOnline:  ctrl.Hub.IsUserOnline(senderID),
+			IsOnline:  senderOnline,
 			IsRead:    isRead,
 			ReadCount: readCount,
 		})
@@ -480,8 +701,11 @@ func (ctrl *ChatController) GetMessageContext(c *gin.Context) {
 		return
 	}
 
-	for i := range msgs {
-		msgs[i].CanRevoke = msgs[i].SenderID != nil && *msgs[i].SenderID == userID && !msgs[i].IsRevoked && time.Since(msgs[i].CreatedAt) < 2*time.Minute
+	if len(msgs) > 0 {
+		conv, _ := ctrl.ChatService.ChatRepo.GetConversation(msgs[0].ConversationID)
+		for i := range msgs {
+			msgs[i].CanRevoke = ctrl.ChatService.CanRevoke(conv, &msgs[i], userID)
+		}
 	}
 
 	util.Success(c, msgs)
@@ -489,7 +713,7 @@ func (ctrl *ChatController) GetMessageContext(c *gin.Context) {
 
 // RevokeMessage godoc
 // @Summary 撤回消息
-// @Description 撤回自己发送的消息（通常有时间限制）
+// @Description 撤回自己发送的消息，需在配置的撤回时限内（默认 2 分钟）；群主和协管员可随时撤回群内其他成员的消息，并会留下系统消息
 // @Tags IM系统
 // @Accept  json
 // @Produce  json
@@ -506,7 +730,7 @@ func (ctrl *ChatController) RevokeMessage(c *gin.Context) {
 	userID := claims.UserID
 	msgID := c.Param("id")
 
-	msg, err := ctrl.ChatService.RevokeMessage(userID, msgID)
+	msg, sysMsg, err := ctrl.ChatService.RevokeMessage(c.Request.Context(), userID, msgID)
 	if err != nil {
 		util.Error(c, 500, err.Error())
 		return
@@ -524,9 +748,16 @@ func (ctrl *ChatController) RevokeMessage(c *gin.Context) {
 		Data: map[string]interface{}{
 			"conversationId": msg.ConversationID,
 			"messageId":      msgID,
-			"senderId":       userID,
+			"senderId":       msg.SenderID,
+			"revokedBy":      userID,
 		},
 	})
+	if sysMsg != nil {
+		ctrl.Hub.PushToUsers(memberIDs, service.WSMessage{
+			Type: "NEW_MESSAGE",
+			Data: sysMsg,
+		})
+	}
 
 	util.Success(c, nil)
 }
@@ -632,9 +863,14 @@ type TransferAdminRequest struct {
 	NewAdminID uint `json:"newAdminId" binding:"required" example:"10"`
 }
 
+// SetMemberRoleRequest 设置群成员角色请求
+type SetMemberRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=moderator member" example:"moderator"`
+}
+
 // UpdateGroupInfo godoc
 // @Summary 修改群信息
-// @Description 仅管理员可修改群名称和头像
+// @Description 群主和协管员可修改群名称和头像
 // @Tags IM系统
 // @Accept  json
 // @Produce  json
@@ -692,7 +928,7 @@ func (ctrl *ChatController) UpdateGroupInfo(c *gin.Context) {
 
 // InviteMember godoc
 // @Summary 邀请成员入群
-// @Description 仅管理员可以邀请新成员
+// @Description 群主和协管员可以邀请新成员，默认只能邀请自己的好友（教师、管理员不受限制）
 // @Tags IM系统
 // @Accept  json
 // @Produce  json
@@ -740,7 +976,7 @@ func (ctrl *ChatController) InviteMember(c *gin.Context) {
 
 // KickMember godoc
 // @Summary 踢出群成员
-// @Description 仅管理员可以踢出成员
+// @Description 群主可以踢出任意成员，协管员只能踢出普通成员
 // @Tags IM系统
 // @Accept  json
 // @Produce  json
@@ -757,10 +993,12 @@ func (ctrl *ChatController) KickMember(c *gin.Context) {
 	}
 	userID := claims.UserID
 	convID := c.Param("id")
-	targetIDStr := c.Param("userId")
-	targetID, _ := strconv.ParseUint(targetIDStr, 10, 32)
+	targetID, ok := util.ParseUintParam(c, "userId")
+	if !ok {
+		return
+	}
 
-	sysMsg, err := ctrl.ChatService.KickMember(userID, convID, uint(targetID))
+	sysMsg, err := ctrl.ChatService.KickMember(userID, convID, targetID)
 	if err != nil {
 		util.Error(c, 500, err.Error())
 		return
@@ -837,6 +1075,70 @@ func (ctrl *ChatController) TransferAdmin(c *gin.Context) {
 	util.Success(c, nil)
 }
 
+// SetMemberRole godoc
+// @Summary 设置群成员角色
+// @Description 仅群主可以将成员设为协管员（moderator）或取消协管员（member）；协管员可邀请、移除普通成员和修改群信息
+// @Tags IM系统
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param   id path string true "会话ID"
+// @Param   userId path uint true "目标用户ID"
+// @Param   request body SetMemberRoleRequest true "角色"
+// @Success 200 {object} util.Response "成功"
+// @Router /api/chat/conversations/{id}/members/{userId}/role [put]
+func (ctrl *ChatController) SetMemberRole(c *gin.Context) {
+	claims := util.GetUserFromContext(c)
+	if claims == nil {
+		util.Unauthorized(c)
+		return
+	}
+	userID := claims.UserID
+	convID := c.Param("id")
+	targetID, ok := util.ParseUintParam(c, "userId")
+	if !ok {
+		return
+	}
+
+	var req SetMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	sysMsg, err := ctrl.ChatService.SetMemberRole(userID, convID, targetID, req.Role)
+	if err != nil {
+		util.Error(c, 500, err.Error())
+		return
+	}
+
+	// 角色未变化时无需推送
+	if sysMsg != nil {
+		conv, _ := ctrl.ChatService.ChatRepo.GetConversation(convID)
+		var memberIDs []uint
+		for _, m := range conv.Members {
+			memberIDs = append(memberIDs, m.UserID)
+		}
+
+		ctrl.Hub.PushToUsers(memberIDs, service.WSMessage{
+			Type: "NEW_MESSAGE",
+			Data: sysMsg,
+		})
+
+		ctrl.Hub.PushToUsers(memberIDs, service.WSMessage{
+			Type: "MEMBER_ROLE_CHANGED",
+			Data: map[string]interface{}{
+				"conversationId": convID,
+				"userId":         targetID,
+				"role":           req.Role,
+				"operatorId":     userID,
+			},
+		})
+	}
+
+	util.Success(c, gin.H{"role": req.Role})
+}
+
 // MarkAsRead godoc
 // @Summary 标记消息为已读
 // @Description 标记指定会话的消息为已读，并通知会话其他成员
@@ -867,6 +1169,7 @@ func (ctrl *ChatController) MarkAsRead(c *gin.Context) {
 		util.Error(c, 500, err.Error())
 		return
 	}
+	ctrl.Unread.MarkChanged(userID)
 
 	// 推送已读事件给会话其他成员
 	conv, _ := ctrl.ChatService.ChatRepo.GetConversation(convID)
@@ -889,6 +1192,214 @@ func (ctrl *ChatController) MarkAsRead(c *gin.Context) {
 	util.Success(c, nil)
 }
 
+// PresenceRequest 批量查询在线状态请求
+type PresenceRequest struct {
+	UserIDs []uint `json:"userIds" binding:"required" example:"1,2,3"`
+}
+
+// GetPresence godoc
+// @Summary 批量查询在线状态
+// @Description 返回用户的 online/away/offline 状态，单次最多 200 个用户；隐藏了在线状态的用户始终显示为 offline
+// @Tags IM系统
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param   request body PresenceRequest true "用户ID列表"
+// @Success 200 {object} util.Response{data=[]service.UserPresence} "成功"
+// @Failure 400 {object} util.Response "用户数超出上限"
+// @Router /api/chat/presence [post]
+func (ctrl *ChatController) GetPresence(c *gin.Context) {
+	claims := util.GetUserFromContext(c)
+	if claims == nil {
+		util.Unauthorized(c)
+		return
+	}
+
+	var req PresenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(c, err.Error())
+		return
+	}
+
+	userIDs := make([]uint, 0, len(req.UserIDs))
+	seen := make(map[uint]bool, len(req.UserIDs))
+	for _, id := range req.UserIDs {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			userIDs = append(userIDs, id)
+		}
+	}
+	if len(userIDs) > service.MaxPresenceQuery {
+		util.BadRequest(c, fmt.Sprintf("单次最多查询 %d 个用户", service.MaxPresenceQuery))
+		return
+	}
+	if len(userIDs) == 0 {
+		util.Success(c, []service.UserPresence{})
+		return
+	}
+
+	presence, err := ctrl.ChatService.GetPresence(claims.UserID, userIDs, ctrl.Hub.OnlineStatus(userIDs))
+	if err != nil {
+		util.Error(c, 500, err.Error())
+		return
+	}
+	util.Success(c, presence)
+}
+
+// GetConversationStats godoc
+// @Summary 会话消息统计
+// @Description 按天、按类型统计会话消息数，并返回发言最多的成员和活跃成员数；仅会话成员和管理员可查看，默认统计最近 30 天
+// @Tags IM系统
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param   id path string true "会话ID"
+// @Param   start query string false "开始时间 RFC3339"
+// @Param   end query string false "结束时间 RFC3339"
+// @Success 200 {object} util.Response "成功，返回 totalMessages/activeMembers/daily/byType/topSenders"
+// @Failure 400 {object} util.Response "时间参数错误"
+// @Failure 403 {object} util.Response "无权查看"
+// @Failure 404 {object} util.Response "会话不存在"
+// @Router /api/chat/conversations/{id}/stats [get]
+func (ctrl *ChatController) GetConversationStats(c *gin.Context) {
+	claims := util.GetUserFromContext(c)
+	if claims == nil {
+		util.Unauthorized(c)
+		return
+	}
+
+	var startPtr, endPtr *time.Time
+	if s := c.Query("start"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			util.BadRequest(c, util.T(c, "开始时间格式错误"))
+			return
+		}
+		startPtr = &t
+	}
+	if e := c.Query("end"); e != "" {
+		t, err := time.Parse(time.RFC3339, e)
+		if err != nil {
+			util.BadRequest(c, util.T(c, "结束时间格式错误"))
+			return
+		}
+		endPtr = &t
+	}
+
+	stats, err := ctrl.ChatService.GetConversationStats(claims.UserID, claims.Role, c.Param("id"), startPtr, endPtr)
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrConversationNotFound):
+			util.NotFound(c)
+		case errors.Is(err, util.ErrPermissionDenied):
+			util.Forbidden(c)
+		case errors.Is(err, util.ErrInvalidRequest):
+			util.BadRequest(c, err.Error())
+		default:
+			util.Error(c, 500, err.Error())
+		}
+		return
+	}
+	util.Success(c, stats)
+}
+
+// GetMentions godoc
+// @Summary 获取未读的 @ 提及
+// @Description 跨会话列出当前用户未读的 @ 提及，按时间倒序；标记会话已读时对应提及一并标记已读
+// @Tags IM系统
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param   limit query int false "每页数量" default(20)
+// @Param   offset query int false "偏移量" default(0)
+// @Success 200 {object} util.Response{data=object} "成功，返回 list/total"
+// @Router /api/chat/mentions [get]
+func (ctrl *ChatController) GetMentions(c *gin.Context) {
+	claims := util.GetUserFromContext(c)
+	if claims == nil {
+		util.Unauthorized(c)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	mentions, total, err := ctrl.ChatService.GetUnreadMentions(claims.UserID, limit, offset)
+	if err != nil {
+		util.Error(c, 500, err.Error())
+		return
+	}
+	util.Success(c, gin.H{"list": mentions, "total": total})
+}
+
+// MarkAllAsRead godoc
+// @Summary 全部标记为已读
+// @Description 将当前用户所有会话的已读位置更新到最新消息，清除全部未读数；向本人其他设备推送 ALL_READ，向会话其他成员推送 MESSAGE_READ
+// @Tags IM系统
+// @Accept  json
+// @Produce  json
+// @Security ApiKeyAuth
+// @Success 200 {object} util.Response "成功，data.conversations 为被更新的会话"
+// @Failure 500 {object} util.Response "服务器内部错误"
+// @Router /api/chat/read-all [put]
+func (ctrl *ChatController) MarkAllAsRead(c *gin.Context) {
+	claims := util.GetUserFromContext(c)
+	if claims == nil {
+		util.Unauthorized(c)
+		return
+	}
+	userID := claims.UserID
+
+	marks, err := ctrl.ChatService.MarkAllAsRead(userID)
+	if err != nil {
+		util.Error(c, 500, err.Error())
+		return
+	}
+	ctrl.Unread.MarkChanged(userID)
+
+	if len(marks) > 0 {
+		// 同步本人其他设备的未读状态
+		ctrl.Hub.PushToUsers([]uint{userID}, service.WSMessage{
+			Type: "ALL_READ",
+			Data: map[string]interface{}{
+				"userId":        userID,
+				"conversations": marks,
+			},
+		})
+
+		// 已读回执推送给各会话其他成员
+		for _, m := range marks {
+			memberIDs, err := ctrl.ChatService.ChatRepo.GetGroupMemberIDsCached(m.ConversationID)
+			if err != nil {
+				continue
+			}
+			var targetIDs []uint
+			for _, id := range memberIDs {
+				if id != userID {
+					targetIDs = append(targetIDs, id)
+				}
+			}
+			if len(targetIDs) == 0 {
+				continue
+			}
+			ctrl.Hub.PushToUsers(targetIDs, service.WSMessage{
+				Type: "MESSAGE_READ",
+				Data: map[string]interface{}{
+					"conversationId": m.ConversationID,
+					"userId":         userID,
+					"messageId":      m.MessageID,
+				},
+			})
+		}
+	}
+
+	util.Success(c, gin.H{"conversations": marks})
+}
+
 // HideConversation godoc
 // @Summary 隐藏会话
 // @Description 从会话列表中隐藏指定会话（不退出群/不删除私聊），收到新消息时自动恢复显示
@@ -959,11 +1470,17 @@ func (ctrl *ChatController) GetMembers(c *gin.Context) {
 		model.ConversationMember
 		IsOnline bool `json:"isOnline"`
 	}
-	var list []memberWithStatus
+	memberIDs := make([]uint, 0, len(members))
+	for _, m := range members {
+		memberIDs = append(memberIDs, m.UserID)
+	}
+	online := ctrl.Hub.VisibleOnlineStatus(userID, memberIDs)
+
+	list := make([]memberWithStatus, 0, len(members))
 	for _, m := range members {
 		list = append(list, memberWithStatus{
 			ConversationMember: m,
-			IsOnline:           ctrl.Hub.IsUserOnline(m.UserID),
+			IsOnline:           online[m.UserID],
 		})
 	}
 
@@ -1009,7 +1526,7 @@ func (ctrl *ChatController) SearchUser(c *gin.Context) {
 func (ctrl *ChatController) SearchUsers(c *gin.Context) {
 	query := c.Query("query")
 	if query == "" {
-		util.BadRequest(c, "搜索关键字不能为空")
+		util.BadRequest(c, util.T(c, "搜索关键字不能为空"))
 		return
 	}
 
@@ -1023,14 +1540,15 @@ func (ctrl *ChatController) SearchUsers(c *gin.Context) {
 
 // SendFriendRequest godoc
 // @Summary 发送好友申请
-// @Description 向指定用户发送好友申请
+// @Description 向指定用户发送好友申请。好友数量、待处理的已发申请数和发送频率受配置限制
 // @Tags IM系统
 // @Accept  json
 // @Produce  json
 // @Security ApiKeyAuth
 // @Param   request body SendFriendRequestRequest true "发送好友申请请求"
 // @Success 200 {object} util.Response "成功"
-// @Failure 400 {object} util.Response "参数错误"
+// @Failure 400 {object} util.Response "参数错误或好友、待处理申请数已达上限"
+// @Failure 429 {object} util.Response "发送过于频繁"
 // @Failure 500 {object} util.Response "服务器内部错误"
 // @Router /api/chat/friend-requests [post]
 func (ctrl *ChatController) SendFriendRequest(c *gin.Context) {
@@ -1046,23 +1564,25 @@ func (ctrl *ChatController) SendFriendRequest(c *gin.Context) {
 		return
 	}
 
-	err := ctrl.FriendshipService.SendFriendRequest(userID, req.ReceiverID, req.Message)
+	err := ctrl.FriendshipService.SendFriendRequest(c.Request.Context(), userID, req.ReceiverID, req.Message)
 	if err != nil {
-		util.Error(c, 500, err.Error())
+		util.Error(c, friendLimitStatus(err), util.T(c, err.Error()))
 		return
 	}
-	util.Success(c, gin.H{"message": "申请已发送"})
+	util.Success(c, gin.H{"message": util.T(c, "申请已发送")})
 }
 
 // GetFriends godoc
 // @Summary 获取好友列表
-// @Description 获取当前用户的好友列表，支持根据昵称或邮箱模糊搜索
+// @Description 获取当前用户的好友列表，支持分页和根据昵称或邮箱模糊搜索
 // @Tags IM系统
 // @Accept  json
 // @Produce  json
 // @Security ApiKeyAuth
+// @Param   page query int false "页码 (从1开始)" default(1)
+// @Param   limit query int false "每页条数 (最大100)" default(20)
 // @Param   query query string false "搜索关键字 (昵称或邮箱)"
-// @Success 200 {object} util.Response{data=[]model.User} "成功"
+// @Success 200 {object} util.Response{data=util.PageResponse{list=[]model.User}} "成功"
 // @Failure 500 {object} util.Response "服务器内部错误"
 // @Router /api/chat/friends [get]
 func (ctrl *ChatController) GetFriends(c *gin.Context) {
@@ -1072,8 +1592,20 @@ func (ctrl *ChatController) GetFriends(c *gin.Context) {
 		return
 	}
 	userID := claims.UserID
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	query := c.Query("query")
-	friends, err := ctrl.FriendshipService.GetFriends(userID, query)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	offset := (page - 1) * limit
+
+	friends, total, err := ctrl.FriendshipService.GetFriends(userID, query, limit, offset)
 	if err != nil {
 		util.Error(c, 500, err.Error())
 		return
@@ -1084,15 +1616,26 @@ func (ctrl *ChatController) GetFriends(c *gin.Context) {
 		model.User
 		IsOnline bool `json:"isOnline"`
 	}
-	var result []friendWithStatus
+	friendIDs := make([]uint, 0, len(friends))
+	for _, f := range friends {
+		friendIDs = append(friendIDs, f.ID)
+	}
+	online := ctrl.Hub.VisibleOnlineStatus(userID, friendIDs)
+
+	result := make([]friendWithStatus, 0, len(friends))
 	for _, f := range friends {
 		result = append(result, friendWithStatus{
 			User:     f,
-			IsOnline: ctrl.Hub.IsUserOnline(f.ID),
+			IsOnline: online[f.ID],
 		})
 	}
 
-	util.Success(c, result)
+	util.Success(c, util.PageResponse{
+		List:  result,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
 }
 
 // GlobalSearch godoc
@@ -1169,19 +1712,17 @@ func (ctrl *ChatController) DeleteFriend(c *gin.Context) {
 		return
 	}
 	userID := claims.UserID
-	friendIDStr := c.Param("id")
-	friendID, err := strconv.ParseUint(friendIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(c, "无效的好友ID")
+	friendID, ok := util.ParseUintParam(c, "id")
+	if !ok {
 		return
 	}
 
-	err = ctrl.FriendshipService.DeleteFriend(userID, uint(friendID))
+	err := ctrl.FriendshipService.DeleteFriend(userID, friendID)
 	if err != nil {
 		util.Error(c, 500, err.Error())
 		return
 	}
-	util.Success(c, gin.H{"message": "好友已删除"})
+	util.Success(c, gin.H{"message": util.T(c, "好友已删除")})
 }
 
 // GetFriendRequests godoc
@@ -1234,7 +1775,7 @@ type HandleFriendRequestRequest struct {
 
 // HandleFriendRequest godoc
 // @Summary 处理好友申请
-// @Description 同意或拒绝好友申请
+// @Description 同意或拒绝好友申请，任意一方好友数已达上限时无法同意
 // @Tags IM系统
 // @Accept  json
 // @Produce  json
@@ -1242,6 +1783,7 @@ type HandleFriendRequestRequest struct {
 // @Param   id path string true "申请ID"
 // @Param   request body HandleFriendRequestRequest true "处理动作"
 // @Success 200 {object} util.Response "成功"
+// @Failure 400 {object} util.Response "好友数量已达上限"
 // @Router /api/chat/friend-requests/{id} [put]
 func (ctrl *ChatController) HandleFriendRequest(c *gin.Context) {
 	claims := util.GetUserFromContext(c)
@@ -1260,7 +1802,7 @@ func (ctrl *ChatController) HandleFriendRequest(c *gin.Context) {
 	accept := req.Action == "accept"
 	err := ctrl.FriendshipService.HandleFriendRequest(requestID, userID, accept)
 	if err != nil {
-		util.Error(c, 500, err.Error())
+		util.Error(c, friendLimitStatus(err), util.T(c, err.Error()))
 		return
 	}
 
@@ -1271,32 +1813,53 @@ func (ctrl *ChatController) HandleFriendRequest(c *gin.Context) {
 	util.Success(c, gin.H{"message": msg})
 }
 
+// friendLimitStatus 好友上限类错误返回 400，发送过于频繁返回 429，其余按 500 处理
+func friendLimitStatus(err error) int {
+	switch {
+	case errors.Is(err, util.ErrFriendRequestRateLimit):
+		return 429
+	case errors.Is(err, util.ErrFriendLimitReached),
+		errors.Is(err, util.ErrPeerFriendLimitReached),
+		errors.Is(err, util.ErrTooManyPendingRequests):
+		return 400
+	}
+	return 500
+}
+
 // UploadFile godoc
 // @Summary 上传聊天文件
-// @Description 上传图片或文件用于聊天，返回文件URL
+// @Description 上传图片或文件用于聊天，返回文件URL、原始文件名、大小及根据内容检测的 MIME 类型
 // @Tags IM系统
 // @Accept  multipart/form-data
 // @Produce  json
 // @Security ApiKeyAuth
 // @Param   file formData file true "文件"
-// @Success 200 {object} util.Response{data=map[string]string} "成功，返回文件URL"
+// @Success 200 {object} util.Response{data=map[string]interface{}} "成功，返回文件URL及附件元数据"
+// @Failure 429 {object} util.Response "同时进行的上传过多，参考 Retry-After 重试"
 // @Router /api/chat/upload [post]
 func (ctrl *ChatController) UploadFile(c *gin.Context) {
+	user := util.GetUserFromContext(c)
+	if user == nil {
+		util.Unauthorized(c)
+		return
+	}
+	// 在解析表单（写入临时文件）之前登记，超出并发上限的请求不占用磁盘
+	uploadID := "chat:" + util.GenerateRandomString(16)
+	if !acquireUploadSlot(c, ctrl.UploadLimiter, ctrl.Config.Upload.RetryAfterSeconds, user.UserID, uploadID) {
+		return
+	}
+	defer ctrl.UploadLimiter.Release(context.Background(), user.UserID, uploadID)
+	if !util.LimitUploadBody(c, util.MB(ctrl.Config.Upload.ChatMaxMB)) {
+		return
+	}
+
 	file, err := c.FormFile("file")
 	if err != nil {
-		util.BadRequest(c, "文件不能为空")
+		util.BadRequest(c, util.T(c, "文件不能为空"))
 		return
 	}
 
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	// 支持的扩展名
-	allowedExts := map[string]bool{
-		".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
-		".pdf": true, ".docx": true, ".txt": true, ".zip": true,
-		".mp4": true, ".mp3": true,
-	}
-	if !allowedExts[ext] {
-		util.BadRequest(c, "不支持的文件类型")
+	if !util.CheckUpload(c, file, util.AllowedChatExtensions, util.MB(ctrl.Config.Upload.ChatMaxMB)) {
 		return
 	}
 
@@ -1304,15 +1867,16 @@ func (ctrl *ChatController) UploadFile(c *gin.Context) {
 
 	src, err := file.Open()
 	if err != nil {
-		util.Error(c, 500, "打开文件失败: "+err.Error())
+		util.Error(c, 500, util.T(c, "打开文件失败")+": "+err.Error())
 		return
 	}
 	defer src.Close()
 
 	// 深度验证 MIME 类型
 	allowedTypes := []string{"image/", "video/", "audio/", "application/pdf", "text/plain", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/zip"}
-	if _, err := util.ValidateMimeType(src, allowedTypes); err != nil {
-		util.BadRequest(c, "非法的文件内容: "+err.Error())
+	mimeType, err := util.ValidateMimeType(src, allowedTypes)
+	if err != nil {
+		util.BadRequest(c, util.T(c, "非法的文件内容")+": "+err.Error())
 		return
 	}
 	// 重置读取指针
@@ -1320,11 +1884,25 @@ func (ctrl *ChatController) UploadFile(c *gin.Context) {
 		seeker.Seek(0, io.SeekStart)
 	}
 
-	fileURL, err := ctrl.StorageService.Upload(c, newFilename, src, file.Size, file.Header.Get("Content-Type"))
+	// 使用根据内容检测出的类型，而不是客户端声明的 Content-Type
+	fileURL, err := ctrl.StorageService.Upload(c, newFilename, src, file.Size, mimeType)
 	if err != nil {
-		util.Error(c, 500, "上传文件失败: "+err.Error())
+		util.Error(c, 500, util.T(c, "上传文件失败")+": "+err.Error())
 		return
 	}
 
-	util.Success(c, gin.H{"url": fileURL})
+	attachment := model.MessageAttachment{
+		FileName: filepath.Base(file.Filename),
+		FileSize: file.Size,
+		MimeType: mimeType,
+	}
+	// 发送文件消息时按 URL 取回这些元数据，客户端无法伪造
+	ctrl.ChatService.RecordUpload(c.Request.Context(), user.UserID, fileURL, attachment)
+
+	util.Success(c, gin.H{
+		"url":      fileURL,
+		"fileName": attachment.FileName,
+		"fileSize": attachment.FileSize,
+		"mimeType": attachment.MimeType,
+	})
 }
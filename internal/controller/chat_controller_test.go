@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"coder_edu_backend/internal/config"
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/service"
+	"coder_edu_backend/internal/testutil"
+	"coder_edu_backend/internal/util"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestChatListsReturnEmptyArrays 需要 testutil.MySQL 的测试库，未设置 TEST_MYSQL_DSN 时跳过
+func TestChatListsReturnEmptyArrays(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.MySQL(t)
+
+	const userID = 987654331
+	conv := model.Conversation{UUIDBase: model.UUIDBase{ID: model.GenerateUUID()}, Type: "group", Name: "empty-list-test"}
+	if err := db.Create(&conv).Error; err != nil {
+		t.Fatalf("create conversation: %v", err)
+	}
+	if err := db.Create(&model.ConversationMember{ConversationID: conv.ID, UserID: userID}).Error; err != nil {
+		t.Fatalf("create member: %v", err)
+	}
+	defer func() {
+		db.Unscoped().Where("conversation_id = ?", conv.ID).Delete(&model.ConversationMember{})
+		db.Unscoped().Delete(&conv)
+	}()
+
+	ctrl := &ChatController{
+		ChatService: &service.ChatService{ChatRepo: repository.NewChatRepository(db, nil)},
+		Hub:         &service.ChatHub{},
+		Config:      &config.Config{},
+	}
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user", &util.Claims{UserID: userID, Role: model.Student})
+	})
+	r.GET("/conversations", ctrl.GetConversations)
+	r.GET("/conversations/:id/messages", ctrl.GetHistory)
+
+	cases := []struct {
+		name string
+		path string
+	}{
+		{"conversations without match", "/conversations?query=no-such-conversation"},
+		{"history of empty conversation", "/conversations/" + conv.ID + "/messages"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Data struct {
+					List json.RawMessage `json:"list"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if string(resp.Data.List) != "[]" {
+				t.Fatalf("list = %s, want []", resp.Data.List)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -185,21 +186,21 @@ func (c *CommunityController) CreatePost(ctx *gin.Context) {
 
 	var req service.PostRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		util.BadRequest(ctx, "请求格式错误")
+		util.BadRequest(ctx, util.T(ctx, "请求格式错误"))
 		return
 	}
 
 	// 安全性校验
 	if req.Title == "" || req.Content == "" {
-		util.BadRequest(ctx, "标题和内容不能为空")
+		util.BadRequest(ctx, util.T(ctx, "标题和内容不能为空"))
 		return
 	}
 	if len(req.Title) > 100 {
-		util.BadRequest(ctx, "标题字数过多（最多100个字符）")
+		util.BadRequest(ctx, util.T(ctx, "标题字数过多（最多100个字符）"))
 		return
 	}
 	if len(req.Content) > 5000 {
-		util.BadRequest(ctx, "讨论内容过长")
+		util.BadRequest(ctx, util.T(ctx, "讨论内容过长"))
 		return
 	}
 
@@ -236,12 +237,12 @@ func (c *CommunityController) UpdatePost(ctx *gin.Context) {
 	postID := ctx.Param("id")
 	var req service.PostRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		util.BadRequest(ctx, "请求格式错误")
+		util.BadRequest(ctx, util.T(ctx, "请求格式错误"))
 		return
 	}
 
 	if req.Title == "" || req.Content == "" {
-		util.BadRequest(ctx, "标题和内容不能为空")
+		util.BadRequest(ctx, util.T(ctx, "标题和内容不能为空"))
 		return
 	}
 
@@ -287,7 +288,7 @@ func (c *CommunityController) DeletePost(ctx *gin.Context) {
 		return
 	}
 
-	util.Success(ctx, gin.H{"message": "Post deleted successfully"})
+	util.Success(ctx, gin.H{"message": util.T(ctx, "Post deleted successfully")})
 }
 
 // @Summary 发表评论/回复
@@ -310,12 +311,12 @@ func (c *CommunityController) CreateComment(ctx *gin.Context) {
 	postID := ctx.Param("id")
 	var req service.CommentCreateRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		util.BadRequest(ctx, "请求格式错误")
+		util.BadRequest(ctx, util.T(ctx, "请求格式错误"))
 		return
 	}
 
 	if req.Content == "" {
-		util.BadRequest(ctx, "内容不能为空")
+		util.BadRequest(ctx, util.T(ctx, "内容不能为空"))
 		return
 	}
 
@@ -359,7 +360,7 @@ func (c *CommunityController) DeleteComment(ctx *gin.Context) {
 		return
 	}
 
-	util.Success(ctx, gin.H{"message": "Comment deleted successfully"})
+	util.Success(ctx, gin.H{"message": util.T(ctx, "Comment deleted successfully")})
 }
 
 // @Summary 获取问题列表
@@ -450,7 +451,7 @@ func (c *CommunityController) AnswerQuestion(ctx *gin.Context) {
 
 	questionID := ctx.Param("questionId")
 	if questionID == "" {
-		util.BadRequest(ctx, "Invalid question ID")
+		util.BadRequest(ctx, util.T(ctx, "Invalid question ID"))
 		return
 	}
 
@@ -489,7 +490,7 @@ func (c *CommunityController) Upvote(ctx *gin.Context) {
 	contentType := ctx.Param("type")
 	contentID := ctx.Param("id")
 	if contentID == "" {
-		util.BadRequest(ctx, "Invalid content ID")
+		util.BadRequest(ctx, util.T(ctx, "Invalid content ID"))
 		return
 	}
 
@@ -593,14 +594,14 @@ func (c *CommunityController) CreateResource(ctx *gin.Context) {
 
 	var req service.ResourceShareRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		util.BadRequest(ctx, "请求格式错误")
+		util.BadRequest(ctx, util.T(ctx, "请求格式错误"))
 		return
 	}
 
 	res, err := c.CommunityService.CreateResource(user.UserID, user.Role, req)
 	if err != nil {
 		if errors.Is(err, util.ErrDailyShareLimit) {
-			util.BadRequest(ctx, "每天最多只能分享3次资源")
+			util.BadRequest(ctx, util.T(ctx, "每天最多只能分享3次资源"))
 		} else {
 			util.LogInternalError(ctx, err)
 		}
@@ -625,10 +626,16 @@ func (c *CommunityController) UploadResourceFile(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
+	if !util.LimitUploadBody(ctx, util.MB(c.CommunityService.Cfg.Upload.ResourceMaxMB)) {
+		return
+	}
 
 	file, err := ctx.FormFile("file")
 	if err != nil {
-		util.BadRequest(ctx, "未找到上传的文件")
+		util.BadRequest(ctx, util.T(ctx, "未找到上传的文件"))
+		return
+	}
+	if !util.CheckUpload(ctx, file, resourceExtensions(), util.MB(c.CommunityService.Cfg.Upload.ResourceMaxMB)) {
 		return
 	}
 
@@ -681,8 +688,12 @@ func (c *CommunityController) DownloadResource(ctx *gin.Context) {
 		}
 	}
 
-	// 其他情况（如 MinIO），如果配置了静态服务则可以访问，或者这里可以实现MinIO下载逻辑
-	// 暂时重定向
+	// 其他情况（如 MinIO）签发短期下载地址，由客户端直连对象存储下载
+	if key, ok := c.CommunityService.StorageService.ObjectKey(fileURL); ok {
+		if signed, err := c.CommunityService.StorageService.PresignedURL(ctx, key, 15*time.Minute); err == nil {
+			fileURL = signed
+		}
+	}
 	ctx.Redirect(http.StatusFound, fileURL)
 }
 
@@ -711,5 +722,5 @@ func (c *CommunityController) DeleteResource(ctx *gin.Context) {
 		return
 	}
 
-	util.Success(ctx, gin.H{"message": "Resource deleted successfully"})
+	util.Success(ctx, gin.H{"message": util.T(ctx, "Resource deleted successfully")})
 }
@@ -1,6 +1,14 @@
 package controller
 
 import (
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
@@ -44,6 +52,9 @@ type UploadResourceRequest struct {
 // @Failure 500 {object} util.Response "服务器内部错误"
 // @Router /api/admin/resources [post]
 func (c *ContentController) UploadResource(ctx *gin.Context) {
+	if !util.LimitUploadBody(ctx, util.MB(c.ContentService.Cfg.Upload.ResourceMaxMB)) {
+		return
+	}
 	var req UploadResourceRequest
 	if err := ctx.ShouldBind(&req); err != nil {
 		util.BadRequest(ctx, err.Error())
@@ -55,6 +66,9 @@ func (c *ContentController) UploadResource(ctx *gin.Context) {
 		util.BadRequest(ctx, "File is required")
 		return
 	}
+	if !util.CheckUpload(ctx, file, resourceExtensions(), util.MB(c.ContentService.Cfg.Upload.ResourceMaxMB)) {
+		return
+	}
 
 	resource := &model.Resource{
 		Title:       req.Title,
@@ -99,6 +113,54 @@ func (c *ContentController) GetResources(ctx *gin.Context) {
 	util.Success(ctx, resources)
 }
 
+// GetResourceAccessURL godoc
+// @Summary 获取资源限时访问地址
+// @Description 返回资源文件的短期签名URL，客户端直接从对象存储下载，本地存储时返回本地路径
+// @Tags 内容
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param   id path int true "资源ID"
+// @Param   expires query int false "有效期（秒），默认900，最长3600"
+// @Success 200 {object} util.Response{data=map[string]interface{}} "成功"
+// @Failure 403 {object} util.Response "无权访问"
+// @Failure 404 {object} util.Response "资源不存在"
+// @Router /api/resources/{id}/url [get]
+func (c *ContentController) GetResourceAccessURL(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	expires, _ := strconv.Atoi(ctx.DefaultQuery("expires", "900"))
+	if expires <= 0 || expires > 3600 {
+		expires = 900
+	}
+	expiry := time.Duration(expires) * time.Second
+
+	url, err := c.ContentService.GetResourceAccessURL(ctx, id, user.Role, expiry)
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrResourceNotFound):
+			util.NotFound(ctx)
+		case errors.Is(err, util.ErrPermissionDenied):
+			util.Forbidden(ctx)
+		default:
+			util.LogInternalError(ctx, err)
+		}
+		return
+	}
+
+	util.Success(ctx, gin.H{
+		"url":       url,
+		"expiresAt": model.NewJSONTime(time.Now().Add(expiry)),
+	})
+}
+
 // UploadIcon godoc
 // @Summary 上传模块图标（仅管理员）
 // @Description 专门用于上传C语言编程模块的图标
@@ -114,11 +176,17 @@ func (c *ContentController) GetResources(ctx *gin.Context) {
 // @Failure 500 {object} util.Response "服务器内部错误"
 // @Router /api/admin/upload/icon [post]
 func (c *ContentController) UploadIcon(ctx *gin.Context) {
+	if !util.LimitUploadBody(ctx, util.MB(c.ContentService.Cfg.Upload.ImageMaxMB)) {
+		return
+	}
 	file, err := ctx.FormFile("icon")
 	if err != nil {
 		util.BadRequest(ctx, "图标文件是必需的")
 		return
 	}
+	if !util.CheckUpload(ctx, file, util.AllowedIconExtensions, util.MB(c.ContentService.Cfg.Upload.ImageMaxMB)) {
+		return
+	}
 
 	url, err := c.ContentService.UploadIcon(ctx, file)
 	if err != nil {
@@ -148,6 +216,15 @@ type VideoChunkUploadRequest struct {
 	Filename    string `form:"filename" binding:"required,max=255"`
 	Title       string `form:"title"`
 	Description string `form:"description"`
+	ChunkMD5    string `form:"chunkMd5" binding:"omitempty,len=32,hexadecimal"`
+	FileMD5     string `form:"fileMd5" binding:"omitempty,len=32,hexadecimal"`
+}
+
+// resourceExtensions 课程资源允许的扩展名（文档、图片、视频）
+func resourceExtensions() []string {
+	exts := append([]string{}, util.AllowedDocumentExtensions...)
+	exts = append(exts, util.AllowedImageExtensions...)
+	return append(exts, util.AllowedVideoExtensions...)
 }
 
 // UploadVideo godoc
@@ -166,6 +243,21 @@ type VideoChunkUploadRequest struct {
 // @Failure 500 {object} util.Response "服务器内部错误"
 // @Router /api/upload/video [post]
 func (c *ContentController) UploadVideo(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	// 在解析表单（写入临时文件）之前登记，超出并发上限的请求不占用磁盘
+	uploadID := "video:" + util.GenerateRandomString(16)
+	if !acquireUploadSlot(ctx, c.ContentService.UploadLimiter, c.ContentService.Cfg.Upload.RetryAfterSeconds, user.UserID, uploadID) {
+		return
+	}
+	defer c.ContentService.UploadLimiter.Release(context.Background(), user.UserID, uploadID)
+	if !util.LimitUploadBody(ctx, util.MB(c.ContentService.Cfg.Upload.VideoMaxMB)) {
+		return
+	}
+
 	var req VideoUploadRequest
 	if err := ctx.ShouldBind(&req); err != nil {
 		util.BadRequest(ctx, err.Error())
@@ -177,6 +269,9 @@ func (c *ContentController) UploadVideo(ctx *gin.Context) {
 		util.BadRequest(ctx, "视频文件是必需的")
 		return
 	}
+	if !util.CheckUpload(ctx, file, util.AllowedVideoExtensions, util.MB(c.ContentService.Cfg.Upload.VideoMaxMB)) {
+		return
+	}
 
 	resource, err := c.ContentService.UploadVideo(ctx, file, req.Title, req.Description)
 	if err != nil {
@@ -193,6 +288,11 @@ func (c *ContentController) UploadVideo(ctx *gin.Context) {
 		"size":        resource.Size,
 		"format":      resource.Format,
 		"thumbnail":   resource.Thumbnail,
+		"width":       resource.Width,
+		"height":      resource.Height,
+		"codec":       resource.Codec,
+		"bitrate":     resource.Bitrate,
+		"probeStatus": resource.ProbeStatus,
 	})
 }
 
@@ -210,12 +310,19 @@ func (c *ContentController) UploadVideo(ctx *gin.Context) {
 // @Param   filename formData string true "原始文件名"
 // @Param   title formData string false "视频标题"
 // @Param   description formData string false "视频描述"
+// @Param   chunkMd5 formData string false "当前分块的MD5（十六进制）"
+// @Param   fileMd5 formData string false "完整文件的MD5，最迟随最后一个分块提交"
 // @Success 200 {object} util.Response{data=object} "上传成功"
-// @Failure 400 {object} util.Response "请求参数错误"
+// @Failure 400 {object} util.Response "请求参数错误或校验失败（data.retryChunks 为需重传的分块）"
 // @Failure 401 {object} util.Response "未授权"
+// @Failure 403 {object} util.Response "上传标识已被其他用户使用"
 // @Failure 500 {object} util.Response "服务器内部错误"
+// @Failure 429 {object} util.Response "同时进行的上传过多，参考 Retry-After 重试"
 // @Router /api/upload/video/chunk [post]
 func (c *ContentController) UploadVideoChunk(ctx *gin.Context) {
+	if !util.LimitUploadBody(ctx, util.MB(c.ContentService.Cfg.Upload.ChunkMaxMB)) {
+		return
+	}
 	var req VideoChunkUploadRequest
 	if err := ctx.ShouldBind(&req); err != nil {
 		util.BadRequest(ctx, err.Error())
@@ -227,14 +334,48 @@ func (c *ContentController) UploadVideoChunk(ctx *gin.Context) {
 		util.BadRequest(ctx, "分块文件是必需的")
 		return
 	}
+	// 分片本身没有扩展名，按原始文件名校验类型
+	if err := util.ValidateUpload(&multipart.FileHeader{Filename: req.Filename}, util.AllowedVideoExtensions, 0); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+	if !util.CheckUpload(ctx, chunkFile, nil, util.MB(c.ContentService.Cfg.Upload.ChunkMaxMB)) {
+		return
+	}
+	if !validUploadIdentifier(req.Identifier) {
+		util.BadRequest(ctx, "上传标识符不合法")
+		return
+	}
+
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	// 同一文件的所有分片按 identifier 只占用一个并发名额
+	if !acquireUploadSlot(ctx, c.ContentService.UploadLimiter, c.ContentService.Cfg.Upload.RetryAfterSeconds, user.UserID, req.Identifier) {
+		return
+	}
 
-	progress, resource, err := c.ContentService.UploadVideoChunk(ctx, chunkFile, req.ChunkNumber, req.TotalChunks, req.Identifier, req.Filename, req.Title, req.Description)
+	progress, resource, err := c.ContentService.UploadVideoChunk(ctx, chunkFile, req.ChunkNumber, req.TotalChunks, req.Identifier, req.Filename, req.Title, req.Description, req.ChunkMD5, req.FileMD5)
 	if err != nil {
+		if errors.Is(err, util.ErrChunkTooSmall) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
+		var mismatch *service.ChecksumMismatchError
+		if errors.As(err, &mismatch) {
+			util.ErrorWithData(ctx, http.StatusBadRequest, err.Error(), gin.H{"retryChunks": mismatch.Chunks})
+			return
+		}
 		util.LogInternalError(ctx, err)
 		return
 	}
 
 	isComplete := progress.UploadedChunks == progress.TotalChunks
+	if isComplete && resource != nil {
+		c.ContentService.UploadLimiter.Release(ctx, user.UserID, req.Identifier)
+	}
 	responseData := gin.H{
 		"identifier":     req.Identifier,
 		"chunkNumber":    req.ChunkNumber,
@@ -253,11 +394,106 @@ func (c *ContentController) UploadVideoChunk(ctx *gin.Context) {
 		responseData["size"] = resource.Size
 		responseData["format"] = resource.Format
 		responseData["thumbnail"] = resource.Thumbnail
+		responseData["width"] = resource.Width
+		responseData["height"] = resource.Height
+		responseData["codec"] = resource.Codec
+		responseData["bitrate"] = resource.Bitrate
+		responseData["probeStatus"] = resource.ProbeStatus
 	}
 
 	util.Success(ctx, responseData)
 }
 
+// AbortChunkUpload godoc
+// @Summary 放弃分片上传
+// @Description 删除已上传的分片和进度，并释放该上传占用的并发名额。只能放弃自己发起且未完成的上传
+// @Tags 内容
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param   identifier path string true "文件唯一标识符"
+// @Success 200 {object} util.Response "已放弃"
+// @Failure 404 {object} util.Response "上传不存在或已结束"
+// @Router /api/upload/video/chunk/{identifier} [delete]
+func (c *ContentController) AbortChunkUpload(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	identifier := ctx.Param("identifier")
+	if !validUploadIdentifier(identifier) {
+		util.BadRequest(ctx, "上传标识符不合法")
+		return
+	}
+	if !c.ContentService.UploadLimiter.Owns(ctx, user.UserID, identifier) {
+		util.NotFound(ctx)
+		return
+	}
+
+	if _, err := c.ContentService.AbortChunkUpload(ctx, identifier); err != nil {
+		util.LogInternalError(ctx, err)
+		return
+	}
+	c.ContentService.UploadLimiter.Release(ctx, user.UserID, identifier)
+	util.Success(ctx, nil)
+}
+
+// AdminAbortChunkUpload godoc
+// @Summary 清理分片上传（管理员）
+// @Description 删除任意分片上传的临时分片目录和 Redis 进度，并释放发起者的并发上传名额，用于回收被客户端放弃的上传占用的空间
+// @Tags 内容
+// @Produce  json
+// @Security ApiKeyAuth
+// @Param   identifier path string true "文件唯一标识符"
+// @Success 200 {object} util.Response "已清理"
+// @Failure 404 {object} util.Response "上传不存在"
+// @Router /api/admin/upload/video/{identifier} [delete]
+func (c *ContentController) AdminAbortChunkUpload(ctx *gin.Context) {
+	identifier := ctx.Param("identifier")
+	if !validUploadIdentifier(identifier) {
+		util.BadRequest(ctx, "上传标识符不合法")
+		return
+	}
+
+	found, err := c.ContentService.AbortChunkUpload(ctx, identifier)
+	if err != nil {
+		util.LogInternalError(ctx, err)
+		return
+	}
+	// 释放发起者占用的并发名额，否则该用户要等登记过期后才能开始新的上传
+	c.ContentService.UploadLimiter.ReleaseUpload(ctx, identifier)
+	if !found {
+		util.NotFound(ctx)
+		return
+	}
+	util.Success(ctx, nil)
+}
+
+// validUploadIdentifier 分片上传标识符会用作临时目录名，不能包含路径分隔符
+func validUploadIdentifier(identifier string) bool {
+	return identifier != "" && identifier != "." && identifier != ".." && filepath.Base(identifier) == identifier
+}
+
+// acquireUploadSlot 登记用户的上传，超出并发上限时返回 429 并通过 Retry-After 提示重试时间；
+// 上传标识已被其他用户占用时返回 403
+func acquireUploadSlot(ctx *gin.Context, limiter *service.UploadLimiter, retryAfterSeconds int, userID uint, uploadID string) bool {
+	if err := limiter.Acquire(ctx, userID, uploadID); err != nil {
+		if errors.Is(err, util.ErrUploadNotOwned) {
+			util.Error(ctx, http.StatusForbidden, err.Error())
+			return false
+		}
+		if errors.Is(err, util.ErrTooManyUploads) {
+			ctx.Header("Retry-After", strconv.Itoa(retryAfterSeconds))
+			util.Error(ctx, http.StatusTooManyRequests, err.Error())
+			return false
+		}
+		util.LogInternalError(ctx, err)
+		return false
+	}
+	return true
+}
+
 // GetUploadProgress godoc
 // @Summary 查询视频上传进度
 // @Description 查询文件上传进度
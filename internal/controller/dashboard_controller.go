@@ -4,7 +4,6 @@ import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -91,20 +90,12 @@ func (c *DashboardController) UpdateTaskStatus(ctx *gin.Context) {
 		return
 	}
 
-	taskIDStr := ctx.Param("taskId")
-	if taskIDStr == "" {
-		util.BadRequest(ctx, "taskId is required")
+	taskID, ok := util.ParseUintParam(ctx, "taskId")
+	if !ok {
 		return
 	}
 
-	taskID, err := strconv.ParseUint(taskIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "invalid taskId")
-		return
-	}
-
-	err = c.DashboardService.UpdateTaskStatus(uint(taskID), req.Status)
-	if err != nil {
+	if err := c.DashboardService.UpdateTaskStatus(taskID, req.Status); err != nil {
 		util.InternalServerError(ctx)
 		return
 	}
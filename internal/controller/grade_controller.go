@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"strconv"
 	"time"
 
@@ -8,14 +9,37 @@ import (
 	"coder_edu_backend/internal/util"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type GradeController struct {
-	LevelService *service.LevelService
+	LevelService        *service.LevelService
+	GradingQueueService *service.GradingQueueService
 }
 
-func NewGradeController(levelService *service.LevelService) *GradeController {
-	return &GradeController{LevelService: levelService}
+func NewGradeController(levelService *service.LevelService, gradingQueueService *service.GradingQueueService) *GradeController {
+	return &GradeController{LevelService: levelService, GradingQueueService: gradingQueueService}
+}
+
+// @Summary 待批改总览
+// @Description 汇总关卡、知识点、摸底评估、课后测试中等待人工批改的记录数及最早提交时间。关卡和课后测试仅统计当前教师创建的（管理员统计全部）
+// @Tags 评分
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} util.Response{data=service.PendingGradingSummary}
+// @Router /api/teacher/grading/pending-summary [get]
+func (c *GradeController) GetPendingSummary(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	summary, err := c.GradingQueueService.PendingSummary(user.UserID, user.Role)
+	if err != nil {
+		util.LogInternalError(ctx, err)
+		return
+	}
+	util.Success(ctx, summary)
 }
 
 // @Summary 列出需人工评分的尝试（按关卡）
@@ -26,13 +50,11 @@ func NewGradeController(levelService *service.LevelService) *GradeController {
 // @Success 200 {object} util.Response
 // @Router /api/teacher/levels/{id}/attempts/pending-grading [get]
 func (c *GradeController) ListPendingGrading(ctx *gin.Context) {
-	levelStr := ctx.Param("id")
-	levelID, err := strconv.Atoi(levelStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid level id")
+	levelID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
-	attempts, err := c.LevelService.ListAttemptsNeedingManual(uint(levelID))
+	attempts, err := c.LevelService.ListAttemptsNeedingManual(levelID)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -56,16 +78,11 @@ func (c *GradeController) GradeAttempt(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	levelStr := ctx.Param("id")
-	_, err := strconv.Atoi(levelStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid level id")
+	if _, ok := util.ParseUintParam(ctx, "id"); !ok {
 		return
 	}
-	attemptStr := ctx.Param("attemptId")
-	aid, err := strconv.Atoi(attemptStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid attempt id")
+	aid, ok := util.ParseUintParam(ctx, "attemptId")
+	if !ok {
 		return
 	}
 	var body struct {
@@ -92,9 +109,103 @@ func (c *GradeController) GradeAttempt(ctx *gin.Context) {
 		})
 	}
 
-	if err := c.LevelService.ManualGradeAttempt(user.UserID, uint(aid), scores); err != nil {
+	if err := c.LevelService.ManualGradeAttempt(user.UserID, aid, scores); err != nil {
 		util.InternalServerError(ctx)
 		return
 	}
 	util.Success(ctx, gin.H{"graded": true})
 }
+
+// @Summary 教师批量人工评分
+// @Description 在一个事务内为同一关卡的多个尝试评分，任一尝试失败则全部回滚；评分完成后通知学生
+// @Tags 评分
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "关卡ID"
+// @Param body body object true "attempts [{attemptId, scores [{questionId, score, comment}]}]"
+// @Success 200 {object} util.Response{data=[]service.AttemptGradeResult}
+// @Router /api/teacher/levels/{id}/attempts/bulk-grade [post]
+func (c *GradeController) BulkGradeAttempts(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	levelID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	var body struct {
+		Attempts []struct {
+			AttemptID uint `json:"attemptId" binding:"required"`
+			Scores    []struct {
+				QuestionID uint   `json:"questionId"`
+				Score      int    `json:"score"`
+				Comment    string `json:"comment"`
+			} `json:"scores"`
+		} `json:"attempts" binding:"required,min=1"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+
+	now := time.Now()
+	grades := make([]service.AttemptGrade, 0, len(body.Attempts))
+	for _, a := range body.Attempts {
+		scores := make([]service.QuestionScore, 0, len(a.Scores))
+		for _, s := range a.Scores {
+			scores = append(scores, service.QuestionScore{
+				QuestionID: s.QuestionID,
+				Score:      s.Score,
+				Comment:    s.Comment,
+				GraderID:   user.UserID,
+				GradedAt:   &now,
+			})
+		}
+		grades = append(grades, service.AttemptGrade{AttemptID: a.AttemptID, Scores: scores})
+	}
+
+	results, err := c.LevelService.BulkGradeAttempts(user.UserID, user.Role, levelID, grades)
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrPermissionDenied):
+			util.Forbidden(ctx)
+		case errors.Is(err, util.ErrAttemptNotBelong):
+			util.BadRequest(ctx, err.Error())
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			util.NotFound(ctx)
+		default:
+			util.InternalServerError(ctx)
+		}
+		return
+	}
+	util.Success(ctx, results)
+}
+
+// @Summary 学生近期关卡尝试复盘
+// @Description 返回学生最近已结束的关卡尝试（跨关卡），包含逐题作答、对错、得分和教师评语，题目按尝试开始时的版本快照展示
+// @Tags 评分
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "学生ID"
+// @Param limit query int false "返回的尝试数量（最多50）" default(10)
+// @Success 200 {object} util.Response{data=[]service.StudentAttemptReview}
+// @Router /api/teacher/students/{id}/attempt-reviews [get]
+func (c *GradeController) GetStudentAttemptReviews(ctx *gin.Context) {
+	studentID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+	reviews, err := c.LevelService.GetStudentAttemptReviews(studentID, limit)
+	if err != nil {
+		util.LogInternalError(ctx, err)
+		return
+	}
+	util.Success(ctx, reviews)
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -30,6 +31,10 @@ func (c *KnowledgePointController) Create(ctx *gin.Context) {
 		util.BadRequest(ctx, err.Error())
 		return
 	}
+	if !validAnswerReveal(req.AnswerReveal, req.RevealDelayMinutes) {
+		util.BadRequest(ctx, answerRevealErrMsg)
+		return
+	}
 
 	kp, err := c.Service.CreateKnowledgePoint(req)
 	if err != nil {
@@ -299,6 +304,10 @@ func (c *KnowledgePointController) Update(ctx *gin.Context) {
 		util.BadRequest(ctx, err.Error())
 		return
 	}
+	if !validAnswerReveal(req.AnswerReveal, req.RevealDelayMinutes) {
+		util.BadRequest(ctx, answerRevealErrMsg)
+		return
+	}
 
 	kp, err := c.Service.UpdateKnowledgePoint(id, req)
 	if err != nil {
@@ -376,6 +385,53 @@ func (c *KnowledgePointController) GetSubmissionDetail(ctx *gin.Context) {
 	util.Success(ctx, submission)
 }
 
+// @Summary 获取解析后的知识点测试结果 (老师/管理员)
+// @Description 将提交记录中的判定结果解析为结构化数据，包含每题的学生答案、正确答案、是否正确和得分
+// @Tags 知识点
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "提交ID"
+// @Success 200 {object} util.Response{data=service.KnowledgePointSubmissionResult}
+// @Router /api/teacher/knowledge-points/submissions/{id}/result [get]
+func (c *KnowledgePointController) GetSubmissionResult(ctx *gin.Context) {
+	result, err := c.Service.GetSubmissionResultForTeacher(ctx.Param("id"))
+	c.respondSubmissionResult(ctx, result, err)
+}
+
+// @Summary 学生端：查看自己的知识点测试结果
+// @Description 返回结构化的逐题判定结果，正确答案按知识点的答案公开策略返回（answerRevealed=false 时不包含 correctAnswer）
+// @Tags 知识点
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "提交ID"
+// @Success 200 {object} util.Response{data=service.KnowledgePointSubmissionResult}
+// @Router /api/knowledge-points/student/submissions/{id} [get]
+func (c *KnowledgePointController) GetMySubmissionResult(ctx *gin.Context) {
+	claims := util.GetUserFromContext(ctx)
+	if claims == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	result, err := c.Service.GetSubmissionResultForStudent(ctx.Param("id"), claims.UserID)
+	c.respondSubmissionResult(ctx, result, err)
+}
+
+func (c *KnowledgePointController) respondSubmissionResult(ctx *gin.Context, result *service.KnowledgePointSubmissionResult, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrSubmissionNotFound):
+			util.NotFound(ctx)
+		case errors.Is(err, util.ErrPermissionDenied):
+			util.Forbidden(ctx)
+		default:
+			util.InternalServerError(ctx)
+		}
+		return
+	}
+	util.Success(ctx, result)
+}
+
 // @Summary 审核学生提交的知识点测试 (老师/管理员)
 // @Tags 知识点
 // @Accept json
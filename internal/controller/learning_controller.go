@@ -3,7 +3,8 @@ package controller
 import (
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
-	"strconv"
+	"errors"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -136,10 +137,8 @@ func (c *LearningController) SubmitQuiz(ctx *gin.Context) {
 		return
 	}
 
-	quizIDStr := ctx.Param("quizId")
-	quizID, err := strconv.Atoi(quizIDStr)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid quiz ID")
+	quizID, ok := util.ParseUintParam(ctx, "quizId")
+	if !ok {
 		return
 	}
 
@@ -149,7 +148,7 @@ func (c *LearningController) SubmitQuiz(ctx *gin.Context) {
 		return
 	}
 
-	result, err := c.LearningService.SubmitQuiz(user.UserID, uint(quizID), submission)
+	result, err := c.LearningService.SubmitQuiz(user.UserID, quizID, submission)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -159,7 +158,7 @@ func (c *LearningController) SubmitQuiz(ctx *gin.Context) {
 }
 
 // @Summary 运行C代码
-// @Description 运行学生提交的C代码并返回执行结果
+// @Description 运行学生提交的代码并返回执行结果，language 支持 c、cpp、python，默认 c
 // @Tags 学习模块
 // @Accept json
 // @Produce json
@@ -182,9 +181,81 @@ func (c *LearningController) ExecuteCode(ctx *gin.Context) {
 
 	result, err := c.LearningService.RunCode(req)
 	if err != nil {
+		if errors.Is(err, util.ErrUnsupportedLanguage) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
 
 	util.Success(ctx, result)
 }
+
+// @Summary 学习日志汇总
+// @Description 按活动类型和日期汇总当前用户的学习时长，默认统计最近30天
+// @Tags 学习模块
+// @Produce json
+// @Security BearerAuth
+// @Param start query string false "开始日期 (YYYY-MM-DD)"
+// @Param end query string false "结束日期 (YYYY-MM-DD)，包含当天"
+// @Success 200 {object} util.Response{data=service.LearningLogSummary}
+// @Router /api/learning/logs/summary [get]
+func (c *LearningController) GetLearningLogSummary(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	c.respondLearningLogSummary(ctx, user.UserID)
+}
+
+// @Summary 学生学习日志汇总
+// @Description 教师/管理员按活动类型和日期查看指定学生的学习时长，默认统计最近30天
+// @Tags 学习模块
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "学生ID"
+// @Param start query string false "开始日期 (YYYY-MM-DD)"
+// @Param end query string false "结束日期 (YYYY-MM-DD)，包含当天"
+// @Success 200 {object} util.Response{data=service.LearningLogSummary}
+// @Router /api/teacher/students/{id}/learning-logs/summary [get]
+func (c *LearningController) GetStudentLearningLogSummary(ctx *gin.Context) {
+	studentID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	c.respondLearningLogSummary(ctx, studentID)
+}
+
+func (c *LearningController) respondLearningLogSummary(ctx *gin.Context, userID uint) {
+	end := time.Now()
+	if s := ctx.Query("end"); s != "" {
+		t, err := time.ParseInLocation(util.DateFormat, s, time.Local)
+		if err != nil {
+			util.BadRequest(ctx, "无效的结束日期格式，应为 YYYY-MM-DD")
+			return
+		}
+		end = t
+	}
+	start := end.AddDate(0, 0, -29)
+	if s := ctx.Query("start"); s != "" {
+		t, err := time.ParseInLocation(util.DateFormat, s, time.Local)
+		if err != nil {
+			util.BadRequest(ctx, "无效的开始日期格式，应为 YYYY-MM-DD")
+			return
+		}
+		start = t
+	}
+	if start.After(end) {
+		util.BadRequest(ctx, "开始日期不能晚于结束日期")
+		return
+	}
+
+	summary, err := c.LearningService.GetLearningLogSummary(userID, start, end)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, summary)
+}
@@ -4,9 +4,10 @@ import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
-	"strconv"
+	"errors"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // LearningGoalController 处理学习目标的API请求
@@ -140,14 +141,12 @@ func (c *LearningGoalController) GetGoalByID(ctx *gin.Context) {
 		return
 	}
 
-	goalIDStr := ctx.Param("id")
-	goalID, err := strconv.ParseUint(goalIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid goal ID")
+	goalID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	goal, err := c.LearningGoalService.GetGoalByID(user.UserID, uint(goalID))
+	goal, err := c.LearningGoalService.GetGoalByID(user.UserID, goalID)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -173,10 +172,8 @@ func (c *LearningGoalController) UpdateGoal(ctx *gin.Context) {
 		return
 	}
 
-	goalIDStr := ctx.Param("id")
-	goalID, err := strconv.ParseUint(goalIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid goal ID")
+	goalID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -186,8 +183,49 @@ func (c *LearningGoalController) UpdateGoal(ctx *gin.Context) {
 		return
 	}
 
-	goal, err := c.LearningGoalService.UpdateGoal(user.UserID, uint(goalID), req)
+	goal, err := c.LearningGoalService.UpdateGoal(user.UserID, goalID, req)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+
+	util.Success(ctx, goal)
+}
+
+// @Summary 暂缓或关闭目标截止提醒
+// @Description action=snooze 暂缓提醒 snoozeHours 小时（默认 24），到期后按剩余天数重新提醒；action=dismiss 不再提醒该目标
+// @Tags 学习目标
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "目标ID"
+// @Param request body service.GoalReminderRequest true "提醒操作"
+// @Success 200 {object} util.Response
+// @Router /api/learning-goals/{id}/reminder [put]
+func (c *LearningGoalController) UpdateReminder(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	goalID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	var req service.GoalReminderRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+
+	goal, err := c.LearningGoalService.UpdateReminder(user.UserID, goalID, req)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			util.NotFound(ctx)
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -211,14 +249,12 @@ func (c *LearningGoalController) DeleteGoal(ctx *gin.Context) {
 		return
 	}
 
-	goalIDStr := ctx.Param("id")
-	goalID, err := strconv.ParseUint(goalIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid goal ID")
+	goalID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	err = c.LearningGoalService.DeleteGoal(user.UserID, uint(goalID))
+	err := c.LearningGoalService.DeleteGoal(user.UserID, goalID)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -243,14 +279,12 @@ func (c *LearningGoalController) GetGoalDetails(ctx *gin.Context) {
 		return
 	}
 
-	goalIDStr := ctx.Param("id")
-	goalID, err := strconv.ParseUint(goalIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "Invalid goal ID")
+	goalID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	goal, err := c.LearningGoalService.GetGoalByID(user.UserID, uint(goalID))
+	goal, err := c.LearningGoalService.GetGoalByID(user.UserID, goalID)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
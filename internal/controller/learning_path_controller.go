@@ -3,9 +3,11 @@ package controller
 import (
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type LearningPathController struct {
@@ -139,10 +141,11 @@ func (c *LearningPathController) DeleteMaterial(ctx *gin.Context) {
 }
 
 // @Summary 学生端：获取自我评估学习路径
+// @Description 按等级、章节顺序返回资料及解锁、完成状态，附带整体完成百分比、下一份待学习资料 nextMaterialId，全部完成时 finished 为 true
 // @Tags 学习路径
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} util.Response
+// @Success 200 {object} util.Response{data=service.StudentPathResponse}
 // @Router /api/learning-path/student [get]
 func (c *LearningPathController) GetStudentPath(ctx *gin.Context) {
 	user := util.GetUserFromContext(ctx)
@@ -221,11 +224,13 @@ func (c *LearningPathController) RecordLearningTime(ctx *gin.Context) {
 }
 
 // @Summary 学生端：标记资料为已完成
+// @Description 标记完成后返回更新后的学习路径进度，重复标记不会重复奖励积分
 // @Tags 学习路径
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "资料ID"
-// @Success 200 {object} util.Response
+// @Success 200 {object} util.Response{data=service.StudentPathResponse}
+// @Failure 404 {object} util.Response
 // @Router /api/learning-path/materials/{id}/complete [post]
 func (c *LearningPathController) CompleteMaterial(ctx *gin.Context) {
 	user := util.GetUserFromContext(ctx)
@@ -235,10 +240,15 @@ func (c *LearningPathController) CompleteMaterial(ctx *gin.Context) {
 	}
 
 	id := ctx.Param("id")
-	if err := c.Service.CompleteMaterial(user.UserID, id); err != nil {
+	progress, err := c.Service.CompleteMaterial(user.UserID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			util.NotFound(ctx)
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
 
-	util.Success(ctx, "标记完成成功")
+	util.Success(ctx, progress)
 }
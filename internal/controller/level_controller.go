@@ -8,13 +8,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type LevelController struct {
@@ -49,6 +48,10 @@ func (c *LevelController) CreateLevel(ctx *gin.Context) {
 
 	level, err := c.LevelService.CreateLevel(user.UserID, req)
 	if err != nil {
+		if errors.Is(err, util.ErrVisibleToRequired) || errors.Is(err, util.ErrInvalidVisibleTo) || errors.Is(err, util.ErrInvalidPassingMode) || errors.Is(err, util.ErrUnsupportedLanguage) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -64,13 +67,11 @@ func (c *LevelController) CreateLevel(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/teacher/levels/{id} [get]
 func (c *LevelController) GetLevel(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
-	level, err := c.LevelService.LevelRepo.FindByID(uint(id))
+	level, err := c.LevelService.LevelRepo.FindByID(id)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -78,6 +79,40 @@ func (c *LevelController) GetLevel(ctx *gin.Context) {
 	util.Success(ctx, level)
 }
 
+// @Summary 获取关卡编辑数据
+// @Description 返回关卡及其题目（按顺序）、能力ID、知识标签ID，结构与创建关卡请求一致；仅创建者或管理员可访问
+// @Tags 关卡管理
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "关卡ID"
+// @Success 200 {object} util.Response{data=service.LevelFullResponse}
+// @Router /api/teacher/levels/{id}/edit [get]
+func (c *LevelController) GetLevelForEdit(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	payload, err := c.LevelService.GetLevelForEdit(user.UserID, user.Role, id)
+	if err != nil {
+		if errors.Is(err, util.ErrPermissionDenied) {
+			util.Forbidden(ctx)
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			util.NotFound(ctx)
+			return
+		}
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, payload)
+}
+
 // @Summary 列表关卡
 // @Tags 关卡管理
 // @Security BearerAuth
@@ -127,10 +162,8 @@ func (c *LevelController) UpdateLevel(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 	var req service.LevelCreateRequest
@@ -138,8 +171,16 @@ func (c *LevelController) UpdateLevel(ctx *gin.Context) {
 		util.BadRequest(ctx, err.Error())
 		return
 	}
-	level, err := c.LevelService.UpdateLevel(user.UserID, uint(id), req)
+	level, err := c.LevelService.UpdateLevel(ctx.Request.Context(), user.UserID, user.Role, id, req)
 	if err != nil {
+		if errors.Is(err, util.ErrPermissionDenied) {
+			util.Forbidden(ctx)
+			return
+		}
+		if errors.Is(err, util.ErrVisibleToRequired) || errors.Is(err, util.ErrInvalidVisibleTo) || errors.Is(err, util.ErrInvalidPassingMode) || errors.Is(err, util.ErrUnsupportedLanguage) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -147,13 +188,15 @@ func (c *LevelController) UpdateLevel(ctx *gin.Context) {
 }
 
 // @Summary 发布/下架关卡
+// @Description 发布前会校验关卡（题目、及格分、正确答案等），存在阻断性问题时返回 400 及校验结果；force=true 时跳过校验
 // @Tags 关卡管理
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "关卡ID"
-// @Param action body object true "publish:bool"
+// @Param action body object true "publish:bool, force:bool"
 // @Success 200 {object} util.Response
+// @Failure 400 {object} util.Response{data=service.LevelValidationResult}
 // @Router /api/teacher/levels/{id}/publish [post]
 func (c *LevelController) PublishLevel(ctx *gin.Context) {
 	user := util.GetUserFromContext(ctx)
@@ -161,26 +204,68 @@ func (c *LevelController) PublishLevel(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 	var body struct {
 		Publish bool `json:"publish"`
+		Force   bool `json:"force"`
 	}
 	if err := ctx.ShouldBindJSON(&body); err != nil {
 		util.BadRequest(ctx, err.Error())
 		return
 	}
-	if err := c.LevelService.PublishLevel(user.UserID, uint(id), body.Publish); err != nil {
-		util.InternalServerError(ctx)
+	validation, err := c.LevelService.PublishLevel(user.UserID, user.Role, id, body.Publish, body.Force)
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrPermissionDenied):
+			util.Forbidden(ctx)
+		case errors.Is(err, util.ErrLevelValidationFailed):
+			util.ErrorWithData(ctx, http.StatusBadRequest, err.Error(), validation)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			util.NotFound(ctx)
+		default:
+			util.InternalServerError(ctx)
+		}
 		return
 	}
 	util.Success(ctx, gin.H{"published": body.Publish})
 }
 
+// @Summary 发布前校验关卡
+// @Description 检查关卡是否有题目、及格分是否合理、自动评分题是否设置正确答案、总分是否达到及格分。error 级问题会阻止发布，warning 仅作提示
+// @Tags 关卡管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "关卡ID"
+// @Success 200 {object} util.Response{data=service.LevelValidationResult}
+// @Router /api/teacher/levels/{id}/validate [get]
+func (c *LevelController) ValidateLevel(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	result, err := c.LevelService.ValidateLevel(user.UserID, user.Role, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrPermissionDenied):
+			util.Forbidden(ctx)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			util.NotFound(ctx)
+		default:
+			util.InternalServerError(ctx)
+		}
+		return
+	}
+	util.Success(ctx, result)
+}
+
 // @Summary 批量更新关卡字段（上限/积分/发布等）
 // @Tags 关卡管理
 // @Accept json
@@ -218,13 +303,11 @@ func (c *LevelController) BulkUpdate(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/teacher/levels/{id}/versions [get]
 func (c *LevelController) GetVersions(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
-	versions, err := c.LevelService.GetVersions(uint(id))
+	versions, err := c.LevelService.GetVersions(id)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -247,26 +330,51 @@ func (c *LevelController) RollbackVersion(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	idStr := ctx.Param("id")
-	_, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	levelID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
-	verStr := ctx.Param("versionId")
-	verID, err := strconv.Atoi(verStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid version id")
+	verID, ok := util.ParseUintParam(ctx, "versionId")
+	if !ok {
 		return
 	}
-	levelID, _ := strconv.ParseUint(idStr, 10, 32)
-	if err := c.LevelService.RollbackToVersion(user.UserID, uint(levelID), uint(verID)); err != nil {
+	if err := c.LevelService.RollbackToVersion(user.UserID, levelID, verID); err != nil {
 		util.InternalServerError(ctx)
 		return
 	}
 	util.Success(ctx, gin.H{"rolled_back_to": verID})
 }
 
+// @Summary 重建关卡当前版本快照（管理员）
+// @Description 当前版本快照损坏时，使用关卡现有数据和题目生成新版本并设为当前版本
+// @Tags 关卡管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "关卡ID"
+// @Success 200 {object} util.Response
+// @Router /api/admin/levels/{id}/versions/regenerate [post]
+func (c *LevelController) RegenerateCurrentVersion(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	levelID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	version, err := c.LevelService.RegenerateCurrentVersion(user.UserID, levelID)
+	if err != nil {
+		if errors.Is(err, util.ErrLevelNotFound) {
+			util.NotFound(ctx)
+			return
+		}
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, version)
+}
+
 // @Summary 上传关卡封面（教师）
 // @Tags 关卡管理
 // @Accept multipart/form-data
@@ -282,9 +390,11 @@ func (c *LevelController) UploadCover(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	idStr := ctx.Param("id")
-	if _, err := strconv.Atoi(idStr); err != nil {
-		util.BadRequest(ctx, "invalid id")
+	levelID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	if !util.LimitUploadBody(ctx, util.MB(c.ContentService.Cfg.Upload.ImageMaxMB)) {
 		return
 	}
 	file, err := ctx.FormFile("cover")
@@ -293,16 +403,13 @@ func (c *LevelController) UploadCover(ctx *gin.Context) {
 		return
 	}
 
-	// validate extension
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	allowed := map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".webp": true}
-	if !allowed[ext] {
-		util.BadRequest(ctx, "unsupported file type")
+	// validate extension and size
+	if !util.CheckUpload(ctx, file, util.AllowedImageExtensions, util.MB(c.ContentService.Cfg.Upload.ImageMaxMB)) {
 		return
 	}
 	// upload via ContentService to create a Resource record
 	resource := &model.Resource{
-		Title:      fmt.Sprintf("Level %s Cover", idStr),
+		Title:      fmt.Sprintf("Level %d Cover", levelID),
 		Type:       model.Article,
 		ModuleType: "level_cover",
 	}
@@ -311,8 +418,7 @@ func (c *LevelController) UploadCover(ctx *gin.Context) {
 		return
 	}
 	// attach to level
-	levelID, _ := strconv.ParseUint(idStr, 10, 32)
-	level, err := c.LevelService.LevelRepo.FindByID(uint(levelID))
+	level, err := c.LevelService.LevelRepo.FindByID(levelID)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -341,9 +447,10 @@ func (c *LevelController) UploadAttachment(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	idStr := ctx.Param("id")
-	if _, err := strconv.Atoi(idStr); err != nil {
-		util.BadRequest(ctx, "invalid id")
+	if _, ok := util.ParseUintParam(ctx, "id"); !ok {
+		return
+	}
+	if !util.LimitUploadBody(ctx, util.MB(c.ContentService.Cfg.Upload.ResourceMaxMB)) {
 		return
 	}
 	file, err := ctx.FormFile("file")
@@ -351,6 +458,9 @@ func (c *LevelController) UploadAttachment(ctx *gin.Context) {
 		util.BadRequest(ctx, "file is required")
 		return
 	}
+	if !util.CheckUpload(ctx, file, append(append([]string{}, util.AllowedDocumentExtensions...), util.AllowedImageExtensions...), util.MB(c.ContentService.Cfg.Upload.ResourceMaxMB)) {
+		return
+	}
 	// reuse ContentService to upload and create resource record
 	resource := &model.Resource{
 		Title:      file.Filename,
@@ -379,10 +489,8 @@ func (c *LevelController) CreateQuestion(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 	var req service.LevelQuestionRequest
@@ -390,8 +498,16 @@ func (c *LevelController) CreateQuestion(ctx *gin.Context) {
 		util.BadRequest(ctx, err.Error())
 		return
 	}
-	q, err := c.LevelService.AddQuestion(user.UserID, uint(id), req)
+	q, err := c.LevelService.AddQuestion(user.UserID, user.Role, id, req)
 	if err != nil {
+		if errors.Is(err, util.ErrPermissionDenied) {
+			util.Forbidden(ctx)
+			return
+		}
+		if errors.Is(err, util.ErrUnsupportedLanguage) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -414,16 +530,12 @@ func (c *LevelController) UpdateQuestion(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	levelStr := ctx.Param("id")
-	levelID, err := strconv.Atoi(levelStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid level id")
+	levelID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
-	qStr := ctx.Param("qid")
-	qid, err := strconv.Atoi(qStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid question id")
+	qid, ok := util.ParseUintParam(ctx, "qid")
+	if !ok {
 		return
 	}
 	var req service.LevelQuestionRequest
@@ -431,8 +543,16 @@ func (c *LevelController) UpdateQuestion(ctx *gin.Context) {
 		util.BadRequest(ctx, err.Error())
 		return
 	}
-	q, err := c.LevelService.UpdateQuestion(user.UserID, uint(levelID), uint(qid), req)
+	q, err := c.LevelService.UpdateQuestion(user.UserID, user.Role, levelID, qid, req)
 	if err != nil {
+		if errors.Is(err, util.ErrPermissionDenied) {
+			util.Forbidden(ctx)
+			return
+		}
+		if errors.Is(err, util.ErrUnsupportedLanguage) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -453,19 +573,19 @@ func (c *LevelController) DeleteQuestion(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	levelStr := ctx.Param("id")
-	levelID, err := strconv.Atoi(levelStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid level id")
+	levelID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
-	qStr := ctx.Param("qid")
-	qid, err := strconv.Atoi(qStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid question id")
+	qid, ok := util.ParseUintParam(ctx, "qid")
+	if !ok {
 		return
 	}
-	if err := c.LevelService.DeleteQuestion(uint(levelID), uint(qid)); err != nil {
+	if err := c.LevelService.DeleteQuestion(user.UserID, user.Role, levelID, qid); err != nil {
+		if errors.Is(err, util.ErrPermissionDenied) {
+			util.Forbidden(ctx)
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -477,7 +597,9 @@ func (c *LevelController) DeleteQuestion(ctx *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "关卡ID"
+// @Param force query bool false "已有挑战记录时归档关卡（保留学生作答）"
 // @Success 200 {object} util.Response
+// @Failure 409 {object} util.Response "关卡已有挑战记录"
 // @Router /api/teacher/levels/{id} [delete]
 func (c *LevelController) DeleteLevel(ctx *gin.Context) {
 	user := util.GetUserFromContext(ctx)
@@ -485,13 +607,21 @@ func (c *LevelController) DeleteLevel(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	levelStr := ctx.Param("id")
-	levelID, err := strconv.Atoi(levelStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid level id")
+	levelID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
-	if err := c.LevelService.DeleteLevel(user.UserID, uint(levelID)); err != nil {
+	force := ctx.Query("force") == "true"
+	if err := c.LevelService.DeleteLevel(user.UserID, levelID, force); err != nil {
+		var inUse *service.LevelInUseError
+		if errors.As(err, &inUse) {
+			util.ErrorWithData(ctx, http.StatusConflict, err.Error(), gin.H{"attempts": inUse.Attempts, "inProgress": inUse.InProgress})
+			return
+		}
+		if errors.Is(err, util.ErrPermissionDenied) {
+			util.Forbidden(ctx)
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -569,14 +699,12 @@ func (c *LevelController) GetStudentLevelDetail(ctx *gin.Context) {
 		return
 	}
 
-	levelStr := ctx.Param("id")
-	levelID, err := strconv.Atoi(levelStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid level id")
+	levelID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	levelDetail, err := c.LevelService.GetStudentLevelDetail(user.UserID, uint(levelID))
+	levelDetail, err := c.LevelService.GetStudentLevelDetail(user.UserID, levelID)
 	if err != nil {
 		if err.Error() == "level not found" || err.Error() == "level not accessible" ||
 			err.Error() == "level not yet available" || err.Error() == "level no longer available" ||
@@ -607,14 +735,12 @@ func (c *LevelController) GetStudentLevelQuestions(ctx *gin.Context) {
 		return
 	}
 
-	levelStr := ctx.Param("id")
-	levelID, err := strconv.Atoi(levelStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid level id")
+	levelID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	questions, err := c.LevelService.GetStudentLevelQuestions(user.UserID, uint(levelID))
+	questions, err := c.LevelService.GetStudentLevelQuestions(user.UserID, levelID)
 	if err != nil {
 		if err.Error() == "level not found" || err.Error() == "level not accessible" ||
 			err.Error() == "level not yet available" || err.Error() == "level no longer available" ||
@@ -650,17 +776,13 @@ func (c *LevelController) BatchSubmitAnswers(ctx *gin.Context) {
 		return
 	}
 
-	levelStr := ctx.Param("id")
-	levelID, err := strconv.Atoi(levelStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid level id")
+	levelID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	attemptStr := ctx.Param("attemptId")
-	attemptID, err := strconv.Atoi(attemptStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid attempt id")
+	attemptID, ok := util.ParseUintParam(ctx, "attemptId")
+	if !ok {
 		return
 	}
 
@@ -671,7 +793,7 @@ func (c *LevelController) BatchSubmitAnswers(ctx *gin.Context) {
 		return
 	}
 
-	result, err := c.LevelService.BatchSubmitAnswers(user.UserID, uint(levelID), uint(attemptID), req)
+	result, err := c.LevelService.BatchSubmitAnswers(user.UserID, levelID, attemptID, req)
 	if err != nil {
 		if err.Error() == "level not found" || err.Error() == "level not accessible" ||
 			err.Error() == "level not yet available" || err.Error() == "level no longer available" ||
@@ -697,10 +819,8 @@ func (c *LevelController) BatchSubmitAnswers(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/teacher/levels/{id}/attempts/stats [get]
 func (c *LevelController) GetAttemptStats(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 	var startPtr *time.Time
@@ -727,7 +847,7 @@ func (c *LevelController) GetAttemptStats(ctx *gin.Context) {
 			studentID = uint(v)
 		}
 	}
-	stats, err := c.LevelService.GetAttemptStats(uint(id), startPtr, endPtr, studentID)
+	stats, err := c.LevelService.GetAttemptStats(id, startPtr, endPtr, studentID)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -740,7 +860,7 @@ func (c *LevelController) GetAttemptStats(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param body body object true "ids, publish"
+// @Param body body object true "ids, publish, force（跳过发布前校验）"
 // @Success 200 {object} util.Response
 // @Router /api/teacher/levels/bulk/publish [post]
 func (c *LevelController) BulkPublish(ctx *gin.Context) {
@@ -758,14 +878,23 @@ func (c *LevelController) BulkPublish(ctx *gin.Context) {
 	var body struct {
 		IDs     []uint `json:"ids" binding:"required"`
 		Publish bool   `json:"publish"`
+		Force   bool   `json:"force"`
 	}
 	if err := ctx.ShouldBindJSON(&body); err != nil {
 		util.BadRequest(ctx, err.Error())
 		return
 	}
 
-	if err := c.LevelService.BulkPublish(user.UserID, body.IDs, body.Publish); err != nil {
-		logger.Log.Error("Bulk publish error", zap.Error(err))
+	if failed, err := c.LevelService.BulkPublish(user.UserID, user.Role, body.IDs, body.Publish, body.Force); err != nil {
+		if errors.Is(err, util.ErrPermissionDenied) {
+			util.Forbidden(ctx)
+			return
+		}
+		if errors.Is(err, util.ErrLevelValidationFailed) {
+			util.ErrorWithData(ctx, http.StatusBadRequest, err.Error(), gin.H{"failed": failed})
+			return
+		}
+		logger.FromContext(ctx.Request.Context()).Error("Bulk publish error", zap.Error(err))
 		util.InternalServerError(ctx)
 		return
 	}
@@ -787,10 +916,8 @@ func (c *LevelController) SchedulePublish(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 	var body struct {
@@ -809,7 +936,7 @@ func (c *LevelController) SchedulePublish(ctx *gin.Context) {
 		}
 		tPtr = &t
 	}
-	if err := c.LevelService.SchedulePublish(user.UserID, uint(id), tPtr); err != nil {
+	if err := c.LevelService.SchedulePublish(user.UserID, id, tPtr); err != nil {
 		util.InternalServerError(ctx)
 		return
 	}
@@ -831,10 +958,8 @@ func (c *LevelController) UpdateVisibility(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 	var body struct {
@@ -845,7 +970,11 @@ func (c *LevelController) UpdateVisibility(ctx *gin.Context) {
 		util.BadRequest(ctx, err.Error())
 		return
 	}
-	if err := c.LevelService.UpdateVisibility(user.UserID, uint(id), body.VisibleScope, body.VisibleTo); err != nil {
+	if err := c.LevelService.UpdateVisibility(user.UserID, id, body.VisibleScope, body.VisibleTo); err != nil {
+		if errors.Is(err, util.ErrVisibleToRequired) || errors.Is(err, util.ErrInvalidVisibleTo) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
@@ -868,13 +997,11 @@ func (c *LevelController) StartAttempt(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
-	attempt, err := c.LevelService.StartAttempt(user.UserID, uint(id))
+	attempt, err := c.LevelService.StartAttempt(user.UserID, id)
 	if err != nil {
 		if errors.Is(err, util.ErrAttemptLimitReached) {
 			util.Error(ctx, http.StatusOK, err.Error())
@@ -902,16 +1029,12 @@ func (c *LevelController) SubmitAttempt(ctx *gin.Context) {
 		util.Unauthorized(ctx)
 		return
 	}
-	idStr := ctx.Param("id")
-	_, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	levelID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
-	attStr := ctx.Param("attemptId")
-	attID, err := strconv.Atoi(attStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid attempt id")
+	attID, ok := util.ParseUintParam(ctx, "attemptId")
+	if !ok {
 		return
 	}
 	var body struct {
@@ -922,8 +1045,7 @@ func (c *LevelController) SubmitAttempt(ctx *gin.Context) {
 		util.BadRequest(ctx, err.Error())
 		return
 	}
-	levelID, _ := strconv.ParseUint(idStr, 10, 32)
-	attempt, err := c.LevelService.SubmitAttempt(user.UserID, uint(levelID), uint(attID), body.Answers, body.Times)
+	attempt, err := c.LevelService.SubmitAttempt(user.UserID, levelID, attID, body.Answers, body.Times)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -983,14 +1105,12 @@ func (c *LevelController) GetAllLevelsBasicInfo(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/users/{userId}/level-total-score [get]
 func (c *LevelController) GetUserLevelTotalScore(ctx *gin.Context) {
-	userIDStr := ctx.Param("userId")
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid user id")
+	userID, ok := util.ParseUintParam(ctx, "userId")
+	if !ok {
 		return
 	}
 
-	totalScore, err := c.LevelService.GetUserLevelTotalScore(uint(userID))
+	totalScore, err := c.LevelService.GetUserLevelTotalScore(userID)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -1009,14 +1129,12 @@ func (c *LevelController) GetUserLevelTotalScore(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/users/{userId}/level-stats [get]
 func (c *LevelController) GetUserLevelStats(ctx *gin.Context) {
-	userIDStr := ctx.Param("userId")
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid user id")
+	userID, ok := util.ParseUintParam(ctx, "userId")
+	if !ok {
 		return
 	}
 
-	stats, err := c.LevelService.GetUserLevelStats(uint(userID))
+	stats, err := c.LevelService.GetUserLevelStats(userID)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -6,7 +6,6 @@ import (
 	"coder_edu_backend/internal/util"
 	"coder_edu_backend/pkg/logger"
 	"io/ioutil"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -95,15 +94,13 @@ func (c *MotivationController) CreateMotivation(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/admin/motivations/{id} [put]
 func (c *MotivationController) UpdateMotivation(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "无效的ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
 	body, _ := ioutil.ReadAll(ctx.Request.Body)
-	logger.Log.Debug("原始请求体", zap.String("body", string(body)))
+	logger.FromContext(ctx.Request.Context()).Debug("原始请求体", zap.String("body", string(body)))
 
 	ctx.Request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
 
@@ -122,7 +119,7 @@ func (c *MotivationController) UpdateMotivation(ctx *gin.Context) {
 		return
 	}
 
-	err = c.MotivationService.UpdateMotivation(uint(id), req.Content, *req.IsEnabled)
+	err := c.MotivationService.UpdateMotivation(id, req.Content, *req.IsEnabled)
 	if err != nil {
 		util.BadRequest(ctx, err.Error())
 		return
@@ -141,14 +138,12 @@ func (c *MotivationController) UpdateMotivation(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/admin/motivations/{id} [delete]
 func (c *MotivationController) DeleteMotivation(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "无效的ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	err = c.MotivationService.DeleteMotivation(uint(id))
+	err := c.MotivationService.DeleteMotivation(id)
 	if err != nil {
 		util.BadRequest(ctx, err.Error())
 		return
@@ -167,14 +162,12 @@ func (c *MotivationController) DeleteMotivation(ctx *gin.Context) {
 // @Success 200 {object} util.Response
 // @Router /api/admin/motivations/{id}/switch [post]
 func (c *MotivationController) SwitchMotivation(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "无效的ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	err = c.MotivationService.SwitchToMotivation(uint(id))
+	err := c.MotivationService.SwitchToMotivation(id)
 	if err != nil {
 		util.BadRequest(ctx, err.Error())
 		return
@@ -182,3 +175,50 @@ func (c *MotivationController) SwitchMotivation(ctx *gin.Context) {
 
 	util.Success(ctx, gin.H{"message": "激励短句切换成功"})
 }
+
+// UpdateMotivationScheduleRequest 更新激励短句自动轮换设置，字段为空时保持不变
+type UpdateMotivationScheduleRequest struct {
+	AutoRotate *bool `json:"autoRotate" example:"true"`
+	PinnedID   *uint `json:"pinnedId" example:"3"` // 置顶的短句ID，0 表示取消置顶
+}
+
+// @Summary 获取激励短句轮换设置
+// @Description 返回是否自动轮换、置顶的短句、上次轮换时间以及配置的每日轮换时刻（管理员权限）
+// @Tags 激励短句
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} util.Response{data=service.MotivationScheduleResponse}
+// @Router /api/admin/motivations/schedule [get]
+func (c *MotivationController) GetSchedule(ctx *gin.Context) {
+	schedule, err := c.MotivationService.GetSchedule()
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, schedule)
+}
+
+// @Summary 更新激励短句轮换设置
+// @Description 开启或关闭每日自动轮换，或置顶某条短句（置顶后立即切换且不再自动轮换，pinnedId 传 0 取消置顶）（管理员权限）
+// @Tags 激励短句
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdateMotivationScheduleRequest true "轮换设置"
+// @Success 200 {object} util.Response{data=service.MotivationScheduleResponse}
+// @Failure 400 {object} util.Response
+// @Router /api/admin/motivations/schedule [put]
+func (c *MotivationController) UpdateSchedule(ctx *gin.Context) {
+	var req UpdateMotivationScheduleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+
+	schedule, err := c.MotivationService.UpdateSchedule(req.AutoRotate, req.PinnedID)
+	if err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+	util.Success(ctx, schedule)
+}
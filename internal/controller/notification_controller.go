@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"coder_edu_backend/internal/service"
+	"coder_edu_backend/internal/util"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationController struct {
+	NotificationService *service.NotificationService
+}
+
+func NewNotificationController(notificationService *service.NotificationService) *NotificationController {
+	return &NotificationController{NotificationService: notificationService}
+}
+
+// UpdateNotificationPreferencesRequest 更新通知开关请求，键为通知类型
+type UpdateNotificationPreferencesRequest struct {
+	Preferences map[string]bool `json:"preferences" binding:"required" example:"mention:false"`
+}
+
+// @Summary 获取我的通知
+// @Description 按时间倒序返回站内通知（好友申请、@ 提及、评分结果、公告、学习目标、反思评论等），附带未读总数
+// @Tags 通知
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(20)
+// @Param unread query bool false "仅返回未读"
+// @Success 200 {object} util.Response{data=service.NotificationList}
+// @Router /api/notifications [get]
+func (c *NotificationController) ListNotifications(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	unreadOnly := ctx.Query("unread") == "true"
+
+	list, err := c.NotificationService.List(user.UserID, unreadOnly, limit, (page-1)*limit)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, list)
+}
+
+// @Summary 标记通知已读
+// @Tags 通知
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "通知ID"
+// @Success 200 {object} util.Response
+// @Failure 404 {object} util.Response
+// @Router /api/notifications/{id}/read [put]
+func (c *NotificationController) MarkRead(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if err := c.NotificationService.MarkRead(user.UserID, id); err != nil {
+		if errors.Is(err, util.ErrNotificationNotFound) {
+			util.NotFound(ctx)
+			return
+		}
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, nil)
+}
+
+// @Summary 全部通知标记已读
+// @Tags 通知
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} util.Response
+// @Router /api/notifications/read-all [put]
+func (c *NotificationController) MarkAllRead(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	updated, err := c.NotificationService.MarkAllRead(user.UserID)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, gin.H{"updated": updated})
+}
+
+// @Summary 获取通知开关
+// @Description 返回每种通知类型是否开启，未设置过的类型默认开启
+// @Tags 通知
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} util.Response{data=[]model.NotificationPreference}
+// @Router /api/notifications/preferences [get]
+func (c *NotificationController) GetPreferences(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	prefs, err := c.NotificationService.GetPreferences(user.UserID)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, prefs)
+}
+
+// @Summary 更新通知开关
+// @Description 按类型开启或关闭通知，类型取值：friend_request、mention、submission_graded、announcement、goal、reflection。关闭后该类事件不再写入通知中心
+// @Tags 通知
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body UpdateNotificationPreferencesRequest true "通知开关"
+// @Success 200 {object} util.Response{data=[]model.NotificationPreference}
+// @Failure 400 {object} util.Response
+// @Router /api/notifications/preferences [put]
+func (c *NotificationController) UpdatePreferences(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	var req UpdateNotificationPreferencesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+
+	prefs, err := c.NotificationService.UpdatePreferences(user.UserID, req.Preferences)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidRequest) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, prefs)
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"coder_edu_backend/internal/service"
+	"coder_edu_backend/internal/util"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OwnershipController struct {
+	OwnershipService *service.OwnershipService
+}
+
+func NewOwnershipController(ownershipService *service.OwnershipService) *OwnershipController {
+	return &OwnershipController{OwnershipService: ownershipService}
+}
+
+// TransferOwnershipRequest 转移负责人请求
+type TransferOwnershipRequest struct {
+	NewOwnerID uint `json:"newOwnerId" binding:"required" example:"2"`
+}
+
+// @Summary 转移学习资源负责人（管理员）
+// @Description 将资源的上传者改为另一名教师或管理员，操作写入审计记录
+// @Tags 内容管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "资源ID"
+// @Param request body TransferOwnershipRequest true "新负责人"
+// @Success 200 {object} util.Response{data=service.OwnershipTransfer}
+// @Router /api/admin/resources/{id}/owner [put]
+func (c *OwnershipController) TransferResource(ctx *gin.Context) {
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	c.handleTransfer(ctx, func(actorID, newOwnerID uint) (*service.OwnershipTransfer, error) {
+		return c.OwnershipService.TransferResourceOwnership(actorID, id, newOwnerID)
+	})
+}
+
+// @Summary 转移社区资源作者（管理员）
+// @Description 将社区资源的作者改为另一名教师或管理员，操作写入审计记录
+// @Tags 社区
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "社区资源ID"
+// @Param request body TransferOwnershipRequest true "新负责人"
+// @Success 200 {object} util.Response{data=service.OwnershipTransfer}
+// @Router /api/admin/community/resources/{id}/owner [put]
+func (c *OwnershipController) TransferCommunityResource(ctx *gin.Context) {
+	id := ctx.Param("id")
+	c.handleTransfer(ctx, func(actorID, newOwnerID uint) (*service.OwnershipTransfer, error) {
+		return c.OwnershipService.TransferCommunityResourceOwnership(actorID, id, newOwnerID)
+	})
+}
+
+// @Summary 转移关卡创建者（管理员）
+// @Description 将关卡的创建者改为另一名教师或管理员，操作写入审计记录
+// @Tags 关卡管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "关卡ID"
+// @Param request body TransferOwnershipRequest true "新负责人"
+// @Success 200 {object} util.Response{data=service.OwnershipTransfer}
+// @Router /api/admin/levels/{id}/owner [put]
+func (c *OwnershipController) TransferLevel(ctx *gin.Context) {
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	c.handleTransfer(ctx, func(actorID, newOwnerID uint) (*service.OwnershipTransfer, error) {
+		return c.OwnershipService.TransferLevelOwnership(actorID, id, newOwnerID)
+	})
+}
+
+func (c *OwnershipController) handleTransfer(ctx *gin.Context, transfer func(actorID, newOwnerID uint) (*service.OwnershipTransfer, error)) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	var req TransferOwnershipRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+
+	result, err := transfer(user.UserID, req.NewOwnerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrResourceNotFound), errors.Is(err, util.ErrLevelNotFound):
+			util.NotFound(ctx)
+		case errors.Is(err, util.ErrUserNotFound):
+			util.BadRequest(ctx, "新负责人不存在")
+		case errors.Is(err, util.ErrInvalidRequest):
+			util.BadRequest(ctx, err.Error())
+		default:
+			util.InternalServerError(ctx)
+		}
+		return
+	}
+	util.Success(ctx, result)
+}
@@ -3,9 +3,11 @@ package controller
 import (
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type PostClassTestController struct {
@@ -274,6 +276,7 @@ func (c *PostClassTestController) DeleteTest(ctx *gin.Context) {
 }
 
 // @Summary 获取试卷答题情况列表
+// @Description 每条记录包含系统自动判分得分 auto_score，以及是否有待人工批改主观题的 needs_manual 标记
 // @Tags 课后测试模块
 // @Produce json
 // @Security BearerAuth
@@ -322,6 +325,54 @@ func (c *PostClassTestController) GetSubmissionDetail(ctx *gin.Context) {
 	util.Success(ctx, detail)
 }
 
+// GradeSubmissionRequest 人工批改主观题请求
+type GradeSubmissionRequest struct {
+	Scores []service.PostClassManualScore `json:"scores" binding:"required,min=1,dive"`
+}
+
+// @Summary 人工批改主观题
+// @Description 为提交中的简答等主观题打分（0 到题目分值），重新汇总总分；全部主观题批改后 needsManual 变为 false。
+// @Description 可重复提交以修改分数；教师只能批改自己创建的试卷
+// @Tags 课后测试模块
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "提交ID"
+// @Param body body GradeSubmissionRequest true "各题得分"
+// @Success 200 {object} util.Response{data=model.PostClassTestSubmission}
+// @Failure 400 {object} util.Response
+// @Failure 403 {object} util.Response
+// @Failure 404 {object} util.Response
+// @Router /api/teacher/post-class-tests/submissions/{id}/grade [post]
+func (c *PostClassTestController) GradeSubmission(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	var req GradeSubmissionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+
+	submission, err := c.Service.GradeSubmission(user.UserID, user.Role, ctx.Param("id"), req.Scores)
+	if err != nil {
+		switch {
+		case errors.Is(err, util.ErrPermissionDenied):
+			util.Forbidden(ctx)
+		case errors.Is(err, util.ErrInvalidRequest):
+			util.BadRequest(ctx, err.Error())
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			util.NotFound(ctx)
+		default:
+			util.LogInternalError(ctx, err)
+		}
+		return
+	}
+	util.Success(ctx, submission)
+}
+
 // @Summary 重置学生测试（支持单人或批量）
 // @Tags 课后测试模块
 // @Accept json
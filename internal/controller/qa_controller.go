@@ -55,7 +55,7 @@ func (c *QAController) Ask(ctx *gin.Context) {
 	}
 
 	// 流式响应，传入userID和sessionID支持多轮对话（传入请求 context 用于断开检测）
-	stream, source, errChan := c.qaService.AskStream(ctx.Request.Context(), userID, req.Question, req.SessionID)
+	stream, source, streamKey, errChan := c.qaService.AskStream(ctx.Request.Context(), userID, req.Question, req.SessionID)
 	if stream == nil {
 		// 处理 AskStream 返回 nil 的情况（如触发敏感词）
 		if err := <-errChan; err != nil {
@@ -70,8 +70,9 @@ func (c *QAController) Ask(ctx *gin.Context) {
 	ctx.Header("Connection", "keep-alive")
 	ctx.Header("Transfer-Encoding", "chunked")
 
-	// 实时发送源信息
+	// 实时发送源信息及流标识（用于 /api/qa/stop 主动停止）
 	ctx.SSEvent("source", source)
+	ctx.SSEvent("stream", gin.H{"streamKey": streamKey})
 	ctx.Writer.Flush()
 
 	// 处理流式响应，支持客户端断开检测
@@ -104,6 +105,48 @@ func (c *QAController) Ask(ctx *gin.Context) {
 	})
 }
 
+// StopRequest 停止生成请求
+type StopRequest struct {
+	StreamKey string `json:"streamKey" binding:"required"`
+}
+
+// Stop 主动停止进行中的问答生成
+// @Summary 停止 AI 问答生成
+// @Description 根据 Ask 接口 stream 事件返回的 streamKey 停止生成，已生成的部分会以"[已停止生成]"标记保存
+// @Tags QA
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body StopRequest true "流标识"
+// @Success 200 {object} gin.H
+// @Router /api/qa/stop [post]
+func (c *QAController) Stop(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+	claims := user.(*util.Claims)
+	userID := claims.UserID
+
+	var req StopRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.qaService.StopStream(userID, req.StreamKey); err != nil {
+		if err == util.ErrQAStreamNotFound {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "已停止生成"})
+}
+
 // GetHistory 获取 AI 问答历史记录
 // @Summary 获取 AI 问答历史
 // @Tags QA
@@ -187,6 +230,45 @@ func (c *QAController) GetHistoryDetail(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, histories)
 }
 
+// GetSession 获取单个会话的完整对话
+// @Summary 获取会话完整对话
+// @Description 按时间正序返回会话内的全部问答，并以首个问题作为会话标题
+// @Tags QA
+// @Security ApiKeyAuth
+// @Param sessionId path string true "会话 ID"
+// @Success 200 {object} service.QASessionThread
+// @Router /api/qa/sessions/{sessionId} [get]
+func (c *QAController) GetSession(ctx *gin.Context) {
+	user, exists := ctx.Get("user")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+	claims := user.(*util.Claims)
+	userID := claims.UserID
+	sessionID := ctx.Param("sessionId")
+
+	if sessionID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "sessionId 不能为空"})
+		return
+	}
+
+	thread, err := c.qaService.GetSessionThread(userID, sessionID)
+	if err != nil {
+		switch err {
+		case util.ErrQASessionForbidden:
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case util.ErrQASessionNotFound:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, thread)
+}
+
 // DeleteSession 删除指定会话的所有历史记录
 // @Summary 删除 AI 问答会话
 // @Description 根据 sessionId 删除该会话的所有历史记录
@@ -220,10 +302,11 @@ func (c *QAController) DeleteSession(ctx *gin.Context) {
 
 // GetWeeklyReport 获取学习周报 (SSE)
 // @Summary 获取学习周报
-// @Description 生成并获取用户的学习周报，采用 SSE 流式返回
+// @Description 生成并获取用户的学习周报，采用 SSE 流式返回。同一周内的周报会被缓存，先推送 meta 事件（生成时间、是否命中缓存）
 // @Tags QA
 // @Security ApiKeyAuth
 // @Produce text/event-stream
+// @Param force query bool false "是否强制重新生成"
 // @Success 200 {string} string "SSE stream"
 // @Router /api/qa/report/weekly [get]
 func (c *QAController) GetWeeklyReport(ctx *gin.Context) {
@@ -235,27 +318,36 @@ func (c *QAController) GetWeeklyReport(ctx *gin.Context) {
 	claims := user.(*util.Claims)
 	userID := claims.UserID
 
-	out, errChan := c.qaService.GenerateWeeklyReport(userID)
+	force := ctx.Query("force") == "true"
+	out, errChan, meta := c.qaService.GenerateWeeklyReport(ctx.Request.Context(), userID, force)
 
 	ctx.Header("Content-Type", "text/event-stream")
 	ctx.Header("Cache-Control", "no-cache")
 	ctx.Header("Connection", "keep-alive")
 	ctx.Header("Transfer-Encoding", "chunked")
 
+	ctx.SSEvent("meta", meta)
+	ctx.Writer.Flush()
+
+	streamReport(ctx, out, errChan)
+}
+
+// streamReport 转发周报内容：先读完 out，out 关闭后再读取 errChan。
+// 生成结束时 out 关闭与 errChan 关闭（或写入错误）同时就绪，若在同一个 select 中等待会随机命中 errChan，丢失内容或错误
+func streamReport(ctx *gin.Context, out <-chan string, errChan <-chan error) {
 	ctx.Stream(func(w io.Writer) bool {
 		select {
 		case content, ok := <-out:
 			if !ok {
+				if err := <-errChan; err != nil {
+					ctx.SSEvent("error", err.Error())
+					return false
+				}
 				ctx.SSEvent("message", "[DONE]")
 				return false
 			}
 			ctx.SSEvent("message", content)
 			return true
-		case err := <-errChan:
-			if err != nil {
-				ctx.SSEvent("error", err.Error())
-			}
-			return false
 		case <-ctx.Request.Context().Done():
 			return false
 		}
@@ -267,6 +359,8 @@ type DiagnoseRequest struct {
 	QuestionID    uint   `json:"questionId" binding:"required"`
 	Code          string `json:"code" binding:"required"`
 	CompilerError string `json:"compilerError"`
+	ErrorType     string `json:"errorType"` // 运行接口返回的 errorType，如 time_limit_exceeded
+	Language      string `json:"language"`  // c、cpp、python，默认 c
 }
 
 // @Summary AI 代码自动诊断
@@ -293,7 +387,7 @@ func (c *QAController) DiagnoseCode(ctx *gin.Context) {
 		return
 	}
 
-	out, errChan := c.qaService.DiagnoseCode(userID, req.QuestionID, req.Code, req.CompilerError)
+	out, errChan := c.qaService.DiagnoseCode(userID, req.QuestionID, req.Code, req.CompilerError, req.ErrorType, req.Language)
 
 	ctx.Header("Content-Type", "text/event-stream")
 	ctx.Header("Cache-Control", "no-cache")
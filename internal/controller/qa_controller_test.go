@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamRecorder 为 httptest.ResponseRecorder 补上 gin.Context.Stream 需要的 CloseNotify
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (streamRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func serveReportStream(out <-chan string, errChan <-chan error) string {
+	gin.SetMode(gin.TestMode)
+	w := streamRecorder{httptest.NewRecorder()}
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/report", nil)
+	streamReport(ctx, out, errChan)
+	return w.Body.String()
+}
+
+// TestStreamReportCachedReplay 命中缓存时 out 与 errChan 都已关闭，反复回放必须每次都输出内容和 [DONE]
+func TestStreamReportCachedReplay(t *testing.T) {
+	for i := 0; i < 500; i++ {
+		out := make(chan string, 1)
+		errChan := make(chan error, 1)
+		out <- "# 本周学习周报"
+		close(out)
+		close(errChan)
+
+		body := serveReportStream(out, errChan)
+		if !strings.Contains(body, "# 本周学习周报") || !strings.Contains(body, "[DONE]") {
+			t.Fatalf("replay %d: body = %q, want report content followed by [DONE]", i, body)
+		}
+	}
+}
+
+// TestStreamReportLiveError 生成失败时错误与 out 关闭同时就绪，错误不能被吞掉
+func TestStreamReportLiveError(t *testing.T) {
+	for i := 0; i < 500; i++ {
+		out := make(chan string)
+		errChan := make(chan error, 1)
+		errChan <- errors.New("upstream failed")
+		close(out)
+		close(errChan)
+
+		body := serveReportStream(out, errChan)
+		if !strings.Contains(body, "upstream failed") || strings.Contains(body, "[DONE]") {
+			t.Fatalf("run %d: body = %q, want error event without [DONE]", i, body)
+		}
+	}
+}
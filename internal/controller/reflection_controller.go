@@ -3,6 +3,7 @@ package controller
 import (
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -23,6 +24,11 @@ type SaveReflectionRequest struct {
 	NextSteps   string `json:"nextSteps"`
 }
 
+type AddReflectionCommentRequest struct {
+	Content  string `json:"content" binding:"required,max=2000"`
+	ParentID *uint  `json:"parentId"` // 回复的评论ID，为空表示直接评论反思
+}
+
 // SaveReflection godoc
 // @Summary 学生保存或更新有效反思
 // @Description 学生填写总结关键知识点、识别挑战、连接已有知识、规划下一步
@@ -109,35 +115,111 @@ func (c *ReflectionController) ListAllReflections(ctx *gin.Context) {
 	})
 }
 
-// UpdateReflection godoc
-// @Summary 老师/管理员修改有效反思数据
+// GetMyReflectionComments godoc
+// @Summary 获取我的反思收到的反馈
+// @Description 按时间顺序返回老师的反馈和自己的回复，parentId 表示回复的评论
+// @Tags 有效反思
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} util.Response{data=[]model.ReflectionComment}
+// @Router /api/reflections/my/comments [get]
+func (c *ReflectionController) GetMyReflectionComments(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	comments, err := c.service.ListComments(user.UserID)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, comments)
+}
+
+// AddMyReflectionComment godoc
+// @Summary 回复反思的反馈
+// @Description 学生在自己的反思下留言或回复老师的反馈，评论只能追加不能修改，在该反思下留言过的老师会收到通知
 // @Tags 有效反思
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
+// @Param body body AddReflectionCommentRequest true "评论内容"
+// @Success 200 {object} util.Response{data=model.ReflectionComment}
+// @Failure 404 {object} util.Response "尚未填写反思或回复的评论不存在"
+// @Router /api/reflections/my/comments [post]
+func (c *ReflectionController) AddMyReflectionComment(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	c.addComment(ctx, user.UserID, user)
+}
+
+// GetReflectionComments godoc
+// @Summary 老师/管理员获取学生反思的评论
+// @Tags 有效反思
+// @Produce json
+// @Security ApiKeyAuth
 // @Param userId path int true "用户ID"
-// @Param body body SaveReflectionRequest true "反思内容"
-// @Success 200 {object} util.Response{data=model.Reflection}
-// @Router /api/teacher/reflections/user/{userId} [put]
-func (c *ReflectionController) UpdateReflection(ctx *gin.Context) {
-	userIDStr := ctx.Param("userId")
-	userID, err := strconv.Atoi(userIDStr)
+// @Success 200 {object} util.Response{data=[]model.ReflectionComment}
+// @Router /api/teacher/reflections/user/{userId}/comments [get]
+func (c *ReflectionController) GetReflectionComments(ctx *gin.Context) {
+	userID, ok := util.ParseUintParam(ctx, "userId")
+	if !ok {
+		return
+	}
+
+	comments, err := c.service.ListComments(userID)
 	if err != nil {
-		util.BadRequest(ctx, "无效的用户ID")
+		util.InternalServerError(ctx)
 		return
 	}
+	util.Success(ctx, comments)
+}
 
-	var req SaveReflectionRequest
+// AddReflectionComment godoc
+// @Summary 老师/管理员反馈学生的反思
+// @Description 在学生反思下追加反馈，学生会收到通知。反思正文仍由学生维护，评论只能追加不能修改
+// @Tags 有效反思
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param userId path int true "用户ID"
+// @Param body body AddReflectionCommentRequest true "评论内容"
+// @Success 200 {object} util.Response{data=model.ReflectionComment}
+// @Failure 404 {object} util.Response "学生尚未填写反思或回复的评论不存在"
+// @Router /api/teacher/reflections/user/{userId}/comments [post]
+func (c *ReflectionController) AddReflectionComment(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	userID, ok := util.ParseUintParam(ctx, "userId")
+	if !ok {
+		return
+	}
+	c.addComment(ctx, userID, user)
+}
+
+func (c *ReflectionController) addComment(ctx *gin.Context, ownerID uint, author *util.Claims) {
+	var req AddReflectionCommentRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		util.BadRequest(ctx, err.Error())
 		return
 	}
 
-	reflection, err := c.service.UpdateReflectionByUserID(uint(userID), req.Summary, req.Challenges, req.Connections, req.NextSteps)
+	comment, err := c.service.AddComment(ownerID, author.UserID, author.Role, req.Content, req.ParentID)
 	if err != nil {
+		if errors.Is(err, util.ErrReflectionNotFound) || errors.Is(err, util.ErrCommentNotFound) {
+			util.Error(ctx, 404, err.Error())
+			return
+		}
 		util.InternalServerError(ctx)
 		return
 	}
-
-	util.Success(ctx, reflection)
+	util.Success(ctx, comment)
 }
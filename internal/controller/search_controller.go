@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"coder_edu_backend/internal/service"
+	"coder_edu_backend/internal/util"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchController struct {
+	SearchService *service.SearchService
+}
+
+func NewSearchController(searchService *service.SearchService) *SearchController {
+	return &SearchController{SearchService: searchService}
+}
+
+// @Summary 全站搜索学习内容
+// @Description 在知识点、C 语言文章、关卡和社区帖子中搜索，结果带类型标记和跳转路径。只返回学生可见的内容，每种类型最多 20 条，并按同类排名交替合并
+// @Tags 搜索
+// @Security BearerAuth
+// @Produce json
+// @Param q query string true "搜索关键词，多个关键词用空格分隔"
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(20)
+// @Success 200 {object} util.Response{data=service.SearchResponse}
+// @Failure 400 {object} util.Response
+// @Router /api/search [get]
+func (c *SearchController) Search(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	q := strings.TrimSpace(ctx.Query("q"))
+	if q == "" {
+		util.BadRequest(ctx, "搜索关键词不能为空")
+		return
+	}
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	result, err := c.SearchService.Search(user.UserID, q, page, limit)
+	if err != nil {
+		util.LogInternalError(ctx, err)
+		return
+	}
+	util.Success(ctx, result)
+}
@@ -63,10 +63,8 @@ func (c *SuggestionController) UpdateSuggestion(ctx *gin.Context) {
 		return
 	}
 
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -76,7 +74,7 @@ func (c *SuggestionController) UpdateSuggestion(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.SuggestionService.UpdateSuggestion(uint(id), user.UserID, &suggestion); err != nil {
+	if err := c.SuggestionService.UpdateSuggestion(id, user.UserID, &suggestion); err != nil {
 		util.InternalServerError(ctx)
 		return
 	}
@@ -120,14 +118,12 @@ func (c *SuggestionController) DeleteSuggestion(ctx *gin.Context) {
 		return
 	}
 
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	if err := c.SuggestionService.DeleteSuggestion(uint(id), user.UserID); err != nil {
+	if err := c.SuggestionService.DeleteSuggestion(id, user.UserID); err != nil {
 		util.InternalServerError(ctx)
 		return
 	}
@@ -171,14 +167,12 @@ func (c *SuggestionController) CompleteSuggestion(ctx *gin.Context) {
 		return
 	}
 
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid id")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	if err := c.SuggestionService.CompleteSuggestion(uint(id), user.UserID); err != nil {
+	if err := c.SuggestionService.CompleteSuggestion(id, user.UserID); err != nil {
 		util.InternalServerError(ctx)
 		return
 	}
@@ -200,14 +194,12 @@ func (c *SuggestionController) GetStudentProgress(ctx *gin.Context) {
 		return
 	}
 
-	studentIDStr := ctx.Param("id")
-	studentID, err := strconv.Atoi(studentIDStr)
-	if err != nil {
-		util.BadRequest(ctx, "invalid student id")
+	studentID, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	progress, err := c.SuggestionService.GetStudentProgressForTeacher(uint(studentID))
+	progress, err := c.SuggestionService.GetStudentProgressForTeacher(studentID)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -36,6 +36,20 @@ type SetWeeklyTaskRequest struct {
 	WeeklyTasks []TaskModuleGroup `json:"weekly_tasks" binding:"required"`
 }
 
+// UpdateWeeklyTaskItemsRequest 修改周任务内容请求
+// swagger:model UpdateWeeklyTaskItemsRequest
+type UpdateWeeklyTaskItemsRequest struct {
+	TaskItems []model.TaskItem `json:"taskItems" binding:"required"`
+}
+
+// SaveWeeklyTaskTemplateRequest 保存周任务模板请求
+// swagger:model SaveWeeklyTaskTemplateRequest
+type SaveWeeklyTaskTemplateRequest struct {
+	ResourceModuleID uint                           `json:"resourceModuleId" binding:"required"`
+	Items            []model.WeeklyTaskTemplateItem `json:"items" binding:"required,min=1"`
+	AutoInstantiate  bool                           `json:"autoInstantiate"`
+}
+
 // GetWeeklyTasksRequest 定义获取周任务列表请求参数
 // swagger:model GetWeeklyTasksRequest
 type GetWeeklyTasksRequest struct {
@@ -156,6 +170,35 @@ func (c *TaskController) GetTodayTasks(ctx *gin.Context) {
 	})
 }
 
+// GetWeeklySummary godoc
+// @Summary 获取周任务完成汇总
+// @Description 返回最近若干周的周任务完成率，以及连续完成全部任务的周数。完成某周全部任务时会发放一次积分奖励
+// @Tags 任务管理
+// @Produce json
+// @Security BearerAuth
+// @Param weeks query int false "返回的周数，默认4，最大52"
+// @Success 200 {object} util.Response{data=service.TaskCompletionSummary} "成功"
+// @Failure 401 {object} util.Response "未授权"
+// @Failure 500 {object} util.Response "服务器内部错误"
+// @Router /api/tasks/weekly/summary [get]
+func (c *TaskController) GetWeeklySummary(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	weeks, _ := strconv.Atoi(ctx.DefaultQuery("weeks", "4"))
+
+	summary, err := c.TaskService.GetWeeklySummary(user.UserID, weeks)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+
+	util.Success(ctx, summary)
+}
+
 // UpdateTaskCompletionRequest 定义任务完成状态更新请求模型
 // swagger:model UpdateTaskCompletionRequest
 type UpdateTaskCompletionRequest struct {
@@ -185,10 +228,8 @@ func (c *TaskController) UpdateTaskCompletion(ctx *gin.Context) {
 		return
 	}
 
-	taskItemIDStr := ctx.Param("taskItemId")
-	taskItemID, err := strconv.ParseUint(taskItemIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "任务项ID无效")
+	taskItemID, ok := util.ParseUintParam(ctx, "taskItemId")
+	if !ok {
 		return
 	}
 
@@ -199,7 +240,7 @@ func (c *TaskController) UpdateTaskCompletion(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.TaskService.UpdateTaskCompletion(user.UserID, uint(taskItemID),
+	if err := c.TaskService.UpdateTaskCompletion(user.UserID, taskItemID,
 		request.IsCompleted, request.Progress, request.ResourceCompleted); err != nil {
 		util.BadRequest(ctx, err.Error())
 		return
@@ -341,14 +382,12 @@ func (c *TaskController) DeleteWeeklyTask(ctx *gin.Context) {
 		return
 	}
 
-	taskIDStr := ctx.Param("taskId")
-	taskID, err := strconv.ParseUint(taskIDStr, 10, 32)
-	if err != nil {
-		util.BadRequest(ctx, "任务ID无效")
+	taskID, ok := util.ParseUintParam(ctx, "taskId")
+	if !ok {
 		return
 	}
 
-	err = c.TaskService.DeleteWeeklyTask(uint(taskID), user.UserID)
+	err := c.TaskService.DeleteWeeklyTask(taskID, user.UserID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			util.Error(ctx, http.StatusNotFound, "任务不存在或无权删除")
@@ -362,3 +401,180 @@ func (c *TaskController) DeleteWeeklyTask(ctx *gin.Context) {
 		"message": "周任务删除成功",
 	})
 }
+
+// UpdateWeeklyTaskItems godoc
+// @Summary 修改周任务内容
+// @Description 替换指定周任务的全部任务项，可在发布前调整模板自动生成的草稿
+// @Tags 任务管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param taskId path int true "周任务ID"
+// @Param request body UpdateWeeklyTaskItemsRequest true "任务项"
+// @Success 200 {object} util.Response{data=model.TeacherWeeklyTask} "成功"
+// @Failure 400 {object} util.Response "请求参数错误"
+// @Failure 403 {object} util.Response "权限不足"
+// @Failure 404 {object} util.Response "任务不存在或无权修改"
+// @Router /api/teacher/tasks/weekly/{taskId} [put]
+func (c *TaskController) UpdateWeeklyTaskItems(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil || (user.Role != model.Teacher && user.Role != model.Admin) {
+		util.Forbidden(ctx)
+		return
+	}
+
+	taskID, ok := util.ParseUintParam(ctx, "taskId")
+	if !ok {
+		return
+	}
+
+	var request UpdateWeeklyTaskItemsRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+
+	task, err := c.TaskService.UpdateWeeklyTaskItems(taskID, user.UserID, request.TaskItems)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			util.Error(ctx, http.StatusNotFound, "任务不存在或无权修改")
+			return
+		}
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+
+	util.Success(ctx, task)
+}
+
+// PublishWeeklyTask godoc
+// @Summary 发布周任务草稿
+// @Description 模板自动生成的周任务为草稿，发布后学生才能看到
+// @Tags 任务管理
+// @Produce json
+// @Security BearerAuth
+// @Param taskId path int true "周任务ID"
+// @Success 200 {object} util.Response "成功"
+// @Failure 403 {object} util.Response "权限不足"
+// @Failure 404 {object} util.Response "任务不存在或无权发布"
+// @Router /api/teacher/tasks/weekly/{taskId}/publish [put]
+func (c *TaskController) PublishWeeklyTask(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil || (user.Role != model.Teacher && user.Role != model.Admin) {
+		util.Forbidden(ctx)
+		return
+	}
+
+	taskID, ok := util.ParseUintParam(ctx, "taskId")
+	if !ok {
+		return
+	}
+
+	if err := c.TaskService.PublishWeeklyTask(taskID, user.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			util.Error(ctx, http.StatusNotFound, "任务不存在或无权发布")
+			return
+		}
+		util.InternalServerError(ctx)
+		return
+	}
+
+	util.Success(ctx, gin.H{
+		"message": "周任务已发布",
+	})
+}
+
+// SaveWeeklyTaskTemplate godoc
+// @Summary 保存周任务模板
+// @Description 为资源分类保存每周重复的任务模板，同一分类重复保存会覆盖原模板。开启 autoInstantiate 后，系统每周按模板生成下一周的周任务草稿，老师预览、修改并发布后学生可见
+// @Tags 任务管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body SaveWeeklyTaskTemplateRequest true "周任务模板"
+// @Success 200 {object} util.Response{data=model.WeeklyTaskTemplate} "成功"
+// @Failure 400 {object} util.Response "请求参数错误"
+// @Failure 403 {object} util.Response "权限不足"
+// @Router /api/teacher/tasks/weekly/template [post]
+func (c *TaskController) SaveWeeklyTaskTemplate(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil || (user.Role != model.Teacher && user.Role != model.Admin) {
+		util.Forbidden(ctx)
+		return
+	}
+
+	var request SaveWeeklyTaskTemplateRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+
+	template, err := c.TaskService.SaveWeeklyTaskTemplate(user.UserID, request.ResourceModuleID, request.Items, request.AutoInstantiate)
+	if err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+
+	util.Success(ctx, template)
+}
+
+// ListWeeklyTaskTemplates godoc
+// @Summary 获取周任务模板列表
+// @Tags 任务管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} util.Response{data=[]model.WeeklyTaskTemplate} "成功"
+// @Failure 403 {object} util.Response "权限不足"
+// @Router /api/teacher/tasks/weekly/templates [get]
+func (c *TaskController) ListWeeklyTaskTemplates(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil || (user.Role != model.Teacher && user.Role != model.Admin) {
+		util.Forbidden(ctx)
+		return
+	}
+
+	templates, err := c.TaskService.ListWeeklyTaskTemplates(user.UserID)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+
+	util.Success(ctx, templates)
+}
+
+// DeleteWeeklyTaskTemplate godoc
+// @Summary 删除周任务模板
+// @Description 删除模板后不再自动生成，已生成的周任务保留
+// @Tags 任务管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "模板ID"
+// @Success 200 {object} util.Response "成功"
+// @Failure 403 {object} util.Response "权限不足"
+// @Failure 404 {object} util.Response "模板不存在或无权删除"
+// @Router /api/teacher/tasks/weekly/template/{id} [delete]
+func (c *TaskController) DeleteWeeklyTaskTemplate(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil || (user.Role != model.Teacher && user.Role != model.Admin) {
+		util.Forbidden(ctx)
+		return
+	}
+
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+
+	if err := c.TaskService.DeleteWeeklyTaskTemplate(id, user.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			util.Error(ctx, http.StatusNotFound, "模板不存在或无权删除")
+			return
+		}
+		util.InternalServerError(ctx)
+		return
+	}
+
+	util.Success(ctx, gin.H{
+		"message": "周任务模板删除成功",
+	})
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"coder_edu_backend/internal/service"
+	"coder_edu_backend/internal/util"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UnreadController struct {
+	UnreadService *service.UnreadService
+}
+
+func NewUnreadController(unreadService *service.UnreadService) *UnreadController {
+	return &UnreadController{UnreadService: unreadService}
+}
+
+// @Summary 获取全局未读数
+// @Description 汇总所有会话的未读消息数（按已读位置计算，不含自己发送的和系统消息）、未读 @ 提及和未读通知，用于全局角标。
+// @Description 未读数变化时在线用户会收到 WebSocket UNREAD_SUMMARY 推送，数据结构与本接口相同
+// @Tags 通知
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} util.Response{data=service.UnreadSummary}
+// @Router /api/unread-summary [get]
+func (c *UnreadController) GetUnreadSummary(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	summary, err := c.UnreadService.GetUnreadSummary(user.UserID)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, summary)
+}
@@ -5,15 +5,18 @@ import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/service"
 	"coder_edu_backend/internal/util"
+	"coder_edu_backend/pkg/logger"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // UserController 处理用户相关的HTTP请求
@@ -43,10 +46,25 @@ type UpdateUserRequest struct {
 	Disabled bool   `json:"disabled"`
 }
 
+// BulkUserRequest 批量用户操作请求
+type BulkUserRequest struct {
+	UserIDs []uint `json:"userIds" binding:"required,min=1,max=200"`
+	Action  string `json:"action" binding:"required,oneof=disable enable delete reset-password set-role"`
+	Role    string `json:"role" binding:"omitempty,oneof=student teacher admin"` // action 为 set-role 时必填
+}
+
+// ResetPasswordRequest 管理员重置密码请求，Password 为空时生成随机临时密码
+type ResetPasswordRequest struct {
+	Password string `json:"password"`
+}
+
 // UpdateProfileRequest 定义个人资料更新请求结构
 type UpdateProfileRequest struct {
-	Name   string `json:"name"`
-	Avatar string `json:"avatar"`
+	Name       string `json:"name"`
+	Avatar     string `json:"avatar"`
+	HideOnline *bool  `json:"hideOnline"` // 对他人隐藏在线状态，不传则不修改
+	// 智能问答是否结合个人练习记录和学习进度回答，不传则不修改
+	QAPersonalization *bool `json:"qaPersonalization"`
 }
 
 // GetUsers godoc
@@ -131,14 +149,12 @@ func (c *UserController) GetUsers(ctx *gin.Context) {
 // @Failure 404 {object} util.Response "用户不存在"
 // @Router /api/admin/users/{id} [get]
 func (c *UserController) GetUser(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "无效的用户ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	user, err := c.UserService.GetUserByID(uint(id))
+	user, err := c.UserService.GetUserByID(id)
 	if err != nil {
 		util.NotFound(ctx)
 		return
@@ -160,12 +176,16 @@ func (c *UserController) GetUser(ctx *gin.Context) {
 // @Failure 400 {object} util.Response "请求参数错误"
 // @Failure 401 {object} util.Response "未授权"
 // @Failure 404 {object} util.Response "用户不存在"
+// @Failure 409 {object} util.Response "不能降级或禁用最后一个管理员"
 // @Router /api/admin/users/{id} [put]
 func (c *UserController) UpdateUser(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "无效的用户ID")
+	operator := util.GetUserFromContext(ctx)
+	if operator == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
@@ -182,24 +202,28 @@ func (c *UserController) UpdateUser(ctx *gin.Context) {
 		Language: req.Language,
 		Disabled: req.Disabled,
 	}
-	user.ID = uint(id)
+	user.ID = id
 
 	// 如果提供了密码，则更新密码
 	if req.Password != "" {
-		err := c.UserService.UpdateUserWithPassword(user, req.Password)
+		err := c.UserService.UpdateUserWithPassword(operator.UserID, user, req.Password)
 		if err != nil {
 			if errors.Is(err, util.ErrUserNotFound) {
 				util.NotFound(ctx)
-			} else {
+			} else if errors.Is(err, util.ErrLastAdmin) {
+				util.Error(ctx, http.StatusConflict, err.Error())
+			} else if !respondWeakPassword(ctx, err) {
 				util.InternalServerError(ctx)
 			}
 			return
 		}
 	} else {
 		// 如果没有提供密码，使用原有的更新方法
-		if err := c.UserService.UpdateUser(user); err != nil {
+		if err := c.UserService.UpdateUser(operator.UserID, user); err != nil {
 			if err.Error() == "用户不存在" {
 				util.NotFound(ctx)
+			} else if errors.Is(err, util.ErrLastAdmin) {
+				util.Error(ctx, http.StatusConflict, err.Error())
 			} else {
 				util.InternalServerError(ctx)
 			}
@@ -207,7 +231,7 @@ func (c *UserController) UpdateUser(ctx *gin.Context) {
 		}
 	}
 
-	updatedUser, _ := c.UserService.GetUserByID(uint(id))
+	updatedUser, _ := c.UserService.GetUserByID(id)
 	util.Success(ctx, updatedUser)
 }
 
@@ -222,18 +246,20 @@ func (c *UserController) UpdateUser(ctx *gin.Context) {
 // @Success 200 {object} util.Response{data=map[string]string} "成功"
 // @Router /api/user/avatar/upload [post]
 func (c *UserController) UploadAvatar(ctx *gin.Context) {
+	if !util.LimitUploadBody(ctx, util.MB(c.Config.Upload.AvatarMaxMB)) {
+		return
+	}
 	file, err := ctx.FormFile("avatar")
 	if err != nil {
 		util.BadRequest(ctx, "头像文件是必需的")
 		return
 	}
 
-	// 验证文件类型
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	if ext != ".png" && ext != ".jpg" && ext != ".jpeg" && ext != ".gif" && ext != ".webp" {
-		util.BadRequest(ctx, "文件格式不支持，请上传图片格式")
+	// 验证文件类型与大小
+	if !util.CheckUpload(ctx, file, util.AllowedImageExtensions, util.MB(c.Config.Upload.AvatarMaxMB)) {
 		return
 	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
 
 	// 文件名
 	filename := "avatars/" + time.Now().Format("20060102150405") + "-" + util.GenerateRandomString(6) + ext
@@ -268,7 +294,7 @@ func (c *UserController) UploadAvatar(ctx *gin.Context) {
 
 // UpdateProfile godoc
 // @Summary 更新个人资料
-// @Description 用户更新自己的昵称或头像
+// @Description 用户更新自己的昵称、头像、在线状态隐私设置，以及智能问答是否使用个人学习记录
 // @Tags 用户
 // @Accept  json
 // @Produce  json
@@ -289,7 +315,7 @@ func (c *UserController) UpdateProfile(ctx *gin.Context) {
 		return
 	}
 
-	err := c.UserService.UpdateProfile(userClaims.UserID, req.Name, req.Avatar)
+	err := c.UserService.UpdateProfile(userClaims.UserID, req.Name, req.Avatar, req.HideOnline, req.QAPersonalization)
 	if err != nil {
 		util.InternalServerError(ctx)
 		return
@@ -301,30 +327,37 @@ func (c *UserController) UpdateProfile(ctx *gin.Context) {
 
 // ResetPassword godoc
 // @Summary 重置用户密码
-// @Description 重置用户密码并返回临时密码
+// @Description 重置用户密码并返回新密码。请求体可指定新密码（需满足密码强度要求），不传时生成随机临时密码
 // @Tags 用户管理
 // @Accept  json
 // @Produce  json
 // @Security ApiKeyAuth
 // @Param   id path int true "用户ID"
+// @Param   body body ResetPasswordRequest false "指定的新密码"
 // @Success 200 {object} util.Response{data=string} "成功"
-// @Failure 400 {object} util.Response "请求参数错误"
+// @Failure 400 {object} util.Response "请求参数错误或密码强度不足（data.rule 为未通过的规则）"
 // @Failure 401 {object} util.Response "未授权"
 // @Failure 404 {object} util.Response "用户不存在"
 // @Router /api/admin/users/{id}/reset-password [post]
 func (c *UserController) ResetPassword(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "无效的用户ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	tempPassword, err := c.UserService.ResetPassword(uint(id))
+	var req ResetPasswordRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
+	}
+
+	tempPassword, err := c.UserService.ResetPassword(id, req.Password)
 	if err != nil {
 		if err.Error() == "用户不存在" {
 			util.NotFound(ctx)
-		} else {
+		} else if !respondWeakPassword(ctx, err) {
 			util.InternalServerError(ctx)
 		}
 		return
@@ -345,18 +378,19 @@ func (c *UserController) ResetPassword(ctx *gin.Context) {
 // @Failure 400 {object} util.Response "请求参数错误"
 // @Failure 401 {object} util.Response "未授权"
 // @Failure 404 {object} util.Response "用户不存在"
+// @Failure 409 {object} util.Response "不能删除最后一个管理员"
 // @Router /api/admin/users/{id} [delete]
 func (c *UserController) DeleteUser(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "无效的用户ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
-	if err := c.UserService.DeleteUser(uint(id)); err != nil {
+	if err := c.UserService.DeleteUser(id); err != nil {
 		if err.Error() == "用户不存在" {
 			util.NotFound(ctx)
+		} else if errors.Is(err, util.ErrLastAdmin) {
+			util.Error(ctx, http.StatusConflict, err.Error())
 		} else {
 			util.InternalServerError(ctx)
 		}
@@ -379,21 +413,22 @@ func (c *UserController) DeleteUser(ctx *gin.Context) {
 // @Failure 400 {object} util.Response "请求参数错误"
 // @Failure 401 {object} util.Response "未授权"
 // @Failure 404 {object} util.Response "用户不存在"
+// @Failure 409 {object} util.Response "不能禁用最后一个管理员"
 // @Router /api/admin/users/{id}/disable [post]
 func (c *UserController) DisableUser(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "无效的用户ID")
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
 	disableStr := ctx.Query("disable")
 	disable := disableStr == "true"
 
-	if err := c.UserService.DisableUser(uint(id), disable); err != nil {
+	if err := c.UserService.DisableUser(id, disable); err != nil {
 		if err.Error() == "用户不存在" {
 			util.NotFound(ctx)
+		} else if errors.Is(err, util.ErrLastAdmin) {
+			util.Error(ctx, http.StatusConflict, err.Error())
 		} else {
 			util.InternalServerError(ctx)
 		}
@@ -408,34 +443,45 @@ func (c *UserController) DisableUser(ctx *gin.Context) {
 	util.Success(ctx, gin.H{"message": fmt.Sprintf("用户已成功%s", status)})
 }
 
-// UpdateUserPoints 更新指定用户的总积分
+// UpdateUserPoints 增减指定用户的积分并记录流水
 // @Summary 更新用户积分
-// @Description 根据用户ID更新积分，可为正可为负
+// @Description 根据用户ID增减独立积分（points），可为正可为负，每次调整都会记录操作人和原因。
+// @Description 注意：该接口以前调整的是经验值（xp），现改为调整 points 并写入积分流水，xp 不再受影响；
+// @Description 同时新增必填参数 reason，且仅教师和管理员可调用
 // @Tags 更新用户积分
 // @Accept json
 // @Produce json
 // @Param id path int true "用户ID"
 // @Param points query int true "要增加的积分数量（可为负数）"
+// @Param reason query string true "调整原因"
 // @Success 200 {object} util.Response{data=model.User}
 // @Failure 400 {object} util.Response "请求参数错误"
 // @Failure 404 {object} util.Response "用户不存在"
 // @Router /api/users/{id}/points [post]
 func (c *UserController) UpdateUserPoints(ctx *gin.Context) {
-	idStr := ctx.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		util.BadRequest(ctx, "无效的用户ID")
+	operator := util.GetUserFromContext(ctx)
+	if operator == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
 		return
 	}
 
 	pointsStr := ctx.Query("points")
 	points, err := strconv.Atoi(pointsStr)
-	if err != nil {
+	if err != nil || points == 0 {
 		util.BadRequest(ctx, "无效的积分数量")
 		return
 	}
+	reason := strings.TrimSpace(ctx.Query("reason"))
+	if reason == "" {
+		util.BadRequest(ctx, "请填写积分调整原因")
+		return
+	}
 
-	err = c.UserService.UpdateUserPoints(uint(id), points)
+	err = c.UserService.AdjustPoints(operator.UserID, id, points, reason)
 	if err != nil {
 		if err.Error() == "用户不存在" {
 			util.NotFound(ctx)
@@ -445,10 +491,232 @@ func (c *UserController) UpdateUserPoints(ctx *gin.Context) {
 		return
 	}
 
-	updatedUser, _ := c.UserService.GetUserByID(uint(id))
+	updatedUser, _ := c.UserService.GetUserByID(id)
 	util.Success(ctx, updatedUser)
 }
 
+// GetPointsLedger 获取用户积分流水
+// @Summary 获取用户积分流水
+// @Description 分页获取积分变动记录，仅本人、老师或管理员可查看
+// @Tags 更新用户积分
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(20)
+// @Success 200 {object} util.Response{data=util.PageResponse{list=[]model.PointsLedger}}
+// @Failure 403 {object} util.Response "无权查看"
+// @Router /api/users/{id}/points/ledger [get]
+func (c *UserController) GetPointsLedger(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	id, ok := util.ParseUintParam(ctx, "id")
+	if !ok {
+		return
+	}
+	if user.UserID != id && user.Role != model.Admin && user.Role != model.Teacher {
+		util.Forbidden(ctx)
+		return
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	entries, total, err := c.UserService.GetPointsLedger(id, page, limit)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, util.PageResponse{
+		List:  entries,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// BulkUpdateUsers 批量用户操作
+// @Summary 批量用户操作
+// @Description 对一组用户执行禁用（disable）、启用（enable）、删除（delete）、重置密码（reset-password）或设置角色（set-role）。
+// @Description 整批在一个事务中执行并逐个返回结果：用户不存在或触发最后一个管理员保护的用户会被跳过，其余用户照常生效。
+// @Description 重置密码的结果中返回各用户的临时密码，设置角色会写入角色变更审计记录（仅管理员）
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body BulkUserRequest true "用户ID列表（最多 200 个）及操作"
+// @Success 200 {object} util.Response{data=service.BulkUserReport}
+// @Failure 400 {object} util.Response "请求参数错误"
+// @Router /api/admin/users/bulk [post]
+func (c *UserController) BulkUpdateUsers(ctx *gin.Context) {
+	operator := util.GetUserFromContext(ctx)
+	if operator == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	var req BulkUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		util.BadRequest(ctx, err.Error())
+		return
+	}
+	if req.Action == service.BulkUserSetRole && req.Role == "" {
+		util.BadRequest(ctx, "设置角色时 role 不能为空")
+		return
+	}
+
+	report, err := c.UserService.BulkUpdateUsers(operator.UserID, req.UserIDs, req.Action, model.UserRole(req.Role))
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidRequest) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
+		util.LogInternalError(ctx, err)
+		return
+	}
+	util.Success(ctx, report)
+}
+
+// ImportUsers 通过 CSV 批量导入用户
+// @Summary 批量导入用户
+// @Description 上传 CSV（列依次为 姓名,邮箱,角色,初始密码，首行可为表头），角色为空时默认为学生，教师只能导入学生。
+// @Description 未填写初始密码的行生成随机密码；邮箱格式错误、文件内重复或已注册的行会被跳过并说明原因。
+// @Description 默认返回可下载的 CSV 结果（含生成的初始密码），format=json 时返回 JSON（教师/管理员）
+// @Tags 用户管理
+// @Accept multipart/form-data
+// @Produce text/csv
+// @Produce json
+// @Security ApiKeyAuth
+// @Param file formData file true "CSV 文件，单次最多 1000 行"
+// @Param format query string false "结果格式" Enums(csv,json) default(csv)
+// @Success 200 {object} util.Response{data=service.UserImportReport}
+// @Failure 400 {object} util.Response "文件格式错误"
+// @Failure 413 {object} util.Response "文件过大"
+// @Router /api/admin/users/import [post]
+func (c *UserController) ImportUsers(ctx *gin.Context) {
+	operator := util.GetUserFromContext(ctx)
+	if operator == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+	format := ctx.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		util.BadRequest(ctx, "format 仅支持 csv、json")
+		return
+	}
+	if !util.LimitUploadBody(ctx, util.MB(c.Config.Upload.ResourceMaxMB)) {
+		return
+	}
+
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		util.BadRequest(ctx, "file is required")
+		return
+	}
+	if !util.CheckUpload(ctx, file, []string{".csv"}, util.MB(c.Config.Upload.ResourceMaxMB)) {
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		util.LogInternalError(ctx, err)
+		return
+	}
+	defer src.Close()
+
+	report, err := c.UserService.ImportUsersCSV(ctx.Request.Context(), src, operator.Role)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidRequest) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
+		util.LogInternalError(ctx, err)
+		return
+	}
+
+	if format == "json" {
+		util.Success(ctx, report)
+		return
+	}
+	filename := fmt.Sprintf("user_import_%s.csv", time.Now().Format("20060102150405"))
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Header("X-Import-Created", strconv.Itoa(report.Created))
+	ctx.Header("X-Import-Skipped", strconv.Itoa(report.Skipped))
+	ctx.Status(http.StatusOK)
+	if err := service.WriteUserImportCSV(ctx.Writer, report); err != nil {
+		logger.FromContext(ctx.Request.Context()).Error("Failed to write user import result", zap.Error(err))
+	}
+}
+
+// GetRoleChanges 获取用户角色变更审计记录
+// @Summary 获取角色变更记录
+// @Description 分页返回管理员修改用户角色的审计记录（操作人、原角色、新角色、时间），可按用户过滤（仅管理员）
+// @Tags 用户管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Param userId query int false "被修改的用户ID"
+// @Param page query int false "页码" default(1)
+// @Param limit query int false "每页数量" default(20)
+// @Success 200 {object} util.Response{data=util.PageResponse{list=[]service.RoleChange}}
+// @Router /api/admin/users/role-changes [get]
+func (c *UserController) GetRoleChanges(ctx *gin.Context) {
+	var userID uint64
+	if raw := ctx.Query("userId"); raw != "" {
+		var err error
+		if userID, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			util.BadRequest(ctx, "无效的用户ID")
+			return
+		}
+	}
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	changes, total, err := c.UserService.GetRoleChanges(uint(userID), page, limit)
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, util.PageResponse{
+		List:  changes,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// CheckPointsConsistency 校验积分与流水是否一致
+// @Summary 积分一致性检查
+// @Description 列出 User.Points 与积分流水合计不一致的用户（仅管理员）
+// @Tags 更新用户积分
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} util.Response{data=[]service.PointsMismatch}
+// @Router /api/admin/points/consistency [get]
+func (c *UserController) CheckPointsConsistency(ctx *gin.Context) {
+	mismatches, err := c.UserService.CheckPointsConsistency()
+	if err != nil {
+		util.InternalServerError(ctx)
+		return
+	}
+	util.Success(ctx, mismatches)
+}
+
 // 学习签到
 // @Summary 用户学习签到
 // @Description 用户每日学习签到，同一天只能签到一次
@@ -524,6 +792,41 @@ func (c *UserController) GetCheckinStats(ctx *gin.Context) {
 	util.Success(ctx, stats)
 }
 
+// 签到冻结
+// @Summary 使用冻结补签昨天
+// @Description 消耗一次冻结为昨天补签，保留连续签到天数。仅当昨天漏签且前天有签到时可用，每月次数有限
+// @Tags 用户管理
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} util.Response{data=map[string]interface{}}
+// @Failure 400 {object} util.Response "无需冻结或次数已用完"
+// @Failure 401 {object} util.Response "未授权"
+// @Router /api/users/checkin/freeze [post]
+func (c *UserController) FreezeCheckinStreak(ctx *gin.Context) {
+	user := util.GetUserFromContext(ctx)
+	if user == nil {
+		util.Unauthorized(ctx)
+		return
+	}
+
+	remaining, err := c.UserService.FreezeStreak(user.UserID)
+	if err != nil {
+		if errors.Is(err, util.ErrStreakFreezeNotNeeded) || errors.Is(err, util.ErrNoStreakFreezeLeft) {
+			util.BadRequest(ctx, err.Error())
+			return
+		}
+		util.InternalServerError(ctx)
+		return
+	}
+
+	stats, err := c.UserService.GetCheckinStats(user.UserID)
+	if err != nil {
+		util.Success(ctx, gin.H{"freezeTokens": remaining})
+		return
+	}
+	util.Success(ctx, stats)
+}
+
 // GetUserStats 获取用户统计数据
 // @Summary 获取用户统计数据
 // @Description 获取用户的活跃天数、关卡平均分、总学习时长和关卡完成个数
@@ -5,13 +5,21 @@ import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/util"
 	"coder_edu_backend/pkg/logger"
+	"errors"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// UserStatusRepo 查询账号状态，用于拒绝已禁用或已删除用户仍在有效期内的 token
+type UserStatusRepo interface {
+	IsDisabled(userID uint) (bool, error)
+}
+
+func AuthMiddleware(cfg *config.Config, users UserStatusRepo) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := ""
 		authHeader := c.GetHeader("Authorization")
@@ -37,6 +45,22 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
+		disabled, err := users.IsDisabled(claims.UserID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			util.Unauthorized(c)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			// 查询失败时放行，避免数据库抖动导致全站登录态失效
+			logger.Log.Error("查询账号状态失败", zap.Uint("userId", claims.UserID), zap.Error(err))
+		}
+		if disabled {
+			util.Error(c, http.StatusForbidden, util.T(c, util.ErrAccountDisabled.Error()))
+			c.Abort()
+			return
+		}
+
 		c.Set("user", claims)
 		c.Next()
 	}
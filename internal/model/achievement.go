@@ -6,6 +6,8 @@ type Achievement struct {
 	Name     string `gorm:"size:100;not null"`
 	Icon     string `gorm:"size:255"`
 	EarnedXP int    `gorm:"default:0"`
+	// SourceKey 由业务事件生成的唯一键（如 goal_completed:<goalID>），用于防止重复发放；手动授予的成就为空
+	SourceKey *string `gorm:"size:100;uniqueIndex"`
 }
 
 func (Achievement) TableName() string {
@@ -1,20 +1,16 @@
 package model
 
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
-
 type LearningSession struct {
-	gorm.Model
-	ID        uint `gorm:"primaryKey"`
-	UserID    uint `gorm:"index;type:bigint unsigned"`
-	ModuleID  uint `gorm:"index;type:bigint unsigned"`
-	StartTime time.Time
-	EndTime   *time.Time
-	Duration  int    `gorm:"default:0"`
-	Activity  string `gorm:"type:text"`
+	BaseModel
+	UserID    uint      `gorm:"index;type:bigint unsigned"`
+	ModuleID  uint      `gorm:"index;type:bigint unsigned"`
+	StartTime JSONTime  `json:"startTime"`
+	EndTime   *JSONTime `json:"endTime"`
+	Duration  int       `gorm:"default:0"`
+	Activity  string    `gorm:"type:text"`
+	// LastSeenAt 最近一次心跳时间；长时间无心跳的会话由后台任务自动结束，时长截止到最后一次心跳
+	LastSeenAt *JSONTime `json:"lastSeenAt"`
+	AutoClosed bool      `gorm:"default:false"`
 }
 
 func (LearningSession) TableName() string {
@@ -22,12 +18,11 @@ func (LearningSession) TableName() string {
 }
 
 type SkillAssessment struct {
-	gorm.Model
-	ID         uint   `gorm:"primaryKey"`
-	UserID     uint   `gorm:"index;type:bigint unsigned"`
-	Skill      string `gorm:"size:100;not null"`
-	Score      int    `gorm:"default:0"`
-	AssessedAt time.Time
+	BaseModel
+	UserID     uint     `gorm:"index;type:bigint unsigned"`
+	Skill      string   `gorm:"size:100;not null"`
+	Score      int      `gorm:"default:0"`
+	AssessedAt JSONTime `json:"assessedAt"`
 }
 
 func (SkillAssessment) TableName() string {
@@ -57,6 +57,12 @@ type AbilityRadarData struct {
 	Value int    `json:"value"`
 }
 
+// ClassAbilityRadar 班级能力雷达图，Subgroup 为指定学生子集的平均值，用于与全班对比
+type ClassAbilityRadar struct {
+	ClassAverage []AbilityRadarData `json:"classAverage"`
+	Subgroup     []AbilityRadarData `json:"subgroup,omitempty"`
+}
+
 // AttemptCurveData 关卡尝试曲线单项数据
 type AttemptCurveData struct {
 	AttemptIndex int    `json:"attemptIndex"` // 第几次尝试
@@ -0,0 +1,35 @@
+package model
+
+const (
+	AnnouncementScopeAll      = "all"      // 全部学生
+	AnnouncementScopeSpecific = "specific" // 指定学生
+)
+
+// Announcement 教师发布的单向公告，学生只能查看不能回复
+// swagger:model Announcement
+type Announcement struct {
+	BaseModel
+	TeacherID      uint   `gorm:"index;not null" json:"teacherId"`
+	Teacher        User   `gorm:"foreignKey:TeacherID" json:"teacher"`
+	Title          string `gorm:"size:255;not null" json:"title"`
+	Content        string `gorm:"type:text;not null" json:"content"`
+	TargetScope    string `gorm:"size:20;default:'all'" json:"targetScope"` // all/specific
+	RecipientCount int    `gorm:"default:0" json:"recipientCount"`
+}
+
+func (Announcement) TableName() string {
+	return "announcements"
+}
+
+// AnnouncementRecipient 公告接收人及已读状态，发布时按目标学生展开写入
+type AnnouncementRecipient struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	AnnouncementID uint      `gorm:"uniqueIndex:idx_announcement_user;not null" json:"announcementId"`
+	UserID         uint      `gorm:"uniqueIndex:idx_announcement_user;index:idx_recipient_user_read;not null" json:"userId"`
+	ReadAt         *JSONTime `gorm:"index:idx_recipient_user_read" json:"readAt"`
+	CreatedAt      JSONTime  `json:"createdAt"`
+}
+
+func (AnnouncementRecipient) TableName() string {
+	return "announcement_recipients"
+}
@@ -2,31 +2,50 @@ package model
 
 import (
 	"encoding/json"
-	"time"
 )
 
 // swagger:model Assessment
 type Assessment struct {
 	BaseModel
-	Title       string     `gorm:"size:255;not null" json:"title"`
-	Description string     `gorm:"type:text" json:"description"`
-	TimeLimit   int        `gorm:"default:0" json:"timeLimit"` // Minutes
-	IsPublished bool       `gorm:"default:false" json:"isPublished"`
-	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+	Title       string    `gorm:"size:255;not null" json:"title"`
+	Description string    `gorm:"type:text" json:"description"`
+	TimeLimit   int       `gorm:"default:0" json:"timeLimit"` // Minutes
+	IsPublished bool      `gorm:"default:false" json:"isPublished"`
+	PublishedAt *JSONTime `json:"publishedAt,omitempty"`
+	// 防作弊：按学生打乱题目顺序和选择题选项顺序，每个学生的顺序固定
+	ShuffleQuestions bool `gorm:"default:false" json:"shuffleQuestions"`
+	ShuffleOptions   bool `gorm:"default:false" json:"shuffleOptions"`
+	// MaxAttempts 每个学生最多作答次数（含首次），0 表示不限制
+	MaxAttempts int `gorm:"default:0" json:"maxAttempts"`
+	// RetestDeadline 重测截止时间，晚于该时间不能再重测（不影响首次作答），为空表示不限制
+	RetestDeadline *JSONTime `json:"retestDeadline,omitempty"`
 }
 
 func (Assessment) TableName() string {
 	return "assessments"
 }
 
+// AssessmentShuffleSeed 记录学生在某个评估中的乱序种子，保证重新获取题目时顺序不变，提交时据此还原选项
+type AssessmentShuffleSeed struct {
+	ID           uint     `gorm:"primaryKey" json:"id"`
+	AssessmentID uint     `gorm:"uniqueIndex:idx_assessment_user;type:bigint unsigned" json:"assessmentId"`
+	UserID       uint     `gorm:"uniqueIndex:idx_assessment_user;type:bigint unsigned" json:"userId"`
+	Seed         int64    `json:"seed"`
+	CreatedAt    JSONTime `json:"createdAt"`
+}
+
+func (AssessmentShuffleSeed) TableName() string {
+	return "assessment_shuffle_seeds"
+}
+
 type PostClassTest struct {
 	UUIDBase
-	Title       string     `gorm:"size:255;not null" json:"title"`
-	Description string     `gorm:"type:text" json:"description"`
-	TimeLimit   int        `gorm:"default:0" json:"timeLimit"` // Minutes
-	IsPublished bool       `gorm:"default:false" json:"isPublished"`
-	PublishedAt *time.Time `json:"publishedAt,omitempty"`
-	CreatorID   uint       `gorm:"index;type:bigint unsigned" json:"creatorId"`
+	Title       string    `gorm:"size:255;not null" json:"title"`
+	Description string    `gorm:"type:text" json:"description"`
+	TimeLimit   int       `gorm:"default:0" json:"timeLimit"` // Minutes
+	IsPublished bool      `gorm:"default:false" json:"isPublished"`
+	PublishedAt *JSONTime `json:"publishedAt,omitempty"`
+	CreatorID   uint      `gorm:"index;type:bigint unsigned" json:"creatorId"`
 }
 
 func (PostClassTest) TableName() string {
@@ -52,15 +71,17 @@ func (PostClassTestQuestion) TableName() string {
 
 type PostClassTestSubmission struct {
 	UUIDBase
-	TestID      string     `gorm:"index;type:varchar(36)" json:"testId"`
-	UserID      uint       `gorm:"index;type:bigint unsigned" json:"userId"`
-	Score       int        `gorm:"default:0" json:"score"`
-	RewardXP    int        `gorm:"default:0" json:"rewardXp"`
-	Status      string     `gorm:"size:20;default:'completed'" json:"status"`
-	IsRetest    bool       `gorm:"default:false" json:"isRetest"`
-	IsTimeout   bool       `gorm:"default:false" json:"isTimeout"` // 是否超时提交
-	StartedAt   time.Time  `json:"startedAt"`
-	CompletedAt *time.Time `json:"completedAt"`
+	TestID      string    `gorm:"index;type:varchar(36)" json:"testId"`
+	UserID      uint      `gorm:"index;type:bigint unsigned" json:"userId"`
+	Score       int       `gorm:"default:0" json:"score"`
+	AutoScore   int       `gorm:"default:0" json:"autoScore"`             // 提交时系统自动判分的得分
+	NeedsManual bool      `gorm:"default:false;index" json:"needsManual"` // 是否包含需老师人工批改的主观题
+	RewardXP    int       `gorm:"default:0" json:"rewardXp"`
+	Status      string    `gorm:"size:20;default:'completed'" json:"status"`
+	IsRetest    bool      `gorm:"default:false" json:"isRetest"`
+	IsTimeout   bool      `gorm:"default:false" json:"isTimeout"` // 是否超时提交
+	StartedAt   JSONTime  `json:"startedAt"`
+	CompletedAt *JSONTime `json:"completedAt"`
 }
 
 func (PostClassTestSubmission) TableName() string {
@@ -77,6 +98,8 @@ type PostClassTestAnswer struct {
 	UserAnswer      string          `gorm:"type:text" json:"userAnswer"`
 	IsCorrect       bool            `gorm:"default:false" json:"isCorrect"`
 	Score           int             `gorm:"default:0" json:"score"`
+	NeedsManual     bool            `gorm:"default:false" json:"needsManual"` // 主观题，系统不判分，需老师人工批改
+	TimeSeconds     int             `gorm:"default:0" json:"timeSeconds"`     // 该题作答耗时（秒），旧客户端未上报时为 0
 }
 
 func (PostClassTestAnswer) TableName() string {
@@ -12,7 +12,8 @@ type AssessmentSubmission struct {
 	TotalScore       int             `json:"totalScore"`
 	Status           string          `gorm:"size:20;default:'pending'" json:"status"` // pending, completed
 	Feedback         string          `gorm:"type:text" json:"feedback"`
-	RecommendedLevel int             `json:"recommendedLevel"` // 1:基础, 2:初级, 3:中级, 4:高级
+	RecommendedLevel int             `json:"recommendedLevel"`              // 1:基础, 2:初级, 3:中级, 4:高级
+	AttemptCount     int             `gorm:"default:1" json:"attemptCount"` // 已作答次数，重测提交时累加
 }
 
 func (AssessmentSubmission) TableName() string {
@@ -20,6 +21,7 @@ func (AssessmentSubmission) TableName() string {
 }
 
 type QuestionAnswer struct {
-	QuestionID uint   `json:"questionId"`
-	Answer     string `json:"answer"`
+	QuestionID  uint   `json:"questionId"`
+	Answer      string `json:"answer"`
+	TimeSeconds int    `json:"timeSeconds,omitempty"` // 该题作答耗时（秒），可选
 }
@@ -0,0 +1,17 @@
+package model
+
+// AuditLog 管理操作审计记录
+// swagger:model AuditLog
+type AuditLog struct {
+	ID         uint     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ActorID    uint     `gorm:"index;not null" json:"actorId"`                             // 操作人
+	Action     string   `gorm:"size:50;index;not null" json:"action"`                      // 操作类型，如 transfer_ownership
+	TargetType string   `gorm:"size:50;index:idx_audit_target;not null" json:"targetType"` // 操作对象类型，如 resource/level
+	TargetID   string   `gorm:"size:64;index:idx_audit_target;not null" json:"targetId"`
+	Detail     string   `gorm:"type:text" json:"detail"` // 变更详情（JSON）
+	CreatedAt  JSONTime `gorm:"index" json:"createdAt"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
@@ -1,8 +1,6 @@
 package model
 
 import (
-	"time"
-
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -10,16 +8,16 @@ import (
 // swagger:model
 type BaseModel struct {
 	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	CreatedAt time.Time      `json:"createdAt"`
-	UpdatedAt time.Time      `json:"updatedAt"`
+	CreatedAt JSONTime       `json:"createdAt"`
+	UpdatedAt JSONTime       `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // swagger:model
 type UUIDBase struct {
 	ID        string         `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	CreatedAt time.Time      `json:"createdAt"`
-	UpdatedAt time.Time      `json:"updatedAt"`
+	CreatedAt JSONTime       `json:"createdAt"`
+	UpdatedAt JSONTime       `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
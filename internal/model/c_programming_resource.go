@@ -17,6 +17,23 @@ func (CProgrammingResource) TableName() string {
 	return "c_programming_resources"
 }
 
+// 练习题答案公开策略，控制学生端何时返回 CorrectAnswer/SolutionCode（教师和管理员始终可见）
+const (
+	AnswerRevealAlways          = "always"           // 始终公开（默认）
+	AnswerRevealAfterSubmission = "after_submission" // 学生提交过答案后公开
+	AnswerRevealAfterDelay      = "after_delay"      // 题目发布 RevealDelayMinutes 分钟后公开
+	AnswerRevealNever           = "never"            // 不向学生公开
+)
+
+// IsValidAnswerReveal 校验答案公开策略，空字符串表示继承上级配置
+func IsValidAnswerReveal(policy string) bool {
+	switch policy {
+	case "", AnswerRevealAlways, AnswerRevealAfterSubmission, AnswerRevealAfterDelay, AnswerRevealNever:
+		return true
+	}
+	return false
+}
+
 // ExerciseCategory 表示练习题的分类
 // swagger:model ExerciseCategory
 type ExerciseCategory struct {
@@ -25,6 +42,9 @@ type ExerciseCategory struct {
 	Description       string `gorm:"type:text"`
 	Order             int    `gorm:"default:0"`
 	CProgrammingResID uint   `gorm:"index;type:bigint unsigned"`
+	// 分类下题目的默认答案公开策略，为空表示 always
+	AnswerReveal       string `gorm:"size:20;default:''"`
+	RevealDelayMinutes int    `gorm:"default:0"`
 }
 
 func (ExerciseCategory) TableName() string {
@@ -46,6 +66,15 @@ type ExerciseQuestion struct {
 	CorrectAnswer string          `gorm:"type:text"`                     // 存储正确答案
 	Points        int             `gorm:"default:0"`                     // 完成此题可获得的积分
 	Tags          string          `gorm:"size:500;default:''"`           // AI 自动生成的关键词标签，逗号分隔
+	// 答案公开策略，为空时继承所属分类的配置
+	AnswerReveal       string `gorm:"size:20;default:''"`
+	RevealDelayMinutes int    `gorm:"default:0"`
+	AnswerRevealed     bool   `gorm:"-"` // 动态字段：当前查看者是否可以看到答案
+	// 后台任务根据提交正确率计算的建议难度，为空表示提交人数不足；不会覆盖老师设置的 Difficulty
+	SuggestedDifficulty    string    `gorm:"size:50;default:''"`
+	SubmissionCount        int       `gorm:"default:0"` // 参与计算的提交人数
+	CorrectRate            float64   `gorm:"default:0"` // 提交正确率，0-1
+	DifficultyCalibratedAt *JSONTime // 最近一次计算建议难度的时间
 }
 
 func (ExerciseQuestion) TableName() string {
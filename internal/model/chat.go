@@ -1,36 +1,61 @@
 package model
 
-import (
-	"time"
-)
-
 // Conversation 存储会话（私聊、群聊信息）
 type Conversation struct {
 	UUIDBase
-	Type      string               `gorm:"type:enum('private','group');default:'group'" json:"type"`
-	Name      string               `gorm:"size:100" json:"name"`
+	Type      string               `gorm:"type:enum('private','group','saved');default:'group'" json:"type"` // saved 为用户给自己记笔记的“我的收藏”会话
+	Name      string               `gorm:"size:100;index" json:"name"`
 	Avatar    string               `gorm:"size:255" json:"avatar"`
 	CreatorID uint                 `gorm:"index" json:"creatorId"` // 指向 User.ID (uint)
 	Members   []ConversationMember `gorm:"foreignKey:ConversationID" json:"members"`
 	MemberIDs []uint               `gorm:"-" json:"memberIds"` // 扁平化的成员ID列表
 	Messages  []Message            `gorm:"foreignKey:ConversationID" json:"messages"`
+	// LastMessageAt 最后一条消息的时间，新建会话取创建时间；会话列表默认按此倒序
+	LastMessageAt *JSONTime `gorm:"index" json:"lastMessageAt"`
+	// InitialMessage 创建会话时一并发送的首条消息，仅在创建接口的响应中返回
+	InitialMessage *Message `gorm:"-" json:"initialMessage,omitempty"`
+	// SkippedMembers 建群时因不满足邀请规则而未加入的用户，仅在创建接口的响应中返回
+	SkippedMembers []SkippedMember `gorm:"-" json:"skippedMembers,omitempty"`
+	// 消息保留策略覆盖：RetentionDays>0 时使用会话自己的保留天数，否则使用全局配置；
+	// RetentionExempt 为 true 的会话（如重要群聊）不参与自动清理
+	RetentionDays   int  `gorm:"default:0" json:"retentionDays"`
+	RetentionExempt bool `gorm:"default:false;index" json:"retentionExempt"`
 }
 
 func (Conversation) TableName() string {
 	return "conversations"
 }
 
+// 建群时成员被跳过的原因
+const (
+	SkipReasonNotFriend    = "not_friend"     // 不是建群人的好友
+	SkipReasonUserNotFound = "user_not_found" // 用户不存在
+)
+
+// SkippedMember 建群时未能加入的用户及原因
+type SkippedMember struct {
+	UserID uint   `json:"userId"`
+	Reason string `json:"reason"`
+}
+
+// 群成员角色：admin 为群主；moderator 可邀请、移除普通成员和修改群信息，但不能解散或转让群聊
+const (
+	MemberRoleAdmin     = "admin"
+	MemberRoleModerator = "moderator"
+	MemberRoleMember    = "member"
+)
+
 // ConversationMember 维护成员关系、未读数、角色
 type ConversationMember struct {
-	ConversationID  string     `gorm:"primaryKey;type:varchar(36)" json:"conversationId"`
-	UserID          uint       `gorm:"primaryKey;index" json:"userId"` // 优化按用户查询会话
-	User            User       `gorm:"foreignKey:UserID" json:"user"`  // 关联用户信息
-	Role            string     `gorm:"type:enum('admin','member');default:'member'" json:"role"`
-	Nickname        string     `gorm:"size:50" json:"nickname"`
-	LastReadMsgID   string     `gorm:"type:varchar(36);default:''" json:"lastReadMsgId"` // 记录最后读到的 UUID 消息 ID
-	LastReadMsgTime *time.Time `json:"lastReadMsgTime"`                                  // 最后阅读消息的时间戳
-	HiddenAt        *time.Time `gorm:"index" json:"hiddenAt,omitempty"`                  // 用户隐藏会话的时间，为 nil 表示未隐藏
-	JoinedAt        time.Time  `gorm:"autoCreateTime" json:"joinedAt"`
+	ConversationID  string    `gorm:"primaryKey;type:varchar(36)" json:"conversationId"`
+	UserID          uint      `gorm:"primaryKey;index" json:"userId"` // 优化按用户查询会话
+	User            User      `gorm:"foreignKey:UserID" json:"user"`  // 关联用户信息
+	Role            string    `gorm:"type:enum('admin','moderator','member');default:'member'" json:"role"`
+	Nickname        string    `gorm:"size:50" json:"nickname"`
+	LastReadMsgID   string    `gorm:"type:varchar(36);default:''" json:"lastReadMsgId"` // 记录最后读到的 UUID 消息 ID
+	LastReadMsgTime *JSONTime `json:"lastReadMsgTime"`                                  // 最后阅读消息的时间戳
+	HiddenAt        *JSONTime `gorm:"index" json:"hiddenAt,omitempty"`                  // 用户隐藏会话的时间，为 nil 表示未隐藏
+	JoinedAt        JSONTime  `gorm:"autoCreateTime" json:"joinedAt"`
 }
 
 func (ConversationMember) TableName() string {
@@ -41,7 +66,7 @@ func (ConversationMember) TableName() string {
 type Message struct {
 	UUIDBase
 	ConversationID string       `gorm:"index;index:idx_conv_created;type:varchar(36);not null" json:"conversationId"`
-	CreatedAt      time.Time    `gorm:"index:idx_conv_created" json:"createdAt"` // 优化历史消息查询 (conversation_id, created_at)
+	CreatedAt      JSONTime     `gorm:"index:idx_conv_created" json:"createdAt"` // 优化历史消息查询 (conversation_id, created_at)
 	SenderID       *uint        `gorm:"index" json:"senderId"`
 	Sender         User         `gorm:"foreignKey:SenderID" json:"sender"`             // 关联发送者用户信息
 	Conversation   Conversation `gorm:"foreignKey:ConversationID" json:"conversation"` // 关联会话信息
@@ -53,6 +78,48 @@ type Message struct {
 	ThumbnailURL   string       `gorm:"size:255" json:"thumbnailUrl"`     // 缩略图 URL
 	ClientMsgID    string       `gorm:"size:50;index" json:"clientMsgId"` // 用于识别重复消息
 	SeqID          uint64       `gorm:"index" json:"seqId"`               // 消息序列号，用于可靠性保证
+	MessageAttachment
+	CollapsedCount int    `gorm:"-" json:"collapsedCount,omitempty"` // 动态字段：折叠进本条的更早的连续系统消息数
+	Mentions       []uint `gorm:"-" json:"mentions,omitempty"`       // 动态字段：发送时解析出的被 @ 成员
+}
+
+// MessageMention 群聊消息中的 @ 提及记录，用于提醒被提及的成员
+type MessageMention struct {
+	ID             uint         `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID      string       `gorm:"uniqueIndex:idx_mention_msg_user;type:varchar(36);not null" json:"messageId"`
+	UserID         uint         `gorm:"uniqueIndex:idx_mention_msg_user;index:idx_mention_user_read;not null" json:"userId"` // 被提及的用户
+	ConversationID string       `gorm:"index;type:varchar(36);not null" json:"conversationId"`
+	Conversation   Conversation `gorm:"foreignKey:ConversationID" json:"conversation"`
+	SenderID       uint         `json:"senderId"`
+	Sender         User         `gorm:"foreignKey:SenderID" json:"sender"`
+	Preview        string       `gorm:"size:255" json:"preview"` // 消息内容摘要
+	ReadAt         *JSONTime    `gorm:"index:idx_mention_user_read" json:"readAt"`
+	CreatedAt      JSONTime     `json:"createdAt"`
+}
+
+func (MessageMention) TableName() string {
+	return "message_mentions"
+}
+
+// ConversationEvent 会话成员变动等系统事件的审计记录，与系统消息同时写入，
+// 系统消息被折叠或过期清理后仍可追溯
+type ConversationEvent struct {
+	ID             uint     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ConversationID string   `gorm:"index:idx_conv_event_created;type:varchar(36);not null" json:"conversationId"`
+	MessageID      string   `gorm:"type:varchar(36)" json:"messageId"`
+	Content        string   `gorm:"type:text" json:"content"`
+	CreatedAt      JSONTime `gorm:"index:idx_conv_event_created" json:"createdAt"`
+}
+
+func (ConversationEvent) TableName() string {
+	return "conversation_events"
+}
+
+// MessageAttachment 文件/图片消息的附件元数据
+type MessageAttachment struct {
+	FileName string `gorm:"size:255" json:"fileName,omitempty"`  // 原始文件名
+	FileSize int64  `gorm:"default:0" json:"fileSize,omitempty"` // 文件大小（字节）
+	MimeType string `gorm:"size:100" json:"mimeType,omitempty"`  // 根据文件内容检测的 MIME 类型
 }
 
 func (Message) TableName() string {
@@ -1,9 +1,5 @@
 package model
 
-import (
-	"time"
-)
-
 type Post struct {
 	UUIDBase
 	Title    string    `gorm:"size:255;not null"`
@@ -39,15 +35,15 @@ func (Comment) TableName() string {
 
 type Question struct {
 	UUIDBase
-	Title    string     `gorm:"size:255;not null" json:"title"`
-	Content  string     `gorm:"type:text;not null" json:"content"`
-	AuthorID uint       `gorm:"index;type:bigint unsigned" json:"authorId"`
-	Author   User       `gorm:"foreignKey:AuthorID" json:"author"`
-	Tags     string     `gorm:"size:255" json:"tags"`
-	Upvotes  int        `gorm:"default:0" json:"likes"`
-	Answers  []Answer   `gorm:"foreignKey:QuestionID" json:"answers"`
-	IsSolved bool       `gorm:"default:false" json:"isSolved"`
-	SolvedAt *time.Time `json:"solvedAt"`
+	Title    string    `gorm:"size:255;not null" json:"title"`
+	Content  string    `gorm:"type:text;not null" json:"content"`
+	AuthorID uint      `gorm:"index;type:bigint unsigned" json:"authorId"`
+	Author   User      `gorm:"foreignKey:AuthorID" json:"author"`
+	Tags     string    `gorm:"size:255" json:"tags"`
+	Upvotes  int       `gorm:"default:0" json:"likes"`
+	Answers  []Answer  `gorm:"foreignKey:QuestionID" json:"answers"`
+	IsSolved bool      `gorm:"default:false" json:"isSolved"`
+	SolvedAt *JSONTime `json:"solvedAt"`
 }
 
 func (Question) TableName() string {
@@ -56,13 +52,13 @@ func (Question) TableName() string {
 
 type Answer struct {
 	UUIDBase
-	QuestionID string     `gorm:"index;type:varchar(36)" json:"questionId"`
-	AuthorID   uint       `gorm:"index;type:bigint unsigned" json:"authorId"`
-	Author     User       `gorm:"foreignKey:AuthorID" json:"author"`
-	Content    string     `gorm:"type:text;not null" json:"content"`
-	Upvotes    int        `gorm:"default:0" json:"likes"`
-	IsAccepted bool       `gorm:"default:false" json:"isAccepted"`
-	AcceptedAt *time.Time `json:"acceptedAt"`
+	QuestionID string    `gorm:"index;type:varchar(36)" json:"questionId"`
+	AuthorID   uint      `gorm:"index;type:bigint unsigned" json:"authorId"`
+	Author     User      `gorm:"foreignKey:AuthorID" json:"author"`
+	Content    string    `gorm:"type:text;not null" json:"content"`
+	Upvotes    int       `gorm:"default:0" json:"likes"`
+	IsAccepted bool      `gorm:"default:false" json:"isAccepted"`
+	AcceptedAt *JSONTime `json:"acceptedAt"`
 }
 
 func (Answer) TableName() string {
@@ -70,12 +66,12 @@ func (Answer) TableName() string {
 }
 
 type CommunityLike struct {
-	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
-	UserID      uint      `gorm:"uniqueIndex:idx_user_content;type:bigint unsigned" json:"userId"`
-	ContentType string    `gorm:"uniqueIndex:idx_user_content;size:20" json:"contentType"` // post, comment, answer
-	ContentID   string    `gorm:"uniqueIndex:idx_user_content;size:36" json:"contentId"`
+	ID          uint     `gorm:"primaryKey;autoIncrement" json:"id"`
+	CreatedAt   JSONTime `json:"createdAt"`
+	UpdatedAt   JSONTime `json:"updatedAt"`
+	UserID      uint     `gorm:"uniqueIndex:idx_user_content;type:bigint unsigned" json:"userId"`
+	ContentType string   `gorm:"uniqueIndex:idx_user_content;size:20" json:"contentType"` // post, comment, answer
+	ContentID   string   `gorm:"uniqueIndex:idx_user_content;size:36" json:"contentId"`
 }
 
 func (CommunityLike) TableName() string {
@@ -1,7 +1,5 @@
 package model
 
-import "time"
-
 type GoalStatus string
 
 const (
@@ -29,10 +27,16 @@ type Goal struct {
 	Current            int        `gorm:"default:0"`
 	Target             int        `gorm:"not null"`
 	Progress           float64    `gorm:"default:0"`
-	TargetDate         time.Time  `gorm:"type:datetime"`
+	TargetDate         JSONTime   `gorm:"type:datetime"`
 	GoalType           GoalType   `gorm:"type:enum('short_term','long_term');default:'short_term'"`
 	ResourceModuleID   uint       `gorm:"index;type:bigint unsigned"`
 	ResourceModuleName string     `gorm:"size:255"`
+	// 截止提醒：ReminderThresholdDays 为最近一次已提醒的阈值（距截止天数），每个阈值只提醒一次；
+	// 用户可暂缓到 ReminderSnoozedUntil 或关闭该目标的全部提醒
+	ReminderSentAt        *JSONTime
+	ReminderThresholdDays int `gorm:"default:0"`
+	ReminderSnoozedUntil  *JSONTime
+	ReminderDismissed     bool `gorm:"default:false"`
 }
 
 func (Goal) TableName() string {
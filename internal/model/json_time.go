@@ -0,0 +1,96 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TimeFormat API 中所有时间戳统一使用的序列化格式：RFC3339，固定三位毫秒（与数据库 datetime(3) 精度一致），带时区
+const TimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// JSONTime 数据库时间字段，JSON 序列化为 TimeFormat 格式，零值序列化为 null。
+// 内嵌 time.Time，Format/Before/Sub 等方法可直接使用
+type JSONTime struct {
+	time.Time
+}
+
+// NewJSONTime 包装 time.Time
+func NewJSONTime(t time.Time) JSONTime {
+	return JSONTime{Time: t}
+}
+
+// MarshalJSON 实现 json.Marshaler
+func (t JSONTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Format(TimeFormat))
+}
+
+// UnmarshalJSON 实现 json.Unmarshaler，兼容带纳秒的 RFC3339
+func (t *JSONTime) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == nil || *s == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, *s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Value 实现 driver.Valuer
+func (t JSONTime) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.Time, nil
+}
+
+// Scan 实现 sql.Scanner
+func (t *JSONTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+	case time.Time:
+		t.Time = v
+	case []byte:
+		return t.parseDB(string(v))
+	case string:
+		return t.parseDB(v)
+	default:
+		return fmt.Errorf("cannot scan %T into JSONTime", value)
+	}
+	return nil
+}
+
+// parseDB 解析 DSN 未开启 parseTime 时驱动返回的时间字符串
+func (t *JSONTime) parseDB(s string) error {
+	parsed, err := time.ParseInLocation("2006-01-02 15:04:05.999999999", s, time.Local)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// GormDataType 让 GORM 按时间类型建列，并对 CreatedAt/UpdatedAt 自动填充
+func (JSONTime) GormDataType() string {
+	return "time"
+}
+
+// NewJSONTimePtr 将 *time.Time 转换为 *JSONTime，nil 保持为 nil
+func NewJSONTimePtr(t *time.Time) *JSONTime {
+	if t == nil {
+		return nil
+	}
+	return &JSONTime{Time: *t}
+}
@@ -1,8 +1,6 @@
 package model
 
 import (
-	"time"
-
 	"gorm.io/gorm"
 )
 
@@ -18,20 +16,23 @@ const (
 )
 
 type KnowledgePoint struct {
-	ID              string                   `gorm:"primaryKey;type:varchar(36)" json:"id"`
-	Title           string                   `gorm:"size:255;not null" json:"title"`
-	Description     string                   `gorm:"type:text" json:"description"`
-	Type            KnowledgePointType       `gorm:"size:50;not null" json:"type"`
-	ArticleContent  string                   `gorm:"type:longtext" json:"articleContent"`
-	TimeLimit       int                      `gorm:"default:0" json:"timeLimit"`
-	Order           int                      `gorm:"default:0" json:"order"`
-	CompletionScore int                      `gorm:"default:0" json:"completionScore"`
-	Tags            string                   `gorm:"size:500;default:''" json:"tags"` // AI 自动生成的关键词标签，逗号分隔
-	Videos          []KnowledgePointVideo    `gorm:"foreignKey:KnowledgePointID" json:"videos"`
-	Exercises       []KnowledgePointExercise `gorm:"foreignKey:KnowledgePointID" json:"exercises"`
-	CreatedAt       time.Time                `json:"createdAt"`
-	UpdatedAt       time.Time                `json:"updatedAt"`
-	DeletedAt       gorm.DeletedAt           `gorm:"index" json:"-"`
+	ID              string             `gorm:"primaryKey;type:varchar(36)" json:"id"`
+	Title           string             `gorm:"size:255;not null" json:"title"`
+	Description     string             `gorm:"type:text" json:"description"`
+	Type            KnowledgePointType `gorm:"size:50;not null" json:"type"`
+	ArticleContent  string             `gorm:"type:longtext" json:"articleContent"`
+	TimeLimit       int                `gorm:"default:0" json:"timeLimit"`
+	Order           int                `gorm:"default:0" json:"order"`
+	CompletionScore int                `gorm:"default:0" json:"completionScore"`
+	Tags            string             `gorm:"size:500;default:''" json:"tags"` // AI 自动生成的关键词标签，逗号分隔
+	// 练习答案公开策略（AnswerReveal* 常量），为空表示 always；after_delay 以学生提交时间起算
+	AnswerReveal       string                   `gorm:"size:20;default:''" json:"answerReveal"`
+	RevealDelayMinutes int                      `gorm:"default:0" json:"revealDelayMinutes"`
+	Videos             []KnowledgePointVideo    `gorm:"foreignKey:KnowledgePointID" json:"videos"`
+	Exercises          []KnowledgePointExercise `gorm:"foreignKey:KnowledgePointID" json:"exercises"`
+	CreatedAt          JSONTime                 `json:"createdAt"`
+	UpdatedAt          JSONTime                 `json:"updatedAt"`
+	DeletedAt          gorm.DeletedAt           `gorm:"index" json:"-"`
 }
 
 func (KnowledgePoint) TableName() string {
@@ -44,8 +45,8 @@ type KnowledgePointVideo struct {
 	Title            string         `gorm:"size:255;not null" json:"title"`
 	URL              string         `gorm:"size:500;not null" json:"url"`
 	Description      string         `gorm:"type:text" json:"description"`
-	CreatedAt        time.Time      `json:"createdAt"`
-	UpdatedAt        time.Time      `json:"updatedAt"`
+	CreatedAt        JSONTime       `json:"createdAt"`
+	UpdatedAt        JSONTime       `json:"updatedAt"`
 	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
@@ -72,8 +73,8 @@ type KnowledgePointExercise struct {
 	Answer           string         `gorm:"type:text;not null" json:"answer"`
 	Explanation      string         `gorm:"type:text" json:"explanation"`
 	Points           int            `gorm:"default:0" json:"points"`
-	CreatedAt        time.Time      `json:"createdAt"`
-	UpdatedAt        time.Time      `json:"updatedAt"`
+	CreatedAt        JSONTime       `json:"createdAt"`
+	UpdatedAt        JSONTime       `json:"updatedAt"`
 	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
@@ -82,10 +83,10 @@ func (KnowledgePointExercise) TableName() string {
 }
 
 type KnowledgePointCompletion struct {
-	UserID           uint      `gorm:"primaryKey;index:idx_user_kp" json:"userId"`
-	KnowledgePointID string    `gorm:"primaryKey;type:varchar(36);index:idx_user_kp" json:"knowledgePointId"`
-	IsCompleted      bool      `gorm:"default:false" json:"isCompleted"`
-	CompletedAt      time.Time `json:"completedAt"`
+	UserID           uint     `gorm:"primaryKey;index:idx_user_kp" json:"userId"`
+	KnowledgePointID string   `gorm:"primaryKey;type:varchar(36);index:idx_user_kp" json:"knowledgePointId"`
+	IsCompleted      bool     `gorm:"default:false" json:"isCompleted"`
+	CompletedAt      JSONTime `json:"completedAt"`
 }
 
 func (KnowledgePointCompletion) TableName() string {
@@ -98,13 +99,13 @@ type KnowledgePointSubmission struct {
 	UserID           uint   `gorm:"index" json:"userId"`
 	KnowledgePointID string `gorm:"index;type:varchar(36)" json:"knowledgePointId"`
 	// Details 存储 JSON 数组，包含每题的题目、类型、学生答案、代码内容、执行结果及系统初步判断
-	Details      string    `gorm:"type:longtext" json:"details"`
-	Score        int       `gorm:"default:0" json:"score"`                  // 系统初步计算的得分
-	Status       string    `gorm:"size:20;default:'pending'" json:"status"` // pending, approved, rejected
-	IsAutoSubmit bool      `gorm:"default:false" json:"isAutoSubmit"`       // 是否为自动提交
-	Duration     int       `gorm:"default:0" json:"duration"`               // 答题耗时（秒）
-	StartedAt    time.Time `json:"startedAt"`                               // 开始答题时间
-	CreatedAt    time.Time `json:"createdAt"`
+	Details      string   `gorm:"type:longtext" json:"details"`
+	Score        int      `gorm:"default:0" json:"score"`                  // 系统初步计算的得分
+	Status       string   `gorm:"size:20;default:'pending'" json:"status"` // pending, approved, rejected
+	IsAutoSubmit bool     `gorm:"default:false" json:"isAutoSubmit"`       // 是否为自动提交
+	Duration     int      `gorm:"default:0" json:"duration"`               // 答题耗时（秒）
+	StartedAt    JSONTime `json:"startedAt"`                               // 开始答题时间
+	CreatedAt    JSONTime `json:"createdAt"`
 }
 
 func (KnowledgePointSubmission) TableName() string {
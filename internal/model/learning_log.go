@@ -1,16 +1,9 @@
 package model
 
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
-
 // LearningLog 记录用户的学习活动
 type LearningLog struct {
-	gorm.Model
-	ID         uint     `gorm:"primaryKey"`
-	UserID     uint     `gorm:"index;type:bigint unsigned"`
+	BaseModel
+	UserID     uint     `gorm:"index;uniqueIndex:idx_learning_log_item_day;type:bigint unsigned"`
 	ModuleID   uint     `gorm:"index;type:bigint unsigned"`
 	Activity   string   `gorm:"type:text"`
 	Content    string   `gorm:"type:text"` //内容字段
@@ -21,7 +14,8 @@ type LearningLog struct {
 	Duration   int      `gorm:"default:0"`
 	Completed  bool     `gorm:"default:false"`
 	Score      int      `gorm:"default:0"`
-	CreatedAt  time.Time
+	ItemKey    *string  `gorm:"size:150;uniqueIndex:idx_learning_log_item_day"` // 学习项标识（活动类型:ID），学习时长按 用户-学习项-日期 累加
+	LogDate    *string  `gorm:"size:10;uniqueIndex:idx_learning_log_item_day"`  // 累加记录所属日期 YYYY-MM-DD
 }
 
 func (LearningLog) TableName() string {
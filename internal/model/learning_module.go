@@ -1,11 +1,5 @@
 package model
 
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
-
 type ModuleType string
 
 const (
@@ -15,8 +9,7 @@ const (
 )
 
 type LearningModule struct {
-	gorm.Model
-	// ID          uint       `gorm:"primaryKey"`
+	BaseModel
 	Title       string     `gorm:"size:255;not null"`
 	Description string     `gorm:"type:text"`
 	Type        ModuleType `gorm:"type:enum('pre_class','in_class','post_class');not null"`
@@ -30,15 +23,14 @@ func (LearningModule) TableName() string {
 }
 
 type UserProgress struct {
-	gorm.Model
-	ID          uint `gorm:"primaryKey"`
-	UserID      uint `gorm:"index;type:bigint unsigned"`
-	ModuleID    uint `gorm:"index;type:bigint unsigned"`
-	Completed   bool `gorm:"default:false"`
-	Score       int  `gorm:"default:0"`
-	TimeSpent   int  `gorm:"default:0"`
-	StartedAt   time.Time
-	CompletedAt *time.Time
+	BaseModel
+	UserID      uint      `gorm:"index;type:bigint unsigned"`
+	ModuleID    uint      `gorm:"index;type:bigint unsigned"`
+	Completed   bool      `gorm:"default:false"`
+	Score       int       `gorm:"default:0"`
+	TimeSpent   int       `gorm:"default:0"`
+	StartedAt   JSONTime  `json:"startedAt"`
+	CompletedAt *JSONTime `json:"completedAt"`
 }
 
 func (UserProgress) TableName() string {
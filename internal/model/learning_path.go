@@ -1,8 +1,6 @@
 package model
 
 import (
-	"time"
-
 	"gorm.io/gorm"
 )
 
@@ -23,8 +21,8 @@ type LearningPathMaterial struct {
 	Content       string         `gorm:"type:longtext" json:"content"`
 	Points        int            `gorm:"default:0" json:"points"`
 	CreatorID     uint           `gorm:"index;type:bigint unsigned" json:"creatorId"`
-	CreatedAt     time.Time      `json:"createdAt"`
-	UpdatedAt     time.Time      `json:"updatedAt"`
+	CreatedAt     JSONTime       `json:"createdAt"`
+	UpdatedAt     JSONTime       `json:"updatedAt"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
@@ -34,10 +32,10 @@ func (LearningPathMaterial) TableName() string {
 
 // swagger:model LearningPathCompletion
 type LearningPathCompletion struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	UserID      uint      `gorm:"index;type:bigint unsigned" json:"userId"`
-	MaterialID  string    `gorm:"index;type:varchar(36)" json:"materialId"`
-	CompletedAt time.Time `json:"completedAt"`
+	ID          uint     `gorm:"primaryKey" json:"id"`
+	UserID      uint     `gorm:"index;type:bigint unsigned" json:"userId"`
+	MaterialID  string   `gorm:"index;type:varchar(36)" json:"materialId"`
+	CompletedAt JSONTime `json:"completedAt"`
 }
 
 func (LearningPathCompletion) TableName() string {
@@ -2,7 +2,6 @@ package model
 
 import (
 	"encoding/json"
-	"time"
 )
 
 const (
@@ -11,6 +10,12 @@ const (
 	LevelDifficultyHard   = "hard"
 )
 
+// 及格判定方式
+const (
+	PassingModeAbsolute   = "absolute"
+	PassingModePercentage = "percentage"
+)
+
 // swagger:model Level
 type Level struct {
 	BaseModel
@@ -23,17 +28,18 @@ type Level struct {
 	EstimatedMinutes int    `gorm:"default:0" json:"estimatedMinutes"` // 预计完成时间（分钟）
 	AttemptLimit     int    `gorm:"default:10" json:"attemptLimit"`
 	PassingScore     int    `gorm:"default:60" json:"passingScore"`
+	PassingMode      string `gorm:"size:20;default:'absolute'" json:"passingMode"` // absolute: 按分数；percentage: 按总分百分比
 	BasePoints       int    `gorm:"default:0" json:"basePoints"`
 	AllowPause       bool   `gorm:"default:true" json:"allowPause"`
 
 	LevelType          string          `gorm:"size:100" json:"levelType"` // 关卡类型
 	IsPublished        bool            `gorm:"default:false" json:"isPublished"`
-	PublishedAt        *time.Time      `json:"publishedAt,omitempty"`
-	ScheduledPublishAt *time.Time      `json:"scheduledPublishAt,omitempty"`              // 定时发布时间
+	PublishedAt        *JSONTime       `json:"publishedAt,omitempty"`
+	ScheduledPublishAt *JSONTime       `json:"scheduledPublishAt,omitempty"`              // 定时发布时间
 	VisibleScope       string          `gorm:"size:50;default:'all'" json:"visibleScope"` // all/class/specific
 	VisibleTo          json.RawMessage `gorm:"type:json" json:"visibleTo"`                // 当为 specific 时，存放学生ID数组
-	AvailableFrom      *time.Time      `json:"availableFrom,omitempty"`
-	AvailableTo        *time.Time      `json:"availableTo,omitempty"`
+	AvailableFrom      *JSONTime       `json:"availableFrom,omitempty"`
+	AvailableTo        *JSONTime       `json:"availableTo,omitempty"`
 
 	CurrentVersion uint `gorm:"default:0" json:"currentVersion"`
 
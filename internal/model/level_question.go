@@ -13,8 +13,9 @@ type LevelQuestion struct {
 	Weight        int    `gorm:"default:1" json:"weight"`            // 权重，默认1
 	ManualGrading bool   `gorm:"default:false" json:"manualGrading"` // 是否需要人工评分
 	Order         int    `gorm:"default:0" json:"order"`
-	ScoringRule   string `gorm:"type:text" json:"scoringRule"` // 自定义评分规则或权重
-	Explanation   string `gorm:"type:text" json:"explanation"` // 答案解析
+	ScoringRule   string `gorm:"type:text" json:"scoringRule"`      // 自定义评分规则或权重
+	Explanation   string `gorm:"type:text" json:"explanation"`      // 答案解析
+	Language      string `gorm:"size:20" json:"language,omitempty"` // 编程题使用的语言，为空时按 C 运行
 }
 
 func (LevelQuestion) TableName() string {
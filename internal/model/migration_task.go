@@ -1,19 +1,15 @@
 package model
 
-import (
-	"time"
-)
-
 // swagger:model MigrationTask
 type MigrationTask struct {
 	UUIDBase
-	Title       string     `gorm:"size:255;not null" json:"title"`
-	Description string     `gorm:"type:text" json:"description"`
-	Difficulty  string     `gorm:"size:20;not null;default:'medium'" json:"difficulty"` // simple, medium, hard
-	TimeLimit   int        `gorm:"default:0" json:"timeLimit"`
-	IsPublished bool       `gorm:"default:false" json:"isPublished"`
-	PublishedAt *time.Time `json:"publishedAt,omitempty"`
-	CreatorID   uint       `gorm:"index;type:bigint unsigned" json:"creatorId"`
+	Title       string    `gorm:"size:255;not null" json:"title"`
+	Description string    `gorm:"type:text" json:"description"`
+	Difficulty  string    `gorm:"size:20;not null;default:'medium'" json:"difficulty"` // simple, medium, hard
+	TimeLimit   int       `gorm:"default:0" json:"timeLimit"`
+	IsPublished bool      `gorm:"default:false" json:"isPublished"`
+	PublishedAt *JSONTime `json:"publishedAt,omitempty"`
+	CreatorID   uint      `gorm:"index;type:bigint unsigned" json:"creatorId"`
 }
 
 func (MigrationTask) TableName() string {
@@ -39,12 +35,12 @@ func (MigrationQuestion) TableName() string {
 // swagger:model MigrationSubmission
 type MigrationSubmission struct {
 	UUIDBase
-	TaskID      string     `gorm:"index;type:varchar(36)" json:"taskId"`
-	UserID      uint       `gorm:"index;type:bigint unsigned" json:"userId"`
-	Score       int        `gorm:"default:0" json:"score"`
-	Status      string     `gorm:"size:20;default:'completed'" json:"status"`
-	StartedAt   time.Time  `json:"startedAt"`
-	CompletedAt *time.Time `json:"completedAt"`
+	TaskID      string    `gorm:"index;type:varchar(36)" json:"taskId"`
+	UserID      uint      `gorm:"index;type:bigint unsigned" json:"userId"`
+	Score       int       `gorm:"default:0" json:"score"`
+	Status      string    `gorm:"size:20;default:'completed'" json:"status"`
+	StartedAt   JSONTime  `json:"startedAt"`
+	CompletedAt *JSONTime `json:"completedAt"`
 }
 
 func (MigrationSubmission) TableName() string {
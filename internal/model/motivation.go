@@ -1,23 +1,27 @@
 package model
 
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
-
 // Motivation 每日激励短句
 type Motivation struct {
-	gorm.Model
-	ID              uint      `gorm:"primarykey" json:"id"`
-	Content         string    `gorm:"type:text;not null" json:"content"`
-	IsEnabled       bool      `gorm:"default:true" json:"is_enabled"`
-	IsCurrentlyUsed bool      `gorm:"default:false" json:"is_currently_used"`
-	LastUsedAt      time.Time `gorm:"autoCreateTime" json:"last_used_at"`
-	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	BaseModel
+	Content         string   `gorm:"type:text;not null" json:"content"`
+	IsEnabled       bool     `gorm:"default:true" json:"is_enabled"`
+	IsCurrentlyUsed bool     `gorm:"default:false" json:"is_currently_used"`
+	LastUsedAt      JSONTime `gorm:"autoCreateTime" json:"lastUsedAt"`
 }
 
 func (Motivation) TableName() string {
 	return "motivations"
 }
+
+// MotivationSchedule 激励短句自动轮换设置，全表只有一行
+type MotivationSchedule struct {
+	ID            uint      `gorm:"primarykey" json:"-"`
+	AutoRotate    bool      `gorm:"not null" json:"autoRotate"`
+	PinnedID      *uint     `json:"pinnedId"`      // 置顶的短句，置顶期间不自动轮换
+	LastRotatedAt *JSONTime `json:"lastRotatedAt"` // 上次自动轮换的时间，重启后据此判断当天是否已轮换
+	UpdatedAt     JSONTime  `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+func (MotivationSchedule) TableName() string {
+	return "motivation_schedules"
+}
@@ -0,0 +1,62 @@
+package model
+
+import "encoding/json"
+
+// 通知类型，用户可按类型关闭
+const (
+	NotificationFriendRequest    = "friend_request"    // 收到好友申请
+	NotificationMention          = "mention"           // 群聊中被 @
+	NotificationSubmissionGraded = "submission_graded" // 知识点提交审核、关卡挑战人工评分完成
+	NotificationAnnouncement     = "announcement"      // 教师公告
+	NotificationGoal             = "goal"              // 学习目标达成、截止提醒
+	NotificationReflection       = "reflection"        // 反思收到老师反馈或学生回复
+)
+
+// NotificationTypes 全部通知类型，偏好设置接口按此顺序返回
+var NotificationTypes = []string{
+	NotificationFriendRequest,
+	NotificationMention,
+	NotificationSubmissionGraded,
+	NotificationAnnouncement,
+	NotificationGoal,
+	NotificationReflection,
+}
+
+func IsValidNotificationType(t string) bool {
+	for _, v := range NotificationTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Notification 站内通知，用户离线时产生的事件也能在上线后查看
+// swagger:model Notification
+type Notification struct {
+	ID        uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint            `gorm:"index:idx_notification_user_read;not null" json:"userId"`
+	Type      string          `gorm:"size:30;not null" json:"type"`
+	Title     string          `gorm:"size:255;not null" json:"title"`
+	Content   string          `gorm:"type:text" json:"content"`
+	Data      json.RawMessage `gorm:"type:json" json:"data,omitempty" swaggertype:"object"` // 关联对象ID等，供前端跳转
+	ReadAt    *JSONTime       `gorm:"index:idx_notification_user_read" json:"readAt"`
+	CreatedAt JSONTime        `gorm:"index" json:"createdAt"`
+}
+
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+// NotificationPreference 用户对某类通知的开关，没有记录时默认开启
+type NotificationPreference struct {
+	ID        uint     `gorm:"primaryKey;autoIncrement" json:"-"`
+	UserID    uint     `gorm:"uniqueIndex:idx_notification_pref_user_type;not null" json:"-"`
+	Type      string   `gorm:"uniqueIndex:idx_notification_pref_user_type;size:30;not null" json:"type"`
+	Enabled   bool     `gorm:"not null" json:"enabled"`
+	UpdatedAt JSONTime `json:"-"`
+}
+
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
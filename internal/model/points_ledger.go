@@ -0,0 +1,28 @@
+package model
+
+// 积分来源
+const (
+	PointSourceKnowledgePoint = "knowledge_point_submission"
+	PointSourceGoalCompleted  = "goal_completed"
+	PointSourceWeeklyTasks    = "weekly_tasks_completed"
+	PointSourceManual         = "manual"
+	PointSourceOpeningBalance = "opening_balance" // 积分流水上线前已有的积分，迁移时一次性回填
+)
+
+// PointsLedger 积分流水，只追加不修改，User.Points 应始终等于该用户全部 Delta 之和。
+// 业务来源的 (SourceType, SourceID) 唯一，保证同一事件只发放一次；手动调整的 SourceID 为空
+// swagger:model PointsLedger
+type PointsLedger struct {
+	ID         uint     `gorm:"primaryKey;autoIncrement" json:"id"`
+	CreatedAt  JSONTime `gorm:"index" json:"createdAt"`
+	UserID     uint     `gorm:"index;type:bigint unsigned;not null" json:"userId"`
+	Delta      int      `gorm:"not null" json:"delta"`
+	Reason     string   `gorm:"size:255" json:"reason"`
+	SourceType string   `gorm:"size:50;not null;uniqueIndex:idx_points_source" json:"sourceType"`
+	SourceID   *string  `gorm:"size:64;uniqueIndex:idx_points_source" json:"sourceId,omitempty"`
+	OperatorID *uint    `gorm:"type:bigint unsigned" json:"operatorId,omitempty"` // 手动调整的操作人
+}
+
+func (PointsLedger) TableName() string {
+	return "points_ledger"
+}
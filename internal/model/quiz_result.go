@@ -1,22 +1,15 @@
 package model
 
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
-
 // QuizResult 存储用户的测验结果
 type QuizResult struct {
-	gorm.Model
-	ID          uint         `gorm:"primaryKey"`
+	BaseModel
 	UserID      uint         `gorm:"index;type:bigint unsigned"`
 	QuizID      uint         `gorm:"index;type:int unsigned"`
 	Score       int          `gorm:"not null"`
 	Total       int          `gorm:"not null"`
 	Answers     map[uint]int `gorm:"type:json"`     // 答案字段
 	Completed   bool         `gorm:"default:false"` // 完成状态字段
-	CompletedAt time.Time
+	CompletedAt JSONTime     `json:"completedAt"`
 }
 
 func (QuizResult) TableName() string {
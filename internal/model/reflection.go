@@ -17,3 +17,21 @@ type Reflection struct {
 func (Reflection) TableName() string {
 	return "reflections"
 }
+
+// ReflectionComment 老师对学生反思的反馈及学生的回复，只追加不修改，反思正文仍由学生本人维护
+// swagger:model
+type ReflectionComment struct {
+	ID           uint     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReflectionID string   `gorm:"index;type:varchar(36);not null;comment:反思ID" json:"reflectionId"`
+	ParentID     *uint    `gorm:"index;comment:回复的评论ID" json:"parentId,omitempty"`
+	AuthorID     uint     `gorm:"index;not null;comment:评论人ID" json:"authorId"`
+	AuthorRole   UserRole `gorm:"type:varchar(20);not null;comment:评论人角色" json:"authorRole"`
+	Content      string   `gorm:"type:text;not null" json:"content"`
+	CreatedAt    JSONTime `json:"createdAt"`
+
+	Author *User `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+}
+
+func (ReflectionComment) TableName() string {
+	return "reflection_comments"
+}
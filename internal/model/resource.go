@@ -1,6 +1,8 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+)
 
 type ResourceType string
 
@@ -36,13 +38,36 @@ type Resource struct {
 	Size        int64          `gorm:"column:size;default:0"`     // 文件大小（字节）
 	Format      string         `gorm:"size:50"`                   // 视频格式
 	Thumbnail   string         `gorm:"size:255"`                  // 缩略图URL
+	Width       int            `gorm:"default:0"`                 // 视频宽度（像素）
+	Height      int            `gorm:"default:0"`                 // 视频高度（像素）
+	Codec       string         `gorm:"size:50"`                   // 视频编码
+	Bitrate     int64          `gorm:"default:0"`                 // 码率（bit/s）
 	Points      int            `gorm:"default:0"`                 // 完成此资源可获得的积分
+	// ProbeStatus 视频元数据探测状态：上传时探测失败为 pending，由后台任务重试，多次失败后为 failed
+	ProbeStatus   ResourceStatus `gorm:"size:20;index"`
+	ProbeAttempts int            `gorm:"default:0"`
+	// Variants 转码后的多分辨率版本，如 {"480p": "url", "720p": "url"}，转码完成前为空
+	Variants json.RawMessage `gorm:"type:json"`
 }
 
 func (Resource) TableName() string {
 	return "resources"
 }
 
+// TranscodeJob 视频转码任务，上传完成后入队，由后台 worker 异步处理
+type TranscodeJob struct {
+	BaseModel
+	ResourceID uint           `gorm:"index;type:bigint unsigned" json:"resourceId"`
+	SourcePath string         `gorm:"size:500;not null" json:"sourcePath"` // 本地待转码源文件
+	Status     ResourceStatus `gorm:"size:20;index;default:'pending'" json:"status"`
+	Attempts   int            `gorm:"default:0" json:"attempts"`
+	LastError  string         `gorm:"type:text" json:"lastError"`
+}
+
+func (TranscodeJob) TableName() string {
+	return "transcode_jobs"
+}
+
 // UploadProgress 跟踪文件上传进度
 type UploadProgress struct {
 	TotalChunks    int          `json:"totalChunks"`
@@ -50,6 +75,14 @@ type UploadProgress struct {
 	FileSize       int64        `json:"fileSize"`
 	Identifier     string       `json:"identifier"`
 	Filename       string       `json:"filename"`
-	CreatedAt      time.Time    `json:"createdAt"`
+	CreatedAt      JSONTime     `json:"createdAt"`
+	UpdatedAt      JSONTime     `json:"updatedAt"` // 最近一次收到分片的时间，用于判定废弃上传
 	Chunks         map[int]bool `json:"chunks"`
+	// ChunkChecksums 已校验通过的分片 MD5，重试时据此跳过重复校验
+	ChunkChecksums map[int]string `json:"chunkChecksums,omitempty"`
+	FileMD5        string         `json:"fileMd5,omitempty"` // 客户端提供的完整文件 MD5
+	// MinIO 分片直传：对象名、multipart uploadID 及各分片 ETag
+	ObjectName string         `json:"objectName,omitempty"`
+	UploadID   string         `json:"uploadId,omitempty"`
+	PartETags  map[int]string `json:"partETags,omitempty"`
 }
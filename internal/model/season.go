@@ -0,0 +1,36 @@
+package model
+
+type SeasonStatus string
+
+const (
+	SeasonActive SeasonStatus = "active"
+	SeasonClosed SeasonStatus = "closed"
+)
+
+// Season 排行榜赛季，赛季内按积分流水统计排名，累计积分不受影响
+// swagger:model Season
+type Season struct {
+	BaseModel
+	Name    string       `gorm:"size:100;not null" json:"name"`
+	StartAt JSONTime     `gorm:"not null" json:"startAt"`
+	EndAt   JSONTime     `gorm:"not null;index" json:"endAt"`
+	Status  SeasonStatus `gorm:"type:enum('active','closed');default:'active';index" json:"status"`
+}
+
+func (Season) TableName() string {
+	return "seasons"
+}
+
+// SeasonResult 赛季结束时的最终排名快照
+// swagger:model SeasonResult
+type SeasonResult struct {
+	BaseModel
+	SeasonID uint `gorm:"index;uniqueIndex:idx_season_user;type:bigint unsigned;not null" json:"seasonId"`
+	UserID   uint `gorm:"uniqueIndex:idx_season_user;type:bigint unsigned;not null" json:"userId"`
+	Rank     int  `gorm:"not null" json:"rank"`
+	Points   int  `gorm:"not null" json:"points"`
+}
+
+func (SeasonResult) TableName() string {
+	return "season_results"
+}
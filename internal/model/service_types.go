@@ -1,7 +1,5 @@
 package model
 
-import "time"
-
 type LearningPath struct {
 	Customized bool                 `json:"customized"`
 	Modules    []LearningPathModule `json:"modules"`
@@ -21,9 +19,9 @@ type DiagnosticTest struct {
 }
 
 type LearningGoal struct {
-	Type        string    `json:"type"`
-	Description string    `json:"description"`
-	TargetDate  time.Time `json:"targetDate"`
+	Type        string   `json:"type"`
+	Description string   `json:"description"`
+	TargetDate  JSONTime `json:"targetDate"`
 }
 type LearningQuestion struct {
 	ID      uint     `json:"id"`
@@ -42,9 +40,27 @@ type Quiz struct {
 }
 
 type PersonalizedRecommendation struct {
-	TimeManagement       string   `json:"timeManagement"`
-	FocusAreas           []string `json:"focusAreas"`
-	CommunitySuggestions []string `json:"communitySuggestions"`
-	ReviewTopics         []string `json:"reviewTopics"`
-	ChallengeTasks       []string `json:"challengeTasks"`
+	TimeManagement       string               `json:"timeManagement"`
+	FocusAreas           []string             `json:"focusAreas"`
+	CommunitySuggestions []string             `json:"communitySuggestions"`
+	ReviewTopics         []string             `json:"reviewTopics"`
+	ChallengeTasks       []string             `json:"challengeTasks"`
+	Items                []RecommendationItem `json:"items"` // 按 Score 降序排列的具体推荐内容
+}
+
+// 推荐来源信号
+const (
+	RecommendationSignalRecentFailure    = "recent_failure"
+	RecommendationSignalWeakAbility      = "weak_ability"
+	RecommendationSignalIncompletePrereq = "incomplete_prerequisite"
+)
+
+// RecommendationItem 单条推荐内容及其推荐理由
+type RecommendationItem struct {
+	Type     string  `json:"type"`     // level / knowledge_point
+	TargetID string  `json:"targetId"` // 关卡ID或知识点ID
+	Title    string  `json:"title"`
+	Signal   string  `json:"signal"` // 产生该推荐的信号
+	Reason   string  `json:"reason"`
+	Score    float64 `json:"score"` // 推荐优先级 0-100
 }
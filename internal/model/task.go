@@ -1,11 +1,5 @@
 package model
 
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
-
 type TaskStatus string
 
 const (
@@ -37,17 +31,16 @@ const (
 )
 
 type Task struct {
-	gorm.Model
-	// ID          uint       `gorm:"primaryKey"`
+	BaseModel
 	Title       string     `gorm:"size:255;not null"`
 	Description string     `gorm:"type:text"`
 	ModuleType  string     `gorm:"size:50;not null"` // pre-class, in-class, post-class
 	Status      TaskStatus `gorm:"type:enum('pending','in_progress','completed');default:'pending'"`
 	UserID      uint       `gorm:"index;type:bigint unsigned"`
 	ModuleID    uint       `gorm:"index;type:bigint unsigned"`
-	DueDate     time.Time
-	Order       int    `gorm:"default:0"`
-	Difficulty  string `gorm:"size:10"` // 难度字段
+	DueDate     JSONTime   `json:"dueDate"`
+	Order       int        `gorm:"default:0"`
+	Difficulty  string     `gorm:"size:10"` // 难度字段
 }
 
 func (Task) TableName() string {
@@ -61,8 +54,10 @@ type TeacherWeeklyTask struct {
 	TeacherID          uint       `gorm:"index" json:"teacherId"`
 	ResourceModuleID   uint       `gorm:"index" json:"resourceModuleId"`
 	ResourceModuleName string     `json:"resourceModuleName"`
-	WeekStartDate      time.Time  `gorm:"index" json:"weekStartDate"` // 周开始日期（周一）
-	WeekEndDate        time.Time  `gorm:"index" json:"weekEndDate"`   // 周结束日期（周日）
+	WeekStartDate      JSONTime   `gorm:"index" json:"weekStartDate"`            // 周开始日期（周一）
+	WeekEndDate        JSONTime   `gorm:"index" json:"weekEndDate"`              // 周结束日期（周日）
+	TemplateID         uint       `gorm:"index" json:"templateId,omitempty"`     // 由周任务模板自动生成时记录模板ID
+	IsDraft            bool       `gorm:"not null;default:false" json:"isDraft"` // 草稿对学生不可见，老师确认后发布
 	TaskItems          []TaskItem `gorm:"foreignKey:WeeklyTaskID" json:"taskItems,omitempty"`
 }
 
@@ -70,6 +65,29 @@ func (TeacherWeeklyTask) TableName() string {
 	return "teacher_weekly_tasks"
 }
 
+// WeeklyTaskTemplate 老师为某个资源分类保存的周任务模板，开启自动生成后每周按模板生成下周任务草稿
+// swagger:model WeeklyTaskTemplate
+type WeeklyTaskTemplate struct {
+	BaseModel
+	TeacherID            uint                     `gorm:"uniqueIndex:idx_weekly_template_teacher_module;not null" json:"teacherId"`
+	ResourceModuleID     uint                     `gorm:"uniqueIndex:idx_weekly_template_teacher_module;not null" json:"resourceModuleId"`
+	Items                []WeeklyTaskTemplateItem `gorm:"type:json;serializer:json" json:"items"`
+	AutoInstantiate      bool                     `gorm:"not null;default:false" json:"autoInstantiate"`
+	LastInstantiatedWeek *JSONTime                `json:"lastInstantiatedWeek"` // 最近一次生成任务的周一，用于防止重复生成
+}
+
+func (WeeklyTaskTemplate) TableName() string {
+	return "weekly_task_templates"
+}
+
+// WeeklyTaskTemplateItem 模板中的任务项，标题等信息在生成任务时从资源重新读取
+type WeeklyTaskTemplateItem struct {
+	DayOfWeek  Weekday      `json:"dayOfWeek"`
+	ItemType   TaskItemType `json:"itemType"`
+	ResourceID uint         `json:"resourceId,omitempty"`
+	ExerciseID uint         `json:"exerciseId,omitempty"`
+}
+
 // TaskItem 任务项
 type TaskItem struct {
 	BaseModel
@@ -91,12 +109,12 @@ func (TaskItem) TableName() string {
 // DailyTaskCompletion 每日任务完成状态
 type DailyTaskCompletion struct {
 	BaseModel
-	UserID            uint      `gorm:"index" json:"userId"`
-	TaskItemID        uint      `gorm:"index" json:"taskItemId"`
-	CompletionDate    time.Time `gorm:"index" json:"completionDate"`
-	IsCompleted       bool      `gorm:"default:false" json:"isCompleted"`
-	Progress          float64   `gorm:"default:0" json:"progress"`              // 0-100
-	ResourceCompleted bool      `gorm:"default:false" json:"resourceCompleted"` // 对应资源是否完成
+	UserID            uint     `gorm:"index" json:"userId"`
+	TaskItemID        uint     `gorm:"index" json:"taskItemId"`
+	CompletionDate    JSONTime `gorm:"index" json:"completionDate"`
+	IsCompleted       bool     `gorm:"default:false" json:"isCompleted"`
+	Progress          float64  `gorm:"default:0" json:"progress"`              // 0-100
+	ResourceCompleted bool     `gorm:"default:false" json:"resourceCompleted"` // 对应资源是否完成
 }
 
 func (DailyTaskCompletion) TableName() string {
@@ -1,9 +1,5 @@
 package model
 
-import (
-	"time"
-)
-
 type UserRole string
 
 const (
@@ -15,18 +11,21 @@ const (
 // swagger:model User
 type User struct {
 	BaseModel
-	Name              string    `gorm:"size:100;not null" json:"Name"`
-	Email             string    `gorm:"size:100;unique;not null" json:"Email"`
-	Password          string    `gorm:"size:100;not null" json:"-"`
-	Role              UserRole  `gorm:"type:enum('student','teacher','admin');default:'student'" json:"Role"`
-	XP                int       `gorm:"default:0" json:"XP"`     // 总经验/等级积分
-	Points            int       `gorm:"default:0" json:"Points"` // 独立积分系统（课中知识点测试积分）
-	Language          string    `gorm:"size:10;default:'en'" json:"Language"`
-	Avatar            string    `gorm:"size:255" json:"avatar"`
-	Disabled          bool      `gorm:"default:false" json:"Disabled"`
-	CanTakeAssessment bool      `gorm:"default:true" json:"canTakeAssessment"`
-	LastLogin         time.Time `gorm:"default:CURRENT_TIMESTAMP(3)" json:"LastLogin"`
-	LastSeen          time.Time `gorm:"default:CURRENT_TIMESTAMP(3)" json:"LastSeen"`
+	Name              string   `gorm:"size:100;not null" json:"Name"`
+	Email             string   `gorm:"size:100;unique;not null" json:"Email"`
+	Password          string   `gorm:"size:100;not null" json:"-"`
+	Role              UserRole `gorm:"type:enum('student','teacher','admin');default:'student'" json:"Role"`
+	XP                int      `gorm:"default:0" json:"XP"`     // 总经验/等级积分
+	Points            int      `gorm:"default:0" json:"Points"` // 独立积分系统（课中知识点测试积分）
+	Language          string   `gorm:"size:10;default:'en'" json:"Language"`
+	Avatar            string   `gorm:"size:255" json:"avatar"`
+	Disabled          bool     `gorm:"default:false" json:"Disabled"`
+	CanTakeAssessment bool     `gorm:"default:true" json:"canTakeAssessment"`
+	LastLogin         JSONTime `gorm:"default:CURRENT_TIMESTAMP(3)" json:"lastLogin"`
+	LastSeen          JSONTime `gorm:"default:CURRENT_TIMESTAMP(3)" json:"lastSeen"`
+	HideOnline        bool     `gorm:"default:false" json:"hideOnline"` // 对他人隐藏在线状态
+	// 为 true 时智能问答不使用个人学习记录（练习记录、学习进度）作为上下文，默认使用
+	QAPersonalizationDisabled bool `gorm:"default:false" json:"qaPersonalizationDisabled"`
 }
 
 func (User) TableName() string {
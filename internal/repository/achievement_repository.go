@@ -4,6 +4,7 @@ import (
 	"coder_edu_backend/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AchievementRepository struct {
@@ -24,3 +25,25 @@ func (r *AchievementRepository) FindByUserID(userID uint) ([]model.Achievement,
 	}
 	return achievements, nil
 }
+
+// AwardOnce 发放成就并累加经验。SourceKey 已存在时不重复发放，返回 false
+func (r *AchievementRepository) AwardOnce(achievement *model.Achievement) (bool, error) {
+	awarded := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(achievement)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		awarded = true
+		if achievement.EarnedXP == 0 {
+			return nil
+		}
+		return tx.Model(&model.User{}).
+			Where("id = ?", achievement.UserID).
+			UpdateColumn("xp", gorm.Expr("xp + ?", achievement.EarnedXP)).Error
+	})
+	return awarded, err
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"coder_edu_backend/internal/model"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -31,6 +32,33 @@ func (r *SessionRepository) Update(session *model.LearningSession) error {
 	return r.DB.Save(session).Error
 }
 
+// Touch 更新未结束会话的心跳时间，会话不存在、不属于该用户或已结束时返回 false
+func (r *SessionRepository) Touch(sessionID, userID uint, at time.Time) (bool, error) {
+	result := r.DB.Model(&model.LearningSession{}).
+		Where("id = ? AND user_id = ? AND end_time IS NULL", sessionID, userID).
+		Update("last_seen_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindStale 获取在 cutoff 之前最后活跃（心跳或开始时间）且仍未结束的会话
+func (r *SessionRepository) FindStale(cutoff time.Time, limit int) ([]model.LearningSession, error) {
+	var sessions []model.LearningSession
+	err := r.DB.Where("end_time IS NULL AND COALESCE(last_seen_at, start_time) < ?", cutoff).
+		Limit(limit).
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// LastActivityAt 返回用户在 since 之后最近一次学习记录的更新时间，没有记录时返回零值
+func (r *SessionRepository) LastActivityAt(userID uint, since time.Time) (model.JSONTime, error) {
+	var last model.JSONTime
+	err := r.DB.Model(&model.LearningLog{}).
+		Where("user_id = ? AND updated_at >= ?", userID, since).
+		Select("MAX(updated_at)").
+		Row().Scan(&last)
+	return last, err
+}
+
 type SkillRepository struct {
 	DB *gorm.DB
 }
@@ -67,6 +95,86 @@ func NewRecommendationRepository(db *gorm.DB) *RecommendationRepository {
 	return &RecommendationRepository{DB: db}
 }
 
+// FailedLevel 近期挑战失败且尚未通过的关卡
+type FailedLevel struct {
+	LevelID     uint
+	Title       string
+	BestScore   int
+	LastEndedAt time.Time
+}
+
+// FindRecentFailedLevels 查询 since 之后有失败尝试、且从未通过的关卡，只包含当前对学生可见的关卡，按最近失败时间倒序
+func (r *RecommendationRepository) FindRecentFailedLevels(userID uint, since time.Time, limit int) ([]FailedLevel, error) {
+	visible := r.DB.Model(&model.Level{}).Select("id").Scopes(LevelsVisibleToStudent(userID))
+	var levels []FailedLevel
+	err := r.DB.Table("level_attempts att").
+		Select("att.level_id, l.title, MAX(att.score) AS best_score, MAX(att.ended_at) AS last_ended_at").
+		Joins("JOIN levels l ON l.id = att.level_id").
+		Where("att.level_id IN (?)", visible).
+		Where("att.user_id = ? AND att.success = ? AND att.ended_at >= ? AND att.deleted_at IS NULL", userID, false, since).
+		Where("NOT EXISTS (SELECT 1 FROM level_attempts ok WHERE ok.user_id = att.user_id AND ok.level_id = att.level_id AND ok.success = ? AND ok.deleted_at IS NULL)", true).
+		Group("att.level_id, l.title").
+		Order("last_ended_at DESC").
+		Limit(limit).
+		Scan(&levels).Error
+	return levels, err
+}
+
+// AbilityLevel 关联某项能力的关卡
+type AbilityLevel struct {
+	AbilityID uint
+	LevelID   uint
+	Title     string
+}
+
+// FindUnpassedLevelsByAbilities 一次查询多项能力关联的、用户尚未通过且当前可见的关卡，每项能力最多返回 perAbility 个
+func (r *RecommendationRepository) FindUnpassedLevelsByAbilities(userID uint, abilityIDs []uint, perAbility int) ([]AbilityLevel, error) {
+	if len(abilityIDs) == 0 {
+		return nil, nil
+	}
+	visible := r.DB.Model(&model.Level{}).Select("id").Scopes(LevelsVisibleToStudent(userID))
+	ranked := r.DB.Table("level_abilities la").
+		Select("la.ability_id, l.id AS level_id, l.title, ROW_NUMBER() OVER (PARTITION BY la.ability_id ORDER BY l.id ASC) AS rn").
+		Joins("JOIN levels l ON l.id = la.level_id").
+		Where("la.ability_id IN ? AND la.deleted_at IS NULL", abilityIDs).
+		Where("l.id IN (?)", visible).
+		Where("NOT EXISTS (SELECT 1 FROM level_attempts ok WHERE ok.user_id = ? AND ok.level_id = l.id AND ok.success = ? AND ok.deleted_at IS NULL)", userID, true)
+
+	var levels []AbilityLevel
+	err := r.DB.Table("(?) ranked", ranked).
+		Select("ability_id, level_id, title").
+		Where("rn <= ?", perAbility).
+		Order("ability_id ASC, level_id ASC").
+		Scan(&levels).Error
+	return levels, err
+}
+
+// FindLatestCompletedKnowledgePoint 查询用户已完成的知识点中学习顺序最靠后的一个，没有时返回 nil
+func (r *RecommendationRepository) FindLatestCompletedKnowledgePoint(userID uint) (*model.KnowledgePoint, error) {
+	var points []model.KnowledgePoint
+	err := r.DB.Model(&model.KnowledgePoint{}).
+		Joins("JOIN knowledge_point_completions c ON c.knowledge_point_id = knowledge_points.id AND c.user_id = ? AND c.is_completed = ?", userID, true).
+		Order("knowledge_points.`order` DESC, knowledge_points.created_at DESC").
+		Limit(1).
+		Find(&points).Error
+	if err != nil || len(points) == 0 {
+		return nil, err
+	}
+	return &points[0], nil
+}
+
+// FindIncompleteKnowledgePointsBefore 按学习顺序查询排在 order 之前、用户尚未完成的知识点，即已学内容的前置知识点
+func (r *RecommendationRepository) FindIncompleteKnowledgePointsBefore(userID uint, order int, limit int) ([]model.KnowledgePoint, error) {
+	var points []model.KnowledgePoint
+	err := r.DB.Model(&model.KnowledgePoint{}).
+		Where("`order` < ?", order).
+		Where("NOT EXISTS (SELECT 1 FROM knowledge_point_completions c WHERE c.user_id = ? AND c.knowledge_point_id = knowledge_points.id AND c.is_completed = ?)", userID, true).
+		Order("`order` ASC, created_at ASC").
+		Limit(limit).
+		Find(&points).Error
+	return points, err
+}
+
 func (r *RecommendationRepository) GenerateForUser(userID uint) (*model.PersonalizedRecommendation, error) {
 	// 基于用户数据生成个性化建议
 	// 模拟数据
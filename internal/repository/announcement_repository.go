@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"coder_edu_backend/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AnnouncementRepository struct {
+	DB *gorm.DB
+}
+
+func NewAnnouncementRepository(db *gorm.DB) *AnnouncementRepository {
+	return &AnnouncementRepository{DB: db}
+}
+
+// AnnouncementItem 学生端公告列表项，附带本人的已读时间
+type AnnouncementItem struct {
+	model.Announcement
+	ReadAt *model.JSONTime `json:"readAt"`
+}
+
+// ActiveStudentIDs 获取所有未禁用学生的ID
+func (r *AnnouncementRepository) ActiveStudentIDs() ([]uint, error) {
+	var ids []uint
+	err := r.DB.Model(&model.User{}).
+		Where("role = ? AND disabled = ?", model.Student, false).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// CreateWithRecipients 在同一事务中写入公告和接收人记录
+func (r *AnnouncementRepository) CreateWithRecipients(a *model.Announcement, userIDs []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		a.RecipientCount = len(userIDs)
+		if err := tx.Create(a).Error; err != nil {
+			return err
+		}
+		if len(userIDs) == 0 {
+			return nil
+		}
+		now := model.NewJSONTime(time.Now())
+		recipients := make([]model.AnnouncementRecipient, 0, len(userIDs))
+		for _, uid := range userIDs {
+			recipients = append(recipients, model.AnnouncementRecipient{
+				AnnouncementID: a.ID,
+				UserID:         uid,
+				CreatedAt:      now,
+			})
+		}
+		return tx.CreateInBatches(recipients, 500).Error
+	})
+}
+
+// ListForUser 分页获取用户收到的公告（按发布时间倒序）及总数
+func (r *AnnouncementRepository) ListForUser(userID uint, unreadOnly bool, limit, offset int) ([]AnnouncementItem, int64, error) {
+	query := r.DB.Model(&model.Announcement{}).
+		Joins("JOIN announcement_recipients ar ON ar.announcement_id = announcements.id AND ar.user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("ar.read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	items := []AnnouncementItem{}
+	if total == 0 {
+		return items, 0, nil
+	}
+
+	var announcements []model.Announcement
+	if err := query.Preload("Teacher").
+		Order("announcements.created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&announcements).Error; err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]uint, 0, len(announcements))
+	for _, a := range announcements {
+		ids = append(ids, a.ID)
+	}
+	var recipients []model.AnnouncementRecipient
+	if err := r.DB.Where("user_id = ? AND announcement_id IN ?", userID, ids).Find(&recipients).Error; err != nil {
+		return nil, 0, err
+	}
+	readAt := make(map[uint]*model.JSONTime, len(recipients))
+	for _, rc := range recipients {
+		readAt[rc.AnnouncementID] = rc.ReadAt
+	}
+
+	for _, a := range announcements {
+		items = append(items, AnnouncementItem{Announcement: a, ReadAt: readAt[a.ID]})
+	}
+	return items, total, nil
+}
+
+// CountUnread 统计用户未读公告数
+func (r *AnnouncementRepository) CountUnread(userID uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.AnnouncementRecipient{}).
+		Joins("JOIN announcements ON announcements.id = announcement_recipients.announcement_id AND announcements.deleted_at IS NULL").
+		Where("announcement_recipients.user_id = ? AND announcement_recipients.read_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkRead 将用户的指定公告标记为已读，ids 为空时标记全部，返回实际更新的条数
+func (r *AnnouncementRepository) MarkRead(userID uint, ids []uint) (int64, error) {
+	query := r.DB.Model(&model.AnnouncementRecipient{}).
+		Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		query = query.Where("announcement_id IN ?", ids)
+	}
+	result := query.Update("read_at", model.NewJSONTime(time.Now()))
+	return result.RowsAffected, result.Error
+}
@@ -68,6 +68,19 @@ func (r *AssessmentRepository) FindAssessmentByID(id uint) (*model.Assessment, e
 	return &a, err
 }
 
+func (r *AssessmentRepository) UpdateAssessment(a *model.Assessment) error {
+	return r.DB.Save(a).Error
+}
+
+// FindOrCreateShuffleSeed 获取学生在评估中的乱序种子，不存在时以 seed 创建
+func (r *AssessmentRepository) FindOrCreateShuffleSeed(assessmentID, userID uint, seed int64) (int64, error) {
+	record := model.AssessmentShuffleSeed{AssessmentID: assessmentID, UserID: userID}
+	err := r.DB.Where(model.AssessmentShuffleSeed{AssessmentID: assessmentID, UserID: userID}).
+		Attrs(model.AssessmentShuffleSeed{Seed: seed}).
+		FirstOrCreate(&record).Error
+	return record.Seed, err
+}
+
 func (r *AssessmentRepository) ListAssessments(page, limit int) ([]model.Assessment, int64, error) {
 	var as []model.Assessment
 	var total int64
@@ -155,6 +168,15 @@ func (r *AssessmentRepository) UpdateSubmission(s *model.AssessmentSubmission) e
 	return r.DB.Save(s).Error
 }
 
+// ListSubmissionAnswers 获取评估所有提交的答案 JSON
+func (r *AssessmentRepository) ListSubmissionAnswers(assessmentID uint) ([]string, error) {
+	var answers []string
+	err := r.DB.Model(&model.AssessmentSubmission{}).
+		Where("assessment_id = ? AND answers IS NOT NULL", assessmentID).
+		Pluck("answers", &answers).Error
+	return answers, err
+}
+
 func (r *AssessmentRepository) FindSubmissionByUserAndAssessment(userID, assessmentID uint) (*model.AssessmentSubmission, error) {
 	var s model.AssessmentSubmission
 	err := r.DB.Where("user_id = ? AND assessment_id = ?", userID, assessmentID).First(&s).Error
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"coder_edu_backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type AuditLogRepository struct {
+	DB *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{DB: db}
+}
+
+func (r *AuditLogRepository) Create(log *model.AuditLog) error {
+	return r.DB.Create(log).Error
+}
+
+// List 按操作类型和对象分页查询审计记录（按时间倒序），targetID 为空时不按对象过滤
+func (r *AuditLogRepository) List(action, targetType, targetID string, limit, offset int) ([]model.AuditLog, int64, error) {
+	query := r.DB.Model(&model.AuditLog{}).Where("action = ? AND target_type = ?", action, targetType)
+	if targetID != "" {
+		query = query.Where("target_id = ?", targetID)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	logs := make([]model.AuditLog, 0)
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&logs).Error
+	return logs, total, err
+}
@@ -97,6 +97,100 @@ func (r *CProgrammingResourceRepository) FindAll(page, limit int, search string,
 	return resources, int(total), err
 }
 
+// ResourceStatsFilter 管理端资源模块列表的筛选条件
+type ResourceStatsFilter struct {
+	Search       string
+	Enabled      *bool
+	HasVideos    *bool // 是否包含视频
+	HasQuestions *bool // 是否包含练习题
+	MinItems     int   // 视频、文章、练习题总数下限，0 表示不限制
+	SortBy       string
+	SortOrder    string
+}
+
+// ResourceWithStats 资源模块及其内容统计
+type ResourceWithStats struct {
+	model.CProgrammingResource
+	VideoCount            int64
+	ArticleCount          int64
+	ExerciseCategoryCount int64
+	QuestionCount         int64
+}
+
+// ItemCount 模块内容总数（视频+文章+练习题）
+func (r ResourceWithStats) ItemCount() int64 {
+	return r.VideoCount + r.ArticleCount + r.QuestionCount
+}
+
+const (
+	resourceVideoCountSQL    = "(SELECT COUNT(*) FROM resources r WHERE r.module_id = c_programming_resources.id AND r.type = 'video' AND r.deleted_at IS NULL)"
+	resourceArticleCountSQL  = "(SELECT COUNT(*) FROM resources r WHERE r.module_id = c_programming_resources.id AND r.type = 'article' AND r.deleted_at IS NULL)"
+	resourceCategoryCountSQL = "(SELECT COUNT(*) FROM exercise_categories ec WHERE ec.c_programming_res_id = c_programming_resources.id AND ec.deleted_at IS NULL)"
+	resourceQuestionCountSQL = "(SELECT COUNT(*) FROM exercise_questions q JOIN exercise_categories ec ON ec.id = q.category_id WHERE ec.c_programming_res_id = c_programming_resources.id AND ec.deleted_at IS NULL AND q.deleted_at IS NULL)"
+	resourceItemCountSQL     = "(" + resourceVideoCountSQL + " + " + resourceArticleCountSQL + " + " + resourceQuestionCountSQL + ")"
+)
+
+// FindAllWithStats 分页获取资源模块及内容统计，统计在同一条 SQL 中以子查询计算，
+// 按内容筛选和按内容总数排序都在数据库中完成，分页总数与筛选结果一致
+func (r *CProgrammingResourceRepository) FindAllWithStats(page, limit int, f ResourceStatsFilter) ([]ResourceWithStats, int, error) {
+	query := r.DB.Model(&model.CProgrammingResource{})
+	if f.Search != "" {
+		query = query.Where("name LIKE ? OR description LIKE ?", "%"+f.Search+"%", "%"+f.Search+"%")
+	}
+	if f.Enabled != nil {
+		query = query.Where("enabled = ?", *f.Enabled)
+	}
+	if f.HasVideos != nil {
+		if *f.HasVideos {
+			query = query.Where(resourceVideoCountSQL + " > 0")
+		} else {
+			query = query.Where(resourceVideoCountSQL + " = 0")
+		}
+	}
+	if f.HasQuestions != nil {
+		if *f.HasQuestions {
+			query = query.Where(resourceQuestionCountSQL + " > 0")
+		} else {
+			query = query.Where(resourceQuestionCountSQL + " = 0")
+		}
+	}
+	if f.MinItems > 0 {
+		query = query.Where(resourceItemCountSQL+" >= ?", f.MinItems)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	fieldMap := map[string]string{
+		"name":      "name",
+		"order":     "`order`",
+		"createdAt": "created_at",
+		"updatedAt": "updated_at",
+		"itemCount": resourceItemCountSQL,
+	}
+	orderField, ok := fieldMap[f.SortBy]
+	if !ok {
+		orderField = "`order`"
+	}
+	orderDirection := "ASC"
+	if f.SortOrder == "desc" {
+		orderDirection = "DESC"
+	}
+
+	var rows []ResourceWithStats
+	err := query.Select("c_programming_resources.*, " +
+		resourceVideoCountSQL + " AS video_count, " +
+		resourceArticleCountSQL + " AS article_count, " +
+		resourceCategoryCountSQL + " AS exercise_category_count, " +
+		resourceQuestionCountSQL + " AS question_count").
+		Order(orderField + " " + orderDirection + ", id ASC").
+		Offset((page - 1) * limit).Limit(limit).
+		Scan(&rows).Error
+	return rows, int(total), err
+}
+
 // ExerciseCategoryRepository 处理练习题分类的数据访问
 
 type ExerciseCategoryRepository struct {
@@ -119,6 +213,26 @@ func (r *ExerciseCategoryRepository) FindByResourceID(resourceID uint) ([]model.
 	return categories, err
 }
 
+// FindByResourceIDs 批量获取多个资源模块下的练习分类
+func (r *ExerciseCategoryRepository) FindByResourceIDs(resourceIDs []uint) ([]model.ExerciseCategory, error) {
+	var categories []model.ExerciseCategory
+	if len(resourceIDs) == 0 {
+		return categories, nil
+	}
+	err := r.DB.Where("c_programming_res_id IN ?", resourceIDs).Order("id").Find(&categories).Error
+	return categories, err
+}
+
+// FindByIDs 批量查找练习题分类
+func (r *ExerciseCategoryRepository) FindByIDs(ids []uint) ([]model.ExerciseCategory, error) {
+	var categories []model.ExerciseCategory
+	if len(ids) == 0 {
+		return categories, nil
+	}
+	err := r.DB.Where("id IN ?", ids).Find(&categories).Error
+	return categories, err
+}
+
 func (r *ExerciseCategoryRepository) UpdateFields(id uint, updates map[string]interface{}) error {
 	return r.DB.Model(&model.ExerciseCategory{}).Where("id = ?", id).Updates(updates).Error
 }
@@ -164,6 +278,16 @@ func (r *ExerciseQuestionRepository) FindByCategoryID(categoryID uint, page, lim
 	return questions, int(total), err
 }
 
+// FindByCategoryIDs 批量获取多个分类下的全部题目
+func (r *ExerciseQuestionRepository) FindByCategoryIDs(categoryIDs []uint) ([]model.ExerciseQuestion, error) {
+	var questions []model.ExerciseQuestion
+	if len(categoryIDs) == 0 {
+		return questions, nil
+	}
+	err := r.DB.Where("category_id IN ?", categoryIDs).Order("id").Find(&questions).Error
+	return questions, err
+}
+
 func (r *ExerciseQuestionRepository) UpdateFields(id uint, updates map[string]interface{}) error {
 	return r.DB.Model(&model.ExerciseQuestion{}).Where("id = ?", id).Updates(updates).Error
 }
@@ -174,8 +298,20 @@ func (r *ExerciseQuestionRepository) FindByID(id uint) (*model.ExerciseQuestion,
 	return &question, err
 }
 
+// UpdateQuestion 保存题目，建议难度相关字段只由校准任务维护，不随题目编辑覆盖
 func (r *ExerciseQuestionRepository) UpdateQuestion(question *model.ExerciseQuestion) error {
-	return r.DB.Save(question).Error
+	return r.DB.Omit("suggested_difficulty", "submission_count", "correct_rate", "difficulty_calibrated_at").
+		Save(question).Error
+}
+
+// UpdateDifficultyStats 写入题目的提交统计和建议难度，不更新 updated_at
+func (r *ExerciseQuestionRepository) UpdateDifficultyStats(id uint, suggested string, count int, rate float64, calibratedAt model.JSONTime) error {
+	return r.DB.Model(&model.ExerciseQuestion{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"suggested_difficulty":     suggested,
+		"submission_count":         count,
+		"correct_rate":             rate,
+		"difficulty_calibrated_at": calibratedAt,
+	}).Error
 }
 
 func (r *ExerciseQuestionRepository) FindAllByCategoryID(categoryID uint) ([]model.ExerciseQuestion, error) {
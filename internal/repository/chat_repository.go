@@ -2,13 +2,21 @@ package repository
 
 import (
 	"coder_edu_backend/internal/model"
+	"coder_edu_backend/pkg/logger"
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ChatRepository struct {
@@ -17,40 +25,126 @@ type ChatRepository struct {
 	ctx        context.Context
 	streamName string
 	groupName  string
+	deadStream string // 多次写库失败或无法解析的消息移入的死信 Stream，供人工排查
 	bufferSize int
+
+	stopConsumer context.CancelFunc // 通知消费者停止读取新消息
+	consumerDone chan struct{}      // 消费者处理完剩余消息并退出后关闭
+	asyncWrites  sync.WaitGroup     // 发送消息时异步执行的缓存写入、取消隐藏等任务
+
+	persistedHook atomic.Pointer[func([]*model.Message)] // 消息写入数据库后的回调
 }
 
+const (
+	// 关闭时消费者处理剩余消息的时间上限
+	streamDrainTimeout = 10 * time.Second
+	// 消息的最大投递次数，仍写库失败则移入死信 Stream，避免一条问题消息反复重试
+	maxStreamDeliveries = 5
+	// 其他消费者（通常是已退出的进程）超过该时长未确认的消息会被当前消费者认领重试
+	streamClaimMinIdle = time.Minute
+	// 检查可认领消息的间隔
+	streamClaimInterval = 30 * time.Second
+	// 死信 Stream 保留的最大条数（近似）
+	deadStreamMaxLen = 10000
+)
+
 func NewChatRepository(db *gorm.DB, rdb *redis.Client) *ChatRepository {
 	r := &ChatRepository{
-		DB:         db,
-		Redis:      rdb,
-		ctx:        context.Background(),
-		streamName: "chat:messages:stream",
-		groupName:  "chat:messages:group",
-		bufferSize: 100,
+		DB:           db,
+		Redis:        rdb,
+		ctx:          context.Background(),
+		streamName:   "chat:messages:stream",
+		groupName:    "chat:messages:group",
+		deadStream:   "chat:messages:dead",
+		bufferSize:   100,
+		consumerDone: make(chan struct{}),
 	}
 
-	if rdb != nil {
-		// 初始化Redis Stream消费组
-		rdb.XGroupCreateMkStream(r.ctx, r.streamName, r.groupName, "0")
-		// 启动后台Redis Stream消费者
-		go r.messageStreamConsumer()
+	r.startConsumer()
+	return r
+}
+
+// startConsumer 初始化消费组并启动后台 Stream 消费者，未配置 Redis 时直接标记消费者已退出
+func (r *ChatRepository) startConsumer() {
+	if r.Redis == nil {
+		close(r.consumerDone)
+		return
 	}
+	r.Redis.XGroupCreateMkStream(r.ctx, r.streamName, r.groupName, "0")
+	consumerCtx, cancel := context.WithCancel(context.Background())
+	r.stopConsumer = cancel
+	go r.messageStreamConsumer(consumerCtx)
+}
 
-	return r
+// OnMessagesPersisted 注册消息写入数据库后的回调（Stream 消费者批量落库或降级同步写入后触发），
+// 未读数等依赖数据库的统计应在此时更新，而不是在消息进入 Stream 时
+func (r *ChatRepository) OnMessagesPersisted(fn func(messages []*model.Message)) {
+	r.persistedHook.Store(&fn)
+}
+
+func (r *ChatRepository) notifyPersisted(messages []*model.Message) {
+	if fn := r.persistedHook.Load(); fn != nil && len(messages) > 0 {
+		(*fn)(messages)
+	}
+}
+
+// Shutdown 停止消息消费者：处理完当前批次后，在 streamDrainTimeout 内将 Stream 中剩余的消息写入数据库并确认，
+// 同时等待发送消息时启动的异步任务结束。应在 HTTP 服务停止接收请求之后、关闭数据库和 Redis 之前调用
+func (r *ChatRepository) Shutdown(timeout time.Duration) error {
+	if r.stopConsumer != nil {
+		r.stopConsumer()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		<-r.consumerDone
+		r.asyncWrites.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("chat message consumer did not stop within %s", timeout)
+	}
 }
 
 func (r *ChatRepository) CreateConversation(conv *model.Conversation) error {
+	if conv.LastMessageAt == nil {
+		now := model.NewJSONTime(time.Now())
+		conv.LastMessageAt = &now
+	}
 	return r.DB.Create(conv).Error
 }
 
+// touchConversation 记录会话的最后消息时间，同时刷新活跃时间
+func touchConversation(db *gorm.DB, convID string, at time.Time) error {
+	return db.Model(&model.Conversation{}).Where("id = ?", convID).
+		Updates(map[string]interface{}{"updated_at": at, "last_message_at": at}).Error
+}
+
 func (r *ChatRepository) GetConversation(id string) (*model.Conversation, error) {
 	var conv model.Conversation
 	err := r.DB.Preload("Members.User").First(&conv, "id = ?", id).Error
 	return &conv, err
 }
 
-func (r *ChatRepository) GetUserConversations(userID uint, query string, limit, offset int) ([]model.Conversation, int64, error) {
+// 会话列表排序方式
+const (
+	ConversationSortLastMessage = "last_message" // 按最后一条消息时间倒序（默认）
+	ConversationSortUpdated     = "updated"      // 按会话更新时间倒序，包含改名、换头像等操作
+)
+
+// ConversationListFilter 会话列表的筛选和排序条件
+type ConversationListFilter struct {
+	Query       string // 群聊匹配群名，私聊匹配对方的用户名或邮箱
+	PrefixMatch bool   // 按前缀匹配，可以走 name、email 上的索引；否则为包含匹配
+	Type        string // private、group 或 saved，为空时不限
+	SortBy      string
+}
+
+func (r *ChatRepository) GetUserConversations(userID uint, f ConversationListFilter, limit, offset int) ([]model.Conversation, int64, error) {
 	var convs []model.Conversation
 	var total int64
 
@@ -59,9 +153,23 @@ func (r *ChatRepository) GetUserConversations(userID uint, query string, limit,
 		Where("conversation_members.user_id = ?", userID).
 		Where("conversation_members.hidden_at IS NULL") // 过滤掉用户隐藏的会话
 
-	if query != "" {
-		searchTerm := "%" + query + "%"
-		db = db.Where("conversations.name LIKE ?", searchTerm)
+	if f.Type != "" {
+		db = db.Where("conversations.type = ?", f.Type)
+	}
+
+	if f.Query != "" {
+		// 群聊按群名匹配；私聊按对方的用户名或邮箱匹配，即使没有任何消息也能找到
+		searchTerm := "%" + f.Query + "%"
+		if f.PrefixMatch {
+			searchTerm = f.Query + "%"
+		}
+		db = db.Where(`((conversations.type = 'group' AND conversations.name LIKE ?) OR
+			(conversations.type = 'private' AND EXISTS (
+				SELECT 1 FROM conversation_members peer
+				JOIN users ON users.id = peer.user_id AND users.deleted_at IS NULL
+				WHERE peer.conversation_id = conversations.id AND peer.user_id <> ?
+					AND (users.name LIKE ? OR users.email LIKE ?))))`,
+			searchTerm, userID, searchTerm, searchTerm)
 	}
 
 	// 获取总数
@@ -69,9 +177,14 @@ func (r *ChatRepository) GetUserConversations(userID uint, query string, limit,
 		return nil, 0, err
 	}
 
+	order := "conversations.last_message_at DESC, conversations.id DESC"
+	if f.SortBy == ConversationSortUpdated {
+		order = "conversations.updated_at DESC, conversations.id DESC"
+	}
+
 	// 分页查询数据
 	err := db.Preload("Members.User").
-		Order("conversations.updated_at DESC").
+		Order(order).
 		Limit(limit).Offset(offset).
 		Find(&convs).Error
 
@@ -120,6 +233,44 @@ func (r *ChatRepository) AddMember(member *model.ConversationMember) error {
 	return err
 }
 
+// savedConversationNamespace 生成“我的收藏”会话ID的命名空间，同一用户总是得到相同的会话ID
+var savedConversationNamespace = uuid.MustParse("5d0c6f0e-9a4b-4f7e-8d51-3c2a9b7e6f14")
+
+// SavedConversationName “我的收藏”会话的默认名称
+const SavedConversationName = "我的收藏"
+
+// EnsureSavedConversation 获取用户的“我的收藏”会话，不存在时创建，会话中只有用户自己。
+// 会话ID由用户ID确定性生成，并发创建时主键冲突直接忽略
+func (r *ChatRepository) EnsureSavedConversation(userID uint) (*model.Conversation, error) {
+	convID := uuid.NewSHA1(savedConversationNamespace, []byte(strconv.FormatUint(uint64(userID), 10))).String()
+	if conv, err := r.GetConversation(convID); err == nil {
+		return conv, nil
+	}
+
+	now := model.NewJSONTime(time.Now())
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		conv := &model.Conversation{
+			UUIDBase:      model.UUIDBase{ID: convID},
+			Type:          "saved",
+			Name:          SavedConversationName,
+			CreatorID:     userID,
+			LastMessageAt: &now,
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(conv).Error; err != nil {
+			return err
+		}
+		member := &model.ConversationMember{ConversationID: convID, UserID: userID, Role: model.MemberRoleMember}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(member).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if r.Redis != nil {
+		r.Redis.Del(r.ctx, fmt.Sprintf("chat:relation:user_groups:%d", userID))
+	}
+	return r.GetConversation(convID)
+}
+
 func (r *ChatRepository) RemoveMember(convID string, userID uint) error {
 	err := r.DB.Delete(&model.ConversationMember{}, "conversation_id = ? AND user_id = ?", convID, userID).Error
 	if err == nil && r.Redis != nil {
@@ -185,6 +336,231 @@ func (r *ChatRepository) UpdateLastReadMessage(convID string, userID uint, msgID
 		}).Error
 }
 
+// CreateMentions 批量写入 @ 提及记录
+func (r *ChatRepository) CreateMentions(mentions []model.MessageMention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+	return r.DB.Create(&mentions).Error
+}
+
+// ListUnreadMentions 分页获取用户在所有会话中未读的 @ 提及（按时间倒序）及总数
+func (r *ChatRepository) ListUnreadMentions(userID uint, limit, offset int) ([]model.MessageMention, int64, error) {
+	query := r.DB.Model(&model.MessageMention{}).Where("user_id = ? AND read_at IS NULL", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	mentions := []model.MessageMention{}
+	if total == 0 {
+		return mentions, 0, nil
+	}
+	err := query.Preload("Sender").Preload("Conversation").
+		Order("created_at DESC").Limit(limit).Offset(offset).
+		Find(&mentions).Error
+	return mentions, total, err
+}
+
+// ConversationUnread 单个会话的未读消息数
+type ConversationUnread struct {
+	ConversationID string `json:"conversationId"`
+	Count          int64  `json:"count"`
+}
+
+// unreadFallbackWindow 既没有已读位置也没有入群时间的成员，只统计该时长内的未读消息
+const unreadFallbackWindow = 30 * 24 * time.Hour
+
+// CountUnreadByConversation 按会话统计用户已读位置之后的未读消息数，不含自己发送的、已撤回的和系统消息
+func (r *ChatRepository) CountUnreadByConversation(userID uint) ([]ConversationUnread, error) {
+	rows := []ConversationUnread{}
+	// 从未读过的会话从入群时间开始统计，入群时间也缺失（旧数据）时最多统计 unreadFallbackWindow 内的消息，避免扫描整个会话
+	err := r.DB.Table("messages mm").
+		Select("mm.conversation_id, COUNT(*) AS count").
+		Joins("JOIN conversation_members cm ON cm.conversation_id = mm.conversation_id").
+		Where("cm.user_id = ? AND mm.deleted_at IS NULL", userID).
+		Where("mm.created_at > COALESCE(cm.last_read_msg_time, cm.joined_at, ?)", time.Now().Add(-unreadFallbackWindow)).
+		Where("mm.sender_id <> ? AND mm.type <> ? AND mm.is_revoked = ?", userID, "system", false).
+		Group("mm.conversation_id").
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *ChatRepository) CountUnreadMentions(userID uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.MessageMention{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// MarkMentionsRead 将用户的 @ 提及标记为已读，convID 为空时标记全部会话
+func (r *ChatRepository) MarkMentionsRead(userID uint, convID string) error {
+	query := r.DB.Model(&model.MessageMention{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if convID != "" {
+		query = query.Where("conversation_id = ?", convID)
+	}
+	return query.Update("read_at", model.NewJSONTime(time.Now())).Error
+}
+
+// FindMessageByClientMsgID 按发送者和客户端消息ID查找已落库的消息，用于发送去重
+func (r *ChatRepository) FindMessageByClientMsgID(convID string, senderID uint, clientMsgID string) (*model.Message, error) {
+	var msg model.Message
+	err := r.DB.Preload("Sender").
+		Where("conversation_id = ? AND sender_id = ? AND client_msg_id = ?", convID, senderID, clientMsgID).
+		First(&msg).Error
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// CreateConversationEvent 写入会话事件审计记录
+func (r *ChatRepository) CreateConversationEvent(event *model.ConversationEvent) error {
+	return r.DB.Create(event).Error
+}
+
+// PruneSystemMessages 分批物理删除早于 before 的系统消息，返回删除条数。
+// retention_exempt 的会话不清理；设置了 retention_days 的会话以会话自己的保留策略为准，由 PurgeExpiredMessages 清理
+func (r *ChatRepository) PruneSystemMessages(before time.Time, batchSize int) (int64, error) {
+	return r.purgeMessages(MessagePurgeOptions{BatchSize: batchSize}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("type = ? AND created_at < ? AND conversation_id NOT IN (?)", "system", before, r.customRetentionConversations())
+	})
+}
+
+// DailyMessageCount 每日消息数
+type DailyMessageCount struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+// MessageTypeCount 各类型消息数
+type MessageTypeCount struct {
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+}
+
+// SenderMessageCount 发送者消息数
+type SenderMessageCount struct {
+	UserID uint   `json:"userId"`
+	Name   string `json:"name"`
+	Avatar string `json:"avatar"`
+	Count  int64  `json:"count"`
+}
+
+// ConversationStats 会话在指定时间段内的消息统计
+type ConversationStats struct {
+	TotalMessages int64                `json:"totalMessages"`
+	ActiveMembers int64                `json:"activeMembers"` // 时间段内发过消息的成员数
+	Daily         []DailyMessageCount  `json:"daily"`
+	ByType        []MessageTypeCount   `json:"byType"`
+	TopSenders    []SenderMessageCount `json:"topSenders"`
+}
+
+// GetConversationStats 按天、按类型统计会话消息，并返回发送最多的前 topN 名成员。
+// 仅统计已落库的消息，仍在 Redis Stream 中排队的消息不计入。
+func (r *ChatRepository) GetConversationStats(convID string, start, end time.Time, topN int) (*ConversationStats, error) {
+	base := func() *gorm.DB {
+		return r.DB.Model(&model.Message{}).
+			Where("messages.conversation_id = ? AND messages.created_at >= ? AND messages.created_at < ?", convID, start, end)
+	}
+
+	stats := &ConversationStats{
+		Daily:      []DailyMessageCount{},
+		ByType:     []MessageTypeCount{},
+		TopSenders: []SenderMessageCount{},
+	}
+	if err := base().Count(&stats.TotalMessages).Error; err != nil {
+		return nil, err
+	}
+	if stats.TotalMessages == 0 {
+		return stats, nil
+	}
+
+	if err := base().Where("messages.sender_id IS NOT NULL").
+		Select("COUNT(DISTINCT messages.sender_id)").Scan(&stats.ActiveMembers).Error; err != nil {
+		return nil, err
+	}
+	if err := base().Select("DATE_FORMAT(messages.created_at, '%Y-%m-%d') AS date, COUNT(*) AS count").
+		Group("date").Order("date ASC").Scan(&stats.Daily).Error; err != nil {
+		return nil, err
+	}
+	if err := base().Select("messages.type AS type, COUNT(*) AS count").
+		Group("messages.type").Order("count DESC").Scan(&stats.ByType).Error; err != nil {
+		return nil, err
+	}
+	if err := base().Joins("JOIN users ON users.id = messages.sender_id").
+		Select("messages.sender_id AS user_id, users.name AS name, users.avatar AS avatar, COUNT(*) AS count").
+		Group("messages.sender_id, users.name, users.avatar").
+		Order("count DESC").Limit(topN).Scan(&stats.TopSenders).Error; err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ReadMark 会话中最新一条消息，批量标记已读时作为已读位置
+type ReadMark struct {
+	ConversationID string    `json:"conversationId"`
+	MessageID      string    `json:"messageId"`
+	CreatedAt      time.Time `json:"-"`
+}
+
+// MarkAllAsRead 将用户所有存在未读消息的会话标记为已读（已读位置为各会话最新消息），返回被更新的会话。
+// 没有消息或已全部读完的会话不会被更新
+func (r *ChatRepository) MarkAllAsRead(userID uint) ([]ReadMark, error) {
+	var rows []ReadMark
+	err := r.DB.Raw(`
+		SELECT m.conversation_id, m.id AS message_id, m.created_at
+		FROM messages m
+		JOIN (
+			SELECT mm.conversation_id, MAX(mm.created_at) AS created_at
+			FROM messages mm
+			JOIN conversation_members cm ON cm.conversation_id = mm.conversation_id
+			WHERE cm.user_id = ? AND mm.deleted_at IS NULL
+				AND (cm.last_read_msg_time IS NULL OR mm.created_at > cm.last_read_msg_time)
+			GROUP BY mm.conversation_id
+		) latest ON latest.conversation_id = m.conversation_id AND latest.created_at = m.created_at
+		WHERE m.deleted_at IS NULL`, userID).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一时刻可能有多条消息，每个会话只保留一条
+	marks := make([]ReadMark, 0, len(rows))
+	seen := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		if seen[row.ConversationID] {
+			continue
+		}
+		seen[row.ConversationID] = true
+		marks = append(marks, row)
+	}
+	if len(marks) == 0 {
+		return marks, nil
+	}
+
+	// 使用 CASE 一次更新所有会话的已读位置
+	convIDs := make([]string, 0, len(marks))
+	idCase := "CASE conversation_id"
+	timeCase := "CASE conversation_id"
+	var idArgs, timeArgs []interface{}
+	for _, m := range marks {
+		convIDs = append(convIDs, m.ConversationID)
+		idCase += " WHEN ? THEN ?"
+		timeCase += " WHEN ? THEN ?"
+		idArgs = append(idArgs, m.ConversationID, m.MessageID)
+		timeArgs = append(timeArgs, m.ConversationID, m.CreatedAt)
+	}
+	idCase += " END"
+	timeCase += " END"
+
+	err = r.DB.Model(&model.ConversationMember{}).
+		Where("user_id = ? AND conversation_id IN ?", userID, convIDs).
+		Updates(map[string]interface{}{
+			"last_read_msg_id":   gorm.Expr(idCase, idArgs...),
+			"last_read_msg_time": gorm.Expr(timeCase, timeArgs...),
+		}).Error
+	return marks, err
+}
+
 func (r *ChatRepository) GetConversationMembers(convID string, query string, limit, offset int) ([]model.ConversationMember, int64, error) {
 	var members []model.ConversationMember
 	var total int64
@@ -218,7 +594,7 @@ func (r *ChatRepository) CreateMessage(msg *model.Message) error {
 		msg.ID = model.GenerateUUID()
 	}
 	if msg.CreatedAt.IsZero() {
-		msg.CreatedAt = time.Now()
+		msg.CreatedAt = model.NewJSONTime(time.Now())
 	}
 
 	// 2. 生成会话内的连续 SeqID (使用 Redis 原子递增)
@@ -231,7 +607,11 @@ func (r *ChatRepository) CreateMessage(msg *model.Message) error {
 	}
 
 	// 自动恢复被隐藏的会话：有新消息时，取消该会话所有成员的隐藏状态
-	go r.UnhideConversation(msg.ConversationID)
+	r.asyncWrites.Add(1)
+	go func() {
+		defer r.asyncWrites.Done()
+		r.UnhideConversation(msg.ConversationID)
+	}()
 
 	// 3. 写入 Redis Stream 实现持久化异步队列
 	if r.Redis != nil {
@@ -243,87 +623,329 @@ func (r *ChatRepository) CreateMessage(msg *model.Message) error {
 
 		if err != nil {
 			// 如果 Redis 写入失败，降级为同步写入 MySQL
-			return r.DB.Transaction(func(tx *gorm.DB) error {
-				if err := tx.Create(msg).Error; err != nil {
-					return err
-				}
-				return tx.Model(&model.Conversation{}).Where("id = ?", msg.ConversationID).Update("updated_at", msg.CreatedAt).Error
-			})
+			return r.createMessageSync(msg)
 		}
 		// 3. 实时更新缓存
-		go r.cacheMessage(msg)
+		r.asyncWrites.Add(1)
+		go func() {
+			defer r.asyncWrites.Done()
+			r.cacheMessage(msg)
+		}()
 	} else {
 		// 无 Redis 环境，同步写入
-		return r.DB.Create(msg).Error
+		return r.createMessageSync(msg)
 	}
 
 	return nil
 }
 
-func (r *ChatRepository) messageStreamConsumer() {
+// createMessageSync 直接写入消息并更新会话最后消息时间
+func (r *ChatRepository) createMessageSync(msg *model.Message) error {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(msg).Error; err != nil {
+			return err
+		}
+		return touchConversation(tx, msg.ConversationID, msg.CreatedAt.Time)
+	})
+	if err != nil {
+		return err
+	}
+	r.notifyPersisted([]*model.Message{msg})
+	return nil
+}
+
+// StreamStats 消息持久化队列的积压情况
+type StreamStats struct {
+	Length  int64 // Stream 中的消息条数（XLEN）
+	Pending int64 // 已投递给消费组但尚未确认（未落库）的消息数
+	Lag     int64 // 尚未投递给消费组的消息数，最多统计 maxStreamLagScan 条
+}
+
+// maxStreamLagScan 统计消费延迟时最多扫描的消息数，避免积压严重时 XRANGE 返回过多数据
+const maxStreamLagScan = 10000
+
+// GetStreamStats 统计消息 Stream 的长度、消费组待确认数和未投递数，未配置 Redis 时返回零值
+func (r *ChatRepository) GetStreamStats() (StreamStats, error) {
+	var stats StreamStats
+	if r.Redis == nil {
+		return stats, nil
+	}
+
+	length, err := r.Redis.XLen(r.ctx, r.streamName).Result()
+	if err != nil {
+		return stats, err
+	}
+	stats.Length = length
+
+	groups, err := r.Redis.XInfoGroups(r.ctx, r.streamName).Result()
+	if err != nil {
+		return stats, err
+	}
+	for _, g := range groups {
+		if g.Name != r.groupName {
+			continue
+		}
+		stats.Pending = g.Pending
+		start, ok := nextStreamID(g.LastDeliveredID)
+		if !ok {
+			break
+		}
+		undelivered, err := r.Redis.XRangeN(r.ctx, r.streamName, start, "+", maxStreamLagScan).Result()
+		if err != nil {
+			return stats, err
+		}
+		stats.Lag = int64(len(undelivered))
+	}
+	return stats, nil
+}
+
+// nextStreamID 返回紧跟在 id 之后的 Stream ID，用作 XRANGE 的起点（不包含 id 本身）
+func nextStreamID(id string) (string, bool) {
+	ms, seq, found := strings.Cut(id, "-")
+	if !found {
+		return "", false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%s-%d", ms, n+1), true
+}
+
+// messageStreamConsumer 从 Stream 批量读取消息写入数据库，写入成功后才确认。
+// 定期认领其他消费者长时间未确认的消息（如进程崩溃遗留的），投递超过 maxStreamDeliveries 次的消息移入死信。
+// ctx 取消后不再阻塞等待新消息，而是处理完剩余消息后退出
+func (r *ChatRepository) messageStreamConsumer(ctx context.Context) {
+	defer close(r.consumerDone)
 	consumerName := fmt.Sprintf("consumer-%d", time.Now().UnixNano())
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
+	retryPending := false
+	var lastClaim time.Time
+
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= streamClaimInterval {
+			lastClaim = time.Now()
+			if r.claimIdlePending(consumerName) > 0 {
+				retryPending = true
+			}
+		}
+		// 上一批写库失败或认领到消息时先重试本消费者未确认的消息（ID 为 "0"），否则读取新消息
+		start := ">"
+		if retryPending {
+			start = "0"
+		}
+		// 阻塞读使用有限超时，以便及时感知 ctx 取消；批次处理使用独立的 r.ctx，保证取消时当前批次能完成并确认
+		n, err := r.consumeBatch(consumerName, start, time.Second)
+		if err != nil {
+			retryPending = true
+			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+		if retryPending && n == 0 {
+			retryPending = false
+		}
+	}
+
+	// 退出前处理剩余消息：未确认的消息和尚未投递的消息
+	deadline := time.Now().Add(streamDrainTimeout)
+	for _, start := range []string{"0", ">"} {
+		for time.Now().Before(deadline) {
+			n, err := r.consumeBatch(consumerName, start, -1)
+			if err != nil || n == 0 {
+				break
+			}
+		}
+	}
+}
+
+// consumeBatch 读取一批消息并写入数据库，返回读取到的条数。block<0 表示不阻塞
+func (r *ChatRepository) consumeBatch(consumerName, start string, block time.Duration) (int, error) {
+	streams, err := r.Redis.XReadGroup(r.ctx, &redis.XReadGroupArgs{
+		Group:    r.groupName,
+		Consumer: consumerName,
+		Streams:  []string{r.streamName, start},
+		Count:    int64(r.bufferSize),
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return 0, nil
+	}
+	entries := streams[0].Messages
+	read := len(entries)
+
+	// 重试未确认的消息时，先将投递次数已达上限的消息移入死信
+	if start != ">" {
+		if entries, err = r.deadLetterExhausted(consumerName, entries); err != nil {
+			return read, err
+		}
+	}
+
+	var batch []*model.Message
+	var msgIDs []string
+	var malformed []redis.XMessage
+	for _, xmsg := range entries {
+		var msg model.Message
+		data, ok := xmsg.Values["data"].(string)
+		if !ok || json.Unmarshal([]byte(data), &msg) != nil {
+			// 无法解析的消息重试也不会成功，直接移入死信
+			malformed = append(malformed, xmsg)
+			continue
+		}
+		msgIDs = append(msgIDs, xmsg.ID)
+		batch = append(batch, &msg)
+	}
+	if err := r.moveToDeadStream(malformed, "malformed"); err != nil {
+		return read, err
+	}
+
+	if err := r.flushMessages(batch); err == nil {
+		// 确认消息处理完毕
+		if len(msgIDs) == 0 {
+			return read, nil
+		}
+		return read, r.Redis.XAck(r.ctx, r.streamName, r.groupName, msgIDs...).Err()
+	}
 
+	// 整批写入失败时逐条写入，避免一条问题消息拖住同批的其他消息；失败的消息不确认，保留在待处理列表中等待重试
+	var flushErr error
+	acked := make([]string, 0, len(batch))
+	for i, msg := range batch {
+		if err := r.flushMessages([]*model.Message{msg}); err != nil {
+			flushErr = err
+			continue
+		}
+		acked = append(acked, msgIDs[i])
+	}
+	if len(acked) > 0 {
+		if err := r.Redis.XAck(r.ctx, r.streamName, r.groupName, acked...).Err(); err != nil {
+			return read, err
+		}
+	}
+	return read, flushErr
+}
+
+// claimIdlePending 将其他消费者超过 streamClaimMinIdle 未确认的消息认领给 consumerName，返回认领条数
+func (r *ChatRepository) claimIdlePending(consumerName string) int {
+	claimed := 0
+	start := "0-0"
 	for {
-		// 批量读取消息
-		streams, err := r.Redis.XReadGroup(r.ctx, &redis.XReadGroupArgs{
+		ids, next, err := r.Redis.XAutoClaimJustID(r.ctx, &redis.XAutoClaimArgs{
+			Stream:   r.streamName,
 			Group:    r.groupName,
 			Consumer: consumerName,
-			Streams:  []string{r.streamName, ">"},
+			MinIdle:  streamClaimMinIdle,
+			Start:    start,
 			Count:    int64(r.bufferSize),
-			Block:    0,
 		}).Result()
-
-		if err != nil || len(streams) == 0 {
-			time.Sleep(100 * time.Millisecond)
-			continue
+		if err != nil {
+			logger.Log.Warn("认领未确认的聊天消息失败", zap.Error(err))
+			return claimed
 		}
+		claimed += len(ids)
+		if next == "" || next == "0-0" {
+			return claimed
+		}
+		start = next
+	}
+}
 
-		var batch []*model.Message
-		var msgIDs []string
+// deadLetterExhausted 将 entries 中投递次数达到 maxStreamDeliveries 的消息移入死信并确认，返回其余消息
+func (r *ChatRepository) deadLetterExhausted(consumerName string, entries []redis.XMessage) ([]redis.XMessage, error) {
+	if len(entries) == 0 {
+		return entries, nil
+	}
+	pending, err := r.Redis.XPendingExt(r.ctx, &redis.XPendingExtArgs{
+		Stream:   r.streamName,
+		Group:    r.groupName,
+		Start:    entries[0].ID,
+		End:      entries[len(entries)-1].ID,
+		Count:    int64(len(entries)),
+		Consumer: consumerName,
+	}).Result()
+	if err != nil {
+		return entries, err
+	}
 
-		for _, xmsg := range streams[0].Messages {
-			var msg model.Message
-			if data, ok := xmsg.Values["data"].(string); ok {
-				if err := json.Unmarshal([]byte(data), &msg); err == nil {
-					batch = append(batch, &msg)
-					msgIDs = append(msgIDs, xmsg.ID)
-				}
-			}
+	exhausted := exhaustedStreamIDs(pending, maxStreamDeliveries)
+	if len(exhausted) == 0 {
+		return entries, nil
+	}
+	remaining := make([]redis.XMessage, 0, len(entries))
+	dead := make([]redis.XMessage, 0, len(exhausted))
+	for _, e := range entries {
+		if exhausted[e.ID] {
+			dead = append(dead, e)
+		} else {
+			remaining = append(remaining, e)
 		}
+	}
+	return remaining, r.moveToDeadStream(dead, "max_deliveries")
+}
 
-		if len(batch) > 0 {
-			r.flushMessages(batch)
-			// 确认消息处理完毕
-			r.Redis.XAck(r.ctx, r.streamName, r.groupName, msgIDs...)
+// exhaustedStreamIDs 返回投递次数已达到 maxDeliveries 的待确认消息 ID
+func exhaustedStreamIDs(pending []redis.XPendingExt, maxDeliveries int64) map[string]bool {
+	ids := make(map[string]bool)
+	for _, p := range pending {
+		if p.RetryCount >= maxDeliveries {
+			ids[p.ID] = true
 		}
 	}
+	return ids
+}
+
+// moveToDeadStream 将消息写入死信 Stream（保留原始数据、原 ID 和原因）后从消费组中确认
+func (r *ChatRepository) moveToDeadStream(entries []redis.XMessage, reason string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(entries))
+	pipe := r.Redis.TxPipeline()
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+		pipe.XAdd(r.ctx, &redis.XAddArgs{
+			Stream: r.deadStream,
+			MaxLen: deadStreamMaxLen,
+			Approx: true,
+			Values: map[string]interface{}{"data": e.Values["data"], "sourceId": e.ID, "reason": reason},
+		})
+	}
+	pipe.XAck(r.ctx, r.streamName, r.groupName, ids...)
+	if _, err := pipe.Exec(r.ctx); err != nil {
+		return err
+	}
+	logger.Log.Warn("聊天消息已移入死信", zap.Strings("ids", ids), zap.String("reason", reason))
+	return nil
 }
 
-func (r *ChatRepository) flushMessages(messages []*model.Message) {
+func (r *ChatRepository) flushMessages(messages []*model.Message) error {
 	if len(messages) == 0 {
-		return
+		return nil
 	}
 
-	// 1. 批量插入消息
-	err := r.DB.Create(&messages).Error
+	// 1. 批量插入消息，主键冲突说明此前已写入（写库成功但确认失败后的重试），忽略即可
+	err := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&messages).Error
 	if err != nil {
-		// 生产环境增加错误重试或者持久化日志
-		return
+		return err
 	}
 
-	// 2. 批量更新会话的活跃时间
+	// 2. 批量更新会话的最后消息时间
 	convUpdates := make(map[string]time.Time)
 	for _, m := range messages {
 		if t, ok := convUpdates[m.ConversationID]; !ok || m.CreatedAt.After(t) {
-			convUpdates[m.ConversationID] = m.CreatedAt
+			convUpdates[m.ConversationID] = m.CreatedAt.Time
 		}
 	}
 
 	for convID, lastTime := range convUpdates {
-		r.DB.Model(&model.Conversation{}).Where("id = ?", convID).Update("updated_at", lastTime)
+		touchConversation(r.DB, convID, lastTime)
 	}
+	r.notifyPersisted(messages)
+	return nil
 }
 
 func (r *ChatRepository) cacheMessage(msg *model.Message) {
@@ -578,6 +1200,111 @@ func (r *ChatRepository) GetLatestMessageForUser(userID uint) (*model.Message, e
 	return &msg, nil
 }
 
+// MessagePurgeOptions 过期消息清理参数
+type MessagePurgeOptions struct {
+	DefaultDays int           // 全局保留天数，<=0 表示仅处理设置了单独保留天数的会话
+	BatchSize   int           // 每批删除条数
+	BatchPause  time.Duration // 批次之间的暂停
+	ShouldStop  func() bool   // 返回 true 时提前结束（如超出清理时间段或服务退出）
+}
+
+// PurgeExpiredMessages 按保留策略分批物理删除过期消息，并清除受影响会话的 Redis 消息缓存，返回删除条数。
+// 设置了 retention_days 的会话按各自天数清理，retention_exempt 的会话不清理，其余会话使用 DefaultDays
+func (r *ChatRepository) PurgeExpiredMessages(opts MessagePurgeOptions) (int64, error) {
+	now := time.Now()
+	var total int64
+
+	var overrides []model.Conversation
+	if err := r.DB.Select("id", "retention_days").
+		Where("retention_exempt = ? AND retention_days > 0", false).
+		Find(&overrides).Error; err != nil {
+		return 0, err
+	}
+	for _, conv := range overrides {
+		cutoff := now.AddDate(0, 0, -conv.RetentionDays)
+		n, err := r.purgeMessages(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("conversation_id = ? AND created_at < ?", conv.ID, cutoff)
+		})
+		total += n
+		if err != nil || (opts.ShouldStop != nil && opts.ShouldStop()) {
+			return total, err
+		}
+	}
+
+	if opts.DefaultDays <= 0 {
+		return total, nil
+	}
+	cutoff := now.AddDate(0, 0, -opts.DefaultDays)
+	n, err := r.purgeMessages(opts, func(db *gorm.DB) *gorm.DB {
+		return db.Where("created_at < ? AND conversation_id NOT IN (?)", cutoff, r.customRetentionConversations())
+	})
+	return total + n, err
+}
+
+// customRetentionConversations 豁免清理或单独设置了保留天数的会话 ID 子查询，这些会话不使用全局保留策略
+func (r *ChatRepository) customRetentionConversations() *gorm.DB {
+	return r.DB.Model(&model.Conversation{}).Unscoped().Select("id").
+		Where("retention_exempt = ? OR retention_days > 0", true)
+}
+
+// purgeMessages 分批删除 scope 匹配的消息，直到没有剩余或 ShouldStop 返回 true
+func (r *ChatRepository) purgeMessages(opts MessagePurgeOptions, scope func(db *gorm.DB) *gorm.DB) (int64, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	var total int64
+	for {
+		var rows []struct {
+			ID             string
+			ConversationID string
+		}
+		if err := scope(r.DB.Model(&model.Message{}).Unscoped()).
+			Select("id", "conversation_id").
+			Limit(batchSize).Scan(&rows).Error; err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+
+		ids := make([]string, 0, len(rows))
+		convIDs := make(map[string]struct{})
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+			convIDs[row.ConversationID] = struct{}{}
+		}
+		result := r.DB.Unscoped().Where("id IN ?", ids).Delete(&model.Message{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+
+		// 缓存中可能包含已删除的消息，直接清除，下次拉取时回源数据库
+		if r.Redis != nil {
+			keys := make([]string, 0, len(convIDs))
+			for convID := range convIDs {
+				keys = append(keys, fmt.Sprintf("chat:cache:%s", convID))
+			}
+			r.Redis.Del(r.ctx, keys...)
+		}
+
+		if len(rows) < batchSize || (opts.ShouldStop != nil && opts.ShouldStop()) {
+			return total, nil
+		}
+		if opts.BatchPause > 0 {
+			time.Sleep(opts.BatchPause)
+		}
+	}
+}
+
+// UpdateRetention 更新会话的消息保留策略
+func (r *ChatRepository) UpdateRetention(convID string, retentionDays int, exempt bool) error {
+	return r.DB.Model(&model.Conversation{}).Where("id = ?", convID).
+		Updates(map[string]interface{}{"retention_days": retentionDays, "retention_exempt": exempt}).Error
+}
+
 // SetupPartitions 为消息表创建分区-----暂时不用，留作后续优化
 func (r *ChatRepository) SetupPartitions() error {
 	_ = `
@@ -590,31 +1317,24 @@ func (r *ChatRepository) SetupPartitions() error {
 	return nil
 }
 
-func (r *ChatRepository) RevokeMessage(msgID string, senderID uint) (*model.Message, error) {
+func (r *ChatRepository) FindMessage(msgID string) (*model.Message, error) {
 	var msg model.Message
-	if err := r.DB.First(&msg, "id = ? AND sender_id = ?", msgID, senderID).Error; err != nil {
-		return nil, err
-	}
-
-	if msg.IsRevoked {
-		return &msg, nil
-	}
-
-	// 限制撤回时间
-	if time.Since(msg.CreatedAt) > 2*time.Minute {
-		return nil, fmt.Errorf("消息发送已超过 2 分钟，无法撤回")
-	}
+	err := r.DB.First(&msg, "id = ?", msgID).Error
+	return &msg, err
+}
 
+// RevokeMessage 将消息标记为已撤回，撤回权限和时限由调用方校验
+func (r *ChatRepository) RevokeMessage(msg *model.Message) error {
 	msg.IsRevoked = true
 	msg.Content = "消息已撤回"
-	err := r.DB.Save(&msg).Error
+	err := r.DB.Save(msg).Error
 
 	if err == nil && r.Redis != nil {
 		// 撤回消息后清除缓存，强制下次拉取时回源数据库并更新缓存
 		r.Redis.Del(r.ctx, fmt.Sprintf("chat:cache:%s", msg.ConversationID))
 	}
 
-	return &msg, err
+	return err
 }
 
 func (r *ChatRepository) SearchMessages(userID uint, query string, limit, offset int) ([]model.Message, int64, error) {
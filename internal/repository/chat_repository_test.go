@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/testutil"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestExhaustedStreamIDs(t *testing.T) {
+	pending := []redis.XPendingExt{
+		{ID: "1-0", RetryCount: 1},
+		{ID: "2-0", RetryCount: maxStreamDeliveries - 1},
+		{ID: "3-0", RetryCount: maxStreamDeliveries},
+		{ID: "4-0", RetryCount: maxStreamDeliveries + 3},
+	}
+
+	got := exhaustedStreamIDs(pending, maxStreamDeliveries)
+	if len(got) != 2 || !got["3-0"] || !got["4-0"] {
+		t.Fatalf("exhaustedStreamIDs = %v, want only 3-0 and 4-0", got)
+	}
+	if got := exhaustedStreamIDs(nil, maxStreamDeliveries); len(got) != 0 {
+		t.Fatalf("exhaustedStreamIDs(nil) = %v, want empty", got)
+	}
+}
+
+// TestMessageStreamShutdownNoLoss 需要 testutil.MySQL 的测试库，Redis 使用 miniredis
+func TestMessageStreamShutdownNoLoss(t *testing.T) {
+	db := testutil.MySQL(t)
+	rdb := testutil.Redis(t)
+
+	suffix := model.GenerateUUID()
+	r := &ChatRepository{
+		DB:           db,
+		Redis:        rdb,
+		ctx:          context.Background(),
+		streamName:   "test:chat:stream:" + suffix,
+		groupName:    "test:chat:group:" + suffix,
+		deadStream:   "test:chat:dead:" + suffix,
+		bufferSize:   10,
+		consumerDone: make(chan struct{}),
+	}
+	defer rdb.Del(context.Background(), r.streamName, r.deadStream)
+
+	conv := model.Conversation{UUIDBase: model.UUIDBase{ID: model.GenerateUUID()}, Type: "group", Name: "stream-shutdown-test"}
+	if err := db.Create(&conv).Error; err != nil {
+		t.Fatalf("create conversation: %v", err)
+	}
+	defer func() {
+		db.Unscoped().Where("conversation_id = ?", conv.ID).Delete(&model.Message{})
+		db.Unscoped().Delete(&conv)
+	}()
+
+	r.startConsumer()
+
+	// 消息数超过一个批次，关闭时消费者必然处于读取或写库的中途
+	const total = 57
+	for i := 0; i < total; i++ {
+		msg := &model.Message{ConversationID: conv.ID, Type: "system", Content: "shutdown test"}
+		if err := r.CreateMessage(msg); err != nil {
+			t.Fatalf("create message %d: %v", i, err)
+		}
+	}
+
+	if err := r.Shutdown(streamDrainTimeout + 5*time.Second); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	var stored int64
+	if err := db.Model(&model.Message{}).Where("conversation_id = ?", conv.ID).Count(&stored).Error; err != nil {
+		t.Fatalf("count messages: %v", err)
+	}
+	if stored != total {
+		t.Fatalf("stored %d messages after shutdown, want %d", stored, total)
+	}
+
+	pending, err := rdb.XPending(context.Background(), r.streamName, r.groupName).Result()
+	if err != nil {
+		t.Fatalf("xpending: %v", err)
+	}
+	if pending.Count != 0 {
+		t.Fatalf("%d messages still pending after shutdown, want 0", pending.Count)
+	}
+}
@@ -48,6 +48,30 @@ func (r *CheckinRepository) FindLatestByUser(userID uint) (*model.Checkin, error
 // GetCheckinCountByUser 获取用户的总签到次数
 func (r *CheckinRepository) GetCheckinCountByUser(userID uint) (int64, error) {
 	var count int64
-	err := r.DB.Model(&model.Checkin{}).Where("user_id = ?", userID).Count(&count).Error
+	err := r.DB.Model(&model.Checkin{}).Where("user_id = ? AND frozen = ?", userID, false).Count(&count).Error
 	return count, err
 }
+
+// ListByUser 按签到时间升序获取用户的全部签到记录（含冻结补签记录）
+func (r *CheckinRepository) ListByUser(userID uint) ([]model.Checkin, error) {
+	var checkins []model.Checkin
+	err := r.DB.Select("checkin_at", "frozen").
+		Where("user_id = ?", userID).
+		Order("checkin_at ASC").
+		Find(&checkins).Error
+	return checkins, err
+}
+
+// CountFrozenSince 统计用户自指定时间起使用的冻结次数
+func (r *CheckinRepository) CountFrozenSince(userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.Checkin{}).
+		Where("user_id = ? AND frozen = ? AND checkin_at >= ?", userID, true, since).
+		Count(&count).Error
+	return count, err
+}
+
+// UpdateStreakDays 更新签到记录的连续天数
+func (r *CheckinRepository) UpdateStreakDays(id uint, streakDays int) error {
+	return r.DB.Model(&model.Checkin{}).Where("id = ?", id).Update("streak_days", streakDays).Error
+}
@@ -31,6 +31,59 @@ func (r *ExerciseSubmissionRepository) FindByUserAndQuestion(userID, questionID
 	return &submission, nil
 }
 
+// FindSubmittedQuestionIDs 返回用户在给定题目中提交过答案的题目ID集合
+func (r *ExerciseSubmissionRepository) FindSubmittedQuestionIDs(userID uint, questionIDs []uint) (map[uint]bool, error) {
+	result := make(map[uint]bool)
+	if userID == 0 || len(questionIDs) == 0 {
+		return result, nil
+	}
+	var ids []uint
+	if err := r.DB.Model(&model.ExerciseSubmission{}).
+		Where("user_id = ? AND question_id IN ?", userID, questionIDs).
+		Distinct().Pluck("question_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result, nil
+}
+
+// FindCorrectQuestionIDs 返回用户在给定题目中已答对的题目ID集合
+func (r *ExerciseSubmissionRepository) FindCorrectQuestionIDs(userID uint, questionIDs []uint) (map[uint]bool, error) {
+	result := make(map[uint]bool)
+	if userID == 0 || len(questionIDs) == 0 {
+		return result, nil
+	}
+	var ids []uint
+	if err := r.DB.Model(&model.ExerciseSubmission{}).
+		Where("user_id = ? AND question_id IN ? AND is_correct = ?", userID, questionIDs, true).
+		Distinct().Pluck("question_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result, nil
+}
+
+// QuestionSubmissionStat 单个题目的提交人数和答对人数
+type QuestionSubmissionStat struct {
+	QuestionID uint
+	Total      int
+	Correct    int
+}
+
+// QuestionStats 按题目统计提交人数和答对人数（每个用户每题只保留一条提交记录）
+func (r *ExerciseSubmissionRepository) QuestionStats() ([]QuestionSubmissionStat, error) {
+	var stats []QuestionSubmissionStat
+	err := r.DB.Model(&model.ExerciseSubmission{}).
+		Select("question_id, COUNT(*) AS total, SUM(CASE WHEN is_correct THEN 1 ELSE 0 END) AS correct").
+		Group("question_id").
+		Scan(&stats).Error
+	return stats, err
+}
+
 // Update 更新练习提交记录
 func (r *ExerciseSubmissionRepository) Update(submission *model.ExerciseSubmission) error {
 	return r.DB.Save(submission).Error
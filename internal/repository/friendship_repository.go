@@ -45,9 +45,11 @@ func (r *FriendshipRepository) CreateFriendship(f *model.Friendship) error {
 	return err
 }
 
-func (r *FriendshipRepository) GetFriends(userID uint, query string) ([]model.User, error) {
+func (r *FriendshipRepository) GetFriends(userID uint, query string, limit, offset int) ([]model.User, int64, error) {
 	var friends []model.User
-	db := r.DB.Joins("JOIN friendships ON friendships.friend_id = users.id").
+	var total int64
+	db := r.DB.Model(&model.User{}).
+		Joins("JOIN friendships ON friendships.friend_id = users.id").
 		Where("friendships.user_id = ?", userID)
 
 	if query != "" {
@@ -55,8 +57,14 @@ func (r *FriendshipRepository) GetFriends(userID uint, query string) ([]model.Us
 		db = db.Where("(users.name LIKE ? OR users.email LIKE ?)", searchTerm, searchTerm)
 	}
 
-	err := db.Find(&friends).Error
-	return friends, err
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := db.Order("users.id").
+		Limit(limit).Offset(offset).
+		Find(&friends).Error
+	return friends, total, err
 }
 
 // GetFriendIDs 只获取好友的 ID 列表
@@ -113,6 +121,37 @@ func (r *FriendshipRepository) IsFriend(userID, friendID uint) (bool, error) {
 	return count > 0, err
 }
 
+func (r *FriendshipRepository) CountFriends(userID uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.Friendship{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// CountPendingSent 统计用户已发出且尚未处理的好友申请数
+func (r *FriendshipRepository) CountPendingSent(senderID uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.FriendRequest{}).
+		Where("sender_id = ? AND status = ?", senderID, "pending").
+		Count(&count).Error
+	return count, err
+}
+
+// IncrRequestRate 累加用户在当前窗口内发送好友申请的次数并返回累计值，Redis 未配置时返回 0
+func (r *FriendshipRepository) IncrRequestRate(senderID uint, window time.Duration) (int64, error) {
+	if r.Redis == nil {
+		return 0, nil
+	}
+	key := fmt.Sprintf("chat:friend_request:rate:%d", senderID)
+	count, err := r.Redis.Incr(r.ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		r.Redis.Expire(r.ctx, key, window)
+	}
+	return count, nil
+}
+
 func (r *FriendshipRepository) CreateRequest(req *model.FriendRequest) error {
 	return r.DB.Create(req).Error
 }
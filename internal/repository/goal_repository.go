@@ -41,6 +41,30 @@ func (r *GoalRepository) Update(goal *model.Goal) error {
 		}).Error
 }
 
+// UpdateReminder 保存截止提醒状态
+func (r *GoalRepository) UpdateReminder(goal *model.Goal) error {
+	return r.DB.Model(&model.Goal{}).
+		Where("id = ?", goal.ID).
+		Updates(map[string]interface{}{
+			"reminder_sent_at":        goal.ReminderSentAt,
+			"reminder_threshold_days": goal.ReminderThresholdDays,
+			"reminder_snoozed_until":  goal.ReminderSnoozedUntil,
+			"reminder_dismissed":      goal.ReminderDismissed,
+		}).Error
+}
+
+// FindDueForReminder 查找截止时间在 (now, before] 内、未完成且未关闭或暂缓提醒的目标
+func (r *GoalRepository) FindDueForReminder(now, before time.Time) ([]model.Goal, error) {
+	var goals []model.Goal
+	err := r.DB.Where("target_date > ? AND target_date <= ?", now, before).
+		Where("status NOT IN ?", []model.GoalStatus{model.GoalCompleted, model.GoalCompletedExpired}).
+		Where("reminder_dismissed = ?", false).
+		Where("reminder_snoozed_until IS NULL OR reminder_snoozed_until <= ?", now).
+		Order("target_date").
+		Find(&goals).Error
+	return goals, err
+}
+
 // Delete 删除学习目标
 func (r *GoalRepository) Delete(id uint) error {
 	return r.DB.Delete(&model.Goal{}, id).Error
@@ -6,6 +6,7 @@ import (
 	"coder_edu_backend/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ModuleRepository struct {
@@ -49,12 +50,12 @@ func (r *ProgressRepository) GetLearningGoals(userID uint) ([]model.LearningGoal
 		{
 			Type:        "short-term",
 			Description: "完成本周的指针学习模块，达到90%准确率",
-			TargetDate:  time.Now().AddDate(0, 0, 7),
+			TargetDate:  model.NewJSONTime(time.Now().AddDate(0, 0, 7)),
 		},
 		{
 			Type:        "long-term",
 			Description: "使用C语言构建一个功能完整的命令行工具",
-			TargetDate:  time.Now().AddDate(0, 3, 0),
+			TargetDate:  model.NewJSONTime(time.Now().AddDate(0, 3, 0)),
 		},
 	}, nil
 }
@@ -93,6 +94,41 @@ func (r *LearningLogRepository) FindByID(id uint) (*model.LearningLog, error) {
 	return &log, nil
 }
 
+// AccumulateDuration 写入按 用户-学习项-日期 唯一的学习日志，记录已存在时只累加时长。
+// 累加量不超过距上次累加（updated_at）实际经过的秒数，客户端频繁重复上报不会让时长超过真实时间
+func (r *LearningLogRepository) AccumulateDuration(log *model.LearningLog) error {
+	now := time.Now()
+	return r.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "item_key"}, {Name: "log_date"}},
+		// MySQL 按顺序执行赋值，duration 必须在 updated_at 更新前计算
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "duration"}, Value: gorm.Expr(
+				"duration + LEAST(?, GREATEST(TIMESTAMPDIFF(SECOND, updated_at, ?), 0))", log.Duration, now)},
+			{Column: clause.Column{Name: "updated_at"}, Value: now},
+		},
+	}).Create(log).Error
+}
+
+// LearningLogDailyActivity 按日期和活动类型汇总的学习日志时长
+type LearningLogDailyActivity struct {
+	Date     string `json:"date"`
+	Activity string `json:"activity"`
+	Duration int    `json:"duration"`
+	Count    int    `json:"count"`
+}
+
+// SummarizeByActivityAndDay 按自然日和活动类型汇总 [start, end) 内的学习日志时长
+func (r *LearningLogRepository) SummarizeByActivityAndDay(userID uint, start, end time.Time) ([]LearningLogDailyActivity, error) {
+	rows := make([]LearningLogDailyActivity, 0)
+	err := r.DB.Model(&model.LearningLog{}).
+		Select("DATE_FORMAT(created_at, '%Y-%m-%d') AS date, activity, COALESCE(SUM(duration), 0) AS duration, COUNT(*) AS count").
+		Where("user_id = ? AND created_at >= ? AND created_at < ?", userID, start, end).
+		Group("date, activity").
+		Order("date ASC, activity ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
 type QuizRepository struct {
 	DB *gorm.DB
 }
@@ -139,6 +139,40 @@ func (r *LevelAttemptRepository) GetAbilityScores(userID uint) (map[uint]float64
 	return scoreMap, nil
 }
 
+// GetNormalizedAbilityScores 统计学生在各能力上的平均得分率（0-100）。
+// 每次已结束的尝试按所属关卡题目加权总分归一化，userIDs 为空时统计全部学生
+func (r *LevelAttemptRepository) GetNormalizedAbilityScores(userIDs []uint) (map[uint]float64, error) {
+	type Result struct {
+		AbilityID uint
+		AvgScore  float64
+	}
+	levelMax := r.DB.Table("level_questions").
+		Select("level_id, SUM(points * GREATEST(weight, 1)) AS max_score").
+		Where("deleted_at IS NULL").
+		Group("level_id")
+
+	db := r.DB.Table("level_attempts att").
+		Select("la.ability_id, AVG(LEAST(att.score * 100.0 / lm.max_score, 100)) AS avg_score").
+		Joins("JOIN level_abilities la ON att.level_id = la.level_id").
+		Joins("JOIN (?) lm ON lm.level_id = att.level_id", levelMax).
+		Joins("JOIN users u ON u.id = att.user_id AND u.role = ? AND u.deleted_at IS NULL", model.Student).
+		Where("att.ended_at IS NOT NULL AND att.deleted_at IS NULL AND lm.max_score > 0")
+	if len(userIDs) > 0 {
+		db = db.Where("att.user_id IN ?", userIDs)
+	}
+
+	var results []Result
+	if err := db.Group("la.ability_id").Scan(&results).Error; err != nil {
+		return nil, err
+	}
+
+	scoreMap := make(map[uint]float64, len(results))
+	for _, r := range results {
+		scoreMap[r.AbilityID] = r.AvgScore
+	}
+	return scoreMap, nil
+}
+
 func (r *LevelAttemptRepository) GetLatestAttemptLevelID(userID uint) (uint, error) {
 	var levelID uint
 	err := r.DB.Model(&model.LevelAttempt{}).
@@ -188,6 +222,34 @@ func (r *LevelAttemptRepository) GetAnswerByQuestion(attemptID, questionID uint)
 	return &ans, err
 }
 
+// ListRecentEndedByUser 按结束时间倒序获取学生最近已结束的尝试（跨关卡）
+func (r *LevelAttemptRepository) ListRecentEndedByUser(userID uint, limit int) ([]model.LevelAttempt, error) {
+	var attempts []model.LevelAttempt
+	err := r.DB.Where("user_id = ? AND ended_at IS NOT NULL", userID).
+		Order("ended_at DESC").
+		Limit(limit).
+		Find(&attempts).Error
+	return attempts, err
+}
+
+func (r *LevelAttemptRepository) GetAnswersByAttempts(attemptIDs []uint) ([]model.LevelAttemptAnswer, error) {
+	var answers []model.LevelAttemptAnswer
+	if len(attemptIDs) == 0 {
+		return answers, nil
+	}
+	err := r.DB.Where("attempt_id IN ?", attemptIDs).Find(&answers).Error
+	return answers, err
+}
+
+func (r *LevelAttemptRepository) GetQuestionScoresByAttempts(attemptIDs []uint) ([]model.LevelAttemptQuestionScore, error) {
+	var scores []model.LevelAttemptQuestionScore
+	if len(attemptIDs) == 0 {
+		return scores, nil
+	}
+	err := r.DB.Where("attempt_id IN ?", attemptIDs).Find(&scores).Error
+	return scores, err
+}
+
 func (r *LevelAttemptRepository) ListNeedingManual(levelID uint) ([]model.LevelAttempt, error) {
 	var attempts []model.LevelAttempt
 	err := r.DB.Where("level_id = ? AND needs_manual = ?", levelID, true).Find(&attempts).Error
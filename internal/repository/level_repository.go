@@ -74,6 +74,31 @@ func (r *LevelRepository) GetVersionByID(id uint) (*model.LevelVersion, error) {
 	return &v, err
 }
 
+func (r *LevelRepository) GetVersionsByIDs(ids []uint) ([]model.LevelVersion, error) {
+	var versions []model.LevelVersion
+	if len(ids) == 0 {
+		return versions, nil
+	}
+	err := r.DB.Where("id IN ?", ids).Find(&versions).Error
+	return versions, err
+}
+
+// GetTitlesByIDs 批量获取关卡标题（含已删除的关卡，便于展示历史记录）
+func (r *LevelRepository) GetTitlesByIDs(ids []uint) (map[uint]string, error) {
+	titles := make(map[uint]string, len(ids))
+	if len(ids) == 0 {
+		return titles, nil
+	}
+	var levels []model.Level
+	if err := r.DB.Unscoped().Select("id", "title").Where("id IN ?", ids).Find(&levels).Error; err != nil {
+		return nil, err
+	}
+	for _, l := range levels {
+		titles[l.ID] = l.Title
+	}
+	return titles, nil
+}
+
 func (r *LevelRepository) DeleteQuestionsByLevel(levelID uint) error {
 	return r.DB.Where("level_id = ?", levelID).Delete(&model.LevelQuestion{}).Error
 }
@@ -117,6 +142,16 @@ func (r *LevelRepository) GetQuestionsByLevel(levelID uint) ([]model.LevelQuesti
 	return qs, err
 }
 
+// GetQuestionsByLevels 批量获取多个关卡的当前题目
+func (r *LevelRepository) GetQuestionsByLevels(levelIDs []uint) ([]model.LevelQuestion, error) {
+	var qs []model.LevelQuestion
+	if len(levelIDs) == 0 {
+		return qs, nil
+	}
+	err := r.DB.Where("level_id IN ?", levelIDs).Order("`order` asc").Find(&qs).Error
+	return qs, err
+}
+
 func (r *LevelRepository) DeleteQuestionsByLevelID(levelID uint) error {
 	return r.DB.Where("level_id = ?", levelID).Delete(&model.LevelQuestion{}).Error
 }
@@ -216,20 +251,48 @@ func (r *LevelRepository) DeleteLevel(id uint) error {
 	return tx.Commit().Error
 }
 
+// CountAttemptsByLevel 统计关卡的挑战记录总数及其中尚未结束的数量
+func (r *LevelRepository) CountAttemptsByLevel(levelID uint) (total int64, inProgress int64, err error) {
+	if err = r.DB.Model(&model.LevelAttempt{}).Where("level_id = ?", levelID).Count(&total).Error; err != nil {
+		return
+	}
+	err = r.DB.Model(&model.LevelAttempt{}).Where("level_id = ? AND ended_at IS NULL", levelID).Count(&inProgress).Error
+	return
+}
+
+// ArchiveLevel 仅软删除关卡本身并结束进行中的挑战，保留挑战记录、作答和题目以便历史与统计查询
+func (r *LevelRepository) ArchiveLevel(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.LevelAttempt{}).
+			Where("level_id = ? AND ended_at IS NULL", id).
+			Update("ended_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Level{}, id).Error
+	})
+}
+
+// LevelsVisibleToStudent 限定为学生可见的关卡：已发布、在可见范围内且处于开放时间
+func LevelsVisibleToStudent(userID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		query := db.Where("is_published = ?", true)
+
+		// 可见性筛选
+		query = query.Where("visible_scope = ? OR (visible_scope = ? AND JSON_CONTAINS(visible_to, CAST(? AS CHAR)))",
+			"all", "specific", userID)
+
+		// 时间范围筛选
+		now := time.Now()
+		return query.Where("visible_scope = ? OR ((available_from IS NULL OR available_from <= ?) AND (available_to IS NULL OR available_to >= ?))",
+			"all", now, now)
+	}
+}
+
 func (r *LevelRepository) ListLevelsForStudent(userID uint, search string, difficulty string, page, limit int) ([]model.Level, int, error) {
 	var levels []model.Level
 	var total int64
 
-	query := r.DB.Model(&model.Level{}).Where("is_published = ?", true)
-
-	// 可见性筛选
-	query = query.Where("visible_scope = ? OR (visible_scope = ? AND JSON_CONTAINS(visible_to, CAST(? AS CHAR)))",
-		"all", "specific", userID)
-
-	// 时间范围筛选
-	now := time.Now()
-	query = query.Where("visible_scope = ? OR ((available_from IS NULL OR available_from <= ?) AND (available_to IS NULL OR available_to >= ?))",
-		"all", now, now)
+	query := r.DB.Model(&model.Level{}).Scopes(LevelsVisibleToStudent(userID))
 
 	// 搜索条件
 	if search != "" {
@@ -25,7 +25,7 @@ func (r *MotivationRepository) GetAll() ([]*model.Motivation, error) {
 // 获取启用的激励短句
 func (r *MotivationRepository) GetEnabled() ([]*model.Motivation, error) {
 	var motivations []*model.Motivation
-	err := r.DB.Where("is_enabled = ?", true).Find(&motivations).Error
+	err := r.DB.Where("is_enabled = ?", true).Order("id").Find(&motivations).Error
 	return motivations, err
 }
 
@@ -80,3 +80,33 @@ func (r *MotivationRepository) HasEnabled() (bool, error) {
 	err := r.DB.Model(&model.Motivation{}).Where("is_enabled = ?", true).Count(&count).Error
 	return count > 0, err
 }
+
+// motivationScheduleID 轮换设置表中唯一一行的主键
+const motivationScheduleID = 1
+
+// GetSchedule 获取自动轮换设置，不存在时按默认值（开启自动轮换）创建
+func (r *MotivationRepository) GetSchedule() (*model.MotivationSchedule, error) {
+	var schedule model.MotivationSchedule
+	err := r.DB.Attrs(model.MotivationSchedule{AutoRotate: true}).
+		FirstOrCreate(&schedule, model.MotivationSchedule{ID: motivationScheduleID}).Error
+	return &schedule, err
+}
+
+// UpdateSchedule 更新自动轮换开关和置顶短句，pinnedID 为 nil 表示取消置顶
+func (r *MotivationRepository) UpdateSchedule(autoRotate bool, pinnedID *uint) error {
+	return r.DB.Model(&model.MotivationSchedule{}).Where("id = ?", motivationScheduleID).
+		Updates(map[string]interface{}{"auto_rotate": autoRotate, "pinned_id": pinnedID}).Error
+}
+
+// ClaimRotation 将上次轮换时间从 prev 更新为 now。多个实例同时执行时只有一个能更新成功，
+// 其余返回 false，避免同一天重复轮换
+func (r *MotivationRepository) ClaimRotation(prev *model.JSONTime, now time.Time) (bool, error) {
+	query := r.DB.Model(&model.MotivationSchedule{}).Where("id = ?", motivationScheduleID)
+	if prev == nil {
+		query = query.Where("last_rotated_at IS NULL")
+	} else {
+		query = query.Where("last_rotated_at = ?", prev.Time)
+	}
+	result := query.Update("last_rotated_at", now)
+	return result.RowsAffected == 1, result.Error
+}
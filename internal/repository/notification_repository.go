@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"coder_edu_backend/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository struct {
+	DB *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{DB: db}
+}
+
+func (r *NotificationRepository) CreateBatch(notifications []model.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return r.DB.CreateInBatches(notifications, 500).Error
+}
+
+// ListForUser 按时间倒序分页获取用户的通知及总数
+func (r *NotificationRepository) ListForUser(userID uint, unreadOnly bool, limit, offset int) ([]model.Notification, int64, error) {
+	query := r.DB.Model(&model.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	items := []model.Notification{}
+	if total == 0 {
+		return items, 0, nil
+	}
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&items).Error
+	return items, total, err
+}
+
+func (r *NotificationRepository) CountUnread(userID uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkRead 将用户的通知标记为已读，id 为 0 时标记全部，返回实际更新的条数
+func (r *NotificationRepository) MarkRead(userID, id uint) (int64, error) {
+	query := r.DB.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if id > 0 {
+		query = query.Where("id = ?", id)
+	}
+	result := query.Update("read_at", model.NewJSONTime(time.Now()))
+	return result.RowsAffected, result.Error
+}
+
+func (r *NotificationRepository) Exists(userID, id uint) (bool, error) {
+	var count int64
+	err := r.DB.Model(&model.Notification{}).Where("id = ? AND user_id = ?", id, userID).Count(&count).Error
+	return count > 0, err
+}
+
+// DisabledUserIDs 返回 userIDs 中关闭了该类通知的用户
+func (r *NotificationRepository) DisabledUserIDs(userIDs []uint, notificationType string) ([]uint, error) {
+	var ids []uint
+	if len(userIDs) == 0 {
+		return ids, nil
+	}
+	err := r.DB.Model(&model.NotificationPreference{}).
+		Where("user_id IN ? AND type = ? AND enabled = ?", userIDs, notificationType, false).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+func (r *NotificationRepository) ListPreferences(userID uint) ([]model.NotificationPreference, error) {
+	var prefs []model.NotificationPreference
+	err := r.DB.Where("user_id = ?", userID).Find(&prefs).Error
+	return prefs, err
+}
+
+// UpsertPreferences 写入用户的通知开关，已有记录时更新
+func (r *NotificationRepository) UpsertPreferences(prefs []model.NotificationPreference) error {
+	if len(prefs) == 0 {
+		return nil
+	}
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(&prefs).Error
+}
@@ -129,6 +129,25 @@ func (r *PostClassTestRepository) ListSubmissions(testID string, page, limit int
 	return results, total, err
 }
 
+// QuestionTimeStat 单题平均作答耗时
+type QuestionTimeStat struct {
+	QuestionID string  `json:"questionId"`
+	AvgSeconds float64 `json:"avgSeconds"`
+	Samples    int64   `json:"samples"` // 参与统计的作答数（仅统计上报了耗时的作答）
+}
+
+// AverageQuestionTimes 统计试卷每道题的平均作答耗时
+func (r *PostClassTestRepository) AverageQuestionTimes(testID string) ([]QuestionTimeStat, error) {
+	var stats []QuestionTimeStat
+	err := r.DB.Table("post_class_test_answers a").
+		Select("a.question_id, AVG(a.time_seconds) AS avg_seconds, COUNT(*) AS samples").
+		Joins("JOIN post_class_test_submissions s ON s.id = a.submission_id").
+		Where("s.test_id = ? AND s.status = ? AND a.time_seconds > 0 AND a.deleted_at IS NULL AND s.deleted_at IS NULL", testID, "completed").
+		Group("a.question_id").
+		Scan(&stats).Error
+	return stats, err
+}
+
 func (r *PostClassTestRepository) GetSubmissionDetail(submissionID string) (*model.PostClassTestSubmission, []model.PostClassTestAnswer, error) {
 	var submission model.PostClassTestSubmission
 	if err := r.DB.Unscoped().First(&submission, "id = ?", submissionID).Error; err != nil {
@@ -195,6 +214,20 @@ func (r *PostClassTestRepository) UpdateSubmissionWithAnswers(submission *model.
 	})
 }
 
+// SaveGradedAnswers 在事务内保存人工批改后的答题得分和提交的总分、待批改标记
+func (r *PostClassTestRepository) SaveGradedAnswers(submission *model.PostClassTestSubmission, answers []model.PostClassTestAnswer) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		for _, a := range answers {
+			if err := tx.Model(&model.PostClassTestAnswer{}).Where("id = ?", a.ID).
+				Updates(map[string]interface{}{"score": a.Score, "is_correct": a.IsCorrect, "needs_manual": a.NeedsManual}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(submission).
+			Updates(map[string]interface{}{"score": submission.Score, "needs_manual": submission.NeedsManual}).Error
+	})
+}
+
 func (r *PostClassTestRepository) CreateSubmission(submission *model.PostClassTestSubmission) error {
 	return r.DB.Create(submission).Error
 }
@@ -88,3 +88,36 @@ func (r *ReflectionRepository) FindByID(id string) (*model.Reflection, error) {
 	err := r.DB.Preload("User").First(&reflection, "id = ?", id).Error
 	return &reflection, err
 }
+
+func (r *ReflectionRepository) CreateComment(comment *model.ReflectionComment) error {
+	return r.DB.Create(comment).Error
+}
+
+// ListComments 按时间顺序获取反思下的全部评论
+func (r *ReflectionRepository) ListComments(reflectionID string) ([]model.ReflectionComment, error) {
+	comments := []model.ReflectionComment{}
+	err := r.DB.Preload("Author").
+		Where("reflection_id = ?", reflectionID).
+		Order("id ASC").
+		Find(&comments).Error
+	return comments, err
+}
+
+func (r *ReflectionRepository) FindComment(reflectionID string, id uint) (*model.ReflectionComment, error) {
+	var comment model.ReflectionComment
+	err := r.DB.Where("id = ? AND reflection_id = ?", id, reflectionID).First(&comment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// CommentAuthorIDs 获取在反思下留言过的非学生用户（老师、管理员）
+func (r *ReflectionRepository) CommentAuthorIDs(reflectionID string, excludeRole model.UserRole) ([]uint, error) {
+	var ids []uint
+	err := r.DB.Model(&model.ReflectionComment{}).
+		Where("reflection_id = ? AND author_role <> ?", reflectionID, excludeRole).
+		Distinct().
+		Pluck("author_id", &ids).Error
+	return ids, err
+}
@@ -47,14 +47,14 @@ func (r *ResourceCompletionRepository) UpdateCompletionStatus(userID, resourceID
 			UserID:      userID,
 			ResourceID:  resourceID,
 			Completed:   completed,
-			CompletedAt: &now,
+			CompletedAt: &model.JSONTime{Time: now},
 		}
 		err = tx.Create(completion).Error
 	} else {
 		// 更新现有记录
 		existing.Completed = completed
 		if completed {
-			existing.CompletedAt = &now
+			existing.CompletedAt = &model.JSONTime{Time: now}
 		} else {
 			existing.CompletedAt = nil
 		}
@@ -59,6 +59,17 @@ func (r *ResourceRepository) FindByModule(moduleType string) ([]model.Resource,
 func (r *ResourceRepository) FindByModuleType(moduleType string) ([]model.Resource, error) {
 	return r.FindByModule(moduleType)
 }
+
+// FindByModuleIDs 批量获取多个资源模块下指定类型的资源
+func (r *ResourceRepository) FindByModuleIDs(moduleIDs []uint, types ...model.ResourceType) ([]model.Resource, error) {
+	var resources []model.Resource
+	if len(moduleIDs) == 0 {
+		return resources, nil
+	}
+	err := r.DB.Where("module_id IN ? AND type IN ?", moduleIDs, types).Order("id").Find(&resources).Error
+	return resources, err
+}
+
 func (r *ResourceRepository) IncrementViewCount(id uint) error {
 	return r.DB.Model(&model.Resource{}).
 		Where("id = ?", id).
@@ -80,6 +91,25 @@ func (r *ResourceRepository) UpdateFields(id uint, resourceType model.ResourceTy
 		Updates(updates).Error
 }
 
+// FindUnattachedVideoByURL 查询上传接口生成、尚未挂到任何模块下的视频记录，不存在时返回 nil
+func (r *ResourceRepository) FindUnattachedVideoByURL(url string) (*model.Resource, error) {
+	var resources []model.Resource
+	err := r.DB.Where("type = ? AND url = ? AND module_id = ? AND module_type = ?", model.Video, url, 0, "").
+		Order("id DESC").Limit(1).Find(&resources).Error
+	if err != nil || len(resources) == 0 {
+		return nil, err
+	}
+	return &resources[0], nil
+}
+
+// FindPendingProbes 查询元数据待重新探测的视频
+func (r *ResourceRepository) FindPendingProbes(limit int) ([]model.Resource, error) {
+	var resources []model.Resource
+	err := r.DB.Where("type = ? AND probe_status = ?", model.Video, model.ResourcePending).
+		Order("id").Limit(limit).Find(&resources).Error
+	return resources, err
+}
+
 func (r *ResourceRepository) DeleteByType(id uint, resourceType model.ResourceType) error {
 	return r.DB.Where("id = ? AND type = ?", id, resourceType).Delete(&model.Resource{}).Error
 }
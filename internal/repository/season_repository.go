@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"coder_edu_backend/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SeasonRepository struct {
+	DB *gorm.DB
+}
+
+func NewSeasonRepository(db *gorm.DB) *SeasonRepository {
+	return &SeasonRepository{DB: db}
+}
+
+// SeasonStanding 赛季内单个用户的积分合计
+type SeasonStanding struct {
+	UserID uint
+	Name   string
+	Avatar string
+	Points int
+}
+
+func (r *SeasonRepository) Create(season *model.Season) error {
+	return r.DB.Create(season).Error
+}
+
+func (r *SeasonRepository) FindByID(id uint) (*model.Season, error) {
+	var season model.Season
+	err := r.DB.First(&season, id).Error
+	return &season, err
+}
+
+// FindActive 获取当前进行中的赛季
+func (r *SeasonRepository) FindActive() (*model.Season, error) {
+	var season model.Season
+	err := r.DB.Where("status = ?", model.SeasonActive).Order("start_at DESC").First(&season).Error
+	return &season, err
+}
+
+// FindActiveForUpdate 在事务内对进行中的赛季加锁查询，用于创建赛季前的互斥检查
+func (r *SeasonRepository) FindActiveForUpdate() (*model.Season, error) {
+	var season model.Season
+	err := r.DB.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status = ?", model.SeasonActive).Order("start_at DESC").First(&season).Error
+	return &season, err
+}
+
+// MarkClosed 将进行中的赛季关闭并写入结束时间，赛季已被关闭时返回 false
+func (r *SeasonRepository) MarkClosed(id uint, endAt time.Time) (bool, error) {
+	result := r.DB.Model(&model.Season{}).
+		Where("id = ? AND status = ?", id, model.SeasonActive).
+		Updates(map[string]interface{}{"status": model.SeasonClosed, "end_at": endAt})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *SeasonRepository) List() ([]model.Season, error) {
+	var seasons []model.Season
+	err := r.DB.Order("start_at DESC").Find(&seasons).Error
+	return seasons, err
+}
+
+// FindExpired 获取已过结束时间但仍未关闭的赛季
+func (r *SeasonRepository) FindExpired(now time.Time) ([]model.Season, error) {
+	var seasons []model.Season
+	err := r.DB.Where("status = ? AND end_at <= ?", model.SeasonActive, now).Find(&seasons).Error
+	return seasons, err
+}
+
+// Standings 按积分流水统计 [start, end) 内各学生获得的积分并降序排列，limit <= 0 时返回全部
+func (r *SeasonRepository) Standings(start, end time.Time, limit int) ([]SeasonStanding, error) {
+	standings := make([]SeasonStanding, 0)
+	query := r.DB.Table("points_ledger l").
+		Select("l.user_id, u.name, u.avatar, SUM(l.delta) AS points").
+		Joins("JOIN users u ON u.id = l.user_id AND u.deleted_at IS NULL").
+		Where("l.created_at >= ? AND l.created_at < ?", start, end).
+		Where("u.disabled = ? AND u.role = ?", false, model.Student).
+		Group("l.user_id, u.name, u.avatar").
+		Having("SUM(l.delta) > 0").
+		Order("points DESC, l.user_id ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Scan(&standings).Error
+	return standings, err
+}
+
+func (r *SeasonRepository) FindResults(seasonID uint) ([]model.SeasonResult, error) {
+	results := make([]model.SeasonResult, 0)
+	err := r.DB.Where("season_id = ?", seasonID).Order("`rank` ASC").Find(&results).Error
+	return results, err
+}
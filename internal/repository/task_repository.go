@@ -152,8 +152,8 @@ func (r *TaskRepository) GetTodayTasks(resourceModuleID uint, dayOfWeek model.We
 	// 查询当前周里所有模块中当天的任务（不再按 resourceModuleID 精确匹配）
 	query := r.DB.Preload("WeeklyTask").
 		Joins("JOIN teacher_weekly_tasks ON task_items.weekly_task_id = teacher_weekly_tasks.id").
-		Where("task_items.day_of_week = ? AND teacher_weekly_tasks.week_start_date = ? AND teacher_weekly_tasks.week_end_date = ?",
-			dayOfWeek, weekStart.Format(util.DateFormat), weekEnd.Format(util.DateFormat))
+		Where("task_items.day_of_week = ? AND teacher_weekly_tasks.week_start_date = ? AND teacher_weekly_tasks.week_end_date = ? AND teacher_weekly_tasks.is_draft = ?",
+			dayOfWeek, weekStart.Format(util.DateFormat), weekEnd.Format(util.DateFormat), false)
 
 	err := query.Find(&taskItems).Error
 	return taskItems, err
@@ -175,8 +175,8 @@ func (r *TaskRepository) GetAllTodayTasks(dayOfWeek model.Weekday) ([]model.Task
 	var taskItems []model.TaskItem
 	query := r.DB.Preload("WeeklyTask").
 		Joins("JOIN teacher_weekly_tasks ON task_items.weekly_task_id = teacher_weekly_tasks.id").
-		Where("task_items.day_of_week = ? AND teacher_weekly_tasks.week_start_date = ? AND teacher_weekly_tasks.week_end_date = ?",
-			dayOfWeek, weekStart.Format(util.DateFormat), weekEnd.Format(util.DateFormat))
+		Where("task_items.day_of_week = ? AND teacher_weekly_tasks.week_start_date = ? AND teacher_weekly_tasks.week_end_date = ? AND teacher_weekly_tasks.is_draft = ?",
+			dayOfWeek, weekStart.Format(util.DateFormat), weekEnd.Format(util.DateFormat), false)
 
 	err := query.Find(&taskItems).Error
 	return taskItems, err
@@ -217,6 +217,9 @@ func (r *TaskRepository) FindWeeklyTasksByWeek(weekStart, weekEnd string, teache
 	query := r.DB.Preload("TaskItems").Where("week_start_date = ? AND week_end_date = ?", weekStart, weekEnd)
 	if teacherID > 0 {
 		query = query.Where("teacher_id = ?", teacherID)
+	} else {
+		// 学生看不到未发布的草稿
+		query = query.Where("is_draft = ?", false)
 	}
 	err := query.Find(&tasks).Error
 	return tasks, err
@@ -228,6 +231,8 @@ func (r *TaskRepository) FindWeeklyTaskByModuleAndWeek(moduleID uint, weekStart,
 	query := r.DB.Preload("TaskItems").Where("resource_module_id = ? AND week_start_date = ? AND week_end_date = ?", moduleID, weekStart, weekEnd)
 	if teacherID > 0 {
 		query = query.Where("teacher_id = ?", teacherID)
+	} else {
+		query = query.Where("is_draft = ?", false)
 	}
 	err := query.First(&task).Error
 	return &task, err
@@ -237,8 +242,8 @@ func (r *TaskRepository) FindWeeklyTaskByModuleAndWeek(moduleID uint, weekStart,
 func (r *TaskRepository) FindTaskItemByExerciseAndWeek(exerciseID uint, dayOfWeek model.Weekday, weekStart, weekEnd string) (*model.TaskItem, error) {
 	var item model.TaskItem
 	err := r.DB.Joins("JOIN teacher_weekly_tasks ON task_items.weekly_task_id = teacher_weekly_tasks.id").
-		Where("task_items.exercise_id = ? AND task_items.day_of_week = ? AND teacher_weekly_tasks.week_start_date = ? AND teacher_weekly_tasks.week_end_date = ?",
-			exerciseID, dayOfWeek, weekStart, weekEnd).First(&item).Error
+		Where("task_items.exercise_id = ? AND task_items.day_of_week = ? AND teacher_weekly_tasks.week_start_date = ? AND teacher_weekly_tasks.week_end_date = ? AND teacher_weekly_tasks.is_draft = ?",
+			exerciseID, dayOfWeek, weekStart, weekEnd, false).First(&item).Error
 	return &item, err
 }
 
@@ -291,3 +296,113 @@ func (r *TaskRepository) DeleteWeeklyTask(taskID uint, teacherID uint) error {
 		return nil
 	})
 }
+
+// FindOwnedWeeklyTask 获取老师自己的周任务（含任务项）
+func (r *TaskRepository) FindOwnedWeeklyTask(taskID, teacherID uint) (*model.TeacherWeeklyTask, error) {
+	var task model.TeacherWeeklyTask
+	err := r.DB.Preload("TaskItems").Where("id = ? AND teacher_id = ?", taskID, teacherID).First(&task).Error
+	return &task, err
+}
+
+// PublishWeeklyTask 发布周任务草稿
+func (r *TaskRepository) PublishWeeklyTask(taskID, teacherID uint) error {
+	result := r.DB.Model(&model.TeacherWeeklyTask{}).
+		Where("id = ? AND teacher_id = ?", taskID, teacherID).
+		Update("is_draft", false)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ExistsWeeklyTask 检查老师在某个资源分类下指定周是否已有周任务
+func (r *TaskRepository) ExistsWeeklyTask(teacherID, resourceModuleID uint, weekStart string) (bool, error) {
+	var count int64
+	err := r.DB.Model(&model.TeacherWeeklyTask{}).
+		Where("teacher_id = ? AND resource_module_id = ? AND week_start_date = ?", teacherID, resourceModuleID, weekStart).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// FindWeeklyTaskTemplate 获取老师在某个资源分类下的周任务模板
+func (r *TaskRepository) FindWeeklyTaskTemplate(teacherID, resourceModuleID uint) (*model.WeeklyTaskTemplate, error) {
+	var template model.WeeklyTaskTemplate
+	err := r.DB.Where("teacher_id = ? AND resource_module_id = ?", teacherID, resourceModuleID).First(&template).Error
+	return &template, err
+}
+
+// SaveWeeklyTaskTemplate 创建或更新周任务模板
+func (r *TaskRepository) SaveWeeklyTaskTemplate(template *model.WeeklyTaskTemplate) error {
+	return r.DB.Save(template).Error
+}
+
+// ListWeeklyTaskTemplates 获取老师的全部周任务模板
+func (r *TaskRepository) ListWeeklyTaskTemplates(teacherID uint) ([]model.WeeklyTaskTemplate, error) {
+	var templates []model.WeeklyTaskTemplate
+	err := r.DB.Where("teacher_id = ?", teacherID).Order("resource_module_id").Find(&templates).Error
+	return templates, err
+}
+
+// DeleteWeeklyTaskTemplate 删除周任务模板，已生成的周任务不受影响
+func (r *TaskRepository) DeleteWeeklyTaskTemplate(id, teacherID uint) error {
+	result := r.DB.Where("id = ? AND teacher_id = ?", id, teacherID).Delete(&model.WeeklyTaskTemplate{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindTemplatesDueForWeek 获取开启了自动生成且尚未为指定周生成任务的模板
+func (r *TaskRepository) FindTemplatesDueForWeek(weekStart string) ([]model.WeeklyTaskTemplate, error) {
+	var templates []model.WeeklyTaskTemplate
+	err := r.DB.Where("auto_instantiate = ? AND (last_instantiated_week IS NULL OR last_instantiated_week < ?)", true, weekStart).
+		Find(&templates).Error
+	return templates, err
+}
+
+// ClaimTemplateWeek 标记模板已为指定周生成任务，只有一个调用方能抢到同一周，
+// 多实例同时运行定时任务时以此防止重复生成
+func (r *TaskRepository) ClaimTemplateWeek(templateID uint, weekStart time.Time) (bool, error) {
+	result := r.DB.Model(&model.WeeklyTaskTemplate{}).
+		Where("id = ? AND (last_instantiated_week IS NULL OR last_instantiated_week < ?)", templateID, weekStart.Format(util.DateFormat)).
+		Update("last_instantiated_week", model.NewJSONTime(weekStart))
+	return result.RowsAffected > 0, result.Error
+}
+
+// WeekItemCount 某一周的任务项数量，WeekStart 为周一日期（YYYY-MM-DD）
+type WeekItemCount struct {
+	WeekStart string
+	Count     int
+}
+
+// CountPublishedItemsByWeek 统计周一日期在 [from, to] 内每周已发布的任务项数量
+func (r *TaskRepository) CountPublishedItemsByWeek(from, to string) ([]WeekItemCount, error) {
+	var counts []WeekItemCount
+	err := r.DB.Table("task_items ti").
+		Select("DATE_FORMAT(twt.week_start_date, '%Y-%m-%d') AS week_start, COUNT(ti.id) AS count").
+		Joins("JOIN teacher_weekly_tasks twt ON twt.id = ti.weekly_task_id AND twt.deleted_at IS NULL").
+		Where("ti.deleted_at IS NULL AND twt.is_draft = ? AND twt.week_start_date BETWEEN ? AND ?", false, from, to).
+		Group("week_start").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// CountCompletedItemsByWeek 统计学生在 [from, to] 内每周已完成的任务项数量，同一任务项只计一次
+func (r *TaskRepository) CountCompletedItemsByWeek(userID uint, from, to string) ([]WeekItemCount, error) {
+	var counts []WeekItemCount
+	err := r.DB.Table("task_items ti").
+		Select("DATE_FORMAT(twt.week_start_date, '%Y-%m-%d') AS week_start, COUNT(DISTINCT ti.id) AS count").
+		Joins("JOIN teacher_weekly_tasks twt ON twt.id = ti.weekly_task_id AND twt.deleted_at IS NULL").
+		Joins("JOIN daily_task_completions dtc ON dtc.task_item_id = ti.id AND dtc.deleted_at IS NULL").
+		Where("dtc.user_id = ? AND dtc.is_completed = ?", userID, true).
+		Where("ti.deleted_at IS NULL AND twt.is_draft = ? AND twt.week_start_date BETWEEN ? AND ?", false, from, to).
+		Group("week_start").
+		Scan(&counts).Error
+	return counts, err
+}
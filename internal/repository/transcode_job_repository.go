@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"coder_edu_backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type TranscodeJobRepository struct {
+	DB *gorm.DB
+}
+
+func NewTranscodeJobRepository(db *gorm.DB) *TranscodeJobRepository {
+	return &TranscodeJobRepository{DB: db}
+}
+
+func (r *TranscodeJobRepository) Create(job *model.TranscodeJob) error {
+	return r.DB.Create(job).Error
+}
+
+func (r *TranscodeJobRepository) Update(job *model.TranscodeJob) error {
+	return r.DB.Save(job).Error
+}
+
+// FindPending 按创建顺序获取待处理任务
+func (r *TranscodeJobRepository) FindPending(limit int) ([]model.TranscodeJob, error) {
+	var jobs []model.TranscodeJob
+	err := r.DB.Where("status = ?", model.ResourcePending).
+		Order("id asc").
+		Limit(limit).
+		Find(&jobs).Error
+	return jobs, err
+}
+
+// Claim 将任务从 pending 置为 processing，返回是否抢占成功（多实例部署时避免重复处理）
+func (r *TranscodeJobRepository) Claim(id uint) (bool, error) {
+	result := r.DB.Model(&model.TranscodeJob{}).
+		Where("id = ? AND status = ?", id, model.ResourcePending).
+		Updates(map[string]interface{}{
+			"status":   model.ResourceProcessing,
+			"attempts": gorm.Expr("attempts + 1"),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ResetProcessing 将进程异常退出时遗留的 processing 任务恢复为 pending
+func (r *TranscodeJobRepository) ResetProcessing() error {
+	return r.DB.Model(&model.TranscodeJob{}).
+		Where("status = ?", model.ResourceProcessing).
+		Update("status", model.ResourcePending).Error
+}
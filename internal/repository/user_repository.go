@@ -18,10 +18,10 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 func (r *UserRepository) Create(user *model.User) error {
 	now := time.Now()
 	if user.CreatedAt.IsZero() {
-		user.CreatedAt = now
+		user.CreatedAt = model.NewJSONTime(now)
 	}
 	if user.UpdatedAt.IsZero() {
-		user.UpdatedAt = now
+		user.UpdatedAt = model.NewJSONTime(now)
 	}
 
 	return r.DB.Create(user).Error
@@ -39,6 +39,13 @@ func (r *UserRepository) FindByEmail(email string) (*model.User, error) {
 	return &user, err
 }
 
+// EmailTaken 邮箱是否已被占用，包含已软删除的账号（唯一索引对其仍然生效）
+func (r *UserRepository) EmailTaken(email string) (bool, error) {
+	var count int64
+	err := r.DB.Unscoped().Model(&model.User{}).Where("email = ?", email).Count(&count).Error
+	return count > 0, err
+}
+
 func (r *UserRepository) Update(user *model.User) error {
 	return r.DB.Save(user).Error
 }
@@ -66,6 +73,26 @@ func (r *UserRepository) UpdateLastSeen(userID uint) error {
 		UpdateColumn("last_seen", time.Now()).
 		Error
 }
+
+// IsDisabled 查询用户是否被禁用，用户不存在时返回 gorm.ErrRecordNotFound
+func (r *UserRepository) IsDisabled(userID uint) (bool, error) {
+	var user model.User
+	err := r.DB.Select("id", "disabled").First(&user, userID).Error
+	return user.Disabled, err
+}
+
+// FindHideOnlineIDs 返回 userIDs 中设置了对他人隐藏在线状态的用户ID
+func (r *UserRepository) FindHideOnlineIDs(userIDs []uint) ([]uint, error) {
+	ids := make([]uint, 0)
+	if len(userIDs) == 0 {
+		return ids, nil
+	}
+	err := r.DB.Model(&model.User{}).
+		Where("id IN ? AND hide_online = ?", userIDs, true).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
 func (r *UserRepository) FindTopByXP(limit int) ([]model.User, error) {
 	var users []model.User
 	err := r.DB.Where("disabled = ?", false).Order("xp DESC").Limit(limit).Find(&users).Error
@@ -1,26 +1,39 @@
 package service
 
 import (
+	"coder_edu_backend/internal/config"
 	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/util"
+	"errors"
+	"fmt"
+	"time"
 
 	"coder_edu_backend/internal/repository"
+
+	"gorm.io/gorm"
 )
 
 type AchievementService struct {
 	AchievementRepo *repository.AchievementRepository
 	UserRepo        *repository.UserRepository
 	GoalRepo        *repository.GoalRepository
+	SeasonRepo      *repository.SeasonRepository
+	Events          *EventBus
 }
 
 func NewAchievementService(
 	achievementRepo *repository.AchievementRepository,
 	userRepo *repository.UserRepository,
 	goalRepo *repository.GoalRepository,
+	seasonRepo *repository.SeasonRepository,
+	events *EventBus,
 ) *AchievementService {
 	return &AchievementService{
 		AchievementRepo: achievementRepo,
 		UserRepo:        userRepo,
 		GoalRepo:        goalRepo,
+		SeasonRepo:      seasonRepo,
+		Events:          events,
 	}
 }
 
@@ -37,6 +50,7 @@ type LeaderboardEntry struct {
 	Rank   int    `json:"rank"`
 	User   string `json:"user"`
 	XP     int    `json:"xp"`
+	Points int    `json:"points,omitempty"` // 赛季排行榜按赛季内获得的积分排名，总排行榜不返回
 	Avatar string `json:"avatar,omitempty"`
 }
 
@@ -103,6 +117,147 @@ func (s *AchievementService) GetLeaderboard(limit int) ([]LeaderboardEntry, erro
 	return leaderboard, nil
 }
 
+// SeasonLeaderboard 赛季排行榜，没有进行中的赛季时 Season 为空
+type SeasonLeaderboard struct {
+	Season      *model.Season      `json:"season"`
+	Leaderboard []LeaderboardEntry `json:"leaderboard"`
+}
+
+type SeasonRequest struct {
+	Name    string    `json:"name" binding:"required"`
+	StartAt time.Time `json:"startAt" binding:"required"`
+	EndAt   time.Time `json:"endAt" binding:"required"`
+}
+
+// GetSeasonLeaderboard 按当前赛季内获得的积分排名
+func (s *AchievementService) GetSeasonLeaderboard(limit int) (*SeasonLeaderboard, error) {
+	result := &SeasonLeaderboard{Leaderboard: make([]LeaderboardEntry, 0)}
+	season, err := s.SeasonRepo.FindActive()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Season = season
+
+	standings, err := s.SeasonRepo.Standings(season.StartAt.Time, season.EndAt.Time, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i, st := range standings {
+		result.Leaderboard = append(result.Leaderboard, LeaderboardEntry{
+			Rank:   i + 1,
+			User:   st.Name,
+			Points: st.Points,
+			Avatar: st.Avatar,
+		})
+	}
+	return result, nil
+}
+
+// CreateSeason 创建新赛季，同一时间只允许一个进行中的赛季。
+// 在事务内对进行中的赛季加锁查询，避免并发创建出多个进行中的赛季
+func (s *AchievementService) CreateSeason(req SeasonRequest) (*model.Season, error) {
+	if !req.EndAt.After(req.StartAt) {
+		return nil, util.ErrInvalidSeasonRange
+	}
+
+	season := &model.Season{
+		Name:    req.Name,
+		StartAt: model.NewJSONTime(req.StartAt),
+		EndAt:   model.NewJSONTime(req.EndAt),
+		Status:  model.SeasonActive,
+	}
+	err := s.SeasonRepo.DB.Transaction(func(tx *gorm.DB) error {
+		repo := &repository.SeasonRepository{DB: tx}
+		if _, err := repo.FindActiveForUpdate(); err == nil {
+			return util.ErrSeasonActive
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return repo.Create(season)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return season, nil
+}
+
+func (s *AchievementService) ListSeasons() ([]model.Season, error) {
+	return s.SeasonRepo.List()
+}
+
+func (s *AchievementService) GetSeasonResults(seasonID uint) ([]model.SeasonResult, error) {
+	return s.SeasonRepo.FindResults(seasonID)
+}
+
+// CloseSeason 结束赛季：将最终排名写入 SeasonResult 并关闭赛季。
+// 提前结束时赛季结束时间截断为当前时间；用户的累计积分不受影响。
+// 关闭通过带状态条件的 UPDATE 完成，并发关闭同一赛季时只有一方会写入排名
+func (s *AchievementService) CloseSeason(seasonID uint) (*model.Season, error) {
+	season, err := s.SeasonRepo.FindByID(seasonID)
+	if err != nil {
+		return nil, err
+	}
+	if season.Status == model.SeasonClosed {
+		return nil, util.ErrSeasonClosed
+	}
+
+	if now := time.Now(); now.Before(season.EndAt.Time) {
+		season.EndAt = model.NewJSONTime(now)
+	}
+	err = s.SeasonRepo.DB.Transaction(func(tx *gorm.DB) error {
+		repo := &repository.SeasonRepository{DB: tx}
+		closed, err := repo.MarkClosed(season.ID, season.EndAt.Time)
+		if err != nil {
+			return err
+		}
+		if !closed {
+			return util.ErrSeasonClosed
+		}
+		standings, err := repo.Standings(season.StartAt.Time, season.EndAt.Time, 0)
+		if err != nil {
+			return err
+		}
+		if len(standings) > 0 {
+			results := make([]model.SeasonResult, 0, len(standings))
+			for i, st := range standings {
+				results = append(results, model.SeasonResult{
+					SeasonID: season.ID,
+					UserID:   st.UserID,
+					Rank:     i + 1,
+					Points:   st.Points,
+				})
+			}
+			if err := tx.CreateInBatches(&results, 500).Error; err != nil {
+				return err
+			}
+		}
+		season.Status = model.SeasonClosed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return season, nil
+}
+
+// CloseExpiredSeasons 关闭所有已到结束时间的赛季（由后台定时触发）
+func (s *AchievementService) CloseExpiredSeasons() error {
+	seasons, err := s.SeasonRepo.FindExpired(time.Now())
+	if err != nil {
+		return err
+	}
+	for _, season := range seasons {
+		// 管理员可能同时手动关闭了该赛季
+		if _, err := s.CloseSeason(season.ID); err != nil && !errors.Is(err, util.ErrSeasonClosed) {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *AchievementService) GetUserGoals(userID uint) ([]model.Goal, error) {
 	return s.GoalRepo.FindByUserID(userID)
 }
@@ -130,17 +285,42 @@ func (s *AchievementService) UpdateGoalProgress(userID uint, goalID uint, progre
 		return err
 	}
 
+	justCompleted := progress >= 100 && goal.Status != model.GoalCompleted
 	goal.Current = progress
 	if progress >= 100 {
 		goal.Status = model.GoalCompleted
+	}
 
-		// 奖励XP
-		xpReward := 50
-		err = s.UserRepo.UpdateXP(userID, xpReward)
-		if err != nil {
-			return err
-		}
+	if err := s.GoalRepo.Update(goal); err != nil {
+		return err
+	}
+
+	// 奖励由订阅者发放，各自按目标ID去重，重复达到 100% 不会重复奖励
+	if justCompleted {
+		s.Events.Publish(EventGoalCompleted, GoalCompletedEvent{
+			UserID: userID,
+			GoalID: goal.ID,
+			Title:  goal.Title,
+		})
 	}
+	return nil
+}
 
-	return s.GoalRepo.Update(goal)
+// GoalCompletedHandler 返回目标达成事件的处理函数：按配置发放成就和经验
+func (s *AchievementService) GoalCompletedHandler(cfg config.AchievementConfig) EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(GoalCompletedEvent)
+		if !ok || cfg.GoalCompletedName == "" {
+			return nil
+		}
+		key := fmt.Sprintf("%s:%d", model.PointSourceGoalCompleted, evt.GoalID)
+		_, err := s.AchievementRepo.AwardOnce(&model.Achievement{
+			UserID:    evt.UserID,
+			Name:      cfg.GoalCompletedName,
+			Icon:      cfg.GoalCompletedIcon,
+			EarnedXP:  cfg.GoalCompletedXP,
+			SourceKey: &key,
+		})
+		return err
+	}
 }
@@ -4,19 +4,116 @@ import (
 	"bufio"
 	"bytes"
 	"coder_edu_backend/internal/config"
+	"coder_edu_backend/pkg/logger"
+	"coder_edu_backend/pkg/monitoring"
+	goctx "context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+
+	"go.uber.org/zap"
 )
 
 type AIService struct {
-	config config.AIConfig
+	config    config.AIConfig
+	providers []config.AIProviderConfig
 }
 
 func NewAIService(cfg config.AIConfig) *AIService {
-	return &AIService{config: cfg}
+	return &AIService{config: cfg, providers: buildAIProviders(cfg)}
+}
+
+// buildAIProviders 按优先级整理模型服务列表：ai.base_url 为主服务，其后为 ai.providers
+func buildAIProviders(cfg config.AIConfig) []config.AIProviderConfig {
+	providers := make([]config.AIProviderConfig, 0, len(cfg.Providers)+1)
+	if cfg.BaseURL != "" || len(cfg.Providers) == 0 {
+		providers = append(providers, config.AIProviderConfig{
+			Name:    "primary",
+			BaseURL: cfg.BaseURL,
+			APIKey:  cfg.APIKey,
+			Model:   cfg.Model,
+		})
+	}
+	for i, p := range cfg.Providers {
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("provider-%d", i+1)
+		}
+		if p.Model == "" {
+			p.Model = cfg.Model
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
+
+// aiStatusError 模型服务返回的非 200 响应
+type aiStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *aiStatusError) Error() string {
+	return fmt.Sprintf("AI API error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// aiFailoverReason 判断错误是否应切换到下一个模型服务，返回用于监控的失败原因；
+// 4xx（如鉴权、参数错误）换服务也无济于事，直接返回给调用方
+func aiFailoverReason(ctx goctx.Context, err error) (string, bool) {
+	if ctx.Err() != nil {
+		return "", false
+	}
+	var statusErr *aiStatusError
+	if errors.As(err, &statusErr) {
+		return "status_5xx", statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return "connection", true
+}
+
+func (s *AIService) recordProviderFailure(provider config.AIProviderConfig, reason string, err error, hasNext bool) {
+	monitoring.AIProviderFailures.WithLabelValues(provider.Name, reason).Inc()
+	logger.Log.Warn("AI provider failed",
+		zap.String("provider", provider.Name),
+		zap.String("reason", reason),
+		zap.Bool("fallback", hasNext),
+		zap.Error(err))
+}
+
+func (s *AIService) recordProviderServed(provider config.AIProviderConfig) {
+	monitoring.AIProviderRequests.WithLabelValues(provider.Name).Inc()
+	logger.Log.Info("AI request served", zap.String("provider", provider.Name))
+}
+
+// doChatRequest 向指定模型服务发送 Chat Completions 请求，非 200 响应返回 *aiStatusError
+func (s *AIService) doChatRequest(ctx goctx.Context, provider config.AIProviderConfig, reqBody map[string]interface{}) (*http.Response, error) {
+	reqBody["model"] = provider.Model
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", provider.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+provider.APIKey)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, &aiStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return resp, nil
 }
 
 type AIChatMessage struct {
@@ -46,6 +143,11 @@ type StreamResult struct {
 }
 
 func (s *AIService) ChatStream(prompt string, context string, history []AIChatMessage) (<-chan string, <-chan error, *StreamResult) {
+	return s.ChatStreamContext(goctx.Background(), prompt, context, history)
+}
+
+// ChatStreamContext 与 ChatStream 相同，ctx 取消时中断上游请求，停止消耗 token
+func (s *AIService) ChatStreamContext(ctx goctx.Context, prompt string, context string, history []AIChatMessage) (<-chan string, <-chan error, *StreamResult) {
 	out := make(chan string)
 	errChan := make(chan error, 1)
 	result := &StreamResult{}
@@ -91,81 +193,94 @@ func (s *AIService) ChatStream(prompt string, context string, history []AIChatMe
 	})
 
 	reqBody := map[string]interface{}{
-		"model":    s.config.Model,
 		"messages": messages,
 		"stream":   true,
 	}
 
-	jsonData, _ := json.Marshal(reqBody)
-
 	go func() {
 		defer close(out)
 		defer close(errChan)
 
-		req, err := http.NewRequest("POST", s.config.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+s.config.APIKey)
+		// 按优先级尝试模型服务；一旦向调用方输出过内容就不再切换，避免回答拼接自不同模型
+		var lastErr error
+		for i, provider := range s.providers {
+			hasNext := i < len(s.providers)-1
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			errChan <- err
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			errChan <- fmt.Errorf("AI API error (status %d): %s", resp.StatusCode, string(body))
-			return
-		}
-
-		reader := bufio.NewReader(resp.Body)
-		for {
-			line, err := reader.ReadString('\n')
+			resp, err := s.doChatRequest(ctx, provider, reqBody)
 			if err != nil {
-				if err != io.EOF {
-					errChan <- err
+				lastErr = err
+				reason, failover := aiFailoverReason(ctx, err)
+				if !failover {
+					break
 				}
-				break
-			}
-
-			line = strings.TrimSpace(line)
-			if line == "" || !strings.HasPrefix(line, "data: ") {
+				s.recordProviderFailure(provider, reason, err, hasNext)
 				continue
 			}
 
-			data := strings.TrimPrefix(line, "data: ")
-			if data == "[DONE]" {
-				break
-			}
-
-			var streamResp ChatCompletionResponse
-			if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
+			streamed, err := readChatStream(resp.Body, out, result)
+			resp.Body.Close()
+			if err != nil && !streamed && ctx.Err() == nil {
+				lastErr = err
+				s.recordProviderFailure(provider, "stream", err, hasNext)
 				continue
 			}
 
-			if len(streamResp.Choices) > 0 {
-				content := streamResp.Choices[0].Delta.Content
-				if content != "" {
-					out <- content
-				}
-				// 检测 finish_reason: "length" 表示回答因token上限被截断
-				if streamResp.Choices[0].FinishReason != nil && *streamResp.Choices[0].FinishReason == "length" {
-					result.Truncated = true
-				}
+			s.recordProviderServed(provider)
+			if err != nil {
+				errChan <- err
 			}
+			return
+		}
+
+		if lastErr != nil {
+			errChan <- lastErr
 		}
 	}()
 
 	return out, errChan, result
 }
 
+// readChatStream 解析 SSE 流并逐段输出内容，streamed 表示是否已向 out 输出过内容
+func readChatStream(body io.Reader, out chan<- string, result *StreamResult) (streamed bool, err error) {
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err != io.EOF {
+				return streamed, err
+			}
+			return streamed, nil
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" || !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			return streamed, nil
+		}
+
+		var streamResp ChatCompletionResponse
+		if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
+			continue
+		}
+
+		if len(streamResp.Choices) > 0 {
+			content := streamResp.Choices[0].Delta.Content
+			if content != "" {
+				out <- content
+				streamed = true
+			}
+			// 检测 finish_reason: "length" 表示回答因token上限被截断
+			if streamResp.Choices[0].FinishReason != nil && *streamResp.Choices[0].FinishReason == "length" {
+				result.Truncated = true
+			}
+		}
+	}
+}
+
 func (s *AIService) Chat(prompt string, context string) (string, error) {
 	messages := []AIChatMessage{}
 
@@ -186,34 +301,34 @@ func (s *AIService) Chat(prompt string, context string) (string, error) {
 		Content: prompt,
 	})
 
-	reqBody := ChatCompletionRequest{
-		Model:    s.config.Model,
-		Messages: messages,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequest("POST", s.config.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
+	reqBody := map[string]interface{}{
+		"messages": messages,
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+s.config.APIKey)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+	ctx := goctx.Background()
+	var body []byte
+	var lastErr error
+	for i, provider := range s.providers {
+		resp, err := s.doChatRequest(ctx, provider, reqBody)
+		if err == nil {
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		if err != nil {
+			lastErr = err
+			reason, failover := aiFailoverReason(ctx, err)
+			if !failover {
+				return "", err
+			}
+			s.recordProviderFailure(provider, reason, err, i < len(s.providers)-1)
+			continue
+		}
+		s.recordProviderServed(provider)
+		lastErr = nil
+		break
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("AI API error (status %d): %s", resp.StatusCode, string(body))
+	if lastErr != nil {
+		return "", lastErr
 	}
 
 	var result ChatCompletionResponse
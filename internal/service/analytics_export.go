@@ -0,0 +1,134 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// 分析数据导出类型
+const (
+	AnalyticsExportOverview = "overview"
+	AnalyticsExportProgress = "progress"
+	AnalyticsExportSkills   = "skills"
+)
+
+// MaxAnalyticsExportWeeks 周进度导出的最大周数，每个学生每周都要单独统计，过大会拖慢导出
+const MaxAnalyticsExportWeeks = 52
+
+// utf8BOM 让 Excel 以 UTF-8 打开 CSV，避免中文表头乱码
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+var analyticsExportHeaders = map[string][]string{
+	AnalyticsExportOverview: {"学生ID", "姓名", "邮箱", "模块总数", "已完成模块", "平均分"},
+	AnalyticsExportProgress: {"学生ID", "姓名", "邮箱", "周", "学习时长(分钟)", "完成模块", "平均分"},
+	AnalyticsExportSkills:   {"学生ID", "姓名", "邮箱", "技能", "得分"},
+}
+
+// IsValidAnalyticsExportType 判断导出类型是否受支持
+func IsValidAnalyticsExportType(exportType string) bool {
+	_, ok := analyticsExportHeaders[exportType]
+	return ok
+}
+
+// ExportAnalyticsCSV 将学生的分析数据以 CSV 流式写入 w。
+// userIDs 为空时导出全部启用的学生；teacherID 非 0 时只导出在该教师创建的关卡中有过挑战记录的学生，
+// userIDs 中的其他学生会被忽略。按批次查询学生并逐批 Flush，不在内存中缓存整份文件
+func (s *AnalyticsService) ExportAnalyticsCSV(w io.Writer, exportType string, weeks int, userIDs []uint, teacherID uint) error {
+	headers, ok := analyticsExportHeaders[exportType]
+	if !ok {
+		return fmt.Errorf("unsupported export type: %s", exportType)
+	}
+	if _, err := w.Write(utf8BOM); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+
+	query := s.DB.Model(&model.User{}).
+		Select("id", "name", "email").
+		Where("role = ? AND disabled = ?", model.Student, false)
+	if len(userIDs) > 0 {
+		query = query.Where("id IN ?", userIDs)
+	}
+	if teacherID != 0 {
+		taught := s.DB.Table("level_attempts").
+			Select("DISTINCT level_attempts.user_id").
+			Joins("JOIN levels ON levels.id = level_attempts.level_id").
+			Where("levels.creator_id = ?", teacherID)
+		query = query.Where("id IN (?)", taught)
+	}
+
+	var students []model.User
+	var writeErr error
+	result := query.Order("id ASC").FindInBatches(&students, 100, func(tx *gorm.DB, batch int) error {
+		for _, u := range students {
+			if writeErr = s.writeAnalyticsRows(cw, exportType, weeks, u); writeErr != nil {
+				return writeErr
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func (s *AnalyticsService) writeAnalyticsRows(cw *csv.Writer, exportType string, weeks int, u model.User) error {
+	prefix := []string{strconv.FormatUint(uint64(u.ID), 10), u.Name, u.Email}
+
+	switch exportType {
+	case AnalyticsExportOverview:
+		overview, err := s.GetLearningOverview(u.ID)
+		if err != nil {
+			return err
+		}
+		return cw.Write(append(prefix,
+			strconv.Itoa(overview.TotalModules),
+			strconv.Itoa(overview.CompletedModules),
+			strconv.FormatFloat(overview.AverageScore, 'f', 2, 64),
+		))
+	case AnalyticsExportProgress:
+		progress, err := s.GetLearningProgress(u.ID, weeks)
+		if err != nil {
+			return err
+		}
+		for _, week := range progress.Weeks {
+			row := append(append([]string{}, prefix...),
+				week.Week,
+				strconv.Itoa(week.StudyTime),
+				strconv.Itoa(week.ModulesCompleted),
+				strconv.FormatFloat(week.AverageScore, 'f', 2, 64),
+			)
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	case AnalyticsExportSkills:
+		skills, err := s.GetSkillAssessments(u.ID)
+		if err != nil {
+			return err
+		}
+		for i, skill := range skills.Skills {
+			row := append(append([]string{}, prefix...), skill, strconv.Itoa(skills.KnowledgeCoverage[i]))
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
 	"fmt"
 	"time"
 
@@ -51,7 +52,7 @@ func (s *AnalyticsService) GetWeeklyChallengeStats(userID uint, weeks int, speci
 		statsMap[s.Week] = s
 	}
 
-	var result []model.ChallengeWeeklyData
+	result := make([]model.ChallengeWeeklyData, 0)
 	now := time.Now()
 
 	// 如果指定了某周，只返回该周的数据（即使是 0）
@@ -178,7 +179,35 @@ func (s *AnalyticsService) GetAbilityRadar(userID uint) ([]model.AbilityRadarDat
 	}
 
 	// 3. 构建雷达图数据，确保顺序一致且没有数据项显示为 0
-	var radarData []model.AbilityRadarData
+	return buildAbilityRadar(abilities, scoreMap), nil
+}
+
+// GetClassAbilityRadar 统计全班在各能力上的平均得分率；指定 studentIDs 时额外返回该子集的平均值
+func (s *AnalyticsService) GetClassAbilityRadar(studentIDs []uint) (*model.ClassAbilityRadar, error) {
+	var abilities []model.Ability
+	if err := s.DB.Where("enabled = ?", true).Order("`order` ASC").Find(&abilities).Error; err != nil {
+		return nil, err
+	}
+
+	classScores, err := s.LevelAttemptRepo.GetNormalizedAbilityScores(nil)
+	if err != nil {
+		return nil, err
+	}
+	radar := &model.ClassAbilityRadar{ClassAverage: buildAbilityRadar(abilities, classScores)}
+
+	if len(studentIDs) > 0 {
+		groupScores, err := s.LevelAttemptRepo.GetNormalizedAbilityScores(studentIDs)
+		if err != nil {
+			return nil, err
+		}
+		radar.Subgroup = buildAbilityRadar(abilities, groupScores)
+	}
+	return radar, nil
+}
+
+// buildAbilityRadar 按能力维度顺序生成雷达图数据，无数据的维度记为 0
+func buildAbilityRadar(abilities []model.Ability, scoreMap map[uint]float64) []model.AbilityRadarData {
+	radarData := make([]model.AbilityRadarData, 0, len(abilities))
 	for _, a := range abilities {
 		score := 0
 		if val, ok := scoreMap[a.ID]; ok {
@@ -189,8 +218,7 @@ func (s *AnalyticsService) GetAbilityRadar(userID uint) ([]model.AbilityRadarDat
 			Value: score,
 		})
 	}
-
-	return radarData, nil
+	return radarData
 }
 
 func (s *AnalyticsService) GetLevelLearningCurve(userID, levelID uint, limit int) (*model.LevelCurveResponse, error) {
@@ -236,7 +264,7 @@ func (s *AnalyticsService) GetLevelLearningCurve(userID, levelID uint, limit int
 	}
 
 	// 5. 从今天起往前推 limit 天，填充数据
-	var curve []model.AttemptCurveData
+	curve := make([]model.AttemptCurveData, 0, limit)
 	now := time.Now()
 	for i := limit - 1; i >= 0; i-- {
 		date := now.AddDate(0, 0, -i)
@@ -267,6 +295,12 @@ func (s *AnalyticsService) GetPersonalizedRecommendations(userID uint) (*model.P
 		return nil, err
 	}
 
+	items, err := s.rankRecommendationItems(userID)
+	if err != nil {
+		return nil, err
+	}
+	recommendations.Items = items
+
 	return recommendations, nil
 }
 
@@ -274,7 +308,7 @@ func (s *AnalyticsService) StartLearningSession(userID, moduleID uint) (uint, er
 	session := &model.LearningSession{
 		UserID:    userID,
 		ModuleID:  moduleID,
-		StartTime: time.Now(),
+		StartTime: model.NewJSONTime(time.Now()),
 	}
 
 	err := s.SessionRepo.Create(session)
@@ -291,12 +325,74 @@ func (s *AnalyticsService) EndLearningSession(userID, sessionID uint, activity s
 		return err
 	}
 
+	// 已被心跳超时任务自动结束的会话保持截止到最后心跳的时长
+	if session.EndTime != nil {
+		return nil
+	}
+
 	endTime := time.Now()
-	duration := int(endTime.Sub(session.StartTime).Minutes())
+	duration := int(endTime.Sub(session.StartTime.Time).Minutes())
 
-	session.EndTime = &endTime
+	session.EndTime = &model.JSONTime{Time: endTime}
 	session.Duration = duration
 	session.Activity = activity
 
 	return s.SessionRepo.Update(session)
 }
+
+// HeartbeatLearningSession 刷新会话的最近活跃时间，客户端应在会话期间定期调用
+func (s *AnalyticsService) HeartbeatLearningSession(userID, sessionID uint) error {
+	ok, err := s.SessionRepo.Touch(sessionID, userID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return util.ErrSessionNotFound
+	}
+	return nil
+}
+
+// staleSessionEndTime 自动结束会话的截止时间：取最后一次心跳与会话期间最近一次学习记录中较晚者，
+// 客户端从未发送心跳时也能按实际学习活动计算时长；两者都没有时才截止到开始时间
+func (s *AnalyticsService) staleSessionEndTime(session *model.LearningSession) (model.JSONTime, error) {
+	endTime := session.StartTime
+	if session.LastSeenAt != nil && session.LastSeenAt.After(endTime.Time) {
+		endTime = *session.LastSeenAt
+	}
+	lastActivity, err := s.SessionRepo.LastActivityAt(session.UserID, session.StartTime.Time)
+	if err != nil {
+		return endTime, err
+	}
+	if lastActivity.After(endTime.Time) {
+		endTime = lastActivity
+	}
+	return endTime, nil
+}
+
+// CloseStaleSessions 自动结束超过 timeout 未收到心跳的会话，时长截止到最后一次心跳或学习活动，返回结束的会话数
+func (s *AnalyticsService) CloseStaleSessions(timeout time.Duration) (int, error) {
+	closed := 0
+	for {
+		sessions, err := s.SessionRepo.FindStale(time.Now().Add(-timeout), 200)
+		if err != nil {
+			return closed, err
+		}
+		if len(sessions) == 0 {
+			return closed, nil
+		}
+		for i := range sessions {
+			session := &sessions[i]
+			endTime, err := s.staleSessionEndTime(session)
+			if err != nil {
+				return closed, err
+			}
+			session.EndTime = &endTime
+			session.Duration = int(endTime.Sub(session.StartTime.Time).Minutes())
+			session.AutoClosed = true
+			if err := s.SessionRepo.Update(session); err != nil {
+				return closed, err
+			}
+			closed++
+		}
+	}
+}
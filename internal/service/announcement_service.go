@@ -0,0 +1,165 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
+	"fmt"
+	"strings"
+)
+
+type AnnouncementService struct {
+	Repo     *repository.AnnouncementRepository
+	UserRepo *repository.UserRepository
+	Hub      *ChatHub
+	Events   *EventBus
+}
+
+func NewAnnouncementService(repo *repository.AnnouncementRepository, userRepo *repository.UserRepository, hub *ChatHub, events *EventBus) *AnnouncementService {
+	return &AnnouncementService{Repo: repo, UserRepo: userRepo, Hub: hub, Events: events}
+}
+
+// CreateAnnouncementInput 发布公告参数
+type CreateAnnouncementInput struct {
+	Title       string
+	Content     string
+	TargetScope string // all/specific，为空时按 all 处理
+	StudentIDs  []uint // TargetScope 为 specific 时的目标学生
+}
+
+// AnnouncementList 学生端公告列表
+type AnnouncementList struct {
+	Items       []repository.AnnouncementItem `json:"items"`
+	Total       int64                         `json:"total"`
+	UnreadCount int64                         `json:"unreadCount"`
+}
+
+// CreateAnnouncement 发布公告：按目标范围展开接收人并写入已读追踪记录，
+// 随后通过 WebSocket 向在线的接收人推送 ANNOUNCEMENT 消息
+func (s *AnnouncementService) CreateAnnouncement(teacherID uint, in CreateAnnouncementInput) (*model.Announcement, error) {
+	title := strings.TrimSpace(in.Title)
+	content := strings.TrimSpace(in.Content)
+	if title == "" {
+		return nil, util.ErrTitleRequired
+	}
+	if content == "" {
+		return nil, util.ErrContentRequired
+	}
+
+	scope := in.TargetScope
+	if scope == "" {
+		scope = model.AnnouncementScopeAll
+	}
+
+	var recipients []uint
+	switch scope {
+	case model.AnnouncementScopeAll:
+		ids, err := s.Repo.ActiveStudentIDs()
+		if err != nil {
+			return nil, err
+		}
+		recipients = ids
+	case model.AnnouncementScopeSpecific:
+		ids, err := s.validateStudents(in.StudentIDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("%w: studentIds required when targetScope is 'specific'", util.ErrInvalidRequest)
+		}
+		recipients = ids
+	default:
+		return nil, fmt.Errorf("%w: targetScope must be 'all' or 'specific'", util.ErrInvalidRequest)
+	}
+
+	announcement := &model.Announcement{
+		TeacherID:   teacherID,
+		Title:       title,
+		Content:     content,
+		TargetScope: scope,
+	}
+	if err := s.Repo.CreateWithRecipients(announcement, recipients); err != nil {
+		return nil, err
+	}
+	if teacher, err := s.UserRepo.FindByID(teacherID); err == nil {
+		announcement.Teacher = *teacher
+	}
+
+	// 没有接收人时不推送，PushToUsers 传空列表会变成全服广播
+	if s.Hub != nil && len(recipients) > 0 {
+		s.Hub.PushToUsers(recipients, WSMessage{
+			Type: "ANNOUNCEMENT",
+			Data: map[string]interface{}{
+				"id":          announcement.ID,
+				"title":       announcement.Title,
+				"content":     announcement.Content,
+				"teacherId":   teacherID,
+				"teacherName": announcement.Teacher.Name,
+				"createdAt":   announcement.CreatedAt,
+			},
+		})
+	}
+	if len(recipients) > 0 {
+		s.Events.Publish(EventAnnouncementPublished, AnnouncementPublishedEvent{
+			AnnouncementID: announcement.ID,
+			Title:          announcement.Title,
+			TeacherName:    announcement.Teacher.Name,
+			UserIDs:        recipients,
+		})
+	}
+	return announcement, nil
+}
+
+// validateStudents 去重并确认目标ID均为学生账号
+func (s *AnnouncementService) validateStudents(ids []uint) ([]uint, error) {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return unique, nil
+	}
+
+	var students []uint
+	if err := s.Repo.DB.Model(&model.User{}).
+		Where("id IN ? AND role = ?", unique, model.Student).
+		Pluck("id", &students).Error; err != nil {
+		return nil, err
+	}
+	if len(students) != len(unique) {
+		valid := make(map[uint]bool, len(students))
+		for _, id := range students {
+			valid[id] = true
+		}
+		var invalid []uint
+		for _, id := range unique {
+			if !valid[id] {
+				invalid = append(invalid, id)
+			}
+		}
+		return nil, fmt.Errorf("%w: studentIds contains users that do not exist or are not students: %v", util.ErrInvalidRequest, invalid)
+	}
+	return unique, nil
+}
+
+// ListForUser 获取用户收到的公告及未读数
+func (s *AnnouncementService) ListForUser(userID uint, unreadOnly bool, limit, offset int) (*AnnouncementList, error) {
+	items, total, err := s.Repo.ListForUser(userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.Repo.CountUnread(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &AnnouncementList{Items: items, Total: total, UnreadCount: unread}, nil
+}
+
+// MarkRead 标记公告已读，ids 为空时标记全部
+func (s *AnnouncementService) MarkRead(userID uint, ids []uint) (int64, error) {
+	return s.Repo.MarkRead(userID, ids)
+}
@@ -3,7 +3,14 @@ package service
 import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
 	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
 )
 
 type AssessmentService struct {
@@ -87,19 +94,35 @@ type StudentAssessmentQuestion struct {
 	Options      json.RawMessage `json:"options"`
 	Points       int             `json:"points"`
 	Order        int             `json:"order"`
+	// 选项乱序时，第 i 个显示选项在原选项中的下标。此时按显示顺序以 A、B、C… 作答，提交时由服务端还原
+	OptionOrder []int `json:"optionOrder,omitempty"`
 }
 
-func (s *AssessmentService) ListStudentQuestions() ([]StudentAssessmentQuestion, error) {
+// ListStudentQuestions 获取学生作答用的题目（不含答案），评估开启乱序时按学生固定的顺序返回
+func (s *AssessmentService) ListStudentQuestions(userID uint) ([]StudentAssessmentQuestion, error) {
 	defaultA, err := s.getOrCreateDefaultAssessment()
 	if err != nil {
 		return nil, err
 	}
 
+	existing, _ := s.Repo.FindSubmissionByUserAndAssessment(userID, defaultA.ID)
+	if err := checkAssessmentAttempt(defaultA, existing, time.Now()); err != nil {
+		return nil, err
+	}
+
 	qs, err := s.Repo.ListAllQuestions(defaultA.ID)
 	if err != nil {
 		return nil, err
 	}
 
+	var seed int64
+	if defaultA.ShuffleQuestions || defaultA.ShuffleOptions {
+		seed = s.shuffleSeed(defaultA.ID, userID)
+	}
+	if defaultA.ShuffleQuestions {
+		shuffleAssessmentQuestions(qs, seed)
+	}
+
 	res := make([]StudentAssessmentQuestion, len(qs))
 	for i, q := range qs {
 		res[i] = StudentAssessmentQuestion{
@@ -111,10 +134,81 @@ func (s *AssessmentService) ListStudentQuestions() ([]StudentAssessmentQuestion,
 			Points:       q.Points,
 			Order:        q.Order,
 		}
+		if defaultA.ShuffleOptions {
+			if options, order, ok := shuffleQuestionOptions(q, seed); ok {
+				res[i].Options = options
+				res[i].OptionOrder = order
+			}
+		}
 	}
 	return res, nil
 }
 
+// shuffleSeed 获取学生在评估中的乱序种子：首次由 (assessmentID, userID) 派生并保存，之后直接读取，
+// 保证重新获取题目和提交时使用同一顺序
+func (s *AssessmentService) shuffleSeed(assessmentID, userID uint) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d:%d", assessmentID, userID)
+	derived := int64(h.Sum64())
+
+	seed, err := s.Repo.FindOrCreateShuffleSeed(assessmentID, userID, derived)
+	if err != nil {
+		return derived
+	}
+	return seed
+}
+
+// shuffleAssessmentQuestions 按种子打乱题目顺序，先按ID排序保证相同种子得到相同结果
+func shuffleAssessmentQuestions(qs []model.AssessmentQuestion, seed int64) {
+	sort.Slice(qs, func(i, j int) bool { return qs[i].ID < qs[j].ID })
+	rand.New(rand.NewSource(seed)).Shuffle(len(qs), func(i, j int) { qs[i], qs[j] = qs[j], qs[i] })
+}
+
+// optionOrder 计算选择题选项的乱序排列，不适用时 ok 为 false
+func optionOrder(q model.AssessmentQuestion, seed int64) (options []json.RawMessage, order []int, ok bool) {
+	if q.QuestionType != "single_choice" && q.QuestionType != "multiple_choice" {
+		return nil, nil, false
+	}
+	if err := json.Unmarshal(q.Options, &options); err != nil || len(options) < 2 {
+		return nil, nil, false
+	}
+	order = make([]int, len(options))
+	for i := range order {
+		order[i] = i
+	}
+	// 每道题使用独立的随机序列，避免题目增删影响其它题的选项顺序
+	rand.New(rand.NewSource(seed^int64(q.ID))).Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	return options, order, true
+}
+
+// shuffleQuestionOptions 返回乱序后的选项 JSON 及显示位置到原下标的映射
+func shuffleQuestionOptions(q model.AssessmentQuestion, seed int64) (json.RawMessage, []int, bool) {
+	options, order, ok := optionOrder(q, seed)
+	if !ok {
+		return nil, nil, false
+	}
+	shuffled := make([]json.RawMessage, len(order))
+	for i, idx := range order {
+		shuffled[i] = options[idx]
+	}
+	data, err := json.Marshal(shuffled)
+	if err != nil {
+		return nil, nil, false
+	}
+	return data, order, true
+}
+
+// restoreChoiceAnswer 将按显示顺序作答的选项字母还原为原选项字母，如显示的 "A" 对应原来的第 3 项则还原为 "C"
+func restoreChoiceAnswer(answer string, order []int) string {
+	tokens := choiceTokens(answer)
+	for i, t := range tokens {
+		if len(t) == 1 && t[0] >= 'A' && int(t[0]-'A') < len(order) {
+			tokens[i] = string(rune('A' + order[t[0]-'A']))
+		}
+	}
+	return strings.Join(tokens, ",")
+}
+
 func (s *AssessmentService) GetQuestion(id uint) (*model.AssessmentQuestion, error) {
 	return s.Repo.FindQuestionByID(id)
 }
@@ -149,16 +243,24 @@ func (s *AssessmentService) DeleteQuestion(id uint) error {
 }
 
 type AssessmentRequest struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description"`
-	TimeLimit   int    `json:"timeLimit"`
+	Title            string          `json:"title" binding:"required"`
+	Description      string          `json:"description"`
+	TimeLimit        int             `json:"timeLimit"`
+	ShuffleQuestions bool            `json:"shuffleQuestions"`                    // 按学生打乱题目顺序
+	ShuffleOptions   bool            `json:"shuffleOptions"`                      // 按学生打乱选择题选项顺序
+	MaxAttempts      int             `json:"maxAttempts" binding:"min=0"`         // 最多作答次数（含首次），0 不限制
+	RetestDeadline   *model.JSONTime `json:"retestDeadline" swaggertype:"string"` // 重测截止时间，为空不限制
 }
 
 func (s *AssessmentService) CreateAssessment(req AssessmentRequest) (*model.Assessment, error) {
 	a := &model.Assessment{
-		Title:       req.Title,
-		Description: req.Description,
-		TimeLimit:   req.TimeLimit,
+		Title:            req.Title,
+		Description:      req.Description,
+		TimeLimit:        req.TimeLimit,
+		ShuffleQuestions: req.ShuffleQuestions,
+		ShuffleOptions:   req.ShuffleOptions,
+		MaxAttempts:      req.MaxAttempts,
+		RetestDeadline:   normalizeDeadline(req.RetestDeadline),
 	}
 	if err := s.Repo.CreateAssessment(a); err != nil {
 		return nil, err
@@ -166,6 +268,24 @@ func (s *AssessmentService) CreateAssessment(req AssessmentRequest) (*model.Asse
 	return a, nil
 }
 
+func (s *AssessmentService) UpdateAssessment(id uint, req AssessmentRequest) (*model.Assessment, error) {
+	a, err := s.Repo.FindAssessmentByID(id)
+	if err != nil {
+		return nil, err
+	}
+	a.Title = req.Title
+	a.Description = req.Description
+	a.TimeLimit = req.TimeLimit
+	a.ShuffleQuestions = req.ShuffleQuestions
+	a.ShuffleOptions = req.ShuffleOptions
+	a.MaxAttempts = req.MaxAttempts
+	a.RetestDeadline = normalizeDeadline(req.RetestDeadline)
+	if err := s.Repo.UpdateAssessment(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
 func (s *AssessmentService) ListAssessments(page, limit int) ([]model.Assessment, int64, error) {
 	return s.Repo.ListAssessments(page, limit)
 }
@@ -188,6 +308,15 @@ func (s *AssessmentService) SubmitAssessment(userID uint, req AssessmentSubmissi
 		req.AssessmentID = defaultA.ID
 	}
 
+	assessment, err := s.Repo.FindAssessmentByID(req.AssessmentID)
+	if err != nil {
+		return nil, err
+	}
+	existing, _ := s.Repo.FindSubmissionByUserAndAssessment(userID, req.AssessmentID)
+	if err := checkAssessmentAttempt(assessment, existing, time.Now()); err != nil {
+		return nil, err
+	}
+
 	questions, err := s.Repo.ListAllQuestions(req.AssessmentID)
 	if err != nil {
 		return nil, err
@@ -198,21 +327,42 @@ func (s *AssessmentService) SubmitAssessment(userID uint, req AssessmentSubmissi
 		questionMap[q.ID] = q
 	}
 
+	// 选项乱序时学生按显示顺序作答，先还原为原选项再判分和保存
+	var seed int64
+	shuffleOptions := assessment.ShuffleOptions
+	if shuffleOptions {
+		seed = s.shuffleSeed(req.AssessmentID, userID)
+	}
+
 	totalScore := 0
-	for _, ans := range req.Answers {
-		if q, ok := questionMap[ans.QuestionID]; ok {
-			if ans.Answer == q.Answer {
-				totalScore += q.Points
+	for i, ans := range req.Answers {
+		req.Answers[i].TimeSeconds = clampQuestionTime(ans.TimeSeconds)
+		ans.TimeSeconds = req.Answers[i].TimeSeconds
+		q, ok := questionMap[ans.QuestionID]
+		if !ok {
+			continue
+		}
+		if shuffleOptions {
+			if _, order, ok := optionOrder(q, seed); ok {
+				ans.Answer = restoreChoiceAnswer(ans.Answer, order)
+				req.Answers[i] = ans
 			}
 		}
+
+		correct := ans.Answer == q.Answer
+		if q.QuestionType == "multiple_choice" {
+			correct = sameChoiceSet(ans.Answer, q.Answer)
+		}
+		if correct {
+			totalScore += q.Points
+		}
 	}
 
 	answersJSON, _ := json.Marshal(req.Answers)
 
-	// 查找是否已存在提交记录
-	existing, err := s.Repo.FindSubmissionByUserAndAssessment(userID, req.AssessmentID)
-	if err == nil && existing != nil {
-		// 如果存在，则覆盖旧数据
+	// 如果已存在提交记录，则覆盖旧数据
+	if existing != nil {
+		existing.AttemptCount = attemptsUsed(existing) + 1
 		existing.Answers = answersJSON
 		existing.TotalScore = totalScore
 		existing.Status = "pending"   // 重新设为待审核
@@ -232,6 +382,7 @@ func (s *AssessmentService) SubmitAssessment(userID uint, req AssessmentSubmissi
 		Answers:      answersJSON,
 		TotalScore:   totalScore,
 		Status:       "pending",
+		AttemptCount: 1,
 	}
 
 	if err := s.Repo.CreateSubmission(submission); err != nil {
@@ -260,8 +411,50 @@ func (s *AssessmentService) GetUserAssessmentStatus(userID uint) (bool, error) {
 }
 
 type SubmissionDetailResponse struct {
-	Submission *model.AssessmentSubmission `json:"submission"`
-	Questions  []model.AssessmentQuestion  `json:"questions"`
+	Submission    *model.AssessmentSubmission `json:"submission"`
+	Questions     []model.AssessmentQuestion  `json:"questions"`
+	QuestionTimes []AssessmentQuestionTime    `json:"questionTimes"` // 所有学生每题的平均作答耗时
+}
+
+// AssessmentQuestionTime 单题平均作答耗时
+type AssessmentQuestionTime struct {
+	QuestionID uint    `json:"questionId"`
+	AvgSeconds float64 `json:"avgSeconds"`
+	Samples    int     `json:"samples"` // 参与统计的作答数（仅统计上报了耗时的作答）
+}
+
+// averageQuestionTimes 汇总评估所有提交中每题的平均作答耗时，答案以 JSON 保存，需在内存中统计
+func (s *AssessmentService) averageQuestionTimes(assessmentID uint) ([]AssessmentQuestionTime, error) {
+	answerSets, err := s.Repo.ListSubmissionAnswers(assessmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[uint]int)
+	samples := make(map[uint]int)
+	for _, raw := range answerSets {
+		var answers []model.QuestionAnswer
+		if json.Unmarshal([]byte(raw), &answers) != nil {
+			continue
+		}
+		for _, a := range answers {
+			if a.TimeSeconds > 0 {
+				totals[a.QuestionID] += a.TimeSeconds
+				samples[a.QuestionID]++
+			}
+		}
+	}
+
+	stats := make([]AssessmentQuestionTime, 0, len(totals))
+	for id, total := range totals {
+		stats = append(stats, AssessmentQuestionTime{
+			QuestionID: id,
+			AvgSeconds: float64(total) / float64(samples[id]),
+			Samples:    samples[id],
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].QuestionID < stats[j].QuestionID })
+	return stats, nil
 }
 
 func (s *AssessmentService) GetSubmissionDetail(id uint) (*SubmissionDetailResponse, error) {
@@ -275,9 +468,15 @@ func (s *AssessmentService) GetSubmissionDetail(id uint) (*SubmissionDetailRespo
 		return nil, err
 	}
 
+	questionTimes, err := s.averageQuestionTimes(submission.AssessmentID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &SubmissionDetailResponse{
-		Submission: submission,
-		Questions:  questions,
+		Submission:    submission,
+		Questions:     questions,
+		QuestionTimes: questionTimes,
 	}, nil
 }
 
@@ -308,6 +507,41 @@ func (s *AssessmentService) DeleteSubmission(id uint) error {
 type StudentAssessmentStatus struct {
 	Submission        *model.AssessmentSubmission `json:"submission"`
 	CanTakeAssessment bool                        `json:"canTakeAssessment"`
+	AttemptsUsed      int                         `json:"attemptsUsed"`
+	MaxAttempts       int                         `json:"maxAttempts"`       // 0 表示不限制
+	AttemptsRemaining *int                        `json:"attemptsRemaining"` // 不限次数时为 null
+	RetestDeadline    *model.JSONTime             `json:"retestDeadline,omitempty"`
+}
+
+// attemptsUsed 已作答次数，兼容未记录次数的历史提交
+func attemptsUsed(submission *model.AssessmentSubmission) int {
+	if submission == nil {
+		return 0
+	}
+	if submission.AttemptCount < 1 {
+		return 1
+	}
+	return submission.AttemptCount
+}
+
+// checkAssessmentAttempt 校验作答次数上限和重测截止时间，首次作答不受截止时间限制
+func checkAssessmentAttempt(a *model.Assessment, submission *model.AssessmentSubmission, now time.Time) error {
+	used := attemptsUsed(submission)
+	if a.MaxAttempts > 0 && used >= a.MaxAttempts {
+		return fmt.Errorf("%w（%d 次）", util.ErrAssessmentAttemptsUsed, a.MaxAttempts)
+	}
+	if used > 0 && a.RetestDeadline != nil && now.After(a.RetestDeadline.Time) {
+		return fmt.Errorf("%w（截止时间 %s）", util.ErrRetestDeadlinePassed, a.RetestDeadline.Format(model.TimeFormat))
+	}
+	return nil
+}
+
+// normalizeDeadline 将零值时间视为未设置
+func normalizeDeadline(t *model.JSONTime) *model.JSONTime {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return t
 }
 
 func (s *AssessmentService) GetStudentAssessmentStatus(userID uint) (*StudentAssessmentStatus, error) {
@@ -326,10 +560,25 @@ func (s *AssessmentService) GetStudentAssessmentStatus(userID uint) (*StudentAss
 	// 获取提交记录
 	submission, _ := s.Repo.FindSubmissionByUserAndAssessment(userID, defaultA.ID)
 
-	return &StudentAssessmentStatus{
+	status := &StudentAssessmentStatus{
 		Submission:        submission,
 		CanTakeAssessment: canTake,
-	}, nil
+		AttemptsUsed:      attemptsUsed(submission),
+		MaxAttempts:       defaultA.MaxAttempts,
+		RetestDeadline:    defaultA.RetestDeadline,
+	}
+	if defaultA.MaxAttempts > 0 {
+		remaining := defaultA.MaxAttempts - status.AttemptsUsed
+		if remaining < 0 {
+			remaining = 0
+		}
+		status.AttemptsRemaining = &remaining
+	}
+	// 次数用尽或重测已截止时，即使老师授予了重测权限也无法作答
+	if canTake && checkAssessmentAttempt(defaultA, submission, time.Now()) != nil {
+		status.CanTakeAssessment = false
+	}
+	return status, nil
 }
 
 type StudentAssessmentResult struct {
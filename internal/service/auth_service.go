@@ -13,14 +13,16 @@ import (
 )
 
 type AuthService struct {
-	UserRepo *repository.UserRepository
-	Cfg      *config.Config
+	UserRepo  *repository.UserRepository
+	Cfg       *config.Config
+	Passwords *PasswordPolicy
 }
 
-func NewAuthService(userRepo *repository.UserRepository, cfg *config.Config) *AuthService {
+func NewAuthService(userRepo *repository.UserRepository, cfg *config.Config, passwords *PasswordPolicy) *AuthService {
 	return &AuthService{
-		UserRepo: userRepo,
-		Cfg:      cfg,
+		UserRepo:  userRepo,
+		Cfg:       cfg,
+		Passwords: passwords,
 	}
 }
 
@@ -32,6 +34,10 @@ func (s *AuthService) Register(user *model.User) error {
 		return err
 	}
 
+	if err := s.Passwords.Validate(user.Password); err != nil {
+		return err
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return err
@@ -46,14 +52,15 @@ func (s *AuthService) Login(email, password string) (string, error) {
 		return "", errors.New("invalid credentials")
 	}
 
-	if user.Disabled {
-		return "", errors.New("account disabled")
-	}
-
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
 		return "", errors.New("invalid credentials")
 	}
 
+	// 密码正确后再提示禁用，避免未知密码的人探测账号状态
+	if user.Disabled {
+		return "", util.ErrAccountDisabled
+	}
+
 	// 更新最后登录时间
 	_ = s.UserRepo.UpdateLastLogin(user.ID)
 	_ = s.UserRepo.UpdateLastSeen(user.ID)
@@ -3,6 +3,8 @@ package service
 import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
+	"errors"
 	"fmt"
 	"math/rand"
 	"time"
@@ -95,49 +97,35 @@ func (s *CProgrammingResourceService) GetResources(page, limit int, enabled *boo
 	return s.Repo.FindAll(page, limit, "", enabled, "order", "asc")
 }
 
-// GetResourcesWithStats 获取所有C语言资源分类模块（带统计信息），支持分页、筛选、搜索和排序
-func (s *CProgrammingResourceService) GetResourcesWithStats(page, limit int, search string, enabled *bool, sortBy, sortOrder string) (map[string]interface{}, error) {
-	// 获取资源列表
-	resources, total, err := s.Repo.FindAll(page, limit, search, enabled, sortBy, sortOrder)
+// ResourceStatsFilter 管理端资源模块列表的筛选条件
+type ResourceStatsFilter = repository.ResourceStatsFilter
+
+// GetResourcesWithStats 获取所有C语言资源分类模块（带统计信息），支持分页、筛选、搜索和排序，
+// 可按是否有视频/练习题及内容总数筛选，方便发布前找出内容不完整的模块
+func (s *CProgrammingResourceService) GetResourcesWithStats(page, limit int, filter ResourceStatsFilter) (map[string]interface{}, error) {
+	// 获取资源列表及统计
+	resources, total, err := s.Repo.FindAllWithStats(page, limit, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	// 为每个资源添加统计信息
 	resourcesWithStats := make([]map[string]interface{}, 0, len(resources))
 	for _, resource := range resources {
-		resourceMap := map[string]interface{}{
-			"id":          resource.ID,
-			"name":        resource.Name,
-			"iconURL":     resource.IconURL,
-			"description": resource.Description,
-			"enabled":     resource.Enabled,
-			"order":       resource.Order,
-			"createdAt":   resource.CreatedAt,
-			"updatedAt":   resource.UpdatedAt,
-		}
-
-		// 获取视频数量
-		videos, videoCount, _ := s.GetVideosByResourceID(resource.ID, 1, 1)
-		if len(videos) > 0 || videoCount > 0 {
-			resourceMap["videoCount"] = videoCount
-		} else {
-			resourceMap["videoCount"] = 0
-		}
-
-		// 获取文章数量
-		articles, articleCount, _ := s.GetArticlesByResourceID(resource.ID, 1, 1)
-		if len(articles) > 0 || articleCount > 0 {
-			resourceMap["articleCount"] = articleCount
-		} else {
-			resourceMap["articleCount"] = 0
-		}
-
-		// 获取练习题分类数量
-		categories, _ := s.GetCategoriesByResourceID(resource.ID)
-		resourceMap["exerciseCategoryCount"] = len(categories)
-
-		resourcesWithStats = append(resourcesWithStats, resourceMap)
+		resourcesWithStats = append(resourcesWithStats, map[string]interface{}{
+			"id":                    resource.ID,
+			"name":                  resource.Name,
+			"iconURL":               resource.IconURL,
+			"description":           resource.Description,
+			"enabled":               resource.Enabled,
+			"order":                 resource.Order,
+			"createdAt":             resource.CreatedAt,
+			"updatedAt":             resource.UpdatedAt,
+			"videoCount":            resource.VideoCount,
+			"articleCount":          resource.ArticleCount,
+			"exerciseCategoryCount": resource.ExerciseCategoryCount,
+			"questionCount":         resource.QuestionCount,
+			"itemCount":             resource.ItemCount(),
+		})
 	}
 
 	// 计算分页信息
@@ -151,9 +139,21 @@ func (s *CProgrammingResourceService) GetResourcesWithStats(page, limit int, sea
 		"hasPrev":     page > 1,
 	}
 
+	// 返回实际生效的筛选条件，便于前端回显
+	filters := map[string]interface{}{
+		"search":       filter.Search,
+		"enabled":      filter.Enabled,
+		"hasVideos":    filter.HasVideos,
+		"hasQuestions": filter.HasQuestions,
+		"minItems":     filter.MinItems,
+		"sortBy":       filter.SortBy,
+		"sortOrder":    filter.SortOrder,
+	}
+
 	result := map[string]interface{}{
 		"resources":  resourcesWithStats,
 		"pagination": pagination,
+		"filters":    filters,
 	}
 
 	return result, nil
@@ -176,12 +176,24 @@ func (s *CProgrammingResourceService) GetCategoriesByResourceID(resourceID uint)
 
 // CreateQuestion 创建新的练习题题目
 func (s *CProgrammingResourceService) CreateQuestion(question *model.ExerciseQuestion) error {
+	// 建议难度只由校准任务写入
+	question.SuggestedDifficulty = ""
+	question.SubmissionCount = 0
+	question.CorrectRate = 0
+	question.DifficultyCalibratedAt = nil
 	return s.QuestionRepo.Create(question)
 }
 
-// GetQuestionsByCategoryID 根据分类ID获取练习题题目，支持分页
-func (s *CProgrammingResourceService) GetQuestionsByCategoryID(categoryID uint, page, limit int) ([]model.ExerciseQuestion, int, error) {
-	return s.QuestionRepo.FindByCategoryID(categoryID, page, limit)
+// GetQuestionsByCategoryID 分页获取分类下的题目，按查看者身份和答案公开策略处理答案字段
+func (s *CProgrammingResourceService) GetQuestionsByCategoryID(categoryID uint, page, limit int, userID uint, role model.UserRole) ([]model.ExerciseQuestion, int, error) {
+	questions, total, err := s.QuestionRepo.FindByCategoryID(categoryID, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.applyAnswerReveal(questions, userID, role); err != nil {
+		return nil, 0, err
+	}
+	return questions, total, nil
 }
 
 // GetVideosByResourceID 根据资源ID获取视频列表，支持分页
@@ -273,6 +285,67 @@ func (s *CProgrammingResourceService) UpdateQuestion(question *model.ExerciseQue
 	return s.QuestionRepo.UpdateQuestion(question)
 }
 
+// 建议难度的正确率阈值
+const (
+	hardCorrectRateBelow = 0.4
+	easyCorrectRateAbove = 0.8
+)
+
+// suggestDifficulty 根据正确率给出建议难度
+func suggestDifficulty(rate float64) string {
+	switch {
+	case rate < hardCorrectRateBelow:
+		return "hard"
+	case rate > easyCorrectRateAbove:
+		return "easy"
+	default:
+		return "medium"
+	}
+}
+
+// CalibrateDifficulty 按提交正确率重新计算所有题目的建议难度，提交人数少于 minSamples 的题目不给出建议。
+// 只写入 SuggestedDifficulty 等统计字段，老师设置的 Difficulty 保持不变，返回给出建议的题目数
+func (s *CProgrammingResourceService) CalibrateDifficulty(minSamples int) (int, error) {
+	stats, err := s.SubmissionRepo.QuestionStats()
+	if err != nil {
+		return 0, err
+	}
+
+	now := model.NewJSONTime(time.Now())
+	suggested := 0
+	for _, st := range stats {
+		var rate float64
+		if st.Total > 0 {
+			rate = float64(st.Correct) / float64(st.Total)
+		}
+		difficulty := ""
+		if st.Total >= minSamples {
+			difficulty = suggestDifficulty(rate)
+			suggested++
+		}
+		if err := s.QuestionRepo.UpdateDifficultyStats(st.QuestionID, difficulty, st.Total, rate, now); err != nil {
+			return suggested, err
+		}
+	}
+	return suggested, nil
+}
+
+// AcceptSuggestedDifficulty 将题目难度设为建议难度
+func (s *CProgrammingResourceService) AcceptSuggestedDifficulty(id uint) (*model.ExerciseQuestion, error) {
+	question, err := s.QuestionRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if question.SuggestedDifficulty == "" {
+		return nil, util.ErrNoSuggestedDifficulty
+	}
+	if err := s.QuestionRepo.UpdateFields(id, map[string]interface{}{"difficulty": question.SuggestedDifficulty}); err != nil {
+		return nil, err
+	}
+	question.Difficulty = question.SuggestedDifficulty
+	return question, nil
+}
+
 // GetAllQuestionsByCategoryID 获取指定分类下的所有练习题题目
 func (s *CProgrammingResourceService) GetAllQuestionsByCategoryID(categoryID uint) ([]model.ExerciseQuestion, int, error) {
 	questions, err := s.QuestionRepo.FindAllByCategoryID(categoryID)
@@ -283,7 +356,7 @@ func (s *CProgrammingResourceService) GetAllQuestionsByCategoryID(categoryID uin
 }
 
 // GetResourcesWithAllContent 获取所有资源分类及其完整内容（支持分页）
-func (s *CProgrammingResourceService) GetResourcesWithAllContent(enabled *bool, page, limit int, userID uint) ([]map[string]interface{}, int, error) {
+func (s *CProgrammingResourceService) GetResourcesWithAllContent(enabled *bool, page, limit int, userID uint, role model.UserRole) ([]map[string]interface{}, int, error) {
 	// 获取分页的资源分类
 	resources, total, err := s.Repo.FindAll(page, limit, "", enabled, "order", "asc")
 	if err != nil {
@@ -367,6 +440,9 @@ func (s *CProgrammingResourceService) GetResourcesWithAllContent(enabled *bool,
 
 			// 获取当前分类下的所有题目
 			questions, _, _ := s.GetAllQuestionsByCategoryID(category.ID)
+			if err := s.applyAnswerReveal(questions, userID, role); err != nil {
+				return nil, 0, err
+			}
 			categoryMap["questions"] = questions
 
 			categoriesWithQuestions = append(categoriesWithQuestions, categoryMap)
@@ -385,28 +461,32 @@ type QuestionWithUserStatus struct {
 	IsSubmitted bool `json:"isSubmitted"`
 }
 
-func (s *CProgrammingResourceService) GetQuestionsByCategoryIDWithUserStatus(categoryID, userID uint, page, limit int) ([]QuestionWithUserStatus, int, error) {
+// viewerRole 为发起请求的用户角色，答案是否公开按 userID 的提交情况判断
+func (s *CProgrammingResourceService) GetQuestionsByCategoryIDWithUserStatus(categoryID, userID uint, viewerRole model.UserRole, page, limit int) ([]QuestionWithUserStatus, int, error) {
 	// 获取题目列表
 	questions, total, err := s.QuestionRepo.FindQuestionsByCategoryIDWithPagination(categoryID, page, limit)
 	if err != nil {
 		return nil, 0, err
 	}
+	if err := s.applyAnswerReveal(questions, userID, viewerRole); err != nil {
+		return nil, 0, err
+	}
+
+	// 一次查出用户在本页题目中答对的题目，为每个题目添加提交状态
+	questionIDs := make([]uint, len(questions))
+	for i, question := range questions {
+		questionIDs[i] = question.ID
+	}
+	correct, err := s.SubmissionRepo.FindCorrectQuestionIDs(userID, questionIDs)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	// 为每个题目添加用户提交状态
 	questionsWithStatus := make([]QuestionWithUserStatus, 0, len(questions))
 	for _, question := range questions {
-		isSubmitted := false
-		// 检查用户是否提交过该题目
-		if userID > 0 {
-			submission, err := s.SubmissionRepo.FindByUserAndQuestion(userID, question.ID)
-			if err == nil && submission.IsCorrect {
-				isSubmitted = true
-			}
-		}
-
 		questionsWithStatus = append(questionsWithStatus, QuestionWithUserStatus{
 			ExerciseQuestion: question,
-			IsSubmitted:      isSubmitted,
+			IsSubmitted:      correct[question.ID],
 		})
 	}
 
@@ -519,188 +599,208 @@ func (s *CProgrammingResourceService) CheckUserSubmittedQuestion(userID, questio
 	return submission.IsCorrect, nil
 }
 
-//获取带进度的资源模块
-
-func (s *CProgrammingResourceService) GetResourceModuleWithProgress(resourceID, userID uint) (*ResourceModuleWithProgress, error) {
+// GetResourceModuleWithProgress 获取带进度的资源模块
+func (s *CProgrammingResourceService) GetResourceModuleWithProgress(resourceID, userID uint, role model.UserRole) (*ResourceModuleWithProgress, error) {
 	// 获取资源模块信息
 	resource, err := s.Repo.FindByID(resourceID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 构建结果对象
-	result := &ResourceModuleWithProgress{
-		CProgrammingResource: *resource,
-		Videos:               []ResourceWithCompletionStatus{},
-		Articles:             []ResourceWithCompletionStatus{},
-		ExerciseCategory:     []ExerciseCategoryWithQuestions{},
+	modules, err := s.buildModulesWithProgress([]model.CProgrammingResource{*resource}, userID, role)
+	if err != nil {
+		return nil, err
 	}
+	return modules[0], nil
+}
 
-	// 计算总进度
-	totalItems := 0
-	completedItems := 0
-
-	// 获取并添加视频状态
-	videos, err := s.GetAllVideosByResourceID(resourceID)
-	if err == nil {
-		videoIDs := make([]uint, len(videos))
-		for i, video := range videos {
-			videoIDs[i] = video.ID
-		}
-
-		// 获取视频完成状态
-		videoCompletions, _ := s.ResourceCompletionRepo.GetUserResourceCompletions(userID, videoIDs)
+// buildModulesWithProgress 批量计算一组资源模块的进度。视频文章、完成状态、练习分类、题目和答题记录
+// 各用一次查询取回后在内存中组装，查询次数与模块数量无关
+func (s *CProgrammingResourceService) buildModulesWithProgress(resources []model.CProgrammingResource, userID uint, role model.UserRole) ([]*ResourceModuleWithProgress, error) {
+	result := make([]*ResourceModuleWithProgress, 0, len(resources))
+	if len(resources) == 0 {
+		return result, nil
+	}
 
-		// 添加带状态的视频
-		for _, video := range videos {
-			isCompleted := videoCompletions[video.ID]
-			result.Videos = append(result.Videos, ResourceWithCompletionStatus{
-				Resource:    video,
-				IsCompleted: isCompleted,
-			})
+	moduleIDs := make([]uint, len(resources))
+	for i, resource := range resources {
+		moduleIDs[i] = resource.ID
+	}
 
-			totalItems++
-			if isCompleted {
-				completedItems++
-			}
+	// 获取视频、文章及其完成状态
+	items, err := s.ResourceRepo.FindByModuleIDs(moduleIDs, model.Video, model.Article)
+	if err != nil {
+		return nil, err
+	}
+	itemIDs := make([]uint, len(items))
+	for i, item := range items {
+		itemIDs[i] = item.ID
+	}
+	completions := map[uint]bool{}
+	if len(itemIDs) > 0 {
+		if completions, err = s.ResourceCompletionRepo.GetUserResourceCompletions(userID, itemIDs); err != nil {
+			return nil, err
 		}
 	}
+	itemsByModule := make(map[uint][]model.Resource)
+	for _, item := range items {
+		itemsByModule[item.ModuleID] = append(itemsByModule[item.ModuleID], item)
+	}
 
-	// 获取并添加文章状态
-	articles, err := s.GetAllArticlesByResourceID(resourceID)
-	if err == nil {
-		articleIDs := make([]uint, len(articles))
-		for i, article := range articles {
-			articleIDs[i] = article.ID
+	// 获取练习分类、题目及答题记录
+	categories, err := s.CategoryRepo.FindByResourceIDs(moduleIDs)
+	if err != nil {
+		return nil, err
+	}
+	categoryIDs := make([]uint, len(categories))
+	categoriesByModule := make(map[uint][]model.ExerciseCategory)
+	for i, category := range categories {
+		categoryIDs[i] = category.ID
+		categoriesByModule[category.CProgrammingResID] = append(categoriesByModule[category.CProgrammingResID], category)
+	}
+	questions, err := s.QuestionRepo.FindByCategoryIDs(categoryIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyAnswerReveal(questions, userID, role); err != nil {
+		return nil, err
+	}
+	questionIDs := make([]uint, len(questions))
+	questionsByCategory := make(map[uint][]model.ExerciseQuestion)
+	for i, question := range questions {
+		questionIDs[i] = question.ID
+		questionsByCategory[question.CategoryID] = append(questionsByCategory[question.CategoryID], question)
+	}
+	correct, err := s.SubmissionRepo.FindCorrectQuestionIDs(userID, questionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, resource := range resources {
+		module := &ResourceModuleWithProgress{
+			CProgrammingResource: resource,
+			Videos:               []ResourceWithCompletionStatus{},
+			Articles:             []ResourceWithCompletionStatus{},
+			ExerciseCategory:     []ExerciseCategoryWithQuestions{},
 		}
 
-		// 获取文章完成状态
-		articleCompletions, _ := s.ResourceCompletionRepo.GetUserResourceCompletions(userID, articleIDs)
+		// 计算总进度
+		totalItems := 0
+		completedItems := 0
 
-		// 添加带状态的文章
-		for _, article := range articles {
-			isCompleted := articleCompletions[article.ID]
-			result.Articles = append(result.Articles, ResourceWithCompletionStatus{
-				Resource:    article,
-				IsCompleted: isCompleted,
-			})
+		for _, item := range itemsByModule[resource.ID] {
+			isCompleted := completions[item.ID]
+			withStatus := ResourceWithCompletionStatus{Resource: item, IsCompleted: isCompleted}
+			if item.Type == model.Video {
+				module.Videos = append(module.Videos, withStatus)
+			} else {
+				module.Articles = append(module.Articles, withStatus)
+			}
 
 			totalItems++
 			if isCompleted {
 				completedItems++
 			}
 		}
-	}
-
-	// 获取并添加练习分类和题目状态
-	categories, err := s.GetCategoriesByResourceID(resourceID)
-	if err == nil {
-		for _, category := range categories {
-			// 获取分类下的所有题目
-			questions, _, err := s.QuestionRepo.FindQuestionsByCategoryIDWithPagination(category.ID, 1, 1000) // 获取所有题目
-			if err != nil {
-				continue
-			}
 
+		for _, category := range categoriesByModule[resource.ID] {
+			categoryQuestions := questionsByCategory[category.ID]
 			categoryWithQuestions := ExerciseCategoryWithQuestions{
 				ExerciseCategory: category,
-				Questions:        []QuestionWithUserStatus{},
+				Questions:        make([]QuestionWithUserStatus, 0, len(categoryQuestions)),
 				IsCompleted:      true, // 默认为已完成，如有未完成的题目则设为false
-				Status:           "completed",
 			}
 
-			var categoryCompletedItems = 0
-
-			// 添加题目状态
-			for _, question := range questions {
-				isSubmitted := false
-				if userID > 0 {
-					submission, err := s.SubmissionRepo.FindByUserAndQuestion(userID, question.ID)
-					if err == nil && submission.IsCorrect {
-						isSubmitted = true
-					} else if err != nil || !submission.IsCorrect {
-						// 只有在确定题目未完成时才设置分类未完成
-						categoryWithQuestions.IsCompleted = false
-					}
-				} else {
+			categoryCompletedItems := 0
+			for _, question := range categoryQuestions {
+				isSubmitted := correct[question.ID]
+				if !isSubmitted {
 					categoryWithQuestions.IsCompleted = false
 				}
-
 				categoryWithQuestions.Questions = append(categoryWithQuestions.Questions, QuestionWithUserStatus{
 					ExerciseQuestion: question,
 					IsSubmitted:      isSubmitted,
 				})
 
-				if isSubmitted {
-					categoryCompletedItems++
-				}
-
 				totalItems++
-
 				if isSubmitted {
+					categoryCompletedItems++
 					completedItems++
 				}
 			}
 
-			if len(questions) == 0 {
-				categoryWithQuestions.Status = "not_started"
-			} else if categoryCompletedItems == len(questions) {
-				categoryWithQuestions.Status = "completed"
-			} else if categoryCompletedItems == 0 {
-				categoryWithQuestions.Status = "not_started"
-			} else {
-				categoryWithQuestions.Status = "in_progress"
-			}
-
-			result.ExerciseCategory = append(result.ExerciseCategory, categoryWithQuestions)
+			categoryWithQuestions.Status = progressStatus(categoryCompletedItems, len(categoryQuestions))
+			module.ExerciseCategory = append(module.ExerciseCategory, categoryWithQuestions)
 		}
-	}
 
-	// 计算进度百分比
-	if totalItems > 0 {
-		result.Progress = float64(completedItems) / float64(totalItems) * 100
-	} else {
-		result.Progress = 0
-	}
+		// 计算进度百分比
+		if totalItems > 0 {
+			module.Progress = float64(completedItems) / float64(totalItems) * 100
+		}
 
-	// 判断模块是否完全完成
-	result.IsCompleted = totalItems > 0 && completedItems == totalItems
+		// 判断模块是否完全完成
+		module.IsCompleted = totalItems > 0 && completedItems == totalItems
+		module.Status = progressStatus(completedItems, totalItems)
 
-	// 设置三种状态
-	if totalItems == 0 {
-		result.Status = "not_started" // 没有项目时视为未开始
-	} else if completedItems == totalItems {
-		result.Status = "completed" // 所有项目都已完成
-	} else if completedItems == 0 {
-		result.Status = "not_started" // 有项目但没有完成的，视为未开始
-	} else {
-		result.Status = "in_progress" // 部分完成，视为进行中
+		result = append(result, module)
 	}
 
 	return result, nil
 }
 
+// progressStatus 根据完成数量返回 "completed"、"not_started" 或 "in_progress"，没有项目时视为未开始
+func progressStatus(completed, total int) string {
+	switch {
+	case total == 0 || completed == 0:
+		return "not_started"
+	case completed == total:
+		return "completed"
+	default:
+		return "in_progress"
+	}
+}
+
 // 更新资源完成状态
 func (s *CProgrammingResourceService) UpdateResourceCompletionStatus(userID, resourceID uint, completed bool) error {
+	// 资源必须存在，且所属资源模块对学生可见
+	resource, err := s.ResourceRepo.FindByID(resourceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.ErrResourceNotFound
+		}
+		return err
+	}
+	module, err := s.Repo.FindByID(resource.ModuleID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.ErrResourceNotFound
+		}
+		return err
+	}
+	if !module.Enabled {
+		return util.ErrPermissionDenied
+	}
+
 	return s.ResourceCompletionRepo.UpdateCompletionStatus(userID, resourceID, completed)
 }
 
 // GetUnfinishedResourceModules 获取未完成的资源模块列表（带进度）
-func (s *CProgrammingResourceService) GetUnfinishedResourceModules(userID uint, limit int) ([]*ResourceModuleWithProgress, error) {
+func (s *CProgrammingResourceService) GetUnfinishedResourceModules(userID uint, role model.UserRole, limit int) ([]*ResourceModuleWithProgress, error) {
 	// 1. 获取所有资源模块
 	allResources, _, err := s.GetResources(1, 1000, nil) // 获取所有启用的资源模块
 	if err != nil {
 		return nil, err
 	}
 
+	modules, err := s.buildModulesWithProgress(allResources, userID, role)
+	if err != nil {
+		return nil, err
+	}
+
 	// 2. 筛选出未完成的资源模块
-	var unfinishedModules []*ResourceModuleWithProgress
+	unfinishedModules := make([]*ResourceModuleWithProgress, 0)
 
-	for _, resource := range allResources {
-		module, err := s.GetResourceModuleWithProgress(resource.ID, userID)
-		if err != nil {
-			continue
-		}
+	for _, module := range modules {
 
 		// 检查是否有未完成的内容（视频、文章或练习题）
 		hasUnfinishedVideos := false
@@ -747,23 +847,80 @@ func (s *CProgrammingResourceService) GetUnfinishedResourceModules(userID uint,
 	return unfinishedModules, nil
 }
 
-// GetAllResourceModulesWithProgress 获取所有带进度的资源模块
-func (s *CProgrammingResourceService) GetAllResourceModulesWithProgress(userID uint, enabled *bool) ([]*ResourceModuleWithProgress, error) {
-	// 获取所有资源模块
-	resources, _, err := s.Repo.FindAll(1, 1000, "", enabled, "order", "asc")
+// GetAllResourceModulesWithProgress 分页获取带进度的资源模块，按排序字段升序
+func (s *CProgrammingResourceService) GetAllResourceModulesWithProgress(userID uint, role model.UserRole, enabled *bool, page, limit int) ([]*ResourceModuleWithProgress, int, error) {
+	resources, total, err := s.Repo.FindAll(page, limit, "", enabled, "order", "asc")
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	// 为每个资源模块获取进度信息
-	var result []*ResourceModuleWithProgress
-	for _, resource := range resources {
-		module, err := s.GetResourceModuleWithProgress(resource.ID, userID)
-		if err != nil {
-			continue
+	modules, err := s.buildModulesWithProgress(resources, userID, role)
+	if err != nil {
+		return nil, 0, err
+	}
+	return modules, total, nil
+}
+
+// applyAnswerReveal 按答案公开策略处理返回给学生的题目：未满足公开条件的题目清空
+// CorrectAnswer 和 SolutionCode，保证答案不会提前出现在响应中。教师和管理员始终可见
+func (s *CProgrammingResourceService) applyAnswerReveal(questions []model.ExerciseQuestion, userID uint, role model.UserRole) error {
+	if len(questions) == 0 {
+		return nil
+	}
+	if role == model.Teacher || role == model.Admin {
+		for i := range questions {
+			questions[i].AnswerRevealed = true
 		}
-		result = append(result, module)
+		return nil
 	}
 
-	return result, nil
+	categoryIDs := make([]uint, 0)
+	seenCategory := make(map[uint]bool)
+	questionIDs := make([]uint, 0, len(questions))
+	for _, q := range questions {
+		questionIDs = append(questionIDs, q.ID)
+		if q.AnswerReveal == "" && !seenCategory[q.CategoryID] {
+			seenCategory[q.CategoryID] = true
+			categoryIDs = append(categoryIDs, q.CategoryID)
+		}
+	}
+	categories, err := s.CategoryRepo.FindByIDs(categoryIDs)
+	if err != nil {
+		return err
+	}
+	categoryByID := make(map[uint]model.ExerciseCategory, len(categories))
+	for _, c := range categories {
+		categoryByID[c.ID] = c
+	}
+	submitted, err := s.SubmissionRepo.FindSubmittedQuestionIDs(userID, questionIDs)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i := range questions {
+		q := &questions[i]
+		policy, delay := q.AnswerReveal, q.RevealDelayMinutes
+		if policy == "" {
+			if c, ok := categoryByID[q.CategoryID]; ok {
+				policy, delay = c.AnswerReveal, c.RevealDelayMinutes
+			}
+		}
+
+		switch policy {
+		case model.AnswerRevealAfterSubmission:
+			q.AnswerRevealed = submitted[q.ID]
+		case model.AnswerRevealAfterDelay:
+			q.AnswerRevealed = !now.Before(q.CreatedAt.Add(time.Duration(delay) * time.Minute))
+		case model.AnswerRevealNever:
+			q.AnswerRevealed = false
+		default:
+			q.AnswerRevealed = true
+		}
+		if !q.AnswerRevealed {
+			q.CorrectAnswer = ""
+			q.SolutionCode = ""
+		}
+	}
+	return nil
 }
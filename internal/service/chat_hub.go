@@ -1,13 +1,18 @@
 package service
 
 import (
+	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
 	"coder_edu_backend/pkg/logger"
 	"coder_edu_backend/pkg/monitoring"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 
@@ -21,9 +26,18 @@ const (
 	writeWait      = 10 * time.Second
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
-	shardCount     = 32
+	maxMessageSize = 1024            // AUTH_REFRESH 需要携带完整的 JWT
 	onlineTTL      = 2 * time.Minute // 在线状态过期时间
+
+	// CloseTokenExpired 连接使用的 token 过期且未通过 AUTH_REFRESH 续期时的关闭码（4000-4999 为应用自定义区间）
+	CloseTokenExpired = 4001
+	// CloseAccountDisabled 账号被管理员禁用或删除时服务端主动断开的关闭码
+	CloseAccountDisabled = 4003
+
+	// defaultShardCount 默认分片数。
+	// 分片越多，单个分片锁的竞争越小，但遍历全部分片的操作（群推送、心跳续期、统计）开销越大；
+	// 分片过少则在线用户集中时注册/推送会争抢同一把锁。userID 连续分配时取模分布通常较均匀。
+	defaultShardCount = 32
 )
 
 var (
@@ -43,17 +57,32 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// SetOriginChecker 设置 WebSocket 握手的 Origin 校验，应与 CORS 白名单保持一致；需在开始服务前调用
+func SetOriginChecker(check func(r *http.Request) bool) {
+	upgrader.CheckOrigin = check
+}
+
 type WSMessage struct {
 	Type string      `json:"type"`
 	Data interface{} `json:"data"`
 }
 
 type Client struct {
-	Hub     *ChatHub
-	Conn    *websocket.Conn
-	Send    chan []byte
-	UserID  uint
-	Limiter *rate.Limiter // 限流器
+	Hub       *ChatHub
+	Conn      *websocket.Conn
+	Send      chan []byte
+	UserID    uint
+	Limiter   *rate.Limiter // 限流器
+	ExpiresAt time.Time     // 当前 token 的过期时间，零值表示不过期
+	// authRefresh 由 readPump 校验 AUTH_REFRESH 后交给 writePump，续期计时和回复都在 writePump 中完成，保证连接只有一个写者
+	authRefresh chan authRefreshResult
+	// kick 通知 writePump 以指定关闭码断开连接
+	kick chan int
+}
+
+type authRefreshResult struct {
+	expiresAt time.Time
+	err       error
 }
 
 func (c *Client) readPump() {
@@ -92,6 +121,12 @@ func (c *Client) readPump() {
 			go c.Hub.UserRepo.UpdateLastSeen(c.UserID)
 		}
 
+		if wsMsg.Type == "AUTH_REFRESH" {
+			c.handleAuthRefresh(wsMsg.Data)
+			messagePool.Put(wsMsg)
+			continue
+		}
+
 		if wsMsg.Type == "TYPING" {
 			data, ok := wsMsg.Data.(map[string]interface{})
 			if !ok {
@@ -110,6 +145,28 @@ func (c *Client) readPump() {
 	}
 }
 
+// handleAuthRefresh 校验客户端通过 AUTH_REFRESH 发来的新 token，新 token 必须属于当前连接的用户
+func (c *Client) handleAuthRefresh(data interface{}) {
+	var result authRefreshResult
+	payload, _ := data.(map[string]interface{})
+	token, _ := payload["token"].(string)
+	if token == "" {
+		result.err = errors.New("token required")
+	} else if claims, err := util.ParseJWT(token, c.Hub.jwtSecret); err != nil {
+		result.err = errors.New("invalid token")
+	} else if claims.UserID != c.UserID {
+		result.err = errors.New("token belongs to another user")
+	} else if claims.ExpiresAt != nil {
+		result.expiresAt = claims.ExpiresAt.Time
+	}
+
+	// 上一次续期结果尚未处理时丢弃本次，客户端收不到回复会重试
+	select {
+	case c.authRefresh <- result:
+	default:
+	}
+}
+
 // HandleTransientEvent 处理不需要存库的瞬时事件转发
 func (h *ChatHub) HandleTransientEvent(senderID uint, convID string, msg WSMessage) {
 	if data, ok := msg.Data.(map[string]interface{}); ok {
@@ -155,12 +212,42 @@ func (h *ChatHub) HandleTransientEvent(senderID uint, convID string, msg WSMessa
 
 func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
+	// token 过期计时器，过期前未续期则以 CloseTokenExpired 关闭连接
+	expiryTimer := time.NewTimer(time.Until(c.ExpiresAt))
+	if c.ExpiresAt.IsZero() {
+		expiryTimer.Stop()
+	}
 	defer func() {
 		ticker.Stop()
+		expiryTimer.Stop()
 		c.Conn.Close()
 	}()
 	for {
 		select {
+		case <-expiryTimer.C:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(CloseTokenExpired, "token expired"))
+			return
+		case code := <-c.kick:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, "account disabled"))
+			return
+		case result := <-c.authRefresh:
+			reply := WSMessage{Type: "AUTH_REFRESHED"}
+			if result.err != nil {
+				reply = WSMessage{Type: "AUTH_REFRESH_FAILED", Data: map[string]interface{}{"reason": result.err.Error()}}
+			} else {
+				c.ExpiresAt = result.expiresAt
+				expiryTimer.Stop()
+				if !c.ExpiresAt.IsZero() {
+					expiryTimer.Reset(time.Until(c.ExpiresAt))
+					reply.Data = map[string]interface{}{"expiresAt": model.NewJSONTime(c.ExpiresAt)}
+				}
+			}
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.Conn.WriteJSON(reply); err != nil {
+				return
+			}
 		case message, ok := <-c.Send:
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
@@ -199,7 +286,8 @@ type shard struct {
 }
 
 type ChatHub struct {
-	shards         [shardCount]*shard
+	shards         []*shard
+	shardCount     int
 	broadcast      chan []byte
 	register       chan *Client
 	unregister     chan *Client
@@ -209,13 +297,19 @@ type ChatHub struct {
 	FriendshipRepo *repository.FriendshipRepository
 	ctx            context.Context
 	instanceID     string
+	jwtSecret      string // 校验 AUTH_REFRESH 携带的 token
 }
 
-func NewChatHub(rdb *redis.Client, chatRepo *repository.ChatRepository, userRepo *repository.UserRepository, friendRepo *repository.FriendshipRepository) *ChatHub {
-	// 暂时生成简单的实例ID(生产环境需要从配置或环境变量中读取)
-	id := fmt.Sprintf("node_%d", time.Now().UnixNano())
+func NewChatHub(rdb *redis.Client, chatRepo *repository.ChatRepository, userRepo *repository.UserRepository, friendRepo *repository.FriendshipRepository, shardCount int, nodeID, jwtSecret string) *ChatHub {
+	id := resolveNodeID(nodeID)
+
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
 
 	h := &ChatHub{
+		shards:         make([]*shard, shardCount),
+		shardCount:     shardCount,
 		broadcast:      make(chan []byte),
 		register:       make(chan *Client),
 		unregister:     make(chan *Client),
@@ -225,8 +319,9 @@ func NewChatHub(rdb *redis.Client, chatRepo *repository.ChatRepository, userRepo
 		FriendshipRepo: friendRepo,
 		ctx:            context.Background(),
 		instanceID:     id,
+		jwtSecret:      jwtSecret,
 	}
-	for i := 0; i < shardCount; i++ {
+	for i := 0; i < h.shardCount; i++ {
 		h.shards[i] = &shard{
 			clients:           make(map[uint]*Client),
 			localGroupMembers: make(map[string]map[uint]bool),
@@ -235,8 +330,23 @@ func NewChatHub(rdb *redis.Client, chatRepo *repository.ChatRepository, userRepo
 	return h
 }
 
+// resolveNodeID 确定节点 ID：优先使用配置，其次主机名，都不可用时生成随机 ID（重启后会变化）
+func resolveNodeID(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return fmt.Sprintf("node_%d", time.Now().UnixNano())
+}
+
 func (h *ChatHub) getShard(userID uint) *shard {
-	return h.shards[userID%shardCount]
+	return h.shards[h.shardIndex(userID)]
+}
+
+func (h *ChatHub) shardIndex(userID uint) int {
+	return int(userID % uint(h.shardCount))
 }
 
 type PubSubMessage struct {
@@ -250,6 +360,7 @@ func (h *ChatHub) Run() {
 		fmt.Sprintf("chat:node:%s", h.instanceID),
 		"chat:global",
 		"chat:node_broadcast",
+		"chat:disconnect",
 	)
 	go func() {
 		ch := pubsub.Channel()
@@ -263,6 +374,8 @@ func (h *ChatHub) Run() {
 			// 如果是节点级广播psMsg.TargetUsers为空且来自chat:node_broadcast)
 			if msg.Channel == "chat:node_broadcast" {
 				h.pushToLocalGroupUsers(psMsg.Payload)
+			} else if msg.Channel == "chat:disconnect" {
+				h.disconnectLocalUsers(psMsg.TargetUsers, CloseAccountDisabled)
 			} else {
 				h.pushToLocalRawUsers(psMsg.TargetUsers, psMsg.Payload)
 			}
@@ -292,9 +405,11 @@ func (h *ChatHub) Run() {
 			s.clients[client.UserID] = client
 			// 预加载该用户的本地群组映射
 			h.updateLocalGroupMapping(client.UserID, true)
+			shardClients := len(s.clients)
 			s.mu.Unlock()
 			pendingUpdates = append(pendingUpdates, statusUpdate{client.UserID, "online"})
 			monitoring.IMOnlineUsers.Inc()
+			monitoring.IMShardClients.WithLabelValues(h.instanceID, strconv.Itoa(h.shardIndex(client.UserID))).Set(float64(shardClients))
 
 			// 更新数据库最后活动时间
 			if h.UserRepo != nil {
@@ -310,7 +425,9 @@ func (h *ChatHub) Run() {
 				close(client.Send)
 				monitoring.IMOnlineUsers.Dec()
 			}
+			shardClients := len(s.clients)
 			s.mu.Unlock()
+			monitoring.IMShardClients.WithLabelValues(h.instanceID, strconv.Itoa(h.shardIndex(client.UserID))).Set(float64(shardClients))
 			pendingUpdates = append(pendingUpdates, statusUpdate{client.UserID, "offline"})
 
 		case <-heartbeatTicker.C:
@@ -348,8 +465,7 @@ func (h *ChatHub) Run() {
 func (h *ChatHub) refreshOnlineStatus() {
 	pipe := h.Redis.Pipeline()
 	count := 0
-	for i := 0; i < shardCount; i++ {
-		s := h.shards[i]
+	for _, s := range h.shards {
 		s.mu.RLock()
 		for userID := range s.clients {
 			// 使用Set放弃Expire，确保即使key意外丢失也能恢复，并锁定在当前实例
@@ -369,7 +485,11 @@ func (h *ChatHub) refreshOnlineStatus() {
 	}
 }
 
+// NotifyStatus 向好友和群成员广播用户上下线，隐藏在线状态的用户不广播
 func (h *ChatHub) NotifyStatus(userID uint, status string) {
+	if len(h.hiddenOnline([]uint{userID})) > 0 {
+		return
+	}
 	msg := WSMessage{
 		Type: "USER_STATUS",
 		Data: map[string]interface{}{
@@ -430,8 +550,7 @@ func (h *ChatHub) Stop() {
 	logger.Log.Info("ChatHub stopping: clearing online status and closing connections...")
 
 	var allUserIDs []uint
-	for i := 0; i < shardCount; i++ {
-		s := h.shards[i]
+	for _, s := range h.shards {
 		s.mu.Lock()
 		for userID, client := range s.clients {
 			allUserIDs = append(allUserIDs, userID)
@@ -450,6 +569,7 @@ func (h *ChatHub) Stop() {
 	}
 
 	monitoring.IMOnlineUsers.Set(0) // 停机时清空指标
+	monitoring.IMShardClients.Reset()
 	logger.Log.Info("ChatHub stopped", zap.Int("closedConnections", len(allUserIDs)))
 }
 
@@ -552,8 +672,7 @@ func (h *ChatHub) PushToUsers(userIDs []uint, msg WSMessage) {
 
 func (h *ChatHub) pushToLocalRawUsers(userIDs []uint, payload []byte) {
 	if len(userIDs) == 0 {
-		for i := 0; i < shardCount; i++ {
-			s := h.shards[i]
+		for _, s := range h.shards {
 			s.mu.RLock()
 			for _, client := range s.clients {
 				select {
@@ -596,8 +715,7 @@ func (h *ChatHub) pushToLocalGroupUsers(payload []byte) {
 	}
 
 	// 遍历分片，只推送本地在该群的用户
-	for i := 0; i < shardCount; i++ {
-		s := h.shards[i]
+	for _, s := range h.shards {
 		s.mu.RLock()
 		if memberMap, ok := s.localGroupMembers[convID]; ok {
 			for userID := range memberMap {
@@ -613,6 +731,83 @@ func (h *ChatHub) pushToLocalGroupUsers(payload []byte) {
 	}
 }
 
+// DisconnectUsers 断开用户在所有节点上的 WebSocket 连接
+func (h *ChatHub) DisconnectUsers(userIDs []uint) {
+	if len(userIDs) == 0 {
+		return
+	}
+	payload, _ := json.Marshal(PubSubMessage{TargetUsers: userIDs})
+	h.Redis.Publish(h.ctx, "chat:disconnect", payload)
+}
+
+// disconnectLocalUsers 以 code 关闭本节点上这些用户的连接，连接关闭后由 readPump 注销
+func (h *ChatHub) disconnectLocalUsers(userIDs []uint, code int) {
+	for _, id := range userIDs {
+		s := h.getShard(id)
+		s.mu.RLock()
+		if client, ok := s.clients[id]; ok {
+			select {
+			case client.kick <- code:
+			default:
+			}
+		}
+		s.mu.RUnlock()
+	}
+}
+
+// UserDisabledHandler 返回用户禁用事件的处理函数：断开该用户的在线连接
+func (h *ChatHub) UserDisabledHandler() EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(UserDisabledEvent)
+		if !ok {
+			return nil
+		}
+		h.DisconnectUsers([]uint{evt.UserID})
+		return nil
+	}
+}
+
+// GoalCompletedHandler 返回目标达成事件的处理函数：向用户推送系统通知
+func (h *ChatHub) GoalCompletedHandler() EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(GoalCompletedEvent)
+		if !ok {
+			return nil
+		}
+		h.PushToUsers([]uint{evt.UserID}, WSMessage{
+			Type: "SYSTEM_NOTICE",
+			Data: map[string]interface{}{
+				"event":   EventGoalCompleted,
+				"goalId":  evt.GoalID,
+				"content": fmt.Sprintf("恭喜你完成目标「%s」！", evt.Title),
+			},
+		})
+		return nil
+	}
+}
+
+// AttemptGradedHandler 返回评分完成事件的处理函数：通知学生查看成绩
+func (h *ChatHub) AttemptGradedHandler() EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(AttemptGradedEvent)
+		if !ok {
+			return nil
+		}
+		h.PushToUsers([]uint{evt.UserID}, WSMessage{
+			Type: "SYSTEM_NOTICE",
+			Data: map[string]interface{}{
+				"event":     EventAttemptGraded,
+				"levelId":   evt.LevelID,
+				"attemptId": evt.AttemptID,
+				"score":     evt.Score,
+				"success":   evt.Success,
+				"content":   fmt.Sprintf("你的关卡挑战已完成评分，得分 %d", evt.Score),
+			},
+		})
+		return nil
+	}
+}
+
 // GetOnlineCount 统计全站在线用户数（本地分片 + Redis 多实例）
 func (h *ChatHub) GetOnlineCount() int {
 	// 统计所有 user:online:* 键（覆盖多实例部署）
@@ -637,8 +832,7 @@ func (h *ChatHub) GetOnlineCount() int {
 
 	// 仅统计本地分片
 	count := 0
-	for i := 0; i < shardCount; i++ {
-		s := h.shards[i]
+	for _, s := range h.shards {
 		s.mu.RLock()
 		count += len(s.clients)
 		s.mu.RUnlock()
@@ -646,11 +840,65 @@ func (h *ChatHub) GetOnlineCount() int {
 	return count
 }
 
+// ShardStats 本节点分片负载统计
+type ShardStats struct {
+	InstanceID   string  `json:"instanceId"`
+	ShardCount   int     `json:"shardCount"`
+	TotalClients int     `json:"totalClients"`
+	MaxClients   int     `json:"maxClients"`   // 最热分片的连接数
+	AvgClients   float64 `json:"avgClients"`   // 分片平均连接数
+	ShardClients []int   `json:"shardClients"` // 下标即分片号
+}
+
+// GetShardStats 统计本节点各分片的连接数，用于发现 userID%shardCount 造成的热点分片
+func (h *ChatHub) GetShardStats() ShardStats {
+	stats := ShardStats{
+		InstanceID:   h.instanceID,
+		ShardCount:   h.shardCount,
+		ShardClients: make([]int, h.shardCount),
+	}
+	for i, s := range h.shards {
+		s.mu.RLock()
+		n := len(s.clients)
+		s.mu.RUnlock()
+		stats.ShardClients[i] = n
+		stats.TotalClients += n
+		if n > stats.MaxClients {
+			stats.MaxClients = n
+		}
+	}
+	if h.shardCount > 0 {
+		stats.AvgClients = float64(stats.TotalClients) / float64(h.shardCount)
+	}
+	return stats
+}
+
+// ReportMetrics 定期上报本节点各分片连接数和消息持久化队列积压情况，instance 标签为本节点实例ID
+func (h *ChatHub) ReportMetrics() {
+	for i, s := range h.shards {
+		s.mu.RLock()
+		n := len(s.clients)
+		s.mu.RUnlock()
+		monitoring.IMShardClients.WithLabelValues(h.instanceID, strconv.Itoa(i)).Set(float64(n))
+	}
+
+	if h.ChatRepo == nil {
+		return
+	}
+	stats, err := h.ChatRepo.GetStreamStats()
+	if err != nil {
+		logger.Log.Warn("采集消息队列指标失败", zap.Error(err))
+		return
+	}
+	monitoring.IMStreamLength.WithLabelValues(h.instanceID).Set(float64(stats.Length))
+	monitoring.IMStreamPending.WithLabelValues(h.instanceID).Set(float64(stats.Pending))
+	monitoring.IMStreamLag.WithLabelValues(h.instanceID).Set(float64(stats.Lag))
+}
+
 // GetOnlineUserIDs 获取所有在线用户 ID（本地分片）
 func (h *ChatHub) GetOnlineUserIDs() []uint {
 	var ids []uint
-	for i := 0; i < shardCount; i++ {
-		s := h.shards[i]
+	for _, s := range h.shards {
 		s.mu.RLock()
 		for uid := range s.clients {
 			ids = append(ids, uid)
@@ -660,6 +908,7 @@ func (h *ChatHub) GetOnlineUserIDs() []uint {
 	return ids
 }
 
+// IsUserOnline 查询用户的实际连接状态，不考虑隐藏设置；向其他用户展示时使用 VisibleOnlineStatus
 func (h *ChatHub) IsUserOnline(userID uint) bool {
 	// 查本地分片
 	s := h.getShard(userID)
@@ -675,18 +924,94 @@ func (h *ChatHub) IsUserOnline(userID uint) bool {
 	return err == nil && val != ""
 }
 
-func ServeWs(hub *ChatHub, w http.ResponseWriter, r *http.Request, userID uint) {
+// OnlineStatus 批量查询在线状态：先查本地分片，其余用户通过一次 MGET 查询 Redis
+func (h *ChatHub) OnlineStatus(userIDs []uint) map[uint]bool {
+	status := make(map[uint]bool, len(userIDs))
+	var remote []uint
+	for _, uid := range userIDs {
+		if _, seen := status[uid]; seen {
+			continue
+		}
+		s := h.getShard(uid)
+		s.mu.RLock()
+		_, ok := s.clients[uid]
+		s.mu.RUnlock()
+		status[uid] = ok
+		if !ok {
+			remote = append(remote, uid)
+		}
+	}
+	if len(remote) == 0 {
+		return status
+	}
+
+	keys := make([]string, len(remote))
+	for i, uid := range remote {
+		keys[i] = fmt.Sprintf("user:online:%d", uid)
+	}
+	vals, err := h.Redis.MGet(h.ctx, keys...).Result()
+	if err != nil {
+		logger.Log.Warn("批量查询在线状态失败", zap.Error(err), zap.Int("count", len(keys)))
+		return status
+	}
+	for i, v := range vals {
+		if str, ok := v.(string); ok && str != "" {
+			status[remote[i]] = true
+		}
+	}
+	return status
+}
+
+// VisibleOnlineStatus 返回 viewerID 看到的在线状态：隐藏在线状态的其他用户始终显示为离线
+func (h *ChatHub) VisibleOnlineStatus(viewerID uint, userIDs []uint) map[uint]bool {
+	status := h.OnlineStatus(userIDs)
+	for uid := range h.hiddenOnline(userIDs) {
+		if uid != viewerID {
+			status[uid] = false
+		}
+	}
+	return status
+}
+
+// hiddenOnline 返回 userIDs 中隐藏在线状态的用户；查询失败时按全部隐藏处理，避免泄露在线状态
+func (h *ChatHub) hiddenOnline(userIDs []uint) map[uint]bool {
+	hidden := make(map[uint]bool)
+	if h.UserRepo == nil || len(userIDs) == 0 {
+		return hidden
+	}
+	ids, err := h.UserRepo.FindHideOnlineIDs(userIDs)
+	if err != nil {
+		logger.Log.Warn("查询隐藏在线状态失败", zap.Error(err), zap.Int("count", len(userIDs)))
+		for _, uid := range userIDs {
+			hidden[uid] = true
+		}
+		return hidden
+	}
+	for _, uid := range ids {
+		hidden[uid] = true
+	}
+	return hidden
+}
+
+// ServeWs 升级为 WebSocket 连接。连接在握手 token 过期时关闭，客户端可在过期前发送
+// {"type":"AUTH_REFRESH","data":{"token":"..."}} 续期，成功回复 AUTH_REFRESHED，失败回复 AUTH_REFRESH_FAILED
+func ServeWs(hub *ChatHub, w http.ResponseWriter, r *http.Request, claims *util.Claims) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		logger.Log.Error("WebSocket upgrade failed", zap.Error(err), zap.Uint("userId", userID))
+		logger.Log.Error("WebSocket upgrade failed", zap.Error(err), zap.Uint("userId", claims.UserID))
 		return
 	}
 	client := &Client{
-		Hub:     hub,
-		Conn:    conn,
-		Send:    make(chan []byte, 256),
-		UserID:  userID,
-		Limiter: rate.NewLimiter(rate.Limit(30), 50), // 每秒30条，允许突发50条
+		Hub:         hub,
+		Conn:        conn,
+		Send:        make(chan []byte, 256),
+		UserID:      claims.UserID,
+		Limiter:     rate.NewLimiter(rate.Limit(30), 50), // 每秒30条，允许突发50条
+		authRefresh: make(chan authRefreshResult, 1),
+		kick:        make(chan int, 1),
+	}
+	if claims.ExpiresAt != nil {
+		client.ExpiresAt = claims.ExpiresAt.Time
 	}
 	client.Hub.register <- client
 
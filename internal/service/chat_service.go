@@ -1,13 +1,19 @@
 package service
 
 import (
+	"coder_edu_backend/internal/config"
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
 	"coder_edu_backend/pkg/logger"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -16,14 +22,20 @@ import (
 )
 
 type ChatService struct {
-	ChatRepo *repository.ChatRepository
-	Redis    *redis.Client
+	ChatRepo       *repository.ChatRepository
+	FriendshipRepo *repository.FriendshipRepository
+	Redis          *redis.Client
+	Events         *EventBus
+	Cfg            config.ChatConfig
 }
 
-func NewChatService(chatRepo *repository.ChatRepository, rdb *redis.Client) *ChatService {
-	return &ChatService{ChatRepo: chatRepo, Redis: rdb}
+func NewChatService(chatRepo *repository.ChatRepository, friendshipRepo *repository.FriendshipRepository, rdb *redis.Client, events *EventBus, cfg config.ChatConfig) *ChatService {
+	return &ChatService{ChatRepo: chatRepo, FriendshipRepo: friendshipRepo, Redis: rdb, Events: events, Cfg: cfg}
 }
 
+// defaultRevokeWindow 未配置 revoke_window_seconds 时发送者撤回消息的时限
+const defaultRevokeWindow = 2 * time.Minute
+
 func (s *ChatService) CreateSystemMessage(convID string, content string) (*model.Message, error) {
 	msg := &model.Message{
 		ConversationID: convID,
@@ -32,10 +44,160 @@ func (s *ChatService) CreateSystemMessage(convID string, content string) (*model
 		Content:        content,
 	}
 	err := s.ChatRepo.CreateMessage(msg)
+	if err == nil {
+		// 同步写入审计记录，系统消息被折叠或清理后仍可追溯
+		event := &model.ConversationEvent{
+			ConversationID: convID,
+			MessageID:      msg.ID,
+			Content:        content,
+			CreatedAt:      msg.CreatedAt,
+		}
+		if evtErr := s.ChatRepo.CreateConversationEvent(event); evtErr != nil {
+			logger.Log.Warn("写入会话事件记录失败", zap.String("convId", convID), zap.Error(evtErr))
+		}
+	}
 	return msg, err
 }
 
+// CollapseSystemMessages 折叠历史消息中连续的系统消息（msgs 按时间倒序）：
+// 每段连续系统消息只保留最新一条，并在 CollapsedCount 中记录折叠掉的条数。
+// 本页最早的一条始终保留，保证客户端以其作为 before_id 继续翻页时不会重复或遗漏
+func CollapseSystemMessages(msgs []model.Message) []model.Message {
+	if len(msgs) < 3 {
+		return msgs
+	}
+	last := len(msgs) - 1
+	result := make([]model.Message, 0, len(msgs))
+	for i := 0; i < len(msgs); {
+		if msgs[i].Type != "system" {
+			result = append(result, msgs[i])
+			i++
+			continue
+		}
+		j := i
+		for j+1 < len(msgs) && msgs[j+1].Type == "system" {
+			j++
+		}
+		head := msgs[i]
+		if j == last && j > i {
+			head.CollapsedCount = j - i - 1
+			result = append(result, head, msgs[j])
+		} else {
+			head.CollapsedCount = j - i
+			result = append(result, head)
+		}
+		i = j + 1
+	}
+	return result
+}
+
+// MaxConversationRetentionDays 会话单独设置的保留天数上限
+const MaxConversationRetentionDays = 3650
+
+// UpdateConversationRetention 设置会话的消息保留策略：retentionDays 为 0 表示使用全局配置，exempt 为 true 表示不自动清理
+func (s *ChatService) UpdateConversationRetention(convID string, retentionDays int, exempt bool) (*model.Conversation, error) {
+	if retentionDays < 0 || retentionDays > MaxConversationRetentionDays {
+		return nil, fmt.Errorf("%w: retentionDays 须在 0-%d 之间", util.ErrInvalidRequest, MaxConversationRetentionDays)
+	}
+	if _, err := s.ChatRepo.GetConversation(convID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, util.ErrConversationNotFound
+		}
+		return nil, err
+	}
+	if err := s.ChatRepo.UpdateRetention(convID, retentionDays, exempt); err != nil {
+		return nil, err
+	}
+	return s.ChatRepo.GetConversation(convID)
+}
+
+// PurgeExpiredMessages 按保留策略清理过期消息。配置了清理时间段时，仅在时间段内执行，
+// 超出时间段或收到 stop 信号时在当前批次结束后停止，剩余部分留到下一次执行
+func (s *ChatService) PurgeExpiredMessages(cfg config.ChatConfig, stop <-chan struct{}) (int64, error) {
+	start, end, hasWindow, err := parseClockWindow(cfg.MessagePurgeWindow)
+	if err != nil {
+		return 0, err
+	}
+	inWindow := func() bool {
+		return !hasWindow || clockInWindow(time.Now(), start, end)
+	}
+	if !inWindow() {
+		return 0, nil
+	}
+
+	return s.ChatRepo.PurgeExpiredMessages(repository.MessagePurgeOptions{
+		DefaultDays: cfg.MessageRetentionDays,
+		BatchSize:   cfg.MessagePurgeBatchSize,
+		BatchPause:  time.Duration(cfg.MessagePurgeBatchPauseMs) * time.Millisecond,
+		ShouldStop: func() bool {
+			select {
+			case <-stop:
+				return true
+			default:
+			}
+			return !inWindow()
+		},
+	})
+}
+
+// parseClockWindow 解析 "HH:MM-HH:MM" 格式的时间段，返回自零点起的分钟数；空字符串表示不限制
+func parseClockWindow(window string) (start, end int, ok bool, err error) {
+	window = strings.TrimSpace(window)
+	if window == "" {
+		return 0, 0, false, nil
+	}
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return 0, 0, false, fmt.Errorf("invalid time window %q, expected HH:MM-HH:MM", window)
+	}
+	from, err := time.Parse("15:04", strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("invalid time window %q: %w", window, err)
+	}
+	to, err := time.Parse("15:04", strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("invalid time window %q: %w", window, err)
+	}
+	return from.Hour()*60 + from.Minute(), to.Hour()*60 + to.Minute(), true, nil
+}
+
+// clockInWindow 判断 t 是否落在 [start, end) 时间段内，start>end 表示跨零点
+func clockInWindow(t time.Time, start, end int) bool {
+	m := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return m >= start && m < end
+	}
+	return m >= start || m < end
+}
+
+// PruneSystemMessages 清理超过保留期的系统消息，retentionDays<=0 时不清理；豁免清理或单独设置了保留天数的会话不受影响
+func (s *ChatService) PruneSystemMessages(retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	return s.ChatRepo.PruneSystemMessages(time.Now().AddDate(0, 0, -retentionDays), 1000)
+}
+
+// ConversationListFilter 会话列表的筛选和排序条件
+type ConversationListFilter = repository.ConversationListFilter
+
+const (
+	ConversationSortLastMessage = repository.ConversationSortLastMessage
+	ConversationSortUpdated     = repository.ConversationSortUpdated
+)
+
+// CreateGroup 创建群聊。不满足邀请规则的成员会被跳过，记录在返回会话的 SkippedMembers 中
 func (s *ChatService) CreateGroup(creatorID uint, name string, memberIDs []uint) (*model.Conversation, *model.Message, error) {
+	// 获取创建者信息用于邀请校验和系统消息
+	var creator model.User
+	if err := s.ChatRepo.DB.First(&creator, creatorID).Error; err != nil {
+		return nil, nil, err
+	}
+	memberIDs, skipped, err := s.filterInvitees(&creator, memberIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	conv := &model.Conversation{
 		Type:      "group",
 		Name:      name,
@@ -55,14 +217,7 @@ func (s *ChatService) CreateGroup(creatorID uint, name string, memberIDs []uint)
 		return nil, nil, err
 	}
 
-	// 获取创建者信息用于系统消息
-	var creator model.User
-	s.ChatRepo.DB.First(&creator, creatorID)
-
 	for _, id := range memberIDs {
-		if id == creatorID {
-			continue
-		}
 		member := &model.ConversationMember{
 			ConversationID: conv.ID,
 			UserID:         id,
@@ -74,18 +229,87 @@ func (s *ChatService) CreateGroup(creatorID uint, name string, memberIDs []uint)
 	sysMsg, _ := s.CreateSystemMessage(conv.ID, fmt.Sprintf("%s 创建了群聊", creator.Name))
 
 	fullConv, err := s.ChatRepo.GetConversation(conv.ID)
-	return fullConv, sysMsg, err
+	if err != nil {
+		return nil, nil, err
+	}
+	fullConv.SkippedMembers = skipped
+	return fullConv, sysMsg, nil
 }
 
-func (s *ChatService) GetOrCreatePrivateChat(userID1, userID2 uint) (*model.Conversation, error) {
+// filterInvitees 按邀请规则筛选 inviter 可以拉入群聊的用户：开启 GroupInviteFriendsOnly 时只能邀请好友，
+// GroupInviteExemptRoles 中的角色可以邀请任意用户。返回去重后可加入的用户ID（不含 inviter 自己）及被跳过的用户
+func (s *ChatService) filterInvitees(inviter *model.User, userIDs []uint) ([]uint, []model.SkippedMember, error) {
+	candidates := make([]uint, 0, len(userIDs))
+	seen := map[uint]bool{inviter.ID: true}
+	for _, id := range userIDs {
+		if !seen[id] {
+			seen[id] = true
+			candidates = append(candidates, id)
+		}
+	}
+	if len(candidates) == 0 {
+		return candidates, nil, nil
+	}
+
+	var existing []uint
+	if err := s.ChatRepo.DB.Model(&model.User{}).Where("id IN ?", candidates).Pluck("id", &existing).Error; err != nil {
+		return nil, nil, err
+	}
+	exists := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		exists[id] = true
+	}
+
+	var friends map[uint]bool
+	if s.inviteRequiresFriend(inviter.Role) {
+		ids, err := s.FriendshipRepo.GetFriendIDs(inviter.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		friends = make(map[uint]bool, len(ids))
+		for _, id := range ids {
+			friends[id] = true
+		}
+	}
+
+	allowed := make([]uint, 0, len(candidates))
+	var skipped []model.SkippedMember
+	for _, id := range candidates {
+		switch {
+		case !exists[id]:
+			skipped = append(skipped, model.SkippedMember{UserID: id, Reason: model.SkipReasonUserNotFound})
+		case friends != nil && !friends[id]:
+			skipped = append(skipped, model.SkippedMember{UserID: id, Reason: model.SkipReasonNotFriend})
+		default:
+			allowed = append(allowed, id)
+		}
+	}
+	return allowed, skipped, nil
+}
+
+// inviteRequiresFriend 该角色的用户拉人入群时是否只能拉好友
+func (s *ChatService) inviteRequiresFriend(role model.UserRole) bool {
+	if !s.Cfg.GroupInviteFriendsOnly {
+		return false
+	}
+	for _, r := range s.Cfg.GroupInviteExemptRoles {
+		if r == string(role) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetOrCreatePrivateChat 获取两人之间的私聊，不存在时创建；created 表示会话是否为本次新建
+func (s *ChatService) GetOrCreatePrivateChat(userID1, userID2 uint) (conv *model.Conversation, created bool, err error) {
 	if userID1 == userID2 {
-		return nil, errors.New("不能和自己创建私聊")
+		return nil, false, errors.New("不能和自己创建私聊")
 	}
 
 	// 1. 尝试查找已存在的私聊
-	conv, err := s.ChatRepo.FindPrivateConversation(userID1, userID2)
+	conv, err = s.ChatRepo.FindPrivateConversation(userID1, userID2)
 	if err == nil {
-		return conv, nil
+		return conv, false, nil
 	}
 
 	// 2. 如果不存在，则创建新私聊
@@ -96,7 +320,7 @@ func (s *ChatService) GetOrCreatePrivateChat(userID1, userID2 uint) (*model.Conv
 	}
 
 	if err := s.ChatRepo.CreateConversation(newConv); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	// 3. 添加两个成员
@@ -108,11 +332,30 @@ func (s *ChatService) GetOrCreatePrivateChat(userID1, userID2 uint) (*model.Conv
 			Role:           "member",
 		}
 		if err := s.ChatRepo.AddMember(member); err != nil {
-			return nil, err
+			return nil, false, err
 		}
 	}
 
-	return s.ChatRepo.GetConversation(newConv.ID)
+	conv, err = s.ChatRepo.GetConversation(newConv.ID)
+	return conv, true, err
+}
+
+// GetSavedConversation 获取用户的“我的收藏”会话（只有自己一个成员，用于记笔记、保存链接），首次访问时创建
+func (s *ChatService) GetSavedConversation(userID uint) (*model.Conversation, error) {
+	return s.ChatRepo.EnsureSavedConversation(userID)
+}
+
+// SendInitialMessage 创建会话后在同一请求内发送首条文本消息。
+// 会话是本次新建的（created 为 true）且消息发送失败时删除该会话，避免成员看到一个空会话；
+// duplicated 的含义与 SendMessage 相同
+func (s *ChatService) SendInitialMessage(ctx context.Context, convID string, senderID uint, created bool, content, clientMsgID string) (msg *model.Message, duplicated bool, err error) {
+	msg, duplicated, err = s.SendMessage(ctx, senderID, convID, "text", content, clientMsgID)
+	if err != nil && created {
+		if delErr := s.ChatRepo.DeleteConversation(convID); delErr != nil {
+			logger.FromContext(ctx).Warn("首条消息发送失败后删除会话失败", zap.String("convId", convID), zap.Error(delErr))
+		}
+	}
+	return msg, duplicated, err
 }
 
 func (s *ChatService) InviteMember(adminID uint, convID string, targetUserID uint) (*model.Message, error) {
@@ -130,10 +373,8 @@ func (s *ChatService) InviteMember(adminID uint, convID string, targetUserID uin
 	if err != nil {
 		return nil, errors.New("你不是该群成员")
 	}
-	isOwner := conv.CreatorID == adminID
-	isAdmin := member.Role == "admin" || isOwner
-	if !isAdmin {
-		return nil, errors.New("只有管理员可以邀请成员")
+	if !canManageMembers(conv, member) {
+		return nil, errors.New("只有群主或协管员可以邀请成员")
 	}
 
 	// 3. 检查目标是否已在群里
@@ -142,6 +383,22 @@ func (s *ChatService) InviteMember(adminID uint, convID string, targetUserID uin
 		return nil, errors.New("该用户已是群成员")
 	}
 
+	// 4. 检查邀请规则：默认只能邀请自己的好友
+	var inviter model.User
+	if err := s.ChatRepo.DB.First(&inviter, adminID).Error; err != nil {
+		return nil, err
+	}
+	_, skipped, err := s.filterInvitees(&inviter, []uint{targetUserID})
+	if err != nil {
+		return nil, err
+	}
+	if len(skipped) > 0 {
+		if skipped[0].Reason == model.SkipReasonNotFriend {
+			return nil, errors.New("只能邀请自己的好友入群")
+		}
+		return nil, errors.New("用户不存在")
+	}
+
 	newMember := &model.ConversationMember{
 		ConversationID: convID,
 		UserID:         targetUserID,
@@ -177,9 +434,8 @@ func (s *ChatService) KickMember(adminID uint, convID string, targetUserID uint)
 		return nil, errors.New("你不是该群成员")
 	}
 	isOwner := conv.CreatorID == adminID
-	isAdmin := caller.Role == "admin" || isOwner
-	if !isAdmin {
-		return nil, errors.New("只有管理员可以踢出成员")
+	if !canManageMembers(conv, caller) {
+		return nil, errors.New("只有群主或协管员可以踢出成员")
 	}
 
 	// 3. 不能踢出群主
@@ -192,9 +448,13 @@ func (s *ChatService) KickMember(adminID uint, convID string, targetUserID uint)
 	if err != nil {
 		return nil, errors.New("目标用户不是群成员")
 	}
-	if targetMember.Role == "admin" && !isOwner {
+	if targetMember.Role == model.MemberRoleAdmin && !isOwner {
 		return nil, errors.New("只有群主可以踢出管理员")
 	}
+	// 协管员只能踢出普通成员
+	if targetMember.Role == model.MemberRoleModerator && caller.Role == model.MemberRoleModerator && !isOwner {
+		return nil, errors.New("协管员不能踢出其他协管员")
+	}
 
 	if err := s.ChatRepo.RemoveMember(convID, targetUserID); err != nil {
 		return nil, err
@@ -221,10 +481,8 @@ func (s *ChatService) UpdateGroupInfo(adminID uint, convID string, name string,
 	if err != nil {
 		return nil, errors.New("你不是该群成员")
 	}
-	isOwner := conv.CreatorID == adminID
-	isAdmin := member.Role == "admin" || isOwner
-	if !isAdmin {
-		return nil, errors.New("只有管理员可以修改群信息")
+	if !canManageMembers(conv, member) {
+		return nil, errors.New("只有群主或协管员可以修改群信息")
 	}
 
 	updates := make(map[string]interface{})
@@ -253,6 +511,53 @@ func (s *ChatService) UpdateGroupInfo(adminID uint, convID string, name string,
 	return nil, nil
 }
 
+// canManageMembers 群主、管理员和协管员可以邀请、移除成员及修改群信息
+func canManageMembers(conv *model.Conversation, member *model.ConversationMember) bool {
+	return conv.CreatorID == member.UserID ||
+		member.Role == model.MemberRoleAdmin ||
+		member.Role == model.MemberRoleModerator
+}
+
+// SetMemberRole 设置群成员角色（协管员/普通成员），仅群主可操作
+func (s *ChatService) SetMemberRole(ownerID uint, convID string, targetUserID uint, role string) (*model.Message, error) {
+	if role != model.MemberRoleModerator && role != model.MemberRoleMember {
+		return nil, errors.New("角色只能设置为 moderator 或 member")
+	}
+
+	conv, err := s.ChatRepo.GetConversation(convID)
+	if err != nil {
+		return nil, err
+	}
+	if conv.Type != "group" {
+		return nil, errors.New("只有群聊可以设置成员角色")
+	}
+	if conv.CreatorID != ownerID {
+		return nil, errors.New("只有群主可以设置协管员")
+	}
+	if targetUserID == ownerID {
+		return nil, errors.New("不能修改群主的角色")
+	}
+
+	target, err := s.ChatRepo.GetMember(convID, targetUserID)
+	if err != nil {
+		return nil, errors.New("目标用户不是群成员")
+	}
+	if target.Role == role {
+		return nil, nil
+	}
+
+	if err := s.ChatRepo.UpdateMemberRole(convID, targetUserID, role); err != nil {
+		return nil, err
+	}
+
+	var targetUser model.User
+	s.ChatRepo.DB.First(&targetUser, targetUserID)
+	if role == model.MemberRoleModerator {
+		return s.CreateSystemMessage(convID, fmt.Sprintf("%s 被设为协管员", targetUser.Name))
+	}
+	return s.CreateSystemMessage(convID, fmt.Sprintf("%s 被取消协管员", targetUser.Name))
+}
+
 func (s *ChatService) TransferAdmin(currentAdminID uint, convID string, newAdminID uint) error {
 	if currentAdminID == newAdminID {
 		return errors.New("不能转让给自己")
@@ -301,12 +606,232 @@ func (s *ChatService) TransferAdmin(currentAdminID uint, convID string, newAdmin
 	})
 }
 
-func (s *ChatService) SendMessage(senderID uint, convID string, msgType string, content string, clientMsgID string) (*model.Message, error) {
-	_, err := s.ChatRepo.GetMember(convID, senderID)
+const chatUploadKeyPrefix = "chat:upload:"
+
+// chatUploadTTL 上传记录的保留时间，超过后发送的文件消息不再带附件元数据
+const chatUploadTTL = 24 * time.Hour
+
+type chatUploadRecord struct {
+	UploaderID uint `json:"uploaderId"`
+	model.MessageAttachment
+}
+
+// RecordUpload 记录聊天文件上传的元数据（文件名、大小、按内容检测的 MIME 类型），
+// 发送引用该文件 URL 的 file/image 消息时由服务端填充附件信息
+func (s *ChatService) RecordUpload(ctx context.Context, uploaderID uint, fileURL string, attachment model.MessageAttachment) {
+	if s.Redis == nil {
+		return
+	}
+	name := filepath.Base(strings.TrimSpace(attachment.FileName))
+	if name == "." || name == "/" {
+		name = ""
+	}
+	attachment.FileName = truncateUTF8(name, 255)
+	attachment.MimeType = truncateUTF8(attachment.MimeType, 100)
+	data, _ := json.Marshal(chatUploadRecord{UploaderID: uploaderID, MessageAttachment: attachment})
+	if err := s.Redis.Set(context.Background(), chatUploadKeyPrefix+fileURL, data, chatUploadTTL).Err(); err != nil {
+		logger.FromContext(ctx).Warn("记录聊天文件元数据失败", zap.String("url", fileURL), zap.Error(err))
+	}
+}
+
+// uploadedAttachment 查找发送者本人上传的文件元数据，记录不存在或已过期时返回 nil
+func (s *ChatService) uploadedAttachment(senderID uint, fileURL string) *model.MessageAttachment {
+	if s.Redis == nil {
+		return nil
+	}
+	val, err := s.Redis.Get(context.Background(), chatUploadKeyPrefix+fileURL).Result()
 	if err != nil {
-		return nil, errors.New("非会话成员无法发送消息")
+		return nil
 	}
+	var record chatUploadRecord
+	if json.Unmarshal([]byte(val), &record) != nil || record.UploaderID != senderID {
+		return nil
+	}
+	return &record.MessageAttachment
+}
+
+const (
+	sendDedupTTL      = 24 * time.Hour        // 同一 ClientMsgID 的去重窗口
+	sendDedupWait     = 2 * time.Second       // 重复请求等待首个请求写入完成的最长时间
+	sendDedupInterval = 50 * time.Millisecond // 等待期间的轮询间隔
+	sendDedupPending  = "pending"             // 首个请求尚未写入完成时的占位值
+)
+
+// SendMessage 发送消息。携带 ClientMsgID 时按 (会话, 发送者, ClientMsgID) 去重：
+// 客户端超时重试等重复提交直接返回首次创建的消息，duplicated 为 true，调用方不应再次推送
+func (s *ChatService) SendMessage(ctx context.Context, senderID uint, convID string, msgType string, content string, clientMsgID string) (msg *model.Message, duplicated bool, err error) {
+	if _, err := s.ChatRepo.GetMember(convID, senderID); err != nil {
+		return nil, false, errors.New("非会话成员无法发送消息")
+	}
+
+	dedupKey := ""
+	if clientMsgID != "" {
+		if s.Redis != nil {
+			dedupKey = fmt.Sprintf("chat:dedup:%s:%d:%s", convID, senderID, clientMsgID)
+			ok, err := s.Redis.SetNX(context.Background(), dedupKey, sendDedupPending, sendDedupTTL).Result()
+			if err != nil {
+				// Redis 不可用时退化为数据库查重
+				dedupKey = ""
+				if existing, err := s.ChatRepo.FindMessageByClientMsgID(convID, senderID, clientMsgID); err == nil {
+					return existing, true, nil
+				}
+			} else if !ok {
+				existing, err := s.waitDedupedMessage(dedupKey)
+				return existing, err == nil, err
+			}
+		} else if existing, err := s.ChatRepo.FindMessageByClientMsgID(convID, senderID, clientMsgID); err == nil {
+			return existing, true, nil
+		}
+	}
+
+	msg, err = s.createUserMessage(senderID, convID, msgType, content, clientMsgID)
+	if err == nil && msgType == "text" && strings.Contains(content, "@") {
+		if mErr := s.recordMentions(msg); mErr != nil {
+			logger.FromContext(ctx).Warn("记录 @ 提及失败", zap.String("msgId", msg.ID), zap.Error(mErr))
+		}
+	}
+	if dedupKey != "" {
+		ctx := context.Background()
+		if err != nil {
+			// 创建失败时释放去重键，允许客户端重试
+			s.Redis.Del(ctx, dedupKey)
+		} else if data, mErr := json.Marshal(msg); mErr == nil {
+			s.Redis.Set(ctx, dedupKey, data, sendDedupTTL)
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return msg, false, nil
+}
+
+// mentionPattern 匹配消息中的 @userId 或 @名称（到空白或下一个 @ 为止）
+var mentionPattern = regexp.MustCompile(`@([^\s@]+)`)
+
+// ParseMentionTokens 提取消息中 @ 后的标记，去掉结尾的标点
+func ParseMentionTokens(content string) []string {
+	matches := mentionPattern.FindAllStringSubmatch(content, -1)
+	tokens := make([]string, 0, len(matches))
+	for _, m := range matches {
+		token := strings.TrimRight(m[1], ",.!?;:，。！？；：、")
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// recordMentions 解析群聊消息中的 @ 提及，只保留会话内的其他成员，写入提及记录并填充 msg.Mentions
+func (s *ChatService) recordMentions(msg *model.Message) error {
+	tokens := ParseMentionTokens(msg.Content)
+	if len(tokens) == 0 {
+		return nil
+	}
+	conv, err := s.ChatRepo.GetConversation(msg.ConversationID)
+	if err != nil {
+		return err
+	}
+	if conv.Type != "group" {
+		return nil
+	}
+
+	senderID := uint(0)
+	if msg.SenderID != nil {
+		senderID = *msg.SenderID
+	}
+	senderName := ""
+	byID := make(map[uint]bool, len(conv.Members))
+	byName := make(map[string]uint, len(conv.Members)*2)
+	for _, m := range conv.Members {
+		if m.UserID == senderID {
+			senderName = m.User.Name
+		}
+		byID[m.UserID] = true
+		if m.Nickname != "" {
+			byName[strings.ToLower(m.Nickname)] = m.UserID
+		}
+		if m.User.Name != "" {
+			byName[strings.ToLower(m.User.Name)] = m.UserID
+		}
+	}
+
+	seen := make(map[uint]bool)
+	var mentioned []uint
+	for _, token := range tokens {
+		var uid uint
+		if id, err := strconv.ParseUint(token, 10, 64); err == nil && byID[uint(id)] {
+			uid = uint(id)
+		} else {
+			uid = byName[strings.ToLower(token)]
+		}
+		if uid == 0 || uid == senderID || seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		mentioned = append(mentioned, uid)
+	}
+	if len(mentioned) == 0 {
+		return nil
+	}
+
+	preview := truncateUTF8(msg.Content, 255)
+	mentions := make([]model.MessageMention, 0, len(mentioned))
+	for _, uid := range mentioned {
+		mentions = append(mentions, model.MessageMention{
+			MessageID:      msg.ID,
+			UserID:         uid,
+			ConversationID: msg.ConversationID,
+			SenderID:       senderID,
+			Preview:        preview,
+			CreatedAt:      msg.CreatedAt,
+		})
+	}
+	if err := s.ChatRepo.CreateMentions(mentions); err != nil {
+		return err
+	}
+	msg.Mentions = mentioned
+
+	s.Events.Publish(EventUserMentioned, UserMentionedEvent{
+		UserIDs:          mentioned,
+		SenderID:         senderID,
+		SenderName:       senderName,
+		ConversationID:   msg.ConversationID,
+		ConversationName: conv.Name,
+		MessageID:        msg.ID,
+		Preview:          preview,
+	})
+	return nil
+}
 
+// GetUnreadMentions 获取用户在所有会话中未读的 @ 提及
+func (s *ChatService) GetUnreadMentions(userID uint, limit, offset int) ([]model.MessageMention, int64, error) {
+	return s.ChatRepo.ListUnreadMentions(userID, limit, offset)
+}
+
+// waitDedupedMessage 读取去重键中保存的首次发送结果；首个请求仍在写入时短暂轮询等待
+func (s *ChatService) waitDedupedMessage(key string) (*model.Message, error) {
+	ctx := context.Background()
+	deadline := time.Now().Add(sendDedupWait)
+	for {
+		val, err := s.Redis.Get(ctx, key).Result()
+		if err == nil && val != sendDedupPending {
+			var msg model.Message
+			if err := json.Unmarshal([]byte(val), &msg); err != nil {
+				return nil, err
+			}
+			return &msg, nil
+		}
+		if err == redis.Nil {
+			return nil, errors.New("消息发送失败，请重试")
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("消息正在发送中，请稍后重试")
+		}
+		time.Sleep(sendDedupInterval)
+	}
+}
+
+func (s *ChatService) createUserMessage(senderID uint, convID string, msgType string, content string, clientMsgID string) (*model.Message, error) {
 	msg := &model.Message{
 		ConversationID: convID,
 		SenderID:       &senderID,
@@ -314,6 +839,12 @@ func (s *ChatService) SendMessage(senderID uint, convID string, msgType string,
 		Content:        content,
 		ClientMsgID:    clientMsgID,
 	}
+	// 仅文件/图片消息保存附件元数据，取自上传时服务端记录的信息，不信任客户端提交的值
+	if msgType == "file" || msgType == "image" {
+		if attachment := s.uploadedAttachment(senderID, content); attachment != nil {
+			msg.MessageAttachment = *attachment
+		}
+	}
 
 	// 提前填充发送者信息，适配异步写入架构
 	var user model.User
@@ -351,8 +882,100 @@ func (s *ChatService) GetMessageContext(userID uint, msgID string, limit int) ([
 	return s.ChatRepo.GetMessageContext(msgID, limit)
 }
 
-func (s *ChatService) RevokeMessage(userID uint, msgID string) (*model.Message, error) {
-	return s.ChatRepo.RevokeMessage(msgID, userID)
+// RevokeWindow 发送者撤回自己消息的时限
+func (s *ChatService) RevokeWindow() time.Duration {
+	if s.Cfg.RevokeWindowSeconds <= 0 {
+		return defaultRevokeWindow
+	}
+	return time.Duration(s.Cfg.RevokeWindowSeconds) * time.Second
+}
+
+// CanRevoke 判断用户能否撤回消息：发送者在撤回时限内可撤回自己的消息，
+// 群聊中群主和协管员可随时撤回其他成员的消息。conv 需预加载成员，系统消息不可撤回
+func (s *ChatService) CanRevoke(conv *model.Conversation, m *model.Message, userID uint) bool {
+	return s.canRevokeAt(conv, m, userID, time.Now())
+}
+
+// canRevokeAt 按给定的当前时间判断能否撤回，发送时间距 now 恰好等于撤回时限时已不可撤回
+func (s *ChatService) canRevokeAt(conv *model.Conversation, m *model.Message, userID uint, now time.Time) bool {
+	if m.IsRevoked || m.SenderID == nil {
+		return false
+	}
+	if *m.SenderID == userID {
+		return now.Sub(m.CreatedAt.Time) < s.RevokeWindow()
+	}
+	if conv == nil || conv.Type != "group" {
+		return false
+	}
+
+	var caller, sender *model.ConversationMember
+	for i := range conv.Members {
+		switch conv.Members[i].UserID {
+		case userID:
+			caller = &conv.Members[i]
+		case *m.SenderID:
+			sender = &conv.Members[i]
+		}
+	}
+	return caller != nil && canRevokeOthers(conv, caller, sender)
+}
+
+// canRevokeOthers 群主可撤回任何成员的消息；协管员只能撤回普通成员（含已退群成员，sender 为 nil）的消息
+func canRevokeOthers(conv *model.Conversation, caller, sender *model.ConversationMember) bool {
+	if conv.CreatorID == caller.UserID || caller.Role == model.MemberRoleAdmin {
+		return true
+	}
+	if caller.Role != model.MemberRoleModerator {
+		return false
+	}
+	return sender == nil || (sender.Role == model.MemberRoleMember && sender.UserID != conv.CreatorID)
+}
+
+// RevokeMessage 撤回消息。群主或协管员撤回他人消息时，额外写入一条系统消息作为记录并一并返回
+func (s *ChatService) RevokeMessage(ctx context.Context, userID uint, msgID string) (*model.Message, *model.Message, error) {
+	msg, err := s.ChatRepo.FindMessage(msgID)
+	if err != nil {
+		return nil, nil, errors.New("消息不存在")
+	}
+	if msg.IsRevoked {
+		return msg, nil, nil
+	}
+
+	conv, err := s.ChatRepo.GetConversation(msg.ConversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !s.CanRevoke(conv, msg, userID) {
+		if msg.SenderID != nil && *msg.SenderID == userID {
+			return nil, nil, fmt.Errorf("消息发送已超过 %s，无法撤回", formatRevokeWindow(s.RevokeWindow()))
+		}
+		return nil, nil, errors.New("无权撤回该消息")
+	}
+
+	if err := s.ChatRepo.RevokeMessage(msg); err != nil {
+		return nil, nil, err
+	}
+	if *msg.SenderID == userID {
+		return msg, nil, nil
+	}
+
+	var operator, sender model.User
+	s.ChatRepo.DB.First(&operator, userID)
+	s.ChatRepo.DB.First(&sender, *msg.SenderID)
+	sysMsg, err := s.CreateSystemMessage(conv.ID, fmt.Sprintf("%s 撤回了 %s 的一条消息", operator.Name, sender.Name))
+	if err != nil {
+		logger.FromContext(ctx).Warn("写入撤回记录失败", zap.String("convId", conv.ID), zap.String("msgId", msgID), zap.Error(err))
+		return msg, nil, nil
+	}
+	return msg, sysMsg, nil
+}
+
+// formatRevokeWindow 将撤回时限格式化为提示文案，整分钟显示为分钟
+func formatRevokeWindow(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return fmt.Sprintf("%d 分钟", int(d/time.Minute))
+	}
+	return fmt.Sprintf("%d 秒", int(d/time.Second))
 }
 
 func (s *ChatService) DisbandGroup(userID uint, convID string) ([]uint, error) {
@@ -428,7 +1051,115 @@ func (s *ChatService) GlobalSearch(userID uint, query string, limit, offset int)
 }
 
 func (s *ChatService) MarkAsRead(userID uint, convID string, msgID string) error {
-	return s.ChatRepo.UpdateLastReadMessage(convID, userID, msgID)
+	if err := s.ChatRepo.UpdateLastReadMessage(convID, userID, msgID); err != nil {
+		return err
+	}
+	return s.ChatRepo.MarkMentionsRead(userID, convID)
+}
+
+// MarkAllAsRead 将用户的所有会话标记为已读，返回被更新的会话及其最新消息
+func (s *ChatService) MarkAllAsRead(userID uint) ([]repository.ReadMark, error) {
+	marks, err := s.ChatRepo.MarkAllAsRead(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ChatRepo.MarkMentionsRead(userID, ""); err != nil {
+		return nil, err
+	}
+	return marks, nil
+}
+
+// 在线状态：online 已连接聊天；away 未连接但近期有接口访问；offline 其他情况或用户隐藏了在线状态
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+
+	presenceAwayWindow = 5 * time.Minute
+	// MaxPresenceQuery 单次批量查询在线状态的用户数上限
+	MaxPresenceQuery = 200
+)
+
+// UserPresence 用户在线状态
+type UserPresence struct {
+	UserID   uint            `json:"userId"`
+	Status   string          `json:"status"`
+	LastSeen *model.JSONTime `json:"lastSeen,omitempty"` // 隐藏在线状态的用户不返回
+}
+
+// GetPresence 根据连接状态（online 由调用方通过 ChatHub 批量查询）和最近访问时间计算在线状态，
+// 隐藏在线状态的用户对他人始终显示为离线
+func (s *ChatService) GetPresence(viewerID uint, userIDs []uint, online map[uint]bool) ([]UserPresence, error) {
+	var users []model.User
+	if err := s.ChatRepo.DB.Select("id", "last_seen", "hide_online").
+		Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]model.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	now := time.Now()
+	result := make([]UserPresence, 0, len(userIDs))
+	for _, id := range userIDs {
+		p := UserPresence{UserID: id, Status: PresenceOffline}
+		u, ok := byID[id]
+		if !ok || (u.HideOnline && id != viewerID) {
+			result = append(result, p)
+			continue
+		}
+		lastSeen := u.LastSeen
+		p.LastSeen = &lastSeen
+		switch {
+		case online[id]:
+			p.Status = PresenceOnline
+		case !u.LastSeen.IsZero() && now.Sub(u.LastSeen.Time) <= presenceAwayWindow:
+			p.Status = PresenceAway
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+const (
+	// 会话统计默认时间范围与最大时间范围
+	conversationStatsDefaultDays = 30
+	conversationStatsMaxDays     = 366
+	conversationStatsTopSenders  = 10
+)
+
+// GetConversationStats 统计会话消息量、类型分布、活跃成员等，仅会话成员和管理员可查看。
+// start/end 为空时默认统计最近 30 天，时间跨度最多一年。
+func (s *ChatService) GetConversationStats(userID uint, role model.UserRole, convID string, start, end *time.Time) (*repository.ConversationStats, error) {
+	if _, err := s.ChatRepo.GetConversation(convID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, util.ErrConversationNotFound
+		}
+		return nil, err
+	}
+	if role != model.Admin {
+		if _, err := s.ChatRepo.GetMember(convID, userID); err != nil {
+			return nil, util.ErrPermissionDenied
+		}
+	}
+
+	to := time.Now()
+	if end != nil {
+		to = *end
+	}
+	from := to.AddDate(0, 0, -conversationStatsDefaultDays)
+	if start != nil {
+		from = *start
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: 开始时间必须早于结束时间", util.ErrInvalidRequest)
+	}
+	if to.Sub(from) > conversationStatsMaxDays*24*time.Hour {
+		return nil, fmt.Errorf("%w: 统计时间跨度不能超过 %d 天", util.ErrInvalidRequest, conversationStatsMaxDays)
+	}
+
+	return s.ChatRepo.GetConversationStats(convID, from, to, conversationStatsTopSenders)
 }
 
 // HideConversation 隐藏会话（从列表中移除，收到新消息时自动恢复）
@@ -460,12 +1191,12 @@ type ActiveUserBrief struct {
 
 // LatestMessagePreview 最新消息预览
 type LatestMessagePreview struct {
-	SenderName       string    `json:"senderName"`
-	SenderAvatar     string    `json:"senderAvatar"`
-	Content          string    `json:"content"`
-	ConversationID   string    `json:"conversationId"`
-	ConversationName string    `json:"conversationName"`
-	CreatedAt        time.Time `json:"createdAt"`
+	SenderName       string         `json:"senderName"`
+	SenderAvatar     string         `json:"senderAvatar"`
+	Content          string         `json:"content"`
+	ConversationID   string         `json:"conversationId"`
+	ConversationName string         `json:"conversationName"`
+	CreatedAt        model.JSONTime `json:"createdAt"`
 }
 
 const overviewCacheTTL = 30 * time.Second // 概览缓存有效期
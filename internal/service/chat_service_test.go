@@ -0,0 +1,212 @@
+package service
+
+import (
+	"coder_edu_backend/internal/config"
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/testutil"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func revokeTestMessage(senderID uint, sentAt time.Time) *model.Message {
+	return &model.Message{SenderID: &senderID, CreatedAt: model.NewJSONTime(sentAt), Type: "text"}
+}
+
+func TestCanRevokeOwnMessageWindowBoundary(t *testing.T) {
+	s := &ChatService{Cfg: config.ChatConfig{RevokeWindowSeconds: 120}}
+	sentAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	msg := revokeTestMessage(1, sentAt)
+	conv := &model.Conversation{Type: "private"}
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    bool
+	}{
+		{"right after sending", 0, true},
+		{"just inside the window", 120*time.Second - time.Millisecond, true},
+		{"exactly at the window", 120 * time.Second, false},
+		{"just outside the window", 120*time.Second + time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.canRevokeAt(conv, msg, 1, sentAt.Add(tt.elapsed)); got != tt.want {
+				t.Fatalf("canRevokeAt after %v = %v, want %v", tt.elapsed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanRevokeUsesDefaultWindow(t *testing.T) {
+	s := &ChatService{}
+	sentAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	msg := revokeTestMessage(1, sentAt)
+	if !s.canRevokeAt(nil, msg, 1, sentAt.Add(defaultRevokeWindow-time.Second)) {
+		t.Fatal("message inside the default window should be revocable")
+	}
+	if s.canRevokeAt(nil, msg, 1, sentAt.Add(defaultRevokeWindow)) {
+		t.Fatal("message at the default window should not be revocable")
+	}
+}
+
+func TestCanRevokeOthersOverride(t *testing.T) {
+	const (
+		owner     uint = 1
+		admin     uint = 2
+		moderator uint = 3
+		member    uint = 4
+		other     uint = 5
+		leftUser  uint = 6
+	)
+	s := &ChatService{Cfg: config.ChatConfig{RevokeWindowSeconds: 120}}
+	group := &model.Conversation{
+		Type:      "group",
+		CreatorID: owner,
+		Members: []model.ConversationMember{
+			{UserID: owner, Role: model.MemberRoleAdmin},
+			{UserID: admin, Role: model.MemberRoleAdmin},
+			{UserID: moderator, Role: model.MemberRoleModerator},
+			{UserID: member, Role: model.MemberRoleMember},
+			{UserID: other, Role: model.MemberRoleModerator},
+		},
+	}
+	sentAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	// 远超撤回时限，管理类撤回不受时限约束
+	now := sentAt.Add(24 * time.Hour)
+
+	tests := []struct {
+		name   string
+		conv   *model.Conversation
+		caller uint
+		sender uint
+		want   bool
+	}{
+		{"owner revokes member", group, owner, member, true},
+		{"owner revokes moderator", group, owner, moderator, true},
+		{"admin revokes owner", group, admin, owner, true},
+		{"moderator revokes member", group, moderator, member, true},
+		{"moderator revokes user who left", group, moderator, leftUser, true},
+		{"moderator cannot revoke another moderator", group, moderator, other, false},
+		{"moderator cannot revoke owner", group, moderator, owner, false},
+		{"member cannot revoke others", group, member, moderator, false},
+		{"non-member cannot revoke", group, leftUser, member, false},
+		{"own message outside window", group, member, member, false},
+		{"no override in private chat", &model.Conversation{Type: "private", CreatorID: owner}, owner, member, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := revokeTestMessage(tt.sender, sentAt)
+			if got := s.canRevokeAt(tt.conv, msg, tt.caller, now); got != tt.want {
+				t.Fatalf("canRevokeAt = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanRevokeRejectsSystemAndRevokedMessages(t *testing.T) {
+	s := &ChatService{}
+	now := time.Now()
+	if s.canRevokeAt(nil, &model.Message{Type: "system", CreatedAt: model.NewJSONTime(now)}, 1, now) {
+		t.Fatal("system message must not be revocable")
+	}
+	revoked := revokeTestMessage(1, now)
+	revoked.IsRevoked = true
+	if s.canRevokeAt(nil, revoked, 1, now) {
+		t.Fatal("revoked message must not be revocable again")
+	}
+}
+
+// TestSendMessageDeduplicatesClientMsgID 需要 testutil.MySQL 的测试库，未设置 TEST_MYSQL_DSN 时跳过；
+// Redis 去重下的并发重复提交使用 miniredis
+func TestSendMessageDeduplicatesClientMsgID(t *testing.T) {
+	db := testutil.MySQL(t)
+
+	const senderID = 987654321
+	conv := model.Conversation{UUIDBase: model.UUIDBase{ID: model.GenerateUUID()}, Type: "group", Name: "send-dedup-test"}
+	if err := db.Create(&conv).Error; err != nil {
+		t.Fatalf("create conversation: %v", err)
+	}
+	if err := db.Create(&model.ConversationMember{ConversationID: conv.ID, UserID: senderID}).Error; err != nil {
+		t.Fatalf("create member: %v", err)
+	}
+	defer func() {
+		db.Unscoped().Where("conversation_id = ?", conv.ID).Delete(&model.Message{})
+		db.Unscoped().Where("conversation_id = ?", conv.ID).Delete(&model.ConversationMember{})
+		db.Unscoped().Delete(&conv)
+	}()
+
+	// 消息仓库不接 Redis，消息同步落库，便于直接统计条数
+	chatRepo := repository.NewChatRepository(db, nil)
+	countStored := func(clientMsgID string) int64 {
+		var n int64
+		db.Model(&model.Message{}).Where("conversation_id = ? AND client_msg_id = ?", conv.ID, clientMsgID).Count(&n)
+		return n
+	}
+
+	t.Run("database fallback", func(t *testing.T) {
+		s := &ChatService{ChatRepo: chatRepo}
+		clientMsgID := model.GenerateUUID()
+
+		first, dup, err := s.SendMessage(context.Background(), senderID, conv.ID, "text", "hello", clientMsgID)
+		if err != nil || dup {
+			t.Fatalf("first send: dup=%v err=%v", dup, err)
+		}
+		second, dup, err := s.SendMessage(context.Background(), senderID, conv.ID, "text", "hello", clientMsgID)
+		if err != nil || !dup {
+			t.Fatalf("second send: dup=%v err=%v, want duplicated", dup, err)
+		}
+		if second.ID != first.ID {
+			t.Fatalf("second send returned %s, want %s", second.ID, first.ID)
+		}
+		if n := countStored(clientMsgID); n != 1 {
+			t.Fatalf("stored %d messages, want 1", n)
+		}
+	})
+
+	t.Run("redis concurrent", func(t *testing.T) {
+		rdb := testutil.Redis(t)
+		s := &ChatService{ChatRepo: chatRepo, Redis: rdb}
+		clientMsgID := model.GenerateUUID()
+		defer rdb.Del(context.Background(), fmt.Sprintf("chat:dedup:%s:%d:%s", conv.ID, senderID, clientMsgID))
+
+		type result struct {
+			msg *model.Message
+			dup bool
+			err error
+		}
+		results := make([]result, 2)
+		var wg sync.WaitGroup
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				msg, dup, err := s.SendMessage(context.Background(), senderID, conv.ID, "text", "hello", clientMsgID)
+				results[i] = result{msg, dup, err}
+			}(i)
+		}
+		wg.Wait()
+
+		dups := 0
+		for i, r := range results {
+			if r.err != nil {
+				t.Fatalf("send %d: %v", i, r.err)
+			}
+			if r.dup {
+				dups++
+			}
+		}
+		if dups != 1 {
+			t.Fatalf("%d sends reported duplicated, want exactly 1", dups)
+		}
+		if results[0].msg.ID != results[1].msg.ID {
+			t.Fatalf("sends returned different messages %s and %s", results[0].msg.ID, results[1].msg.ID)
+		}
+		if n := countStored(clientMsgID); n != 1 {
+			t.Fatalf("stored %d messages, want 1", n)
+		}
+	})
+}
@@ -82,16 +82,16 @@ type PostRequest struct {
 }
 
 type PostResponse struct {
-	ID           string    `json:"id"`
-	Title        string    `json:"title"`
-	Content      string    `json:"content"`
-	Author       string    `json:"author"`
-	Avatar       string    `json:"avatar"`
-	Tags         []string  `json:"tags"`
-	CreatedAt    time.Time `json:"createdAt"`
-	Likes        int       `json:"likes"`
-	Views        int       `json:"views"`
-	CommentCount int       `json:"commentCount"`
+	ID           string         `json:"id"`
+	Title        string         `json:"title"`
+	Content      string         `json:"content"`
+	Author       string         `json:"author"`
+	Avatar       string         `json:"avatar"`
+	Tags         []string       `json:"tags"`
+	CreatedAt    model.JSONTime `json:"createdAt"`
+	Likes        int            `json:"likes"`
+	Views        int            `json:"views"`
+	CommentCount int            `json:"commentCount"`
 }
 
 type QuestionRequest struct {
@@ -124,7 +124,7 @@ type ResourceResponse struct {
 	DownloadCount int                         `json:"downloadCount"`
 	ViewCount     int                         `json:"viewCount"`
 	Likes         int                         `json:"likes"`
-	CreatedAt     time.Time                   `json:"createdAt"`
+	CreatedAt     model.JSONTime              `json:"createdAt"`
 	IsLiked       bool                        `json:"isLiked"`
 }
 
@@ -135,15 +135,15 @@ type CommentCreateRequest struct {
 }
 
 type ReplyResponse struct {
-	ID        string    `json:"id"`
-	Author    string    `json:"author"`
-	AuthorID  uint      `json:"authorId"`
-	Avatar    string    `json:"avatar"`
-	Content   string    `json:"content"`
-	ToUser    string    `json:"toUser,omitempty"`
-	CreatedAt time.Time `json:"createdAt"`
-	Likes     int       `json:"likes"`
-	IsLiked   bool      `json:"isLiked"`
+	ID        string         `json:"id"`
+	Author    string         `json:"author"`
+	AuthorID  uint           `json:"authorId"`
+	Avatar    string         `json:"avatar"`
+	Content   string         `json:"content"`
+	ToUser    string         `json:"toUser,omitempty"`
+	CreatedAt model.JSONTime `json:"createdAt"`
+	Likes     int            `json:"likes"`
+	IsLiked   bool           `json:"isLiked"`
 }
 
 type CommentResponse struct {
@@ -153,7 +153,7 @@ type CommentResponse struct {
 	Avatar    string          `json:"avatar"`
 	Content   string          `json:"content"`
 	ToUser    string          `json:"toUser,omitempty"`
-	CreatedAt time.Time       `json:"createdAt"`
+	CreatedAt model.JSONTime  `json:"createdAt"`
 	Likes     int             `json:"likes"`
 	Replies   []ReplyResponse `json:"replies"`
 	IsLiked   bool            `json:"isLiked"`
@@ -260,7 +260,7 @@ func (s *CommunityService) GetPostComments(postID string, page, limit int, userI
 
 	// 转换并组装树形结构
 	commentMap := make(map[string]*CommentResponse)
-	var rootComments []CommentResponse
+	rootComments := make([]CommentResponse, 0)
 
 	// 第一遍：识别一级评论
 	for _, c := range allComments {
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/testutil"
+	"encoding/json"
+	"testing"
+)
+
+// TestGetPostCommentsEmpty 需要 testutil.MySQL 的测试库，未设置 TEST_MYSQL_DSN 时跳过
+func TestGetPostCommentsEmpty(t *testing.T) {
+	db := testutil.MySQL(t)
+	s := &CommunityService{PostRepo: repository.NewPostRepository(db)}
+
+	comments, total, err := s.GetPostComments(model.GenerateUUID(), 1, 20, 0)
+	if err != nil {
+		t.Fatalf("get post comments: %v", err)
+	}
+	if total != 0 {
+		t.Fatalf("total = %d, want 0", total)
+	}
+	body, _ := json.Marshal(comments)
+	if string(body) != "[]" {
+		t.Fatalf("comments = %s, want []", body)
+	}
+}
@@ -1,14 +1,23 @@
 package service
 
 import (
+	"bytes"
 	"coder_edu_backend/internal/config"
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
 	"coder_edu_backend/internal/util"
 	"coder_edu_backend/pkg/logger"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -30,17 +39,20 @@ type ContentService struct {
 	StorageService *StorageService
 	Cfg            *config.Config
 	Redis          *redis.Client
+	Transcoder     *TranscodeService // 可为空，为空时不生成多分辨率版本
 	httpClient     *http.Client
-	workerSem      chan struct{}  // 并发控制信号量
+	FFmpeg         *FFmpegLimiter // 限制 FFmpeg 并发
+	UploadLimiter  *UploadLimiter // 限制单用户并发上传
 	wg             sync.WaitGroup // 优雅停机等待组
 }
 
-func NewContentService(resourceRepo *repository.ResourceRepository, storageService *StorageService, cfg *config.Config, rdb *redis.Client) *ContentService {
+func NewContentService(resourceRepo *repository.ResourceRepository, storageService *StorageService, cfg *config.Config, rdb *redis.Client, transcoder *TranscodeService, ffmpegLimiter *FFmpegLimiter, uploadLimiter *UploadLimiter) *ContentService {
 	return &ContentService{
 		ResourceRepo:   resourceRepo,
 		StorageService: storageService,
 		Cfg:            cfg,
 		Redis:          rdb,
+		Transcoder:     transcoder,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 			Transport: &http.Transport{
@@ -49,7 +61,8 @@ func NewContentService(resourceRepo *repository.ResourceRepository, storageServi
 				MaxIdleConnsPerHost: 20,
 			},
 		},
-		workerSem: make(chan struct{}, 5), // 限制同时处理视频数量
+		FFmpeg:        ffmpegLimiter,
+		UploadLimiter: uploadLimiter,
 	}
 }
 
@@ -183,11 +196,12 @@ func (s *ContentService) UploadVideo(ctx context.Context, file *multipart.FileHe
 	if err != nil {
 		return nil, err
 	}
-	defer dst.Close()
 
 	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
 		return nil, err
 	}
+	dst.Close() // 转码任务会移动该文件，需先关闭句柄
 
 	// 上传视频
 	videoURL, err := s.StorageService.UploadFile(ctx, videoFilename, videoPath, file.Header.Get("Content-Type"))
@@ -196,7 +210,7 @@ func (s *ContentService) UploadVideo(ctx context.Context, file *multipart.FileHe
 	}
 
 	// 同步获取元数据，确保返回给前端正确的数据
-	duration, thumbnailURL := s.processVideoMetadata(ctx, videoURL, videoPath, file.Filename)
+	meta := s.processVideoMetadata(ctx, videoURL, videoPath, file.Filename)
 
 	resource := &model.Resource{
 		Title:       title,
@@ -204,45 +218,45 @@ func (s *ContentService) UploadVideo(ctx context.Context, file *multipart.FileHe
 		Type:        model.Video,
 		Status:      model.ResourceSuccess,
 		URL:         videoURL,
-		Duration:    duration,
 		Size:        file.Size,
 		Format:      strings.TrimPrefix(ext, "."),
-		Thumbnail:   thumbnailURL,
 	}
+	meta.apply(resource)
 
 	if err := s.ResourceRepo.Create(resource); err != nil {
 		s.StorageService.Delete(ctx, videoFilename)
 		return nil, err
 	}
 
+	s.enqueueTranscode(resource.ID, videoPath)
+
 	return resource, nil
 }
 
-func (s *ContentService) UploadVideoChunk(ctx context.Context, chunkFile *multipart.FileHeader, chunkNumber, totalChunks int, identifier, filename string, title, description string) (*model.UploadProgress, *model.Resource, error) {
-	// 创建临时目录存储分块
-	tempDir := filepath.Join(s.Cfg.Storage.LocalPath, "temp", identifier)
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		return nil, nil, err
-	}
+// ChecksumMismatchError 分片或合并文件校验失败，Chunks 为需要重新上传的分片序号
+type ChecksumMismatchError struct {
+	Chunks []int
+}
 
-	// 保存分块文件
-	chunkPath := filepath.Join(tempDir, fmt.Sprintf("chunk_%d", chunkNumber))
-	src, err := chunkFile.Open()
-	if err != nil {
-		return nil, nil, err
-	}
-	defer src.Close()
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("文件校验失败，请重新上传分片: %v", e.Chunks)
+}
 
-	dst, err := os.Create(chunkPath)
-	if err != nil {
-		return nil, nil, err
+// UploadVideoChunk 保存视频分片，全部到齐后合并上传。
+// chunkMD5 为当前分片的 MD5（十六进制），fileMD5 为整个文件的 MD5，均可为空（为空时跳过对应校验）
+func (s *ContentService) UploadVideoChunk(ctx context.Context, chunkFile *multipart.FileHeader, chunkNumber, totalChunks int, identifier, filename string, title, description string, chunkMD5, fileMD5 string) (*model.UploadProgress, *model.Resource, error) {
+	// MinIO 使用分片直传，不在本地落盘
+	if s.Cfg.Storage.Type == util.StorageMinio {
+		if mp, ok := s.StorageService.Provider.(*MinioStorageProvider); ok {
+			return s.uploadVideoChunkMultipart(ctx, mp, chunkFile, chunkNumber, totalChunks, identifier, filename, title, description, chunkMD5, fileMD5)
+		}
 	}
 
-	if _, err := io.Copy(dst, src); err != nil {
-		dst.Close()
+	// 创建临时目录存储分块
+	tempDir := filepath.Join(s.Cfg.Storage.LocalPath, "temp", identifier)
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
 		return nil, nil, err
 	}
-	dst.Close() // 写入完成后立即关闭，不要等 defer，防止win文件锁问题
 
 	// 更新进度 (使用Redis----方便共享)
 	redisKey := uploadProgressKeyPrefix + identifier
@@ -257,7 +271,7 @@ func (s *ContentService) UploadVideoChunk(ctx context.Context, chunkFile *multip
 			FileSize:       0,
 			Identifier:     identifier,
 			Filename:       filename,
-			CreatedAt:      time.Now(),
+			CreatedAt:      model.NewJSONTime(time.Now()),
 			Chunks:         make(map[int]bool),
 		}
 	} else if err != nil {
@@ -270,6 +284,63 @@ func (s *ContentService) UploadVideoChunk(ctx context.Context, chunkFile *multip
 			progress.Chunks = make(map[int]bool)
 		}
 	}
+	if progress.ChunkChecksums == nil {
+		progress.ChunkChecksums = make(map[int]string)
+	}
+	chunkMD5 = strings.ToLower(chunkMD5)
+	if fileMD5 != "" {
+		progress.FileMD5 = strings.ToLower(fileMD5)
+	}
+
+	// 已校验且内容一致的分片无需重复写入（断点续传重试场景）
+	alreadyVerified := progress.Chunks[chunkNumber] && chunkMD5 != "" && progress.ChunkChecksums[chunkNumber] == chunkMD5
+	if !alreadyVerified {
+		// 保存分块文件，写入时同步计算 MD5
+		chunkPath := filepath.Join(tempDir, fmt.Sprintf("chunk_%d", chunkNumber))
+		src, err := chunkFile.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		defer src.Close()
+
+		// 重传已记录的分片时先记下旧分片大小，校验失败需从进度中扣除
+		var prevSize int64
+		if progress.Chunks[chunkNumber] {
+			if info, err := os.Stat(chunkPath); err == nil {
+				prevSize = info.Size()
+			}
+		}
+
+		dst, err := os.Create(chunkPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		hash := md5.New()
+		if _, err := io.Copy(io.MultiWriter(dst, hash), src); err != nil {
+			dst.Close()
+			return nil, nil, err
+		}
+		dst.Close() // 写入完成后立即关闭，不要等 defer，防止win文件锁问题
+
+		sum := hex.EncodeToString(hash.Sum(nil))
+		if chunkMD5 != "" && sum != chunkMD5 {
+			os.Remove(chunkPath)
+			// 旧分片已被覆盖删除，需从进度中移除，否则合并时会按已到齐处理
+			if progress.Chunks[chunkNumber] {
+				progress.UploadedChunks--
+				progress.FileSize -= prevSize
+				delete(progress.Chunks, chunkNumber)
+				delete(progress.ChunkChecksums, chunkNumber)
+				progress.UpdatedAt = model.NewJSONTime(time.Now())
+				if err := s.saveUploadProgress(ctx, redisKey, progress); err != nil {
+					return nil, nil, err
+				}
+			}
+			return nil, nil, &ChecksumMismatchError{Chunks: []int{chunkNumber}}
+		}
+		progress.ChunkChecksums[chunkNumber] = sum
+	}
 
 	// 更新进度
 	if !progress.Chunks[chunkNumber] {
@@ -278,11 +349,11 @@ func (s *ContentService) UploadVideoChunk(ctx context.Context, chunkFile *multip
 		progress.Chunks[chunkNumber] = true
 	}
 
+	progress.UpdatedAt = model.NewJSONTime(time.Now())
 	isComplete := progress.UploadedChunks == progress.TotalChunks
 
 	// 保存回Redis(设置24小时过期)
-	updatedVal, _ := json.Marshal(progress)
-	if err := s.Redis.Set(ctx, redisKey, updatedVal, 24*time.Hour).Err(); err != nil {
+	if err := s.saveUploadProgress(ctx, redisKey, progress); err != nil {
 		return nil, nil, err
 	}
 
@@ -298,6 +369,7 @@ func (s *ContentService) UploadVideoChunk(ctx context.Context, chunkFile *multip
 			return nil, nil, err
 		}
 
+		fileHash := md5.New()
 		for i := 1; i <= totalChunks; i++ {
 			chunkPath := filepath.Join(tempDir, fmt.Sprintf("chunk_%d", i))
 			f, err := os.Open(chunkPath)
@@ -305,7 +377,7 @@ func (s *ContentService) UploadVideoChunk(ctx context.Context, chunkFile *multip
 				finalFile.Close()
 				return nil, nil, err
 			}
-			_, err = io.Copy(finalFile, f)
+			_, err = io.Copy(io.MultiWriter(finalFile, fileHash), f)
 			f.Close()
 			if err != nil {
 				finalFile.Close()
@@ -314,6 +386,32 @@ func (s *ContentService) UploadVideoChunk(ctx context.Context, chunkFile *multip
 		}
 		finalFile.Close()
 
+		// 校验合并后的完整文件，失败时不创建资源记录
+		if progress.FileMD5 != "" && hex.EncodeToString(fileHash.Sum(nil)) != progress.FileMD5 {
+			os.Remove(finalPath)
+			bad := s.findCorruptChunks(tempDir, progress)
+			if len(bad) == 0 {
+				// 各分片与记录一致，说明客户端分片本身有误，需整体重传
+				for i := 1; i <= totalChunks; i++ {
+					bad = append(bad, i)
+				}
+			}
+			for _, n := range bad {
+				if progress.Chunks[n] {
+					chunkPath := filepath.Join(tempDir, fmt.Sprintf("chunk_%d", n))
+					if info, err := os.Stat(chunkPath); err == nil {
+						progress.FileSize -= info.Size()
+					}
+					os.Remove(chunkPath)
+					progress.UploadedChunks--
+					delete(progress.Chunks, n)
+				}
+				delete(progress.ChunkChecksums, n)
+			}
+			s.saveUploadProgress(ctx, redisKey, progress)
+			return nil, nil, &ChecksumMismatchError{Chunks: bad}
+		}
+
 		// 上传合并后的文件
 		finalURL, err := s.StorageService.UploadFile(ctx, videoFilename, finalPath, "video/"+strings.TrimPrefix(ext, "."))
 		if err != nil {
@@ -327,7 +425,7 @@ func (s *ContentService) UploadVideoChunk(ctx context.Context, chunkFile *multip
 		}
 
 		// 1. 同步获取元数据（分片上传最后一步需要准确的时长和封面）
-		duration, thumbnail := s.processVideoMetadata(ctx, finalURL, finalPath, filename)
+		meta := s.processVideoMetadata(ctx, finalURL, finalPath, filename)
 
 		resource = &model.Resource{
 			Title:       title,
@@ -335,19 +433,20 @@ func (s *ContentService) UploadVideoChunk(ctx context.Context, chunkFile *multip
 			Type:        model.Video,
 			Status:      model.ResourceSuccess,
 			URL:         finalURL,
-			Duration:    duration,
 			Size:        progress.FileSize,
 			Format:      strings.TrimPrefix(ext, "."),
-			Thumbnail:   thumbnail,
 		}
+		meta.apply(resource)
 
 		if err := s.ResourceRepo.Create(resource); err != nil {
-			logger.Log.Error("创建资源记录失败", zap.Error(err))
+			logger.FromContext(ctx).Error("创建资源记录失败", zap.Error(err))
 			s.StorageService.Delete(ctx, videoFilename) // 清理孤立文件
 			os.Remove(finalPath)
 			return nil, nil, err
 		}
 
+		s.enqueueTranscode(resource.ID, finalPath)
+
 		// 2. 异步执行清理工作
 		s.wg.Add(1)
 		go func(lPath, tDir, rKey string) {
@@ -365,6 +464,265 @@ func (s *ContentService) UploadVideoChunk(ctx context.Context, chunkFile *multip
 	return progress, nil, nil
 }
 
+// minMultipartPartSize S3 协议要求除最后一片外每个 part 不小于 5MB
+const minMultipartPartSize = 5 << 20
+
+// uploadVideoChunkMultipart 将分片作为 MinIO multipart 的一个 part 直接上传，全部到齐后在服务端合并。
+// 除最后一片外小于 5MB 的分片直接拒绝；合并后下载到本地校验整文件 MD5，并用于探测和多分辨率转码
+func (s *ContentService) uploadVideoChunkMultipart(ctx context.Context, mp *MinioStorageProvider, chunkFile *multipart.FileHeader, chunkNumber, totalChunks int, identifier, filename, title, description, chunkMD5, fileMD5 string) (*model.UploadProgress, *model.Resource, error) {
+	// 提前拒绝过小的分片，避免所有分片传完后才在合并时失败
+	if chunkNumber < totalChunks && chunkFile.Size < minMultipartPartSize {
+		return nil, nil, util.ErrChunkTooSmall
+	}
+
+	redisKey := uploadProgressKeyPrefix + identifier
+	ext := filepath.Ext(filename)
+	contentType := "video/" + strings.TrimPrefix(ext, ".")
+
+	// 初始化进度与 uploadID 需加锁，避免并发首片各自创建 multipart upload
+	var progress *model.UploadProgress
+	err := s.withUploadLock(ctx, identifier, func() error {
+		var err error
+		progress, err = s.loadOrInitProgress(ctx, redisKey, identifier, filename, totalChunks)
+		if err != nil {
+			return err
+		}
+		changed := false
+		if fileMD5 != "" && progress.FileMD5 != strings.ToLower(fileMD5) {
+			progress.FileMD5 = strings.ToLower(fileMD5)
+			changed = true
+		}
+		if progress.UploadID == "" {
+			progress.ObjectName = fmt.Sprintf("videos/%s%s", util.GenerateRandomString(16), ext)
+			progress.UploadID, err = mp.NewMultipartUpload(ctx, progress.ObjectName, contentType)
+			if err != nil {
+				return err
+			}
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+		return s.saveUploadProgress(ctx, redisKey, progress)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 上传分片（不持锁，允许多个分片并行上传）
+	chunkMD5 = strings.ToLower(chunkMD5)
+	var md5Base64 string
+	if chunkMD5 != "" {
+		raw, _ := hex.DecodeString(chunkMD5)
+		md5Base64 = base64.StdEncoding.EncodeToString(raw)
+	}
+	src, err := chunkFile.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer src.Close()
+
+	etag, err := mp.PutPart(ctx, progress.ObjectName, progress.UploadID, chunkNumber, src, chunkFile.Size, md5Base64)
+	if err != nil {
+		// 携带 Content-MD5 时，内容不一致会被 MinIO 拒绝，提示客户端重传该分片；其他错误（网络、权限等）原样返回
+		if chunkMD5 != "" && isDigestMismatch(err) {
+			logger.FromContext(ctx).Warn("分片 MD5 校验失败", zap.String("identifier", identifier), zap.Int("chunk", chunkNumber), zap.Error(err))
+			return nil, nil, &ChecksumMismatchError{Chunks: []int{chunkNumber}}
+		}
+		return nil, nil, err
+	}
+
+	// 记录 ETag 并判断是否全部到齐
+	var isComplete bool
+	err = s.withUploadLock(ctx, identifier, func() error {
+		var err error
+		progress, err = s.loadOrInitProgress(ctx, redisKey, identifier, filename, totalChunks)
+		if err != nil {
+			return err
+		}
+		if progress.PartETags == nil {
+			progress.PartETags = make(map[int]string)
+		}
+		if !progress.Chunks[chunkNumber] {
+			progress.UploadedChunks++
+			progress.FileSize += chunkFile.Size
+			progress.Chunks[chunkNumber] = true
+		}
+		progress.PartETags[chunkNumber] = etag
+		progress.UpdatedAt = model.NewJSONTime(time.Now())
+		isComplete = progress.UploadedChunks == progress.TotalChunks
+		return s.saveUploadProgress(ctx, redisKey, progress)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isComplete {
+		return progress, nil, nil
+	}
+
+	finalURL, err := mp.CompleteMultipartUpload(ctx, progress.ObjectName, progress.UploadID, progress.PartETags)
+	if err != nil {
+		// 合并失败后该 uploadID 无法继续使用，中止以释放已上传的分片，客户端需重新上传
+		if _, abortErr := s.AbortChunkUpload(ctx, identifier); abortErr != nil {
+			logger.FromContext(ctx).Warn("合并失败后中止分片直传失败", zap.String("identifier", identifier), zap.Error(abortErr))
+		}
+		return nil, nil, err
+	}
+
+	// 需要校验整文件 MD5 或转码时，将合并后的对象下载到本地
+	var localPath string
+	if progress.FileMD5 != "" || s.Transcoder != nil {
+		localPath = filepath.Join(s.Cfg.Storage.LocalPath, "temp", identifier+"_final"+ext)
+		sum, err := downloadWithMD5(ctx, mp, progress.ObjectName, localPath)
+		if err == nil && progress.FileMD5 != "" && sum != progress.FileMD5 {
+			// 合并后 uploadID 已失效，无法只重传部分分片，需整体重传
+			bad := make([]int, 0, totalChunks)
+			for i := 1; i <= totalChunks; i++ {
+				bad = append(bad, i)
+			}
+			err = &ChecksumMismatchError{Chunks: bad}
+		}
+		if err != nil {
+			os.Remove(localPath)
+			s.StorageService.Delete(ctx, progress.ObjectName)
+			s.Redis.Del(ctx, redisKey)
+			return nil, nil, err
+		}
+	}
+
+	if title == "" {
+		title = strings.TrimSuffix(filename, ext)
+	}
+
+	// 优先探测本地副本，否则通过限时地址让 FFmpeg 直接读取对象获取时长和封面
+	probePath := localPath
+	if probePath == "" {
+		probePath, _ = s.StorageService.PresignedURL(ctx, progress.ObjectName, 10*time.Minute)
+	}
+	meta := s.processVideoMetadata(ctx, finalURL, probePath, filename)
+
+	resource := &model.Resource{
+		Title:       title,
+		Description: description,
+		Type:        model.Video,
+		Status:      model.ResourceSuccess,
+		URL:         finalURL,
+		Size:        progress.FileSize,
+		Format:      strings.TrimPrefix(ext, "."),
+	}
+	meta.apply(resource)
+	if err := s.ResourceRepo.Create(resource); err != nil {
+		logger.FromContext(ctx).Error("创建资源记录失败", zap.Error(err))
+		s.StorageService.Delete(ctx, progress.ObjectName)
+		s.Redis.Del(ctx, redisKey)
+		if localPath != "" {
+			os.Remove(localPath)
+		}
+		return nil, nil, err
+	}
+
+	if localPath != "" {
+		// 转码任务会移走本地副本，未启用转码时直接清理
+		s.enqueueTranscode(resource.ID, localPath)
+		os.Remove(localPath)
+	}
+
+	s.Redis.Del(ctx, redisKey)
+	return progress, resource, nil
+}
+
+// downloadWithMD5 将对象下载到 localPath，同时返回内容的 MD5（十六进制）
+func downloadWithMD5(ctx context.Context, mp *MinioStorageProvider, objectName, localPath string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return "", err
+	}
+	f, err := os.Create(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if err := mp.Download(ctx, objectName, io.MultiWriter(f, hash)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// loadOrInitProgress 读取上传进度，不存在时初始化
+func (s *ContentService) loadOrInitProgress(ctx context.Context, redisKey, identifier, filename string, totalChunks int) (*model.UploadProgress, error) {
+	val, err := s.Redis.Get(ctx, redisKey).Result()
+	if err == redis.Nil {
+		return &model.UploadProgress{
+			TotalChunks: totalChunks,
+			Identifier:  identifier,
+			Filename:    filename,
+			CreatedAt:   model.NewJSONTime(time.Now()),
+			Chunks:      make(map[int]bool),
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var progress model.UploadProgress
+	if err := json.Unmarshal([]byte(val), &progress); err != nil {
+		return nil, err
+	}
+	if progress.Chunks == nil {
+		progress.Chunks = make(map[int]bool)
+	}
+	return &progress, nil
+}
+
+// withUploadLock 基于 Redis 的短时互斥锁，串行化同一上传的进度读写
+func (s *ContentService) withUploadLock(ctx context.Context, identifier string, fn func() error) error {
+	lockKey := uploadProgressKeyPrefix + identifier + ":lock"
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		ok, err := s.Redis.SetNX(ctx, lockKey, "1", 30*time.Second).Result()
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("获取上传锁超时: %s", identifier)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	defer s.Redis.Del(context.Background(), lockKey)
+	return fn()
+}
+
+func (s *ContentService) saveUploadProgress(ctx context.Context, redisKey string, progress *model.UploadProgress) error {
+	data, _ := json.Marshal(progress)
+	return s.Redis.Set(ctx, redisKey, data, 24*time.Hour).Err()
+}
+
+// findCorruptChunks 重新计算磁盘上各分片的 MD5，返回与已记录值不一致或缺失的分片
+func (s *ContentService) findCorruptChunks(tempDir string, progress *model.UploadProgress) []int {
+	var bad []int
+	for i := 1; i <= progress.TotalChunks; i++ {
+		expected, ok := progress.ChunkChecksums[i]
+		if !ok {
+			continue
+		}
+		f, err := os.Open(filepath.Join(tempDir, fmt.Sprintf("chunk_%d", i)))
+		if err != nil {
+			bad = append(bad, i)
+			continue
+		}
+		hash := md5.New()
+		_, err = io.Copy(hash, f)
+		f.Close()
+		if err != nil || hex.EncodeToString(hash.Sum(nil)) != expected {
+			bad = append(bad, i)
+		}
+	}
+	return bad
+}
+
 func (s *ContentService) GetUploadProgress(identifier string) (*model.UploadProgress, error) {
 	redisKey := uploadProgressKeyPrefix + identifier
 	val, err := s.Redis.Get(context.Background(), redisKey).Result()
@@ -381,6 +739,234 @@ func (s *ContentService) GetUploadProgress(identifier string) (*model.UploadProg
 	return &progress, nil
 }
 
+// AbortChunkUpload 放弃分片上传：删除临时分片和进度，MinIO 直传模式下同时中止 multipart upload。
+// 临时目录和进度都不存在时 found 为 false
+func (s *ContentService) AbortChunkUpload(ctx context.Context, identifier string) (found bool, err error) {
+	redisKey := uploadProgressKeyPrefix + identifier
+	chunkDir := filepath.Join(s.Cfg.Storage.LocalPath, "temp", identifier)
+	err = s.withUploadLock(ctx, identifier, func() error {
+		if _, statErr := os.Stat(chunkDir); statErr == nil {
+			found = true
+		}
+		if val, err := s.Redis.Get(ctx, redisKey).Result(); err == nil {
+			found = true
+			var progress model.UploadProgress
+			if json.Unmarshal([]byte(val), &progress) == nil && progress.UploadID != "" {
+				if mp, ok := s.StorageService.Provider.(*MinioStorageProvider); ok {
+					if err := mp.AbortMultipartUpload(ctx, progress.ObjectName, progress.UploadID); err != nil {
+						logger.FromContext(ctx).Warn("中止分片直传失败", zap.String("identifier", identifier), zap.Error(err))
+					}
+				}
+			}
+		} else if err != redis.Nil {
+			return err
+		}
+
+		if err := os.RemoveAll(chunkDir); err != nil {
+			return err
+		}
+		return s.Redis.Del(ctx, redisKey).Err()
+	})
+	return found, err
+}
+
+// CleanupStaleUploads 清理被放弃的分片上传：删除临时分片目录和对应的 Redis 进度，
+// 合并中断后残留的 *_final 文件，以及 MinIO 直传模式下超时未完成的 multipart upload
+func (s *ContentService) CleanupStaleUploads(ttl time.Duration) {
+	tempDir := filepath.Join(s.Cfg.Storage.LocalPath, "temp")
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Log.Error("读取上传临时目录失败", zap.Error(err))
+		}
+		return
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	cleaned := 0
+	for _, entry := range entries {
+		// 合并后的完整文件正常会在上传结束后删除，进程中途退出时会残留
+		if !entry.IsDir() {
+			if strings.Contains(entry.Name(), "_final") {
+				if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) >= ttl {
+					if err := os.Remove(filepath.Join(tempDir, entry.Name())); err == nil {
+						cleaned++
+					}
+				}
+			}
+			continue
+		}
+		// 转码目录由 TranscodeService 管理
+		if entry.Name() == "transcode" {
+			continue
+		}
+		identifier := entry.Name()
+		redisKey := uploadProgressKeyPrefix + identifier
+
+		// 优先使用进度中的最近活动时间，进度丢失时退回目录修改时间
+		var lastActive time.Time
+		if val, err := s.Redis.Get(ctx, redisKey).Result(); err == nil {
+			var progress model.UploadProgress
+			if json.Unmarshal([]byte(val), &progress) == nil {
+				lastActive = progress.UpdatedAt.Time
+				if lastActive.IsZero() {
+					lastActive = progress.CreatedAt.Time
+				}
+			}
+		} else if err != redis.Nil {
+			logger.Log.Warn("读取上传进度失败，跳过本次清理", zap.String("identifier", identifier), zap.Error(err))
+			continue
+		}
+		if lastActive.IsZero() {
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			lastActive = info.ModTime()
+		}
+
+		if now.Sub(lastActive) < ttl {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(tempDir, identifier)); err != nil {
+			logger.Log.Error("删除废弃分片目录失败", zap.String("identifier", identifier), zap.Error(err))
+			continue
+		}
+		s.Redis.Del(ctx, redisKey)
+		cleaned++
+	}
+
+	cleaned += s.cleanupStaleMultipartUploads(ctx, ttl)
+
+	if cleaned > 0 {
+		logger.Log.Info("已清理废弃的分片上传", zap.Int("count", cleaned))
+	}
+}
+
+// cleanupStaleMultipartUploads 中止超过 ttl 未活动的 MinIO 直传。直传不落盘，无法通过临时目录发现，
+// 先按 Redis 进度中止过期的上传，再回收进度已过期（24 小时）或丢失后遗留在 MinIO 中的 upload
+func (s *ContentService) cleanupStaleMultipartUploads(ctx context.Context, ttl time.Duration) int {
+	mp, ok := s.StorageService.Provider.(*MinioStorageProvider)
+	if !ok {
+		return 0
+	}
+
+	now := time.Now()
+	cleaned := 0
+	active := make(map[string]bool)
+	iter := s.Redis.Scan(ctx, 0, uploadProgressKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		identifier := strings.TrimPrefix(iter.Val(), uploadProgressKeyPrefix)
+		if strings.HasSuffix(identifier, ":lock") {
+			continue
+		}
+		val, err := s.Redis.Get(ctx, iter.Val()).Result()
+		if err != nil {
+			continue
+		}
+		var progress model.UploadProgress
+		if json.Unmarshal([]byte(val), &progress) != nil || progress.UploadID == "" {
+			continue
+		}
+		lastActive := progress.UpdatedAt.Time
+		if lastActive.IsZero() {
+			lastActive = progress.CreatedAt.Time
+		}
+		if now.Sub(lastActive) < ttl {
+			active[progress.UploadID] = true
+			continue
+		}
+		if _, err := s.AbortChunkUpload(ctx, identifier); err != nil {
+			logger.Log.Warn("中止过期分片直传失败", zap.String("identifier", identifier), zap.Error(err))
+			active[progress.UploadID] = true
+			continue
+		}
+		cleaned++
+	}
+	if err := iter.Err(); err != nil {
+		// 无法确认哪些上传仍在进行，本轮不做兜底回收
+		logger.Log.Warn("扫描上传进度失败", zap.Error(err))
+		return cleaned
+	}
+
+	aborted, err := mp.AbortStaleMultipartUploads(ctx, "videos/", now.Add(-ttl), active)
+	if err != nil {
+		logger.Log.Warn("回收遗留分片直传失败", zap.Error(err))
+	}
+	return cleaned + aborted
+}
+
+// GeneratePresignedURL 为对象生成限时下载地址，本地存储直接返回本地访问路径
+func (s *ContentService) GeneratePresignedURL(ctx context.Context, objectPath string, expiry time.Duration) (string, error) {
+	if s.Cfg.Storage.Type == util.StorageLocal {
+		return s.StorageService.GetURL(objectPath), nil
+	}
+	return s.StorageService.PresignedURL(ctx, objectPath, expiry)
+}
+
+// GetResourceAccessURL 校验访问权限后返回资源的限时访问地址。
+// 学生不能访问已停用的C语言资源模块下的资源
+func (s *ContentService) GetResourceAccessURL(ctx context.Context, resourceID uint, role model.UserRole, expiry time.Duration) (string, error) {
+	resource, err := s.ResourceRepo.FindByID(resourceID)
+	if err != nil {
+		return "", util.ErrResourceNotFound
+	}
+
+	// ModuleID 的含义取决于 ModuleType，目前只有 C 语言资源模块会关联 ModuleID 并可被禁用
+	if role == model.Student && resource.ModuleID != 0 {
+		switch resource.ModuleType {
+		case "c_programming":
+			var module model.CProgrammingResource
+			if err := s.ResourceRepo.DB.Select("enabled").First(&module, resource.ModuleID).Error; err == nil && !module.Enabled {
+				return "", util.ErrPermissionDenied
+			}
+		}
+	}
+
+	objectPath, ok := s.StorageService.ObjectKey(resource.URL)
+	if !ok {
+		// 外部地址（如历史数据中的完整 URL）无法签名，原样返回
+		return resource.URL, nil
+	}
+	return s.GeneratePresignedURL(ctx, objectPath, expiry)
+}
+
+// CreateVideoResource 将视频挂到模块下。视频由上传接口上传时已生成一条未挂模块的记录并保存了探测到的元数据，
+// 此时直接认领该记录而不是另建一条，使分辨率、编码等元数据及后台重新探测的结果在之后的读取中可见
+func (s *ContentService) CreateVideoResource(resource *model.Resource) error {
+	uploaded, err := s.ResourceRepo.FindUnattachedVideoByURL(resource.URL)
+	if err != nil {
+		return err
+	}
+	if uploaded == nil {
+		return s.ResourceRepo.Create(resource)
+	}
+
+	updates := map[string]interface{}{
+		"module_id":   resource.ModuleID,
+		"module_type": resource.ModuleType,
+		"title":       resource.Title,
+		"description": resource.Description,
+		"points":      resource.Points,
+	}
+	if resource.Thumbnail != "" {
+		updates["thumbnail"] = resource.Thumbnail
+	}
+	if uploaded.Duration == 0 && resource.Duration > 0 {
+		updates["duration"] = resource.Duration
+	}
+	if err := s.ResourceRepo.UpdateFields(uploaded.ID, model.Video, updates); err != nil {
+		return err
+	}
+	saved, err := s.ResourceRepo.FindByID(uploaded.ID)
+	if err != nil {
+		return err
+	}
+	*resource = *saved
+	return nil
+}
+
 func (s *ContentService) UpdateResource(id uint, resourceType model.ResourceType, updates map[string]interface{}) error {
 	return s.ResourceRepo.UpdateFields(id, resourceType, updates)
 }
@@ -389,21 +975,57 @@ func (s *ContentService) DeleteResource(id uint, resourceType model.ResourceType
 	return s.ResourceRepo.DeleteByType(id, resourceType)
 }
 
-// processVideoMetadata 处理视频元数据（时长和封面）
-func (s *ContentService) processVideoMetadata(ctx context.Context, videoURL, localPath, originalFilename string) (float64, string) {
-	// 1. 获取视频时长
-	var duration float64
-	if s.Cfg.Storage.Type == util.StorageOSS {
-		duration = s.getVideoDurationFromOSS(videoURL)
+// enqueueTranscode 登记多分辨率转码任务，失败只记录日志，不影响原视频的上传结果
+func (s *ContentService) enqueueTranscode(resourceID uint, localPath string) {
+	if s.Transcoder == nil {
+		return
 	}
+	if err := s.Transcoder.Enqueue(resourceID, localPath); err != nil {
+		logger.Log.Warn("登记视频转码任务失败", zap.Uint("resourceID", resourceID), zap.Error(err))
+	}
+}
+
+// videoMetadata 上传时获取的视频元数据，Probed 为 false 表示 FFmpeg 探测失败，需由后台任务重新探测
+type videoMetadata struct {
+	Info      util.VideoInfo
+	Probed    bool
+	Thumbnail string
+}
+
+// apply 将元数据写入资源，Size/Format 以上传文件为准不覆盖
+func (m videoMetadata) apply(r *model.Resource) {
+	r.Duration = m.Info.Duration
+	r.Width = m.Info.Width
+	r.Height = m.Info.Height
+	r.Codec = m.Info.Codec
+	r.Bitrate = m.Info.Bitrate
+	r.Thumbnail = m.Thumbnail
+	if m.Probed {
+		r.ProbeStatus = model.ResourceSuccess
+	} else {
+		r.ProbeStatus = model.ResourcePending
+	}
+}
 
-	// 如果不是 OSS 或 OSS 获取失败，尝试使用本地 FFmpeg
-	if duration == 0 && localPath != "" {
-		if videoInfo, err := util.GetVideoInfo(localPath); err == nil {
-			duration = videoInfo.Duration
+// processVideoMetadata 处理视频元数据（时长、分辨率、编码和封面）
+func (s *ContentService) processVideoMetadata(ctx context.Context, videoURL, localPath, originalFilename string) videoMetadata {
+	var meta videoMetadata
+
+	// 1. 使用本地 FFmpeg 探测视频信息
+	if localPath != "" {
+		if videoInfo, err := s.probeVideo(ctx, localPath); err == nil {
+			meta.Info = *videoInfo
+			meta.Probed = true
+		} else {
+			logger.FromContext(ctx).Warn("探测视频信息失败，稍后重试", zap.String("file", originalFilename), zap.Error(err))
 		}
 	}
 
+	// OSS 可通过媒体处理获取时长，作为探测失败时的补充
+	if meta.Info.Duration == 0 && s.Cfg.Storage.Type == util.StorageOSS {
+		meta.Info.Duration = s.getVideoDurationFromOSS(videoURL)
+	}
+
 	// 2. 生成封面图
 	var thumbnailURL string
 	if s.Cfg.Storage.Type == util.StorageOSS {
@@ -417,18 +1039,163 @@ func (s *ContentService) processVideoMetadata(ctx context.Context, videoURL, loc
 		os.MkdirAll(thumbnailDir, 0755)
 		thumbnailPath := filepath.Join(thumbnailDir, filepath.Base(thumbnailFilename))
 
-		if err := util.GenerateThumbnail(localPath, thumbnailPath, "3"); err == nil {
-			thumbnailURL, _ = s.StorageService.UploadFile(ctx, thumbnailFilename, thumbnailPath, "image/jpeg")
-			os.Remove(thumbnailPath)
+		// 部分编码的视频在指定时间点解码失败或时长不足，依次尝试更早的时间点
+		for _, offset := range s.thumbnailOffsets() {
+			err := s.FFmpeg.Do(ctx, func() error {
+				return util.GenerateThumbnail(localPath, thumbnailPath, offset)
+			})
+			if err == nil {
+				thumbnailURL, _ = s.StorageService.UploadFile(ctx, thumbnailFilename, thumbnailPath, "image/jpeg")
+				break
+			}
+			fields := []zap.Field{zap.String("file", originalFilename), zap.String("offset", offset), zap.Error(err)}
+			var ffErr *util.FFmpegError
+			if errors.As(err, &ffErr) {
+				fields = append(fields, zap.String("command", ffErr.Command), zap.String("stderr", ffErr.Stderr))
+			}
+			logger.FromContext(ctx).Warn("生成视频封面失败", fields...)
 		}
+		os.Remove(thumbnailPath)
 	}
 
 	// 如果所有截图方案都失败，使用默认占位图
 	if thumbnailURL == "" {
-		thumbnailURL = s.StorageService.GetURL("thumbnails/default-video-thumbnail.jpg")
+		thumbnailURL = s.DefaultThumbnailURL()
+	}
+	meta.Thumbnail = thumbnailURL
+
+	return meta
+}
+
+const (
+	videoProbeMaxAttempts = 5
+	videoProbeBatchSize   = 10
+)
+
+// ReprobePendingVideos 重新探测上传时未能获取元数据的视频，多次失败后标记为 failed 不再重试
+func (s *ContentService) ReprobePendingVideos() {
+	resources, err := s.ResourceRepo.FindPendingProbes(videoProbeBatchSize)
+	if err != nil {
+		logger.Log.Error("查询待探测视频失败", zap.Error(err))
+		return
 	}
+	for _, r := range resources {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		updates := s.reprobeVideo(ctx, &r)
+		cancel()
+		if err := s.ResourceRepo.UpdateFields(r.ID, model.Video, updates); err != nil {
+			logger.Log.Error("更新视频元数据失败", zap.Uint("resourceID", r.ID), zap.Error(err))
+		}
+	}
+}
+
+// probeVideo 在 FFmpeg 并发名额内获取视频信息
+func (s *ContentService) probeVideo(ctx context.Context, source string) (*util.VideoInfo, error) {
+	var info *util.VideoInfo
+	err := s.FFmpeg.Do(ctx, func() error {
+		var err error
+		info, err = util.GetVideoInfo(source)
+		return err
+	})
+	return info, err
+}
+
+// reprobeVideo 探测单个视频，返回需更新的字段
+func (s *ContentService) reprobeVideo(ctx context.Context, r *model.Resource) map[string]interface{} {
+	attempts := r.ProbeAttempts + 1
+	updates := map[string]interface{}{"probe_attempts": attempts}
+
+	source, err := s.videoProbeSource(ctx, r.URL)
+	var info *util.VideoInfo
+	if err == nil {
+		info, err = s.probeVideo(ctx, source)
+	}
+	if err != nil {
+		logger.Log.Warn("重新探测视频信息失败",
+			zap.Uint("resourceID", r.ID),
+			zap.Int("attempts", attempts),
+			zap.Error(err))
+		if attempts >= videoProbeMaxAttempts {
+			updates["probe_status"] = model.ResourceFailed
+		}
+		return updates
+	}
+
+	updates["probe_status"] = model.ResourceSuccess
+	updates["width"] = info.Width
+	updates["height"] = info.Height
+	updates["codec"] = info.Codec
+	updates["bitrate"] = info.Bitrate
+	if info.Duration > 0 {
+		updates["duration"] = info.Duration
+	}
+	return updates
+}
 
-	return duration, thumbnailURL
+// videoProbeSource 返回 FFmpeg 可读取的视频地址：本地存储为文件路径，对象存储为限时访问地址
+func (s *ContentService) videoProbeSource(ctx context.Context, videoURL string) (string, error) {
+	key, ok := s.StorageService.ObjectKey(videoURL)
+	if !ok {
+		return "", fmt.Errorf("无法解析视频存储路径: %s", videoURL)
+	}
+	if _, isLocal := s.StorageService.Provider.(*LocalStorageProvider); isLocal {
+		return filepath.Join(s.Cfg.Storage.LocalPath, key), nil
+	}
+	return s.StorageService.PresignedURL(ctx, key, 10*time.Minute)
+}
+
+func (s *ContentService) thumbnailOffsets() []string {
+	if len(s.Cfg.Storage.ThumbnailOffsets) > 0 {
+		return s.Cfg.Storage.ThumbnailOffsets
+	}
+	return []string{"3", "0"}
+}
+
+// defaultThumbnailKey 返回默认封面在存储中的对象路径，配置为完整 URL 时返回 false
+func (s *ContentService) defaultThumbnailKey() (string, bool) {
+	thumb := s.Cfg.Storage.DefaultThumbnail
+	if thumb == "" {
+		return "thumbnails/default-video-thumbnail.jpg", true
+	}
+	if strings.HasPrefix(thumb, "http://") || strings.HasPrefix(thumb, "https://") || strings.HasPrefix(thumb, "/") {
+		return "", false
+	}
+	return thumb, true
+}
+
+// DefaultThumbnailURL 视频封面截取失败时使用的占位图地址
+func (s *ContentService) DefaultThumbnailURL() string {
+	if key, ok := s.defaultThumbnailKey(); ok {
+		return s.StorageService.GetURL(key)
+	}
+	return s.Cfg.Storage.DefaultThumbnail
+}
+
+// EnsureDefaultThumbnail 启动时检查默认封面是否存在，缺失时生成一张灰色占位图上传，避免封面地址失效
+func (s *ContentService) EnsureDefaultThumbnail(ctx context.Context) error {
+	key, ok := s.defaultThumbnailKey()
+	if !ok {
+		return nil
+	}
+	exists, err := s.StorageService.Exists(ctx, key)
+	if err != nil {
+		return fmt.Errorf("检查默认封面失败: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 640, 360))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: 0xD9, G: 0xD9, B: 0xD9, A: 0xFF}}, image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		return err
+	}
+	if _, err := s.StorageService.Upload(ctx, key, &buf, int64(buf.Len()), "image/jpeg"); err != nil {
+		return fmt.Errorf("上传默认封面失败: %w", err)
+	}
+	logger.Log.Info("已生成默认视频封面", zap.String("key", key))
+	return nil
 }
 
 // getVideoDurationFromOSS 从阿里云OSS获取视频时长（带重试逻辑，解决IMM索引延迟）
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"coder_edu_backend/pkg/logger"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 进程内事件主题
+const (
+	EventGoalCompleted         = "goal.completed"
+	EventAttemptGraded         = "level.attempt_graded"
+	EventFriendRequestSent     = "friend.request_sent"
+	EventUserMentioned         = "chat.user_mentioned"
+	EventSubmissionAudited     = "knowledge_point.submission_audited"
+	EventAnnouncementPublished = "announcement.published"
+	EventGoalDeadline          = "goal.deadline_approaching"
+	EventReflectionCommented   = "reflection.commented"
+	EventUserDisabled          = "user.disabled"
+)
+
+// GoalCompletedEvent 目标首次达成 100% 时发布
+type GoalCompletedEvent struct {
+	UserID uint
+	GoalID uint
+	Title  string
+}
+
+// AttemptGradedEvent 关卡挑战完成人工评分时发布
+type AttemptGradedEvent struct {
+	UserID    uint
+	LevelID   uint
+	AttemptID uint
+	Score     int
+	Success   bool
+}
+
+// GoalDeadlineEvent 未完成的学习目标临近截止时发布，每个提醒阈值只发布一次
+type GoalDeadlineEvent struct {
+	UserID     uint
+	GoalID     uint
+	Title      string
+	TargetDate time.Time
+	DaysLeft   int
+}
+
+// FriendRequestSentEvent 新的好友申请创建时发布（对方已申请而直接成为好友时不发布）
+type FriendRequestSentEvent struct {
+	RequestID  string
+	SenderID   uint
+	SenderName string
+	ReceiverID uint
+	Message    string
+}
+
+// UserMentionedEvent 群聊消息 @ 了其他成员时发布
+type UserMentionedEvent struct {
+	UserIDs          []uint
+	SenderID         uint
+	SenderName       string
+	ConversationID   string
+	ConversationName string
+	MessageID        string
+	Preview          string
+}
+
+// SubmissionAuditedEvent 知识点测试提交被老师审核后发布
+type SubmissionAuditedEvent struct {
+	UserID           uint
+	SubmissionID     string
+	KnowledgePointID string
+	Status           string // approved/rejected
+	Score            int
+}
+
+// AnnouncementPublishedEvent 教师发布公告后发布
+type AnnouncementPublishedEvent struct {
+	AnnouncementID uint
+	Title          string
+	TeacherName    string
+	UserIDs        []uint
+}
+
+// ReflectionCommentedEvent 老师反馈或学生回复反思时发布，RecipientIDs 为需要通知的另一方
+type ReflectionCommentedEvent struct {
+	ReflectionID string
+	CommentID    uint
+	AuthorID     uint
+	AuthorName   string
+	RecipientIDs []uint
+	Preview      string
+}
+
+// UserDisabledEvent 管理员禁用或删除用户后发布，用于断开该用户仍在线的连接
+type UserDisabledEvent struct {
+	UserID uint
+}
+
+// EventHandler 事件处理函数，payload 的具体类型由主题约定
+type EventHandler func(payload interface{}) error
+
+// EventBus 简单的进程内事件分发器，让成就、积分、聊天等模块订阅业务事件而不互相依赖。
+// 处理函数同步执行，单个订阅者出错或 panic 只记录日志，不影响发布方和其他订阅者
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]EventHandler
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{handlers: make(map[string][]EventHandler)}
+}
+
+func (b *EventBus) Subscribe(topic string, handler EventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[topic] = append(b.handlers[topic], handler)
+}
+
+func (b *EventBus) Publish(topic string, payload interface{}) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	handlers := b.handlers[topic]
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		b.dispatch(topic, h, payload)
+	}
+}
+
+func (b *EventBus) dispatch(topic string, h EventHandler, payload interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Log.Error("Event handler panic", zap.String("topic", topic), zap.Any("panic", r))
+		}
+	}()
+	if err := h(payload); err != nil {
+		logger.Log.Error("Event handler failed", zap.String("topic", topic), zap.Error(err))
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+
+	"coder_edu_backend/pkg/monitoring"
+)
+
+// FFmpegLimiter 限制同时运行的 FFmpeg 进程数，上传处理和后台转码共用，超出上限的任务排队等待
+type FFmpegLimiter struct {
+	sem chan struct{}
+}
+
+func NewFFmpegLimiter(concurrency int) *FFmpegLimiter {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &FFmpegLimiter{sem: make(chan struct{}, concurrency)}
+}
+
+// Do 获取执行名额后运行 fn；排队期间 ctx 结束则放弃并返回 ctx.Err()
+func (l *FFmpegLimiter) Do(ctx context.Context, fn func() error) error {
+	monitoring.FFmpegJobsQueued.Inc()
+	select {
+	case l.sem <- struct{}{}:
+		monitoring.FFmpegJobsQueued.Dec()
+	case <-ctx.Done():
+		monitoring.FFmpegJobsQueued.Dec()
+		return ctx.Err()
+	}
+	monitoring.FFmpegJobsRunning.Inc()
+	defer func() {
+		monitoring.FFmpegJobsRunning.Dec()
+		<-l.sem
+	}()
+	return fn()
+}
@@ -1,21 +1,79 @@
 package service
 
 import (
+	"coder_edu_backend/internal/config"
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
+	"coder_edu_backend/pkg/logger"
+	"context"
 	"errors"
+	"time"
+
+	"go.uber.org/zap"
 )
 
 type FriendshipService struct {
 	FriendRepo *repository.FriendshipRepository
 	UserRepo   *repository.UserRepository
+	Events     *EventBus
+	Cfg        config.ChatConfig
 }
 
-func NewFriendshipService(friendRepo *repository.FriendshipRepository, userRepo *repository.UserRepository) *FriendshipService {
+func NewFriendshipService(friendRepo *repository.FriendshipRepository, userRepo *repository.UserRepository, events *EventBus, cfg config.ChatConfig) *FriendshipService {
 	return &FriendshipService{
 		FriendRepo: friendRepo,
 		UserRepo:   userRepo,
+		Events:     events,
+		Cfg:        cfg,
+	}
+}
+
+// checkFriendLimit 好友数已达上限时返回 limitErr
+func (s *FriendshipService) checkFriendLimit(userID uint, limitErr error) error {
+	if s.Cfg.MaxFriends <= 0 {
+		return nil
+	}
+	count, err := s.FriendRepo.CountFriends(userID)
+	if err != nil {
+		return err
+	}
+	if count >= int64(s.Cfg.MaxFriends) {
+		return limitErr
+	}
+	return nil
+}
+
+// checkSendLimits 检查发送方的好友上限、待处理申请上限和发送频率
+func (s *FriendshipService) checkSendLimits(ctx context.Context, senderID uint) error {
+	if err := s.checkFriendLimit(senderID, util.ErrFriendLimitReached); err != nil {
+		return err
+	}
+	if s.Cfg.MaxPendingFriendRequests > 0 {
+		pending, err := s.FriendRepo.CountPendingSent(senderID)
+		if err != nil {
+			return err
+		}
+		if pending >= int64(s.Cfg.MaxPendingFriendRequests) {
+			return util.ErrTooManyPendingRequests
+		}
 	}
+	if s.Cfg.FriendRequestRateLimit > 0 {
+		window := time.Duration(s.Cfg.FriendRequestRateWindowMinutes) * time.Minute
+		if window <= 0 {
+			window = time.Hour
+		}
+		count, err := s.FriendRepo.IncrRequestRate(senderID, window)
+		if err != nil {
+			// Redis 不可用时放行，不影响正常加好友
+			logger.FromContext(ctx).Warn("好友申请频率计数失败", zap.Uint("userID", senderID), zap.Error(err))
+			return nil
+		}
+		if count > int64(s.Cfg.FriendRequestRateLimit) {
+			return util.ErrFriendRequestRateLimit
+		}
+	}
+	return nil
 }
 
 func (s *FriendshipService) SearchUserByEmail(email string) (*model.User, error) {
@@ -38,7 +96,7 @@ func (s *FriendshipService) FuzzySearchUsers(query string) ([]model.User, error)
 	return users, err
 }
 
-func (s *FriendshipService) SendFriendRequest(senderID uint, receiverID uint, message string) error {
+func (s *FriendshipService) SendFriendRequest(ctx context.Context, senderID uint, receiverID uint, message string) error {
 	if senderID == receiverID {
 		return errors.New("不能添加自己为好友")
 	}
@@ -57,13 +115,26 @@ func (s *FriendshipService) SendFriendRequest(senderID uint, receiverID uint, me
 		return s.HandleFriendRequest(reciprocalReq.ID, senderID, true)
 	}
 
+	if err := s.checkSendLimits(ctx, senderID); err != nil {
+		return err
+	}
+
 	req := &model.FriendRequest{
 		SenderID:   senderID,
 		ReceiverID: receiverID,
 		Message:    message,
 		Status:     "pending",
 	}
-	return s.FriendRepo.CreateRequest(req)
+	if err := s.FriendRepo.CreateRequest(req); err != nil {
+		return err
+	}
+
+	evt := FriendRequestSentEvent{RequestID: req.ID, SenderID: senderID, ReceiverID: receiverID, Message: message}
+	if sender, err := s.UserRepo.FindByID(senderID); err == nil {
+		evt.SenderName = sender.Name
+	}
+	s.Events.Publish(EventFriendRequestSent, evt)
+	return nil
 }
 
 func (s *FriendshipService) HandleFriendRequest(requestID string, receiverID uint, accept bool) error {
@@ -81,6 +152,14 @@ func (s *FriendshipService) HandleFriendRequest(requestID string, receiverID uin
 	}
 
 	if accept {
+		// 任意一方好友已满时不能同意，申请保持待处理
+		if err := s.checkFriendLimit(req.ReceiverID, util.ErrFriendLimitReached); err != nil {
+			return err
+		}
+		if err := s.checkFriendLimit(req.SenderID, util.ErrPeerFriendLimitReached); err != nil {
+			return err
+		}
+
 		// 1. 更新当前申请状态
 		err = s.FriendRepo.UpdateRequestStatus(requestID, "accepted")
 		if err != nil {
@@ -110,8 +189,8 @@ func (s *FriendshipService) HandleFriendRequest(requestID string, receiverID uin
 	}
 }
 
-func (s *FriendshipService) GetFriends(userID uint, query string) ([]model.User, error) {
-	return s.FriendRepo.GetFriends(userID, query)
+func (s *FriendshipService) GetFriends(userID uint, query string, limit, offset int) ([]model.User, int64, error) {
+	return s.FriendRepo.GetFriends(userID, query, limit, offset)
 }
 
 func (s *FriendshipService) GetFriendRequests(userID uint, query string, limit, offset int) ([]model.FriendRequest, int64, error) {
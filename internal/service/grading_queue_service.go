@@ -0,0 +1,91 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	GradingModuleLevel          = "level"
+	GradingModuleKnowledgePoint = "knowledge_point"
+	GradingModuleAssessment     = "assessment"
+	GradingModulePostClassTest  = "post_class_test"
+)
+
+// GradingQueueService 汇总各模块等待教师人工批改的记录。
+// 迁移任务全部由系统自动判分，没有人工批改环节，因此不在汇总范围内
+type GradingQueueService struct {
+	DB *gorm.DB
+}
+
+func NewGradingQueueService(db *gorm.DB) *GradingQueueService {
+	return &GradingQueueService{DB: db}
+}
+
+// PendingGradingModule 单个模块的待批改情况
+type PendingGradingModule struct {
+	Module          string          `json:"module"`
+	Count           int64           `json:"count"`
+	OldestPendingAt *model.JSONTime `json:"oldestPendingAt,omitempty"` // 最早一条待批改记录的提交时间
+}
+
+// PendingGradingSummary 教师待批改总览
+type PendingGradingSummary struct {
+	Total   int64                  `json:"total"`
+	Modules []PendingGradingModule `json:"modules"`
+}
+
+type pendingAggregate struct {
+	Count  int64
+	Oldest *time.Time
+}
+
+// PendingSummary 统计教师可批改的待批改记录数量及最早提交时间。
+// 关卡和课后测试只统计该教师创建的，管理员统计全部；知识点和摸底评估没有归属教师，所有教师都可批改
+func (s *GradingQueueService) PendingSummary(teacherID uint, role model.UserRole) (*PendingGradingSummary, error) {
+	scoped := func(q *gorm.DB, column string) *gorm.DB {
+		if role == model.Admin {
+			return q
+		}
+		return q.Where(column+" = ?", teacherID)
+	}
+
+	queries := []struct {
+		module string
+		query  *gorm.DB
+	}{
+		{GradingModuleLevel, scoped(s.DB.Table("level_attempts la").
+			Select("COUNT(*) AS count, MIN(la.ended_at) AS oldest").
+			Joins("JOIN levels l ON l.id = la.level_id AND l.deleted_at IS NULL").
+			Where("la.needs_manual = ? AND la.ended_at IS NOT NULL AND la.deleted_at IS NULL", true), "l.creator_id")},
+		{GradingModuleKnowledgePoint, s.DB.Table("knowledge_point_submissions kps").
+			Select("COUNT(*) AS count, MIN(kps.created_at) AS oldest").
+			Joins("JOIN knowledge_points kp ON kp.id = kps.knowledge_point_id AND kp.deleted_at IS NULL").
+			Where("kps.status = ?", "pending")},
+		// 重测提交会把状态重置为 pending 并更新 updated_at，以此作为提交时间
+		{GradingModuleAssessment, s.DB.Table("assessment_submissions").
+			Select("COUNT(*) AS count, MIN(updated_at) AS oldest").
+			Where("status = ? AND deleted_at IS NULL", "pending")},
+		{GradingModulePostClassTest, scoped(s.DB.Table("post_class_test_submissions pts").
+			Select("COUNT(*) AS count, MIN(pts.completed_at) AS oldest").
+			Joins("JOIN post_class_tests t ON t.id = pts.test_id AND t.deleted_at IS NULL").
+			Where("pts.needs_manual = ? AND pts.deleted_at IS NULL", true), "t.creator_id")},
+	}
+
+	summary := &PendingGradingSummary{Modules: make([]PendingGradingModule, 0, len(queries))}
+	for _, q := range queries {
+		var agg pendingAggregate
+		if err := q.query.Scan(&agg).Error; err != nil {
+			return nil, err
+		}
+		summary.Modules = append(summary.Modules, PendingGradingModule{
+			Module:          q.module,
+			Count:           agg.Count,
+			OldestPendingAt: model.NewJSONTimePtr(agg.Oldest),
+		})
+		summary.Total += agg.Count
+	}
+	return summary, nil
+}
@@ -2,7 +2,10 @@ package service
 
 import (
 	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -12,11 +15,12 @@ import (
 )
 
 type KnowledgePointService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	events *EventBus
 }
 
-func NewKnowledgePointService(db *gorm.DB) *KnowledgePointService {
-	return &KnowledgePointService{db: db}
+func NewKnowledgePointService(db *gorm.DB, events *EventBus) *KnowledgePointService {
+	return &KnowledgePointService{db: db, events: events}
 }
 
 type CreateVideoResourceRequest struct {
@@ -37,15 +41,17 @@ type CreateExerciseRequest struct {
 }
 
 type CreateKnowledgePointRequest struct {
-	Title           string                       `json:"title" binding:"required"`
-	Description     string                       `json:"description"`
-	Type            model.KnowledgePointType     `json:"type" binding:"required"`
-	ArticleContent  string                       `json:"articleContent"`
-	TimeLimit       int                          `json:"timeLimit"`
-	Order           int                          `json:"order"`
-	CompletionScore int                          `json:"completionScore"`
-	Videos          []CreateVideoResourceRequest `json:"videos"`
-	Exercises       []CreateExerciseRequest      `json:"exercises"`
+	Title              string                       `json:"title" binding:"required"`
+	Description        string                       `json:"description"`
+	Type               model.KnowledgePointType     `json:"type" binding:"required"`
+	ArticleContent     string                       `json:"articleContent"`
+	TimeLimit          int                          `json:"timeLimit"`
+	Order              int                          `json:"order"`
+	CompletionScore    int                          `json:"completionScore"`
+	AnswerReveal       string                       `json:"answerReveal"` // always/after_submission/after_delay/never
+	RevealDelayMinutes int                          `json:"revealDelayMinutes"`
+	Videos             []CreateVideoResourceRequest `json:"videos"`
+	Exercises          []CreateExerciseRequest      `json:"exercises"`
 }
 
 type ExerciseSubmissionItem struct {
@@ -62,6 +68,38 @@ type SubmitKnowledgePointExercisesRequest struct {
 	Duration         int                      `json:"duration"`     // 答题时长（秒）
 }
 
+// KnowledgePointExerciseResult 单道练习的判定结果，序列化后保存在 KnowledgePointSubmission.Details 中
+type KnowledgePointExerciseResult struct {
+	ExerciseID      string `json:"exerciseId"`
+	Question        string `json:"question"`
+	Type            string `json:"type"`
+	UserAnswer      string `json:"userAnswer"`
+	CorrectAnswer   string `json:"correctAnswer,omitempty"` // 未满足答案公开策略时不返回
+	Code            string `json:"code,omitempty"`
+	ExecutionResult string `json:"executionResult,omitempty"`
+	IsCorrect       bool   `json:"isCorrect"`
+	Points          int    `json:"points"`
+}
+
+// KnowledgePointSubmissionResult 解析后的知识点测试提交详情
+type KnowledgePointSubmissionResult struct {
+	ID                  string                         `json:"id"`
+	UserID              uint                           `json:"userId"`
+	UserName            string                         `json:"userName"`
+	KnowledgePointID    string                         `json:"knowledgePointId"`
+	KnowledgePointTitle string                         `json:"knowledgePointTitle"`
+	Status              string                         `json:"status"`
+	Score               int                            `json:"score"` // 最终得分（老师审核时可能已手动调整）
+	CorrectCount        int                            `json:"correctCount"`
+	QuestionCount       int                            `json:"questionCount"`
+	IsAutoSubmit        bool                           `json:"isAutoSubmit"`
+	Duration            int                            `json:"duration"`
+	StartedAt           model.JSONTime                 `json:"startedAt"`
+	CreatedAt           model.JSONTime                 `json:"createdAt"`
+	AnswerRevealed      bool                           `json:"answerRevealed"` // 是否包含正确答案
+	Results             []KnowledgePointExerciseResult `json:"results"`
+}
+
 type KnowledgePointStudentResponse struct {
 	ID              string                   `json:"id"`
 	Title           string                   `json:"title"`
@@ -284,14 +322,28 @@ func (s *KnowledgePointService) GetKnowledgePointForStudent(id string, userID ui
 		// 如果已提交待审核或已通过，则返回提交详情
 		if submission.Status == "pending" || submission.Status == "approved" {
 			isSubmitted = true
-			var details []interface{}
-			if err := json.Unmarshal([]byte(submission.Details), &details); err == nil {
+			if details, err := parseSubmissionDetails(submission.Details); err == nil {
+				if !knowledgePointAnswerRevealed(&kp, &submission, time.Now()) {
+					details = hideCorrectAnswers(details)
+				}
 				submissionDetails = details
 			}
-			startTime = submission.StartedAt
+			startTime = submission.StartedAt.Time
 		} else if submission.Status == "draft" {
 			// 如果是进行中的草稿，返回其开始时间供前端恢复倒计时
-			startTime = submission.StartedAt
+			startTime = submission.StartedAt.Time
+		}
+	}
+
+	// 未满足公开条件时不下发练习的标准答案和解析
+	var revealSub *model.KnowledgePointSubmission
+	if isSubmitted {
+		revealSub = &submission
+	}
+	if !knowledgePointAnswerRevealed(&kp, revealSub, time.Now()) {
+		for i := range kp.Exercises {
+			kp.Exercises[i].Answer = ""
+			kp.Exercises[i].Explanation = ""
 		}
 	}
 
@@ -311,7 +363,7 @@ func (s *KnowledgePointService) StartExercises(userID uint, id string) (time.Tim
 
 	// 2. 如果已经有记录且不是被驳回的状态，则直接返回原有的开始时间（防止重复点按钮重置时间）
 	if err == nil && existing.Status != "rejected" {
-		return existing.StartedAt, nil
+		return existing.StartedAt.Time, nil
 	}
 
 	// 3. 真正的开启计时逻辑：创建草稿记录
@@ -321,8 +373,8 @@ func (s *KnowledgePointService) StartExercises(userID uint, id string) (time.Tim
 		UserID:           userID,
 		KnowledgePointID: id,
 		Status:           "draft",
-		StartedAt:        startTime,
-		CreatedAt:        startTime,
+		StartedAt:        model.NewJSONTime(startTime),
+		CreatedAt:        model.NewJSONTime(startTime),
 	}
 
 	if err := s.db.Create(&newDraft).Error; err != nil {
@@ -339,19 +391,7 @@ func (s *KnowledgePointService) SubmitExercises(userID uint, req SubmitKnowledge
 
 	totalScore := 0
 
-	type DetailedResult struct {
-		ExerciseID      string `json:"exerciseId"`
-		Question        string `json:"question"`
-		Type            string `json:"type"`
-		UserAnswer      string `json:"userAnswer"`
-		CorrectAnswer   string `json:"correctAnswer"`
-		Code            string `json:"code,omitempty"`
-		ExecutionResult string `json:"executionResult,omitempty"`
-		IsCorrect       bool   `json:"isCorrect"`
-		Points          int    `json:"points"`
-	}
-
-	detailedResults := make([]DetailedResult, 0)
+	detailedResults := make([]KnowledgePointExerciseResult, 0)
 	submissionMap := make(map[string]ExerciseSubmissionItem)
 	for _, sub := range req.Submissions {
 		submissionMap[sub.ExerciseID] = sub
@@ -376,7 +416,7 @@ func (s *KnowledgePointService) SubmitExercises(userID uint, req SubmitKnowledge
 			totalScore += points
 		}
 
-		detailedResults = append(detailedResults, DetailedResult{
+		detailedResults = append(detailedResults, KnowledgePointExerciseResult{
 			ExerciseID:      ex.ID,
 			Question:        ex.Question,
 			Type:            string(ex.Type),
@@ -399,7 +439,7 @@ func (s *KnowledgePointService) SubmitExercises(userID uint, req SubmitKnowledge
 			ID:               uuid.New().String(),
 			UserID:           userID,
 			KnowledgePointID: req.KnowledgePointID,
-			StartedAt:        time.Now(),
+			StartedAt:        model.NewJSONTime(time.Now()),
 		}
 	}
 
@@ -411,14 +451,19 @@ func (s *KnowledgePointService) SubmitExercises(userID uint, req SubmitKnowledge
 	if req.Duration > 0 {
 		submission.Duration = req.Duration
 	} else {
-		submission.Duration = int(time.Since(submission.StartedAt).Seconds())
+		submission.Duration = int(time.Since(submission.StartedAt.Time).Seconds())
 	}
-	submission.CreatedAt = time.Now()
+	submission.CreatedAt = model.NewJSONTime(time.Now())
 
 	if err := s.db.Save(&submission).Error; err != nil {
 		return nil, err
 	}
 
+	// 按答案公开策略决定是否在判定结果中返回正确答案，Details 中始终保存完整结果供老师审核
+	if !knowledgePointAnswerRevealed(&kp, &submission, time.Now()) {
+		detailedResults = hideCorrectAnswers(detailedResults)
+	}
+
 	return map[string]interface{}{
 		"score":   totalScore,
 		"results": detailedResults,
@@ -432,26 +477,21 @@ func (s *KnowledgePointService) RecordLearningTime(userID uint, id string, durat
 		return err
 	}
 
-	log := &model.LearningLog{
-		UserID:   userID,
-		Activity: "knowledge_point",
-		Content:  fmt.Sprintf("学习了知识点: %s", kp.Title),
-		Duration: duration,
-	}
-
-	return s.db.Create(log).Error
+	return recordLearningTime(repository.NewLearningLogRepository(s.db), userID, "knowledge_point", kp.ID, fmt.Sprintf("学习了知识点: %s", kp.Title), duration)
 }
 
 func (s *KnowledgePointService) CreateKnowledgePoint(req CreateKnowledgePointRequest) (*model.KnowledgePoint, error) {
 	kp := &model.KnowledgePoint{
-		ID:              uuid.New().String(),
-		Title:           req.Title,
-		Description:     req.Description,
-		Type:            req.Type,
-		ArticleContent:  req.ArticleContent,
-		TimeLimit:       req.TimeLimit,
-		Order:           req.Order,
-		CompletionScore: req.CompletionScore,
+		ID:                 uuid.New().String(),
+		Title:              req.Title,
+		Description:        req.Description,
+		Type:               req.Type,
+		ArticleContent:     req.ArticleContent,
+		TimeLimit:          req.TimeLimit,
+		Order:              req.Order,
+		CompletionScore:    req.CompletionScore,
+		AnswerReveal:       req.AnswerReveal,
+		RevealDelayMinutes: req.RevealDelayMinutes,
 	}
 
 	err := s.db.Transaction(func(tx *gorm.DB) error {
@@ -524,13 +564,15 @@ func (s *KnowledgePointService) UpdateKnowledgePoint(id string, req CreateKnowle
 
 	err := s.db.Transaction(func(tx *gorm.DB) error {
 		updates := map[string]interface{}{
-			"title":            req.Title,
-			"description":      req.Description,
-			"type":             req.Type,
-			"article_content":  req.ArticleContent,
-			"time_limit":       req.TimeLimit,
-			"order":            req.Order,
-			"completion_score": req.CompletionScore,
+			"title":                req.Title,
+			"description":          req.Description,
+			"type":                 req.Type,
+			"article_content":      req.ArticleContent,
+			"time_limit":           req.TimeLimit,
+			"order":                req.Order,
+			"completion_score":     req.CompletionScore,
+			"answer_reveal":        req.AnswerReveal,
+			"reveal_delay_minutes": req.RevealDelayMinutes,
 		}
 		if err := tx.Model(&kp).Updates(updates).Error; err != nil {
 			return err
@@ -623,14 +665,14 @@ func (s *KnowledgePointService) DeleteKnowledgePoint(id string) error {
 }
 
 type SubmissionListResponse struct {
-	ID                  string    `json:"id"`
-	UserID              uint      `json:"userId"`
-	UserName            string    `json:"userName"`
-	KnowledgePointID    string    `json:"knowledgePointId"`
-	KnowledgePointTitle string    `json:"knowledgePointTitle"`
-	Score               int       `json:"score"`
-	Status              string    `json:"status"`
-	CreatedAt           time.Time `json:"createdAt"`
+	ID                  string         `json:"id"`
+	UserID              uint           `json:"userId"`
+	UserName            string         `json:"userName"`
+	KnowledgePointID    string         `json:"knowledgePointId"`
+	KnowledgePointTitle string         `json:"knowledgePointTitle"`
+	Score               int            `json:"score"`
+	Status              string         `json:"status"`
+	CreatedAt           model.JSONTime `json:"createdAt"`
 }
 
 func (s *KnowledgePointService) ListSubmissions(kpID string, status string, studentName string, page int, limit int) ([]SubmissionListResponse, int64, error) {
@@ -654,35 +696,64 @@ func (s *KnowledgePointService) ListSubmissions(kpID string, status string, stud
 	}
 
 	var res []SubmissionListResponse
+	if len(students) == 0 {
+		return res, total, nil
+	}
 
-	// 3. 遍历分页后的学生，查询其提交记录
+	// 3. 一次性查询本页学生的所有匹配提交记录，避免逐个学生查询
+	studentIDs := make([]uint, 0, len(students))
 	for _, student := range students {
-		var subs []model.KnowledgePointSubmission
-		db := s.db.Where("user_id = ?", student.ID)
+		studentIDs = append(studentIDs, student.ID)
+	}
+
+	var subs []model.KnowledgePointSubmission
+	db := s.db.Where("user_id IN ?", studentIDs)
+	if kpID != "" {
+		db = db.Where("knowledge_point_id = ?", kpID)
+	}
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	if err := db.Order("created_at DESC").Find(&subs).Error; err != nil {
+		return nil, 0, err
+	}
 
-		if kpID != "" {
-			db = db.Where("knowledge_point_id = ?", kpID)
+	subsByUser := make(map[uint][]model.KnowledgePointSubmission, len(students))
+	kpIDSet := make(map[string]bool)
+	if kpID != "" {
+		kpIDSet[kpID] = true
+	}
+	for _, sub := range subs {
+		subsByUser[sub.UserID] = append(subsByUser[sub.UserID], sub)
+		kpIDSet[sub.KnowledgePointID] = true
+	}
+
+	// 4. 一次性查询涉及的知识点标题
+	titles := make(map[string]string, len(kpIDSet))
+	if len(kpIDSet) > 0 {
+		kpIDs := make([]string, 0, len(kpIDSet))
+		for id := range kpIDSet {
+			kpIDs = append(kpIDs, id)
 		}
-		if status != "" {
-			db = db.Where("status = ?", status)
+		var kps []model.KnowledgePoint
+		if err := s.db.Select("id", "title").Where("id IN ?", kpIDs).Find(&kps).Error; err != nil {
+			return nil, 0, err
 		}
-
-		// 获取该学生匹配条件的提交记录
-		if err := db.Order("created_at DESC").Find(&subs).Error; err != nil {
-			continue
+		for _, kp := range kps {
+			titles[kp.ID] = kp.Title
 		}
+	}
 
-		if len(subs) > 0 {
-			for _, sub := range subs {
-				var kp model.KnowledgePoint
-				s.db.Select("title").First(&kp, "id = ?", sub.KnowledgePointID)
-
+	// 5. 按学生分页顺序在内存中组装结果，未提交的学生补一条 unsubmitted 记录
+	for _, student := range students {
+		if userSubs := subsByUser[student.ID]; len(userSubs) > 0 {
+			for _, sub := range userSubs {
 				res = append(res, SubmissionListResponse{
 					ID:                  sub.ID,
 					UserID:              student.ID,
 					UserName:            student.Name,
 					KnowledgePointID:    sub.KnowledgePointID,
-					KnowledgePointTitle: kp.Title,
+					KnowledgePointTitle: titles[sub.KnowledgePointID],
 					Score:               sub.Score,
 					Status:              sub.Status,
 					CreatedAt:           sub.CreatedAt,
@@ -691,9 +762,7 @@ func (s *KnowledgePointService) ListSubmissions(kpID string, status string, stud
 		} else if status == "" || status == "unsubmitted" {
 			title := "待分配"
 			if kpID != "" {
-				var kp model.KnowledgePoint
-				s.db.Select("title").First(&kp, "id = ?", kpID)
-				title = kp.Title
+				title = titles[kpID]
 			}
 
 			res = append(res, SubmissionListResponse{
@@ -704,7 +773,7 @@ func (s *KnowledgePointService) ListSubmissions(kpID string, status string, stud
 				KnowledgePointTitle: title,
 				Score:               0,
 				Status:              "unsubmitted",
-				CreatedAt:           time.Time{},
+				CreatedAt:           model.JSONTime{},
 			})
 		}
 	}
@@ -720,12 +789,130 @@ func (s *KnowledgePointService) GetSubmissionDetail(id string) (*model.Knowledge
 	return &sub, nil
 }
 
+// GetSubmissionResultForTeacher 老师/管理员查看解析后的提交详情，始终包含正确答案
+func (s *KnowledgePointService) GetSubmissionResultForTeacher(id string) (*KnowledgePointSubmissionResult, error) {
+	sub, kp, err := s.loadSubmissionWithKnowledgePoint(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.buildSubmissionResult(sub, kp, true)
+}
+
+// GetSubmissionResultForStudent 学生查看自己的提交详情，正确答案按知识点的答案公开策略返回
+func (s *KnowledgePointService) GetSubmissionResultForStudent(id string, userID uint) (*KnowledgePointSubmissionResult, error) {
+	sub, kp, err := s.loadSubmissionWithKnowledgePoint(id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.UserID != userID {
+		return nil, util.ErrPermissionDenied
+	}
+	// 草稿只是计时记录，还没有答题结果
+	if sub.Status == "draft" {
+		return nil, util.ErrSubmissionNotFound
+	}
+	return s.buildSubmissionResult(sub, kp, knowledgePointAnswerRevealed(kp, sub, time.Now()))
+}
+
+func (s *KnowledgePointService) loadSubmissionWithKnowledgePoint(id string) (*model.KnowledgePointSubmission, *model.KnowledgePoint, error) {
+	var sub model.KnowledgePointSubmission
+	if err := s.db.First(&sub, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, util.ErrSubmissionNotFound
+		}
+		return nil, nil, err
+	}
+
+	// 知识点可能已被删除，此时标题为空、按默认策略处理
+	var kp model.KnowledgePoint
+	if err := s.db.Unscoped().Select("id", "title", "answer_reveal", "reveal_delay_minutes").
+		First(&kp, "id = ?", sub.KnowledgePointID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+	return &sub, &kp, nil
+}
+
+func (s *KnowledgePointService) buildSubmissionResult(sub *model.KnowledgePointSubmission, kp *model.KnowledgePoint, revealed bool) (*KnowledgePointSubmissionResult, error) {
+	results, err := parseSubmissionDetails(sub.Details)
+	if err != nil {
+		return nil, fmt.Errorf("parse submission details: %w", err)
+	}
+	if !revealed {
+		results = hideCorrectAnswers(results)
+	}
+
+	var user model.User
+	s.db.Select("id", "name").First(&user, sub.UserID)
+
+	correct := 0
+	for _, r := range results {
+		if r.IsCorrect {
+			correct++
+		}
+	}
+
+	return &KnowledgePointSubmissionResult{
+		ID:                  sub.ID,
+		UserID:              sub.UserID,
+		UserName:            user.Name,
+		KnowledgePointID:    sub.KnowledgePointID,
+		KnowledgePointTitle: kp.Title,
+		Status:              sub.Status,
+		Score:               sub.Score,
+		CorrectCount:        correct,
+		QuestionCount:       len(results),
+		IsAutoSubmit:        sub.IsAutoSubmit,
+		Duration:            sub.Duration,
+		StartedAt:           sub.StartedAt,
+		CreatedAt:           sub.CreatedAt,
+		AnswerRevealed:      revealed,
+		Results:             results,
+	}, nil
+}
+
+// parseSubmissionDetails 解析提交记录中保存的判定结果
+func parseSubmissionDetails(details string) ([]KnowledgePointExerciseResult, error) {
+	results := make([]KnowledgePointExerciseResult, 0)
+	if strings.TrimSpace(details) == "" {
+		return results, nil
+	}
+	if err := json.Unmarshal([]byte(details), &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// hideCorrectAnswers 返回去掉正确答案的副本，不修改原切片
+func hideCorrectAnswers(results []KnowledgePointExerciseResult) []KnowledgePointExerciseResult {
+	hidden := make([]KnowledgePointExerciseResult, len(results))
+	for i, r := range results {
+		r.CorrectAnswer = ""
+		hidden[i] = r
+	}
+	return hidden
+}
+
+// knowledgePointAnswerRevealed 判断学生是否可以看到知识点练习的正确答案，sub 为空表示尚未提交
+func knowledgePointAnswerRevealed(kp *model.KnowledgePoint, sub *model.KnowledgePointSubmission, now time.Time) bool {
+	switch kp.AnswerReveal {
+	case model.AnswerRevealAfterSubmission:
+		return sub != nil
+	case model.AnswerRevealAfterDelay:
+		return sub != nil && !now.Before(sub.CreatedAt.Add(time.Duration(kp.RevealDelayMinutes)*time.Minute))
+	case model.AnswerRevealNever:
+		return false
+	default:
+		return true
+	}
+}
+
 func (s *KnowledgePointService) AuditSubmission(id string, status string, manualScore *int) error {
 	if status != "approved" && status != "rejected" {
 		return fmt.Errorf("invalid status")
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	var audited model.KnowledgePointSubmission
+	err := s.db.Transaction(func(tx *gorm.DB) error {
 		var sub model.KnowledgePointSubmission
 		if err := tx.First(&sub, "id = ?", id).Error; err != nil {
 			return err
@@ -753,22 +940,33 @@ func (s *KnowledgePointService) AuditSubmission(id string, status string, manual
 				UserID:           sub.UserID,
 				KnowledgePointID: sub.KnowledgePointID,
 				IsCompleted:      true,
-				CompletedAt:      time.Now(),
+				CompletedAt:      model.NewJSONTime(time.Now()),
 			}
 			if err := tx.Save(&completion).Error; err != nil {
 				return err
 			}
 
-			var user model.User
-			if err := tx.First(&user, sub.UserID).Error; err != nil {
-				return err
-			}
-			user.Points += finalScore // 发放到独立积分系统
-			if err := tx.Save(&user).Error; err != nil {
+			// 发放到独立积分系统：原子累加，并以提交ID幂等，重复审核通过不会重复发放
+			if _, err := awardPoints(tx, sub.UserID, finalScore, model.PointSourceKnowledgePoint, sub.ID, "知识点测试审核通过"); err != nil {
 				return err
 			}
 		}
 
+		audited = sub
+		audited.Score = finalScore
+		audited.Status = status
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	s.events.Publish(EventSubmissionAudited, SubmissionAuditedEvent{
+		UserID:           audited.UserID,
+		SubmissionID:     audited.ID,
+		KnowledgePointID: audited.KnowledgePointID,
+		Status:           audited.Status,
+		Score:            audited.Score,
+	})
+	return nil
 }
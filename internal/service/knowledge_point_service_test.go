@@ -0,0 +1,170 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/testutil"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TestAuditSubmissionConcurrentApprovals 需要 testutil.MySQL 的测试库，未设置 TEST_MYSQL_DSN 时跳过
+func TestAuditSubmissionConcurrentApprovals(t *testing.T) {
+	db := testutil.MySQL(t)
+	s := NewKnowledgePointService(db, NewEventBus())
+
+	user := model.User{Name: "audit-test", Email: "audit-test-" + model.GenerateUUID() + "@example.com", Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	now := model.NewJSONTime(time.Now())
+	subs := []model.KnowledgePointSubmission{
+		{ID: model.GenerateUUID(), UserID: user.ID, KnowledgePointID: model.GenerateUUID(), Score: 30, StartedAt: now, CreatedAt: now},
+		{ID: model.GenerateUUID(), UserID: user.ID, KnowledgePointID: model.GenerateUUID(), Score: 45, StartedAt: now, CreatedAt: now},
+	}
+	if err := db.Create(&subs).Error; err != nil {
+		t.Fatalf("create submissions: %v", err)
+	}
+	defer func() {
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(&model.PointsLedger{})
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(&model.KnowledgePointCompletion{})
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(&model.KnowledgePointSubmission{})
+		db.Unscoped().Delete(&user)
+	}()
+
+	// 同一用户的两份提交同时审核通过，两笔积分都必须累加，不能互相覆盖
+	var wg sync.WaitGroup
+	errs := make([]error, len(subs))
+	for i := range subs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.AuditSubmission(subs[i].ID, "approved", nil)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("audit submission %d: %v", i, err)
+		}
+	}
+
+	// 重复审核通过不会重复发放
+	if err := s.AuditSubmission(subs[0].ID, "approved", nil); err != nil {
+		t.Fatalf("re-audit submission: %v", err)
+	}
+
+	var points int
+	if err := db.Model(&model.User{}).Where("id = ?", user.ID).Pluck("points", &points).Error; err != nil {
+		t.Fatalf("load points: %v", err)
+	}
+	if want := subs[0].Score + subs[1].Score; points != want {
+		t.Fatalf("points = %d, want %d", points, want)
+	}
+
+	var entries int64
+	db.Model(&model.PointsLedger{}).Where("user_id = ? AND source_type = ?", user.ID, model.PointSourceKnowledgePoint).Count(&entries)
+	if entries != int64(len(subs)) {
+		t.Fatalf("ledger entries = %d, want %d", entries, len(subs))
+	}
+}
+
+// openListSubmissionsDB 打开独立连接并注册查询计数回调，返回的计数器统计经过 gorm 的查询条数
+func openListSubmissionsDB(tb testing.TB) (*gorm.DB, *int64) {
+	tb.Helper()
+	db := testutil.MySQL(tb)
+	var queries int64
+	if err := db.Callback().Query().After("gorm:query").Register("test:count_queries", func(*gorm.DB) {
+		atomic.AddInt64(&queries, 1)
+	}); err != nil {
+		tb.Fatalf("register callback: %v", err)
+	}
+	return db, &queries
+}
+
+// seedListSubmissions 创建 students 名学生，每人对 kps 个知识点中的前两个各提交一次，返回用于筛选的学生姓名前缀
+func seedListSubmissions(tb testing.TB, db *gorm.DB, students, kps int) string {
+	tb.Helper()
+	prefix := "list-sub-" + model.GenerateUUID()[:8]
+
+	points := make([]model.KnowledgePoint, kps)
+	for i := range points {
+		points[i] = model.KnowledgePoint{ID: model.GenerateUUID(), Title: fmt.Sprintf("%s-kp-%d", prefix, i), Type: model.KPConcept}
+	}
+	if err := db.Create(&points).Error; err != nil {
+		tb.Fatalf("create knowledge points: %v", err)
+	}
+
+	users := make([]model.User, students)
+	for i := range users {
+		users[i] = model.User{Name: fmt.Sprintf("%s-%d", prefix, i), Email: fmt.Sprintf("%s-%d@example.com", prefix, i), Password: "x", Role: model.Student}
+	}
+	if err := db.Create(&users).Error; err != nil {
+		tb.Fatalf("create students: %v", err)
+	}
+
+	now := model.NewJSONTime(time.Now())
+	var subs []model.KnowledgePointSubmission
+	for _, u := range users {
+		for _, kp := range points[:2] {
+			subs = append(subs, model.KnowledgePointSubmission{ID: model.GenerateUUID(), UserID: u.ID, KnowledgePointID: kp.ID, Score: 10, StartedAt: now, CreatedAt: now})
+		}
+	}
+	if err := db.Create(&subs).Error; err != nil {
+		tb.Fatalf("create submissions: %v", err)
+	}
+
+	tb.Cleanup(func() {
+		userIDs := make([]uint, len(users))
+		for i, u := range users {
+			userIDs[i] = u.ID
+		}
+		db.Where("user_id IN ?", userIDs).Delete(&model.KnowledgePointSubmission{})
+		db.Unscoped().Delete(&users)
+		db.Delete(&points)
+	})
+	return prefix
+}
+
+// TestListSubmissionsQueryCount 查询条数不随本页学生数和提交数增长
+func TestListSubmissionsQueryCount(t *testing.T) {
+	db, queries := openListSubmissionsDB(t)
+	prefix := seedListSubmissions(t, db, 20, 3)
+	s := NewKnowledgePointService(db, nil)
+
+	for _, limit := range []int{1, 5, 20} {
+		atomic.StoreInt64(queries, 0)
+		res, total, err := s.ListSubmissions("", "", prefix, 1, limit)
+		if err != nil {
+			t.Fatalf("limit %d: list submissions: %v", limit, err)
+		}
+		if total != 20 || len(res) != limit*2 {
+			t.Fatalf("limit %d: got %d rows (total %d), want %d rows (total 20)", limit, len(res), total, limit*2)
+		}
+		// 学生总数、学生分页、提交记录、知识点标题各一条
+		if n := atomic.LoadInt64(queries); n != 4 {
+			t.Fatalf("limit %d: ListSubmissions ran %d queries, want 4", limit, n)
+		}
+	}
+}
+
+// BenchmarkListSubmissions 报告每次调用的查询条数（queries/op）。逐个学生查询时约为 2+每页学生数×(1+提交数)，批量加载后恒为 4
+func BenchmarkListSubmissions(b *testing.B) {
+	db, queries := openListSubmissionsDB(b)
+	prefix := seedListSubmissions(b, db, 50, 3)
+	s := NewKnowledgePointService(db, nil)
+
+	atomic.StoreInt64(queries, 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := s.ListSubmissions("", "", prefix, 1, 50); err != nil {
+			b.Fatalf("list submissions: %v", err)
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(atomic.LoadInt64(queries))/float64(b.N), "queries/op")
+}
@@ -3,8 +3,13 @@ package service
 import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
+	"coder_edu_backend/pkg/logger"
+	"math"
+	"sort"
 	"time"
 
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -14,6 +19,7 @@ type LearningGoalService struct {
 	CProgrammingResourceRepo    *repository.CProgrammingResourceRepository
 	CProgrammingResourceService *CProgrammingResourceService
 	DB                          *gorm.DB
+	Events                      *EventBus
 }
 
 func NewLearningGoalService(
@@ -21,12 +27,14 @@ func NewLearningGoalService(
 	cProgrammingResourceRepo *repository.CProgrammingResourceRepository,
 	cProgrammingResourceService *CProgrammingResourceService,
 	db *gorm.DB,
+	events *EventBus,
 ) *LearningGoalService {
 	return &LearningGoalService{
 		GoalRepo:                    goalRepo,
 		CProgrammingResourceRepo:    cProgrammingResourceRepo,
 		CProgrammingResourceService: cProgrammingResourceService,
 		DB:                          db,
+		Events:                      events,
 	}
 }
 
@@ -48,6 +56,18 @@ type UpdateGoalRequest struct {
 	ResourceModuleID uint      `json:"resourceModuleId"`
 }
 
+// 截止提醒操作
+const (
+	GoalReminderSnooze  = "snooze"  // 暂缓，到期后重新提醒
+	GoalReminderDismiss = "dismiss" // 不再提醒该目标
+)
+
+// GoalReminderRequest 暂缓或关闭目标截止提醒的请求
+type GoalReminderRequest struct {
+	Action      string `json:"action" binding:"required,oneof=snooze dismiss"`
+	SnoozeHours int    `json:"snoozeHours" binding:"omitempty,min=1,max=168"` // 暂缓时长，默认 24 小时
+}
+
 // GetRecommendedResourceModules 获取推荐资源模块列表
 func (s *LearningGoalService) GetRecommendedResourceModules() ([]model.CProgrammingResource, error) {
 	// 获取所有启用的资源模块
@@ -75,7 +95,7 @@ func (s *LearningGoalService) CreateGoal(userID uint, req CreateGoalRequest) (*m
 		Current:            0,
 		Target:             100,
 		Progress:           0,
-		TargetDate:         req.TargetDate,
+		TargetDate:         model.NewJSONTime(req.TargetDate),
 		GoalType:           model.GoalType(req.GoalType),
 		ResourceModuleID:   req.ResourceModuleID,
 		ResourceModuleName: resourceModule.Name,
@@ -141,8 +161,10 @@ func (s *LearningGoalService) UpdateGoal(userID, goalID uint, req UpdateGoalRequ
 	if req.Description != "" {
 		goal.Description = req.Description
 	}
-	if !req.TargetDate.IsZero() {
-		goal.TargetDate = req.TargetDate
+	targetDateChanged := false
+	if !req.TargetDate.IsZero() && !req.TargetDate.Equal(goal.TargetDate.Time) {
+		goal.TargetDate = model.NewJSONTime(req.TargetDate)
+		targetDateChanged = true
 	}
 	if req.GoalType != "" {
 		goal.GoalType = model.GoalType(req.GoalType)
@@ -160,7 +182,102 @@ func (s *LearningGoalService) UpdateGoal(userID, goalID uint, req UpdateGoalRequ
 	// 更新目标的状态和进度
 	s.updateGoalStatusAndProgress(goal, userID)
 
-	return goal, s.GoalRepo.Update(goal)
+	if err := s.GoalRepo.Update(goal); err != nil {
+		return nil, err
+	}
+	// 截止日期调整后按新日期重新计算提醒
+	if targetDateChanged && (goal.ReminderThresholdDays > 0 || goal.ReminderSnoozedUntil != nil) {
+		goal.ReminderThresholdDays = 0
+		goal.ReminderSnoozedUntil = nil
+		if err := s.GoalRepo.UpdateReminder(goal); err != nil {
+			return nil, err
+		}
+	}
+	return goal, nil
+}
+
+// UpdateReminder 暂缓或关闭目标的截止提醒。暂缓到期后会按当前剩余天数重新提醒一次
+func (s *LearningGoalService) UpdateReminder(userID, goalID uint, req GoalReminderRequest) (*model.Goal, error) {
+	goal, err := s.GoalRepo.FindByIDAndUserID(goalID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Action {
+	case GoalReminderSnooze:
+		hours := req.SnoozeHours
+		if hours <= 0 {
+			hours = 24
+		}
+		until := time.Now().Add(time.Duration(hours) * time.Hour)
+		goal.ReminderSnoozedUntil = model.NewJSONTimePtr(&until)
+		goal.ReminderThresholdDays = 0
+	case GoalReminderDismiss:
+		goal.ReminderDismissed = true
+	default:
+		return nil, util.ErrInvalidRequest
+	}
+	return goal, s.GoalRepo.UpdateReminder(goal)
+}
+
+// SendDeadlineReminders 为临近截止且未完成的目标发布提醒事件，thresholds 为截止前的天数阈值。
+// 目标进入更小的阈值时再次提醒，同一阈值只提醒一次；返回本次提醒的目标数
+func (s *LearningGoalService) SendDeadlineReminders(thresholds []int) (int, error) {
+	days := make([]int, 0, len(thresholds))
+	for _, d := range thresholds {
+		if d > 0 {
+			days = append(days, d)
+		}
+	}
+	if len(days) == 0 {
+		return 0, nil
+	}
+	sort.Ints(days)
+
+	now := time.Now()
+	goals, err := s.GoalRepo.FindDueForReminder(now, now.Add(time.Duration(days[len(days)-1])*24*time.Hour))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range goals {
+		goal := &goals[i]
+		remaining := goal.TargetDate.Sub(now)
+		threshold := 0
+		for _, d := range days {
+			if remaining <= time.Duration(d)*24*time.Hour {
+				threshold = d
+				break
+			}
+		}
+		if threshold == 0 || (goal.ReminderThresholdDays > 0 && goal.ReminderThresholdDays <= threshold) {
+			continue
+		}
+
+		// 数据库中的状态只在用户查看时刷新，提醒前按资源进度重新计算，避免提醒已完成的目标
+		s.updateGoalStatusAndProgress(goal, goal.UserID)
+		if goal.Status == model.GoalCompleted || goal.Status == model.GoalCompletedExpired {
+			continue
+		}
+
+		goal.ReminderSentAt = model.NewJSONTimePtr(&now)
+		goal.ReminderThresholdDays = threshold
+		goal.ReminderSnoozedUntil = nil
+		if err := s.GoalRepo.UpdateReminder(goal); err != nil {
+			logger.Log.Warn("保存目标提醒状态失败", zap.Uint("goalID", goal.ID), zap.Error(err))
+			continue
+		}
+		s.Events.Publish(EventGoalDeadline, GoalDeadlineEvent{
+			UserID:     goal.UserID,
+			GoalID:     goal.ID,
+			Title:      goal.Title,
+			TargetDate: goal.TargetDate.Time,
+			DaysLeft:   int(math.Ceil(remaining.Hours() / 24)),
+		})
+		sent++
+	}
+	return sent, nil
 }
 
 // DeleteGoal 删除学习目标
@@ -177,7 +294,7 @@ func (s *LearningGoalService) DeleteGoal(userID, goalID uint) error {
 // updateGoalStatusAndProgress 更新目标的状态和进度
 func (s *LearningGoalService) updateGoalStatusAndProgress(goal *model.Goal, userID uint) {
 	// 获取资源模块的进度
-	resourceModuleProgress, err := s.CProgrammingResourceService.GetResourceModuleWithProgress(goal.ResourceModuleID, userID)
+	resourceModuleProgress, err := s.CProgrammingResourceService.GetResourceModuleWithProgress(goal.ResourceModuleID, userID, model.Student)
 	if err != nil {
 		return
 	}
@@ -191,7 +308,7 @@ func (s *LearningGoalService) updateGoalStatusAndProgress(goal *model.Goal, user
 
 	// 检查是否已过期
 	today := time.Now()
-	isExpired := !today.Before(goal.TargetDate)
+	isExpired := !today.Before(goal.TargetDate.Time)
 
 	// 更新目标状态
 	if isCompleted {
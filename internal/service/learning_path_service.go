@@ -4,6 +4,7 @@ import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -40,7 +41,41 @@ type StudentMaterialResponse struct {
 	ChapterNumber int    `json:"chapterNumber"`
 }
 
-func (s *LearningPathService) GetStudentPath(userID uint) ([]StudentMaterialResponse, error) {
+// StudentPathResponse 学生学习路径及整体进度
+type StudentPathResponse struct {
+	Materials       []StudentMaterialResponse `json:"materials"`
+	ProgressPercent int                       `json:"progressPercent"` // 已完成资料占全部资料的百分比
+	CompletedCount  int                       `json:"completedCount"`
+	TotalCount      int                       `json:"totalCount"`
+	NextMaterialID  string                    `json:"nextMaterialId"` // 按路径顺序第一个已解锁且未完成的资料，没有时为空
+	Finished        bool                      `json:"finished"`       // 全部资料均已完成
+}
+
+// newStudentPathResponse 按等级、章节排序资料并计算进度和下一步
+func newStudentPathResponse(materials []StudentMaterialResponse) *StudentPathResponse {
+	sort.SliceStable(materials, func(i, j int) bool {
+		if materials[i].Level != materials[j].Level {
+			return materials[i].Level < materials[j].Level
+		}
+		return materials[i].ChapterNumber < materials[j].ChapterNumber
+	})
+
+	resp := &StudentPathResponse{Materials: materials, TotalCount: len(materials)}
+	for _, m := range materials {
+		if m.IsCompleted {
+			resp.CompletedCount++
+		} else if m.IsUnlocked && resp.NextMaterialID == "" {
+			resp.NextMaterialID = m.ID
+		}
+	}
+	if resp.TotalCount > 0 {
+		resp.ProgressPercent = resp.CompletedCount * 100 / resp.TotalCount
+		resp.Finished = resp.CompletedCount == resp.TotalCount
+	}
+	return resp
+}
+
+func (s *LearningPathService) GetStudentPath(userID uint) (*StudentPathResponse, error) {
 	// 1. 获取学生的学前测试建议等级
 	var recommendedLevel int
 	// 先获取默认评估 ID
@@ -82,7 +117,7 @@ func (s *LearningPathService) GetStudentPath(userID uint) ([]StudentMaterialResp
 		}
 	}
 
-	return res, nil
+	return newStudentPathResponse(res), nil
 }
 
 type CreateMaterialRequest struct {
@@ -189,7 +224,15 @@ func (s *LearningPathService) GetMaterialsByLevel(userID uint, level int) ([]Mat
 	return res, nil
 }
 
-func (s *LearningPathService) CompleteMaterial(userID uint, materialID string) error {
+// CompleteMaterial 标记资料完成并返回更新后的学习路径进度，便于前端无需重新拉取
+func (s *LearningPathService) CompleteMaterial(userID uint, materialID string) (*StudentPathResponse, error) {
+	if err := s.completeMaterial(userID, materialID); err != nil {
+		return nil, err
+	}
+	return s.GetStudentPath(userID)
+}
+
+func (s *LearningPathService) completeMaterial(userID uint, materialID string) error {
 	// 1. 检查是否已经完成过
 	existing, err := s.Repo.FindCompletion(userID, materialID)
 	if err == nil && existing != nil {
@@ -206,7 +249,7 @@ func (s *LearningPathService) CompleteMaterial(userID uint, materialID string) e
 	completion := &model.LearningPathCompletion{
 		UserID:      userID,
 		MaterialID:  materialID,
-		CompletedAt: time.Now(),
+		CompletedAt: model.NewJSONTime(time.Now()),
 	}
 
 	if err := s.Repo.CreateCompletion(completion); err != nil {
@@ -241,12 +284,5 @@ func (s *LearningPathService) RecordLearningTime(userID uint, materialID string,
 		return err
 	}
 
-	log := &model.LearningLog{
-		UserID:   userID,
-		Activity: "learning_path_material",
-		Content:  fmt.Sprintf("学习了资料: %s", material.Title),
-		Duration: duration,
-	}
-
-	return s.LearningLogRepo.Create(log)
+	return recordLearningTime(s.LearningLogRepo, userID, "learning_path_material", materialID, fmt.Sprintf("学习了资料: %s", material.Title), duration)
 }
@@ -5,12 +5,16 @@ import (
 	"coder_edu_backend/internal/config"
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"gorm.io/gorm"
 )
@@ -93,9 +97,9 @@ type Collaboration struct {
 }
 
 type ChatMessage struct {
-	Author    string    `json:"author"`
-	Content   string    `json:"content"`
-	Timestamp time.Time `json:"timestamp"`
+	Author    string         `json:"author"`
+	Content   string         `json:"content"`
+	Timestamp model.JSONTime `json:"timestamp"`
 }
 
 type CodeEditor struct {
@@ -148,13 +152,82 @@ type QuizSubmission struct {
 }
 
 type CodeExecutionRequest struct {
-	Code string `json:"code"`
+	Code     string `json:"code"`
+	Language string `json:"language,omitempty"` // c、cpp、python，为空时按 C 运行
+}
+
+// 代码运行支持的编程语言
+const (
+	CodeLanguageC      = "c"
+	CodeLanguageCPP    = "cpp"
+	CodeLanguagePython = "python"
+)
+
+// NormalizeCodeLanguage 规范化语言名称，为空时默认 C
+func NormalizeCodeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	switch language {
+	case "":
+		return CodeLanguageC
+	case "c++":
+		return CodeLanguageCPP
+	case "python3", "py":
+		return CodeLanguagePython
+	}
+	return language
+}
+
+// codeLanguage 查找语言对应的 Judge0 配置，未配置的语言返回 ErrUnsupportedLanguage
+func (s *LearningService) codeLanguage(language string) (string, config.Judge0LanguageConfig, error) {
+	language = NormalizeCodeLanguage(language)
+	lang, ok := s.Config.Judge0.Languages[language]
+	if !ok || lang.LanguageID == 0 {
+		return language, lang, fmt.Errorf("%w: %s", util.ErrUnsupportedLanguage, language)
+	}
+	return language, lang, nil
+}
+
+// ValidateCodeLanguage 校验语言是否受支持，返回规范化后的语言名称
+func (s *LearningService) ValidateCodeLanguage(language string) (string, error) {
+	language, _, err := s.codeLanguage(language)
+	return language, err
 }
 
 type CodeExecutionResponse struct {
-	Output string `json:"output"`
-	Errors string `json:"errors"`
-	Status int    `json:"status"` // 0: success, 1: compilation error, 2: runtime error, 3: timeout
+	Output    string `json:"output"`
+	Errors    string `json:"errors"`
+	Status    int    `json:"status"`              // 0: success, 1: compilation error, 2: runtime error, 3: timeout, 4: output limit exceeded
+	ErrorType string `json:"errorType,omitempty"` // 见 CodeError* 常量，成功时为空
+	Truncated bool   `json:"truncated,omitempty"` // 输出是否因超过上限被截断
+}
+
+// 代码运行失败类型
+const (
+	CodeErrorCompile       = "compile_error"
+	CodeErrorRuntime       = "runtime_error"
+	CodeErrorTimeLimit     = "time_limit_exceeded"
+	CodeErrorOutputLimit   = "output_limit_exceeded"
+	CodeErrorMemoryLimit   = "memory_limit_exceeded"
+	CodeErrorInternalError = "internal_error"
+)
+
+// CodeErrorLabel 返回运行失败类型的中文描述，未知类型返回空字符串
+func CodeErrorLabel(errorType string) string {
+	switch errorType {
+	case CodeErrorCompile:
+		return "编译错误"
+	case CodeErrorRuntime:
+		return "运行时错误"
+	case CodeErrorTimeLimit:
+		return "运行超时"
+	case CodeErrorOutputLimit:
+		return "输出超出限制"
+	case CodeErrorMemoryLimit:
+		return "内存超出限制"
+	case CodeErrorInternalError:
+		return "判题系统内部错误"
+	}
+	return ""
 }
 
 type Judge0Response struct {
@@ -243,17 +316,17 @@ func (s *LearningService) GetInClassContent(userID uint) (*InClassContent, error
 			{
 				Author:    "Prof. Ada",
 				Content:   "Great progress everyone! Let's discuss the approach for Task 3's algorithm.",
-				Timestamp: time.Now().Add(-10 * time.Minute),
+				Timestamp: model.NewJSONTime(time.Now().Add(-10 * time.Minute)),
 			},
 			{
 				Author:    "You",
 				Content:   "I'm thinking of using a recursive solution for Task 3, but I'm unsure about this base case.",
-				Timestamp: time.Now().Add(-5 * time.Minute),
+				Timestamp: model.NewJSONTime(time.Now().Add(-5 * time.Minute)),
 			},
 			{
 				Author:    "Alex M.",
 				Content:   "For Task 3, consider an iterative approach with a loop. It might be more straightforward for this specific problem.",
-				Timestamp: time.Now().Add(-2 * time.Minute),
+				Timestamp: model.NewJSONTime(time.Now().Add(-2 * time.Minute)),
 			},
 		},
 	}
@@ -414,11 +487,31 @@ func (s *LearningService) SubmitQuiz(userID uint, quizID uint, submission QuizSu
 }
 
 func (s *LearningService) RunCode(req CodeExecutionRequest) (*CodeExecutionResponse, error) {
+	_, lang, err := s.codeLanguage(req.Language)
+	if err != nil {
+		return nil, err
+	}
 	encodedCode := base64.StdEncoding.EncodeToString([]byte(req.Code))
 
+	limits := s.Config.Judge0
+	if lang.TimeLimitSeconds > 0 {
+		limits.TimeLimitSeconds = lang.TimeLimitSeconds
+	}
 	inputData := map[string]interface{}{
 		"source_code": encodedCode,
-		"language_id": 75,
+		"language_id": lang.LanguageID,
+	}
+	// 由 Judge0 在沙箱内强制执行时间/内存/输出限制，超限时进程由沙箱终止回收
+	if limits.TimeLimitSeconds > 0 {
+		inputData["cpu_time_limit"] = limits.TimeLimitSeconds
+		inputData["wall_time_limit"] = limits.TimeLimitSeconds
+	}
+	if limits.MemoryLimitKB > 0 {
+		inputData["memory_limit"] = limits.MemoryLimitKB
+	}
+	if limits.MaxOutputBytes > 0 {
+		// max_file_size 以 KB 为单位，写出超限会触发 SIGXFSZ
+		inputData["max_file_size"] = (limits.MaxOutputBytes + 1023) / 1024
 	}
 	jsonData, _ := json.Marshal(inputData)
 
@@ -435,7 +528,8 @@ func (s *LearningService) RunCode(req CodeExecutionRequest) (*CodeExecutionRespo
 	httpReq.Header.Set("X-RapidAPI-Key", apiKey)
 	httpReq.Header.Set("X-RapidAPI-Host", host)
 
-	client := &http.Client{Timeout: 20 * time.Second}
+	// 请求超时在运行时限基础上预留排队和编译时间
+	client := &http.Client{Timeout: time.Duration(limits.TimeLimitSeconds*float64(time.Second)) + 20*time.Second}
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("calling Judge0 API failed: %v", err)
@@ -467,16 +561,110 @@ func (s *LearningService) RunCode(req CodeExecutionRequest) (*CodeExecutionRespo
 		response.Status = 0
 	case 6: // Compilation Error
 		response.Status = 1
+		response.ErrorType = CodeErrorCompile
 		response.Errors = string(compileOut)
 	case 5: // Time Limit Exceeded
 		response.Status = 3
-		response.Errors = "执行超时 (Judge0)"
+		response.ErrorType = CodeErrorTimeLimit
+		response.Errors = fmt.Sprintf("执行超时（上限 %gs）", limits.TimeLimitSeconds)
+	case 8: // Runtime Error (SIGXFSZ)：输出超过 max_file_size
+		response.Status = 4
+		response.ErrorType = CodeErrorOutputLimit
+		response.Errors = fmt.Sprintf("输出超出限制（上限 %d 字节）", limits.MaxOutputBytes)
+	case 13, 14: // Internal Error / Exec Format Error
+		response.Status = 2
+		response.ErrorType = CodeErrorInternalError
+		if response.Errors == "" {
+			response.Errors = jResp.Status.Description
+		}
 	default:
 		response.Status = 2
+		response.ErrorType = CodeErrorRuntime
+		if limits.MemoryLimitKB > 0 && jResp.Memory >= limits.MemoryLimitKB {
+			response.ErrorType = CodeErrorMemoryLimit
+		}
 		if response.Errors == "" {
 			response.Errors = jResp.Status.Description
 		}
 	}
 
+	// 沙箱未拦截时（如一次性输出大量内容）仍在服务端截断，避免超大响应
+	if limits.MaxOutputBytes > 0 {
+		if len(response.Output) > limits.MaxOutputBytes {
+			response.Output = truncateUTF8(response.Output, limits.MaxOutputBytes)
+			response.Truncated = true
+			if response.Status == 0 {
+				response.Status = 4
+				response.ErrorType = CodeErrorOutputLimit
+				response.Errors = fmt.Sprintf("输出超出限制（上限 %d 字节）", limits.MaxOutputBytes)
+			}
+		}
+		if len(response.Errors) > limits.MaxOutputBytes {
+			response.Errors = truncateUTF8(response.Errors, limits.MaxOutputBytes)
+			response.Truncated = true
+		}
+	}
+
 	return response, nil
 }
+
+// truncateUTF8 截断到不超过 max 字节，且不切断多字节字符
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// ActivityDuration 某类学习活动的累计时长
+type ActivityDuration struct {
+	Activity string `json:"activity"`
+	Duration int    `json:"duration"`
+	Count    int    `json:"count"`
+}
+
+// LearningLogSummary 学习日志汇总，时长单位与写入日志时一致
+type LearningLogSummary struct {
+	StartDate     string                                `json:"startDate"`
+	EndDate       string                                `json:"endDate"`
+	TotalDuration int                                   `json:"totalDuration"`
+	ByActivity    []ActivityDuration                    `json:"byActivity"`
+	Daily         []repository.LearningLogDailyActivity `json:"daily"`
+}
+
+// GetLearningLogSummary 按活动类型和日期汇总用户在 [start, end] 日期范围内记录的学习时长
+func (s *LearningService) GetLearningLogSummary(userID uint, start, end time.Time) (*LearningLogSummary, error) {
+	rangeStart := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+	rangeEnd := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+
+	daily, err := s.LearningLogRepo.SummarizeByActivityAndDay(userID, rangeStart, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &LearningLogSummary{
+		StartDate:  rangeStart.Format(util.DateFormat),
+		EndDate:    end.Format(util.DateFormat),
+		ByActivity: make([]ActivityDuration, 0),
+		Daily:      daily,
+	}
+	index := make(map[string]int)
+	for _, row := range daily {
+		summary.TotalDuration += row.Duration
+		i, ok := index[row.Activity]
+		if !ok {
+			i = len(summary.ByActivity)
+			index[row.Activity] = i
+			summary.ByActivity = append(summary.ByActivity, ActivityDuration{Activity: row.Activity})
+		}
+		summary.ByActivity[i].Duration += row.Duration
+		summary.ByActivity[i].Count += row.Count
+	}
+	sort.Slice(summary.ByActivity, func(a, b int) bool {
+		return summary.ByActivity[a].Duration > summary.ByActivity[b].Duration
+	})
+	return summary, nil
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
+	"time"
+)
+
+// MaxLearningTimeIncrement 单次上报学习时长的上限（秒），超出部分丢弃，避免异常客户端虚增学习时长
+const MaxLearningTimeIncrement = 30 * 60
+
+// recordLearningTime 将学习时长累加到 用户-学习项-自然日 唯一的学习日志中，
+// 重复上报不会新增记录，且每次累加不超过距上次上报实际经过的时间。content 仅在当天首次上报时写入
+func recordLearningTime(repo *repository.LearningLogRepository, userID uint, activity, itemID, content string, duration int) error {
+	if duration <= 0 {
+		return nil
+	}
+	if duration > MaxLearningTimeIncrement {
+		duration = MaxLearningTimeIncrement
+	}
+
+	itemKey := activity + ":" + itemID
+	logDate := time.Now().Format(util.DateFormat)
+	return repo.AccumulateDuration(&model.LearningLog{
+		UserID:   userID,
+		Activity: activity,
+		Content:  content,
+		Duration: duration,
+		ItemKey:  &itemKey,
+		LogDate:  &logDate,
+	})
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -69,7 +71,7 @@ func (ft FlexibleTime) MarshalJSON() ([]byte, error) {
 	if ft.Time.IsZero() {
 		return []byte("null"), nil
 	}
-	return json.Marshal(ft.Time.Format(time.RFC3339))
+	return json.Marshal(ft.Time.Format(model.TimeFormat))
 }
 
 // TimePtr 返回指向内部 time.Time 的指针，如果时间为零值则返回 nil
@@ -85,14 +87,16 @@ type LevelService struct {
 	LevelAttemptRepo *repository.LevelAttemptRepository
 	LearningService  *LearningService
 	DB               *gorm.DB
+	Events           *EventBus
 }
 
-func NewLevelService(levelRepo *repository.LevelRepository, levelAttemptRepo *repository.LevelAttemptRepository, learningService *LearningService, db *gorm.DB) *LevelService {
+func NewLevelService(levelRepo *repository.LevelRepository, levelAttemptRepo *repository.LevelAttemptRepository, learningService *LearningService, db *gorm.DB, events *EventBus) *LevelService {
 	return &LevelService{
 		LevelRepo:        levelRepo,
 		LevelAttemptRepo: levelAttemptRepo,
 		LearningService:  learningService,
 		DB:               db,
+		Events:           events,
 	}
 }
 
@@ -106,13 +110,14 @@ type LevelQuestionRequest struct {
 	Weight        int         `json:"weight,omitempty"`
 	ManualGrading bool        `json:"manualGrading,omitempty"`
 	Explanation   string      `json:"explanation,omitempty"`
+	Language      string      `json:"language,omitempty"` // 编程题语言：c、cpp、python
 }
 
 // LevelFullResponse 包含关卡完整信息的响应结构体
 type LevelFullResponse struct {
 	ID                 uint                    `json:"id"`
-	CreatedAt          time.Time               `json:"createdAt"`
-	UpdatedAt          time.Time               `json:"updatedAt"`
+	CreatedAt          model.JSONTime          `json:"createdAt"`
+	UpdatedAt          model.JSONTime          `json:"updatedAt"`
 	CreatorID          uint                    `json:"creatorId"`
 	Title              string                  `json:"title"`
 	Description        string                  `json:"description"`
@@ -121,16 +126,17 @@ type LevelFullResponse struct {
 	EstimatedMinutes   int                     `json:"estimatedMinutes"`
 	AttemptLimit       int                     `json:"attemptLimit"`
 	PassingScore       int                     `json:"passingScore"`
+	PassingMode        string                  `json:"passingMode"`
 	BasePoints         int                     `json:"basePoints"`
 	AllowPause         bool                    `json:"allowPause"`
 	LevelType          string                  `json:"levelType"`
 	IsPublished        bool                    `json:"isPublished"`
-	PublishedAt        *time.Time              `json:"publishedAt,omitempty"`
-	ScheduledPublishAt *time.Time              `json:"scheduledPublishAt,omitempty"`
+	PublishedAt        *model.JSONTime         `json:"publishedAt,omitempty"`
+	ScheduledPublishAt *model.JSONTime         `json:"scheduledPublishAt,omitempty"`
 	VisibleScope       string                  `json:"visibleScope"`
 	VisibleTo          json.RawMessage         `json:"visibleTo"`
-	AvailableFrom      *time.Time              `json:"availableFrom,omitempty"`
-	AvailableTo        *time.Time              `json:"availableTo,omitempty"`
+	AvailableFrom      *model.JSONTime         `json:"availableFrom,omitempty"`
+	AvailableTo        *model.JSONTime         `json:"availableTo,omitempty"`
 	CurrentVersion     uint                    `json:"currentVersion"`
 	Abilities          []uint                  `json:"abilityIds"`
 	KnowledgeTags      []uint                  `json:"knowledgeTagIds"`
@@ -140,8 +146,8 @@ type LevelFullResponse struct {
 // LevelQuestionResponse 题目完整信息响应结构体
 type LevelQuestionResponse struct {
 	ID            uint            `json:"id"`
-	CreatedAt     time.Time       `json:"createdAt"`
-	UpdatedAt     time.Time       `json:"updatedAt"`
+	CreatedAt     model.JSONTime  `json:"createdAt"`
+	UpdatedAt     model.JSONTime  `json:"updatedAt"`
 	LevelID       uint            `json:"levelId"`
 	QuestionType  string          `json:"questionType"`
 	Content       json.RawMessage `json:"content"`
@@ -153,25 +159,26 @@ type LevelQuestionResponse struct {
 	Order         int             `json:"order"`
 	ScoringRule   string          `json:"scoringRule"`
 	Explanation   string          `json:"explanation"`
+	Language      string          `json:"language,omitempty"`
 	CodeTemplate  string          `json:"codeTemplate"`
 }
 
 // StudentLevelResponse 学生端关卡列表响应结构体
 type StudentLevelResponse struct {
-	ID               uint      `json:"id"`
-	Title            string    `json:"title"`
-	Description      string    `json:"description"`
-	CoverURL         string    `json:"coverUrl"`
-	Difficulty       string    `json:"difficulty"`
-	EstimatedMinutes int       `json:"estimatedMinutes"`
-	AttemptLimit     int       `json:"attemptLimit"`
-	PassingScore     int       `json:"passingScore"`
-	BasePoints       int       `json:"basePoints"`     // 积分奖励分数（所有题目积分总和）
-	QuestionsCount   int       `json:"questionsCount"` // 题目数量
-	Status           string    `json:"status"`         // "not_started", "in_progress", "completed"
-	BestScore        int       `json:"bestScore,omitempty"`
-	AttemptsUsed     int       `json:"attemptsUsed,omitempty"`
-	CreatedAt        time.Time `json:"createdAt"`
+	ID               uint           `json:"id"`
+	Title            string         `json:"title"`
+	Description      string         `json:"description"`
+	CoverURL         string         `json:"coverUrl"`
+	Difficulty       string         `json:"difficulty"`
+	EstimatedMinutes int            `json:"estimatedMinutes"`
+	AttemptLimit     int            `json:"attemptLimit"`
+	PassingScore     int            `json:"passingScore"`
+	BasePoints       int            `json:"basePoints"`     // 积分奖励分数（所有题目积分总和）
+	QuestionsCount   int            `json:"questionsCount"` // 题目数量
+	Status           string         `json:"status"`         // "not_started", "in_progress", "completed"
+	BestScore        int            `json:"bestScore,omitempty"`
+	AttemptsUsed     int            `json:"attemptsUsed,omitempty"`
+	CreatedAt        model.JSONTime `json:"createdAt"`
 }
 
 // StudentLevelDetailResponse 学生端关卡详情响应结构体
@@ -189,12 +196,12 @@ type StudentLevelDetailResponse struct {
 	QuestionsCount   int    `json:"questionsCount"`
 
 	// 关卡元数据
-	UpdatedAt          *time.Time    `json:"updatedAt,omitempty"`
-	Author             *UserInfo     `json:"author,omitempty"`
-	Abilities          []AbilityInfo `json:"abilities,omitempty"` // 能力分类详细信息
-	Tags               []TagInfo     `json:"tags,omitempty"`
-	Prerequisites      []string      `json:"prerequisites"`
-	LearningObjectives []string      `json:"learningObjectives"`
+	UpdatedAt          *model.JSONTime `json:"updatedAt,omitempty"`
+	Author             *UserInfo       `json:"author,omitempty"`
+	Abilities          []AbilityInfo   `json:"abilities,omitempty"` // 能力分类详细信息
+	Tags               []TagInfo       `json:"tags,omitempty"`
+	Prerequisites      []string        `json:"prerequisites"`
+	LearningObjectives []string        `json:"learningObjectives"`
 
 	// 统计数据
 	TotalAttempts  int     `json:"totalAttempts"`  // 总挑战次数
@@ -202,16 +209,16 @@ type StudentLevelDetailResponse struct {
 	CompletionRate float64 `json:"completionRate"` // 完成率
 
 	// 用户进度
-	Status        string     `json:"status"` // "not_started", "in_progress", "completed"
-	BestScore     int        `json:"bestScore,omitempty"`
-	AttemptsUsed  int        `json:"attemptsUsed,omitempty"`
-	CompletedAt   *time.Time `json:"completedAt,omitempty"`   // 完成时间
-	LastAttemptAt *time.Time `json:"lastAttemptAt,omitempty"` // 最后尝试时间
-	TimeSpent     int        `json:"timeSpent,omitempty"`     // 用时(秒)
+	Status        string          `json:"status"` // "not_started", "in_progress", "completed"
+	BestScore     int             `json:"bestScore,omitempty"`
+	AttemptsUsed  int             `json:"attemptsUsed,omitempty"`
+	CompletedAt   *model.JSONTime `json:"completedAt,omitempty"`   // 完成时间
+	LastAttemptAt *model.JSONTime `json:"lastAttemptAt,omitempty"` // 最后尝试时间
+	TimeSpent     int             `json:"timeSpent,omitempty"`     // 用时(秒)
 
 	// 题目信息
 	Questions []StudentQuestionResponse `json:"questions"`
-	CreatedAt time.Time                 `json:"createdAt"`
+	CreatedAt model.JSONTime            `json:"createdAt"`
 }
 
 // LevelBasicInfo 关卡基础信息响应结构体
@@ -262,6 +269,7 @@ type LevelCreateRequest struct {
 	EstimatedMinutes int                    `json:"estimatedMinutes"`
 	AttemptLimit     int                    `json:"attemptLimit"`
 	PassingScore     int                    `json:"passingScore"`
+	PassingMode      string                 `json:"passingMode"`
 	BasePoints       int                    `json:"basePoints"`
 	AllowPause       bool                   `json:"allowPause"`
 	LevelType        string                 `json:"levelType"`
@@ -287,8 +295,21 @@ func (s *LevelService) CreateLevel(creatorID uint, req LevelCreateRequest) (*mod
 	if req.VisibleScope == "specific" && len(req.VisibleTo) == 0 {
 		return nil, util.ErrVisibleToRequired
 	}
+	if err := normalizePassingMode(&req); err != nil {
+		return nil, err
+	}
+	for i := range req.Questions {
+		if err := s.normalizeQuestionLanguage(&req.Questions[i]); err != nil {
+			return nil, err
+		}
+	}
+	visibleTo, err := s.resolveVisibleTo(req.VisibleScope, req.VisibleTo)
+	if err != nil {
+		return nil, err
+	}
+	req.VisibleTo = visibleTo
 	var createdLevel *model.Level
-	err := s.DB.Transaction(func(tx *gorm.DB) error {
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
 		level := &model.Level{
 			CreatorID:        creatorID,
 			Title:            req.Title,
@@ -298,13 +319,14 @@ func (s *LevelService) CreateLevel(creatorID uint, req LevelCreateRequest) (*mod
 			EstimatedMinutes: req.EstimatedMinutes,
 			AttemptLimit:     req.AttemptLimit,
 			PassingScore:     req.PassingScore,
+			PassingMode:      req.PassingMode,
 			BasePoints:       req.BasePoints,
 			AllowPause:       req.AllowPause,
 			LevelType:        req.LevelType,
 			IsPublished:      req.IsPublished,
 			VisibleScope:     req.VisibleScope,
-			AvailableFrom:    req.AvailableFrom.TimePtr(),
-			AvailableTo:      req.AvailableTo.TimePtr(),
+			AvailableFrom:    model.NewJSONTimePtr(req.AvailableFrom.TimePtr()),
+			AvailableTo:      model.NewJSONTimePtr(req.AvailableTo.TimePtr()),
 		}
 		{
 			var vtBytes []byte
@@ -366,6 +388,7 @@ func (s *LevelService) CreateLevel(creatorID uint, req LevelCreateRequest) (*mod
 					Order:         idx + 1,
 					ScoringRule:   q.ScoringRule,
 					Explanation:   q.Explanation,
+					Language:      q.Language,
 				}
 				if err := tx.Create(question).Error; err != nil {
 					return err
@@ -409,9 +432,144 @@ func (s *LevelService) CreateLevel(creatorID uint, req LevelCreateRequest) (*mod
 	return createdLevel, nil
 }
 
-func (s *LevelService) UpdateLevel(editorID uint, levelID uint, req LevelCreateRequest) (*model.Level, error) {
+// validateVisibleTo 校验可见学生列表：去重后逐个确认账号存在且为学生，返回校验后的列表。
+// 存在无效ID时返回 ErrInvalidVisibleTo 并列出全部无效ID
+func (s *LevelService) validateVisibleTo(ids []uint) ([]uint, error) {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return unique, nil
+	}
+
+	var students []uint
+	if err := s.DB.Model(&model.User{}).
+		Where("id IN ? AND role = ?", unique, model.Student).
+		Pluck("id", &students).Error; err != nil {
+		return nil, err
+	}
+	valid := make(map[uint]bool, len(students))
+	for _, id := range students {
+		valid[id] = true
+	}
+
+	var invalid []uint
+	for _, id := range unique {
+		if !valid[id] {
+			invalid = append(invalid, id)
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("%w: %v", util.ErrInvalidVisibleTo, invalid)
+	}
+	return unique, nil
+}
+
+// resolveVisibleTo 仅在可见范围为指定学生时校验可见学生列表；其他范围不使用该列表，返回空列表将其清空
+func (s *LevelService) resolveVisibleTo(scope string, ids []uint) ([]uint, error) {
+	if scope != "specific" {
+		return []uint{}, nil
+	}
+	return s.validateVisibleTo(ids)
+}
+
+// normalizePassingMode 校验及格判定方式，为空时使用按分数判定
+func normalizePassingMode(req *LevelCreateRequest) error {
+	switch req.PassingMode {
+	case "":
+		req.PassingMode = model.PassingModeAbsolute
+	case model.PassingModeAbsolute, model.PassingModePercentage:
+	default:
+		return util.ErrInvalidPassingMode
+	}
+	if req.PassingMode == model.PassingModePercentage && (req.PassingScore < 0 || req.PassingScore > 100) {
+		return util.ErrInvalidPassingMode
+	}
+	return nil
+}
+
+// normalizeQuestionLanguage 校验编程题语言并规范化名称，非编程题忽略
+func (s *LevelService) normalizeQuestionLanguage(req *LevelQuestionRequest) error {
+	if req.QuestionType != "code" {
+		return nil
+	}
+	language, err := s.LearningService.ValidateCodeLanguage(req.Language)
+	if err != nil {
+		return err
+	}
+	req.Language = language
+	return nil
+}
+
+// weightedPoints 返回题目按权重计算的满分
+func weightedPoints(q model.LevelQuestion) int {
+	w := q.Weight
+	if w <= 0 {
+		w = 1
+	}
+	return q.Points * w
+}
+
+// determineSuccess 按关卡的及格判定方式判断得分是否通过。
+// 百分比模式下 PassingScore 为占总分 maxScore 的百分比；自动评分与人工评分必须共用此判定
+func determineSuccess(score, maxScore int, level *model.Level) bool {
+	if level.PassingMode == model.PassingModePercentage {
+		if maxScore <= 0 {
+			return false
+		}
+		return score*100 >= level.PassingScore*maxScore
+	}
+	return score >= level.PassingScore
+}
+
+// finishManualGrading 人工评分完成后结算尝试：总分为自动评分与人工评分之和，
+// 按关卡的及格判定方式（与自动评分相同）以全部题目的加权总分判断是否通过
+func finishManualGrading(attempt *model.LevelAttempt, questions []model.LevelQuestion, level *model.Level, total int, now time.Time) {
+	maxScore := 0
+	for _, q := range questions {
+		maxScore += weightedPoints(q)
+	}
+	attempt.Score = total
+	attempt.NeedsManual = false
+	attempt.Success = determineSuccess(total, maxScore, level)
+	attempt.EndedAt = &model.JSONTime{Time: now}
+}
+
+// checkLevelEditor 校验调用者是否为关卡创建者或管理员
+func (s *LevelService) checkLevelEditor(editorID uint, role model.UserRole, levelID uint) error {
+	level, err := s.LevelRepo.FindByID(levelID)
+	if err != nil {
+		return err
+	}
+	if role != model.Admin && level.CreatorID != editorID {
+		return util.ErrPermissionDenied
+	}
+	return nil
+}
+
+func (s *LevelService) UpdateLevel(ctx context.Context, editorID uint, role model.UserRole, levelID uint, req LevelCreateRequest) (*model.Level, error) {
+	if err := s.checkLevelEditor(editorID, role, levelID); err != nil {
+		return nil, err
+	}
+	visibleTo, err := s.resolveVisibleTo(req.VisibleScope, req.VisibleTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizePassingMode(&req); err != nil {
+		return nil, err
+	}
+	for i := range req.Questions {
+		if err := s.normalizeQuestionLanguage(&req.Questions[i]); err != nil {
+			return nil, err
+		}
+	}
 	var updatedLevel *model.Level
-	err := s.DB.Transaction(func(tx *gorm.DB) error {
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
 		level, err := s.LevelRepo.FindByID(levelID)
 		if err != nil {
 			return err
@@ -423,16 +581,20 @@ func (s *LevelService) UpdateLevel(editorID uint, levelID uint, req LevelCreateR
 		level.EstimatedMinutes = req.EstimatedMinutes
 		level.AttemptLimit = req.AttemptLimit
 		level.PassingScore = req.PassingScore
+		level.PassingMode = req.PassingMode
 		level.BasePoints = req.BasePoints
 		level.AllowPause = req.AllowPause
 		level.LevelType = req.LevelType
 		level.IsPublished = req.IsPublished
 		level.VisibleScope = req.VisibleScope
-		level.AvailableFrom = req.AvailableFrom.TimePtr()
-		level.AvailableTo = req.AvailableTo.TimePtr()
+		level.AvailableFrom = model.NewJSONTimePtr(req.AvailableFrom.TimePtr())
+		level.AvailableTo = model.NewJSONTimePtr(req.AvailableTo.TimePtr())
+		if vtBytes, err := json.Marshal(visibleTo); err == nil {
+			level.VisibleTo = vtBytes
+		}
 
 		if err := tx.Save(level).Error; err != nil {
-			logger.Log.Error("Failed to save level", zap.Error(err), zap.Uint("levelID", level.ID))
+			logger.FromContext(ctx).Error("Failed to save level", zap.Error(err), zap.Uint("levelID", level.ID))
 			return err
 		}
 
@@ -444,7 +606,7 @@ func (s *LevelService) UpdateLevel(editorID uint, levelID uint, req LevelCreateR
 		}
 
 		if err := tx.Where("level_id = ?", level.ID).Delete(&model.LevelAbility{}).Error; err != nil {
-			logger.Log.Error("Failed to delete level abilities", zap.Error(err), zap.Uint("levelID", level.ID))
+			logger.FromContext(ctx).Error("Failed to delete level abilities", zap.Error(err), zap.Uint("levelID", level.ID))
 			return err
 		}
 		if len(req.AbilityIDs) > 0 {
@@ -453,13 +615,13 @@ func (s *LevelService) UpdateLevel(editorID uint, levelID uint, req LevelCreateR
 				links = append(links, model.LevelAbility{LevelID: level.ID, AbilityID: aid})
 			}
 			if err := tx.Create(&links).Error; err != nil {
-				logger.Log.Error("Failed to create level abilities", zap.Error(err), zap.Uint("levelID", level.ID))
+				logger.FromContext(ctx).Error("Failed to create level abilities", zap.Error(err), zap.Uint("levelID", level.ID))
 				return err
 			}
 		}
 
 		if err := tx.Where("level_id = ?", level.ID).Delete(&model.LevelKnowledge{}).Error; err != nil {
-			logger.Log.Error("Failed to delete level knowledge", zap.Error(err), zap.Uint("levelID", level.ID))
+			logger.FromContext(ctx).Error("Failed to delete level knowledge", zap.Error(err), zap.Uint("levelID", level.ID))
 			return err
 		}
 		if len(req.KnowledgeTagIDs) > 0 {
@@ -468,13 +630,13 @@ func (s *LevelService) UpdateLevel(editorID uint, levelID uint, req LevelCreateR
 				links = append(links, model.LevelKnowledge{LevelID: level.ID, KnowledgeTagID: kid})
 			}
 			if err := tx.Create(&links).Error; err != nil {
-				logger.Log.Error("Failed to create level knowledge", zap.Error(err), zap.Uint("levelID", level.ID))
+				logger.FromContext(ctx).Error("Failed to create level knowledge", zap.Error(err), zap.Uint("levelID", level.ID))
 				return err
 			}
 		}
 
 		if err := tx.Where("level_id = ?", level.ID).Delete(&model.LevelQuestion{}).Error; err != nil {
-			logger.Log.Error("Failed to delete level questions", zap.Error(err), zap.Uint("levelID", level.ID))
+			logger.FromContext(ctx).Error("Failed to delete level questions", zap.Error(err), zap.Uint("levelID", level.ID))
 			return err
 		}
 		if len(req.Questions) > 0 {
@@ -495,10 +657,11 @@ func (s *LevelService) UpdateLevel(editorID uint, levelID uint, req LevelCreateR
 					Order:         idx + 1,
 					ScoringRule:   q.ScoringRule,
 					Explanation:   q.Explanation,
+					Language:      q.Language,
 				})
 			}
 			if err := tx.Create(&qEntities).Error; err != nil {
-				logger.Log.Error("Failed to create level questions", zap.Error(err), zap.Uint("levelID", level.ID))
+				logger.FromContext(ctx).Error("Failed to create level questions", zap.Error(err), zap.Uint("levelID", level.ID))
 				return err
 			}
 		}
@@ -548,7 +711,123 @@ func (s *LevelService) UpdateLevel(editorID uint, levelID uint, req LevelCreateR
 	return updatedLevel, nil
 }
 
-func (s *LevelService) PublishLevel(editorID, levelID uint, publish bool) error {
+// PublishLevel 发布/下架关卡。发布前会校验关卡，存在阻断性问题时返回 ErrLevelValidationFailed
+// 及校验结果，force 为 true 时跳过校验强制发布
+func (s *LevelService) PublishLevel(editorID uint, role model.UserRole, levelID uint, publish, force bool) (*LevelValidationResult, error) {
+	if err := s.checkLevelEditor(editorID, role, levelID); err != nil {
+		return nil, err
+	}
+	if publish && !force {
+		result, err := s.validateLevel(levelID)
+		if err != nil {
+			return nil, err
+		}
+		if !result.Valid {
+			return result, util.ErrLevelValidationFailed
+		}
+	}
+	return nil, s.publishLevel(editorID, levelID, publish)
+}
+
+const (
+	LevelIssueError   = "error"   // 阻断发布
+	LevelIssueWarning = "warning" // 仅提示
+)
+
+// LevelValidationIssue 关卡校验发现的问题，QuestionID 为 0 表示关卡级问题
+type LevelValidationIssue struct {
+	Severity   string `json:"severity"` // error/warning
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	QuestionID uint   `json:"questionId,omitempty"`
+}
+
+// LevelValidationResult 关卡发布前校验结果
+type LevelValidationResult struct {
+	LevelID     uint                   `json:"levelId"`
+	Valid       bool                   `json:"valid"` // 没有 error 级问题
+	TotalPoints int                    `json:"totalPoints"`
+	Issues      []LevelValidationIssue `json:"issues"`
+}
+
+// ValidateLevel 校验关卡是否可以发布
+func (s *LevelService) ValidateLevel(editorID uint, role model.UserRole, levelID uint) (*LevelValidationResult, error) {
+	if err := s.checkLevelEditor(editorID, role, levelID); err != nil {
+		return nil, err
+	}
+	return s.validateLevel(levelID)
+}
+
+// validateLevel 检查关卡是否有题目、及格分是否合理、自动评分题是否设置了正确答案、总分能否达到及格分
+func (s *LevelService) validateLevel(levelID uint) (*LevelValidationResult, error) {
+	level, err := s.LevelRepo.FindByID(levelID)
+	if err != nil {
+		return nil, err
+	}
+	questions, err := s.LevelRepo.GetQuestionsByLevel(levelID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &LevelValidationResult{LevelID: levelID, Issues: []LevelValidationIssue{}}
+	addIssue := func(severity, code, message string, questionID uint) {
+		result.Issues = append(result.Issues, LevelValidationIssue{Severity: severity, Code: code, Message: message, QuestionID: questionID})
+	}
+
+	if len(questions) == 0 {
+		addIssue(LevelIssueError, "no_questions", "关卡没有题目", 0)
+	}
+	for _, q := range questions {
+		result.TotalPoints += weightedPoints(q)
+		if q.Points <= 0 {
+			addIssue(LevelIssueWarning, "zero_points", "题目分值为 0，作答不影响得分", q.ID)
+		}
+		if q.ManualGrading {
+			continue
+		}
+		if isEmptyJSONValue(q.CorrectAnswer) {
+			addIssue(LevelIssueError, "missing_answer", "自动评分题目未设置正确答案", q.ID)
+		}
+		if q.QuestionType == "multiple_choice" && isEmptyJSONValue(q.Options) {
+			addIssue(LevelIssueError, "missing_options", "选择题未设置选项", q.ID)
+		}
+	}
+
+	switch {
+	case level.PassingScore <= 0:
+		addIssue(LevelIssueWarning, "passing_score_zero", "及格分为 0，任何作答都会判定通过", 0)
+	case level.PassingMode == model.PassingModePercentage:
+		if level.PassingScore > 100 {
+			addIssue(LevelIssueError, "passing_score_invalid", "百分比模式下及格分不能超过 100", 0)
+		} else if len(questions) > 0 && result.TotalPoints == 0 {
+			addIssue(LevelIssueError, "passing_score_unreachable", "题目总分为 0，百分比模式下学生无法通过", 0)
+		}
+	case len(questions) > 0 && result.TotalPoints < level.PassingScore:
+		addIssue(LevelIssueError, "passing_score_unreachable",
+			fmt.Sprintf("题目总分 %d 低于及格分 %d，学生无法通过", result.TotalPoints, level.PassingScore), 0)
+	}
+
+	result.Valid = true
+	for _, issue := range result.Issues {
+		if issue.Severity == LevelIssueError {
+			result.Valid = false
+			break
+		}
+	}
+	return result, nil
+}
+
+// isEmptyJSONValue 判断以 JSON 字符串保存的字段是否为空值
+func isEmptyJSONValue(raw string) bool {
+	switch strings.TrimSpace(raw) {
+	case "", "null", `""`, "[]", "{}":
+		return true
+	}
+	return false
+}
+
+// publishLevel 执行发布/下架并写入版本记录，不做权限校验
+func (s *LevelService) publishLevel(editorID, levelID uint, publish bool) error {
 	return s.DB.Transaction(func(tx *gorm.DB) error {
 		level, err := s.LevelRepo.FindByID(levelID)
 		if err != nil {
@@ -557,7 +836,7 @@ func (s *LevelService) PublishLevel(editorID, levelID uint, publish bool) error
 		level.IsPublished = publish
 		if publish {
 			now := time.Now()
-			level.PublishedAt = &now
+			level.PublishedAt = &model.JSONTime{Time: now}
 		} else {
 			level.PublishedAt = nil
 		}
@@ -634,6 +913,7 @@ func (s *LevelService) RollbackToVersion(editorID uint, levelID uint, versionID
 		level.EstimatedMinutes = snap.Level.EstimatedMinutes
 		level.AttemptLimit = snap.Level.AttemptLimit
 		level.PassingScore = snap.Level.PassingScore
+		level.PassingMode = snap.Level.PassingMode
 		level.BasePoints = snap.Level.BasePoints
 		level.AllowPause = snap.Level.AllowPause
 		level.LevelType = snap.Level.LevelType
@@ -688,13 +968,99 @@ func (s *LevelService) RollbackToVersion(editorID uint, levelID uint, versionID
 	})
 }
 
+// levelSnapshotCorrupted 判断版本快照内容是否无法解析为关卡和题目
+func levelSnapshotCorrupted(content string) bool {
+	var snap struct {
+		Level     model.Level           `json:"level"`
+		Questions []model.LevelQuestion `json:"questions"`
+	}
+	return json.Unmarshal([]byte(content), &snap) != nil
+}
+
+// RegenerateCurrentVersion 用关卡当前数据和题目重建版本快照，用于修复损坏（无法解析）的当前版本。
+// 原当前版本快照损坏时，仍在进行中的挑战改为使用新版本判分
+func (s *LevelService) RegenerateCurrentVersion(editorID, levelID uint) (*model.LevelVersion, error) {
+	var regenerated *model.LevelVersion
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		levelRepo := &repository.LevelRepository{DB: tx}
+		level, err := levelRepo.FindByID(levelID)
+		if err != nil {
+			return err
+		}
+
+		brokenVersionID := uint(0)
+		if level.CurrentVersion > 0 {
+			if v, err := levelRepo.GetVersionByID(level.CurrentVersion); err == nil && levelSnapshotCorrupted(v.Content) {
+				brokenVersionID = v.ID
+			}
+		}
+
+		var questions []model.LevelQuestion
+		if err := tx.Where("level_id = ?", level.ID).Order("id").Find(&questions).Error; err != nil {
+			return err
+		}
+		snapshot := map[string]interface{}{"level": level, "questions": questions}
+		content, err := json.Marshal(snapshot)
+		if err != nil {
+			return err
+		}
+
+		versions, err := levelRepo.GetVersions(level.ID)
+		if err != nil {
+			return err
+		}
+		nextVersion := 1
+		if len(versions) > 0 {
+			nextVersion = versions[0].VersionNumber + 1
+		}
+		v := &model.LevelVersion{
+			LevelID:       level.ID,
+			VersionNumber: nextVersion,
+			EditorID:      editorID,
+			ChangeNote:    "Regenerate snapshot",
+			Content:       string(content),
+			IsPublished:   level.IsPublished,
+			PublishedAt:   level.PublishedAt,
+		}
+		if err := tx.Create(v).Error; err != nil {
+			return err
+		}
+		level.CurrentVersion = v.ID
+		if err := tx.Save(level).Error; err != nil {
+			return err
+		}
+
+		if brokenVersionID > 0 {
+			if err := tx.Model(&model.LevelAttempt{}).
+				Where("version_id = ? AND ended_at IS NULL", brokenVersionID).
+				Update("version_id", v.ID).Error; err != nil {
+				return err
+			}
+			logger.Log.Warn("Regenerated corrupted level version snapshot",
+				zap.Uint("levelID", level.ID),
+				zap.Uint("brokenVersionID", brokenVersionID),
+				zap.Uint("newVersionID", v.ID))
+		}
+
+		regenerated = v
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, util.ErrLevelNotFound
+		}
+		return nil, err
+	}
+	return regenerated, nil
+}
+
 func (s *LevelService) GetAllLevelsBasicInfo() ([]LevelBasicInfo, error) {
 	levels, err := s.LevelRepo.GetAllLevelsBasicInfo()
 	if err != nil {
 		return nil, err
 	}
 
-	var basicInfos []LevelBasicInfo
+	basicInfos := make([]LevelBasicInfo, 0, len(levels))
 	for _, level := range levels {
 		basicInfos = append(basicInfos, LevelBasicInfo{
 			ID:    level.ID,
@@ -723,7 +1089,7 @@ func (s *LevelService) StartAttempt(userID, levelID uint) (*model.LevelAttempt,
 		LevelID:          levelID,
 		UserID:           userID,
 		AttemptsUsed:     int(count) + 1,
-		StartedAt:        time.Now(),
+		StartedAt:        model.NewJSONTime(time.Now()),
 		VersionID:        level.CurrentVersion,
 		PerQuestionTimes: "{}",
 	}
@@ -802,10 +1168,10 @@ func (s *LevelService) SubmitAttempt(userID, levelID, attemptID uint, answers []
 	}
 
 	now := time.Now()
-	duration := int(now.Sub(attempt.StartedAt).Seconds())
+	duration := int(now.Sub(attempt.StartedAt.Time).Seconds())
 	attempt.Score = totalScore
 	attempt.TotalTimeSeconds = duration
-	attempt.EndedAt = &now
+	attempt.EndedAt = &model.JSONTime{Time: now}
 	attempt.NeedsManual = needsManual
 
 	level, err := s.LevelRepo.FindByID(levelID)
@@ -815,7 +1181,11 @@ func (s *LevelService) SubmitAttempt(userID, levelID, attemptID uint, answers []
 	if needsManual {
 		attempt.Success = false
 	} else {
-		attempt.Success = totalScore >= level.PassingScore && attempt.AttemptsUsed <= level.AttemptLimit
+		maxScore := 0
+		for _, q := range qMap {
+			maxScore += weightedPoints(q)
+		}
+		attempt.Success = determineSuccess(totalScore, maxScore, level) && attempt.AttemptsUsed <= level.AttemptLimit
 	}
 
 	err = s.DB.Transaction(func(tx *gorm.DB) error {
@@ -858,13 +1228,19 @@ func (s *LevelService) SubmitAttempt(userID, levelID, attemptID uint, answers []
 }
 
 // AddQuestion 向关卡添加单个题目
-func (s *LevelService) AddQuestion(editorID, levelID uint, req LevelQuestionRequest) (*model.LevelQuestion, error) {
+func (s *LevelService) AddQuestion(editorID uint, role model.UserRole, levelID uint, req LevelQuestionRequest) (*model.LevelQuestion, error) {
+	if err := s.checkLevelEditor(editorID, role, levelID); err != nil {
+		return nil, err
+	}
 	if req.QuestionType == "" {
 		return nil, util.ErrQuestionTypeRequired
 	}
 	if req.Content == nil {
 		return nil, util.ErrContentRequired
 	}
+	if err := s.normalizeQuestionLanguage(&req); err != nil {
+		return nil, err
+	}
 	cb, _ := json.Marshal(req.Content)
 	ob, _ := json.Marshal(req.Options)
 	correct, _ := json.Marshal(req.CorrectAnswer)
@@ -879,6 +1255,7 @@ func (s *LevelService) AddQuestion(editorID, levelID uint, req LevelQuestionRequ
 		ManualGrading: req.ManualGrading,
 		ScoringRule:   req.ScoringRule,
 		Explanation:   req.Explanation,
+		Language:      req.Language,
 	}
 	if err := s.LevelRepo.CreateQuestion(q); err != nil {
 		return nil, err
@@ -887,7 +1264,10 @@ func (s *LevelService) AddQuestion(editorID, levelID uint, req LevelQuestionRequ
 }
 
 // UpdateQuestion 更新题目
-func (s *LevelService) UpdateQuestion(editorID, levelID, questionID uint, req LevelQuestionRequest) (*model.LevelQuestion, error) {
+func (s *LevelService) UpdateQuestion(editorID uint, role model.UserRole, levelID, questionID uint, req LevelQuestionRequest) (*model.LevelQuestion, error) {
+	if err := s.checkLevelEditor(editorID, role, levelID); err != nil {
+		return nil, err
+	}
 	q, err := s.LevelRepo.FindQuestionByID(questionID)
 	if err != nil {
 		return nil, err
@@ -895,6 +1275,9 @@ func (s *LevelService) UpdateQuestion(editorID, levelID, questionID uint, req Le
 	if q.LevelID != levelID {
 		return nil, util.ErrQuestionNotBelong
 	}
+	if err := s.normalizeQuestionLanguage(&req); err != nil {
+		return nil, err
+	}
 	if req.Content != nil {
 		cb, _ := json.Marshal(req.Content)
 		q.Content = string(cb)
@@ -913,6 +1296,7 @@ func (s *LevelService) UpdateQuestion(editorID, levelID, questionID uint, req Le
 	q.ManualGrading = req.ManualGrading
 	q.ScoringRule = req.ScoringRule
 	q.Explanation = req.Explanation
+	q.Language = req.Language
 	if err := s.LevelRepo.UpdateQuestion(q); err != nil {
 		return nil, err
 	}
@@ -920,7 +1304,10 @@ func (s *LevelService) UpdateQuestion(editorID, levelID, questionID uint, req Le
 }
 
 // DeleteQuestion 删除题目
-func (s *LevelService) DeleteQuestion(levelID, questionID uint) error {
+func (s *LevelService) DeleteQuestion(editorID uint, role model.UserRole, levelID, questionID uint) error {
+	if err := s.checkLevelEditor(editorID, role, levelID); err != nil {
+		return err
+	}
 	q, err := s.LevelRepo.FindQuestionByID(questionID)
 	if err != nil {
 		return err
@@ -964,8 +1351,282 @@ func (s *LevelService) ListAttemptsNeedingManual(levelID uint) ([]model.LevelAtt
 	return s.LevelAttemptRepo.ListNeedingManual(levelID)
 }
 
+// AttemptQuestionReview 尝试中单题的作答与评分情况
+type AttemptQuestionReview struct {
+	QuestionID    uint        `json:"questionId"`
+	QuestionType  string      `json:"questionType"`
+	Content       interface{} `json:"content"`
+	Answer        interface{} `json:"answer"`
+	CorrectAnswer interface{} `json:"correctAnswer,omitempty"`
+	MaxScore      int         `json:"maxScore"`
+	Score         int         `json:"score"`
+	Status        string      `json:"status"` // correct, incorrect, unanswered, graded（已人工评分）, pending（待人工评分）
+	Comment       string      `json:"comment,omitempty"`
+}
+
+// StudentAttemptReview 学生一次关卡尝试的复盘数据，题目取自尝试开始时的版本快照
+type StudentAttemptReview struct {
+	AttemptID        uint                    `json:"attemptId"`
+	LevelID          uint                    `json:"levelId"`
+	LevelTitle       string                  `json:"levelTitle"`
+	VersionID        uint                    `json:"versionId"`
+	Score            int                     `json:"score"`
+	MaxScore         int                     `json:"maxScore"`
+	Success          bool                    `json:"success"`
+	NeedsManual      bool                    `json:"needsManual"`
+	StartedAt        model.JSONTime          `json:"startedAt"`
+	EndedAt          *model.JSONTime         `json:"endedAt,omitempty"`
+	TotalTimeSeconds int                     `json:"totalTimeSeconds"`
+	Questions        []AttemptQuestionReview `json:"questions"`
+}
+
+// GetStudentAttemptReviews 获取学生最近已结束的尝试（跨关卡）及逐题对错和教师评语。
+// 尝试、答案、人工评分、版本快照各用一次批量查询，不随尝试数量增加查询次数
+func (s *LevelService) GetStudentAttemptReviews(studentID uint, limit int) ([]StudentAttemptReview, error) {
+	attempts, err := s.LevelAttemptRepo.ListRecentEndedByUser(studentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	reviews := make([]StudentAttemptReview, 0, len(attempts))
+	if len(attempts) == 0 {
+		return reviews, nil
+	}
+
+	attemptIDs := make([]uint, 0, len(attempts))
+	var levelIDs, versionIDs []uint
+	seenLevel := make(map[uint]bool)
+	seenVersion := make(map[uint]bool)
+	for _, a := range attempts {
+		attemptIDs = append(attemptIDs, a.ID)
+		if !seenLevel[a.LevelID] {
+			seenLevel[a.LevelID] = true
+			levelIDs = append(levelIDs, a.LevelID)
+		}
+		if a.VersionID > 0 && !seenVersion[a.VersionID] {
+			seenVersion[a.VersionID] = true
+			versionIDs = append(versionIDs, a.VersionID)
+		}
+	}
+
+	answers, err := s.LevelAttemptRepo.GetAnswersByAttempts(attemptIDs)
+	if err != nil {
+		return nil, err
+	}
+	answerMap := make(map[uint]map[uint]string, len(attempts))
+	for _, ans := range answers {
+		if answerMap[ans.AttemptID] == nil {
+			answerMap[ans.AttemptID] = make(map[uint]string)
+		}
+		answerMap[ans.AttemptID][ans.QuestionID] = ans.Answer
+	}
+
+	scores, err := s.LevelAttemptRepo.GetQuestionScoresByAttempts(attemptIDs)
+	if err != nil {
+		return nil, err
+	}
+	scoreMap := make(map[uint]map[uint]model.LevelAttemptQuestionScore, len(attempts))
+	for _, sc := range scores {
+		if scoreMap[sc.AttemptID] == nil {
+			scoreMap[sc.AttemptID] = make(map[uint]model.LevelAttemptQuestionScore)
+		}
+		scoreMap[sc.AttemptID][sc.QuestionID] = sc
+	}
+
+	versions, err := s.LevelRepo.GetVersionsByIDs(versionIDs)
+	if err != nil {
+		return nil, err
+	}
+	versionQuestions := make(map[uint][]model.LevelQuestion, len(versions))
+	for _, v := range versions {
+		var snap struct {
+			Questions []model.LevelQuestion `json:"questions"`
+		}
+		if err := json.Unmarshal([]byte(v.Content), &snap); err == nil && len(snap.Questions) > 0 {
+			versionQuestions[v.ID] = snap.Questions
+		}
+	}
+
+	// 没有版本快照（或快照损坏）的尝试退回使用关卡当前题目
+	currentQuestions := make(map[uint][]model.LevelQuestion)
+	var fallbackLevelIDs []uint
+	seenFallback := make(map[uint]bool)
+	for _, a := range attempts {
+		if _, ok := versionQuestions[a.VersionID]; !ok && !seenFallback[a.LevelID] {
+			seenFallback[a.LevelID] = true
+			fallbackLevelIDs = append(fallbackLevelIDs, a.LevelID)
+		}
+	}
+	current, err := s.LevelRepo.GetQuestionsByLevels(fallbackLevelIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, q := range current {
+		currentQuestions[q.LevelID] = append(currentQuestions[q.LevelID], q)
+	}
+
+	titles, err := s.LevelRepo.GetTitlesByIDs(levelIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range attempts {
+		questions, ok := versionQuestions[a.VersionID]
+		if !ok {
+			questions = currentQuestions[a.LevelID]
+		}
+		review := StudentAttemptReview{
+			AttemptID:        a.ID,
+			LevelID:          a.LevelID,
+			LevelTitle:       titles[a.LevelID],
+			VersionID:        a.VersionID,
+			Score:            a.Score,
+			Success:          a.Success,
+			NeedsManual:      a.NeedsManual,
+			StartedAt:        a.StartedAt,
+			EndedAt:          a.EndedAt,
+			TotalTimeSeconds: a.TotalTimeSeconds,
+			Questions:        make([]AttemptQuestionReview, 0, len(questions)),
+		}
+		for _, q := range questions {
+			review.MaxScore += weightedPoints(q)
+			review.Questions = append(review.Questions, reviewAttemptQuestion(q, answerMap[a.ID], scoreMap[a.ID]))
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, nil
+}
+
+// reviewAttemptQuestion 按提交时的判分规则（答案 JSON 与正确答案完全一致）判断单题对错，人工评分题取教师评分
+func reviewAttemptQuestion(q model.LevelQuestion, answers map[uint]string, scores map[uint]model.LevelAttemptQuestionScore) AttemptQuestionReview {
+	item := AttemptQuestionReview{
+		QuestionID:   q.ID,
+		QuestionType: q.QuestionType,
+		MaxScore:     weightedPoints(q),
+	}
+	_ = json.Unmarshal([]byte(q.Content), &item.Content)
+	_ = json.Unmarshal([]byte(q.CorrectAnswer), &item.CorrectAnswer)
+
+	raw, answered := answers[q.ID]
+	var provided interface{}
+	if answered {
+		answered = json.Unmarshal([]byte(raw), &provided) == nil
+		item.Answer = provided
+	}
+
+	if q.ManualGrading {
+		if sc, ok := scores[q.ID]; ok {
+			item.Status = "graded"
+			item.Score = sc.Score
+			item.Comment = sc.Comment
+		} else {
+			item.Status = "pending"
+		}
+		return item
+	}
+	if sc, ok := scores[q.ID]; ok {
+		item.Comment = sc.Comment
+	}
+
+	if !answered {
+		item.Status = "unanswered"
+		return item
+	}
+	providedBytes, _ := json.Marshal(provided)
+	if string(providedBytes) == q.CorrectAnswer {
+		item.Status = "correct"
+		item.Score = item.MaxScore
+	} else {
+		item.Status = "incorrect"
+	}
+	return item
+}
+
 // ManualGradeAttempt 保存人工评分并完成尝试（若全部题目评分完成）
 func (s *LevelService) ManualGradeAttempt(graderID uint, attemptID uint, scores []QuestionScore) error {
+	var graded *model.LevelAttempt
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		graded, err = gradeAttempt(&repository.LevelRepository{DB: tx}, &repository.LevelAttemptRepository{DB: tx}, graderID, attemptID, scores)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.notifyAttemptGraded(graded)
+	return nil
+}
+
+// AttemptGrade 批量评分中单个尝试的评分数据
+type AttemptGrade struct {
+	AttemptID uint
+	Scores    []QuestionScore
+}
+
+// AttemptGradeResult 批量评分中单个尝试的评分结果
+type AttemptGradeResult struct {
+	AttemptID uint `json:"attemptId"`
+	UserID    uint `json:"userId"`
+	Score     int  `json:"score"`
+	Success   bool `json:"success"`
+}
+
+// BulkGradeAttempts 在一个事务内批量人工评分同一关卡的多个尝试，任一失败则全部回滚。
+// 仅关卡创建者或管理员可操作，评分完成后逐个通知学生
+func (s *LevelService) BulkGradeAttempts(graderID uint, role model.UserRole, levelID uint, grades []AttemptGrade) ([]AttemptGradeResult, error) {
+	if err := s.checkLevelEditor(graderID, role, levelID); err != nil {
+		return nil, err
+	}
+
+	graded := make([]*model.LevelAttempt, 0, len(grades))
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		levelRepo := &repository.LevelRepository{DB: tx}
+		attemptRepo := &repository.LevelAttemptRepository{DB: tx}
+		for _, g := range grades {
+			attempt, err := levelRepo.FindAttemptByID(g.AttemptID)
+			if err != nil {
+				return err
+			}
+			if attempt.LevelID != levelID {
+				return fmt.Errorf("%w: %d", util.ErrAttemptNotBelong, g.AttemptID)
+			}
+			result, err := gradeAttempt(levelRepo, attemptRepo, graderID, g.AttemptID, g.Scores)
+			if err != nil {
+				return err
+			}
+			graded = append(graded, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]AttemptGradeResult, 0, len(graded))
+	for _, a := range graded {
+		results = append(results, AttemptGradeResult{
+			AttemptID: a.ID,
+			UserID:    a.UserID,
+			Score:     a.Score,
+			Success:   a.Success,
+		})
+		s.notifyAttemptGraded(a)
+	}
+	return results, nil
+}
+
+// notifyAttemptGraded 发布评分完成事件，由订阅者通知学生
+func (s *LevelService) notifyAttemptGraded(attempt *model.LevelAttempt) {
+	s.Events.Publish(EventAttemptGraded, AttemptGradedEvent{
+		UserID:    attempt.UserID,
+		LevelID:   attempt.LevelID,
+		AttemptID: attempt.ID,
+		Score:     attempt.Score,
+		Success:   attempt.Success,
+	})
+}
+
+// gradeAttempt 保存人工评分并重新计算总分、完成尝试。levelRepo/attemptRepo 可为事务内的仓库
+func gradeAttempt(levelRepo *repository.LevelRepository, attemptRepo *repository.LevelAttemptRepository, graderID uint, attemptID uint, scores []QuestionScore) (*model.LevelAttempt, error) {
 	// save scores
 	var scoreEntities []model.LevelAttemptQuestionScore
 	now := time.Now()
@@ -976,23 +1637,22 @@ func (s *LevelService) ManualGradeAttempt(graderID uint, attemptID uint, scores
 			Score:      sc.Score,
 			GraderID:   graderID,
 			Comment:    sc.Comment,
-			GradedAt:   &now,
+			GradedAt:   &model.JSONTime{Time: now},
 		})
 	}
 
-	err := s.LevelAttemptRepo.CreateOrUpdateQuestionScores(scoreEntities)
+	attempt, err := levelRepo.FindAttemptByID(attemptID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	attempt, err := s.LevelRepo.FindAttemptByID(attemptID)
-	if err != nil {
-		return err
+	if err := attemptRepo.CreateOrUpdateQuestionScores(scoreEntities); err != nil {
+		return nil, err
 	}
 
 	var questions []model.LevelQuestion
 	if attempt.VersionID > 0 {
-		if v, err := s.LevelRepo.GetVersionByID(attempt.VersionID); err == nil {
+		if v, err := levelRepo.GetVersionByID(attempt.VersionID); err == nil {
 			var snap struct {
 				Level     model.Level           `json:"level"`
 				Questions []model.LevelQuestion `json:"questions"`
@@ -1003,9 +1663,9 @@ func (s *LevelService) ManualGradeAttempt(graderID uint, attemptID uint, scores
 		}
 	}
 	if len(questions) == 0 {
-		questions, err = s.LevelRepo.GetQuestionsByLevel(attempt.LevelID)
+		questions, err = levelRepo.GetQuestionsByLevel(attempt.LevelID)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 	autoScore := 0
@@ -1013,7 +1673,7 @@ func (s *LevelService) ManualGradeAttempt(graderID uint, attemptID uint, scores
 		if q.ManualGrading {
 			continue
 		}
-		if ans, err := s.LevelAttemptRepo.GetAnswerByQuestion(attemptID, q.ID); err == nil {
+		if ans, err := attemptRepo.GetAnswerByQuestion(attemptID, q.ID); err == nil {
 			var provided interface{}
 			if json.Unmarshal([]byte(ans.Answer), &provided) == nil {
 				providedBytes, _ := json.Marshal(provided)
@@ -1028,45 +1688,69 @@ func (s *LevelService) ManualGradeAttempt(graderID uint, attemptID uint, scores
 		}
 	}
 
-	manualTotal, err := s.LevelAttemptRepo.GetTotalManualScore(attemptID)
+	manualTotal, err := attemptRepo.GetTotalManualScore(attemptID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	newTotal := autoScore + int(manualTotal)
-	now2 := time.Now()
-	attempt.Score = newTotal
-	attempt.NeedsManual = false
-	level, err := s.LevelRepo.FindByID(attempt.LevelID)
+	level, err := levelRepo.FindByID(attempt.LevelID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	attempt.Success = newTotal >= level.PassingScore
-	attempt.EndedAt = &now2
+	finishManualGrading(attempt, questions, level, autoScore+int(manualTotal), time.Now())
 
-	if err := s.LevelRepo.UpdateAttempt(attempt); err != nil {
-		return err
+	if err := levelRepo.UpdateAttempt(attempt); err != nil {
+		return nil, err
 	}
-	return nil
+	return attempt, nil
 }
 
-// BulkPublish 批量发布/下架（会为每个关卡创建版本记录）
-func (s *LevelService) BulkPublish(editorID uint, ids []uint, publish bool) error {
+// BulkPublish 批量发布/下架（会为每个关卡创建版本记录）。
+// 先确认调用者可以编辑所有关卡，任一关卡无权限时整体拒绝，不做部分修改；
+// 发布时与 PublishLevel 一样逐个校验，存在阻断性问题时返回 ErrLevelValidationFailed 及未通过的关卡，force 为 true 时跳过校验
+func (s *LevelService) BulkPublish(editorID uint, role model.UserRole, ids []uint, publish, force bool) ([]*LevelValidationResult, error) {
+	levels := make([]*model.Level, 0, len(ids))
 	for _, id := range ids {
 		level, err := s.LevelRepo.FindByID(id)
 		if err != nil {
-			return fmt.Errorf("level with id %d not found", id)
+			return nil, fmt.Errorf("level with id %d not found", id)
 		}
+		if role != model.Admin && level.CreatorID != editorID {
+			return nil, util.ErrPermissionDenied
+		}
+		levels = append(levels, level)
+	}
+
+	if publish && !force {
+		var failed []*LevelValidationResult
+		for _, level := range levels {
+			if level.IsPublished {
+				continue
+			}
+			result, err := s.validateLevel(level.ID)
+			if err != nil {
+				return nil, err
+			}
+			if !result.Valid {
+				failed = append(failed, result)
+			}
+		}
+		if len(failed) > 0 {
+			return failed, util.ErrLevelValidationFailed
+		}
+	}
 
+	for _, level := range levels {
+		id := level.ID
 		if level.IsPublished == publish {
 			continue
 		}
 
-		if err := s.PublishLevel(editorID, id, publish); err != nil {
-			return err
+		if err := s.publishLevel(editorID, id, publish); err != nil {
+			return nil, err
 		}
 	}
-	return nil
+	return nil, nil
 }
 
 // SchedulePublish 设置/取消定时发布
@@ -1075,7 +1759,7 @@ func (s *LevelService) SchedulePublish(editorID, levelID uint, scheduledAt *time
 	if err != nil {
 		return err
 	}
-	level.ScheduledPublishAt = scheduledAt
+	level.ScheduledPublishAt = model.NewJSONTimePtr(scheduledAt)
 	return s.LevelRepo.UpdateLevel(level)
 }
 
@@ -1088,6 +1772,10 @@ func (s *LevelService) UpdateVisibility(editorID, levelID uint, visibleScope str
 	if visibleScope == "specific" && len(visibleTo) == 0 {
 		return util.ErrVisibleToRequired
 	}
+	visibleTo, err = s.resolveVisibleTo(visibleScope, visibleTo)
+	if err != nil {
+		return err
+	}
 	// marshal visibleTo
 	vtBytes, _ := json.Marshal(visibleTo)
 	level.VisibleScope = visibleScope
@@ -1104,7 +1792,7 @@ func (s *LevelService) ProcessScheduledPublishes() error {
 	}
 	for _, lvl := range levels {
 		// publish using existing logic
-		if err := s.PublishLevel(0, lvl.ID, true); err != nil {
+		if err := s.publishLevel(0, lvl.ID, true); err != nil {
 			logger.Log.Error("自动发布关卡失败", zap.Uint("levelID", lvl.ID), zap.Error(err))
 			continue
 		}
@@ -1123,86 +1811,129 @@ func (s *LevelService) ListLevelsFull(creatorID uint, page, limit int) ([]LevelF
 		return nil, 0, err
 	}
 
-	var fullLevels []LevelFullResponse
-	for _, level := range levels {
-		// 获取能力关联
-		var abilityIDs []uint
-		levelAbilities, err := s.LevelRepo.GetLevelAbilities(level.ID)
-		if err == nil {
-			for _, la := range levelAbilities {
-				abilityIDs = append(abilityIDs, la.AbilityID)
-			}
+	fullLevels := make([]LevelFullResponse, 0, len(levels))
+	for i := range levels {
+		// 能力、知识标签或题目加载失败时只记录日志，该关卡缺少对应数据，不影响整个列表
+		fullLevel, err := s.buildLevelFullResponse(&levels[i])
+		if err != nil {
+			logger.Log.Warn("Failed to load level details for list", zap.Error(err), zap.Uint("levelID", levels[i].ID))
 		}
+		fullLevels = append(fullLevels, *fullLevel)
+	}
 
-		// 获取知识标签关联
-		var knowledgeTagIDs []uint
-		levelKnowledge, err := s.LevelRepo.GetLevelKnowledge(level.ID)
-		if err == nil {
-			for _, lk := range levelKnowledge {
-				knowledgeTagIDs = append(knowledgeTagIDs, lk.KnowledgeTagID)
-			}
-		}
+	return fullLevels, total, nil
+}
 
-		// 获取题目信息
-		var questions []LevelQuestionResponse
-		levelQuestions, err := s.LevelRepo.GetQuestionsByLevel(level.ID)
-		if err == nil {
-			for _, q := range levelQuestions {
-				questions = append(questions, LevelQuestionResponse{
-					ID:            q.ID,
-					CreatedAt:     q.CreatedAt,
-					UpdatedAt:     q.UpdatedAt,
-					LevelID:       q.LevelID,
-					QuestionType:  q.QuestionType,
-					Content:       json.RawMessage(q.Content),
-					Options:       json.RawMessage(q.Options),
-					CorrectAnswer: json.RawMessage(q.CorrectAnswer),
-					Points:        q.Points,
-					Weight:        q.Weight,
-					ManualGrading: q.ManualGrading,
-					Order:         q.Order,
-					ScoringRule:   q.ScoringRule,
-					Explanation:   q.Explanation,
-					CodeTemplate:  "", // 如果有的话需要从 Content 中解析
-				})
-			}
-		}
+// GetLevelForEdit 获取编辑器所需的完整关卡数据（题目按顺序、能力与知识标签ID），结构与 LevelCreateRequest 对应。
+// 仅关卡创建者或管理员可获取
+func (s *LevelService) GetLevelForEdit(editorID uint, role model.UserRole, levelID uint) (*LevelFullResponse, error) {
+	level, err := s.LevelRepo.FindByID(levelID)
+	if err != nil {
+		return nil, err
+	}
+	if role != model.Admin && level.CreatorID != editorID {
+		return nil, util.ErrPermissionDenied
+	}
+	fullLevel, err := s.buildLevelFullResponse(level)
+	if err != nil {
+		return nil, err
+	}
+	return fullLevel, nil
+}
+
+// buildLevelFullResponse 加载关卡的能力、知识标签和题目并组装完整响应。
+// 某项加载失败时该项留空并继续组装，返回的响应始终非 nil，err 汇总各项失败原因
+func (s *LevelService) buildLevelFullResponse(level *model.Level) (*LevelFullResponse, error) {
+	var errs []error
+
+	abilityIDs := make([]uint, 0)
+	levelAbilities, err := s.LevelRepo.GetLevelAbilities(level.ID)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("load abilities: %w", err))
+	}
+	for _, la := range levelAbilities {
+		abilityIDs = append(abilityIDs, la.AbilityID)
+	}
 
-		fullLevel := LevelFullResponse{
-			ID:                 level.ID,
-			CreatedAt:          level.CreatedAt,
-			UpdatedAt:          level.UpdatedAt,
-			CreatorID:          level.CreatorID,
-			Title:              level.Title,
-			Description:        level.Description,
-			CoverURL:           level.CoverURL,
-			Difficulty:         level.Difficulty,
-			EstimatedMinutes:   level.EstimatedMinutes,
-			AttemptLimit:       level.AttemptLimit,
-			PassingScore:       level.PassingScore,
-			BasePoints:         level.BasePoints,
-			AllowPause:         level.AllowPause,
-			LevelType:          level.LevelType,
-			IsPublished:        level.IsPublished,
-			PublishedAt:        level.PublishedAt,
-			ScheduledPublishAt: level.ScheduledPublishAt,
-			VisibleScope:       level.VisibleScope,
-			VisibleTo:          json.RawMessage(level.VisibleTo),
-			AvailableFrom:      level.AvailableFrom,
-			AvailableTo:        level.AvailableTo,
-			CurrentVersion:     level.CurrentVersion,
-			Abilities:          abilityIDs,
-			KnowledgeTags:      knowledgeTagIDs,
-			Questions:          questions,
-		}
-		fullLevels = append(fullLevels, fullLevel)
+	knowledgeTagIDs := make([]uint, 0)
+	levelKnowledge, err := s.LevelRepo.GetLevelKnowledge(level.ID)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("load knowledge tags: %w", err))
+	}
+	for _, lk := range levelKnowledge {
+		knowledgeTagIDs = append(knowledgeTagIDs, lk.KnowledgeTagID)
 	}
 
-	return fullLevels, total, nil
+	questions := make([]LevelQuestionResponse, 0)
+	levelQuestions, err := s.LevelRepo.GetQuestionsByLevel(level.ID)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("load questions: %w", err))
+	}
+	for _, q := range levelQuestions {
+		questions = append(questions, LevelQuestionResponse{
+			ID:            q.ID,
+			CreatedAt:     q.CreatedAt,
+			UpdatedAt:     q.UpdatedAt,
+			LevelID:       q.LevelID,
+			QuestionType:  q.QuestionType,
+			Content:       json.RawMessage(q.Content),
+			Options:       json.RawMessage(q.Options),
+			CorrectAnswer: json.RawMessage(q.CorrectAnswer),
+			Points:        q.Points,
+			Weight:        q.Weight,
+			ManualGrading: q.ManualGrading,
+			Order:         q.Order,
+			ScoringRule:   q.ScoringRule,
+			Explanation:   q.Explanation,
+			Language:      q.Language,
+			CodeTemplate:  "", // 如果有的话需要从 Content 中解析
+		})
+	}
+
+	return &LevelFullResponse{
+		ID:                 level.ID,
+		CreatedAt:          level.CreatedAt,
+		UpdatedAt:          level.UpdatedAt,
+		CreatorID:          level.CreatorID,
+		Title:              level.Title,
+		Description:        level.Description,
+		CoverURL:           level.CoverURL,
+		Difficulty:         level.Difficulty,
+		EstimatedMinutes:   level.EstimatedMinutes,
+		AttemptLimit:       level.AttemptLimit,
+		PassingScore:       level.PassingScore,
+		PassingMode:        level.PassingMode,
+		BasePoints:         level.BasePoints,
+		AllowPause:         level.AllowPause,
+		LevelType:          level.LevelType,
+		IsPublished:        level.IsPublished,
+		PublishedAt:        level.PublishedAt,
+		ScheduledPublishAt: level.ScheduledPublishAt,
+		VisibleScope:       level.VisibleScope,
+		VisibleTo:          json.RawMessage(level.VisibleTo),
+		AvailableFrom:      level.AvailableFrom,
+		AvailableTo:        level.AvailableTo,
+		CurrentVersion:     level.CurrentVersion,
+		Abilities:          abilityIDs,
+		KnowledgeTags:      knowledgeTagIDs,
+		Questions:          questions,
+	}, errors.Join(errs...)
 }
 
-// DeleteLevel 删除关卡
-func (s *LevelService) DeleteLevel(deleterID, levelID uint) error {
+// LevelInUseError 关卡已有学生挑战记录，未指定 force 时拒绝删除
+type LevelInUseError struct {
+	Attempts   int64
+	InProgress int64
+}
+
+func (e *LevelInUseError) Error() string {
+	return fmt.Sprintf("该关卡已有 %d 条挑战记录（其中 %d 条进行中），删除将影响学生成绩，如需删除请使用 force 归档", e.Attempts, e.InProgress)
+}
+
+// DeleteLevel 删除关卡。
+// 没有挑战记录时删除关卡及其所有关联数据；已有挑战记录时返回 LevelInUseError，
+// force 为 true 时改为归档：结束进行中的挑战并仅删除关卡本身，保留学生作答数据
+func (s *LevelService) DeleteLevel(deleterID, levelID uint, force bool) error {
 	// 检查关卡是否存在以及权限
 	level, err := s.LevelRepo.FindByID(levelID)
 	if err != nil {
@@ -1212,6 +1943,17 @@ func (s *LevelService) DeleteLevel(deleterID, levelID uint) error {
 		return util.ErrPermissionDenied
 	}
 
+	attempts, inProgress, err := s.LevelRepo.CountAttemptsByLevel(levelID)
+	if err != nil {
+		return err
+	}
+	if attempts > 0 {
+		if !force {
+			return &LevelInUseError{Attempts: attempts, InProgress: inProgress}
+		}
+		return s.LevelRepo.ArchiveLevel(levelID)
+	}
+
 	// 删除关卡及其所有关联数据
 	return s.LevelRepo.DeleteLevel(levelID)
 }
@@ -1481,8 +2223,8 @@ func (s *LevelService) GetStudentLevelDetail(userID, levelID uint) (*StudentLeve
 	}
 
 	// 确定关卡状态和用户进度信息
-	var completedAt *time.Time
-	var lastAttemptAt *time.Time
+	var completedAt *model.JSONTime
+	var lastAttemptAt *model.JSONTime
 	var timeSpent int
 
 	if len(attempts) == 0 {
@@ -1500,7 +2242,7 @@ func (s *LevelService) GetStudentLevelDetail(userID, levelID uint) (*StudentLeve
 
 			// 计算该次尝试的用时（提交时间 - 开始时间）
 			if attempt.EndedAt != nil {
-				attemptTimeSpent := int(attempt.EndedAt.Sub(attempt.StartedAt).Seconds())
+				attemptTimeSpent := int(attempt.EndedAt.Sub(attempt.StartedAt.Time).Seconds())
 				timeSpent += attemptTimeSpent
 			}
 
@@ -1509,7 +2251,7 @@ func (s *LevelService) GetStudentLevelDetail(userID, levelID uint) (*StudentLeve
 				// 记录第一次成功的完成时间（提交时间）
 				if completedAt == nil {
 					completedAt = attempt.EndedAt
-				} else if attempt.EndedAt != nil && attempt.EndedAt.Before(*completedAt) {
+				} else if attempt.EndedAt != nil && attempt.EndedAt.Before(completedAt.Time) {
 					// 找到最早的成功完成时间
 					completedAt = attempt.EndedAt
 				}
@@ -1517,12 +2259,12 @@ func (s *LevelService) GetStudentLevelDetail(userID, levelID uint) (*StudentLeve
 
 			// 记录最后一次尝试的时间（优先使用提交时间）
 			if attempt.EndedAt != nil {
-				if lastAttemptAt == nil || attempt.EndedAt.After(*lastAttemptAt) {
+				if lastAttemptAt == nil || attempt.EndedAt.After(lastAttemptAt.Time) {
 					lastAttemptAt = attempt.EndedAt
 				}
 			} else {
 				// 如果没有提交时间，使用开始时间
-				if lastAttemptAt == nil || attempt.StartedAt.After(*lastAttemptAt) {
+				if lastAttemptAt == nil || attempt.StartedAt.After(lastAttemptAt.Time) {
 					lastAttemptAt = &attempt.StartedAt
 				}
 			}
@@ -1772,13 +2514,13 @@ func (s *LevelService) BatchSubmitAnswers(userID, levelID, attemptID uint, req i
 
 	// 更新尝试记录
 	now := time.Now()
-	attempt.EndedAt = &now
+	attempt.EndedAt = &model.JSONTime{Time: now}
 	attempt.Score = totalScore
-	attempt.Success = totalScore >= level.PassingScore
+	attempt.Success = determineSuccess(totalScore, maxScore, level)
 
 	// 计算总时间（从开始到现在的时长）
 	if attempt.StartedAt.Before(now) {
-		attempt.TotalTimeSeconds = int(now.Sub(attempt.StartedAt).Seconds())
+		attempt.TotalTimeSeconds = int(now.Sub(attempt.StartedAt.Time).Seconds())
 	}
 
 	if err := s.LevelRepo.UpdateAttempt(attempt); err != nil {
@@ -1909,10 +2651,14 @@ func (s *LevelService) checkAnswer(question model.LevelQuestion, userAnswer inte
 
 		// 调用运行代码服务
 		runReq := CodeExecutionRequest{
-			Code: userAnswerStr,
+			Code:     userAnswerStr,
+			Language: question.Language,
 		}
 		runResult, err := s.LearningService.RunCode(runReq)
 		if err != nil {
+			if errors.Is(err, util.ErrUnsupportedLanguage) {
+				return false, 0, "题目配置的编程语言暂不支持: " + question.Language
+			}
 			logger.Log.Error("编程题运行失败", zap.Error(err))
 			return false, 0, "代码执行环境异常，请稍后重试"
 		}
@@ -1922,8 +2668,11 @@ func (s *LevelService) checkAnswer(question model.LevelQuestion, userAnswer inte
 			return false, 0, "编译错误:\n" + runResult.Errors
 		}
 
-		// 检查运行状态（超时等）
+		// 检查运行状态（超时、输出超限等）
 		if runResult.Status != 0 {
+			if label := CodeErrorLabel(runResult.ErrorType); label != "" {
+				return false, 0, label + ": " + runResult.Errors
+			}
 			return false, 0, "运行异常: " + runResult.Errors
 		}
 
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/testutil"
+	"coder_edu_backend/internal/util"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFinishManualGradingPercentagePassing(t *testing.T) {
+	// 满分 = 10 + 10 + 30*2 = 80，60% 及格线为 48 分
+	questions := []model.LevelQuestion{
+		{Points: 10, Weight: 1},
+		{Points: 10},
+		{Points: 30, Weight: 2, ManualGrading: true},
+	}
+	percentage := &model.Level{PassingMode: model.PassingModePercentage, PassingScore: 60}
+	absolute := &model.Level{PassingMode: model.PassingModeAbsolute, PassingScore: 60}
+
+	tests := []struct {
+		name  string
+		level *model.Level
+		total int
+		want  bool
+	}{
+		{"percentage above threshold", percentage, 50, true},
+		{"percentage exactly at threshold", percentage, 48, true},
+		{"percentage below threshold", percentage, 47, false},
+		{"absolute mode uses raw score", absolute, 50, false},
+		{"absolute mode passes at passing score", absolute, 60, true},
+	}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempt := &model.LevelAttempt{NeedsManual: true}
+			finishManualGrading(attempt, questions, tt.level, tt.total, now)
+			if attempt.Success != tt.want {
+				t.Fatalf("Success = %v, want %v", attempt.Success, tt.want)
+			}
+			if attempt.Score != tt.total || attempt.NeedsManual {
+				t.Fatalf("Score = %d, NeedsManual = %v; want %d, false", attempt.Score, attempt.NeedsManual, tt.total)
+			}
+			if attempt.EndedAt == nil || !attempt.EndedAt.Time.Equal(now) {
+				t.Fatalf("EndedAt = %v, want %v", attempt.EndedAt, now)
+			}
+		})
+	}
+}
+
+func TestFinishManualGradingPercentageWithoutQuestions(t *testing.T) {
+	attempt := &model.LevelAttempt{}
+	finishManualGrading(attempt, nil, &model.Level{PassingMode: model.PassingModePercentage, PassingScore: 0}, 0, time.Now())
+	if attempt.Success {
+		t.Fatal("attempt with zero max score must not pass in percentage mode")
+	}
+}
+
+func TestLevelSnapshotCorrupted(t *testing.T) {
+	valid, err := json.Marshal(map[string]interface{}{
+		"level":     model.Level{Title: "数组"},
+		"questions": []model.LevelQuestion{{Points: 10}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"valid snapshot", string(valid), false},
+		{"truncated json", `{"level": {"title": "数组"`, true},
+		{"wrong shape", `{"level": "corrupted", "questions": 7}`, true},
+		{"empty", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := levelSnapshotCorrupted(tt.content); got != tt.want {
+				t.Fatalf("levelSnapshotCorrupted = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRegenerateCurrentVersionRecoversCorruptSnapshot 需要 testutil.MySQL 的测试库，未设置 TEST_MYSQL_DSN 时跳过
+func TestRegenerateCurrentVersionRecoversCorruptSnapshot(t *testing.T) {
+	db := testutil.MySQL(t)
+	s := &LevelService{LevelRepo: repository.NewLevelRepository(db), DB: db}
+
+	level := model.Level{Title: "regenerate-version-test"}
+	if err := db.Create(&level).Error; err != nil {
+		t.Fatalf("create level: %v", err)
+	}
+	// 题目的 order 与插入顺序相反，确认快照按 ID 而不是 order 排序
+	questions := []model.LevelQuestion{
+		{LevelID: level.ID, QuestionType: "fill_blank", Content: `{"stem":"1"}`, Options: "[]", CorrectAnswer: `"1"`, Points: 10, Order: 2},
+		{LevelID: level.ID, QuestionType: "fill_blank", Content: `{"stem":"2"}`, Options: "[]", CorrectAnswer: `"2"`, Points: 20, Order: 1},
+	}
+	if err := db.Create(&questions).Error; err != nil {
+		t.Fatalf("create questions: %v", err)
+	}
+	broken := model.LevelVersion{LevelID: level.ID, VersionNumber: 1, Content: `{"level": "corrupted", "questions": 7}`}
+	if err := db.Create(&broken).Error; err != nil {
+		t.Fatalf("create version: %v", err)
+	}
+	if err := db.Model(&level).Update("current_version", broken.ID).Error; err != nil {
+		t.Fatalf("set current version: %v", err)
+	}
+	attempt := model.LevelAttempt{LevelID: level.ID, UserID: 1, VersionID: broken.ID, StartedAt: model.NewJSONTime(time.Now()), PerQuestionTimes: "{}"}
+	if err := db.Create(&attempt).Error; err != nil {
+		t.Fatalf("create attempt: %v", err)
+	}
+	defer func() {
+		db.Unscoped().Where("level_id = ?", level.ID).Delete(&model.LevelAttempt{})
+		db.Unscoped().Where("level_id = ?", level.ID).Delete(&model.LevelVersion{})
+		db.Unscoped().Where("level_id = ?", level.ID).Delete(&model.LevelQuestion{})
+		db.Unscoped().Delete(&level)
+	}()
+
+	v, err := s.RegenerateCurrentVersion(1, level.ID)
+	if err != nil {
+		t.Fatalf("RegenerateCurrentVersion: %v", err)
+	}
+	if v.VersionNumber != 2 {
+		t.Fatalf("VersionNumber = %d, want 2", v.VersionNumber)
+	}
+	var snap struct {
+		Level     model.Level           `json:"level"`
+		Questions []model.LevelQuestion `json:"questions"`
+	}
+	if err := json.Unmarshal([]byte(v.Content), &snap); err != nil {
+		t.Fatalf("regenerated snapshot does not parse: %v", err)
+	}
+	if len(snap.Questions) != 2 || snap.Questions[0].ID != questions[0].ID || snap.Questions[1].ID != questions[1].ID {
+		t.Fatalf("snapshot questions not ordered by id: %+v", snap.Questions)
+	}
+
+	var reloaded model.Level
+	if err := db.First(&reloaded, level.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.CurrentVersion != v.ID {
+		t.Fatalf("CurrentVersion = %d, want %d", reloaded.CurrentVersion, v.ID)
+	}
+	var movedAttempt model.LevelAttempt
+	if err := db.First(&movedAttempt, attempt.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if movedAttempt.VersionID != v.ID {
+		t.Fatalf("in-progress attempt VersionID = %d, want %d", movedAttempt.VersionID, v.ID)
+	}
+}
+
+// TestLevelEditsRejectOtherTeacher 需要 testutil.MySQL 的测试库，未设置 TEST_MYSQL_DSN 时跳过
+func TestLevelEditsRejectOtherTeacher(t *testing.T) {
+	db := testutil.MySQL(t)
+	s := &LevelService{LevelRepo: repository.NewLevelRepository(db), DB: db}
+
+	const ownerID, otherID = 987654321, 987654322
+	level := model.Level{Title: "cross-teacher-test", CreatorID: ownerID}
+	if err := db.Create(&level).Error; err != nil {
+		t.Fatalf("create level: %v", err)
+	}
+	question := model.LevelQuestion{LevelID: level.ID, QuestionType: "fill_blank", Content: `{"stem":"1"}`, Options: "[]", CorrectAnswer: `"1"`, Points: 10}
+	if err := db.Create(&question).Error; err != nil {
+		t.Fatalf("create question: %v", err)
+	}
+	own := model.Level{Title: "cross-teacher-own", CreatorID: otherID}
+	if err := db.Create(&own).Error; err != nil {
+		t.Fatalf("create own level: %v", err)
+	}
+	defer func() {
+		db.Unscoped().Where("level_id IN ?", []uint{level.ID, own.ID}).Delete(&model.LevelQuestion{})
+		db.Unscoped().Delete(&level)
+		db.Unscoped().Delete(&own)
+	}()
+
+	questionReq := LevelQuestionRequest{QuestionType: "fill_blank", Content: map[string]interface{}{"stem": "changed"}}
+	cases := []struct {
+		name string
+		call func() error
+	}{
+		{"UpdateLevel", func() error {
+			_, err := s.UpdateLevel(context.Background(), otherID, model.Teacher, level.ID, LevelCreateRequest{Title: "hijacked"})
+			return err
+		}},
+		{"PublishLevel", func() error {
+			_, err := s.PublishLevel(otherID, model.Teacher, level.ID, true, true)
+			return err
+		}},
+		{"AddQuestion", func() error {
+			_, err := s.AddQuestion(otherID, model.Teacher, level.ID, questionReq)
+			return err
+		}},
+		{"UpdateQuestion", func() error {
+			_, err := s.UpdateQuestion(otherID, model.Teacher, level.ID, question.ID, questionReq)
+			return err
+		}},
+		{"DeleteQuestion", func() error {
+			return s.DeleteQuestion(otherID, model.Teacher, level.ID, question.ID)
+		}},
+		{"BulkPublish", func() error {
+			// 混入自己的关卡也不能越权发布他人的关卡
+			_, err := s.BulkPublish(otherID, model.Teacher, []uint{own.ID, level.ID}, true, true)
+			return err
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.call(); !errors.Is(err, util.ErrPermissionDenied) {
+				t.Fatalf("%s by other teacher = %v, want ErrPermissionDenied", tc.name, err)
+			}
+		})
+	}
+
+	// 关卡、题目均未被修改，BulkPublish 也未发布调用者自己的关卡
+	var reloaded model.Level
+	db.First(&reloaded, level.ID)
+	if reloaded.Title != level.Title || reloaded.IsPublished {
+		t.Fatalf("level changed by other teacher: title=%q published=%v", reloaded.Title, reloaded.IsPublished)
+	}
+	var questions []model.LevelQuestion
+	db.Where("level_id = ?", level.ID).Find(&questions)
+	if len(questions) != 1 || questions[0].Content != question.Content {
+		t.Fatalf("questions changed by other teacher: %+v", questions)
+	}
+	db.First(&own, own.ID)
+	if own.IsPublished {
+		t.Fatal("BulkPublish published the caller's own level despite the permission error")
+	}
+}
+
+// TestUpdateVisibilityValidatesListOnlyForSpecificScope 需要 testutil.MySQL 的测试库，未设置 TEST_MYSQL_DSN 时跳过
+func TestUpdateVisibilityValidatesListOnlyForSpecificScope(t *testing.T) {
+	db := testutil.MySQL(t)
+	s := &LevelService{LevelRepo: repository.NewLevelRepository(db), DB: db}
+
+	const creatorID = 987654321
+	level := model.Level{Title: "visible-to-test", CreatorID: creatorID}
+	if err := db.Create(&level).Error; err != nil {
+		t.Fatalf("create level: %v", err)
+	}
+	defer db.Unscoped().Delete(&level)
+
+	// 不存在的学生ID，作为遗留在请求中的失效列表
+	stale := []uint{4294967000}
+
+	if err := s.UpdateVisibility(creatorID, level.ID, "all", stale); err != nil {
+		t.Fatalf("UpdateVisibility(all) with stale list = %v, want nil", err)
+	}
+	var reloaded model.Level
+	db.First(&reloaded, level.ID)
+	if reloaded.VisibleScope != "all" || string(reloaded.VisibleTo) != "[]" {
+		t.Fatalf("after scope all: scope=%q visibleTo=%s, want all and []", reloaded.VisibleScope, reloaded.VisibleTo)
+	}
+
+	if err := s.UpdateVisibility(creatorID, level.ID, "specific", stale); !errors.Is(err, util.ErrInvalidVisibleTo) {
+		t.Fatalf("UpdateVisibility(specific) with stale list = %v, want ErrInvalidVisibleTo", err)
+	}
+}
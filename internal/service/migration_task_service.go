@@ -61,7 +61,7 @@ func (s *MigrationTaskService) CreateTask(creatorID uint, req MigrationTaskReq)
 		task.IsPublished = *req.IsPublished
 		if task.IsPublished {
 			now := time.Now()
-			task.PublishedAt = &now
+			task.PublishedAt = &model.JSONTime{Time: now}
 		}
 	}
 
@@ -110,7 +110,7 @@ func (s *MigrationTaskService) UpdateTask(taskID string, req MigrationTaskReq) (
 	if req.IsPublished != nil {
 		if *req.IsPublished && !task.IsPublished {
 			now := time.Now()
-			task.PublishedAt = &now
+			task.PublishedAt = &model.JSONTime{Time: now}
 		} else if !*req.IsPublished {
 			task.PublishedAt = nil
 		}
@@ -218,7 +218,7 @@ func (s *MigrationTaskService) StartTask(userID uint, taskID string) (*model.Mig
 		TaskID:    taskID,
 		UserID:    userID,
 		Status:    "in_progress",
-		StartedAt: time.Now(),
+		StartedAt: model.NewJSONTime(time.Now()),
 	}
 
 	if err := s.Repo.DB.Create(submission).Error; err != nil {
@@ -245,7 +245,7 @@ func (s *MigrationTaskService) SubmitTask(userID uint, taskID string, req Migrat
 
 	// 2. 时间校验 (防止绕过前端限时)
 	if task.TimeLimit > 0 {
-		elapsedMinutes := int(time.Since(submission.StartedAt).Minutes())
+		elapsedMinutes := int(time.Since(submission.StartedAt.Time).Minutes())
 		// 允许 1 分钟的网络延迟容差
 		if elapsedMinutes > task.TimeLimit+1 {
 			// 如果严重超时，强制判 0 分，但允许提交以关闭任务
@@ -291,7 +291,7 @@ func (s *MigrationTaskService) SubmitTask(userID uint, taskID string, req Migrat
 	now := time.Now()
 	submission.Score = totalScore
 	submission.Status = "completed"
-	submission.CompletedAt = &now
+	submission.CompletedAt = &model.JSONTime{Time: now}
 
 	// 使用事务包裹：保存结果 + 更新积分 + 记录日志
 	err = s.Repo.DB.Transaction(func(tx *gorm.DB) error {
@@ -320,7 +320,7 @@ func (s *MigrationTaskService) SubmitTask(userID uint, taskID string, req Migrat
 				UserID:   userID,
 				Activity: "migration_task_score",
 				Content:  "迁移任务得分: " + task.Title,
-				Duration: int(now.Sub(submission.StartedAt).Minutes()),
+				Duration: int(now.Sub(submission.StartedAt.Time).Minutes()),
 				Score:    totalScore,
 			}
 			if err := tx.Create(log).Error; err != nil {
@@ -373,14 +373,14 @@ func (s *MigrationTaskService) GetStudentTaskDetail(userID uint, taskID string)
 	submission, _ := s.Repo.FindSubmissionByUserAndTask(userID, taskID)
 
 	status := "pending"
-	var startedAt *time.Time
+	var startedAt *model.JSONTime
 	remainingTime := task.TimeLimit * 60
 
 	if submission != nil {
 		status = submission.Status
 		startedAt = &submission.StartedAt
 		if status == "in_progress" {
-			elapsed := int(time.Since(submission.StartedAt).Seconds())
+			elapsed := int(time.Since(submission.StartedAt.Time).Seconds())
 			remainingTime = (task.TimeLimit * 60) - elapsed
 			if remainingTime < 0 {
 				remainingTime = 0
@@ -441,12 +441,5 @@ func (s *MigrationTaskService) RecordLearningTime(userID uint, taskID string, du
 		return err
 	}
 
-	log := &model.LearningLog{
-		UserID:   userID,
-		Activity: "migration_task",
-		Content:  "迁移任务学习: " + task.Title,
-		Duration: duration,
-	}
-
-	return s.Repo.DB.Create(log).Error
+	return recordLearningTime(repository.NewLearningLogRepository(s.Repo.DB), userID, "migration_task", taskID, "迁移任务学习: "+task.Title, duration)
 }
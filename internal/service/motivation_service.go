@@ -4,16 +4,25 @@ import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
 	"errors"
-	"math/rand"
 	"time"
 )
 
 type MotivationService struct {
 	MotivationRepo *repository.MotivationRepository
+	RotateHour     int // 每天自动轮换的时刻（本地时间）
 }
 
-func NewMotivationService(motivationRepo *repository.MotivationRepository) *MotivationService {
-	return &MotivationService{MotivationRepo: motivationRepo}
+func NewMotivationService(motivationRepo *repository.MotivationRepository, rotateHour int) *MotivationService {
+	if rotateHour < 0 || rotateHour > 23 {
+		rotateHour = 0
+	}
+	return &MotivationService{MotivationRepo: motivationRepo, RotateHour: rotateHour}
+}
+
+// MotivationScheduleResponse 自动轮换设置及配置的轮换时刻
+type MotivationScheduleResponse struct {
+	model.MotivationSchedule
+	RotateHour int `json:"rotateHour"`
 }
 
 // 获取所有激励短句
@@ -35,29 +44,106 @@ func (s *MotivationService) GetCurrentMotivation() (string, error) {
 		return enabledMotivations[0].Content, nil
 	}
 
-	// 检查是否需要切换（每12小时切换一次）
-	now := time.Now()
-	elapsed := now.Sub(current.LastUsedAt)
-	enabledMotivations, err := s.MotivationRepo.GetEnabled()
+	// 每日切换由后台任务 RotateIfDue 完成
+	return current.Content, nil
+}
 
-	// 如果只有一条启用的短句，则不切换
-	if err == nil && len(enabledMotivations) > 1 && elapsed.Hours() >= 12 {
-		// 从启用的列表中随机选择一个，排除当前使用的
-		var candidates []*model.Motivation
-		for _, m := range enabledMotivations {
-			if m.ID != current.ID {
-				candidates = append(candidates, m)
+// RotateIfDue 每天 RotateHour 点之后按 ID 顺序切换到下一条启用的短句，每天只切换一次。
+// 关闭自动轮换或置顶了短句时不切换；首次运行只记录时间。返回是否发生了切换
+func (s *MotivationService) RotateIfDue(now time.Time) (bool, error) {
+	schedule, err := s.MotivationRepo.GetSchedule()
+	if err != nil {
+		return false, err
+	}
+	if !schedule.AutoRotate || schedule.PinnedID != nil {
+		return false, nil
+	}
+
+	due := time.Date(now.Year(), now.Month(), now.Day(), s.RotateHour, 0, 0, 0, now.Location())
+	if now.Before(due) {
+		due = due.AddDate(0, 0, -1)
+	}
+	if schedule.LastRotatedAt != nil && !schedule.LastRotatedAt.Time.Before(due) {
+		return false, nil
+	}
+
+	claimed, err := s.MotivationRepo.ClaimRotation(schedule.LastRotatedAt, now)
+	if err != nil || !claimed || schedule.LastRotatedAt == nil {
+		return false, err
+	}
+	return s.rotateNext()
+}
+
+// rotateNext 切换到当前短句之后的下一条启用短句，到末尾后回到第一条
+func (s *MotivationService) rotateNext() (bool, error) {
+	enabled, err := s.MotivationRepo.GetEnabled()
+	if err != nil || len(enabled) == 0 {
+		return false, err
+	}
+
+	next := enabled[0]
+	if current, err := s.MotivationRepo.GetCurrent(); err == nil {
+		for i, m := range enabled {
+			if m.ID == current.ID {
+				next = enabled[(i+1)%len(enabled)]
+				break
 			}
 		}
-		if len(candidates) > 0 {
-			// 随机选择一个
-			newCurrent := candidates[rand.Intn(len(candidates))]
-			s.MotivationRepo.SetCurrent(newCurrent.ID)
-			return newCurrent.Content, nil
+		if next.ID == current.ID {
+			return false, nil
 		}
 	}
+	return true, s.MotivationRepo.SetCurrent(next.ID)
+}
 
-	return current.Content, nil
+// GetSchedule 获取自动轮换设置
+func (s *MotivationService) GetSchedule() (*MotivationScheduleResponse, error) {
+	schedule, err := s.MotivationRepo.GetSchedule()
+	if err != nil {
+		return nil, err
+	}
+	return &MotivationScheduleResponse{MotivationSchedule: *schedule, RotateHour: s.RotateHour}, nil
+}
+
+// UpdateSchedule 开关自动轮换或置顶短句，参数为 nil 时保持不变，pinnedID 为 0 表示取消置顶。
+// 置顶时立即切换到该短句
+func (s *MotivationService) UpdateSchedule(autoRotate *bool, pinnedID *uint) (*MotivationScheduleResponse, error) {
+	schedule, err := s.MotivationRepo.GetSchedule()
+	if err != nil {
+		return nil, err
+	}
+
+	auto, pinned := schedule.AutoRotate, schedule.PinnedID
+	if autoRotate != nil {
+		auto = *autoRotate
+	}
+	if pinnedID != nil {
+		if *pinnedID == 0 {
+			pinned = nil
+		} else {
+			if err := s.SwitchToMotivation(*pinnedID); err != nil {
+				return nil, err
+			}
+			pinned = pinnedID
+		}
+	}
+
+	if err := s.MotivationRepo.UpdateSchedule(auto, pinned); err != nil {
+		return nil, err
+	}
+	return s.GetSchedule()
+}
+
+// unpinIfPinned 置顶的短句被停用或删除时取消置顶，恢复自动轮换
+func (s *MotivationService) unpinIfPinned(id uint) error {
+	schedule, err := s.MotivationRepo.GetSchedule()
+	if err != nil {
+		return err
+	}
+	if schedule.PinnedID == nil || *schedule.PinnedID != id {
+		return nil
+	}
+	return s.MotivationRepo.UpdateSchedule(schedule.AutoRotate, nil)
 }
 
 // 创建新的激励短句
@@ -89,6 +175,12 @@ func (s *MotivationService) UpdateMotivation(id uint, content string, isEnabled
 		}
 	}
 
+	if !isEnabled {
+		if err := s.unpinIfPinned(id); err != nil {
+			return err
+		}
+	}
+
 	motivation.Content = content
 	motivation.IsEnabled = isEnabled
 	return s.MotivationRepo.Update(&motivation)
@@ -110,10 +202,13 @@ func (s *MotivationService) DeleteMotivation(id uint) error {
 		}
 	}
 
+	if err := s.unpinIfPinned(id); err != nil {
+		return err
+	}
 	return s.MotivationRepo.Delete(id)
 }
 
-// 立即切换到指定的激励短句
+// 立即切换到指定的激励短句。已置顶短句时置顶随之改为该短句
 func (s *MotivationService) SwitchToMotivation(id uint) error {
 	// 检查是否启用
 	motivations, err := s.MotivationRepo.GetAll()
@@ -136,5 +231,15 @@ func (s *MotivationService) SwitchToMotivation(id uint) error {
 		return errors.New("未找到指定的激励短句")
 	}
 
-	return s.MotivationRepo.SetCurrent(id)
+	if err := s.MotivationRepo.SetCurrent(id); err != nil {
+		return err
+	}
+	schedule, err := s.MotivationRepo.GetSchedule()
+	if err != nil {
+		return err
+	}
+	if schedule.PinnedID != nil && *schedule.PinnedID != id {
+		return s.MotivationRepo.UpdateSchedule(schedule.AutoRotate, &id)
+	}
+	return nil
 }
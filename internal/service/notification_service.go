@@ -0,0 +1,266 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// NotificationService 将好友申请、@ 提及、评分、公告等事件持久化为站内通知，
+// 离线用户上线后可在通知中心查看；在线用户同时收到 NOTIFICATION 推送
+type NotificationService struct {
+	Repo   *repository.NotificationRepository
+	Hub    *ChatHub
+	Unread *UnreadService
+}
+
+func NewNotificationService(repo *repository.NotificationRepository, hub *ChatHub, unread *UnreadService) *NotificationService {
+	return &NotificationService{Repo: repo, Hub: hub, Unread: unread}
+}
+
+// NotificationList 通知中心列表
+type NotificationList struct {
+	Items       []model.Notification `json:"items"`
+	Total       int64                `json:"total"`
+	UnreadCount int64                `json:"unreadCount"`
+}
+
+// Notify 为用户写入一条通知并推送给在线用户，关闭了该类通知的用户会被跳过
+func (s *NotificationService) Notify(userIDs []uint, notificationType, title, content string, data map[string]interface{}) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	disabled, err := s.Repo.DisabledUserIDs(userIDs, notificationType)
+	if err != nil {
+		return err
+	}
+	skip := make(map[uint]bool, len(disabled))
+	for _, id := range disabled {
+		skip[id] = true
+	}
+
+	var raw json.RawMessage
+	if len(data) > 0 {
+		raw, _ = json.Marshal(data)
+	}
+	now := model.NewJSONTime(time.Now())
+	recipients := make([]uint, 0, len(userIDs))
+	notifications := make([]model.Notification, 0, len(userIDs))
+	for _, uid := range userIDs {
+		if skip[uid] {
+			continue
+		}
+		skip[uid] = true // 去重
+		recipients = append(recipients, uid)
+		notifications = append(notifications, model.Notification{
+			UserID:    uid,
+			Type:      notificationType,
+			Title:     title,
+			Content:   content,
+			Data:      raw,
+			CreatedAt: now,
+		})
+	}
+	if err := s.Repo.CreateBatch(notifications); err != nil {
+		return err
+	}
+	s.Unread.MarkChanged(recipients...)
+
+	// 没有接收人时不推送，PushToUsers 传空列表会变成全服广播
+	if s.Hub != nil && len(recipients) > 0 {
+		s.Hub.PushToUsers(recipients, WSMessage{
+			Type: "NOTIFICATION",
+			Data: map[string]interface{}{
+				"type":      notificationType,
+				"title":     title,
+				"content":   content,
+				"data":      data,
+				"createdAt": now,
+			},
+		})
+	}
+	return nil
+}
+
+// List 获取用户的通知及未读数
+func (s *NotificationService) List(userID uint, unreadOnly bool, limit, offset int) (*NotificationList, error) {
+	items, total, err := s.Repo.ListForUser(userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.Repo.CountUnread(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &NotificationList{Items: items, Total: total, UnreadCount: unread}, nil
+}
+
+// MarkRead 标记单条通知已读，通知不存在或不属于该用户时返回 ErrNotificationNotFound
+func (s *NotificationService) MarkRead(userID, id uint) error {
+	exists, err := s.Repo.Exists(userID, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return util.ErrNotificationNotFound
+	}
+	if _, err = s.Repo.MarkRead(userID, id); err != nil {
+		return err
+	}
+	s.Unread.MarkChanged(userID)
+	return nil
+}
+
+// MarkAllRead 标记用户全部通知已读，返回更新条数
+func (s *NotificationService) MarkAllRead(userID uint) (int64, error) {
+	updated, err := s.Repo.MarkRead(userID, 0)
+	if err == nil && updated > 0 {
+		s.Unread.MarkChanged(userID)
+	}
+	return updated, err
+}
+
+// GetPreferences 返回每种通知类型的开关，未设置的类型默认开启
+func (s *NotificationService) GetPreferences(userID uint) ([]model.NotificationPreference, error) {
+	saved, err := s.Repo.ListPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	enabled := make(map[string]bool, len(saved))
+	for _, p := range saved {
+		enabled[p.Type] = p.Enabled
+	}
+	prefs := make([]model.NotificationPreference, 0, len(model.NotificationTypes))
+	for _, t := range model.NotificationTypes {
+		on, ok := enabled[t]
+		prefs = append(prefs, model.NotificationPreference{Type: t, Enabled: !ok || on})
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences 按类型更新通知开关，未出现的类型保持不变
+func (s *NotificationService) UpdatePreferences(userID uint, updates map[string]bool) ([]model.NotificationPreference, error) {
+	prefs := make([]model.NotificationPreference, 0, len(updates))
+	for t, on := range updates {
+		if !model.IsValidNotificationType(t) {
+			return nil, fmt.Errorf("%w: unknown notification type %q", util.ErrInvalidRequest, t)
+		}
+		prefs = append(prefs, model.NotificationPreference{UserID: userID, Type: t, Enabled: on})
+	}
+	if err := s.Repo.UpsertPreferences(prefs); err != nil {
+		return nil, err
+	}
+	return s.GetPreferences(userID)
+}
+
+// FriendRequestHandler 好友申请事件：通知接收人
+func (s *NotificationService) FriendRequestHandler() EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(FriendRequestSentEvent)
+		if !ok {
+			return nil
+		}
+		return s.Notify([]uint{evt.ReceiverID}, model.NotificationFriendRequest,
+			"新的好友申请",
+			fmt.Sprintf("%s 请求添加你为好友", evt.SenderName),
+			map[string]interface{}{"requestId": evt.RequestID, "senderId": evt.SenderID, "message": evt.Message})
+	}
+}
+
+// MentionHandler @ 提及事件：通知被提及的成员
+func (s *NotificationService) MentionHandler() EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(UserMentionedEvent)
+		if !ok {
+			return nil
+		}
+		return s.Notify(evt.UserIDs, model.NotificationMention,
+			fmt.Sprintf("%s 在「%s」中提到了你", evt.SenderName, evt.ConversationName),
+			evt.Preview,
+			map[string]interface{}{"conversationId": evt.ConversationID, "messageId": evt.MessageID, "senderId": evt.SenderID})
+	}
+}
+
+// SubmissionAuditedHandler 知识点提交审核事件：通知学生审核结果
+func (s *NotificationService) SubmissionAuditedHandler() EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(SubmissionAuditedEvent)
+		if !ok {
+			return nil
+		}
+		content := fmt.Sprintf("你的知识点测试已审核通过，得分 %d", evt.Score)
+		if evt.Status != "approved" {
+			content = "你的知识点测试未通过审核，可以重新提交"
+		}
+		return s.Notify([]uint{evt.UserID}, model.NotificationSubmissionGraded, "知识点测试审核结果", content,
+			map[string]interface{}{"submissionId": evt.SubmissionID, "knowledgePointId": evt.KnowledgePointID, "status": evt.Status, "score": evt.Score})
+	}
+}
+
+// AttemptGradedHandler 关卡人工评分完成事件：通知学生查看成绩
+func (s *NotificationService) AttemptGradedHandler() EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(AttemptGradedEvent)
+		if !ok {
+			return nil
+		}
+		return s.Notify([]uint{evt.UserID}, model.NotificationSubmissionGraded, "关卡挑战评分完成",
+			fmt.Sprintf("你的关卡挑战已完成评分，得分 %d", evt.Score),
+			map[string]interface{}{"levelId": evt.LevelID, "attemptId": evt.AttemptID, "score": evt.Score, "success": evt.Success})
+	}
+}
+
+// AnnouncementHandler 公告发布事件：通知接收的学生
+func (s *NotificationService) AnnouncementHandler() EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(AnnouncementPublishedEvent)
+		if !ok {
+			return nil
+		}
+		return s.Notify(evt.UserIDs, model.NotificationAnnouncement,
+			fmt.Sprintf("%s 发布了公告", evt.TeacherName), evt.Title,
+			map[string]interface{}{"announcementId": evt.AnnouncementID})
+	}
+}
+
+// GoalCompletedHandler 目标达成事件：记录通知
+func (s *NotificationService) GoalCompletedHandler() EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(GoalCompletedEvent)
+		if !ok {
+			return nil
+		}
+		return s.Notify([]uint{evt.UserID}, model.NotificationGoal, "学习目标已完成",
+			fmt.Sprintf("恭喜你完成目标「%s」！", evt.Title),
+			map[string]interface{}{"goalId": evt.GoalID})
+	}
+}
+
+// GoalDeadlineHandler 目标临近截止事件：提醒学生
+func (s *NotificationService) GoalDeadlineHandler() EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(GoalDeadlineEvent)
+		if !ok {
+			return nil
+		}
+		return s.Notify([]uint{evt.UserID}, model.NotificationGoal, "学习目标即将截止",
+			fmt.Sprintf("目标「%s」还有 %d 天截止，记得按时完成", evt.Title, evt.DaysLeft),
+			map[string]interface{}{"goalId": evt.GoalID, "targetDate": model.NewJSONTime(evt.TargetDate), "daysLeft": evt.DaysLeft})
+	}
+}
+
+// ReflectionCommentedHandler 反思评论事件：通知对方查看
+func (s *NotificationService) ReflectionCommentedHandler() EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(ReflectionCommentedEvent)
+		if !ok {
+			return nil
+		}
+		return s.Notify(evt.RecipientIDs, model.NotificationReflection,
+			fmt.Sprintf("%s 评论了反思", evt.AuthorName), evt.Preview,
+			map[string]interface{}{"reflectionId": evt.ReflectionID, "commentId": evt.CommentID, "authorId": evt.AuthorID})
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AuditActionTransferOwnership = "transfer_ownership"
+	AuditActionChangeRole        = "change_role"
+
+	AuditTargetUser = "user"
+
+	OwnershipTargetResource          = "resource"
+	OwnershipTargetCommunityResource = "community_resource"
+	OwnershipTargetLevel             = "level"
+)
+
+// OwnershipService 处理教师离职等场景下的内容负责人转移，所有转移都会写入审计记录
+type OwnershipService struct {
+	DB *gorm.DB
+}
+
+func NewOwnershipService(db *gorm.DB) *OwnershipService {
+	return &OwnershipService{DB: db}
+}
+
+// OwnershipTransfer 负责人转移结果
+type OwnershipTransfer struct {
+	TargetType    string `json:"targetType"`
+	TargetID      string `json:"targetId"`
+	PreviousOwner uint   `json:"previousOwnerId"`
+	NewOwner      uint   `json:"newOwnerId"`
+}
+
+// TransferResourceOwnership 转移学习资源（视频/文章等）的上传者
+func (s *OwnershipService) TransferResourceOwnership(actorID, resourceID, newOwnerID uint) (*OwnershipTransfer, error) {
+	return s.transfer(actorID, newOwnerID, OwnershipTargetResource, fmt.Sprint(resourceID), func(tx *gorm.DB) (uint, error) {
+		var resource model.Resource
+		if err := tx.Select("id", "uploader_id").First(&resource, resourceID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, util.ErrResourceNotFound
+			}
+			return 0, err
+		}
+		return resource.UploaderID, tx.Model(&resource).Update("uploader_id", newOwnerID).Error
+	})
+}
+
+// TransferCommunityResourceOwnership 转移社区资源的作者
+func (s *OwnershipService) TransferCommunityResourceOwnership(actorID uint, resourceID string, newOwnerID uint) (*OwnershipTransfer, error) {
+	return s.transfer(actorID, newOwnerID, OwnershipTargetCommunityResource, resourceID, func(tx *gorm.DB) (uint, error) {
+		var resource model.CommunityResource
+		if err := tx.Select("id", "author_id").First(&resource, "id = ?", resourceID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, util.ErrResourceNotFound
+			}
+			return 0, err
+		}
+		return resource.AuthorID, tx.Model(&resource).Update("author_id", newOwnerID).Error
+	})
+}
+
+// TransferLevelOwnership 转移关卡的创建者
+func (s *OwnershipService) TransferLevelOwnership(actorID, levelID, newOwnerID uint) (*OwnershipTransfer, error) {
+	return s.transfer(actorID, newOwnerID, OwnershipTargetLevel, fmt.Sprint(levelID), func(tx *gorm.DB) (uint, error) {
+		var level model.Level
+		if err := tx.Select("id", "creator_id").First(&level, levelID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, util.ErrLevelNotFound
+			}
+			return 0, err
+		}
+		return level.CreatorID, tx.Model(&level).Update("creator_id", newOwnerID).Error
+	})
+}
+
+// transfer 校验新负责人后在事务中执行 update 并写入审计记录，update 返回原负责人ID
+func (s *OwnershipService) transfer(actorID, newOwnerID uint, targetType, targetID string, update func(tx *gorm.DB) (uint, error)) (*OwnershipTransfer, error) {
+	var owner model.User
+	if err := s.DB.Select("id", "role", "disabled").First(&owner, newOwnerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, util.ErrUserNotFound
+		}
+		return nil, err
+	}
+	if owner.Role != model.Teacher && owner.Role != model.Admin {
+		return nil, fmt.Errorf("%w: 新负责人必须是教师或管理员", util.ErrInvalidRequest)
+	}
+	if owner.Disabled {
+		return nil, fmt.Errorf("%w: 新负责人账号已被禁用", util.ErrInvalidRequest)
+	}
+
+	result := &OwnershipTransfer{TargetType: targetType, TargetID: targetID, NewOwner: newOwnerID}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		previous, err := update(tx)
+		if err != nil {
+			return err
+		}
+		result.PreviousOwner = previous
+
+		detail, _ := json.Marshal(map[string]uint{"previousOwnerId": previous, "newOwnerId": newOwnerID})
+		return repository.NewAuditLogRepository(tx).Create(&model.AuditLog{
+			ActorID:    actorID,
+			Action:     AuditActionTransferOwnership,
+			TargetType: targetType,
+			TargetID:   targetID,
+			Detail:     string(detail),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
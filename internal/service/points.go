@@ -0,0 +1,42 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// awardPoints 在事务内为业务事件发放积分。
+// 同一 (source, sourceID) 只会生效一次，重复调用返回 false
+func awardPoints(tx *gorm.DB, userID uint, amount int, source, sourceID, reason string) (bool, error) {
+	entry := model.PointsLedger{
+		UserID:     userID,
+		Delta:      amount,
+		Reason:     reason,
+		SourceType: source,
+		SourceID:   &sourceID,
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, applyPoints(tx, userID, amount)
+}
+
+// recordPoints 在事务内追加一条积分流水并同步累加用户积分
+func recordPoints(tx *gorm.DB, entry *model.PointsLedger) error {
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+	return applyPoints(tx, entry.UserID, entry.Delta)
+}
+
+func applyPoints(tx *gorm.DB, userID uint, delta int) error {
+	return tx.Model(&model.User{}).
+		Where("id = ?", userID).
+		UpdateColumn("points", gorm.Expr("points + ?", delta)).Error
+}
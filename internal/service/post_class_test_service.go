@@ -3,8 +3,11 @@ package service
 import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 	"strings"
 	"time"
 )
@@ -185,8 +188,23 @@ func (s *PostClassTestService) GetPublishedTestForStudent(userID uint) (*reposit
 }
 
 type PostClassTestAnswerReq struct {
-	Result string `json:"result"` // 用于比对得分的结果
-	Code   string `json:"code"`   // 如果是代码题，提交的源代码
+	Result      string `json:"result"`      // 用于比对得分的结果
+	Code        string `json:"code"`        // 如果是代码题，提交的源代码
+	TimeSeconds int    `json:"timeSeconds"` // 该题作答耗时（秒），可选
+}
+
+// maxQuestionTimeSeconds 单题耗时上限，超出视为客户端计时异常
+const maxQuestionTimeSeconds = 24 * 60 * 60
+
+// clampQuestionTime 将客户端上报的单题耗时限制在合理范围内
+func clampQuestionTime(seconds int) int {
+	if seconds < 0 {
+		return 0
+	}
+	if seconds > maxQuestionTimeSeconds {
+		return maxQuestionTimeSeconds
+	}
+	return seconds
 }
 
 type PostClassTestSubmissionReq struct {
@@ -217,21 +235,18 @@ func (s *PostClassTestService) SubmitTest(userID uint, testID string, req PostCl
 	// 3. 评分和计算积分
 	totalScore := 0
 	totalXP := 0
+	submissionNeedsManual := false
 	answers := make([]model.PostClassTestAnswer, 0, len(qs))
 
 	for _, q := range qs {
 		ansReq := req.Answers[q.ID]
 		userAns := ansReq.Result
-		isCorrect := false
 		score := 0
 
-		// 评分逻辑：比对结果
-		switch q.QuestionType {
-		case "single_choice", "multiple_choice", "true_false", "fill_blank", "code", "programming", "essay":
-			// 比对去除首尾空格后的结果（忽略大小写）
-			if strings.TrimSpace(strings.ToLower(userAns)) == strings.TrimSpace(strings.ToLower(q.Answer)) {
-				isCorrect = true
-			}
+		// 评分逻辑：客观题自动判分，主观题留给老师人工批改
+		isCorrect, needsManual := gradePostClassAnswer(q, userAns)
+		if needsManual {
+			submissionNeedsManual = true
 		}
 
 		if isCorrect {
@@ -258,16 +273,20 @@ func (s *PostClassTestService) SubmitTest(userID uint, testID string, req PostCl
 			UserAnswer:      storageAnswer,
 			IsCorrect:       isCorrect,
 			Score:           score,
+			NeedsManual:     needsManual,
+			TimeSeconds:     clampQuestionTime(ansReq.TimeSeconds),
 		})
 	}
 
 	// 4. 更新记录
 	now := time.Now()
 	submission.Score = totalScore
+	submission.AutoScore = totalScore
+	submission.NeedsManual = submissionNeedsManual
 	submission.RewardXP = totalXP
 	submission.Status = "completed"
 	submission.IsTimeout = req.IsTimeout
-	submission.CompletedAt = &now
+	submission.CompletedAt = &model.JSONTime{Time: now}
 
 	if err := s.Repo.UpdateSubmissionWithAnswers(submission, answers); err != nil {
 		return nil, err
@@ -297,6 +316,129 @@ func (s *PostClassTestService) SubmitTest(userID uint, testID string, req PostCl
 	return submission, nil
 }
 
+// gradePostClassAnswer 对单题自动判分：选择题和判断题比对选项，填空题和编程题比对结果，
+// 简答等主观题不判分并返回 needsManual
+func gradePostClassAnswer(q model.PostClassTestQuestion, userAns string) (isCorrect bool, needsManual bool) {
+	if isManualPostClassQuestion(q.QuestionType) {
+		return false, true
+	}
+	if q.QuestionType == "multiple_choice" {
+		return sameChoiceSet(userAns, q.Answer), false
+	}
+	// 比对去除首尾空格后的结果（忽略大小写）
+	return strings.TrimSpace(strings.ToLower(userAns)) == strings.TrimSpace(strings.ToLower(q.Answer)), false
+}
+
+// isManualPostClassQuestion 简答题及未知题型无法自动判分，由老师通过 GradeSubmission 人工批改
+func isManualPostClassQuestion(questionType string) bool {
+	switch questionType {
+	case "single_choice", "multiple_choice", "true_false", "fill_blank", "code", "programming":
+		return false
+	default:
+		return true
+	}
+}
+
+// PostClassManualScore 老师对单道主观题的评分
+type PostClassManualScore struct {
+	QuestionID string `json:"questionId" binding:"required"`
+	Score      int    `json:"score" binding:"min=0"`
+}
+
+// GradeSubmission 老师人工批改提交中的主观题：写入各题得分，重新汇总总分，
+// 全部主观题批改后提交不再标记 needsManual。可重复调用以修改已批改的分数。
+// 非管理员只能批改自己创建的试卷；人工批改的得分只计入成绩，不额外发放经验
+func (s *PostClassTestService) GradeSubmission(graderID uint, role model.UserRole, submissionID string, scores []PostClassManualScore) (*model.PostClassTestSubmission, error) {
+	submission, answers, err := s.Repo.GetSubmissionDetail(submissionID)
+	if err != nil {
+		return nil, err
+	}
+	test, qs, err := s.GetTest(submission.TestID)
+	if err != nil {
+		return nil, err
+	}
+	if role != model.Admin && test.CreatorID != graderID {
+		return nil, util.ErrPermissionDenied
+	}
+
+	points := make(map[string]int, len(qs))
+	for _, q := range qs {
+		points[q.ID] = q.Points
+	}
+	answerIdx := make(map[string]int, len(answers))
+	for i, a := range answers {
+		answerIdx[a.QuestionID] = i
+	}
+
+	graded := make([]model.PostClassTestAnswer, 0, len(scores))
+	for _, sc := range scores {
+		i, ok := answerIdx[sc.QuestionID]
+		if !ok || !isManualPostClassQuestion(answers[i].QuestionType) {
+			return nil, fmt.Errorf("%w: 题目 %s 不是该提交中的主观题", util.ErrInvalidRequest, sc.QuestionID)
+		}
+		maxScore, ok := points[sc.QuestionID]
+		if !ok || sc.Score > maxScore {
+			return nil, fmt.Errorf("%w: 题目 %s 的得分须在 0-%d 之间", util.ErrInvalidRequest, sc.QuestionID, maxScore)
+		}
+		answers[i].Score = sc.Score
+		answers[i].IsCorrect = sc.Score == maxScore
+		answers[i].NeedsManual = false
+		graded = append(graded, answers[i])
+	}
+
+	total := 0
+	needsManual := false
+	for _, a := range answers {
+		total += a.Score
+		needsManual = needsManual || a.NeedsManual
+	}
+	submission.Score = total
+	submission.NeedsManual = needsManual
+
+	if err := s.Repo.SaveGradedAnswers(submission, graded); err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+// sameChoiceSet 多选题答案与选项顺序和分隔符无关，"A,C"、"C A" 和 "AC" 视为相同
+func sameChoiceSet(a, b string) bool {
+	normalize := func(s string) string {
+		tokens := choiceTokens(s)
+		seen := make(map[string]bool, len(tokens))
+		unique := make([]string, 0, len(tokens))
+		for _, t := range tokens {
+			if !seen[t] {
+				seen[t] = true
+				unique = append(unique, t)
+			}
+		}
+		sort.Strings(unique)
+		return strings.Join(unique, ",")
+	}
+	return normalize(a) == normalize(b)
+}
+
+// choiceTokens 将选择题答案拆分为大写选项，支持常见分隔符；未使用分隔符的字母组合（如 "AC"）按单个字母拆分
+func choiceTokens(s string) []string {
+	tokens := strings.FieldsFunc(strings.ToUpper(s), func(r rune) bool {
+		return r == ',' || r == '，' || r == ';' || r == '|' || r == '、' || r == ' '
+	})
+	if len(tokens) == 1 && isASCIILetters(tokens[0]) {
+		tokens = strings.Split(tokens[0], "")
+	}
+	return tokens
+}
+
+func isASCIILetters(s string) bool {
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
 type StudentPostClassTestQuestion struct {
 	ID           string          `json:"id"`
 	QuestionType string          `json:"questionType"`
@@ -305,7 +447,8 @@ type StudentPostClassTestQuestion struct {
 	Points       int             `json:"points"`
 	Order        int             `json:"order"`
 	// status == 'completed' 时返回
-	IsCorrect   *bool   `json:"isCorrect,omitempty"`
+	IsCorrect   *bool   `json:"isCorrect,omitempty"`   // 待人工批改的主观题不返回
+	NeedsManual bool    `json:"needsManual,omitempty"` // 主观题，等待老师批改
 	UserAnswer  *string `json:"userAnswer,omitempty"`
 	StudentCode *string `json:"studentCode,omitempty"` // 学生编写的代码
 	Answer      *string `json:"answer,omitempty"`      // 标准答案
@@ -319,7 +462,7 @@ type StudentPostClassTestDetail struct {
 	TimeLimit     int                            `json:"timeLimit"` // 总限时（分钟）
 	QuestionCount int                            `json:"questionCount"`
 	Status        string                         `json:"status"`        // pending, in_progress, completed
-	StartedAt     *time.Time                     `json:"startedAt"`     // 开始答题时间
+	StartedAt     *model.JSONTime                `json:"startedAt"`     // 开始答题时间
 	RemainingTime int                            `json:"remainingTime"` // 剩余秒数
 	Score         *int                           `json:"score,omitempty"`
 	RewardXP      *int                           `json:"rewardXp,omitempty"`
@@ -347,7 +490,7 @@ func (s *PostClassTestService) StartTest(userID uint, testID string) (*model.Pos
 		TestID:    testID,
 		UserID:    userID,
 		Status:    "in_progress",
-		StartedAt: time.Now(),
+		StartedAt: model.NewJSONTime(time.Now()),
 	}
 
 	if err := s.Repo.CreateSubmission(submission); err != nil {
@@ -374,7 +517,7 @@ func (s *PostClassTestService) GetStudentTestDetail(userID uint, testID string)
 
 	submission, _ := s.Repo.FindSubmissionByUserAndTest(userID, testID)
 	status := "pending"
-	var startedAt *time.Time
+	var startedAt *model.JSONTime
 	remainingTime := test.TimeLimit * 60 // 默认总秒数
 
 	var answers []model.PostClassTestAnswer
@@ -384,7 +527,7 @@ func (s *PostClassTestService) GetStudentTestDetail(userID uint, testID string)
 
 		if status == "in_progress" {
 			// 计算剩余时间：总限时(秒) - 已过去时间
-			elapsed := int(time.Since(submission.StartedAt).Seconds())
+			elapsed := int(time.Since(submission.StartedAt.Time).Seconds())
 			remainingTime = (test.TimeLimit * 60) - elapsed
 			if remainingTime < 0 {
 				remainingTime = 0
@@ -416,8 +559,12 @@ func (s *PostClassTestService) GetStudentTestDetail(userID uint, testID string)
 		// 如果是完成状态，填充结果、标准答案和解析
 		if status == "completed" {
 			if ans, ok := ansMap[q.ID]; ok {
-				isCorrect := ans.IsCorrect
-				sq.IsCorrect = &isCorrect
+				if ans.NeedsManual {
+					sq.NeedsManual = true
+				} else {
+					isCorrect := ans.IsCorrect
+					sq.IsCorrect = &isCorrect
+				}
 
 				userAns := ans.UserAnswer
 				// 如果是编程题，尝试解析出结果和代码
@@ -473,14 +620,7 @@ func (s *PostClassTestService) RecordLearningTime(userID uint, testID string, du
 		return err
 	}
 
-	log := &model.LearningLog{
-		UserID:   userID,
-		Activity: "post_class_test",
-		Content:  "课后测试学习: " + test.Title,
-		Duration: duration,
-	}
-
-	return s.Repo.DB.Create(log).Error
+	return recordLearningTime(repository.NewLearningLogRepository(s.Repo.DB), userID, "post_class_test", testID, "课后测试学习: "+test.Title, duration)
 }
 
 func (s *PostClassTestService) ListSubmissions(testID string, page, limit int, studentName string, status string) ([]map[string]interface{}, int64, error) {
@@ -498,11 +638,18 @@ func (s *PostClassTestService) GetSubmissionDetail(submissionID string) (map[str
 		return nil, err
 	}
 
+	// 全班每题平均耗时，便于老师发现难以理解的题目
+	questionTimes, err := s.Repo.AverageQuestionTimes(submission.TestID)
+	if err != nil {
+		return nil, err
+	}
+
 	return map[string]interface{}{
-		"submission": submission,
-		"answers":    answers,
-		"test":       test,
-		"questions":  qs,
+		"submission":    submission,
+		"answers":       answers,
+		"test":          test,
+		"questions":     qs,
+		"questionTimes": questionTimes,
 	}, nil
 }
 
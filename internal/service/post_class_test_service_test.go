@@ -0,0 +1,30 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"testing"
+)
+
+func TestGradePostClassAnswer(t *testing.T) {
+	tests := []struct {
+		name        string
+		q           model.PostClassTestQuestion
+		answer      string
+		wantCorrect bool
+		wantManual  bool
+	}{
+		{"single choice ignores case and spaces", model.PostClassTestQuestion{QuestionType: "single_choice", Answer: "B"}, " b ", true, false},
+		{"multiple choice ignores order", model.PostClassTestQuestion{QuestionType: "multiple_choice", Answer: "A,C"}, "CA", true, false},
+		{"wrong fill blank", model.PostClassTestQuestion{QuestionType: "fill_blank", Answer: "42"}, "41", false, false},
+		{"essay needs manual grading", model.PostClassTestQuestion{QuestionType: "essay", Answer: "参考答案"}, "参考答案", false, true},
+		{"unknown type needs manual grading", model.PostClassTestQuestion{QuestionType: "drawing", Answer: "x"}, "x", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			correct, manual := gradePostClassAnswer(tt.q, tt.answer)
+			if correct != tt.wantCorrect || manual != tt.wantManual {
+				t.Fatalf("gradePostClassAnswer = (%v, %v), want (%v, %v)", correct, manual, tt.wantCorrect, tt.wantManual)
+			}
+		})
+	}
+}
@@ -2,10 +2,14 @@ package service
 
 import (
 	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/util"
 	"coder_edu_backend/pkg/logger"
 	goctx "context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -122,23 +126,24 @@ func (s *QAService) extractKeywords(question string) []string {
 	return keywords
 }
 
-func (s *QAService) buildSearchQuery(db *gorm.DB, table string, fields []string, keywords []string) *gorm.DB {
+// fullTextTables 建有全文索引（ngram）的表，与 database 包中创建的索引保持一致
+var fullTextTables = map[string]bool{
+	"knowledge_points":          true,
+	"exercise_questions":        true,
+	"assessment_questions":      true,
+	"post_class_test_questions": true,
+	"posts":                     true,
+	"questions":                 true,
+}
+
+// buildSearchQuery 按关键词检索：有全文索引的表使用 MATCH...AGAINST，其余表退化为 LIKE
+func buildSearchQuery(db *gorm.DB, table string, fields []string, keywords []string) *gorm.DB {
 	if len(keywords) == 0 {
 		return db
 	}
 
 	query := db.Where("deleted_at IS NULL")
 
-	// 定义支持全文索引的表及其对应的索引字段
-	fullTextTables := map[string]bool{
-		"knowledge_points":          true,
-		"exercise_questions":        true,
-		"assessment_questions":      true,
-		"post_class_test_questions": true,
-		"posts":                     true,
-		"questions":                 true,
-	}
-
 	if fullTextTables[table] {
 		fieldsStr := strings.Join(fields, ",")
 		searchStr := strings.Join(keywords, " ")
@@ -171,27 +176,92 @@ const (
 	IntentGeneral   Intent = "general"   // 通用聊天
 )
 
-// classifyIntent 简单的意图识别逻辑（支持多意图，返回主意图）
-func (s *QAService) classifyIntent(question string) Intent {
+// IntentScore 意图及其命中得分
+type IntentScore struct {
+	Intent Intent
+	Score  int
+}
+
+// intentKeywords 各意图的触发词，按优先级排列（得分相同时靠前的意图优先）
+var intentKeywords = []struct {
+	intent   Intent
+	keywords []string
+}{
+	{IntentPractice, []string{"练习", "编程题", "题目", "错", "报错", "没过", "作业"}},
+	{IntentProgress, []string{"进度", "学到哪", "计划", "接下来", "关卡"}},
+	{IntentCommunity, []string{"帖子", "有人问", "讨论", "社区"}},
+	{IntentKnowledge, []string{"什么是", "怎么", "原理", "知识"}},
+}
+
+// rankIntents 按关键词命中数对意图打分，返回按得分降序排列的意图列表
+// 未命中任何意图时，较长的问题视为知识查询，否则为通用聊天
+func (s *QAService) rankIntents(question string) []IntentScore {
 	q := strings.ToLower(question)
-	// 1. 练习/错题分析意图
-	if strings.Contains(q, "练习") || strings.Contains(q, "编程题") || strings.Contains(q, "题目") ||
-		strings.Contains(q, "错") || strings.Contains(q, "报错") || strings.Contains(q, "没过") || strings.Contains(q, "作业") {
-		return IntentPractice
+
+	var ranked []IntentScore
+	for _, ik := range intentKeywords {
+		score := 0
+		for _, kw := range ik.keywords {
+			if strings.Contains(q, kw) {
+				score++
+			}
+		}
+		if score > 0 {
+			ranked = append(ranked, IntentScore{Intent: ik.intent, Score: score})
+		}
 	}
-	// 2. 进度/计划意图
-	if strings.Contains(q, "进度") || strings.Contains(q, "学到哪") || strings.Contains(q, "计划") || strings.Contains(q, "接下来") || strings.Contains(q, "关卡") {
-		return IntentProgress
+
+	if len(ranked) == 0 {
+		if len(q) > 4 {
+			return []IntentScore{{Intent: IntentKnowledge, Score: 0}}
+		}
+		return []IntentScore{{Intent: IntentGeneral, Score: 0}}
 	}
-	// 3. 社区/互动意图
-	if strings.Contains(q, "帖子") || strings.Contains(q, "有人问") || strings.Contains(q, "讨论") || strings.Contains(q, "社区") {
-		return IntentCommunity
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked
+}
+
+// qaRetrievalBudget 单次问答从各来源检索的条目总数上限，避免复合问题的上下文过长
+const qaRetrievalBudget = 8
+
+// intentSourceLimits 各意图对应来源单独检索时的条数上限
+var intentSourceLimits = map[Intent]int{
+	IntentKnowledge: 2,
+	IntentPractice:  2,
+	IntentProgress:  5,
+	IntentCommunity: 2,
+}
+
+// containsIntent 判断 intents 中是否包含任一 targets
+func containsIntent(intents []Intent, targets ...Intent) bool {
+	for _, it := range intents {
+		for _, t := range targets {
+			if it == t {
+				return true
+			}
+		}
 	}
-	// 4. 知识查询意图 (默认)
-	if strings.Contains(q, "什么是") || strings.Contains(q, "怎么") || strings.Contains(q, "原理") || strings.Contains(q, "知识") || len(q) > 4 {
-		return IntentKnowledge
+	return false
+}
+
+// personalizationEnabled 用户是否允许问答使用个人学习记录，查询失败时按默认开启处理
+func (s *QAService) personalizationEnabled(userID uint) bool {
+	var disabled []bool
+	s.db.Model(&model.User{}).Where("id = ?", userID).Limit(1).Pluck("qa_personalization_disabled", &disabled)
+	return len(disabled) == 0 || !disabled[0]
+}
+
+// retrievalIntents 返回需要检索的意图（按优先级），通用意图不对应具体来源，按知识、练习、进度、社区顺序全部检索
+func retrievalIntents(intents []Intent) []Intent {
+	for _, it := range intents {
+		if it == IntentGeneral {
+			return []Intent{IntentKnowledge, IntentPractice, IntentProgress, IntentCommunity}
+		}
 	}
-	return IntentGeneral
+	return intents
 }
 
 func (s *QAService) isContinueRequest(question string) bool {
@@ -205,13 +275,15 @@ func (s *QAService) isContinueRequest(question string) bool {
 	return false
 }
 
-func (s *QAService) AskStream(ctx goctx.Context, userID uint, question string, sessionID string) (<-chan string, string, <-chan error) {
+// AskStream 流式问答，返回的 streamKey 可用于 StopStream 主动停止生成
+func (s *QAService) AskStream(ctx goctx.Context, userID uint, question string, sessionID string) (out <-chan string, source string, streamKey string, errOut <-chan error) {
+	log := logger.FromContext(ctx)
 	sensitiveWords := []string{"政治", "暴力", "色情"}
 	for _, word := range sensitiveWords {
 		if strings.Contains(question, word) {
 			errChan := make(chan error, 1)
 			errChan <- fmt.Errorf("您的提问包含敏感词，请修改后重新提问")
-			return nil, "llm", errChan
+			return nil, "llm", "", errChan
 		}
 	}
 
@@ -219,7 +291,7 @@ func (s *QAService) AskStream(ctx goctx.Context, userID uint, question string, s
 		var latestHistory model.AIQAHistory
 		if err := s.db.Where("user_id = ?", userID).Order("created_at desc").First(&latestHistory).Error; err == nil && latestHistory.SessionID != "" {
 			sessionID = latestHistory.SessionID
-			logger.Log.Info("继续请求缺少sessionId，自动回退到最近会话",
+			log.Info("继续请求缺少sessionId，自动回退到最近会话",
 				zap.Uint("userID", userID),
 				zap.String("fallbackSessionID", sessionID))
 		}
@@ -290,17 +362,33 @@ func (s *QAService) AskStream(ctx goctx.Context, userID uint, question string, s
 		}
 	}
 
-	// 2. 意图识别与关键词提取（使用原始问题而非"继续"）
-	intent := s.classifyIntent(originalQuestion)
+	// 2. 意图识别与关键词提取（使用原始问题而非"继续"），复合问题会命中多个意图
+	ranked := s.rankIntents(originalQuestion)
+	intents := make([]Intent, 0, len(ranked))
+	intentNames := make([]string, 0, len(ranked))
+	for _, r := range ranked {
+		intents = append(intents, r.Intent)
+		intentNames = append(intentNames, string(r.Intent))
+	}
 	keywords := s.extractKeywords(originalQuestion)
-	context := fmt.Sprintf("【当前对话意图: %s】\n", intent)
-	source := "llm"
+	personalized := s.personalizationEnabled(userID)
+	log.Info("QA意图识别",
+		zap.Uint("userID", userID),
+		zap.Any("intents", ranked),
+		zap.Strings("keywords", keywords))
+	context := fmt.Sprintf("【当前对话意图: %s】\n", strings.Join(intentNames, ","))
+	source = "llm"
 
 	// 预生成引用链接列表
 	var citations []string
 
 	// 3. 检查Redis缓存(针对高频问题，同时缓存citations)
-	cacheKey := fmt.Sprintf("qa:context:cache:%s", strings.Join(keywords, "_"))
+	// 练习、进度意图的上下文包含个人学习记录，开启个性化时缓存按用户隔离
+	cacheScope := "shared"
+	if personalized && containsIntent(retrievalIntents(intents), IntentPractice, IntentProgress) {
+		cacheScope = fmt.Sprintf("user%d", userID)
+	}
+	cacheKey := fmt.Sprintf("qa:context:cache:%s:%s:%s", cacheScope, strings.Join(intentNames, "_"), strings.Join(keywords, "_"))
 	citationCacheKey := cacheKey + ":citations"
 	if cachedContext, err := s.rdb.Get(goctx.Background(), cacheKey).Result(); err == nil {
 		context = cachedContext
@@ -319,93 +407,108 @@ func (s *QAService) AskStream(ctx goctx.Context, userID uint, question string, s
 		//   /courses/:id          → /levels/detail?id=:id               需要 levels uint ID          ← 匹配
 		// =======================================================================================
 
-		// 4.1 知识类检索 (仅在知识或通用意图下执行)
-		if intent == IntentKnowledge || intent == IntentGeneral {
-			var kps []model.KnowledgePoint
-			s.buildSearchQuery(s.db.Model(&model.KnowledgePoint{}), "knowledge_points", []string{"title", "article_content"}, keywords).
-				Limit(2).Find(&kps)
-			for _, kp := range kps {
-				source = "knowledge_base"
-				context += fmt.Sprintf("标题: %s\n内容: %s\n\n", kp.Title, kp.ArticleContent)
-				// 不生成链接：因为前端将 /knowledge/detail/:id 跳转到 community_resources 页面，但此 ID 来自 knowledge_points 表，不匹配
+		// 按意图得分从高到低依次检索各来源，所有来源共用 qaRetrievalBudget 条的检索预算，
+		// 排在前面的意图优先拿到完整配额
+		budget := qaRetrievalBudget
+		for _, intent := range retrievalIntents(intents) {
+			limit := intentSourceLimits[intent]
+			if limit > budget {
+				limit = budget
 			}
-		}
-
-		// 4.2 练习类检索 (仅在练习或通用意图下执行)
-		if intent == IntentPractice || intent == IntentGeneral {
-			var exercises []model.ExerciseQuestion
-			s.buildSearchQuery(s.db.Model(&model.ExerciseQuestion{}), "exercise_questions", []string{"title", "description"}, keywords).
-				Limit(2).Find(&exercises)
-			for _, ex := range exercises {
-				source = "knowledge_base"
-				context += fmt.Sprintf("题目: %s\n描述: %s\n提示: %s\n\n", ex.Title, ex.Description, ex.Hint)
-				// 不生成链接：因为前端将 /practice/:id 跳转到 /levels/detail，但 exercise ID ≠ level ID，不匹配
+			if limit <= 0 {
+				break
 			}
 
-			var lastSubmissions []model.ExerciseSubmission
-			s.db.Where("user_id = ?", userID).Order("created_at desc").Limit(3).Find(&lastSubmissions)
-			if len(lastSubmissions) > 0 {
-				context += "【用户最近练习记录】\n"
-				for _, sub := range lastSubmissions {
-					resStr := "错误"
-					if sub.IsCorrect {
-						resStr = "正确"
-					}
-					context += fmt.Sprintf("- 题目ID: %d, 结果: %s, 时间: %s\n", sub.QuestionID, resStr, sub.CreatedAt.Format("15:04"))
+			switch intent {
+			case IntentKnowledge:
+				var kps []model.KnowledgePoint
+				buildSearchQuery(s.db.Model(&model.KnowledgePoint{}), "knowledge_points", []string{"title", "article_content"}, keywords).
+					Limit(limit).Find(&kps)
+				for _, kp := range kps {
+					source = "knowledge_base"
+					context += fmt.Sprintf("标题: %s\n内容: %s\n\n", kp.Title, kp.ArticleContent)
+					// 不生成链接：因为前端将 /knowledge/detail/:id 跳转到 community_resources 页面，但此 ID 来自 knowledge_points 表，不匹配
 				}
-			}
-		}
+				budget -= len(kps)
+
+			case IntentPractice:
+				var exercises []model.ExerciseQuestion
+				buildSearchQuery(s.db.Model(&model.ExerciseQuestion{}), "exercise_questions", []string{"title", "description"}, keywords).
+					Limit(limit).Find(&exercises)
+				for _, ex := range exercises {
+					source = "knowledge_base"
+					context += fmt.Sprintf("题目: %s\n描述: %s\n提示: %s\n\n", ex.Title, ex.Description, ex.Hint)
+					// 不生成链接：因为前端将 /practice/:id 跳转到 /levels/detail，但 exercise ID ≠ level ID，不匹配
+				}
+				budget -= len(exercises)
 
-		// 4.3 进度类检索
-		if intent == IntentProgress || intent == IntentGeneral {
-			var progress []model.UserProgress
-			s.db.Where("user_id = ?", userID).Order("updated_at desc").Limit(2).Find(&progress)
-			for _, p := range progress {
-				status := "进行中"
-				if p.Completed {
-					status = "已完成"
+				var lastSubmissions []model.ExerciseSubmission
+				if personalized {
+					s.db.Where("user_id = ?", userID).Order("created_at desc").Limit(3).Find(&lastSubmissions)
+				}
+				if len(lastSubmissions) > 0 {
+					context += "【用户最近练习记录】\n"
+					for _, sub := range lastSubmissions {
+						resStr := "错误"
+						if sub.IsCorrect {
+							resStr = "正确"
+						}
+						context += fmt.Sprintf("- 题目ID: %d, 结果: %s, 时间: %s\n", sub.QuestionID, resStr, sub.CreatedAt.Format("15:04"))
+					}
 				}
-				context += fmt.Sprintf("学习进度: 模块ID: %d, 状态: %s, 积分: %d\n", p.ModuleID, status, p.Score)
-			}
 
-			// 先尝试用关键词匹配已发布关卡
-			var levels []model.Level
-			hasKeywords := false
-			for _, kw := range keywords {
-				if len(kw) > 1 {
-					hasKeywords = true
-					break
+			case IntentProgress:
+				var progress []model.UserProgress
+				if personalized {
+					s.db.Where("user_id = ?", userID).Order("updated_at desc").Limit(2).Find(&progress)
+				}
+				for _, p := range progress {
+					status := "进行中"
+					if p.Completed {
+						status = "已完成"
+					}
+					context += fmt.Sprintf("学习进度: 模块ID: %d, 状态: %s, 积分: %d\n", p.ModuleID, status, p.Score)
 				}
-			}
-			if hasKeywords {
-				s.buildSearchQuery(s.db.Where("is_published = ?", true).Model(&model.Level{}), "levels", []string{"title", "description"}, keywords).
-					Limit(5).Find(&levels)
-			}
 
-			// 兜底：如果关键词未匹配到关卡，返回最近发布的关卡
-			if len(levels) == 0 {
-				s.db.Where("is_published = ?", true).Order("published_at desc").Limit(5).Find(&levels)
-			}
+				// 先尝试用关键词匹配已发布关卡
+				var levels []model.Level
+				hasKeywords := false
+				for _, kw := range keywords {
+					if len(kw) > 1 {
+						hasKeywords = true
+						break
+					}
+				}
+				if hasKeywords {
+					buildSearchQuery(s.db.Where("is_published = ?", true).Model(&model.Level{}), "levels", []string{"title", "description"}, keywords).
+						Limit(limit).Find(&levels)
+				}
 
-			if len(levels) > 0 {
-				source = "knowledge_base"
-				context += "【已发布的关卡列表】\n"
-				for _, lv := range levels {
-					context += fmt.Sprintf("关卡: %s（难度: %s）\n描述: %s\n\n", lv.Title, lv.Difficulty, lv.Description)
-					citations = append(citations, fmt.Sprintf("- [%s](/courses/%d)", lv.Title, lv.ID))
+				// 兜底：如果关键词未匹配到关卡，返回最近发布的关卡
+				if len(levels) == 0 {
+					s.db.Where("is_published = ?", true).Order("published_at desc").Limit(limit).Find(&levels)
 				}
-			}
-		}
 
-		// 4.4 社区类检索
-		if intent == IntentCommunity || intent == IntentGeneral {
-			var posts []model.Post
-			s.buildSearchQuery(s.db.Model(&model.Post{}), "posts", []string{"title", "content"}, keywords).
-				Limit(1).Find(&posts)
-			for _, p := range posts {
-				source = "knowledge_base"
-				context += fmt.Sprintf("帖子: %s\n内容摘要: %s\n\n", p.Title, p.Content)
-				citations = append(citations, fmt.Sprintf("- [%s](/community/post/%s)", p.Title, p.ID))
+				if len(levels) > 0 {
+					source = "knowledge_base"
+					context += "【已发布的关卡列表】\n"
+					for _, lv := range levels {
+						context += fmt.Sprintf("关卡: %s（难度: %s）\n描述: %s\n\n", lv.Title, lv.Difficulty, lv.Description)
+						citations = append(citations, fmt.Sprintf("- [%s](/courses/%d)", lv.Title, lv.ID))
+					}
+				}
+				budget -= len(levels)
+
+			case IntentCommunity:
+				var posts []model.Post
+				buildSearchQuery(s.db.Model(&model.Post{}), "posts", []string{"title", "content"}, keywords).
+					Limit(limit).Find(&posts)
+				for _, p := range posts {
+					source = "knowledge_base"
+					context += fmt.Sprintf("帖子: %s\n内容摘要: %s\n\n", p.Title, p.Content)
+					citations = append(citations, fmt.Sprintf("- [%s](/community/post/%s)", p.Title, p.ID))
+				}
+				budget -= len(posts)
 			}
 		}
 
@@ -425,13 +528,25 @@ func (s *QAService) AskStream(ctx goctx.Context, userID uint, question string, s
 	}
 
 	// 6. 调用AI Service获取流式回答（不再在 Prompt 中要求 AI 输出链接）
-	stream, aiErrChan, streamResult := s.aiService.ChatStream(question, context, historyMessages)
+	// aiCtx 在用户主动停止或客户端断开时取消，中断上游请求以节省 token
+	aiCtx, cancelAI := goctx.WithCancel(goctx.Background())
+	stream, aiErrChan, streamResult := s.aiService.ChatStreamContext(aiCtx, question, context, historyMessages)
 
 	// 7. 创建一个包装后的 channel
 	wrappedOut := make(chan string)
 	wrappedErr := make(chan error, 1)
 
 	tempKey := fmt.Sprintf("qa:stream:temp:%d:%s:%d", userID, sessionID, time.Now().Unix())
+	streamKey = tempKey
+	// 流开始时即写入暂存键，StopStream 据此判断流是否仍在进行；收到内容后会刷新过期时间，结束时由 saveHistory 删除
+	s.rdb.Set(goctx.Background(), tempKey, "", 10*time.Minute)
+
+	// 监听停止标记（POST /api/qa/stop 写入），多实例部署下同样生效
+	var stopped atomic.Bool
+	go s.watchStreamStop(aiCtx, tempKey, func() {
+		stopped.Store(true)
+		cancelAI()
+	})
 
 	// saveHistory 封装保存对话历史逻辑（正常结束和客户端断开都需要保存）
 	saveHistory := func(fullAnswer string, streamCompleted bool) {
@@ -470,9 +585,9 @@ func (s *QAService) AskStream(ctx goctx.Context, userID uint, question string, s
 				Source:    source,
 			}
 			if err := s.db.Create(&history).Error; err != nil {
-				logger.Log.Error("Failed to save QA history", zap.Error(err))
+				log.Error("Failed to save QA history", zap.Error(err))
 			} else {
-				logger.Log.Info("QA history saved",
+				log.Info("QA history saved",
 					zap.Uint("userID", userID),
 					zap.String("sessionID", sessionID),
 					zap.Bool("completed", streamCompleted),
@@ -484,6 +599,7 @@ func (s *QAService) AskStream(ctx goctx.Context, userID uint, question string, s
 	go func() {
 		defer close(wrappedErr)
 		defer close(wrappedOut)
+		defer cancelAI()
 
 		var fullAnswer string
 		streamCompleted := false    // 标记流是否正常完成
@@ -491,6 +607,9 @@ func (s *QAService) AskStream(ctx goctx.Context, userID uint, question string, s
 
 		// 消费 AI stream，使用 select 检测客户端断开，避免 goroutine 永久阻塞
 		for content := range stream {
+			if stopped.Load() {
+				break
+			}
 			fullAnswer += content
 			s.rdb.Set(goctx.Background(), tempKey, fullAnswer, 10*time.Minute)
 
@@ -501,16 +620,17 @@ func (s *QAService) AskStream(ctx goctx.Context, userID uint, question string, s
 			case <-ctx.Done():
 				// 客户端断开连接
 				clientDisconnected = true
-				logger.Log.Info("Client disconnected during stream",
+				log.Info("Client disconnected during stream",
 					zap.Uint("userID", userID),
 					zap.String("sessionID", sessionID),
 					zap.Int("currentAnswerLen", len(fullAnswer)))
 			}
 
 			if clientDisconnected {
+				cancelAI()
 				// 客户端断开后，立即保存当前已有的部分回答到数据库
 				saveHistory(fullAnswer, false)
-				logger.Log.Info("Partial answer saved immediately on disconnect",
+				log.Info("Partial answer saved immediately on disconnect",
 					zap.Uint("userID", userID),
 					zap.String("sessionID", sessionID),
 					zap.Int("savedLen", len(fullAnswer)))
@@ -528,9 +648,24 @@ func (s *QAService) AskStream(ctx goctx.Context, userID uint, question string, s
 			}
 		}
 
+		// 用户主动停止：保存已生成的部分回答，不视为错误
+		if stopped.Load() {
+			go func() {
+				for range stream {
+				}
+			}()
+			saveHistory(fullAnswer, false)
+			s.rdb.Del(goctx.Background(), tempKey+":stop")
+			log.Info("QA stream stopped by user",
+				zap.Uint("userID", userID),
+				zap.String("sessionID", sessionID),
+				zap.Int("savedLen", len(fullAnswer)))
+			return
+		}
+
 		// 检查 AI 错误（仅正常流结束时执行）
 		if err := <-aiErrChan; err != nil {
-			logger.Log.Error("AI stream error", zap.Error(err))
+			log.Error("AI stream error", zap.Error(err))
 			wrappedErr <- err
 		} else {
 			streamCompleted = true
@@ -549,7 +684,40 @@ func (s *QAService) AskStream(ctx goctx.Context, userID uint, question string, s
 		saveHistory(fullAnswer, streamCompleted)
 	}()
 
-	return wrappedOut, source, wrappedErr
+	return wrappedOut, source, streamKey, wrappedErr
+}
+
+// watchStreamStop 轮询停止标记，发现后回调 onStop；ctx 结束时退出
+func (s *QAService) watchStreamStop(ctx goctx.Context, tempKey string, onStop func()) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	stopKey := tempKey + ":stop"
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := s.rdb.Exists(goctx.Background(), stopKey).Result(); err == nil && n > 0 {
+				onStop()
+				return
+			}
+		}
+	}
+}
+
+// StopStream 停止指定的进行中问答流，streamKey 即 Ask 接口 stream 事件返回的键
+func (s *QAService) StopStream(userID uint, streamKey string) error {
+	if !strings.HasPrefix(streamKey, fmt.Sprintf("qa:stream:temp:%d:", userID)) {
+		return util.ErrQAStreamNotFound
+	}
+	n, err := s.rdb.Exists(goctx.Background(), streamKey).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return util.ErrQAStreamNotFound
+	}
+	return s.rdb.Set(goctx.Background(), streamKey+":stop", "1", 10*time.Minute).Err()
 }
 
 func (s *QAService) GetHistory(userID uint, limit, offset int) ([]model.AIQAHistory, int64, error) {
@@ -563,6 +731,38 @@ func (s *QAService) GetHistory(userID uint, limit, offset int) ([]model.AIQAHist
 	return histories, total, err
 }
 
+// QASessionThread 单个会话的完整对话线程
+type QASessionThread struct {
+	SessionID string              `json:"sessionId"`
+	Title     string              `json:"title"` // 取会话首个问题
+	Messages  []model.AIQAHistory `json:"messages"`
+}
+
+// GetSessionThread 按时间正序返回指定会话的全部问答记录
+func (s *QAService) GetSessionThread(userID uint, sessionID string) (*QASessionThread, error) {
+	var histories []model.AIQAHistory
+	if err := s.db.Where("user_id = ? AND session_id = ?", userID, sessionID).
+		Order("created_at asc, id asc").Find(&histories).Error; err != nil {
+		return nil, err
+	}
+
+	if len(histories) == 0 {
+		// 区分会话不存在与会话属于其他用户
+		var count int64
+		s.db.Model(&model.AIQAHistory{}).Where("session_id = ?", sessionID).Count(&count)
+		if count > 0 {
+			return nil, util.ErrQASessionForbidden
+		}
+		return nil, util.ErrQASessionNotFound
+	}
+
+	return &QASessionThread{
+		SessionID: sessionID,
+		Title:     histories[0].Question,
+		Messages:  histories,
+	}, nil
+}
+
 // DeleteSession 删除指定会话的所有历史记录
 func (s *QAService) DeleteSession(userID uint, sessionID string) error {
 	result := s.db.Where("user_id = ? AND session_id = ?", userID, sessionID).Delete(&model.AIQAHistory{})
@@ -597,8 +797,53 @@ func (s *QAService) CheckRateLimit(userID uint) (bool, error) {
 	return true, nil
 }
 
+// WeeklyReportMeta 周报元信息
+type WeeklyReportMeta struct {
+	GeneratedAt model.JSONTime `json:"generatedAt"`
+	Cached      bool           `json:"cached"`
+}
+
+type cachedWeeklyReport struct {
+	Content     string         `json:"content"`
+	GeneratedAt model.JSONTime `json:"generatedAt"`
+}
+
+// weeklyReportCacheKey 按用户和 ISO 周生成缓存键，并返回距本周结束的剩余时长
+func weeklyReportCacheKey(userID uint, now time.Time) (string, time.Duration) {
+	year, week := now.ISOWeek()
+	key := fmt.Sprintf("qa:report:weekly:%d:%d-W%02d", userID, year, week)
+
+	// ISO 周从周一开始，计算下周一零点
+	daysUntilMonday := (8 - int(now.Weekday())) % 7
+	if daysUntilMonday == 0 {
+		daysUntilMonday = 7
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	weekEnd := today.AddDate(0, 0, daysUntilMonday)
+	return key, weekEnd.Sub(now)
+}
+
 // GenerateWeeklyReport 生成学习周报
-func (s *QAService) GenerateWeeklyReport(userID uint) (<-chan string, <-chan error) {
+// 同一 ISO 周内的周报会缓存到 Redis，重复请求直接回放缓存内容；force 为 true 时强制重新生成。
+// ctx 为请求上下文，客户端断开后停止转发内容，但仍会生成完毕并写入缓存
+func (s *QAService) GenerateWeeklyReport(ctx goctx.Context, userID uint, force bool) (<-chan string, <-chan error, *WeeklyReportMeta) {
+	now := time.Now()
+	cacheKey, ttl := weeklyReportCacheKey(userID, now)
+
+	if !force {
+		if raw, err := s.rdb.Get(goctx.Background(), cacheKey).Result(); err == nil {
+			var cached cachedWeeklyReport
+			if err := json.Unmarshal([]byte(raw), &cached); err == nil && cached.Content != "" {
+				out := make(chan string, 1)
+				errChan := make(chan error, 1)
+				out <- cached.Content
+				close(out)
+				close(errChan)
+				return out, errChan, &WeeklyReportMeta{GeneratedAt: cached.GeneratedAt, Cached: true}
+			}
+		}
+	}
+
 	// 1. 获取过去一周的数据
 	oneWeekAgo := time.Now().AddDate(0, 0, -7)
 
@@ -629,13 +874,51 @@ func (s *QAService) GenerateWeeklyReport(userID uint) (<-chan string, <-chan err
 
 	systemPrompt := "你是一个专业的编程教育导师。请根据提供的用户过去一周的学习数据，生成一份鼓励性的、专业的学习周报。周报应包含：1. 学习概况总结；2. 技术亮点分析；3. 薄弱环节建议；4. 下周学习规划。请使用 Markdown 格式，并严格遵守之前的 Markdown 渲染指令。"
 
-	// 3. 调用AI生成
-	stream, errChan, _ := s.aiService.ChatStream(systemPrompt, reportContext, nil)
-	return stream, errChan
+	// 3. 调用AI生成，边转发边缓冲，完整生成后写入缓存
+	stream, aiErrChan, _ := s.aiService.ChatStream(systemPrompt, reportContext, nil)
+
+	out := make(chan string)
+	errChan := make(chan error, 1)
+	meta := &WeeklyReportMeta{GeneratedAt: model.NewJSONTime(now)}
+
+	go func() {
+		defer close(errChan)
+		defer close(out)
+
+		// 客户端断开后不再转发，但继续读完上游流：既不阻塞在发送上，也能把完整周报写入缓存
+		consumerGone := false
+		var buf strings.Builder
+		for content := range stream {
+			buf.WriteString(content)
+			if consumerGone {
+				continue
+			}
+			select {
+			case out <- content:
+			case <-ctx.Done():
+				consumerGone = true
+			}
+		}
+
+		if err := <-aiErrChan; err != nil {
+			errChan <- err
+			return
+		}
+
+		if buf.Len() == 0 {
+			return
+		}
+		data, _ := json.Marshal(cachedWeeklyReport{Content: buf.String(), GeneratedAt: model.NewJSONTime(now)})
+		if err := s.rdb.Set(goctx.Background(), cacheKey, data, ttl).Err(); err != nil {
+			logger.Log.Warn("Failed to cache weekly report", zap.Uint("userID", userID), zap.Error(err))
+		}
+	}()
+
+	return out, errChan, meta
 }
 
 // DiagnoseCode 自动代码诊断
-func (s *QAService) DiagnoseCode(userID uint, questionID uint, code string, compilerError string) (<-chan string, <-chan error) {
+func (s *QAService) DiagnoseCode(userID uint, questionID uint, code string, compilerError string, errorType string, language string) (<-chan string, <-chan error) {
 	// 1. 获取题目背景
 	var exercise model.ExerciseQuestion
 	s.db.First(&exercise, questionID)
@@ -643,7 +926,10 @@ func (s *QAService) DiagnoseCode(userID uint, questionID uint, code string, comp
 	// 2. 构造诊断Context
 	context := fmt.Sprintf("【题目信息】\n标题: %s\n描述: %s\n提示: %s\n\n",
 		exercise.Title, exercise.Description, exercise.Hint)
-	context += fmt.Sprintf("【用户提交的代码】\n```c\n%s\n```\n\n", code)
+	context += fmt.Sprintf("【用户提交的代码】\n```%s\n%s\n```\n\n", NormalizeCodeLanguage(language), code)
+	if label := CodeErrorLabel(errorType); label != "" {
+		context += fmt.Sprintf("【运行结果】%s\n", label)
+	}
 	context += fmt.Sprintf("【编译器/判题报错】\n%s\n", compilerError)
 
 	systemPrompt := "你是一个资深的编程导师。请分析用户的代码和报错信息，指出逻辑错误或语法错误。要求：1. 不要直接给出完整正确答案；2. 采用启发式引导，指出错误行号和原因；3. 给出修改建议。严格遵守 Markdown 渲染指令。"
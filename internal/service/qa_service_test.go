@@ -0,0 +1,48 @@
+package service
+
+import "testing"
+
+func TestRankIntents(t *testing.T) {
+	s := &QAService{}
+	cases := []struct {
+		question  string
+		primary   Intent
+		secondary Intent // 为空表示只命中一个意图
+	}{
+		{"什么是指针", IntentKnowledge, ""},
+		{"这道编程题报错了怎么办", IntentPractice, IntentKnowledge},
+		{"我的学习进度怎么样，接下来学哪个关卡", IntentProgress, IntentKnowledge},
+		{"社区里有人问过指针的帖子吗", IntentCommunity, ""},
+		{"练习里怎么理解指针原理", IntentKnowledge, IntentPractice},
+		{"作业相关的知识", IntentPractice, IntentKnowledge}, // 得分相同时按优先级排序
+		{"有人问过进度计划的帖子吗", IntentProgress, IntentCommunity},
+		{"指针和数组的区别", IntentKnowledge, ""}, // 未命中关键词的较长问题
+		{"hi", IntentGeneral, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.question, func(t *testing.T) {
+			got := s.rankIntents(tc.question)
+			if len(got) == 0 {
+				t.Fatalf("rankIntents(%q) returned no intents", tc.question)
+			}
+			if got[0].Intent != tc.primary {
+				t.Fatalf("primary intent = %s, want %s (ranked %v)", got[0].Intent, tc.primary, got)
+			}
+			if tc.secondary == "" {
+				if len(got) != 1 {
+					t.Fatalf("ranked %v, want only %s", got, tc.primary)
+				}
+				return
+			}
+			if len(got) < 2 || got[1].Intent != tc.secondary {
+				t.Fatalf("secondary intent mismatch: ranked %v, want %s", got, tc.secondary)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].Score > got[i-1].Score {
+					t.Fatalf("ranked %v is not sorted by score", got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxRecommendationItems 推荐列表最多返回的条数
+	maxRecommendationItems = 10
+	// recentFailureWindow 近期失败关卡的统计窗口
+	recentFailureWindow = 14 * 24 * time.Hour
+	// weakAbilityThreshold 平均得分率低于该值的能力视为薄弱项
+	weakAbilityThreshold = 60.0
+)
+
+// rankRecommendationItems 汇总各推荐信号产生的候选项并按分数排序。
+// 同一内容被多个信号命中时保留分数最高的一条及其理由
+func (s *AnalyticsService) rankRecommendationItems(userID uint) ([]model.RecommendationItem, error) {
+	var candidates []model.RecommendationItem
+
+	// 1. 近期失败：越近失败越优先
+	failed, err := s.RecommendationRepo.FindRecentFailedLevels(userID, time.Now().Add(-recentFailureWindow), maxRecommendationItems)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range failed {
+		days := time.Since(f.LastEndedAt).Hours() / 24
+		candidates = append(candidates, model.RecommendationItem{
+			Type:     "level",
+			TargetID: strconv.FormatUint(uint64(f.LevelID), 10),
+			Title:    f.Title,
+			Signal:   model.RecommendationSignalRecentFailure,
+			Reason:   fmt.Sprintf("你最近挑战「%s」未通过（最高 %d 分），建议巩固后再次尝试", f.Title, f.BestScore),
+			Score:    math.Max(50, 90-2*days),
+		})
+	}
+
+	// 2. 薄弱能力：得分率越低越优先，所有薄弱能力的关卡一次查出
+	abilityScores, err := s.LevelAttemptRepo.GetNormalizedAbilityScores([]uint{userID})
+	if err != nil {
+		return nil, err
+	}
+	var abilities []model.Ability
+	if err := s.DB.Where("enabled = ?", true).Order("`order` ASC").Find(&abilities).Error; err != nil {
+		return nil, err
+	}
+	weak := make(map[uint]model.Ability)
+	weakIDs := make([]uint, 0, len(abilities))
+	for _, a := range abilities {
+		if score, ok := abilityScores[a.ID]; ok && score < weakAbilityThreshold {
+			weak[a.ID] = a
+			weakIDs = append(weakIDs, a.ID)
+		}
+	}
+	abilityLevels, err := s.RecommendationRepo.FindUnpassedLevelsByAbilities(userID, weakIDs, 2)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range abilityLevels {
+		a, ok := weak[l.AbilityID]
+		if !ok {
+			continue
+		}
+		score := abilityScores[l.AbilityID]
+		candidates = append(candidates, model.RecommendationItem{
+			Type:     "level",
+			TargetID: strconv.FormatUint(uint64(l.LevelID), 10),
+			Title:    l.Title,
+			Signal:   model.RecommendationSignalWeakAbility,
+			Reason:   fmt.Sprintf("你在「%s」能力上的平均得分率为 %.0f%%，该关卡可针对性练习", a.Name, score),
+			Score:    100 - score,
+		})
+	}
+
+	// 3. 未完成的前置知识点：知识点按学习顺序排列，排在已完成知识点之前却未完成的视为跳过的前置内容，越靠前越优先
+	latest, err := s.RecommendationRepo.FindLatestCompletedKnowledgePoint(userID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil {
+		points, err := s.RecommendationRepo.FindIncompleteKnowledgePointsBefore(userID, latest.Order, 3)
+		if err != nil {
+			return nil, err
+		}
+		for i, kp := range points {
+			candidates = append(candidates, model.RecommendationItem{
+				Type:     "knowledge_point",
+				TargetID: kp.ID,
+				Title:    kp.Title,
+				Signal:   model.RecommendationSignalIncompletePrereq,
+				Reason:   fmt.Sprintf("「%s」是你已学习的「%s」的前置内容，尚未完成学习", kp.Title, latest.Title),
+				Score:    float64(60 - 5*i),
+			})
+		}
+	}
+
+	return mergeRecommendationItems(candidates), nil
+}
+
+// mergeRecommendationItems 按内容去重（保留分数最高的一条及其理由），按分数降序排列并截取前 maxRecommendationItems 条
+func mergeRecommendationItems(candidates []model.RecommendationItem) []model.RecommendationItem {
+	best := make(map[string]int)
+	items := make([]model.RecommendationItem, 0, len(candidates))
+	for _, c := range candidates {
+		c.Score = math.Round(c.Score*10) / 10
+		key := c.Type + ":" + c.TargetID
+		if i, ok := best[key]; ok {
+			if c.Score > items[i].Score {
+				items[i] = c
+			}
+			continue
+		}
+		best[key] = len(items)
+		items = append(items, c)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Score > items[j].Score })
+	if len(items) > maxRecommendationItems {
+		items = items[:maxRecommendationItems]
+	}
+	return items
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"strconv"
+	"testing"
+)
+
+func TestMergeRecommendationItemsKeepsHighestScorePerTarget(t *testing.T) {
+	candidates := []model.RecommendationItem{
+		{Type: "level", TargetID: "1", Signal: model.RecommendationSignalRecentFailure, Score: 70},
+		{Type: "level", TargetID: "2", Signal: model.RecommendationSignalWeakAbility, Score: 55.55},
+		{Type: "level", TargetID: "1", Signal: model.RecommendationSignalWeakAbility, Score: 80},
+		{Type: "knowledge_point", TargetID: "1", Signal: model.RecommendationSignalIncompletePrereq, Score: 60},
+	}
+
+	items := mergeRecommendationItems(candidates)
+	if len(items) != 3 {
+		t.Fatalf("got %d items, want 3", len(items))
+	}
+	if items[0].TargetID != "1" || items[0].Type != "level" || items[0].Signal != model.RecommendationSignalWeakAbility || items[0].Score != 80 {
+		t.Fatalf("top item = %+v, want level 1 from weak_ability with score 80", items[0])
+	}
+	if items[1].Type != "knowledge_point" {
+		t.Fatalf("second item = %+v, want the knowledge point", items[1])
+	}
+	if items[2].Score != 55.6 {
+		t.Fatalf("score rounded to %v, want 55.6", items[2].Score)
+	}
+}
+
+func TestMergeRecommendationItemsCapsLength(t *testing.T) {
+	var candidates []model.RecommendationItem
+	for i := 0; i < maxRecommendationItems+5; i++ {
+		candidates = append(candidates, model.RecommendationItem{Type: "level", TargetID: strconv.Itoa(i), Score: float64(i)})
+	}
+	items := mergeRecommendationItems(candidates)
+	if len(items) != maxRecommendationItems {
+		t.Fatalf("got %d items, want %d", len(items), maxRecommendationItems)
+	}
+	for i := 1; i < len(items); i++ {
+		if items[i-1].Score < items[i].Score {
+			t.Fatalf("items not sorted by score: %v before %v", items[i-1].Score, items[i].Score)
+		}
+	}
+}
@@ -3,17 +3,20 @@ package service
 import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
 	"errors"
 
 	"gorm.io/gorm"
 )
 
 type ReflectionService struct {
-	repo *repository.ReflectionRepository
+	repo     *repository.ReflectionRepository
+	userRepo *repository.UserRepository
+	events   *EventBus
 }
 
-func NewReflectionService(repo *repository.ReflectionRepository) *ReflectionService {
-	return &ReflectionService{repo: repo}
+func NewReflectionService(repo *repository.ReflectionRepository, userRepo *repository.UserRepository, events *EventBus) *ReflectionService {
+	return &ReflectionService{repo: repo, userRepo: userRepo, events: events}
 }
 
 func (s *ReflectionService) SaveReflection(userID uint, summary, challenges, connections, nextSteps string) (*model.Reflection, error) {
@@ -52,10 +55,74 @@ func (s *ReflectionService) ListAllReflections(name string, page, pageSize int)
 	return s.repo.ListAll(name, page, pageSize)
 }
 
-func (s *ReflectionService) UpdateReflectionByUserID(userID uint, summary, challenges, connections, nextSteps string) (*model.Reflection, error) {
-	return s.SaveReflection(userID, summary, challenges, connections, nextSteps)
-}
-
 func (s *ReflectionService) GetReflectionByID(id string) (*model.Reflection, error) {
 	return s.repo.FindByID(id)
 }
+
+// ListComments 获取学生反思下的评论，学生尚未填写反思时返回空列表
+func (s *ReflectionService) ListComments(ownerID uint) ([]model.ReflectionComment, error) {
+	reflection, err := s.repo.FindByUserID(ownerID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []model.ReflectionComment{}, nil
+		}
+		return nil, err
+	}
+	return s.repo.ListComments(reflection.ID)
+}
+
+// AddComment 在学生 ownerID 的反思下追加评论，parentID 不为空时作为对该评论的回复。
+// 老师留言通知学生，学生回复通知在该反思下留言过的老师
+func (s *ReflectionService) AddComment(ownerID, authorID uint, authorRole model.UserRole, content string, parentID *uint) (*model.ReflectionComment, error) {
+	reflection, err := s.repo.FindByUserID(ownerID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, util.ErrReflectionNotFound
+		}
+		return nil, err
+	}
+	if parentID != nil {
+		if _, err := s.repo.FindComment(reflection.ID, *parentID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, util.ErrCommentNotFound
+			}
+			return nil, err
+		}
+	}
+
+	comment := &model.ReflectionComment{
+		ReflectionID: reflection.ID,
+		ParentID:     parentID,
+		AuthorID:     authorID,
+		AuthorRole:   authorRole,
+		Content:      content,
+	}
+	if err := s.repo.CreateComment(comment); err != nil {
+		return nil, err
+	}
+	if author, err := s.userRepo.FindByID(authorID); err == nil {
+		comment.Author = author
+	}
+
+	var recipients []uint
+	if authorID == ownerID {
+		recipients, err = s.repo.CommentAuthorIDs(reflection.ID, model.Student)
+		if err != nil {
+			return comment, nil
+		}
+	} else {
+		recipients = []uint{ownerID}
+	}
+	evt := ReflectionCommentedEvent{
+		ReflectionID: reflection.ID,
+		CommentID:    comment.ID,
+		AuthorID:     authorID,
+		RecipientIDs: recipients,
+		Preview:      truncateUTF8(content, 255),
+	}
+	if comment.Author != nil {
+		evt.AuthorName = comment.Author.Name
+	}
+	s.events.Publish(EventReflectionCommented, evt)
+	return comment, nil
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 全站搜索的内容类型
+const (
+	SearchTypeKnowledgePoint = "knowledge_point"
+	SearchTypeArticle        = "article"
+	SearchTypeLevel          = "level"
+	SearchTypePost           = "post"
+)
+
+const (
+	// searchPerTypeLimit 每种类型最多返回的命中数，避免某一类内容挤占其它类型
+	searchPerTypeLimit = 20
+	searchSnippetBytes = 300
+)
+
+// SearchService 跨知识点、C 语言文章、关卡和社区帖子的全站搜索
+type SearchService struct {
+	DB *gorm.DB
+}
+
+func NewSearchService(db *gorm.DB) *SearchService {
+	return &SearchService{DB: db}
+}
+
+// SearchResult 单条搜索结果
+type SearchResult struct {
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"`
+	Link    string `json:"link"` // 前端跳转路径
+	Rank    int    `json:"rank"` // 在同类结果中的相关度排名，从 1 开始
+}
+
+// SearchResponse 搜索结果分页
+type SearchResponse struct {
+	Items  []SearchResult `json:"items"`
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"` // 各类型命中数，每类最多 searchPerTypeLimit 条
+}
+
+type searchHit struct {
+	ID       string
+	Title    string
+	Content  string
+	ModuleID uint
+}
+
+// Search 在学生可见的学习内容中搜索。各类型分别按相关度排序取前 searchPerTypeLimit 条，
+// 再按同类排名交替合并，使每种类型的最相关结果都排在前面
+func (s *SearchService) Search(userID uint, q string, page, limit int) (*SearchResponse, error) {
+	keywords := strings.Fields(q)
+	resp := &SearchResponse{Items: []SearchResult{}, Counts: map[string]int{}}
+	if len(keywords) == 0 {
+		return resp, nil
+	}
+
+	sources := []struct {
+		searchType string
+		table      string
+		fields     []string
+		query      *gorm.DB
+		link       func(h searchHit) string
+	}{
+		{SearchTypeKnowledgePoint, "knowledge_points", []string{"title", "article_content"},
+			s.DB.Model(&model.KnowledgePoint{}).Select("id, title, article_content AS content"),
+			func(h searchHit) string { return "/knowledge-points/" + h.ID }},
+		{SearchTypeArticle, "resources", []string{"title", "description"},
+			s.DB.Model(&model.Resource{}).Select("id, title, description AS content, module_id").
+				Where("type = ? AND module_id IN (?)", model.Article,
+					s.DB.Model(&model.CProgrammingResource{}).Select("id").Where("enabled = ?", true)),
+			func(h searchHit) string {
+				return fmt.Sprintf("/c-programming/resources/%d/articles/%s", h.ModuleID, h.ID)
+			}},
+		{SearchTypeLevel, "levels", []string{"title", "description"},
+			s.DB.Model(&model.Level{}).Select("id, title, description AS content").Scopes(repository.LevelsVisibleToStudent(userID)),
+			func(h searchHit) string { return "/courses/" + h.ID }},
+		{SearchTypePost, "posts", []string{"title", "content"},
+			s.DB.Model(&model.Post{}).Select("id, title, content"),
+			func(h searchHit) string { return "/community/post/" + h.ID }},
+	}
+
+	perType := make([][]SearchResult, 0, len(sources))
+	for _, src := range sources {
+		var hits []searchHit
+		err := buildSearchQuery(src.query, src.table, src.fields, keywords).
+			Order(relevanceOrder(src.table, src.fields, keywords)).
+			Limit(searchPerTypeLimit).
+			Scan(&hits).Error
+		if err != nil {
+			return nil, err
+		}
+		results := make([]SearchResult, 0, len(hits))
+		for i, h := range hits {
+			results = append(results, SearchResult{
+				Type:    src.searchType,
+				ID:      h.ID,
+				Title:   h.Title,
+				Snippet: truncateUTF8(h.Content, searchSnippetBytes),
+				Link:    src.link(h),
+				Rank:    i + 1,
+			})
+		}
+		perType = append(perType, results)
+		resp.Counts[src.searchType] = len(results)
+		resp.Total += len(results)
+	}
+
+	// 按同类排名交替合并：先取各类第 1 名，再取各类第 2 名……
+	merged := make([]SearchResult, 0, resp.Total)
+	for rank := 0; rank < searchPerTypeLimit; rank++ {
+		for _, results := range perType {
+			if rank < len(results) {
+				merged = append(merged, results[rank])
+			}
+		}
+	}
+
+	start := (page - 1) * limit
+	if start < len(merged) {
+		end := start + limit
+		if end > len(merged) {
+			end = len(merged)
+		}
+		resp.Items = merged[start:end]
+	}
+	return resp, nil
+}
+
+// relevanceOrder 同类结果的排序：全文索引表按 MATCH 相关度，其余表标题命中的优先
+func relevanceOrder(table string, fields []string, keywords []string) clause.OrderBy {
+	expr := clause.Expr{SQL: "title LIKE ? DESC, id DESC", Vars: []interface{}{"%" + strings.Join(keywords, " ") + "%"}}
+	if fullTextTables[table] {
+		expr = clause.Expr{
+			SQL:  fmt.Sprintf("MATCH(%s) AGAINST(? IN NATURAL LANGUAGE MODE) DESC", strings.Join(fields, ",")),
+			Vars: []interface{}{strings.Join(keywords, " ")},
+		}
+	}
+	return clause.OrderBy{Expression: expr}
+}
@@ -3,15 +3,20 @@ package service
 import (
 	"coder_edu_backend/internal/config"
 	"coder_edu_backend/internal/util"
+	"coder_edu_backend/pkg/logger"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.uber.org/zap"
 )
 
 // StorageProvider 定义通用存储接口
@@ -19,9 +24,15 @@ type StorageProvider interface {
 	Upload(ctx context.Context, filename string, reader io.Reader, size int64, contentType string) (string, error)
 	UploadFile(ctx context.Context, filename string, localPath string, contentType string) (string, error)
 	Delete(ctx context.Context, filename string) error
+	Exists(ctx context.Context, filename string) (bool, error)
 	GetURL(filename string) string
 }
 
+// Presigner 支持生成限时访问地址的存储实现（本地存储不支持）
+type Presigner interface {
+	PresignedURL(ctx context.Context, filename string, expiry time.Duration) (string, error)
+}
+
 // LocalStorageProvider 本地存储实现
 type LocalStorageProvider struct {
 	Config *config.StorageConfig
@@ -90,6 +101,14 @@ func (p *LocalStorageProvider) Delete(ctx context.Context, filename string) erro
 	return os.Remove(dst)
 }
 
+func (p *LocalStorageProvider) Exists(ctx context.Context, filename string) (bool, error) {
+	_, err := os.Stat(filepath.Join(p.Config.LocalPath, filename))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (p *LocalStorageProvider) GetURL(filename string) string {
 	return "/uploads/" + filename
 }
@@ -135,10 +154,107 @@ func (p *MinioStorageProvider) Delete(ctx context.Context, filename string) erro
 	return p.Client.RemoveObject(ctx, p.Config.MinioBucket, filename, minio.RemoveObjectOptions{})
 }
 
+func (p *MinioStorageProvider) Exists(ctx context.Context, filename string) (bool, error) {
+	_, err := p.Client.StatObject(ctx, p.Config.MinioBucket, filename, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 func (p *MinioStorageProvider) GetURL(filename string) string {
 	return "/" + p.Config.MinioBucket + "/" + filename
 }
 
+func (p *MinioStorageProvider) PresignedURL(ctx context.Context, filename string, expiry time.Duration) (string, error) {
+	u, err := p.Client.PresignedGetObject(ctx, p.Config.MinioBucket, filename, expiry, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// NewMultipartUpload 初始化分片直传，返回 uploadID
+func (p *MinioStorageProvider) NewMultipartUpload(ctx context.Context, filename, contentType string) (string, error) {
+	core := minio.Core{Client: p.Client}
+	return core.NewMultipartUpload(ctx, p.Config.MinioBucket, filename, minio.PutObjectOptions{ContentType: contentType})
+}
+
+// Download 读取对象内容写入 w
+func (p *MinioStorageProvider) Download(ctx context.Context, filename string, w io.Writer) error {
+	obj, err := p.Client.GetObject(ctx, p.Config.MinioBucket, filename, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	_, err = io.Copy(w, obj)
+	return err
+}
+
+// PutPart 上传单个分片，md5Base64 非空时由 MinIO 校验内容完整性，返回分片 ETag
+func (p *MinioStorageProvider) PutPart(ctx context.Context, filename, uploadID string, partNumber int, reader io.Reader, size int64, md5Base64 string) (string, error) {
+	core := minio.Core{Client: p.Client}
+	part, err := core.PutObjectPart(ctx, p.Config.MinioBucket, filename, uploadID, partNumber, reader, size, minio.PutObjectPartOptions{
+		Md5Base64: md5Base64,
+	})
+	if err != nil {
+		return "", err
+	}
+	return part.ETag, nil
+}
+
+// CompleteMultipartUpload 按分片序号合并已上传的分片
+func (p *MinioStorageProvider) CompleteMultipartUpload(ctx context.Context, filename, uploadID string, etags map[int]string) (string, error) {
+	parts := make([]minio.CompletePart, 0, len(etags))
+	for n, etag := range etags {
+		parts = append(parts, minio.CompletePart{PartNumber: n, ETag: etag})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+	core := minio.Core{Client: p.Client}
+	if _, err := core.CompleteMultipartUpload(ctx, p.Config.MinioBucket, filename, uploadID, parts, minio.PutObjectOptions{}); err != nil {
+		return "", err
+	}
+	return p.GetURL(filename), nil
+}
+
+func (p *MinioStorageProvider) AbortMultipartUpload(ctx context.Context, filename, uploadID string) error {
+	core := minio.Core{Client: p.Client}
+	return core.AbortMultipartUpload(ctx, p.Config.MinioBucket, filename, uploadID)
+}
+
+// AbortStaleMultipartUploads 中止 prefix 下发起时间早于 before 且不在 keep 中的未完成分片上传，返回中止的数量。
+// 用于回收进度已过期或进程中途退出后遗留的 multipart upload，避免已上传的分片长期占用存储
+func (p *MinioStorageProvider) AbortStaleMultipartUploads(ctx context.Context, prefix string, before time.Time, keep map[string]bool) (int, error) {
+	aborted := 0
+	for upload := range p.Client.ListIncompleteUploads(ctx, p.Config.MinioBucket, prefix, true) {
+		if upload.Err != nil {
+			return aborted, upload.Err
+		}
+		if keep[upload.UploadID] || !upload.Initiated.Before(before) {
+			continue
+		}
+		if err := p.AbortMultipartUpload(ctx, upload.Key, upload.UploadID); err != nil {
+			logger.Log.Warn("中止遗留分片直传失败", zap.String("object", upload.Key), zap.Error(err))
+			continue
+		}
+		aborted++
+	}
+	return aborted, nil
+}
+
+// isDigestMismatch 判断分片上传失败是否由 Content-MD5 校验不一致导致
+func isDigestMismatch(err error) bool {
+	switch minio.ToErrorResponse(err).Code {
+	case "BadDigest", "InvalidDigest":
+		return true
+	}
+	return false
+}
+
 // OSSStorageProvider 阿里云OSS存储实现
 type OSSStorageProvider struct {
 	Config *config.StorageConfig
@@ -197,10 +313,26 @@ func (p *OSSStorageProvider) Delete(ctx context.Context, filename string) error
 	return bucket.DeleteObject(filename)
 }
 
+func (p *OSSStorageProvider) Exists(ctx context.Context, filename string) (bool, error) {
+	bucket, err := p.Client.Bucket(p.Config.OSSBucket)
+	if err != nil {
+		return false, err
+	}
+	return bucket.IsObjectExist(filename)
+}
+
 func (p *OSSStorageProvider) GetURL(filename string) string {
 	return fmt.Sprintf("https://%s.%s/%s", p.Config.OSSBucket, p.Config.OSSEndpoint, filename)
 }
 
+func (p *OSSStorageProvider) PresignedURL(ctx context.Context, filename string, expiry time.Duration) (string, error) {
+	bucket, err := p.Client.Bucket(p.Config.OSSBucket)
+	if err != nil {
+		return "", err
+	}
+	return bucket.SignURL(filename, oss.HTTPGet, int64(expiry.Seconds()))
+}
+
 // StorageService 存储服务
 type StorageService struct {
 	Provider StorageProvider
@@ -240,6 +372,27 @@ func (s *StorageService) Delete(ctx context.Context, filename string) error {
 	return s.Provider.Delete(ctx, filename)
 }
 
+func (s *StorageService) Exists(ctx context.Context, filename string) (bool, error) {
+	return s.Provider.Exists(ctx, filename)
+}
+
 func (s *StorageService) GetURL(filename string) string {
 	return s.Provider.GetURL(filename)
 }
+
+// ObjectKey 从 GetURL 生成的访问地址反解出对象路径，非本存储的地址返回 false
+func (s *StorageService) ObjectKey(fileURL string) (string, bool) {
+	prefix := s.Provider.GetURL("")
+	if !strings.HasPrefix(fileURL, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(fileURL, prefix), true
+}
+
+// PresignedURL 生成限时访问地址；存储不支持签名（本地存储）时返回普通地址
+func (s *StorageService) PresignedURL(ctx context.Context, filename string, expiry time.Duration) (string, error) {
+	if p, ok := s.Provider.(Presigner); ok {
+		return p.PresignedURL(ctx, filename, expiry)
+	}
+	return s.Provider.GetURL(filename), nil
+}
@@ -171,7 +171,7 @@ func (s *SuggestionService) ListStudentsProgress(page, pageSize int, search stri
 		return nil, 0, err
 	}
 
-	var results []StudentProgressListItem
+	results := make([]StudentProgressListItem, 0, len(students))
 	for _, student := range students {
 		var stats struct {
 			LevelsCompleted int     `gorm:"column:comp_count"`
@@ -191,7 +191,7 @@ func (s *SuggestionService) ListStudentsProgress(page, pageSize int, search stri
 			TotalXP:         student.XP,
 			LevelsCompleted: stats.LevelsCompleted,
 			AverageScore:    stats.AverageScore,
-			LastSeen:        student.LastSeen.Format("2006-01-02 15:04:05"),
+			LastSeen:        student.LastSeen.Format(model.TimeFormat),
 		})
 	}
 
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/testutil"
+	"encoding/json"
+	"testing"
+)
+
+// TestListStudentsProgressEmpty 需要 testutil.MySQL 的测试库，未设置 TEST_MYSQL_DSN 时跳过
+func TestListStudentsProgressEmpty(t *testing.T) {
+	db := testutil.MySQL(t)
+	s := &SuggestionService{LevelAttemptRepo: repository.NewLevelAttemptRepository(db)}
+
+	items, total, err := s.ListStudentsProgress(1, 20, "no-such-student-"+model.GenerateUUID())
+	if err != nil {
+		t.Fatalf("list students progress: %v", err)
+	}
+	if total != 0 {
+		t.Fatalf("total = %d, want 0", total)
+	}
+	body, _ := json.Marshal(items)
+	if string(body) != "[]" {
+		t.Fatalf("items = %s, want []", body)
+	}
+}
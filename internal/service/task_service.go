@@ -4,10 +4,13 @@ import (
 	"coder_edu_backend/internal/model"
 	"coder_edu_backend/internal/repository"
 	"coder_edu_backend/internal/util"
+	"coder_edu_backend/pkg/logger"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -18,6 +21,9 @@ type TaskService struct {
 	ExerciseRepo       *repository.ExerciseQuestionRepository
 	ResourceModuleRepo *repository.CProgrammingResourceRepository
 	GoalRepo           *repository.GoalRepository
+	DB                 *gorm.DB
+	// WeeklyCompletionPoints 完成某周全部任务时发放的积分，0 表示不发放
+	WeeklyCompletionPoints int
 }
 
 func NewTaskService(
@@ -26,35 +32,49 @@ func NewTaskService(
 	exerciseRepo *repository.ExerciseQuestionRepository,
 	resourceModuleRepo *repository.CProgrammingResourceRepository,
 	goalRepo *repository.GoalRepository,
+	db *gorm.DB,
+	weeklyCompletionPoints int,
 ) *TaskService {
 	return &TaskService{
-		TaskRepo:           taskRepo,
-		ResourceRepo:       resourceRepo,
-		ExerciseRepo:       exerciseRepo,
-		ResourceModuleRepo: resourceModuleRepo,
-		GoalRepo:           goalRepo,
+		TaskRepo:               taskRepo,
+		ResourceRepo:           resourceRepo,
+		ExerciseRepo:           exerciseRepo,
+		ResourceModuleRepo:     resourceModuleRepo,
+		GoalRepo:               goalRepo,
+		DB:                     db,
+		WeeklyCompletionPoints: weeklyCompletionPoints,
 	}
 }
 
+// weekRange 返回 date 所在周的周一和周日（零点）
+func weekRange(date time.Time) (time.Time, time.Time) {
+	weekday := int(date.Weekday())
+	if weekday == 0 { // Sunday
+		weekday = 7
+	}
+	weekStart := time.Date(date.Year(), date.Month(), date.Day()-weekday+1, 0, 0, 0, 0, date.Location())
+	return weekStart, weekStart.AddDate(0, 0, 6)
+}
+
 // SetWeeklyTask 设置周任务
 func (s *TaskService) SetWeeklyTask(teacherID, resourceModuleID uint, taskItems []model.TaskItem) (*model.TeacherWeeklyTask, error) {
+	return s.setWeeklyTask(teacherID, resourceModuleID, time.Now(), taskItems, nil)
+}
+
+// setWeeklyTask 设置 date 所在周的周任务，已有任务时替换其任务项。
+// template 不为空时新建的任务记为该模板生成的草稿，老师发布前学生不可见
+func (s *TaskService) setWeeklyTask(teacherID, resourceModuleID uint, date time.Time, taskItems []model.TaskItem, template *model.WeeklyTaskTemplate) (*model.TeacherWeeklyTask, error) {
 	// 获取资源模块信息
 	resourceModule, err := s.ResourceModuleRepo.FindByID(resourceModuleID)
 	if err != nil {
 		return nil, fmt.Errorf("资源模块不存在 (ID: %d)", resourceModuleID)
 	}
 
-	// 计算本周的开始和结束日期
-	today := time.Now()
-	weekday := int(today.Weekday())
-	if weekday == 0 { // Sunday
-		weekday = 7
-	}
-	weekStart := time.Date(today.Year(), today.Month(), today.Day()-weekday+1, 0, 0, 0, 0, today.Location())
-	weekEnd := weekStart.AddDate(0, 0, 6)
+	// 计算目标周的开始和结束日期
+	weekStart, weekEnd := weekRange(date)
 
-	// 检查是否已有本周任务
-	existingTask, err := s.TaskRepo.GetWeeklyTaskByTeacherAndDate(teacherID, resourceModuleID, today)
+	// 检查是否已有该周任务
+	existingTask, err := s.TaskRepo.GetWeeklyTaskByTeacherAndDate(teacherID, resourceModuleID, date)
 	var weeklyTask *model.TeacherWeeklyTask
 
 	if err == nil {
@@ -67,13 +87,37 @@ func (s *TaskService) SetWeeklyTask(teacherID, resourceModuleID uint, taskItems
 			TeacherID:          teacherID,
 			ResourceModuleID:   resourceModuleID,
 			ResourceModuleName: resourceModule.Name,
-			WeekStartDate:      weekStart,
-			WeekEndDate:        weekEnd,
+			WeekStartDate:      model.NewJSONTime(weekStart),
+			WeekEndDate:        model.NewJSONTime(weekEnd),
 			TaskItems:          taskItems,
 		}
+		if template != nil {
+			weeklyTask.TemplateID = template.ID
+			weeklyTask.IsDraft = true
+		}
 	}
 
 	// 验证并完善任务项信息
+	if err := s.fillTaskItems(taskItems, weeklyTask.ID); err != nil {
+		return nil, err
+	}
+
+	// 保存周任务
+	if err == nil {
+		if err := s.TaskRepo.UpdateWeeklyTask(weeklyTask); err != nil {
+			return nil, fmt.Errorf("更新周任务失败: %v", err)
+		}
+	} else {
+		if err := s.TaskRepo.CreateWeeklyTask(weeklyTask); err != nil {
+			return nil, fmt.Errorf("创建周任务失败: %v", err)
+		}
+	}
+
+	return weeklyTask, nil
+}
+
+// fillTaskItems 校验任务项不重复，并根据资源或练习题补全标题、描述等信息
+func (s *TaskService) fillTaskItems(taskItems []model.TaskItem, weeklyTaskID uint) error {
 	seen := make(map[string]bool)
 	for i := range taskItems {
 		item := &taskItems[i]
@@ -81,18 +125,18 @@ func (s *TaskService) SetWeeklyTask(teacherID, resourceModuleID uint, taskItems
 		// 检查同一天是否添加了相同的资源
 		key := fmt.Sprintf("%s-%s-%d-%d", item.DayOfWeek, item.ItemType, item.ResourceID, item.ExerciseID)
 		if seen[key] {
-			return nil, fmt.Errorf("同一天不能添加重复的任务项")
+			return fmt.Errorf("同一天不能添加重复的任务项")
 		}
 		seen[key] = true
 
-		item.WeeklyTaskID = weeklyTask.ID
+		item.WeeklyTaskID = weeklyTaskID
 		item.ID = 0
 
 		// 根据类型获取资源信息
 		if item.ItemType == model.TaskItemVideo || item.ItemType == model.TaskItemArticle {
 			resource, err := s.ResourceRepo.FindByID(item.ResourceID)
 			if err != nil {
-				return nil, fmt.Errorf("资源不存在 (ID: %d)", item.ResourceID)
+				return fmt.Errorf("资源不存在 (ID: %d)", item.ResourceID)
 			}
 			item.Title = resource.Title
 			item.Description = resource.Description
@@ -100,26 +144,14 @@ func (s *TaskService) SetWeeklyTask(teacherID, resourceModuleID uint, taskItems
 		} else if item.ItemType == model.TaskItemExercise {
 			exercise, err := s.ExerciseRepo.FindByID(item.ExerciseID)
 			if err != nil {
-				return nil, fmt.Errorf("练习题不存在 (ID: %d)", item.ExerciseID)
+				return fmt.Errorf("练习题不存在 (ID: %d)", item.ExerciseID)
 			}
 			item.Title = exercise.Title
 			item.Description = exercise.Description
 			item.ContentType = "exercise"
 		}
 	}
-
-	// 保存周任务
-	if err == nil {
-		if err := s.TaskRepo.UpdateWeeklyTask(weeklyTask); err != nil {
-			return nil, fmt.Errorf("更新周任务失败: %v", err)
-		}
-	} else {
-		if err := s.TaskRepo.CreateWeeklyTask(weeklyTask); err != nil {
-			return nil, fmt.Errorf("创建周任务失败: %v", err)
-		}
-	}
-
-	return weeklyTask, nil
+	return nil
 }
 
 // GetTodayTasks 获取今天的任务列表
@@ -219,7 +251,8 @@ func (s *TaskService) buildTaskResult(taskItems []model.TaskItem, userID uint) [
 // UpdateTaskCompletion 更新任务完成状态
 func (s *TaskService) UpdateTaskCompletion(userID, taskItemID uint, isCompleted bool, progress float64, resourceCompleted bool) error {
 	// 获取任务项信息
-	if _, err := s.TaskRepo.FindTaskItemByID(taskItemID); err != nil {
+	taskItem, err := s.TaskRepo.FindTaskItemByID(taskItemID)
+	if err != nil {
 		return errors.New("任务项不存在")
 	}
 
@@ -230,7 +263,7 @@ func (s *TaskService) UpdateTaskCompletion(userID, taskItemID uint, isCompleted
 		completion = &model.DailyTaskCompletion{
 			UserID:         userID,
 			TaskItemID:     taskItemID,
-			CompletionDate: time.Now(),
+			CompletionDate: model.NewJSONTime(time.Now()),
 		}
 	}
 
@@ -243,9 +276,43 @@ func (s *TaskService) UpdateTaskCompletion(userID, taskItemID uint, isCompleted
 		return err
 	}
 
+	if isCompleted {
+		// 奖励发放失败不影响完成状态的保存
+		if err := s.awardWeekCompletion(userID, taskItem.WeeklyTaskID); err != nil {
+			logger.Log.Warn("发放周任务完成奖励失败",
+				zap.Uint("userID", userID),
+				zap.Uint("taskItemID", taskItemID),
+				zap.Error(err))
+		}
+	}
+
 	return nil
 }
 
+// awardWeekCompletion 学生完成任务项所在周的全部任务时发放积分，同一周只发放一次
+func (s *TaskService) awardWeekCompletion(userID, weeklyTaskID uint) error {
+	if s.WeeklyCompletionPoints <= 0 || s.DB == nil {
+		return nil
+	}
+	weeklyTask, err := s.TaskRepo.FindWeeklyTaskByID(weeklyTaskID)
+	if err != nil {
+		return err
+	}
+	week, err := s.weekSummaries(userID, weeklyTask.WeekStartDate.Time, 1)
+	if err != nil {
+		return err
+	}
+	if len(week) == 0 || !week[0].FullyCompleted {
+		return nil
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		sourceID := fmt.Sprintf("%d-%s", userID, week[0].WeekStartDate)
+		_, err := awardPoints(tx, userID, s.WeeklyCompletionPoints, model.PointSourceWeeklyTasks, sourceID,
+			fmt.Sprintf("完成 %s 当周全部任务", week[0].WeekStartDate))
+		return err
+	})
+}
+
 // GetWeeklyTasks 获取老师的周任务列表
 func (s *TaskService) GetWeeklyTasks(teacherID uint, page, limit int, search string) ([]model.TeacherWeeklyTask, int, error) {
 	if page < 1 {
@@ -343,3 +410,200 @@ func (s *TaskService) GetCurrentWeekTask(userID uint, role model.UserRole, resou
 func (s *TaskService) DeleteWeeklyTask(taskID uint, teacherID uint) error {
 	return s.TaskRepo.DeleteWeeklyTask(taskID, teacherID)
 }
+
+// UpdateWeeklyTaskItems 修改老师自己的周任务内容，常用于在发布前调整模板生成的草稿
+func (s *TaskService) UpdateWeeklyTaskItems(taskID, teacherID uint, taskItems []model.TaskItem) (*model.TeacherWeeklyTask, error) {
+	task, err := s.TaskRepo.FindOwnedWeeklyTask(taskID, teacherID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fillTaskItems(taskItems, task.ID); err != nil {
+		return nil, err
+	}
+	task.TaskItems = taskItems
+	if err := s.TaskRepo.UpdateWeeklyTask(task); err != nil {
+		return nil, fmt.Errorf("更新周任务失败: %v", err)
+	}
+	return task, nil
+}
+
+// PublishWeeklyTask 发布周任务草稿，发布后学生可见
+func (s *TaskService) PublishWeeklyTask(taskID, teacherID uint) error {
+	return s.TaskRepo.PublishWeeklyTask(taskID, teacherID)
+}
+
+// SaveWeeklyTaskTemplate 保存老师在某个资源分类下的周任务模板，同一分类只保留一个模板
+func (s *TaskService) SaveWeeklyTaskTemplate(teacherID, resourceModuleID uint, items []model.WeeklyTaskTemplateItem, autoInstantiate bool) (*model.WeeklyTaskTemplate, error) {
+	if _, err := s.ResourceModuleRepo.FindByID(resourceModuleID); err != nil {
+		return nil, fmt.Errorf("资源模块不存在 (ID: %d)", resourceModuleID)
+	}
+	// 按生成任务时的规则预先校验，避免保存后每周都生成失败
+	if err := s.fillTaskItems(templateTaskItems(items), 0); err != nil {
+		return nil, err
+	}
+
+	template, err := s.TaskRepo.FindWeeklyTaskTemplate(teacherID, resourceModuleID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		template = &model.WeeklyTaskTemplate{TeacherID: teacherID, ResourceModuleID: resourceModuleID}
+	}
+	template.Items = items
+	template.AutoInstantiate = autoInstantiate
+	if err := s.TaskRepo.SaveWeeklyTaskTemplate(template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// ListWeeklyTaskTemplates 获取老师的周任务模板
+func (s *TaskService) ListWeeklyTaskTemplates(teacherID uint) ([]model.WeeklyTaskTemplate, error) {
+	return s.TaskRepo.ListWeeklyTaskTemplates(teacherID)
+}
+
+// DeleteWeeklyTaskTemplate 删除周任务模板
+func (s *TaskService) DeleteWeeklyTaskTemplate(id, teacherID uint) error {
+	return s.TaskRepo.DeleteWeeklyTaskTemplate(id, teacherID)
+}
+
+// InstantiateWeeklyTemplates 为开启自动生成的模板生成下一周的周任务草稿，返回生成数量。
+// 每个模板每周只生成一次；老师已手动设置了下周任务的分类不会被覆盖
+func (s *TaskService) InstantiateWeeklyTemplates(now time.Time) (int, error) {
+	thisWeekStart, _ := weekRange(now)
+	nextWeekStart := thisWeekStart.AddDate(0, 0, 7)
+
+	templates, err := s.TaskRepo.FindTemplatesDueForWeek(nextWeekStart.Format(util.DateFormat))
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for i := range templates {
+		template := &templates[i]
+		claimed, err := s.TaskRepo.ClaimTemplateWeek(template.ID, nextWeekStart)
+		if err != nil {
+			return created, err
+		}
+		if !claimed {
+			continue
+		}
+
+		exists, err := s.TaskRepo.ExistsWeeklyTask(template.TeacherID, template.ResourceModuleID, nextWeekStart.Format(util.DateFormat))
+		if err != nil {
+			return created, err
+		}
+		if exists {
+			continue
+		}
+
+		if _, err := s.setWeeklyTask(template.TeacherID, template.ResourceModuleID, nextWeekStart, templateTaskItems(template.Items), template); err != nil {
+			logger.Log.Warn("按模板生成周任务失败",
+				zap.Uint("templateID", template.ID),
+				zap.Uint("teacherID", template.TeacherID),
+				zap.Error(err))
+			continue
+		}
+		created++
+	}
+	return created, nil
+}
+
+func templateTaskItems(items []model.WeeklyTaskTemplateItem) []model.TaskItem {
+	taskItems := make([]model.TaskItem, 0, len(items))
+	for _, item := range items {
+		taskItems = append(taskItems, model.TaskItem{
+			DayOfWeek:  item.DayOfWeek,
+			ItemType:   item.ItemType,
+			ResourceID: item.ResourceID,
+			ExerciseID: item.ExerciseID,
+		})
+	}
+	return taskItems
+}
+
+// WeeklyTaskSummary 学生某一周的周任务完成情况
+type WeeklyTaskSummary struct {
+	WeekStartDate  string  `json:"weekStartDate"`
+	WeekEndDate    string  `json:"weekEndDate"`
+	TotalItems     int     `json:"totalItems"`
+	CompletedItems int     `json:"completedItems"`
+	CompletionRate float64 `json:"completionRate"` // 0-100
+	FullyCompleted bool    `json:"fullyCompleted"`
+}
+
+// TaskCompletionSummary 学生周任务完成汇总
+type TaskCompletionSummary struct {
+	Weeks  []WeeklyTaskSummary `json:"weeks"`  // 从本周开始倒序
+	Streak int                 `json:"streak"` // 连续完成全部任务的周数
+}
+
+// streakLookbackWeeks 计算连续完成周数时最多回溯的周数
+const streakLookbackWeeks = 52
+
+// GetWeeklySummary 返回学生最近 weeks 周的周任务完成率，以及连续完成全部任务的周数。
+// 没有布置任务的周不计入也不中断连续周数；本周尚未全部完成时从上周开始计算
+func (s *TaskService) GetWeeklySummary(userID uint, weeks int) (*TaskCompletionSummary, error) {
+	if weeks < 1 || weeks > streakLookbackWeeks {
+		weeks = 4
+	}
+	summaries, err := s.weekSummaries(userID, time.Now(), streakLookbackWeeks)
+	if err != nil {
+		return nil, err
+	}
+
+	streak := 0
+	for i, week := range summaries {
+		if week.TotalItems == 0 || (i == 0 && !week.FullyCompleted) {
+			continue
+		}
+		if !week.FullyCompleted {
+			break
+		}
+		streak++
+	}
+
+	return &TaskCompletionSummary{Weeks: summaries[:weeks], Streak: streak}, nil
+}
+
+// weekSummaries 返回 date 所在周及之前共 count 周的完成情况，按周倒序
+func (s *TaskService) weekSummaries(userID uint, date time.Time, count int) ([]WeeklyTaskSummary, error) {
+	latest, _ := weekRange(date)
+	earliest := latest.AddDate(0, 0, -7*(count-1))
+	from, to := earliest.Format(util.DateFormat), latest.Format(util.DateFormat)
+
+	totals, err := s.TaskRepo.CountPublishedItemsByWeek(from, to)
+	if err != nil {
+		return nil, err
+	}
+	completed, err := s.TaskRepo.CountCompletedItemsByWeek(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	totalMap := make(map[string]int, len(totals))
+	for _, c := range totals {
+		totalMap[c.WeekStart] = c.Count
+	}
+	completedMap := make(map[string]int, len(completed))
+	for _, c := range completed {
+		completedMap[c.WeekStart] = c.Count
+	}
+
+	summaries := make([]WeeklyTaskSummary, 0, count)
+	for i := 0; i < count; i++ {
+		weekStart := latest.AddDate(0, 0, -7*i)
+		key := weekStart.Format(util.DateFormat)
+		summary := WeeklyTaskSummary{
+			WeekStartDate:  key,
+			WeekEndDate:    weekStart.AddDate(0, 0, 6).Format(util.DateFormat),
+			TotalItems:     totalMap[key],
+			CompletedItems: completedMap[key],
+		}
+		if summary.TotalItems > 0 {
+			summary.CompletionRate = math.Round(float64(summary.CompletedItems)*10000/float64(summary.TotalItems)) / 100
+			summary.FullyCompleted = summary.CompletedItems >= summary.TotalItems
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
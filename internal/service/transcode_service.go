@@ -0,0 +1,196 @@
+package service
+
+import (
+	"coder_edu_backend/internal/config"
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/util"
+	"coder_edu_backend/pkg/logger"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// transcodeProfiles 需要生成的分辨率版本
+var transcodeProfiles = []struct {
+	Name   string
+	Height int
+}{
+	{"480p", 480},
+	{"720p", 720},
+}
+
+const (
+	transcodeMaxAttempts  = 3
+	transcodePollInterval = 30 * time.Second
+	transcodeBatchSize    = 5
+)
+
+// TranscodeService 视频多分辨率转码，任务落库后由单个后台 worker 串行处理，FFmpeg 进程与上传处理共用并发上限
+type TranscodeService struct {
+	JobRepo        *repository.TranscodeJobRepository
+	ResourceRepo   *repository.ResourceRepository
+	StorageService *StorageService
+	Cfg            *config.Config
+	FFmpeg         *FFmpegLimiter
+	notify         chan struct{}
+}
+
+func NewTranscodeService(jobRepo *repository.TranscodeJobRepository, resourceRepo *repository.ResourceRepository, storageService *StorageService, cfg *config.Config, ffmpegLimiter *FFmpegLimiter) *TranscodeService {
+	return &TranscodeService{
+		JobRepo:        jobRepo,
+		ResourceRepo:   resourceRepo,
+		StorageService: storageService,
+		Cfg:            cfg,
+		FFmpeg:         ffmpegLimiter,
+		notify:         make(chan struct{}, 1),
+	}
+}
+
+// Enqueue 为已创建的视频资源登记转码任务。
+// 源文件会被移动到转码目录，调用方原有的临时文件清理逻辑不会再影响它。
+func (s *TranscodeService) Enqueue(resourceID uint, localPath string) error {
+	dir := filepath.Join(s.Cfg.Storage.LocalPath, "temp", "transcode")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	sourcePath := filepath.Join(dir, fmt.Sprintf("%d%s", resourceID, filepath.Ext(localPath)))
+	if err := os.Rename(localPath, sourcePath); err != nil {
+		return err
+	}
+
+	job := &model.TranscodeJob{
+		ResourceID: resourceID,
+		SourcePath: sourcePath,
+		Status:     model.ResourcePending,
+	}
+	if err := s.JobRepo.Create(job); err != nil {
+		os.Remove(sourcePath)
+		return err
+	}
+
+	// 非阻塞唤醒 worker
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run 启动转码 worker，直到 stopCh 关闭
+func (s *TranscodeService) Run(stopCh <-chan struct{}) {
+	if err := s.JobRepo.ResetProcessing(); err != nil {
+		logger.Log.Error("恢复转码任务状态失败", zap.Error(err))
+	}
+
+	ticker := time.NewTicker(transcodePollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.processPending(stopCh)
+		select {
+		case <-ticker.C:
+		case <-s.notify:
+		case <-stopCh:
+			logger.Log.Info("Transcode worker stopped")
+			return
+		}
+	}
+}
+
+func (s *TranscodeService) processPending(stopCh <-chan struct{}) {
+	jobs, err := s.JobRepo.FindPending(transcodeBatchSize)
+	if err != nil {
+		logger.Log.Error("查询待转码任务失败", zap.Error(err))
+		return
+	}
+	for i := range jobs {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		claimed, err := s.JobRepo.Claim(jobs[i].ID)
+		if err != nil || !claimed {
+			continue
+		}
+		jobs[i].Attempts++
+		s.processJob(&jobs[i])
+	}
+}
+
+func (s *TranscodeService) processJob(job *model.TranscodeJob) {
+	variants, err := s.transcode(job)
+	if err != nil {
+		logger.Log.Error("视频转码失败",
+			zap.Uint("jobID", job.ID),
+			zap.Uint("resourceID", job.ResourceID),
+			zap.Int("attempts", job.Attempts),
+			zap.Error(err))
+
+		job.LastError = err.Error()
+		if job.Attempts >= transcodeMaxAttempts {
+			// 放弃重试，原视频仍可正常播放
+			job.Status = model.ResourceFailed
+			os.Remove(job.SourcePath)
+		} else {
+			job.Status = model.ResourcePending
+		}
+		if err := s.JobRepo.Update(job); err != nil {
+			logger.Log.Error("更新转码任务失败", zap.Uint("jobID", job.ID), zap.Error(err))
+		}
+		return
+	}
+
+	data, _ := json.Marshal(variants)
+	if err := s.ResourceRepo.UpdateFields(job.ResourceID, model.Video, map[string]interface{}{
+		"variants": data,
+	}); err != nil {
+		logger.Log.Error("写入转码结果失败", zap.Uint("resourceID", job.ResourceID), zap.Error(err))
+	}
+
+	job.Status = model.ResourceSuccess
+	job.LastError = ""
+	if err := s.JobRepo.Update(job); err != nil {
+		logger.Log.Error("更新转码任务失败", zap.Uint("jobID", job.ID), zap.Error(err))
+	}
+	os.Remove(job.SourcePath)
+
+	logger.Log.Info("视频转码完成", zap.Uint("resourceID", job.ResourceID), zap.Int("variants", len(variants)))
+}
+
+// transcode 依次生成各分辨率版本并上传，返回 分辨率 -> URL
+func (s *TranscodeService) transcode(job *model.TranscodeJob) (map[string]string, error) {
+	if _, err := os.Stat(job.SourcePath); err != nil {
+		return nil, fmt.Errorf("源文件不存在: %v", err)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(job.SourcePath), filepath.Ext(job.SourcePath))
+	variants := make(map[string]string, len(transcodeProfiles))
+	for _, p := range transcodeProfiles {
+		outPath := filepath.Join(filepath.Dir(job.SourcePath), fmt.Sprintf("%s_%s.mp4", base, p.Name))
+		err := s.FFmpeg.Do(context.Background(), func() error {
+			return util.TranscodeVideo(job.SourcePath, outPath, p.Height)
+		})
+		if err != nil {
+			os.Remove(outPath)
+			return nil, fmt.Errorf("转码 %s 失败: %v", p.Name, err)
+		}
+
+		objectName := fmt.Sprintf("videos/%s_%s_%s.mp4", base, util.GenerateRandomString(8), p.Name)
+		url, err := s.StorageService.UploadFile(context.Background(), objectName, outPath, "video/mp4")
+		os.Remove(outPath)
+		if err != nil {
+			return nil, fmt.Errorf("上传 %s 失败: %v", p.Name, err)
+		}
+		variants[p.Name] = url
+	}
+	return variants, nil
+}
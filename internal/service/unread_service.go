@@ -0,0 +1,133 @@
+package service
+
+import (
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/pkg/logger"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// UnreadSummary 全局未读角标：聊天未读消息、未读 @ 提及和未读通知
+type UnreadSummary struct {
+	Total         int64                           `json:"total"`    // 角标总数 = Messages + Notifications，@ 提及本身也是未读消息，不重复计入
+	Messages      int64                           `json:"messages"` // 所有会话已读位置之后的未读消息数
+	Mentions      int64                           `json:"mentions"`
+	Notifications int64                           `json:"notifications"`
+	Conversations []repository.ConversationUnread `json:"conversations"` // 有未读消息的会话及各自未读数
+}
+
+// UnreadService 汇总未读数，并在未读数变化时通过 ChatHub 推送 UNREAD_SUMMARY。
+// 发消息、标记已读、新通知等操作只记录受影响的用户，由后台任务定期合并推送，
+// 避免大群中每条消息都为全部成员重新统计
+type UnreadService struct {
+	ChatRepo         *repository.ChatRepository
+	NotificationRepo *repository.NotificationRepository
+	Hub              *ChatHub
+
+	mu      sync.Mutex
+	changed map[uint]struct{}
+}
+
+func NewUnreadService(chatRepo *repository.ChatRepository, notificationRepo *repository.NotificationRepository, hub *ChatHub) *UnreadService {
+	s := &UnreadService{
+		ChatRepo:         chatRepo,
+		NotificationRepo: notificationRepo,
+		Hub:              hub,
+		changed:          make(map[uint]struct{}),
+	}
+	chatRepo.OnMessagesPersisted(s.markRecipients)
+	return s
+}
+
+// markRecipients 消息写入数据库后标记会话中除发送者外的成员。
+// 消息先进入 Redis Stream 再异步落库，未读数按数据库统计，在落库后才会变化
+func (s *UnreadService) markRecipients(messages []*model.Message) {
+	members := make(map[string][]uint)
+	for _, m := range messages {
+		if m.Type == "system" {
+			continue
+		}
+		ids, ok := members[m.ConversationID]
+		if !ok {
+			var err error
+			if ids, err = s.ChatRepo.GetGroupMemberIDsCached(m.ConversationID); err != nil {
+				logger.Log.Warn("查询会话成员失败", zap.String("conversationID", m.ConversationID), zap.Error(err))
+			}
+			members[m.ConversationID] = ids
+		}
+		for _, uid := range ids {
+			if m.SenderID == nil || uid != *m.SenderID {
+				s.MarkChanged(uid)
+			}
+		}
+	}
+}
+
+// GetUnreadSummary 获取用户的全局未读数
+func (s *UnreadService) GetUnreadSummary(userID uint) (*UnreadSummary, error) {
+	conversations, err := s.ChatRepo.CountUnreadByConversation(userID)
+	if err != nil {
+		return nil, err
+	}
+	mentions, err := s.ChatRepo.CountUnreadMentions(userID)
+	if err != nil {
+		return nil, err
+	}
+	notifications, err := s.NotificationRepo.CountUnread(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &UnreadSummary{
+		Mentions:      mentions,
+		Notifications: notifications,
+		Conversations: conversations,
+	}
+	for _, c := range conversations {
+		summary.Messages += c.Count
+	}
+	summary.Total = summary.Messages + summary.Notifications
+	return summary, nil
+}
+
+// MarkChanged 记录未读数可能发生变化的用户，下次 FlushChanged 时推送
+func (s *UnreadService) MarkChanged(userIDs ...uint) {
+	if s == nil || len(userIDs) == 0 {
+		return
+	}
+	s.mu.Lock()
+	for _, uid := range userIDs {
+		s.changed[uid] = struct{}{}
+	}
+	s.mu.Unlock()
+}
+
+// FlushChanged 为未读数发生变化的在线用户重新统计并推送 UNREAD_SUMMARY，离线用户上线后通过接口获取
+func (s *UnreadService) FlushChanged() {
+	s.mu.Lock()
+	if len(s.changed) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	userIDs := make([]uint, 0, len(s.changed))
+	for uid := range s.changed {
+		userIDs = append(userIDs, uid)
+	}
+	s.changed = make(map[uint]struct{})
+	s.mu.Unlock()
+
+	online := s.Hub.OnlineStatus(userIDs)
+	for _, uid := range userIDs {
+		if !online[uid] {
+			continue
+		}
+		summary, err := s.GetUnreadSummary(uid)
+		if err != nil {
+			logger.Log.Warn("统计未读数失败", zap.Uint("userID", uid), zap.Error(err))
+			continue
+		}
+		s.Hub.PushToUsers([]uint{uid}, WSMessage{Type: "UNREAD_SUMMARY", Data: summary})
+	}
+}
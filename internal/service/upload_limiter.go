@@ -0,0 +1,105 @@
+package service
+
+import (
+	"coder_edu_backend/internal/util"
+	"coder_edu_backend/pkg/logger"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	uploadActiveKeyFmt = "upload:active:%d"
+	uploadOwnerKeyFmt  = "upload:owner:%s" // uploadID -> 发起上传的用户，管理员清理上传时据此释放名额
+)
+
+// acquireUploadScript 清理过期记录后登记上传：uploadID 归属其他用户时返回 -1；
+// 已登记的上传只刷新活动时间，新上传超过上限时返回 0。归属检查与写入在脚本内原子完成，uploadID 由首个上传者占有
+var acquireUploadScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[2])
+if owner and owner ~= ARGV[6] then
+	return -1
+end
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[2])
+if not redis.call('ZSCORE', KEYS[1], ARGV[4]) then
+	local limit = tonumber(ARGV[3])
+	if limit > 0 and redis.call('ZCARD', KEYS[1]) >= limit then
+		return 0
+	end
+end
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[4])
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+redis.call('SET', KEYS[2], ARGV[6], 'EX', ARGV[5])
+return 1
+`)
+
+// UploadLimiter 在 Redis 中按用户记录进行中的上传（有序集合，分值为最近活动时间），
+// 限制单个用户同时进行的上传数量，避免一个用户占满临时目录。
+// 超过 staleAfter 未活动的记录视为已放弃，在下次登记时自动清理
+type UploadLimiter struct {
+	Redis      *redis.Client
+	MaxPerUser int // 为 0 时只记录不限制
+	StaleAfter time.Duration
+}
+
+func NewUploadLimiter(rdb *redis.Client, maxPerUser int, staleAfter time.Duration) *UploadLimiter {
+	if staleAfter <= 0 {
+		staleAfter = time.Hour
+	}
+	return &UploadLimiter{Redis: rdb, MaxPerUser: maxPerUser, StaleAfter: staleAfter}
+}
+
+// Acquire 登记一次上传，uploadID 相同的重复登记（如同一分片上传的后续分片）只刷新活动时间。
+// uploadID 已被其他用户占用时返回 util.ErrUploadNotOwned，超过上限时返回 util.ErrTooManyUploads；
+// Redis 不可用时放行，不影响正常上传
+func (l *UploadLimiter) Acquire(ctx context.Context, userID uint, uploadID string) error {
+	now := time.Now()
+	ok, err := acquireUploadScript.Run(ctx, l.Redis, []string{fmt.Sprintf(uploadActiveKeyFmt, userID), fmt.Sprintf(uploadOwnerKeyFmt, uploadID)},
+		now.UnixMilli(),
+		now.Add(-l.StaleAfter).UnixMilli(),
+		l.MaxPerUser,
+		uploadID,
+		int(l.StaleAfter.Seconds()),
+		userID,
+	).Int()
+	if err != nil {
+		logger.Log.Warn("登记上传失败，跳过并发限制", zap.Uint("userID", userID), zap.Error(err))
+		return nil
+	}
+	switch ok {
+	case -1:
+		return util.ErrUploadNotOwned
+	case 0:
+		return util.ErrTooManyUploads
+	}
+	return nil
+}
+
+// Release 上传完成或放弃后移除登记
+func (l *UploadLimiter) Release(ctx context.Context, userID uint, uploadID string) {
+	if err := l.Redis.ZRem(ctx, fmt.Sprintf(uploadActiveKeyFmt, userID), uploadID).Err(); err != nil {
+		logger.Log.Warn("移除上传登记失败", zap.Uint("userID", userID), zap.String("uploadID", uploadID), zap.Error(err))
+	}
+	l.Redis.Del(ctx, fmt.Sprintf(uploadOwnerKeyFmt, uploadID))
+}
+
+// ReleaseUpload 按 uploadID 移除登记，用于管理员清理他人发起的上传；登记不存在时忽略
+func (l *UploadLimiter) ReleaseUpload(ctx context.Context, uploadID string) {
+	userID, err := l.Redis.Get(ctx, fmt.Sprintf(uploadOwnerKeyFmt, uploadID)).Uint64()
+	if err != nil {
+		if err != redis.Nil {
+			logger.Log.Warn("查询上传登记失败", zap.String("uploadID", uploadID), zap.Error(err))
+		}
+		return
+	}
+	l.Release(ctx, uint(userID), uploadID)
+}
+
+// Owns 判断上传是否由该用户发起且尚未结束，以 uploadID 的归属记录为准
+func (l *UploadLimiter) Owns(ctx context.Context, userID uint, uploadID string) bool {
+	owner, err := l.Redis.Get(ctx, fmt.Sprintf(uploadOwnerKeyFmt, uploadID)).Uint64()
+	return err == nil && uint(owner) == userID
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"coder_edu_backend/internal/testutil"
+	"coder_edu_backend/internal/util"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUploadLimiterBindsIdentifierToFirstUploader(t *testing.T) {
+	ctx := context.Background()
+	l := NewUploadLimiter(testutil.Redis(t), 2, time.Hour)
+
+	if err := l.Acquire(ctx, 1, "video-a"); err != nil {
+		t.Fatalf("owner acquire: %v", err)
+	}
+	// 后续分片重复登记只刷新活动时间
+	if err := l.Acquire(ctx, 1, "video-a"); err != nil {
+		t.Fatalf("owner re-acquire: %v", err)
+	}
+
+	// 其他用户使用同一标识被拒绝，且不能接管归属
+	if err := l.Acquire(ctx, 2, "video-a"); !errors.Is(err, util.ErrUploadNotOwned) {
+		t.Fatalf("other user acquire = %v, want ErrUploadNotOwned", err)
+	}
+	if l.Owns(ctx, 2, "video-a") {
+		t.Fatal("other user must not own video-a")
+	}
+	if !l.Owns(ctx, 1, "video-a") {
+		t.Fatal("first uploader must still own video-a")
+	}
+
+	// 上传结束后标识释放，可被重新使用
+	l.Release(ctx, 1, "video-a")
+	if l.Owns(ctx, 1, "video-a") {
+		t.Fatal("released upload must not be owned")
+	}
+	if err := l.Acquire(ctx, 2, "video-a"); err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	if !l.Owns(ctx, 2, "video-a") {
+		t.Fatal("new uploader must own video-a after release")
+	}
+}
+
+func TestUploadLimiterPerUserLimit(t *testing.T) {
+	ctx := context.Background()
+	l := NewUploadLimiter(testutil.Redis(t), 2, time.Hour)
+
+	for _, id := range []string{"a", "b"} {
+		if err := l.Acquire(ctx, 1, id); err != nil {
+			t.Fatalf("acquire %s: %v", id, err)
+		}
+	}
+	if err := l.Acquire(ctx, 1, "c"); !errors.Is(err, util.ErrTooManyUploads) {
+		t.Fatalf("third upload = %v, want ErrTooManyUploads", err)
+	}
+	// 被限流的上传不应占用标识
+	if err := l.Acquire(ctx, 2, "c"); err != nil {
+		t.Fatalf("other user acquire c: %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"bytes"
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/util"
+	"coder_edu_backend/pkg/logger"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userImportMaxRows 单次导入的最大行数，bcrypt 逐行加密较慢，过大的文件应拆分导入
+const userImportMaxRows = 1000
+
+// 导入结果中每行的状态
+const (
+	UserImportCreated = "created"
+	UserImportSkipped = "skipped"
+)
+
+// UserImportRow 导入文件中一行的处理结果
+type UserImportRow struct {
+	Line     int            `json:"line"` // 在 CSV 文件中的行号
+	Name     string         `json:"name"`
+	Email    string         `json:"email"`
+	Role     model.UserRole `json:"role"`
+	Status   string         `json:"status"`
+	UserID   uint           `json:"userId,omitempty"`
+	Password string         `json:"password,omitempty"` // 系统生成的初始密码，CSV 中自带密码的行不回显
+	Reason   string         `json:"reason,omitempty"`   // 跳过原因
+}
+
+// UserImportReport 批量导入结果
+type UserImportReport struct {
+	Created int             `json:"created"`
+	Skipped int             `json:"skipped"`
+	Rows    []UserImportRow `json:"rows"`
+}
+
+var userImportResultHeaders = []string{"行号", "姓名", "邮箱", "角色", "结果", "初始密码", "原因"}
+
+// ImportUsersCSV 按 CSV（姓名,邮箱,角色,初始密码）批量创建账号，首行为表头时自动跳过。
+// 角色为空时默认为学生，教师只能导入学生；初始密码为空时生成随机密码，否则按密码强度规则校验。
+// 邮箱格式错误、文件内重复或已被注册的行会被跳过并说明原因，其余行逐个创建
+func (s *UserService) ImportUsersCSV(ctx context.Context, r io.Reader, importerRole model.UserRole) (*UserImportReport, error) {
+	records, err := readUserImportCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &UserImportReport{Rows: make([]UserImportRow, 0, len(records))}
+	pending := make([]int, 0, len(records))
+	passwords := make(map[int]string)
+	seen := make(map[string]bool, len(records))
+	for _, rec := range records {
+		row := UserImportRow{Line: rec.line, Name: rec.field(0), Email: rec.field(1), Role: model.UserRole(strings.ToLower(rec.field(2)))}
+		if row.Role == "" {
+			row.Role = model.Student
+		}
+		password := rec.field(3)
+
+		row.Reason = validateImportRow(row, importerRole)
+		if row.Reason == "" && seen[strings.ToLower(row.Email)] {
+			row.Reason = "文件中邮箱重复"
+		}
+		if row.Reason == "" && password != "" {
+			if err := s.validatePassword(password); err != nil {
+				var ruleErr *PasswordRuleError
+				if !errors.As(err, &ruleErr) {
+					return nil, err
+				}
+				row.Reason = ruleErr.Message
+			}
+		}
+		if row.Reason == "" {
+			seen[strings.ToLower(row.Email)] = true
+			passwords[len(report.Rows)] = password
+			pending = append(pending, len(report.Rows))
+		} else {
+			row.Status = UserImportSkipped
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	registered, err := s.registeredEmails(report.Rows, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range pending {
+		row := &report.Rows[i]
+		if registered[strings.ToLower(row.Email)] {
+			row.Status, row.Reason = UserImportSkipped, util.ErrEmailRegistered.Error()
+			continue
+		}
+
+		password := passwords[i]
+		generated := password == ""
+		if generated {
+			password = generateTempPassword()
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		user := &model.User{Name: row.Name, Email: row.Email, Password: string(hashed), Role: row.Role}
+		if err := s.UserRepo.Create(user); err != nil {
+			// 与其他注册请求并发时由唯一索引兜底；其他错误只跳过当前行，已创建的账号仍需回显初始密码
+			row.Status, row.Reason = UserImportSkipped, "创建账号失败"
+			if taken, findErr := s.UserRepo.EmailTaken(row.Email); findErr == nil && taken {
+				row.Reason = util.ErrEmailRegistered.Error()
+			} else {
+				logger.FromContext(ctx).Error("Failed to create imported user", zap.Int("line", row.Line), zap.Error(err))
+			}
+			continue
+		}
+		row.Status, row.UserID = UserImportCreated, user.ID
+		if generated {
+			row.Password = password
+		}
+	}
+
+	for _, row := range report.Rows {
+		if row.Status == UserImportCreated {
+			report.Created++
+		} else {
+			report.Skipped++
+		}
+	}
+	return report, nil
+}
+
+// WriteUserImportCSV 将导入结果写成带 UTF-8 BOM 的 CSV，包含生成的初始密码，供管理员下载分发
+func WriteUserImportCSV(w io.Writer, report *UserImportReport) error {
+	if _, err := w.Write(utf8BOM); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(userImportResultHeaders); err != nil {
+		return err
+	}
+	for _, row := range report.Rows {
+		status := "已创建"
+		if row.Status == UserImportSkipped {
+			status = "已跳过"
+		}
+		record := []string{strconv.Itoa(row.Line), row.Name, row.Email, string(row.Role), status, row.Password, row.Reason}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type userImportRecord struct {
+	line   int
+	fields []string
+}
+
+func (r userImportRecord) field(i int) string {
+	if i >= len(r.fields) {
+		return ""
+	}
+	return strings.TrimSpace(r.fields[i])
+}
+
+// readUserImportCSV 读取导入文件，去掉 Excel 写入的 BOM 并跳过表头
+func readUserImportCSV(r io.Reader) ([]userImportRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var records []userImportRecord
+	for {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: CSV 格式错误: %v", util.ErrInvalidRequest, err)
+		}
+		line, _ := cr.FieldPos(0)
+		rec := userImportRecord{line: line, fields: fields}
+		if len(records) == 0 && isUserImportHeader(rec) {
+			continue
+		}
+		records = append(records, rec)
+		if len(records) > userImportMaxRows {
+			return nil, fmt.Errorf("%w: 单次最多导入 %d 行", util.ErrInvalidRequest, userImportMaxRows)
+		}
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: 文件中没有可导入的数据", util.ErrInvalidRequest)
+	}
+	return records, nil
+}
+
+func isUserImportHeader(rec userImportRecord) bool {
+	switch strings.ToLower(rec.field(1)) {
+	case "email", "邮箱":
+		return true
+	}
+	return false
+}
+
+// validateImportRow 校验一行的姓名、邮箱和角色，返回跳过原因，通过时返回空字符串
+func validateImportRow(row UserImportRow, importerRole model.UserRole) string {
+	if row.Name == "" {
+		return "姓名不能为空"
+	}
+	if utf8.RuneCountInString(row.Name) > 100 {
+		return "姓名过长（最多100个字符）"
+	}
+	if addr, err := mail.ParseAddress(row.Email); err != nil || addr.Address != row.Email || len(row.Email) > 100 {
+		return "邮箱格式错误"
+	}
+	switch row.Role {
+	case model.Student:
+	case model.Teacher, model.Admin:
+		if importerRole != model.Admin {
+			return "教师只能导入学生账号"
+		}
+	default:
+		return "角色只能是 student、teacher 或 admin"
+	}
+	return ""
+}
+
+// registeredEmails 查询待创建行中已被注册的邮箱（小写），已软删除的账号仍占用唯一索引，一并计入
+func (s *UserService) registeredEmails(rows []UserImportRow, pending []int) (map[string]bool, error) {
+	emails := make([]string, 0, len(pending))
+	for _, i := range pending {
+		emails = append(emails, rows[i].Email)
+	}
+	registered := make(map[string]bool)
+	for start := 0; start < len(emails); start += 500 {
+		end := min(start+500, len(emails))
+		var existing []string
+		err := s.UserRepo.DB.Unscoped().Model(&model.User{}).Where("email IN ?", emails[start:end]).Pluck("email", &existing).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range existing {
+			registered[strings.ToLower(e)] = true
+		}
+	}
+	return registered, nil
+}
@@ -5,13 +5,18 @@ import (
 	"coder_edu_backend/internal/repository"
 	"coder_edu_backend/internal/util"
 	"crypto/rand"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"math"
 	"math/big"
+	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UserFilter 定义用户筛选条件
@@ -39,6 +44,8 @@ type UserService struct {
 	UserRepo    *repository.UserRepository
 	CheckinRepo *repository.CheckinRepository
 	DB          *gorm.DB
+	Passwords   *PasswordPolicy
+	Events      *EventBus
 }
 
 // UserStatsResponse 用户统计数据响应
@@ -49,6 +56,20 @@ type UserStatsResponse struct {
 	LevelCompletionCount  int     `json:"levelCompletionCount"`  // 关卡挑战完成个数
 }
 
+// GoalCompletedHandler 返回目标达成事件的处理函数：通过积分流水发放积分，同一目标只发放一次
+func (s *UserService) GoalCompletedHandler(points int) EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(GoalCompletedEvent)
+		if !ok || points <= 0 || s.DB == nil {
+			return nil
+		}
+		return s.DB.Transaction(func(tx *gorm.DB) error {
+			_, err := awardPoints(tx, evt.UserID, points, model.PointSourceGoalCompleted, strconv.FormatUint(uint64(evt.GoalID), 10), "完成目标："+evt.Title)
+			return err
+		})
+	}
+}
+
 // NewUserService 创建一个新的用户服务实例
 func NewUserService(userRepo *repository.UserRepository, checkinRepo *repository.CheckinRepository) *UserService {
 	return &UserService{
@@ -57,12 +78,14 @@ func NewUserService(userRepo *repository.UserRepository, checkinRepo *repository
 	}
 }
 
-// NewUserServiceWithDB 创建一个新的用户服务实例（包含数据库连接）
-func NewUserServiceWithDB(userRepo *repository.UserRepository, checkinRepo *repository.CheckinRepository, db *gorm.DB) *UserService {
+// NewUserServiceWithDB 创建一个新的用户服务实例（包含数据库连接、密码强度校验及事件发布）
+func NewUserServiceWithDB(userRepo *repository.UserRepository, checkinRepo *repository.CheckinRepository, db *gorm.DB, passwords *PasswordPolicy, events *EventBus) *UserService {
 	return &UserService{
 		UserRepo:    userRepo,
 		CheckinRepo: checkinRepo,
 		DB:          db,
+		Passwords:   passwords,
+		Events:      events,
 	}
 }
 
@@ -116,32 +139,24 @@ func (s *UserService) GetUserByID(id uint) (*model.User, error) {
 	return s.UserRepo.FindByID(id)
 }
 
-// UpdateUser 更新用户信息
-func (s *UserService) UpdateUser(user *model.User) error {
-	existingUser, err := s.UserRepo.FindByID(user.ID)
-	if err != nil {
-		return util.ErrUserNotFound
-	}
-
-	existingUser.Name = user.Name
-	existingUser.Email = user.Email
-	existingUser.Role = user.Role
-	existingUser.Language = user.Language
-	existingUser.Disabled = user.Disabled
-	existingUser.UpdatedAt = time.Now()
-
-	return s.UserRepo.Update(existingUser)
+// UpdateUser 更新用户信息，角色变更会以 actorID 为操作人写入审计记录
+func (s *UserService) UpdateUser(actorID uint, user *model.User) error {
+	return s.UpdateUserWithPassword(actorID, user, "")
 }
 
-// ResetPassword 重置用户密码
-func (s *UserService) ResetPassword(userID uint) (string, error) {
+// ResetPassword 重置用户密码。password 为空时生成随机临时密码，否则按密码强度规则校验后使用
+func (s *UserService) ResetPassword(userID uint, password string) (string, error) {
 	user, err := s.UserRepo.FindByID(userID)
 	if err != nil {
 		return "", util.ErrUserNotFound
 	}
 
-	// 生成临时密码
-	tempPassword := generateTempPassword()
+	tempPassword := password
+	if tempPassword == "" {
+		tempPassword = generateTempPassword()
+	} else if err := s.validatePassword(tempPassword); err != nil {
+		return "", err
+	}
 
 	// 加密密码
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(tempPassword), bcrypt.DefaultCost)
@@ -150,7 +165,7 @@ func (s *UserService) ResetPassword(userID uint) (string, error) {
 	}
 
 	user.Password = string(hashedPassword)
-	user.UpdatedAt = time.Now()
+	user.UpdatedAt = model.NewJSONTime(time.Now())
 
 	if err := s.UserRepo.Update(user); err != nil {
 		return "", err
@@ -159,61 +174,269 @@ func (s *UserService) ResetPassword(userID uint) (string, error) {
 	return tempPassword, nil
 }
 
-// DeleteUser 删除用户
+// DeleteUser 删除用户，不能删除最后一个启用中的管理员
 func (s *UserService) DeleteUser(id uint) error {
 	user, err := s.UserRepo.FindByID(id)
 	if err != nil {
 		return util.ErrUserNotFound
 	}
 
-	return s.UserRepo.DB.Delete(user).Error
+	err = s.UserRepo.DB.Transaction(func(tx *gorm.DB) error {
+		if isActiveAdmin(user) {
+			if err := ensureNotLastAdmin(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(user).Error
+	})
+	if err == nil {
+		s.Events.Publish(EventUserDisabled, UserDisabledEvent{UserID: id})
+	}
+	return err
 }
 
-// DisableUser 禁用/启用用户
+// DisableUser 禁用/启用用户，不能禁用最后一个启用中的管理员
 func (s *UserService) DisableUser(id uint, disable bool) error {
 	user, err := s.UserRepo.FindByID(id)
 	if err != nil {
 		return util.ErrUserNotFound
 	}
 
-	user.Disabled = disable
-	user.UpdatedAt = time.Now()
+	err = s.UserRepo.DB.Transaction(func(tx *gorm.DB) error {
+		if disable && isActiveAdmin(user) {
+			if err := ensureNotLastAdmin(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		user.Disabled = disable
+		user.UpdatedAt = model.NewJSONTime(time.Now())
+		return repository.NewUserRepository(tx).Update(user)
+	})
+	if err == nil && disable {
+		s.Events.Publish(EventUserDisabled, UserDisabledEvent{UserID: id})
+	}
+	return err
+}
 
-	return s.UserRepo.Update(user)
+// 批量用户操作类型
+const (
+	BulkUserDisable       = "disable"
+	BulkUserEnable        = "enable"
+	BulkUserDelete        = "delete"
+	BulkUserResetPassword = "reset-password"
+	BulkUserSetRole       = "set-role"
+)
+
+// BulkUserResult 批量操作中单个用户的执行结果
+type BulkUserResult struct {
+	UserID       uint   `json:"userId"`
+	Success      bool   `json:"success"`
+	Error        string `json:"error,omitempty"`
+	TempPassword string `json:"tempPassword,omitempty"` // reset-password 成功时返回的临时密码
 }
 
-// generateTempPassword 生成安全的随机临时密码（16位，包含大小写字母和数字）
-func generateTempPassword() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	password := make([]byte, 16)
-	for i := range password {
-		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
-		if err != nil {
-			password[i] = charset[i%len(charset)]
+// BulkUserReport 批量操作结果汇总
+type BulkUserReport struct {
+	Action    string           `json:"action"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkUserResult `json:"results"`
+}
+
+// BulkUpdateUsers 对多个用户执行同一操作。整批在一个事务中执行，每个用户使用独立的保存点：
+// 用户不存在、触发最后一个管理员保护的用户单独回滚并在结果中说明，其余用户照常提交；数据库错误则整批回滚。
+// 只按列更新禁用状态、角色和密码，不会用旧数据覆盖积分等字段，积分流水保持不变
+func (s *UserService) BulkUpdateUsers(actorID uint, userIDs []uint, action string, role model.UserRole) (*BulkUserReport, error) {
+	switch action {
+	case BulkUserDisable, BulkUserEnable, BulkUserDelete, BulkUserResetPassword:
+	case BulkUserSetRole:
+		if role != model.Student && role != model.Teacher && role != model.Admin {
+			return nil, fmt.Errorf("%w: invalid role %q", util.ErrInvalidRequest, role)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", util.ErrInvalidRequest, action)
+	}
+
+	seen := make(map[uint]bool, len(userIDs))
+	ids := make([]uint, 0, len(userIDs))
+	for _, id := range userIDs {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	// bcrypt 较慢，在事务外生成临时密码，避免长时间持有锁
+	tempPasswords := make(map[uint]string)
+	hashes := make(map[uint]string)
+	if action == BulkUserResetPassword {
+		for _, id := range ids {
+			temp := generateTempPassword()
+			hashed, err := bcrypt.GenerateFromPassword([]byte(temp), bcrypt.DefaultCost)
+			if err != nil {
+				return nil, err
+			}
+			tempPasswords[id] = temp
+			hashes[id] = string(hashed)
+		}
+	}
+
+	report := &BulkUserReport{Action: action, Results: make([]BulkUserResult, 0, len(ids))}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, id := range ids {
+			result := BulkUserResult{UserID: id}
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				return applyBulkUserAction(tx, actorID, id, action, role, hashes[id])
+			})
+			switch {
+			case err == nil:
+				result.Success = true
+				result.TempPassword = tempPasswords[id]
+			case errors.Is(err, util.ErrUserNotFound), errors.Is(err, util.ErrLastAdmin):
+				result.Error = err.Error()
+			default:
+				return err
+			}
+			report.Results = append(report.Results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var deactivated []uint
+	for _, r := range report.Results {
+		if !r.Success {
+			report.Failed++
 			continue
 		}
-		password[i] = charset[n.Int64()]
+		report.Succeeded++
+		deactivated = append(deactivated, r.UserID)
+	}
+	if action == BulkUserDisable || action == BulkUserDelete {
+		for _, id := range deactivated {
+			s.Events.Publish(EventUserDisabled, UserDisabledEvent{UserID: id})
+		}
+	}
+	return report, nil
+}
+
+// applyBulkUserAction 在保存点内对单个用户执行批量操作
+func applyBulkUserAction(tx *gorm.DB, actorID, userID uint, action string, role model.UserRole, passwordHash string) error {
+	var user model.User
+	if err := tx.Select("id", "role", "disabled").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return util.ErrUserNotFound
+		}
+		return err
+	}
+
+	columns := map[string]interface{}{"updated_at": time.Now()}
+	switch action {
+	case BulkUserDisable:
+		if isActiveAdmin(&user) {
+			if err := ensureNotLastAdmin(tx, userID); err != nil {
+				return err
+			}
+		}
+		columns["disabled"] = true
+	case BulkUserEnable:
+		columns["disabled"] = false
+	case BulkUserDelete:
+		if isActiveAdmin(&user) {
+			if err := ensureNotLastAdmin(tx, userID); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&model.User{}, userID).Error
+	case BulkUserResetPassword:
+		columns["password"] = passwordHash
+	case BulkUserSetRole:
+		if user.Role == role {
+			return nil
+		}
+		if isActiveAdmin(&user) && role != model.Admin {
+			if err := ensureNotLastAdmin(tx, userID); err != nil {
+				return err
+			}
+		}
+		columns["role"] = role
+	}
+
+	if err := tx.Model(&model.User{}).Where("id = ?", userID).Updates(columns).Error; err != nil {
+		return err
+	}
+	if action != BulkUserSetRole {
+		return nil
+	}
+	detail, _ := json.Marshal(map[string]model.UserRole{"from": user.Role, "to": role})
+	return repository.NewAuditLogRepository(tx).Create(&model.AuditLog{
+		ActorID:    actorID,
+		Action:     AuditActionChangeRole,
+		TargetType: AuditTargetUser,
+		TargetID:   strconv.FormatUint(uint64(userID), 10),
+		Detail:     string(detail),
+	})
+}
+
+// generateTempPassword 生成安全的随机临时密码（16位），小写字母、大写字母、数字和符号各至少一个，
+// 保证在任意 min_char_classes 配置下都能通过密码强度校验
+func generateTempPassword() string {
+	classes := []string{
+		"abcdefghijklmnopqrstuvwxyz",
+		"ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+		"0123456789",
+		"!@#$%^&*-_=+?",
+	}
+	const length = 16
+
+	password := make([]byte, 0, length)
+	for _, set := range classes {
+		password = append(password, set[randomIndex(len(set))])
+	}
+	all := strings.Join(classes, "")
+	for len(password) < length {
+		password = append(password, all[randomIndex(len(all))])
+	}
+	// 打乱顺序，避免前四位的字符种类固定
+	for i := len(password) - 1; i > 0; i-- {
+		j := randomIndex(i + 1)
+		password[i], password[j] = password[j], password[i]
 	}
 	return string(password)
 }
 
-// UpdateUserWithPassword 更新用户信息并修改密码
-func (s *UserService) UpdateUserWithPassword(user *model.User, newPassword string) error {
+// randomIndex 返回 [0, n) 内的安全随机数
+func randomIndex(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(fmt.Sprintf("crypto/rand unavailable: %v", err))
+	}
+	return int(v.Int64())
+}
+
+// validatePassword 未配置密码策略时（如 NewUserService 创建的实例）不做校验
+func (s *UserService) validatePassword(password string) error {
+	if s.Passwords == nil {
+		return nil
+	}
+	return s.Passwords.Validate(password)
+}
+
+// UpdateUserWithPassword 更新用户信息，newPassword 不为空时同时修改密码。
+// 不能把最后一个启用中的管理员降级或禁用（返回 ErrLastAdmin），角色变更会写入审计记录
+func (s *UserService) UpdateUserWithPassword(actorID uint, user *model.User, newPassword string) error {
 	existingUser, err := s.UserRepo.FindByID(user.ID)
 	if err != nil {
 		return util.ErrUserNotFound
 	}
 
-	// 更新基本信息
-	existingUser.Name = user.Name
-	existingUser.Email = user.Email
-	existingUser.Role = user.Role
-	existingUser.Language = user.Language
-	existingUser.Disabled = user.Disabled
-	existingUser.UpdatedAt = time.Now()
-
-	// 如果提供了新密码，则进行加密并更新
+	// 如果提供了新密码，则校验强度后加密并更新
 	if newPassword != "" {
+		if err := s.validatePassword(newPassword); err != nil {
+			return err
+		}
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
 		if err != nil {
 			return err
@@ -221,11 +444,129 @@ func (s *UserService) UpdateUserWithPassword(user *model.User, newPassword strin
 		existingUser.Password = string(hashedPassword)
 	}
 
-	return s.UserRepo.Update(existingUser)
+	previousRole := existingUser.Role
+	wasDisabled := existingUser.Disabled
+	err = s.UserRepo.DB.Transaction(func(tx *gorm.DB) error {
+		if isActiveAdmin(existingUser) && (user.Role != model.Admin || user.Disabled) {
+			if err := ensureNotLastAdmin(tx, existingUser.ID); err != nil {
+				return err
+			}
+		}
+
+		// 更新基本信息
+		existingUser.Name = user.Name
+		existingUser.Email = user.Email
+		existingUser.Role = user.Role
+		existingUser.Language = user.Language
+		existingUser.Disabled = user.Disabled
+		existingUser.UpdatedAt = model.NewJSONTime(time.Now())
+		if err := repository.NewUserRepository(tx).Update(existingUser); err != nil {
+			return err
+		}
+
+		if previousRole == user.Role {
+			return nil
+		}
+		detail, _ := json.Marshal(map[string]model.UserRole{"from": previousRole, "to": user.Role})
+		return repository.NewAuditLogRepository(tx).Create(&model.AuditLog{
+			ActorID:    actorID,
+			Action:     AuditActionChangeRole,
+			TargetType: AuditTargetUser,
+			TargetID:   strconv.FormatUint(uint64(existingUser.ID), 10),
+			Detail:     string(detail),
+		})
+	})
+	if err == nil && user.Disabled && !wasDisabled {
+		s.Events.Publish(EventUserDisabled, UserDisabledEvent{UserID: existingUser.ID})
+	}
+	return err
+}
+
+func isActiveAdmin(user *model.User) bool {
+	return user.Role == model.Admin && !user.Disabled
+}
+
+// ensureNotLastAdmin 按 ID 顺序锁定所有启用中的管理员，userID 是其中唯一一个时返回 ErrLastAdmin。
+// 统一加锁顺序，避免同时降级两个管理员时各自通过校验或互相死锁
+func ensureNotLastAdmin(tx *gorm.DB, userID uint) error {
+	var adminIDs []uint
+	err := tx.Model(&model.User{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ? AND disabled = ?", model.Admin, false).
+		Order("id").
+		Pluck("id", &adminIDs).Error
+	if err != nil {
+		return err
+	}
+	if len(adminIDs) == 1 && adminIDs[0] == userID {
+		return util.ErrLastAdmin
+	}
+	return nil
+}
+
+// RoleChange 用户角色变更审计记录
+type RoleChange struct {
+	ID        uint           `json:"id"`
+	ActorID   uint           `json:"actorId"`
+	ActorName string         `json:"actorName"`
+	UserID    uint           `json:"userId"`
+	UserName  string         `json:"userName"`
+	From      model.UserRole `json:"from"`
+	To        model.UserRole `json:"to"`
+	CreatedAt model.JSONTime `json:"createdAt"`
+}
+
+// GetRoleChanges 分页获取角色变更审计记录（按时间倒序），userID 为 0 时返回全部用户的记录
+func (s *UserService) GetRoleChanges(userID uint, page, limit int) ([]RoleChange, int64, error) {
+	targetID := ""
+	if userID > 0 {
+		targetID = strconv.FormatUint(uint64(userID), 10)
+	}
+	logs, total, err := repository.NewAuditLogRepository(s.UserRepo.DB).List(AuditActionChangeRole, AuditTargetUser, targetID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	changes := make([]RoleChange, 0, len(logs))
+	userIDs := make([]uint, 0, len(logs)*2)
+	for _, l := range logs {
+		var detail struct {
+			From model.UserRole `json:"from"`
+			To   model.UserRole `json:"to"`
+		}
+		_ = json.Unmarshal([]byte(l.Detail), &detail)
+		target, _ := strconv.ParseUint(l.TargetID, 10, 64)
+		changes = append(changes, RoleChange{
+			ID:        l.ID,
+			ActorID:   l.ActorID,
+			UserID:    uint(target),
+			From:      detail.From,
+			To:        detail.To,
+			CreatedAt: l.CreatedAt,
+		})
+		userIDs = append(userIDs, l.ActorID, uint(target))
+	}
+	if len(userIDs) == 0 {
+		return changes, total, nil
+	}
+
+	var users []model.User
+	if err := s.UserRepo.DB.Select("id", "name").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Name
+	}
+	for i := range changes {
+		changes[i].ActorName = names[changes[i].ActorID]
+		changes[i].UserName = names[changes[i].UserID]
+	}
+	return changes, total, nil
 }
 
 // UpdateProfile 更新个人资料
-func (s *UserService) UpdateProfile(userID uint, name, avatar string) error {
+func (s *UserService) UpdateProfile(userID uint, name, avatar string, hideOnline, qaPersonalization *bool) error {
 	user, err := s.UserRepo.FindByID(userID)
 	if err != nil {
 		return err
@@ -237,7 +578,13 @@ func (s *UserService) UpdateProfile(userID uint, name, avatar string) error {
 	if avatar != "" {
 		user.Avatar = avatar
 	}
-	user.UpdatedAt = time.Now()
+	if hideOnline != nil {
+		user.HideOnline = *hideOnline
+	}
+	if qaPersonalization != nil {
+		user.QAPersonalizationDisabled = !*qaPersonalization
+	}
+	user.UpdatedAt = model.NewJSONTime(time.Now())
 
 	return s.UserRepo.Update(user)
 }
@@ -252,6 +599,55 @@ func (s *UserService) UpdateUserPoints(userID uint, points int) error {
 	return s.UserRepo.UpdateXP(userID, points)
 }
 
+// AdjustPoints 手动增减用户的独立积分（User.Points），并记录操作人和原因到积分流水
+func (s *UserService) AdjustPoints(operatorID, userID uint, delta int, reason string) error {
+	if _, err := s.UserRepo.FindByID(userID); err != nil {
+		return errors.New("用户不存在")
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		return recordPoints(tx, &model.PointsLedger{
+			UserID:     userID,
+			Delta:      delta,
+			Reason:     reason,
+			SourceType: model.PointSourceManual,
+			OperatorID: &operatorID,
+		})
+	})
+}
+
+// GetPointsLedger 分页获取用户的积分流水（按时间倒序）
+func (s *UserService) GetPointsLedger(userID uint, page, limit int) ([]model.PointsLedger, int64, error) {
+	var total int64
+	entries := make([]model.PointsLedger, 0)
+	query := s.DB.Model(&model.PointsLedger{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&entries).Error
+	return entries, total, err
+}
+
+// PointsMismatch 用户积分与积分流水合计不一致的记录
+type PointsMismatch struct {
+	UserID      uint `json:"userId"`
+	Points      int  `json:"points"`
+	LedgerTotal int  `json:"ledgerTotal"`
+}
+
+// CheckPointsConsistency 找出 User.Points 与积分流水 Delta 之和不一致的用户
+func (s *UserService) CheckPointsConsistency() ([]PointsMismatch, error) {
+	mismatches := make([]PointsMismatch, 0)
+	err := s.DB.Raw(`
+		SELECT u.id AS user_id, u.points AS points, COALESCE(SUM(l.delta), 0) AS ledger_total
+		FROM users u
+		LEFT JOIN points_ledger l ON l.user_id = u.id
+		WHERE u.deleted_at IS NULL
+		GROUP BY u.id, u.points
+		HAVING u.points <> COALESCE(SUM(l.delta), 0)
+	`).Scan(&mismatches).Error
+	return mismatches, err
+}
+
 // 用户签到功能
 func (s *UserService) Checkin(userID uint) (bool, error) {
 	// 检查今天是否已经签到
@@ -264,18 +660,16 @@ func (s *UserService) Checkin(userID uint) (bool, error) {
 	// 创建新的签到记录
 	checkin := &model.Checkin{
 		UserID:     userID,
-		CheckinAt:  time.Now(),
+		CheckinAt:  model.NewJSONTime(time.Now()),
 		StreakDays: 1,
 	}
 
 	// 检查用户最近的签到记录，更新连续签到天数
 	latestCheckin, err := s.CheckinRepo.FindLatestByUser(userID)
 	if err == nil {
-		// 检查是否是连续签到（昨天）
-		yesterday := time.Now().Add(-24 * time.Hour)
-		if latestCheckin.CheckinAt.Year() == yesterday.Year() &&
-			latestCheckin.CheckinAt.Month() == yesterday.Month() &&
-			latestCheckin.CheckinAt.Day() == yesterday.Day() {
+		// 检查是否是连续签到（昨天，含冻结补签）
+		yesterday := startOfDay(time.Now()).AddDate(0, 0, -1)
+		if startOfDay(latestCheckin.CheckinAt.Time).Equal(yesterday) {
 			// 连续签到，增加连续签到天数
 			checkin.StreakDays = latestCheckin.StreakDays + 1
 		}
@@ -343,15 +737,22 @@ func (s *UserService) GetCheckinStats(userID uint) (map[string]interface{}, erro
 		return nil, err
 	}
 
-	// 获取连续签到天数和对应的积分
-	streakDays := 0
+	// 按自然日计算当前和历史最长连续签到天数及对应的积分
+	checkins, err := s.CheckinRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	streakDays, longestStreak := computeStreaks(checkins, time.Now())
 	streakPoints := 0
-	latestCheckin, err := s.CheckinRepo.FindLatestByUser(userID)
-	if err == nil {
-		streakDays = latestCheckin.StreakDays
+	if streakDays > 0 {
 		streakPoints = calculateCheckinPoints(streakDays)
 	}
 
+	freezeTokens, err := remainingStreakFreezes(s.CheckinRepo, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	// 获取用户当前积分
 	user, err := s.UserRepo.FindByID(userID)
 	currentPoints := 0
@@ -363,11 +764,120 @@ func (s *UserService) GetCheckinStats(userID uint) (map[string]interface{}, erro
 		"isCheckedInToday": isCheckedInToday,
 		"totalCheckins":    checkinCount,
 		"currentStreak":    streakDays,
+		"longestStreak":    longestStreak,
+		"freezeTokens":     freezeTokens,
 		"streakPoints":     streakPoints,
 		"currentPoints":    currentPoints,
 	}, nil
 }
 
+// MaxStreakFreezesPerMonth 每个自然月可使用的签到冻结次数
+const MaxStreakFreezesPerMonth = 2
+
+// startOfDay 返回 t 在服务器时区（与数据库连接 loc=Local 一致）下当天的零点
+func startOfDay(t time.Time) time.Time {
+	t = t.In(time.Local)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// computeStreaks 根据按时间升序的签到记录计算当前连续天数和最长连续天数。
+// 冻结记录只用于衔接断开的一天，本身不计入天数；最后一次签到为今天或昨天时当前连续才有效
+func computeStreaks(checkins []model.Checkin, now time.Time) (current, longest int) {
+	var prevDay time.Time
+	run := 0
+	for _, c := range checkins {
+		day := startOfDay(c.CheckinAt.Time)
+		if !prevDay.IsZero() && !day.After(prevDay) {
+			continue
+		}
+		if prevDay.IsZero() || !day.Equal(prevDay.AddDate(0, 0, 1)) {
+			run = 0
+		}
+		if !c.Frozen {
+			run++
+		}
+		prevDay = day
+		if run > longest {
+			longest = run
+		}
+	}
+
+	if !prevDay.IsZero() && !prevDay.Before(startOfDay(now).AddDate(0, 0, -1)) {
+		current = run
+	}
+	return current, longest
+}
+
+// remainingStreakFreezes 返回用户本月剩余的冻结次数
+func remainingStreakFreezes(checkins *repository.CheckinRepository, userID uint) (int, error) {
+	today := startOfDay(time.Now())
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.Local)
+	used, err := checkins.CountFrozenSince(userID, monthStart)
+	if err != nil {
+		return 0, err
+	}
+	remaining := MaxStreakFreezesPerMonth - int(used)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, nil
+}
+
+// FreezeStreak 消耗一次冻结为昨天补签，使连续签到不因漏签一天而中断。
+// 仅当昨天未签到且前天有签到（或冻结）时可用，返回本月剩余冻结次数。
+// 检查与补签在锁定用户行的事务中完成，并发或重试的请求只有一个能为同一天补签
+func (s *UserService) FreezeStreak(userID uint) (int, error) {
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+	var remaining int
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").First(&model.User{}, userID).Error; err != nil {
+			return err
+		}
+		checkins := repository.NewCheckinRepository(tx)
+
+		if _, err := checkins.FindByUserAndDate(userID, yesterday); err == nil {
+			return util.ErrStreakFreezeNotNeeded
+		}
+		before, err := checkins.FindByUserAndDate(userID, now.AddDate(0, 0, -2))
+		if err != nil {
+			return util.ErrStreakFreezeNotNeeded
+		}
+
+		left, err := remainingStreakFreezes(checkins, userID)
+		if err != nil {
+			return err
+		}
+		if left == 0 {
+			return util.ErrNoStreakFreezeLeft
+		}
+
+		if err := checkins.Create(&model.Checkin{
+			UserID:     userID,
+			CheckinAt:  model.NewJSONTime(yesterday),
+			StreakDays: before.StreakDays,
+			Frozen:     true,
+		}); err != nil {
+			return err
+		}
+
+		// 今天已签到时连续天数已被重置为 1，补签后接上之前的连续天数
+		if today, err := checkins.FindByUserAndDate(userID, now); err == nil {
+			if err := checkins.UpdateStreakDays(today.ID, before.StreakDays+1); err != nil {
+				return err
+			}
+		}
+
+		remaining = left - 1
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return remaining, nil
+}
+
 // GetUserStats 获取用户的统计数据
 func (s *UserService) GetUserStats(userID uint) (*UserStatsResponse, error) {
 	if s.DB == nil {
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"coder_edu_backend/internal/config"
+	"coder_edu_backend/internal/model"
+	"coder_edu_backend/internal/repository"
+	"coder_edu_backend/internal/testutil"
+	"coder_edu_backend/internal/util"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGenerateTempPasswordPassesStrictPolicy(t *testing.T) {
+	policy := NewPasswordPolicy(config.PasswordConfig{MinLength: 16, MinCharClasses: 4})
+	for i := 0; i < 200; i++ {
+		password := generateTempPassword()
+		if len(password) != 16 {
+			t.Fatalf("len(%q) = %d, want 16", password, len(password))
+		}
+		if err := policy.Validate(password); err != nil {
+			t.Fatalf("generated password %q rejected: %v", password, err)
+		}
+	}
+}
+
+// TestFreezeStreakConcurrent 需要 testutil.MySQL 的测试库，未设置 TEST_MYSQL_DSN 时跳过
+func TestFreezeStreakConcurrent(t *testing.T) {
+	db := testutil.MySQL(t)
+	s := &UserService{CheckinRepo: repository.NewCheckinRepository(db), DB: db}
+
+	user := model.User{Name: "freeze-test", Email: "freeze-test-" + model.GenerateUUID() + "@example.com", Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	defer func() {
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(&model.Checkin{})
+		db.Unscoped().Delete(&user)
+	}()
+	// 前天签到、昨天漏签，可以冻结一次
+	if err := db.Create(&model.Checkin{UserID: user.ID, CheckinAt: model.NewJSONTime(time.Now().AddDate(0, 0, -2)), StreakDays: 3}).Error; err != nil {
+		t.Fatalf("create checkin: %v", err)
+	}
+
+	errs := make([]error, 3)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.FreezeStreak(user.ID)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, util.ErrStreakFreezeNotNeeded):
+			t.Fatalf("freeze %d: %v", i, err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d freezes succeeded, want exactly 1", succeeded)
+	}
+
+	var frozen int64
+	db.Model(&model.Checkin{}).Where("user_id = ? AND frozen = ?", user.ID, true).Count(&frozen)
+	if frozen != 1 {
+		t.Fatalf("stored %d frozen checkins, want 1", frozen)
+	}
+}
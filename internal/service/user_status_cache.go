@@ -0,0 +1,63 @@
+package service
+
+import (
+	"coder_edu_backend/pkg/logger"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	userEnabledKeyFmt  = "user:enabled:%d"
+	userStatusCacheTTL = 30 * time.Second
+)
+
+// UserStatusSource 查询账号是否被禁用，由 repository.UserRepository 实现
+type UserStatusSource interface {
+	IsDisabled(userID uint) (bool, error)
+}
+
+// UserStatusCache 为鉴权中间件缓存账号状态，避免每个请求都查询 users 表。
+// 只缓存“未禁用”，禁用事件到达时删除缓存，被禁用的账号总是回源查询，重新启用后无需额外失效
+type UserStatusCache struct {
+	Users UserStatusSource
+	Redis *redis.Client
+	TTL   time.Duration
+}
+
+func NewUserStatusCache(users UserStatusSource, rdb *redis.Client) *UserStatusCache {
+	return &UserStatusCache{Users: users, Redis: rdb, TTL: userStatusCacheTTL}
+}
+
+// IsDisabled 优先读取缓存，未命中或 Redis 不可用时回源查询；用户不存在时返回 gorm.ErrRecordNotFound
+func (c *UserStatusCache) IsDisabled(userID uint) (bool, error) {
+	ctx := context.Background()
+	key := fmt.Sprintf(userEnabledKeyFmt, userID)
+	if c.Redis != nil {
+		if n, err := c.Redis.Exists(ctx, key).Result(); err == nil && n > 0 {
+			return false, nil
+		}
+	}
+
+	disabled, err := c.Users.IsDisabled(userID)
+	if err == nil && !disabled && c.Redis != nil {
+		if err := c.Redis.Set(ctx, key, 1, c.TTL).Err(); err != nil {
+			logger.Log.Warn("缓存账号状态失败", zap.Uint("userId", userID), zap.Error(err))
+		}
+	}
+	return disabled, err
+}
+
+// UserDisabledHandler 返回用户禁用事件的处理函数：删除该用户的状态缓存，使禁用立即生效
+func (c *UserStatusCache) UserDisabledHandler() EventHandler {
+	return func(payload interface{}) error {
+		evt, ok := payload.(UserDisabledEvent)
+		if !ok || c.Redis == nil {
+			return nil
+		}
+		return c.Redis.Del(context.Background(), fmt.Sprintf(userEnabledKeyFmt, evt.UserID)).Err()
+	}
+}
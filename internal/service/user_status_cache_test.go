@@ -0,0 +1,52 @@
+package service
+
+import (
+	"coder_edu_backend/internal/testutil"
+	"testing"
+)
+
+// fakeUserStatus 记录回源次数的账号状态源
+type fakeUserStatus struct {
+	disabled map[uint]bool
+	lookups  int
+}
+
+func (f *fakeUserStatus) IsDisabled(userID uint) (bool, error) {
+	f.lookups++
+	return f.disabled[userID], nil
+}
+
+func TestUserStatusCache(t *testing.T) {
+	users := &fakeUserStatus{disabled: map[uint]bool{2: true}}
+	c := NewUserStatusCache(users, testutil.Redis(t))
+
+	// 未禁用的账号回源一次后命中缓存
+	for i := 0; i < 3; i++ {
+		if disabled, err := c.IsDisabled(1); err != nil || disabled {
+			t.Fatalf("IsDisabled(1) = %v, %v, want false", disabled, err)
+		}
+	}
+	if users.lookups != 1 {
+		t.Fatalf("enabled user looked up %d times, want 1", users.lookups)
+	}
+
+	// 已禁用的账号不缓存，每次回源
+	users.lookups = 0
+	for i := 0; i < 2; i++ {
+		if disabled, err := c.IsDisabled(2); err != nil || !disabled {
+			t.Fatalf("IsDisabled(2) = %v, %v, want true", disabled, err)
+		}
+	}
+	if users.lookups != 2 {
+		t.Fatalf("disabled user looked up %d times, want 2", users.lookups)
+	}
+
+	// 禁用事件清除缓存，下一次请求即可看到禁用状态
+	users.disabled[1] = true
+	if err := c.UserDisabledHandler()(UserDisabledEvent{UserID: 1}); err != nil {
+		t.Fatalf("disabled handler: %v", err)
+	}
+	if disabled, err := c.IsDisabled(1); err != nil || !disabled {
+		t.Fatalf("IsDisabled(1) after disable event = %v, %v, want true", disabled, err)
+	}
+}
@@ -0,0 +1,55 @@
+// Package testutil 提供测试共用的数据库与 Redis 连接，仅供 _test.go 引用
+package testutil
+
+import (
+	"coder_edu_backend/pkg/logger"
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// MySQLDSNEnv 集成测试使用的 MySQL 连接串，需指向已迁移表结构的测试库并开启 parseTime
+const MySQLDSNEnv = "TEST_MYSQL_DSN"
+
+// MySQL 打开 TEST_MYSQL_DSN 指向的测试库，每次调用返回独立连接；未设置时跳过测试
+func MySQL(tb testing.TB) *gorm.DB {
+	tb.Helper()
+	dsn := os.Getenv(MySQLDSNEnv)
+	if dsn == "" {
+		tb.Skip(MySQLDSNEnv + " not set")
+	}
+	initLogger()
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		tb.Fatalf("open mysql: %v", err)
+	}
+	tb.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// Redis 启动进程内的 Redis（miniredis），测试结束时关闭
+func Redis(tb testing.TB) *redis.Client {
+	tb.Helper()
+	initLogger()
+
+	srv := miniredis.RunT(tb)
+	rdb := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	tb.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+func initLogger() {
+	if logger.Log == nil {
+		logger.Log = zap.NewNop()
+	}
+}
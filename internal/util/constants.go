@@ -20,5 +20,9 @@ const (
 )
 
 var (
-	AllowedVideoExtensions = []string{".mp4", ".mov", ".avi", ".mkv", ".wmv", ".flv", ".webm"}
+	AllowedVideoExtensions    = []string{".mp4", ".mov", ".avi", ".mkv", ".wmv", ".flv", ".webm"}
+	AllowedImageExtensions    = []string{".png", ".jpg", ".jpeg", ".gif", ".webp"}
+	AllowedIconExtensions     = []string{".png", ".jpg", ".jpeg", ".svg"}
+	AllowedDocumentExtensions = []string{".pdf", ".doc", ".docx", ".txt", ".md", ".zip"}
+	AllowedChatExtensions     = []string{".jpg", ".jpeg", ".png", ".gif", ".pdf", ".docx", ".txt", ".zip", ".mp4", ".mp3"}
 )
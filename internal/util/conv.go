@@ -2,10 +2,36 @@ package util
 
 import (
 	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
 )
 
-// MustParseUint 将字符串转换为无符号整数，解析失败时返回 0
-func MustParseUint(s string) uint {
-	id, _ := strconv.ParseUint(s, 10, 32)
-	return uint(id)
+// ParseUintParam 解析路径参数为无符号整数，失败时写入统一的 400 响应并返回 false
+func ParseUintParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		BadRequest(c, "invalid "+name)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// ParseUintListQuery 解析逗号分隔的无符号整数查询参数，参数为空时返回 nil；
+// 失败时写入统一的 400 响应并返回 false
+func ParseUintListQuery(c *gin.Context, name string) ([]uint, bool) {
+	raw := strings.TrimSpace(c.Query(name))
+	if raw == "" {
+		return nil, true
+	}
+	var ids []uint
+	for _, part := range strings.Split(raw, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+		if err != nil {
+			BadRequest(c, "invalid "+name+": "+part)
+			return nil, false
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, true
 }
@@ -5,6 +5,9 @@ import "errors"
 var (
 	ErrUserNotFound            = errors.New("用户不存在")
 	ErrEmailRegistered         = errors.New("该邮箱已被注册")
+	ErrWeakPassword            = errors.New("密码不符合安全要求")
+	ErrLastAdmin               = errors.New("不能降级、禁用或删除最后一个管理员")
+	ErrAccountDisabled         = errors.New("账号已被禁用")
 	ErrPermissionDenied        = errors.New("permission denied")
 	ErrLevelNotFound           = errors.New("level not found")
 	ErrLevelNotAccessible      = errors.New("level not accessible")
@@ -20,9 +23,17 @@ var (
 	ErrTitleRequired           = errors.New("title required")
 	ErrAbilityRequired         = errors.New("at least one ability must be selected")
 	ErrVisibleToRequired       = errors.New("visibleTo must be provided when visibleScope is 'specific'")
+	ErrSeasonActive            = errors.New("已有进行中的赛季，请先结束当前赛季")
+	ErrSeasonClosed            = errors.New("赛季已结束")
+	ErrInvalidSeasonRange      = errors.New("赛季结束时间必须晚于开始时间")
+	ErrStreakFreezeNotNeeded   = errors.New("昨天已签到或没有可保留的连续签到，无需使用冻结")
+	ErrNoStreakFreezeLeft      = errors.New("本月冻结次数已用完")
+	ErrInvalidVisibleTo        = errors.New("visibleTo contains users that do not exist or are not students")
 	ErrQuestionTypeRequired    = errors.New("questionType required")
 	ErrContentRequired         = errors.New("content required")
 	ErrQuestionNotBelong       = errors.New("question not belong to level")
+	ErrInvalidPassingMode      = errors.New("passingMode must be 'absolute' or 'percentage' (percentage passingScore 0-100)")
+	ErrAttemptNotBelong        = errors.New("attempt not belong to level")
 	ErrInvalidVideoExt         = errors.New("文件格式不支持，请上传有效的视频文件")
 	ErrInvalidIconExt          = errors.New("文件格式不支持，请上传PNG、JPG或SVG格式")
 	ErrUploadProgressNotFound  = errors.New("upload progress not found")
@@ -30,4 +41,30 @@ var (
 	ErrAnswersFieldMissing     = errors.New("answers field missing")
 	ErrAnswersFieldMustBeArray = errors.New("answers field must be array")
 	ErrResourceNotFound        = errors.New("resource not found")
+	ErrSessionNotFound         = errors.New("学习会话不存在或已结束")
+	ErrQASessionNotFound       = errors.New("会话不存在")
+	ErrQASessionForbidden      = errors.New("无权查看该会话")
+	ErrQAStreamNotFound        = errors.New("生成任务不存在或无权操作")
+	ErrFileTooLarge            = errors.New("文件大小超出限制")
+	ErrFileTypeNotAllowed      = errors.New("不支持的文件类型")
+	ErrUnsupportedLanguage     = errors.New("不支持的编程语言")
+	ErrConversationNotFound    = errors.New("会话不存在")
+	ErrSubmissionNotFound      = errors.New("提交记录不存在")
+	ErrTooManyUploads          = errors.New("同时进行的上传过多，请等待当前上传完成后再试")
+	ErrUploadNotOwned          = errors.New("该上传标识已被其他用户使用")
+	ErrChunkTooSmall           = errors.New("除最后一个分块外，每个分块不能小于 5MB")
+	ErrAssessmentAttemptsUsed  = errors.New("已达到该测试的最大作答次数")
+	ErrRetestDeadlinePassed    = errors.New("重测已截止")
+	ErrLevelValidationFailed   = errors.New("关卡校验未通过，请修正后再发布")
+	ErrNotificationNotFound    = errors.New("通知不存在")
+	ErrNoFieldsToUpdate        = errors.New("没有需要更新的字段")
+	ErrBodyNotJSONObject       = errors.New("请求体必须是 JSON 对象")
+	ErrInvalidJSONBody         = errors.New("请求体不是有效的 JSON")
+	ErrFriendLimitReached      = errors.New("好友数量已达上限")
+	ErrPeerFriendLimitReached  = errors.New("对方好友数量已达上限")
+	ErrTooManyPendingRequests  = errors.New("待处理的好友申请过多，请等待对方处理后再试")
+	ErrFriendRequestRateLimit  = errors.New("发送好友申请过于频繁，请稍后再试")
+	ErrReflectionNotFound      = errors.New("该学生尚未填写反思")
+	ErrCommentNotFound         = errors.New("回复的评论不存在")
+	ErrNoSuggestedDifficulty   = errors.New("该题目提交人数不足，暂无建议难度")
 )
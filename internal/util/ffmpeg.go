@@ -19,14 +19,21 @@ type VideoInfo struct {
 	Height   int     `json:"height"`
 	Format   string  `json:"format"`
 	Size     int64   `json:"size"`
+	Codec    string  `json:"codec"`   // 视频流编码，如 h264
+	Bitrate  int64   `json:"bitrate"` // 码率（bit/s），优先取容器总码率
 }
 
 // GetVideoInfo 使用ffmpeg-go库获取视频信息
 func GetVideoInfo(videoPath string) (*VideoInfo, error) {
-	// 检查文件是否存在
-	fileInfo, err := os.Stat(videoPath)
-	if err != nil {
-		return nil, fmt.Errorf("视频文件不存在: %v", err)
+	// 检查文件是否存在（远程地址由 ffprobe 直接读取）
+	isRemote := strings.HasPrefix(videoPath, "http://") || strings.HasPrefix(videoPath, "https://")
+	var fileInfo os.FileInfo
+	if !isRemote {
+		var err error
+		fileInfo, err = os.Stat(videoPath)
+		if err != nil {
+			return nil, fmt.Errorf("视频文件不存在: %v", err)
+		}
 	}
 
 	// 使用ffmpeg-go的Probe函数获取视频元数据
@@ -39,13 +46,16 @@ func GetVideoInfo(videoPath string) (*VideoInfo, error) {
 	var result struct {
 		Streams []struct {
 			CodecType string `json:"codec_type"`
+			CodecName string `json:"codec_name"`
 			Width     int    `json:"width"`
 			Height    int    `json:"height"`
+			BitRate   string `json:"bit_rate"`
 		} `json:"streams"`
 		Format struct {
 			Duration string `json:"duration"`
 			Size     string `json:"size"`
 			Format   string `json:"format_name"`
+			BitRate  string `json:"bit_rate"`
 		} `json:"format"`
 	}
 
@@ -55,14 +65,23 @@ func GetVideoInfo(videoPath string) (*VideoInfo, error) {
 
 	// 提取视频流信息
 	var width, height int
+	var codec, streamBitRate string
 	for _, stream := range result.Streams {
 		if stream.CodecType == "video" {
 			width = stream.Width
 			height = stream.Height
+			codec = stream.CodecName
+			streamBitRate = stream.BitRate
 			break
 		}
 	}
 
+	// 解析码率，部分容器（如 mkv）只在流上记录码率
+	bitrate, err := strconv.ParseInt(result.Format.BitRate, 10, 64)
+	if err != nil {
+		bitrate, _ = strconv.ParseInt(streamBitRate, 10, 64)
+	}
+
 	// 解析时长
 	duration, err := strconv.ParseFloat(result.Format.Duration, 64)
 	if err != nil {
@@ -71,7 +90,7 @@ func GetVideoInfo(videoPath string) (*VideoInfo, error) {
 
 	// 解析文件大小
 	size, err := strconv.ParseInt(result.Format.Size, 10, 64)
-	if err != nil {
+	if err != nil && fileInfo != nil {
 		size = fileInfo.Size()
 	}
 
@@ -90,10 +109,30 @@ func GetVideoInfo(videoPath string) (*VideoInfo, error) {
 		Height:   height,
 		Format:   format,
 		Size:     size,
+		Codec:    codec,
+		Bitrate:  bitrate,
 	}, nil
 }
 
-// GenerateThumbnail 使用ffmpeg-go库生成视频缩略图
+// FFmpegError FFmpeg 执行失败，携带完整命令和错误输出末尾便于排查
+type FFmpegError struct {
+	Command string
+	Stderr  string
+	Err     error
+}
+
+func (e *FFmpegError) Error() string {
+	return fmt.Sprintf("ffmpeg 执行失败: %v", e.Err)
+}
+
+func (e *FFmpegError) Unwrap() error {
+	return e.Err
+}
+
+// ffmpegStderrTail 错误输出只保留末尾部分，真正的失败原因通常在最后几行
+const ffmpegStderrTail = 2048
+
+// GenerateThumbnail 使用ffmpeg-go库生成视频缩略图，失败时返回 *FFmpegError
 func GenerateThumbnail(videoPath, thumbnailPath string, timeOffset string) error {
 	// 确保目录存在
 	dir := strings.Replace(thumbnailPath, "\\", "/", -1)
@@ -106,8 +145,8 @@ func GenerateThumbnail(videoPath, thumbnailPath string, timeOffset string) error
 	}
 
 	// 使用ffmpeg-go的链式API生成缩略图
-	// 使用Run函数直接执行命令，不使用Stderr
-	return ffmpeg.Input(videoPath, ffmpeg.KwArgs{
+	var errOut bytes.Buffer
+	stream := ffmpeg.Input(videoPath, ffmpeg.KwArgs{
 		"ss": timeOffset, // 从视频的哪个时间点抓取帧
 	}).
 		Output(thumbnailPath, ffmpeg.KwArgs{
@@ -115,6 +154,42 @@ func GenerateThumbnail(videoPath, thumbnailPath string, timeOffset string) error
 			"q:v":     "2", // 图像质量 (1-31, 越小质量越高)
 		}).
 		OverWriteOutput().
+		WithErrorOutput(&errOut)
+
+	err := stream.Run()
+	if err == nil {
+		// 时间点超出视频时长时 FFmpeg 正常退出但不输出任何帧
+		if info, statErr := os.Stat(thumbnailPath); statErr != nil || info.Size() == 0 {
+			err = fmt.Errorf("未截取到视频帧（时间点 %ss）", timeOffset)
+		}
+	}
+	if err != nil {
+		stderr := errOut.String()
+		if len(stderr) > ffmpegStderrTail {
+			stderr = stderr[len(stderr)-ffmpegStderrTail:]
+		}
+		return &FFmpegError{
+			Command: "ffmpeg " + strings.Join(stream.GetArgs(), " "),
+			Stderr:  stderr,
+			Err:     err,
+		}
+	}
+	return nil
+}
+
+// TranscodeVideo 将视频转码为指定高度（宽度按比例缩放）的 H.264/AAC MP4
+func TranscodeVideo(inputPath, outputPath string, height int) error {
+	return ffmpeg.Input(inputPath).
+		Output(outputPath, ffmpeg.KwArgs{
+			"vf":       fmt.Sprintf("scale=-2:%d", height),
+			"c:v":      "libx264",
+			"preset":   "veryfast",
+			"crf":      "23",
+			"c:a":      "aac",
+			"b:a":      "128k",
+			"movflags": "+faststart", // 元数据前置，便于边下边播
+		}).
+		OverWriteOutput().
 		Run()
 }
 
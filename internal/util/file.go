@@ -2,11 +2,124 @@ package util
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"path/filepath"
 	"strings"
+
+	"github.com/gin-gonic/gin"
 )
 
+// MB 将兆字节数转换为字节数
+func MB(n int) int64 {
+	return int64(n) << 20
+}
+
+// imageContentTypes 位图扩展名按文件头识别出的内容类型
+var imageContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// isImageExt 判断扩展名是否为需要校验文件头的位图格式
+func isImageExt(ext string) bool {
+	switch ext {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+// ValidateUpload 校验上传文件的扩展名和大小，位图扩展名还会按文件头确认内容确实是图片。
+// allowedExts 为空时不限制扩展名，maxBytes <= 0 时不限制大小
+func ValidateUpload(file *multipart.FileHeader, allowedExts []string, maxBytes int64) error {
+	if maxBytes > 0 && file.Size > maxBytes {
+		return fmt.Errorf("%w: 最大 %dMB", ErrFileTooLarge, maxBytes>>20)
+	}
+	if len(allowedExts) == 0 {
+		return nil
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	for _, allowed := range allowedExts {
+		if ext == allowed {
+			if isImageExt(ext) {
+				return checkImageContent(file, ext)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrFileTypeNotAllowed, ext)
+}
+
+// checkImageContent 读取文件头识别内容类型，拒绝改了扩展名的非图片文件
+func checkImageContent(file *multipart.FileHeader, ext string) error {
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	if !imageContentTypes[http.DetectContentType(head[:n])] {
+		return fmt.Errorf("%w: %s 文件内容不是图片", ErrFileTypeNotAllowed, ext)
+	}
+	return nil
+}
+
+// uploadFormOverhead multipart 表单中除文件内容外的边界、头部及其他表单字段的余量
+const uploadFormOverhead = 1 << 20
+
+// LimitUploadBody 在解析表单之前按单文件上限限制请求体，需在 FormFile/ShouldBind 之前调用。
+// Content-Length 已超限时直接返回 413；否则边读边计数，超限即中止解析，不会把超大文件完整写入临时目录。
+// maxBytes <= 0 时不限制，返回 false 时已写入响应
+func LimitUploadBody(c *gin.Context, maxBytes int64) bool {
+	if maxBytes <= 0 {
+		return true
+	}
+	limit := maxBytes + uploadFormOverhead
+	tooLarge := func() bool {
+		Error(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("%s: 最大 %dMB", ErrFileTooLarge.Error(), maxBytes>>20))
+		return false
+	}
+	if c.Request.ContentLength > limit {
+		return tooLarge()
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	if err := c.Request.ParseMultipartForm(multipartMemory); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return tooLarge()
+		}
+		// 其他解析错误（如缺少文件字段、非 multipart 请求）交给后续的 FormFile/ShouldBind 按原有方式处理
+	}
+	return true
+}
+
+// multipartMemory 解析 multipart 表单时保存在内存中的上限，与 gin 默认的 MaxMultipartMemory 一致
+const multipartMemory = 32 << 20
+
+// CheckUpload 调用 ValidateUpload，失败时写入响应（超限 413，类型不符 400）并返回 false
+func CheckUpload(c *gin.Context, file *multipart.FileHeader, allowedExts []string, maxBytes int64) bool {
+	err := ValidateUpload(file, allowedExts, maxBytes)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, ErrFileTooLarge) {
+		Error(c, http.StatusRequestEntityTooLarge, err.Error())
+	} else {
+		BadRequest(c, err.Error())
+	}
+	return false
+}
+
 // ValidateMimeType 深度校验文件 MIME 类型
 // allowedTypes: 允许的 MIME 前缀或完整类型，如 "image/", "video/", "application/pdf"
 func ValidateMimeType(reader io.Reader, allowedTypes []string) (string, error) {
@@ -0,0 +1,126 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newUploadContext(t *testing.T, size int, declareLength bool) (*gin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(bytes.Repeat([]byte("a"), size))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if !declareLength {
+		req.ContentLength = -1
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	return c, w
+}
+
+func TestLimitUploadBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const max = 1 << 20
+
+	tests := []struct {
+		name          string
+		size          int
+		declareLength bool
+		want          bool
+	}{
+		{"within limit", max / 2, true, true},
+		{"content length over limit", 3 * max, true, false},
+		{"streamed body over limit", 3 * max, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newUploadContext(t, tt.size, tt.declareLength)
+			if got := LimitUploadBody(c, max); got != tt.want {
+				t.Fatalf("LimitUploadBody() = %v, want %v", got, tt.want)
+			}
+			if !tt.want {
+				if w.Code != http.StatusRequestEntityTooLarge {
+					t.Fatalf("status = %d, want 413", w.Code)
+				}
+				return
+			}
+			file, err := c.FormFile("file")
+			if err != nil {
+				t.Fatalf("FormFile after limit: %v", err)
+			}
+			if file.Size != int64(tt.size) {
+				t.Fatalf("file size = %d, want %d", file.Size, tt.size)
+			}
+		})
+	}
+}
+
+// newFileHeader 通过解析真实的 multipart 请求构造可 Open 的 FileHeader
+func newFileHeader(t *testing.T, name string, content []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	return req.MultipartForm.File["file"][0]
+}
+
+func TestValidateUploadImageContent(t *testing.T) {
+	gif := []byte("GIF89a\x01\x00\x01\x00\x80\x00\x00\x00\x00\x00\xff\xff\xff!\xf9\x04\x01\x00\x00\x00\x00,\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02D\x01\x00;")
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	html := []byte("<html><script>alert(1)</script></html>")
+
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+		exts    []string
+		wantErr bool
+	}{
+		{"gif avatar", "a.GIF", gif, AllowedImageExtensions, false},
+		{"gif in chat", "a.gif", gif, AllowedChatExtensions, false},
+		{"png named jpg", "a.jpg", png, AllowedImageExtensions, false},
+		{"html named gif", "a.gif", html, AllowedImageExtensions, true},
+		{"html named png in chat", "a.png", html, AllowedChatExtensions, true},
+		{"text file in chat", "a.txt", html, AllowedChatExtensions, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateUpload(newFileHeader(t, tt.file, tt.content), tt.exts, MB(1))
+			if tt.wantErr {
+				if !errors.Is(err, ErrFileTypeNotAllowed) {
+					t.Fatalf("ValidateUpload(%s) = %v, want ErrFileTypeNotAllowed", tt.file, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateUpload(%s) = %v, want nil", tt.file, err)
+			}
+		})
+	}
+}
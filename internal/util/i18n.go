@@ -0,0 +1,112 @@
+package util
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 支持的响应语言
+const (
+	LangZhCN = "zh-CN"
+	LangEn   = "en"
+)
+
+// messages 响应文案目录。以接口原有文案作为 key，按语言给出译文；
+// 未收录的 key 或请求未指定支持的语言时原样返回，保证迁移前后行为一致
+var messages = map[string]map[string]string{
+	// 通用
+	"Unauthorized":          {LangZhCN: "未登录或登录已过期"},
+	"Forbidden":             {LangZhCN: "没有权限执行该操作"},
+	"Resource not found":    {LangZhCN: "资源不存在"},
+	"Internal server error": {LangZhCN: "服务器内部错误"},
+	"请求格式错误":                {LangEn: "Invalid request format"},
+
+	// 认证
+	"该邮箱已被注册":  {LangEn: "This email is already registered"},
+	"人机验证失败":   {LangEn: "Captcha verification failed"},
+	"请先完成人机验证": {LangEn: "Please complete the captcha first"},
+	"账号已被禁用":   {LangEn: "Account disabled"},
+
+	// IM
+	"申请已发送":      {LangEn: "Friend request sent"},
+	"好友已删除":      {LangEn: "Friend removed"},
+	"好友数量已达上限":   {LangEn: "You have reached the friend limit"},
+	"对方好友数量已达上限": {LangEn: "The other user has reached the friend limit"},
+	"待处理的好友申请过多，请等待对方处理后再试":           {LangEn: "Too many pending friend requests, please wait for them to be handled"},
+	"发送好友申请过于频繁，请稍后再试":                {LangEn: "Too many friend requests, please try again later"},
+	"搜索关键字不能为空":                       {LangEn: "Search keyword is required"},
+	"开始时间格式错误":                        {LangEn: "Invalid start time format"},
+	"结束时间格式错误":                        {LangEn: "Invalid end time format"},
+	"文件不能为空":                          {LangEn: "File is required"},
+	"打开文件失败":                          {LangEn: "Failed to open file"},
+	"非法的文件内容":                         {LangEn: "Invalid file content"},
+	"上传文件失败":                          {LangEn: "Failed to upload file"},
+	"type 只能是 private、group 或 saved":  {LangEn: "type must be private, group or saved"},
+	"sort 只能是 last_message 或 updated": {LangEn: "sort must be last_message or updated"},
+
+	// 社区
+	"标题和内容不能为空":                     {LangEn: "Title and content are required"},
+	"标题字数过多（最多100个字符）":              {LangEn: "Title is too long (max 100 characters)"},
+	"讨论内容过长":                        {LangEn: "Content is too long"},
+	"内容不能为空":                        {LangEn: "Content is required"},
+	"每天最多只能分享3次资源":                  {LangEn: "You can share at most 3 resources per day"},
+	"未找到上传的文件":                      {LangEn: "No uploaded file found"},
+	"Post deleted successfully":     {LangZhCN: "帖子已删除"},
+	"Comment deleted successfully":  {LangZhCN: "评论已删除"},
+	"Resource deleted successfully": {LangZhCN: "资源已删除"},
+	"Invalid question ID":           {LangZhCN: "无效的问题ID"},
+	"Invalid content ID":            {LangZhCN: "无效的内容ID"},
+}
+
+// Lang 按 Accept-Language 的权重选择响应语言，没有可支持的语言时返回空字符串
+func Lang(c *gin.Context) string {
+	header := c.GetHeader("Accept-Language")
+	if header == "" {
+		return ""
+	}
+
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if tag != "" && q > 0 {
+			candidates = append(candidates, candidate{tag, q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, cand := range candidates {
+		switch {
+		case cand.tag == "zh" || strings.HasPrefix(cand.tag, "zh-"):
+			return LangZhCN
+		case cand.tag == "en" || strings.HasPrefix(cand.tag, "en-"):
+			return LangEn
+		}
+	}
+	return ""
+}
+
+// T 返回 key 在请求语言下的文案，未收录时原样返回 key
+func T(c *gin.Context, key string) string {
+	if lang := Lang(c); lang != "" {
+		if msg, ok := messages[key][lang]; ok {
+			return msg
+		}
+	}
+	return key
+}
@@ -46,12 +46,21 @@ func Error(c *gin.Context, code int, message string) {
 	})
 }
 
+// ErrorWithData 返回错误响应并附带结构化数据，便于前端展示冲突或校验详情
+func ErrorWithData(c *gin.Context, code int, message string, data interface{}) {
+	c.JSON(code, Response{
+		Code:    code,
+		Message: message,
+		Data:    data,
+	})
+}
+
 func Unauthorized(c *gin.Context) {
-	Error(c, http.StatusUnauthorized, "Unauthorized")
+	Error(c, http.StatusUnauthorized, T(c, "Unauthorized"))
 }
 
 func Forbidden(c *gin.Context) {
-	Error(c, http.StatusForbidden, "Forbidden")
+	Error(c, http.StatusForbidden, T(c, "Forbidden"))
 }
 
 func BadRequest(c *gin.Context, message string) {
@@ -59,14 +68,14 @@ func BadRequest(c *gin.Context, message string) {
 }
 
 func NotFound(c *gin.Context) {
-	Error(c, http.StatusNotFound, "Resource not found")
+	Error(c, http.StatusNotFound, T(c, "Resource not found"))
 }
 
 func InternalServerError(c *gin.Context) {
-	Error(c, http.StatusInternalServerError, "Internal server error")
+	Error(c, http.StatusInternalServerError, T(c, "Internal server error"))
 }
 
 func LogInternalError(c *gin.Context, err error) {
-	logger.Log.Error("Internal server error", zap.Error(err))
+	logger.FromContext(c.Request.Context()).Error("Internal server error", zap.Error(err), zap.String("path", c.Request.URL.Path))
 	InternalServerError(c)
 }
@@ -63,8 +63,10 @@ func InitDB(cfg *config.DatabaseConfig, mode string, forceMigrate ...bool) (*gor
 			&model.User{},
 			&model.Achievement{},
 			&model.Resource{},
+			&model.TranscodeJob{},
 			&model.Task{},
 			&model.Motivation{},
+			&model.MotivationSchedule{},
 			&model.LearningModule{},
 			&model.UserProgress{},
 			&model.LearningLog{},
@@ -85,6 +87,7 @@ func InitDB(cfg *config.DatabaseConfig, mode string, forceMigrate ...bool) (*gor
 			&model.ResourceCompletion{},
 			&model.TeacherWeeklyTask{},
 			&model.TaskItem{},
+			&model.WeeklyTaskTemplate{},
 			&model.DailyTaskCompletion{},
 			&model.Level{},
 			&model.LevelVersion{},
@@ -102,6 +105,7 @@ func InitDB(cfg *config.DatabaseConfig, mode string, forceMigrate ...bool) (*gor
 			&model.Assessment{},
 			&model.AssessmentQuestion{},
 			&model.AssessmentSubmission{},
+			&model.AssessmentShuffleSeed{},
 			&model.LearningPathMaterial{},
 			&model.LearningPathCompletion{},
 			&model.KnowledgePoint{},
@@ -118,13 +122,24 @@ func InitDB(cfg *config.DatabaseConfig, mode string, forceMigrate ...bool) (*gor
 			&model.MigrationSubmission{},
 			&model.MigrationAnswer{},
 			&model.Reflection{},
+			&model.ReflectionComment{},
 			&model.Conversation{},
 			&model.ConversationMember{},
 			&model.Message{},
+			&model.ConversationEvent{},
+			&model.MessageMention{},
 			&model.Friendship{},
 			&model.FriendRequest{},
 			&model.CommunityResource{},
 			&model.AIQAHistory{},
+			&model.PointsLedger{},
+			&model.Season{},
+			&model.SeasonResult{},
+			&model.Announcement{},
+			&model.AnnouncementRecipient{},
+			&model.Notification{},
+			&model.NotificationPreference{},
+			&model.AuditLog{},
 		)
 
 		// 恢复外键检查
@@ -161,6 +176,20 @@ func InitDB(cfg *config.DatabaseConfig, mode string, forceMigrate ...bool) (*gor
 			}
 		}
 
+		// 旧会话没有最后消息时间，用活跃时间回填，保证按最后消息排序时位置不变
+		db.Exec("UPDATE conversations SET last_message_at = updated_at WHERE last_message_at IS NULL")
+
+		// 积分流水上线前的积分没有流水记录，为每个用户补一条期初余额，使 User.Points 等于流水合计。
+		// 只在尚无期初余额记录时执行一次（包括余额为 0 的用户），之后的不一致由管理员一致性检查发现
+		var openingCount int64
+		db.Model(&model.PointsLedger{}).Where("source_type = ?", model.PointSourceOpeningBalance).Count(&openingCount)
+		if openingCount == 0 {
+			db.Exec(`INSERT INTO points_ledger (created_at, user_id, delta, reason, source_type, source_id)
+				SELECT NOW(3), u.id, u.points - COALESCE(SUM(l.delta), 0), '期初余额', ?, CAST(u.id AS CHAR)
+				FROM users u LEFT JOIN points_ledger l ON l.user_id = u.id
+				GROUP BY u.id, u.points`, model.PointSourceOpeningBalance)
+		}
+
 		// 默认的激励短句
 		var seedCount int64
 		db.Model(&model.Motivation{}).Count(&seedCount)
@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader 请求ID的请求头和响应头，客户端或网关传入时沿用，否则由服务端生成
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen 客户端传入的请求ID超过该长度时忽略，避免日志被超长字段污染
+const maxRequestIDLen = 64
+
+// isValidRequestID 客户端传入的请求ID只接受字母、数字和 . _ : -，
+// 含换行、引号等其他字符时视为无效，防止伪造日志行
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '_', c == ':', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 将请求ID写入 context，供后续日志关联
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext 获取 context 中的请求ID，没有时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext 返回带 requestId 字段的 logger，context 中没有请求ID时返回全局 Log
+func FromContext(ctx context.Context) *zap.Logger {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return Log.With(zap.String("requestId", id))
+	}
+	return Log
+}
+
+// GinMiddleware 为每个请求分配请求ID（写入 context 和响应头），并在请求结束后记录方法、路径、状态码和耗时
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Header(RequestIDHeader, requestID)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), requestID))
+
+		c.Next()
+
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		status := c.Writer.Status()
+		fields := []zap.Field{
+			zap.String("requestId", requestID),
+			zap.String("method", c.Request.Method),
+			zap.String("path", path),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("clientIP", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+
+		switch {
+		case status >= 500:
+			Log.Warn("request", fields...)
+		default:
+			Log.Info("request", fields...)
+		}
+	}
+}
+
+// GinRecovery 捕获 handler 中的 panic，记录带请求ID的日志并返回 500，需注册在 GinMiddleware 之后
+func GinRecovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		FromContext(c.Request.Context()).Error("panic recovered",
+			zap.Any("panic", recovered),
+			zap.String("path", c.Request.URL.Path),
+		)
+		c.AbortWithStatus(500)
+	})
+}
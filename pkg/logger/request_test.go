@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestIsValidRequestID(t *testing.T) {
+	cases := []struct {
+		id   string
+		want bool
+	}{
+		{"", false},
+		{"7f3c2a9e-1b4d-4c1a-9f0e-123456789abc", true},
+		{"gw.node-1:req_42", true},
+		{strings.Repeat("a", maxRequestIDLen), true},
+		{strings.Repeat("a", maxRequestIDLen+1), false},
+		{"abc\ninjected", false},
+		{"abc def", false},
+		{`abc"}`, false},
+		{"请求", false},
+	}
+	for _, tc := range cases {
+		if got := isValidRequestID(tc.id); got != tc.want {
+			t.Errorf("isValidRequestID(%q) = %v, want %v", tc.id, got, tc.want)
+		}
+	}
+}
+
+func TestGinMiddlewareReplacesInvalidRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	Log = zap.NewNop()
+
+	var seen string
+	r := gin.New()
+	r.Use(GinMiddleware())
+	r.GET("/", func(c *gin.Context) {
+		seen = RequestIDFromContext(c.Request.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "abc\r\nfake log line")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if seen == "" || strings.ContainsAny(seen, "\r\n ") {
+		t.Fatalf("request id not replaced: %q", seen)
+	}
+	if got := w.Header().Get(RequestIDHeader); got != seen {
+		t.Fatalf("response header %q, context %q", got, seen)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "gw-123")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if seen != "gw-123" {
+		t.Fatalf("valid request id not kept: %q", seen)
+	}
+}
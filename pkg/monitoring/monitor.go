@@ -35,6 +35,39 @@ var (
 		},
 	)
 
+	IMShardClients = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "im_shard_clients",
+			Help: "Current number of WebSocket clients per ChatHub shard on this node",
+		},
+		[]string{"node", "shard"},
+	)
+
+	// 消息持久化队列（Redis Stream）指标，由各节点定期采集，node 区分上报节点（即 ChatHub 节点 ID）
+	IMStreamLength = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "im_message_stream_length",
+			Help: "Number of entries in the chat message Redis Stream (XLEN)",
+		},
+		[]string{"node"},
+	)
+
+	IMStreamPending = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "im_message_stream_pending",
+			Help: "Chat messages delivered to the consumer group but not yet acknowledged",
+		},
+		[]string{"node"},
+	)
+
+	IMStreamLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "im_message_stream_lag",
+			Help: "Chat messages in the Redis Stream not yet delivered to the consumer group",
+		},
+		[]string{"node"},
+	)
+
 	IMMessageCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "im_messages_total",
@@ -42,13 +75,53 @@ var (
 		},
 		[]string{"type", "direction"}, // type: chat, status, typing; direction: in, out
 	)
+
+	// AI 模型服务相关指标
+	AIProviderRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_provider_requests_total",
+			Help: "Total number of AI requests served by each provider",
+		},
+		[]string{"provider"},
+	)
+
+	AIProviderFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ai_provider_failures_total",
+			Help: "Total number of AI provider failures that triggered a fallback",
+		},
+		[]string{"provider", "reason"}, // reason: connection, status_5xx, stream
+	)
+
+	// 视频处理相关指标
+	FFmpegJobsRunning = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ffmpeg_jobs_running",
+			Help: "Current number of running FFmpeg jobs",
+		},
+	)
+
+	FFmpegJobsQueued = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ffmpeg_jobs_queued",
+			Help: "Current number of FFmpeg jobs waiting for a free slot",
+		},
+	)
 )
 
 func Init() {
 	prometheus.MustRegister(RequestCounter)
 	prometheus.MustRegister(RequestDuration)
 	prometheus.MustRegister(IMOnlineUsers)
+	prometheus.MustRegister(IMShardClients)
 	prometheus.MustRegister(IMMessageCounter)
+	prometheus.MustRegister(IMStreamLength)
+	prometheus.MustRegister(IMStreamPending)
+	prometheus.MustRegister(IMStreamLag)
+	prometheus.MustRegister(AIProviderRequests)
+	prometheus.MustRegister(AIProviderFailures)
+	prometheus.MustRegister(FFmpegJobsRunning)
+	prometheus.MustRegister(FFmpegJobsQueued)
 }
 
 func MetricsMiddleware() gin.HandlerFunc {
@@ -1,7 +1,12 @@
 package security
 
 import (
+	"coder_edu_backend/internal/config"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -9,33 +14,111 @@ import (
 	"golang.org/x/time/rate"
 )
 
-// CORS 中间件 仅允许白名单中的Origin，支持Credentials
-func CORS(allowedOrigins []string) gin.HandlerFunc {
-	originSet := make(map[string]bool, len(allowedOrigins))
+// originMatcher 根据 CORS 白名单判断来源是否允许
+type originMatcher struct {
+	any     bool
+	origins map[string]bool
+}
+
+func newOriginMatcher(allowedOrigins []string) originMatcher {
+	m := originMatcher{origins: make(map[string]bool, len(allowedOrigins))}
 	for _, o := range allowedOrigins {
-		originSet[o] = true
+		o = strings.TrimRight(strings.TrimSpace(o), "/")
+		if o == "*" {
+			m.any = true
+			continue
+		}
+		m.origins[strings.ToLower(o)] = true
 	}
+	return m
+}
+
+func (m originMatcher) allowed(origin string) bool {
+	return m.any || m.origins[strings.ToLower(origin)]
+}
+
+// CORS 中间件 仅允许白名单中的Origin；白名单为 "*" 时允许任意来源但不下发 Credentials。
+// 预检请求（OPTIONS + Access-Control-Request-Method）在此直接应答，来源不在白名单时返回 403
+func CORS(cfg config.CORSConfig) gin.HandlerFunc {
+	matcher := newOriginMatcher(cfg.AllowedOrigins)
+	allowMethods := strings.Join(cfg.AllowedMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	exposeHeaders := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(cfg.MaxAgeSeconds)
 
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.Request.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin == "" {
+			if preflight {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
 
-		if origin != "" && originSet[origin] {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
-			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		if !matcher.allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
 		}
 
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
+		if matcher.any && !cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials && !matcher.any {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", allowMethods)
+			if allowHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", allowHeaders)
+			} else if reqHeaders := c.Request.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+			if cfg.MaxAgeSeconds > 0 {
+				h.Set("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
 
+		if exposeHeaders != "" {
+			h.Set("Access-Control-Expose-Headers", exposeHeaders)
+		}
 		c.Next()
 	}
 }
 
+// WebSocketOriginChecker 返回 WebSocket 握手的 Origin 校验函数，与 CORS 使用同一份白名单。
+// 无 Origin 的非浏览器客户端及同源请求始终放行
+func WebSocketOriginChecker(cfg config.CORSConfig) func(r *http.Request) bool {
+	matcher := newOriginMatcher(cfg.AllowedOrigins)
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || matcher.allowed(origin) {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		return strings.EqualFold(u.Host, r.Host)
+	}
+}
+
 // Secure 中间件
 func Secure() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -54,6 +137,58 @@ func Secure() gin.HandlerFunc {
 	}
 }
 
+// staticMimeTypes 上传文件按扩展名返回的 Content-Type，不依赖系统 mime 表（部分环境缺少 svg、webm 等类型）
+var staticMimeTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".svg":  "image/svg+xml",
+	".ico":  "image/x-icon",
+	".mp4":  "video/mp4",
+	".webm": "video/webm",
+	".ogg":  "video/ogg",
+	".mov":  "video/quicktime",
+	".m3u8": "application/x-mpegURL",
+	".ts":   "video/mp2t",
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".pdf":  "application/pdf",
+	".txt":  "text/plain; charset=utf-8",
+	".md":   "text/plain; charset=utf-8",
+	".c":    "text/plain; charset=utf-8",
+	".json": "application/json",
+	".zip":  "application/zip",
+}
+
+// staticInlineExts 浏览器可直接渲染的扩展名，其余类型（含 html 等可执行脚本的文档）一律作为附件下载
+var staticInlineExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".svg": true, ".ico": true,
+	".mp4": true, ".webm": true, ".ogg": true, ".mov": true, ".m3u8": true, ".ts": true,
+	".mp3": true, ".wav": true, ".pdf": true, ".txt": true, ".md": true, ".c": true, ".json": true,
+}
+
+// StaticFileHeaders 本地上传文件的响应头中间件：按扩展名设置 Content-Type 和缓存策略，
+// 并通过 CSP sandbox 禁止文件内脚本执行，防止上传的 SVG/HTML 触发 XSS
+func StaticFileHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ext := strings.ToLower(filepath.Ext(c.Request.URL.Path))
+		if ctype, ok := staticMimeTypes[ext]; ok {
+			c.Header("Content-Type", ctype)
+		}
+		if !staticInlineExts[ext] {
+			c.Header("Content-Disposition", "attachment")
+		}
+		c.Header("Content-Security-Policy", "default-src 'none'; img-src 'self' data:; media-src 'self'; style-src 'unsafe-inline'; sandbox")
+		c.Header("X-Content-Type-Options", "nosniff")
+		// 上传文件名带时间戳和随机串，内容不会变化，可长期缓存
+		c.Header("Cache-Control", "public, max-age=604800")
+
+		c.Next()
+	}
+}
+
 // visitor 包装限流器和最后活跃时间，用于定期清理
 type visitor struct {
 	limiter  *rate.Limiter
@@ -0,0 +1,79 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStaticFileHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	files := map[string]string{
+		"logo.svg":   `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`,
+		"LOGO.SVG":   `<svg xmlns="http://www.w3.org/2000/svg"></svg>`,
+		"clip.webm":  "\x1a\x45\xdf\xa3",
+		"clip.mp4":   "\x00\x00\x00\x18ftypmp42",
+		"cover.png":  "\x89PNG\r\n\x1a\n",
+		"notes.pdf":  "%PDF-1.4",
+		"main.c":     "int main(void) { return 0; }",
+		"page.html":  "<html><script>alert(1)</script></html>",
+		"bundle.zip": "PK\x03\x04",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	r := gin.New()
+	r.Group("/uploads", StaticFileHeaders()).Static("/", dir)
+
+	cases := []struct {
+		name        string
+		contentType string // 为空表示不检查
+		attachment  bool
+	}{
+		{"logo.svg", "image/svg+xml", false},
+		{"LOGO.SVG", "image/svg+xml", false},
+		{"clip.webm", "video/webm", false},
+		{"clip.mp4", "video/mp4", false},
+		{"cover.png", "image/png", false},
+		{"notes.pdf", "application/pdf", false},
+		{"main.c", "text/plain; charset=utf-8", false},
+		{"page.html", "", true},
+		{"bundle.zip", "application/zip", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/uploads/"+tc.name, nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			if tc.contentType != "" {
+				if got := w.Header().Get("Content-Type"); got != tc.contentType {
+					t.Fatalf("Content-Type = %q, want %q", got, tc.contentType)
+				}
+			}
+			if got := w.Header().Get("Content-Disposition") == "attachment"; got != tc.attachment {
+				t.Fatalf("Content-Disposition = %q, want attachment=%v", w.Header().Get("Content-Disposition"), tc.attachment)
+			}
+			if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "sandbox") || !strings.Contains(csp, "default-src 'none'") {
+				t.Fatalf("Content-Security-Policy = %q, want sandbox with default-src 'none'", csp)
+			}
+			if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Fatalf("X-Content-Type-Options = %q, want nosniff", got)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "public, max-age=604800" {
+				t.Fatalf("Cache-Control = %q, want public, max-age=604800", got)
+			}
+		})
+	}
+}